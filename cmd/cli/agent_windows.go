@@ -0,0 +1,131 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/profile"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// Per-user agent management. The agent is the service binary run with --user from the user's Run key, so it
+// starts at login inside the user's session, without a console window
+
+const runKeyPath = `Software\Microsoft\Windows\CurrentVersion\Run`
+
+// Selects the per-user scope when the agent is registered for this user, so commands use its data and pipe
+func detectUserScope() {
+	key, err := registry.OpenKey(registry.CURRENT_USER, runKeyPath, registry.QUERY_VALUE)
+	if err != nil {
+		return
+	}
+	defer key.Close()
+
+	if _, _, err := key.GetStringValue(serviceName()); err == nil {
+		profile.SetUserScope(true)
+	}
+}
+
+// Registers the agent to start at login
+func (s *CLIService) installAgent(binPath string) error {
+	binPath, err := resolveServiceBinary(binPath)
+	if err != nil {
+		return err
+	}
+
+	args := append([]string{"--headless", binPath, "--user"}, profileArgs()...)
+	command := "conhost.exe " + windows.ComposeCommandLine(args)
+
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, runKeyPath, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open Run key: %w", err)
+	}
+	defer key.Close()
+
+	if err := key.SetStringValue(serviceName(), command); err != nil {
+		return fmt.Errorf("failed to register agent: %w", err)
+	}
+
+	fmt.Printf("Agent %s registered to start at login (%s)\n", serviceName(), binPath)
+	fmt.Println("Start it now with 'timekeep service start --user'")
+	return nil
+}
+
+// Stops the agent if running and removes it from the Run key
+func (s *CLIService) uninstallAgent() error {
+	if err := s.stopAgent(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	key, err := registry.OpenKey(registry.CURRENT_USER, runKeyPath, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open Run key: %w", err)
+	}
+	defer key.Close()
+
+	if err := key.DeleteValue(serviceName()); err != nil {
+		if errors.Is(err, registry.ErrNotExist) {
+			return fmt.Errorf("agent %s is not installed", serviceName())
+		}
+		return fmt.Errorf("failed to remove agent: %w", err)
+	}
+
+	fmt.Printf("Agent %s uninstalled\n", serviceName())
+	return nil
+}
+
+// Launches the registered agent command in the background
+func (s *CLIService) startAgent() error {
+	profile.SetUserScope(true)
+
+	key, err := registry.OpenKey(registry.CURRENT_USER, runKeyPath, registry.QUERY_VALUE)
+	if err != nil {
+		return fmt.Errorf("agent %s is not installed", serviceName())
+	}
+	defer key.Close()
+
+	command, _, err := key.GetStringValue(serviceName())
+	if err != nil {
+		return fmt.Errorf("agent %s is not installed", serviceName())
+	}
+
+	if resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionHealth)); err == nil && resp.OK {
+		return fmt.Errorf("agent %s is already running", serviceName())
+	}
+
+	name, _, _ := strings.Cut(command, " ")
+	cmd := exec.Command(name)
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: command, CreationFlags: windows.DETACHED_PROCESS | windows.CREATE_NEW_PROCESS_GROUP}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start agent: %w", err)
+	}
+	if err := cmd.Process.Release(); err != nil {
+		return fmt.Errorf("failed to detach agent: %w", err)
+	}
+
+	fmt.Printf("Agent %s started\n", serviceName())
+	return nil
+}
+
+// Asks the running agent to shut down over its pipe
+func (s *CLIService) stopAgent() error {
+	profile.SetUserScope(true)
+
+	resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionShutdown))
+	if err != nil {
+		return fmt.Errorf("agent %s is not running: %w", serviceName(), err)
+	}
+	if err := resp.Err(); err != nil {
+		return fmt.Errorf("failed to stop agent: %w", err)
+	}
+
+	fmt.Printf("Agent %s stopped\n", serviceName())
+	return nil
+}
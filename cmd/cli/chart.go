@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/charts"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/productivity"
+)
+
+// Types of 'timekeep export chart'
+const (
+	ChartTimeline = "timeline" // Sessions of a day as bars, one row per program
+	ChartScores   = "scores"   // Daily productivity score as a line over days
+)
+
+// Renders a chart of chartType over day, in any 'history --date' format, to output as an SVG or PNG image by its
+// extension, or as SVG to stdout when output is empty. Timelines cover today by default, scores the last 30 days
+func (s *CLIService) ExportChart(ctx context.Context, chartType, day string, includeArchive bool, output string) error {
+	switch chartType {
+	case ChartTimeline:
+		if day == "" {
+			day = "today"
+		}
+	case ChartScores:
+		if day == "" {
+			day = "30d"
+		}
+	default:
+		return fmt.Errorf("unknown chart type %q, use timeline or scores", chartType)
+	}
+
+	out := io.Writer(os.Stdout)
+	format := charts.FormatSVG
+	if output != "" {
+		format = charts.FormatOf(output)
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("error creating %s: %w", output, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if chartType == ChartScores {
+		return s.chartScores(ctx, out, format, day, includeArchive, output)
+	}
+
+	loc := s.location()
+	now := time.Now()
+	span, err := dates.Parse(day, now, loc, s.dateOptions())
+	if err != nil {
+		return err
+	}
+	lanes, err := s.timelineLanes(ctx, day, includeArchive, span.End, now)
+	if err != nil {
+		return err
+	}
+
+	title := s.formatDate(span.Start)
+	if last := span.End.AddDate(0, 0, -1); last.After(span.Start) {
+		title += " to " + s.formatDate(last)
+	}
+	if err := charts.Timeline(out, format, title, span.Start, span.End, lanes); err != nil {
+		return err
+	}
+
+	if output != "" {
+		fmt.Printf("Wrote a timeline of %s to %s\n", plural(int64(len(lanes)), "program"), output)
+	}
+	return nil
+}
+
+// Renders the daily productivity score, by the weights and formula in config, of each day of day to out
+func (s *CLIService) chartScores(ctx context.Context, out io.Writer, format, day string, includeArchive bool, output string) error {
+	f, err := productivity.Formula(s.Config)
+	if err != nil {
+		return err
+	}
+	days, summaries, err := s.productivityDays(ctx, day, "", "", "", "", "", "", "", includeArchive)
+	if err != nil {
+		return err
+	}
+	if len(days) < 2 {
+		return errors.New("a score chart needs at least two days, use a span such as 30d or 2026-03")
+	}
+
+	scores := make([]float64, len(summaries))
+	for i, sum := range summaries {
+		scores[i] = sum.Score(f)
+	}
+	title := fmt.Sprintf("Productivity score (%s), %s to %s", f, s.formatDate(days[0]), s.formatDate(days[len(days)-1]))
+	if err := charts.Scores(out, format, title, days, scores); err != nil {
+		return err
+	}
+
+	if output != "" {
+		fmt.Printf("Wrote the scores of %s to %s\n", plural(int64(len(days)), "day"), output)
+	}
+	return nil
+}
+
+// Returns a lane per program with sessions open on day, in the order they first started, including sessions started
+// before end and still running, up to now. Lanes are grouped by the program's category
+func (s *CLIService) timelineLanes(ctx context.Context, day string, includeArchive bool, end, now time.Time) ([]charts.Lane, error) {
+	programs, err := s.PrRepo.GetAllPrograms(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting programs: %w", err)
+	}
+	categories := make(map[string]string, len(programs))
+	for _, p := range programs {
+		categories[p.Name] = p.Category.String
+	}
+
+	var lanes []charts.Lane
+	index := map[string]int{}
+	add := func(program string, start, end time.Time) {
+		i, ok := index[program]
+		if !ok {
+			i = len(lanes)
+			index[program] = i
+			lanes = append(lanes, charts.Lane{Label: program, Group: categories[program]})
+		}
+		lanes[i].Spans = append(lanes[i].Spans, charts.Span{Start: start, End: end})
+	}
+
+	err = s.streamSessionHistory(ctx, "", day, "", "", s.Device, includeArchive, func(session database.SessionHistory) {
+		add(session.ProgramName, session.StartTime, session.EndTime)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := s.activeSessions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting active sessions: %w", err)
+	}
+	for _, session := range active {
+		if session.StartTime.Before(end) {
+			add(session.ProgramName, session.StartTime, now)
+		}
+	}
+	return lanes, nil
+}
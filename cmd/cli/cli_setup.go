@@ -1,7 +1,13 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"io"
+
 	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/policy"
+	"github.com/jms-guy/timekeep/internal/privacy"
 	"github.com/jms-guy/timekeep/internal/repository"
 	mysql "github.com/jms-guy/timekeep/sql"
 )
@@ -12,18 +18,33 @@ type CLIService struct {
 	PrRepo     repository.ProgramRepository
 	AsRepo     repository.ActiveRepository
 	HsRepo     repository.HistoryRepository
+	ArchRepo   repository.ArchiveRepository
+	TxRepo     repository.TxRepository
+	SyncRepo   repository.TxRepository // Transactions over names as stored, for sync, the sync server and team reports
+	TokenRepo  repository.TokenRepository
+	GroupRepo  repository.GroupRepository
+	EstRepo    repository.EstimateRepository
 	ServiceCmd ServiceCommander
 	CmdExe     CommandExecutor
 	Config     *config.Config
 	Version    string
+	NoNotify   bool           // Skip the service refresh after program changes, set by --no-notify
+	DB         *sql.DB        // Connection behind the repositories, used by doctor's database checks
+	Policy     *policy.Policy // Managed tracking policy last fetched by the service, nil when none applies
+	Input      io.Reader      // Answers to questions, such as which program a name meant, nil when stdin isn't a terminal
+	Device     string         // Device whose sessions reports count, set by --device, empty for every device
+	deferred   bool           // Database not opened yet, see Setup
 }
 
 // Creates new CLI service instance
-func CreateCLIService(pr repository.ProgramRepository, ar repository.ActiveRepository, hr repository.HistoryRepository, sc ServiceCommander, cmdE CommandExecutor) *CLIService {
+func CreateCLIService(pr repository.ProgramRepository, ar repository.ActiveRepository, hr repository.HistoryRepository, arch repository.ArchiveRepository, tx repository.TxRepository, sc ServiceCommander, cmdE CommandExecutor) *CLIService {
 	return &CLIService{
 		PrRepo:     pr,
 		AsRepo:     ar,
 		HsRepo:     hr,
+		ArchRepo:   arch,
+		TxRepo:     tx,
+		SyncRepo:   tx,
 		ServiceCmd: sc,
 		CmdExe:     cmdE,
 		Version:    Version,
@@ -31,23 +52,71 @@ func CreateCLIService(pr repository.ProgramRepository, ar repository.ActiveRepos
 }
 
 func CLIServiceSetup() (*CLIService, error) {
+	service := CreateCLIService(nil, nil, nil, nil, nil, &realServiceCommander{}, &realCommandExecutor{})
+	if err := service.Setup(false); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// Loads the config and, unless deferred, opens the database. A deferred database is opened by connect when a command
+// first needs it, sparing commands mostly served from cache, such as the prompt and status bar, the cost of opening
+// and migrating it
+func (s *CLIService) Setup(deferred bool) error {
+	config, err := config.Load()
+	if err != nil {
+		return err
+	}
+	s.Config = config
+	s.deferred = true
+
+	// An upgrade is recorded in the database, and the upgraded file won't report it again
+	if deferred && config.Upgraded() == nil {
+		return nil
+	}
+	return s.connect()
+}
+
+// Opens the database and the repositories over it, when Setup deferred it
+func (s *CLIService) connect() error {
+	if !s.deferred {
+		return nil
+	}
+
 	db, err := mysql.OpenLocalDatabase()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	store := repository.NewSqliteStore(db)
+	if up := s.Config.Upgraded(); up != nil {
+		if err := mysql.RecordUpgrade(db, mysql.ComponentConfig, mysql.Upgrade{From: int64(up.From), To: int64(up.To)}); err != nil {
+			return err
+		}
+	}
 
-	service := CreateCLIService(store, store, store, &realServiceCommander{}, &realCommandExecutor{})
+	store := repository.NewSqliteStore(db)
+	store.SetTimeout(s.Config.DatabaseTimeout())
 
-	config, err := config.Load()
+	repos, err := privacy.Open(context.Background(), store, s.Config)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	service.Config = config
+	s.PrRepo, s.AsRepo, s.HsRepo, s.ArchRepo, s.TxRepo = repos, repos, repos, repos, repos
+	s.SyncRepo = store
+	s.TokenRepo = store
+	s.GroupRepo = repos
+	s.EstRepo = repos
+	s.DB = db
 
-	return service, nil
+	if s.Config.Policy.Source != "" {
+		if s.Policy, err = policy.Load(); err != nil {
+			return err
+		}
+	}
+
+	s.deferred = false
+	return nil
 }
 
 func CLITestServiceSetup() (*CLIService, error) {
@@ -58,7 +127,10 @@ func CLITestServiceSetup() (*CLIService, error) {
 
 	store := repository.NewSqliteStore(db)
 
-	service := CreateCLIService(store, store, store, &testServiceCommander{}, &testCommandExecutor{})
+	service := CreateCLIService(store, store, store, store, store, &testServiceCommander{}, &testCommandExecutor{})
+	service.TokenRepo = store
+	service.GroupRepo = store
+	service.EstRepo = store
 
 	return service, nil
 }
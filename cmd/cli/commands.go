@@ -1,40 +1,63 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/jms-guy/timekeep/internal/config"
 	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/progname"
+	"github.com/jms-guy/timekeep/internal/repository"
 )
 
-// Adds programs into the database, and sends communication to service to being tracking them
+// Adds programs into the database, and sends communication to service to being tracking them. Programs the managed
+// policy excludes are skipped, and those it maps are filed under its category
 func (s *CLIService) AddPrograms(ctx context.Context, args []string, category, project string) error {
-	categoryNull := sql.NullString{
-		String: category,
-		Valid:  category != "",
-	}
-
 	projectNull := sql.NullString{
 		String: project,
 		Valid:  project != "",
 	}
 
-	for _, program := range args {
-		err := s.PrRepo.AddProgram(ctx, database.AddProgramParams{
-			Name:     strings.ToLower(program),
-			Category: categoryNull,
-			Project:  projectNull,
-		})
-		if err != nil {
-			return fmt.Errorf("error adding program %s: %w", program, err)
+	err := s.TxRepo.WithTx(ctx, func(store repository.Store) error {
+		for _, program := range args {
+			name := progname.Normalize(program)
+			if s.Policy.Excluded(name) {
+				fmt.Printf("Skipping %s, excluded by the managed policy\n", name)
+				continue
+			}
+
+			programCategory := category
+			if mapped, ok := s.Policy.Category(name); ok {
+				programCategory = mapped
+			}
+
+			err := store.AddProgram(ctx, database.AddProgramParams{
+				Name:     name,
+				Category: sql.NullString{String: programCategory, Valid: programCategory != ""},
+				Project:  projectNull,
+			})
+			if err != nil {
+				return fmt.Errorf("error adding program %s: %w", program, err)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	err := s.ServiceCmd.WriteToService()
+	err = s.notifyService()
 	if err != nil {
 		return fmt.Errorf("programs added but failed to notify service: %w", err)
 	}
@@ -44,9 +67,15 @@ func (s *CLIService) AddPrograms(ctx context.Context, args []string, category, p
 
 // Update program's category/project fields and notify service of change
 func (s *CLIService) UpdateProgram(ctx context.Context, args []string, category, project string) error {
-	program := args[0]
+	program, err := s.resolveProgram(ctx, args[0])
+	if err != nil {
+		return err
+	}
 
 	if category != "" {
+		if mapped, ok := s.Policy.Category(program); ok && mapped != category {
+			return fmt.Errorf("category of %s is set to %s by the managed policy", program, mapped)
+		}
 		err := s.PrRepo.UpdateCategory(ctx, database.UpdateCategoryParams{
 			Category: sql.NullString{String: category, Valid: true},
 			Name:     program,
@@ -66,7 +95,7 @@ func (s *CLIService) UpdateProgram(ctx context.Context, args []string, category,
 		}
 	}
 
-	err := s.ServiceCmd.WriteToService()
+	err = s.notifyService()
 	if err != nil {
 		return fmt.Errorf("programs updated but failed to notify service: %w", err)
 	}
@@ -77,12 +106,17 @@ func (s *CLIService) UpdateProgram(ctx context.Context, args []string, category,
 // Removes programs from database, and tells service to stop tracking them
 func (s *CLIService) RemovePrograms(ctx context.Context, args []string, all bool) error {
 	if all {
-		err := s.PrRepo.RemoveAllPrograms(ctx)
+		err := s.TxRepo.WithTx(ctx, func(store repository.Store) error {
+			if err := snapshotForUndo(ctx, store, "remove --all", nil, false); err != nil {
+				return err
+			}
+			return store.RemoveAllPrograms(ctx)
+		})
 		if err != nil {
 			return fmt.Errorf("error removing all programs: %w", err)
 		}
 
-		err = s.ServiceCmd.WriteToService()
+		err = s.notifyService()
 		if err != nil {
 			return fmt.Errorf("error alerting service of program removal: %w", err)
 		}
@@ -94,14 +128,28 @@ func (s *CLIService) RemovePrograms(ctx context.Context, args []string, all bool
 		return fmt.Errorf("missing argument")
 	}
 
-	for _, program := range args {
-		err := s.PrRepo.RemoveProgram(ctx, strings.ToLower(program))
-		if err != nil {
-			return fmt.Errorf("error removing program %s: %w", program, err)
+	programs := make([]string, len(args))
+	for i, program := range args {
+		programs[i] = progname.Normalize(program)
+	}
+
+	err := s.TxRepo.WithTx(ctx, func(store repository.Store) error {
+		if err := snapshotForUndo(ctx, store, "remove "+strings.Join(programs, " "), programs, false); err != nil {
+			return err
 		}
+		for i, program := range programs {
+			err := store.RemoveProgram(ctx, program)
+			if err != nil {
+				return fmt.Errorf("error removing program %s: %w", args[i], err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	err := s.ServiceCmd.WriteToService()
+	err = s.notifyService()
 	if err != nil {
 		return fmt.Errorf("programs removed but failed to notify service: %w", err)
 	}
@@ -109,9 +157,9 @@ func (s *CLIService) RemovePrograms(ctx context.Context, args []string, all bool
 	return nil
 }
 
-// Prints a list of programs currently being tracked by service
+// Prints a list of programs currently being tracked by service, marking those only synced from other devices
 func (s *CLIService) GetList(ctx context.Context) error {
-	programs, err := s.PrRepo.GetAllProgramNames(ctx)
+	programs, err := s.PrRepo.GetAllPrograms(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting list of programs: %w", err)
 	}
@@ -121,8 +169,93 @@ func (s *CLIService) GetList(ctx context.Context) error {
 	}
 
 	for _, program := range programs {
-		fmt.Printf(" • %s\n", program)
+		if program.Remote {
+			fmt.Printf(" • %s (other devices only)\n", program.Name)
+		} else {
+			fmt.Printf(" • %s\n", program.Name)
+		}
+	}
+
+	return nil
+}
+
+// Lists tracked programs with no session since the start of since, parsed like history's --date, along with when
+// each last ran. Running programs are never stale. With clean, asks on out whether to stop tracking each one, reading
+// answers from in
+func (s *CLIService) ListStalePrograms(ctx context.Context, since string, clean bool, in io.Reader) error {
+	span, err := dates.Parse(since, time.Now(), s.location(), s.dateOptions())
+	if err != nil {
+		return err
+	}
+	cutoff := span.Start
+
+	programs, err := s.PrRepo.GetAllProgramNames(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting list of programs: %w", err)
+	}
+	recent, err := s.HsRepo.GetRecentSessionsPerProgram(ctx, 1)
+	if err != nil {
+		return fmt.Errorf("error getting last sessions: %w", err)
+	}
+	active, err := s.AsRepo.GetAllActiveSessions(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting active sessions: %w", err)
+	}
+
+	lastRun := make(map[string]time.Time, len(recent))
+	for _, session := range recent {
+		lastRun[session.ProgramName] = session.EndTime
+	}
+	running := make(map[string]bool, len(active))
+	for _, session := range active {
+		running[session.ProgramName] = true
+	}
+
+	var stale []string
+	for _, program := range programs {
+		last, ok := lastRun[program]
+		if running[program] || (ok && !last.Before(cutoff)) {
+			continue
+		}
+		stale = append(stale, program)
+
+		if ok {
+			fmt.Printf(" • %s - last session %s (%s)\n", program, s.formatDate(last), formatAgo(time.Since(last)))
+		} else {
+			fmt.Printf(" • %s - no sessions recorded\n", program)
+		}
+	}
+
+	if len(stale) == 0 {
+		fmt.Printf("No programs without a session since %s\n", s.formatDate(cutoff))
+		return nil
 	}
+	if !clean {
+		fmt.Printf("%d stale program(s), remove them with `timekeep ls --stale %s --clean` or `timekeep rm`\n", len(stale), since)
+		return nil
+	}
+
+	var remove []string
+	answers := bufio.NewScanner(in)
+	for _, program := range stale {
+		fmt.Printf("Stop tracking %s? [y/N] ", program)
+		if !answers.Scan() {
+			fmt.Println()
+			break
+		}
+		if answer := strings.ToLower(strings.TrimSpace(answers.Text())); answer == "y" || answer == "yes" {
+			remove = append(remove, program)
+		}
+	}
+
+	if len(remove) == 0 {
+		fmt.Println("No programs removed")
+		return nil
+	}
+	if err := s.RemovePrograms(ctx, remove, false); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d program(s), `timekeep undo` restores them\n", len(remove))
 
 	return nil
 }
@@ -138,8 +271,13 @@ func (s *CLIService) GetAllInfo(ctx context.Context) error {
 		return nil
 	}
 
+	active, err := s.activeElapsed(ctx)
+	if err != nil {
+		return err
+	}
+
 	for _, program := range programs {
-		duration := time.Duration(program.LifetimeSeconds) * time.Second
+		duration := time.Duration(program.LifetimeSeconds)*time.Second + active[program.Name]
 
 		if duration < time.Minute {
 			fmt.Printf("  %s: %d seconds\n", program.Name, int(duration.Seconds()))
@@ -157,12 +295,24 @@ func (s *CLIService) GetAllInfo(ctx context.Context) error {
 
 // Get detailed stats for a single tracked program
 func (s *CLIService) GetInfo(ctx context.Context, args []string) error {
-	program, err := s.PrRepo.GetProgramByName(ctx, strings.ToLower(args[0]))
+	name, err := s.resolveProgram(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	program, err := s.PrRepo.GetProgramByName(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s is not tracked: %w", args[0], err)
+	}
 	if err != nil {
 		return fmt.Errorf("error getting tracked program: %w", err)
 	}
 
-	duration := time.Duration(program.LifetimeSeconds) * time.Second
+	active, err := s.activeElapsed(ctx)
+	if err != nil {
+		return err
+	}
+	inProgress := active[program.Name]
+	duration := time.Duration(program.LifetimeSeconds)*time.Second + inProgress
 
 	lastSession, err := s.HsRepo.GetLastSessionForProgram(ctx, program.Name)
 	if err != nil {
@@ -174,6 +324,9 @@ func (s *CLIService) GetInfo(ctx context.Context, args []string) error {
 				fmt.Printf(" • Project: %s\n", program.Project.String)
 			}
 			s.formatDuration(" • Current Lifetime: ", duration)
+			if inProgress > 0 {
+				s.formatDuration(" • In progress: ", inProgress)
+			}
 			fmt.Printf(" • Total sessions to date: 0\n")
 			fmt.Printf(" • Last Session: None\n")
 			return nil
@@ -194,12 +347,15 @@ func (s *CLIService) GetInfo(ctx context.Context, args []string) error {
 		fmt.Printf(" • Project: %s\n", program.Project.String)
 	}
 	s.formatDuration(" • Current Lifetime: ", duration)
+	if inProgress > 0 {
+		s.formatDuration(" • In progress: ", inProgress)
+	}
 	fmt.Printf(" • Total sessions to date: %d\n", sessionCount)
 
 	lastDuration := time.Duration(lastSession.DurationSeconds) * time.Second
 	fmt.Printf(" • Last Session: %s - %s ",
-		lastSession.StartTime.Format("2006-01-02 15:04"),
-		lastSession.EndTime.Format("2006-01-02 15:04"))
+		s.formatDateTime(lastSession.StartTime, false),
+		s.formatDateTime(lastSession.EndTime, false))
 	s.formatDuration("(", lastDuration)
 	fmt.Printf(")\n")
 
@@ -212,34 +368,75 @@ func (s *CLIService) GetInfo(ctx context.Context, args []string) error {
 	return nil
 }
 
+// Shows session times relative to now for this command, overriding the display config without saving it
+func (s *CLIService) UseRelativeTimes() {
+	if s.Config == nil {
+		s.Config = &config.Config{}
+	}
+	s.Config.Display.Relative = true
+}
+
 // Returns session history for a given program
-func (s *CLIService) GetSessionHistory(ctx context.Context, args []string, date, start, end string, limit int64) error {
+func (s *CLIService) GetSessionHistory(ctx context.Context, args []string, date, start, end, device string, limit int64, includeArchive, merged bool) error {
 	programName := ""
 	if len(args) != 0 {
-		programName = args[0]
+		name, err := s.resolveProgram(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		programName = name
+	}
+
+	if limit <= 0 {
+		if !merged {
+			return s.streamSessionHistory(ctx, programName, date, start, end, device, includeArchive, func(session database.SessionHistory) {
+				s.printSession(session)
+			})
+		}
+
+		// Merging needs every part of a run split by sleep, so sessions are collected before printing
+		var history []database.SessionHistory
+		err := s.streamSessionHistory(ctx, programName, date, start, end, device, includeArchive, func(session database.SessionHistory) {
+			history = append(history, session)
+		})
+		if err != nil {
+			return err
+		}
+		for _, session := range mergeContinuations(history) {
+			s.printSession(session)
+		}
+		return nil
 	}
 
 	var history []database.SessionHistory
 	var err error
 
 	if programName == "" {
-		history, err = s.getSessionHistoryNoName(ctx, date, start, end, limit)
+		history, err = s.getSessionHistoryNoName(ctx, date, start, end, device, limit)
 		if err != nil {
 			return err
 		}
 	} else {
-		history, err = s.getSessionHistoryNamed(ctx, programName, date, start, end, limit)
+		history, err = s.getSessionHistoryNamed(ctx, programName, date, start, end, device, limit)
 		if err != nil {
 			return err
 		}
 	}
 
-	if len(history) == 0 {
-		return nil
+	if includeArchive {
+		archived, err := s.getArchivedHistory(ctx, programName, date, start, end, device, limit)
+		if err != nil {
+			return err
+		}
+		history = mergeHistory(history, archived, limit)
+	}
+
+	if merged {
+		history = mergeContinuations(history)
 	}
 
 	for _, session := range history {
-		printSession(session)
+		s.printSession(session)
 	}
 
 	return nil
@@ -259,16 +456,29 @@ func (s *CLIService) ResetStats(ctx context.Context, args []string, all bool) er
 			return nil
 		}
 
-		for _, program := range args {
-			err := s.ResetDatabaseForProgram(ctx, strings.ToLower(program))
-			if err != nil {
+		programs := make([]string, len(args))
+		for i, program := range args {
+			programs[i] = progname.Normalize(program)
+		}
+
+		err := s.TxRepo.WithTx(ctx, func(store repository.Store) error {
+			if err := snapshotForUndo(ctx, store, "reset "+strings.Join(programs, " "), programs, true); err != nil {
 				return err
 			}
+			for _, program := range programs {
+				if err := resetProgramRecords(ctx, store, program); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 
 	}
 
-	err := s.ServiceCmd.WriteToService()
+	err := s.notifyService()
 	if err != nil {
 		fmt.Printf("Warning: Failed to notify service: %v\n", err)
 	}
@@ -276,39 +486,215 @@ func (s *CLIService) ResetStats(ctx context.Context, args []string, all bool) er
 	return nil
 }
 
-// Removes active session and session records for all programs
+// Removes active session and session records for all programs, in a single transaction
 func (s *CLIService) ResetAllDatabase(ctx context.Context) error {
-	err := s.AsRepo.RemoveAllSessions(ctx)
+	return s.TxRepo.WithTx(ctx, func(store repository.Store) error {
+		err := snapshotForUndo(ctx, store, "reset --all", nil, true)
+		if err != nil {
+			return err
+		}
+		err = store.RemoveAllSessions(ctx)
+		if err != nil {
+			return fmt.Errorf("error removing all active sessions: %w", err)
+		}
+		err = store.RemoveAllRecords(ctx)
+		if err != nil {
+			return fmt.Errorf("error removing all session records: %w", err)
+		}
+		err = store.RemoveAllArchivedRecords(ctx)
+		if err != nil {
+			return fmt.Errorf("error removing all archived records: %w", err)
+		}
+		err = store.ResetAllLifetimes(ctx)
+		if err != nil {
+			return fmt.Errorf("error resetting lifetime values: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Removes active session and session records for single program, in a single transaction
+func (s *CLIService) ResetDatabaseForProgram(ctx context.Context, program string) error {
+	program = progname.Normalize(program)
+	return s.TxRepo.WithTx(ctx, func(store repository.Store) error {
+		if err := snapshotForUndo(ctx, store, "reset "+program, []string{program}, true); err != nil {
+			return err
+		}
+		return resetProgramRecords(ctx, store, program)
+	})
+}
+
+// Removes active session, session records and lifetime for a program using the given store
+func resetProgramRecords(ctx context.Context, store repository.Store, program string) error {
+	err := store.RemoveActiveSession(ctx, program)
 	if err != nil {
-		return fmt.Errorf("error removing all active sessions: %w", err)
+		return fmt.Errorf("error removing active session for %s: %w", program, err)
 	}
-	err = s.HsRepo.RemoveAllRecords(ctx)
+	err = store.RemoveRecordsForProgram(ctx, program)
 	if err != nil {
-		return fmt.Errorf("error removing all session records: %w", err)
+		return fmt.Errorf("error removing session records for %s: %w", program, err)
 	}
-	err = s.PrRepo.ResetAllLifetimes(ctx)
+	err = store.RemoveArchivedRecordsForProgram(ctx, program)
 	if err != nil {
-		return fmt.Errorf("error resetting lifetime values: %w", err)
+		return fmt.Errorf("error removing archived records for %s: %w", program, err)
+	}
+	err = store.ResetLifetimeForProgram(ctx, program)
+	if err != nil {
+		return fmt.Errorf("error resetting lifetime for %s: %w", program, err)
 	}
 
 	return nil
 }
 
-// Removes Removes active session and session records for single program
-func (s *CLIService) ResetDatabaseForProgram(ctx context.Context, program string) error {
-	program = strings.ToLower(program)
+// Restores the rows deleted by the last reset or remove, provided it ran within the configured undo window
+func (s *CLIService) Undo(ctx context.Context) error {
+	window := s.Config.UndoPeriod()
+
+	var op database.UndoOperation
+	var found, expired bool
+	var programs, sessions int64
+	err := s.TxRepo.WithTx(ctx, func(store repository.Store) error {
+		var err error
+		op, err = store.GetUndoOperation(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error getting last operation: %w", err)
+		}
+		found = true
+
+		if time.Since(op.CreatedAt) > window {
+			expired = true
+			return nil
+		}
+
+		programs, err = store.RestorePrograms(ctx)
+		if err != nil {
+			return fmt.Errorf("error restoring programs: %w", err)
+		}
+		history, err := store.RestoreSessionHistory(ctx)
+		if err != nil {
+			return fmt.Errorf("error restoring session records: %w", err)
+		}
+		archived, err := store.RestoreSessionArchive(ctx)
+		if err != nil {
+			return fmt.Errorf("error restoring archived records: %w", err)
+		}
+		sessions = history + archived
 
-	err := s.AsRepo.RemoveActiveSession(ctx, program)
+		return clearUndo(ctx, store)
+	})
 	if err != nil {
-		return fmt.Errorf("error removing active session for %s: %w", program, err)
+		return err
+	}
+
+	if !found {
+		fmt.Println("Nothing to undo")
+		return nil
 	}
-	err = s.HsRepo.RemoveRecordsForProgram(ctx, program)
+	if expired {
+		fmt.Printf("Cannot undo `%s`: it ran %s ago, outside the undo window of %s\n",
+			op.Operation, time.Since(op.CreatedAt).Round(time.Minute), window)
+		return nil
+	}
+
+	fmt.Printf("Undid `%s`: restored %d programs and %d sessions\n", op.Operation, programs, sessions)
+
+	err = s.notifyService()
 	if err != nil {
-		return fmt.Errorf("error removing session records for %s: %w", program, err)
+		fmt.Printf("Warning: Failed to notify service: %v\n", err)
 	}
-	err = s.PrRepo.ResetLifetimeForProgram(ctx, program)
+
+	return nil
+}
+
+// Moves sessions that ended more than the given number of months ago into the archive table
+func (s *CLIService) ArchiveSessions(ctx context.Context, months int) error {
+	if months <= 0 {
+		return fmt.Errorf("months must be greater than 0")
+	}
+
+	cutoff := time.Now().AddDate(0, -months, 0).UTC()
+
+	var archived int64
+	err := s.TxRepo.WithTx(ctx, func(store repository.Store) error {
+		var err error
+		archived, err = store.ArchiveSessionsBefore(ctx, database.ArchiveSessionsBeforeParams{
+			ArchivedAt: time.Now().UTC(),
+			Cutoff:     cutoff,
+		})
+		if err != nil {
+			return fmt.Errorf("error archiving sessions: %w", err)
+		}
+
+		if err := store.RemoveSessionsBefore(ctx, cutoff); err != nil {
+			return fmt.Errorf("error removing archived sessions from history: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("error resetting lifetime for %s: %w", program, err)
+		return err
+	}
+
+	fmt.Printf("Archived %d session(s) ended before %s\n", archived, s.formatDate(cutoff))
+	return nil
+}
+
+// Recomputes lifetimes and session counts from this device's session history, printing any programs whose totals drifted
+func (s *CLIService) RecalculateLifetimes(ctx context.Context, args []string) error {
+	device := s.Config.DeviceName()
+
+	var before, after []database.TrackedProgram
+	err := s.TxRepo.WithTx(ctx, func(store repository.Store) error {
+		var err error
+		if before, err = store.GetAllPrograms(ctx); err != nil {
+			return fmt.Errorf("error getting programs list: %w", err)
+		}
+
+		if len(args) == 0 {
+			if err := store.RecalculateAllLifetimes(ctx, device); err != nil {
+				return fmt.Errorf("error recalculating lifetimes: %w", err)
+			}
+		}
+		for _, program := range args {
+			err := store.RecalculateLifetimeForProgram(ctx, database.RecalculateLifetimeForProgramParams{Device: device, Name: progname.Normalize(program)})
+			if err != nil {
+				return fmt.Errorf("error recalculating lifetime for %s: %w", program, err)
+			}
+		}
+
+		if after, err = store.GetAllPrograms(ctx); err != nil {
+			return fmt.Errorf("error getting programs list: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	previous := make(map[string]database.TrackedProgram, len(before))
+	for _, program := range before {
+		previous[program.Name] = program
+	}
+
+	changed := 0
+	for _, program := range after {
+		old, ok := previous[program.Name]
+		if !ok || (old.LifetimeSeconds == program.LifetimeSeconds && old.SessionCount == program.SessionCount) {
+			continue
+		}
+		changed++
+		fmt.Printf(" • %s: %s in %s -> %s in %s\n", program.Name,
+			(time.Duration(old.LifetimeSeconds) * time.Second).String(), plural(old.SessionCount, "session"),
+			(time.Duration(program.LifetimeSeconds) * time.Second).String(), plural(program.SessionCount, "session"))
+	}
+
+	if changed == 0 {
+		fmt.Println("All lifetimes and session counts already match session history")
+	} else {
+		fmt.Printf("Recalculated %s\n", plural(int64(changed), "program"))
 	}
 
 	return nil
@@ -334,6 +720,213 @@ func (s *CLIService) GetActiveSessions(ctx context.Context) error {
 	return nil
 }
 
+// Prints the running service's in-memory session state, rather than the active_sessions table
+func (s *CLIService) GetLiveActiveSessions() error {
+	resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionQueryActive))
+	if err != nil {
+		return fmt.Errorf("error querying service: %w", err)
+	}
+	if err := resp.Err(); err != nil {
+		return err
+	}
+
+	var active []ipc.ActiveSession
+	if err := resp.Decode(&active); err != nil {
+		return fmt.Errorf("error decoding service response: %w", err)
+	}
+	if len(active) == 0 {
+		return nil
+	}
+
+	for _, session := range active {
+		pids := make([]string, 0, len(session.PIDs))
+		for _, pid := range session.PIDs {
+			pids = append(pids, fmt.Sprintf("%d", pid))
+		}
+
+		s.formatDuration(fmt.Sprintf(" • %s - ", session.Name), time.Since(session.StartAt))
+		fmt.Printf("     PIDs: %s\n", strings.Join(pids, ", "))
+		fmt.Printf("     Started: %s\n", s.formatSessionTime(session.StartAt, true))
+		fmt.Printf("     Last seen: %s\n", s.formatSessionTime(session.LastSeen, true))
+	}
+
+	return nil
+}
+
+// Sends a health request to the service and reports its state along with round-trip latency
+func (s *CLIService) PingService() error {
+	start := time.Now()
+	resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionHealth))
+	latency := time.Since(start)
+	if err != nil {
+		return fmt.Errorf("service unreachable: %w", err)
+	}
+	if err := resp.Err(); err != nil {
+		return err
+	}
+
+	var health ipc.Health
+	if err := resp.Decode(&health); err != nil {
+		return fmt.Errorf("error decoding service response: %w", err)
+	}
+
+	fmt.Printf("  Version: %s\n", health.Version)
+	if !health.StartedAt.IsZero() {
+		s.formatDuration("  Uptime: ", time.Since(health.StartedAt))
+	}
+	fmt.Printf("  Database: %s\n", health.Database)
+	fmt.Printf("  Monitor: %s\n", health.Monitor)
+	if health.Profile != "" {
+		fmt.Printf("  Profile: %s\n", health.Profile)
+	}
+	if health.ActiveProfile != "" {
+		fmt.Printf("  Profile in use: %s\n", health.ActiveProfile)
+	}
+	fmt.Printf("  Tracked programs: %d\n", health.TrackedPrograms)
+	fmt.Printf("  Active sessions: %d\n", health.ActiveSessions)
+	if health.Desktop != "" {
+		fmt.Printf("  Desktop: %s\n", health.Desktop)
+	}
+	fmt.Printf("  Round trip: %s\n", latency.Round(time.Microsecond))
+
+	if health.Database != "ok" {
+		return fmt.Errorf("service database unhealthy: %s", health.Database)
+	}
+
+	return nil
+}
+
+// Turns the service's incognito mode on, for duration if it's not zero, or off. While on, nothing is observed or
+// recorded
+func (s *CLIService) SetIncognito(on bool, duration time.Duration) error {
+	mode := ipc.Incognito{Enabled: on}
+	if on && duration > 0 {
+		mode.Until = time.Now().Add(duration).UTC()
+	}
+	payload, err := json.Marshal(mode)
+	if err != nil {
+		return err
+	}
+
+	req := ipc.NewRequest(ipc.ActionIncognito)
+	req.Payload = payload
+	resp, err := s.ServiceCmd.Send(req)
+	if err != nil {
+		return fmt.Errorf("service unreachable: %w", err)
+	}
+	if err := resp.Err(); err != nil {
+		return err
+	}
+
+	switch {
+	case !on:
+		fmt.Println("Incognito off, tracking resumed")
+	case mode.Until.IsZero():
+		fmt.Println("Incognito on, nothing is recorded until 'timekeep incognito off'")
+	default:
+		fmt.Printf("Incognito on, nothing is recorded until %s\n", s.formatDateTime(mode.Until, false))
+	}
+	return nil
+}
+
+// Pauses the service's monitoring and heartbeats, resuming by itself after duration if it's not zero, even across
+// service restarts
+func (s *CLIService) PauseService(duration time.Duration) error {
+	req := ipc.NewRequest(ipc.ActionPause)
+	var pause ipc.Pause
+	if duration > 0 {
+		pause.Until = time.Now().Add(duration).UTC()
+		payload, err := json.Marshal(pause)
+		if err != nil {
+			return err
+		}
+		req.Payload = payload
+	}
+
+	resp, err := s.ServiceCmd.Send(req)
+	if err != nil {
+		return fmt.Errorf("service unreachable: %w", err)
+	}
+	if err := resp.Err(); err != nil {
+		return err
+	}
+
+	if pause.Until.IsZero() {
+		fmt.Println("Tracking paused until 'timekeep resume'")
+	} else {
+		fmt.Printf("Tracking paused until %s\n", s.formatDateTime(pause.Until, false))
+	}
+	return nil
+}
+
+// Resumes the service's monitoring after a pause
+func (s *CLIService) ResumeService() error {
+	resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionResume))
+	if err != nil {
+		return fmt.Errorf("service unreachable: %w", err)
+	}
+	if err := resp.Err(); err != nil {
+		return err
+	}
+
+	fmt.Println("Tracking resumed")
+	return nil
+}
+
+// Returns a line describing why the service isn't tracking, while paused, standing by or incognito. Empty when it's tracking or
+// can't be reached
+func (s *CLIService) monitorStatus() string {
+	resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionHealth))
+	if err != nil || resp.Err() != nil {
+		return ""
+	}
+	var health ipc.Health
+	if err := resp.Decode(&health); err != nil {
+		return ""
+	}
+
+	switch {
+	case health.Monitor == "incognito" && health.IncognitoUntil.IsZero():
+		return "  Incognito: ON, nothing is being recorded\n"
+	case health.Monitor == "incognito":
+		return fmt.Sprintf("  Incognito: ON until %s, nothing is being recorded\n", s.formatDateTime(health.IncognitoUntil, false))
+	case health.Monitor == "standby":
+		return fmt.Sprintf("  Tracking: standing by, profile %s is in use\n", health.ActiveProfile)
+	case health.Monitor == "paused" && health.PausedUntil.IsZero():
+		return "  Tracking: paused until resumed\n"
+	case health.Monitor == "paused":
+		return fmt.Sprintf("  Tracking: paused until %s\n", s.formatDateTime(health.PausedUntil, false))
+	default:
+		return ""
+	}
+}
+
+// Runs the service binary in simulation mode over an events file and prints its report
+func (s *CLIService) Simulate(ctx context.Context, eventsFile, binPath string, verbose bool) error {
+	bin, err := resolveServiceBinary(binPath)
+	if err != nil {
+		return err
+	}
+
+	path, err := filepath.Abs(eventsFile)
+	if err != nil {
+		return fmt.Errorf("invalid events file path: %w", err)
+	}
+
+	args := append(profileArgs(), "--simulate", path)
+	if verbose {
+		args = append(args, "--debug")
+	}
+
+	out, err := s.CmdExe.RunCommand(ctx, bin, args...)
+	fmt.Print(out)
+	if err != nil {
+		return fmt.Errorf("simulation failed: %w", err)
+	}
+
+	return nil
+}
+
 // Clears all active sessions and resets the count
 func (s *CLIService) CleanActiveSessions(ctx context.Context) error {
 	err := s.AsRepo.RemoveAllSessions(ctx)
@@ -443,11 +1036,14 @@ func (s *CLIService) DisableWakapi() error {
 }
 
 // Set various config values
-func (s *CLIService) SetConfig(cliPath, server, project, interval string, grace int) error {
+func (s *CLIService) SetConfig(cliPath, server, project, interval, timezone, device, scope string, grace int, display config.DisplayConfig) error {
 	if cliPath != "" {
 		s.Config.WakaTime.CLIPath = cliPath
 	}
 	if server != "" {
+		if err := config.ValidateServerURL(server); err != nil {
+			return err
+		}
 		s.Config.Wakapi.Server = server
 	}
 	if project != "" {
@@ -455,11 +1051,47 @@ func (s *CLIService) SetConfig(cliPath, server, project, interval string, grace
 		s.Config.Wakapi.GlobalProject = project
 	}
 	if interval != "" {
+		if d, err := time.ParseDuration(interval); err != nil || d <= 0 {
+			return fmt.Errorf("invalid poll interval %q, use a positive duration such as 1s or 500ms", interval)
+		}
 		s.Config.PollInterval = interval
 	}
 	if grace != 3 && grace >= 0 {
 		s.Config.PollGrace = grace
 	}
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil && timezone != "system" {
+			return fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+		s.Config.Timezone = timezone
+	}
+	if device != "" {
+		s.Config.Device = device
+	}
+	if scope != "" {
+		if scope != config.ScopeUser && scope != config.ScopeShared {
+			return fmt.Errorf("invalid scope %q, use %s or %s", scope, config.ScopeUser, config.ScopeShared)
+		}
+		s.Config.Scope = scope
+	}
+	if display.WeekStart != "" {
+		if _, ok := dates.ParseWeekday(display.WeekStart); !ok {
+			return fmt.Errorf("invalid week start %q, use a day name such as monday or sunday", display.WeekStart)
+		}
+		s.Config.Display.WeekStart = strings.ToLower(display.WeekStart)
+	}
+	if display.Clock != "" {
+		if display.Clock != config.Clock24h && display.Clock != config.Clock12h {
+			return fmt.Errorf("invalid clock %q, use %s or %s", display.Clock, config.Clock24h, config.Clock12h)
+		}
+		s.Config.Display.Clock = display.Clock
+	}
+	if display.DateFormat != "" {
+		if err := config.ValidateDateFormat(display.DateFormat); err != nil {
+			return err
+		}
+		s.Config.Display.DateFormat = display.DateFormat
+	}
 
 	if err := s.saveAndNotify(); err != nil {
 		return err
@@ -468,6 +1100,27 @@ func (s *CLIService) SetConfig(cliPath, server, project, interval string, grace
 	return nil
 }
 
+// Checks the config file, printing each problem found along with how to fix it
+func (s *CLIService) ValidateConfig() error {
+	path, err := config.Path()
+	if err != nil {
+		return err
+	}
+
+	problems := s.Config.Validate()
+	if len(problems) == 0 {
+		fmt.Printf("%s is valid\n", path)
+		return nil
+	}
+
+	fmt.Printf("%s has %d problem(s):\n", path, len(problems))
+	for _, problem := range problems {
+		fmt.Printf("  %s\n", problem)
+	}
+
+	return fmt.Errorf("invalid config")
+}
+
 // Returns WakaTime enabled/disabled status for user
 func (s *CLIService) StatusWakatime() error {
 	if s.Config.WakaTime.Enabled {
@@ -491,6 +1144,21 @@ func (s *CLIService) StatusWakapi() error {
 }
 
 // Display comprehensive statistics about the system
+// Sums the time and sessions of each program over the sessions recorded on the device set by --device, archived ones
+// included as stored lifetimes include them, keeping the last three of each in recent
+func (s *CLIService) deviceLifetimes(ctx context.Context, recent map[string][]database.SessionHistory) (map[string]database.TrackedProgram, error) {
+	lifetimes := map[string]database.TrackedProgram{}
+	err := s.streamSessionHistory(ctx, "", "", "", "", s.Device, true, func(session database.SessionHistory) {
+		lifetime := lifetimes[session.ProgramName]
+		lifetime.LifetimeSeconds += session.DurationSeconds
+		lifetime.SessionCount++
+		lifetimes[session.ProgramName] = lifetime
+		history := append(recent[session.ProgramName], session)
+		recent[session.ProgramName] = history[max(len(history)-3, 0):]
+	})
+	return lifetimes, err
+}
+
 func (s *CLIService) GetStats(ctx context.Context) error {
 	// Define color styles
 	titleStyle := lipgloss.NewStyle().
@@ -548,9 +1216,25 @@ func (s *CLIService) GetStats(ctx context.Context) error {
 	}
 	fmt.Println()
 
+	// Service Metrics
+	fmt.Println(sectionTitleStyle.Render("📈 SERVICE METRICS"))
+	if metrics, err := s.getServiceMetrics(); err != nil {
+		fmt.Printf("  ⚠️  %v\n", err)
+	} else {
+		fmt.Printf("  Events processed: %d\n", metrics.EventsProcessed)
+		fmt.Printf("  Sessions created/closed: %d/%d\n", metrics.SessionsCreated, metrics.SessionsClosed)
+		fmt.Printf("  Heartbeats sent/failed: %d/%d\n", metrics.HeartbeatsSent, metrics.HeartbeatsFailed)
+		fmt.Printf("  Heartbeat queue/dropped: %d/%d\n", metrics.HeartbeatQueue, metrics.HeartbeatsDropped)
+		fmt.Printf("  Validator cleanups: %d\n", metrics.ValidatorCleanups)
+		fmt.Printf("  Database errors: %d\n", metrics.DBErrors)
+		fmt.Printf("  Task restarts: %d\n", metrics.TaskRestarts)
+	}
+	fmt.Println()
+
 	// Active Sessions
 	fmt.Println(sectionTitleStyle.Render("🔄 ACTIVE SESSIONS"))
-	activeSessions, err := s.AsRepo.GetAllActiveSessions(ctx)
+	activeSessions, err := s.activeSessions(ctx)
+	inProgress := map[string]time.Duration{}
 	if err != nil {
 		fmt.Printf("  Error getting active sessions: %v\n", err)
 	} else if len(activeSessions) == 0 {
@@ -558,6 +1242,9 @@ func (s *CLIService) GetStats(ctx context.Context) error {
 	} else {
 		for _, session := range activeSessions {
 			duration := time.Since(session.StartTime)
+			if s.Config.IncludeActive() {
+				inProgress[session.ProgramName] = max(duration, 0)
+			}
 			fmt.Printf("  • %s - ", programNameStyle.Render(session.ProgramName))
 			s.formatDurationToString(nil, duration)
 		}
@@ -572,8 +1259,24 @@ func (s *CLIService) GetStats(ctx context.Context) error {
 	} else if len(programs) == 0 {
 		fmt.Println("  (none)")
 	} else {
+		// Recent sessions for every program in one query, lifetimes come from each program's stored total
+		recent := map[string][]database.SessionHistory{}
+		var lifetimes map[string]database.TrackedProgram
+		if s.Device == "" {
+			if sessions, err := s.HsRepo.GetRecentSessionsPerProgram(ctx, 3); err == nil {
+				for _, session := range sessions {
+					recent[session.ProgramName] = append(recent[session.ProgramName], session)
+				}
+			}
+		} else if lifetimes, err = s.deviceLifetimes(ctx, recent); err != nil {
+			fmt.Printf("  Error getting sessions of device %s: %v\n", s.Device, err)
+		}
+
 		for _, program := range programs {
-			duration := time.Duration(program.LifetimeSeconds) * time.Second
+			if s.Device != "" {
+				program.LifetimeSeconds, program.SessionCount = lifetimes[program.Name].LifetimeSeconds, lifetimes[program.Name].SessionCount
+			}
+			duration := time.Duration(program.LifetimeSeconds)*time.Second + inProgress[program.Name]
 			fmt.Printf("  └─ %s\n", programNameStyle.Render(program.Name))
 
 			// Category
@@ -599,13 +1302,10 @@ func (s *CLIService) GetStats(ctx context.Context) error {
 				minutes := int(duration.Minutes()) % 60
 				fmt.Printf("%dh %dm\n", hours, minutes)
 			}
+			fmt.Printf("      └─ %s: %d\n", lifetimeStyle.Render("Sessions"), program.SessionCount)
 
-			// Get recent history for this program
-			history, err := s.HsRepo.GetSessionHistory(ctx, database.GetSessionHistoryParams{
-				ProgramName: program.Name,
-				Limit:       3,
-			})
-			if err == nil && len(history) > 0 {
+			history := recent[program.Name]
+			if len(history) > 0 {
 				fmt.Print("      └─ ")
 				fmt.Println(recentSessionsStyle.Render("Recent Sessions"))
 				for j, session := range history {
@@ -617,8 +1317,8 @@ func (s *CLIService) GetStats(ctx context.Context) error {
 
 					sessionDuration := time.Duration(session.DurationSeconds) * time.Second
 					fmt.Printf("%s%s - %s ", historyPrefix,
-						sessionTimeStyle.Render(session.StartTime.Format("2006-01-02 15:04")),
-						sessionTimeStyle.Render(session.EndTime.Format("15:04")))
+						sessionTimeStyle.Render(s.formatDateTime(session.StartTime, false)),
+						sessionTimeStyle.Render(s.formatClock(session.EndTime, false)))
 
 					if sessionDuration < time.Minute {
 						fmt.Printf("%s\n", sessionDurationStyle.Render(fmt.Sprintf("(%d seconds)", int(sessionDuration.Seconds()))))
@@ -635,6 +1335,19 @@ func (s *CLIService) GetStats(ctx context.Context) error {
 	}
 	fmt.Println()
 
+	// Streaks
+	if list, err := s.updateStreaks(ctx); err != nil {
+		fmt.Println(sectionTitleStyle.Render("🔥 STREAKS"))
+		fmt.Printf("  ⚠️  %v\n", err)
+		fmt.Println()
+	} else if len(list) > 0 {
+		fmt.Println(sectionTitleStyle.Render("🔥 STREAKS"))
+		for _, streak := range list {
+			fmt.Printf("  • %s\n", describeStreak(streak))
+		}
+		fmt.Println()
+	}
+
 	// WakaTime Status
 	fmt.Println(sectionTitleStyle.Render("⏱️  WAKATIME INTEGRATION"))
 	if s.Config.WakaTime.Enabled {
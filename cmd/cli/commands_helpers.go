@@ -1,113 +1,369 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/profile"
+	"github.com/jms-guy/timekeep/internal/progname"
+	"github.com/jms-guy/timekeep/internal/repository"
 )
 
-// Determine which SQL query to execute to return session history, no program name given
-func (s *CLIService) getSessionHistoryNoName(ctx context.Context, date, start, end string, limit int64) ([]database.SessionHistory, error) {
-	var history []database.SessionHistory
-	var err error
-	var dateTime time.Time
-	var startDate time.Time
-	var endDate time.Time = time.Now()
+// Service manager's view of the installed service, shown by the status command
+type ServiceStatus struct {
+	State     string    // Current state, such as Running or active
+	SubState  string    // Linux - systemd sub-state, such as running or dead
+	StartType string    // Windows - how the SCM starts the service, such as Automatic
+	PID       int       // Main process ID, 0 when not running
+	Since     time.Time // Linux - when the service entered its current state
+}
 
-	if date != "" {
-		dateTime, err = time.Parse("2006-01-02", date)
-		if err != nil {
-			return history, err
-		}
-		startOfDay := time.Date(dateTime.Year(), dateTime.Month(), dateTime.Day(), 0, 0, 0, 0, dateTime.Location())
-		endOfDay := startOfDay.Add(24 * time.Hour)
+// Attempts made to notify the service, backing off from notifyBackoff between them. Covers a service restart
+const (
+	notifyAttempts = 4
+	notifyBackoff  = 250 * time.Millisecond
+)
 
-		history, err = s.HsRepo.GetAllSessionHistoryByDate(ctx, database.GetAllSessionHistoryByDateParams{
-			StartTime: endOfDay,
-			EndTime:   startOfDay,
-			Limit:     limit,
-		})
+// Asks the service to reload tracked programs, unless notifying was turned off with --no-notify
+func (s *CLIService) notifyService() error {
+	if s.NoNotify {
+		return nil
+	}
 
-	} else if start != "" {
-		startDate, err = time.Parse("2006-01-02", start)
-		if err != nil {
-			return history, err
+	var err error
+	for attempt := range notifyAttempts {
+		if attempt > 0 {
+			time.Sleep(notifyBackoff << (attempt - 1))
 		}
-		startOfDay := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
-
-		if end != "" {
-			endDate, err = time.Parse("2006-01-02", end)
-			if err != nil {
-				return history, err
-			}
-			endDate = time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 59, 59, 999999999, endDate.Location())
+		if err = s.ServiceCmd.WriteToService(); err == nil {
+			return nil
+		}
+		// Retrying or deferring the refresh can't help a service that doesn't understand the request
+		var versionErr *ipc.VersionError
+		if errors.As(err, &versionErr) {
+			return err
 		}
+	}
 
-		history, err = s.HsRepo.GetAllSessionHistoryByRange(ctx, database.GetAllSessionHistoryByRangeParams{
-			StartTime: endDate,
-			EndTime:   startOfDay,
-			Limit:     limit,
+	// A remote service can't see the local marker
+	if _, remote := s.ServiceCmd.(*remoteServiceCommander); remote {
+		return err
+	}
+	if markErr := ipc.MarkRefreshPending(); markErr != nil {
+		return fmt.Errorf("%w (%v)", err, markErr)
+	}
+	fmt.Printf("Warning: service unreachable (%v), changes will be applied when it next starts\n", err)
+	return nil
+}
+
+// Most candidates offered when a program name matches several tracked programs
+const maxCandidates = 9
+
+// Returns the tracked program name means: the program itself when tracked, or else the one program it fuzzy matches,
+// such as chrome for "chr". When it matches several, asks which one on Input, or fails naming them when there's no
+// one to ask
+func (s *CLIService) resolveProgram(ctx context.Context, name string) (string, error) {
+	program := progname.Normalize(name)
+	programs, err := s.PrRepo.GetAllProgramNames(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error getting tracked programs: %w", err)
+	}
+	if slices.Contains(programs, program) {
+		return program, nil
+	}
+
+	candidates := progname.Match(program, programs)
+	if len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
+	}
+	switch {
+	case len(candidates) == 0:
+		return "", fmt.Errorf("%s is %w, see 'timekeep ls'", name, errNotTracked)
+	case len(candidates) == 1:
+		fmt.Fprintf(os.Stderr, "%s is not tracked, using %s\n", name, candidates[0])
+		return candidates[0], nil
+	case s.Input == nil:
+		return "", fmt.Errorf("%s is %w, did you mean %s?", name, errNotTracked, orList(candidates))
+	}
+
+	fmt.Printf("%s is not tracked, did you mean:\n", name)
+	for i, candidate := range candidates {
+		fmt.Printf("  %d. %s\n", i+1, candidate)
+	}
+	fmt.Printf("Program [1-%d]: ", len(candidates))
+	answer, _ := bufio.NewReader(s.Input).ReadString('\n')
+	choice, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || choice < 1 || choice > len(candidates) {
+		return "", usageError{err: fmt.Errorf("no program picked for %s", name)}
+	}
+	return candidates[choice-1], nil
+}
+
+// Joins names as "a, b or c"
+func orList(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// Reports whether f is a terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Determine which SQL query to execute to return session history, no program name given
+func (s *CLIService) getSessionHistoryNoName(ctx context.Context, date, start, end, device string, limit int64) ([]database.SessionHistory, error) {
+	deviceFilter := sql.NullString{String: device, Valid: device != ""}
+
+	if date == "" && start == "" {
+		return s.HsRepo.GetAllSessionHistory(ctx, database.GetAllSessionHistoryParams{
+			Device: deviceFilter,
+			Limit:  limit,
 		})
-	} else {
-		history, err = s.HsRepo.GetAllSessionHistory(ctx, limit)
 	}
 
-	return history, err
+	rangeStart, rangeEnd, err := s.historyRange(date, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	if date != "" {
+		return s.HsRepo.GetAllSessionHistoryByDate(ctx, database.GetAllSessionHistoryByDateParams{
+			StartTime: rangeEnd,
+			EndTime:   rangeStart,
+			Device:    deviceFilter,
+			Limit:     limit,
+		})
+	}
+	return s.HsRepo.GetAllSessionHistoryByRange(ctx, database.GetAllSessionHistoryByRangeParams{
+		StartTime: rangeEnd,
+		EndTime:   rangeStart,
+		Device:    deviceFilter,
+		Limit:     limit,
+	})
 }
 
 // Determine which SQL query to execute to return session history, program name given
-func (s *CLIService) getSessionHistoryNamed(ctx context.Context, programName, date, start, end string, limit int64) ([]database.SessionHistory, error) {
-	var history []database.SessionHistory
-	var err error
-	var dateTime time.Time
-	var startDate time.Time
-	var endDate time.Time = time.Now()
+func (s *CLIService) getSessionHistoryNamed(ctx context.Context, programName, date, start, end, device string, limit int64) ([]database.SessionHistory, error) {
+	deviceFilter := sql.NullString{String: device, Valid: device != ""}
 
-	if date != "" {
-		dateTime, err = time.Parse("2006-01-02", date)
-		if err != nil {
-			return history, err
-		}
-		startOfDay := time.Date(dateTime.Year(), dateTime.Month(), dateTime.Day(), 0, 0, 0, 0, dateTime.Location())
-		endOfDay := startOfDay.Add(24 * time.Hour)
+	if date == "" && start == "" {
+		return s.HsRepo.GetSessionHistory(ctx, database.GetSessionHistoryParams{
+			ProgramName: programName,
+			Device:      deviceFilter,
+			Limit:       limit,
+		})
+	}
 
-		history, err = s.HsRepo.GetSessionHistoryByDate(ctx, database.GetSessionHistoryByDateParams{
+	rangeStart, rangeEnd, err := s.historyRange(date, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	if date != "" {
+		return s.HsRepo.GetSessionHistoryByDate(ctx, database.GetSessionHistoryByDateParams{
 			ProgramName: programName,
-			StartTime:   endOfDay,
-			EndTime:     startOfDay,
+			StartTime:   rangeEnd,
+			EndTime:     rangeStart,
+			Device:      deviceFilter,
 			Limit:       limit,
 		})
+	}
+	return s.HsRepo.GetSessionHistoryByRange(ctx, database.GetSessionHistoryByRangeParams{
+		ProgramName: programName,
+		StartTime:   rangeEnd,
+		EndTime:     rangeStart,
+		Device:      deviceFilter,
+		Limit:       limit,
+	})
+}
+
+// Reports whether sessions running on this machine count, as they do unless --device names another device
+func (s *CLIService) onLocalDevice() bool {
+	return s.Device == "" || s.Device == s.Config.DeviceName()
+}
+
+// Returns the sessions running on this machine, none when --device names another device
+func (s *CLIService) activeSessions(ctx context.Context) ([]database.ActiveSession, error) {
+	if !s.onLocalDevice() {
+		return nil, nil
+	}
+	return s.AsRepo.GetAllActiveSessions(ctx)
+}
+
+// Resolves the date filters used by history into a UTC range, matching sessions overlapping it. Dates are parsed by
+// the dates package, so relative dates like "yesterday" or "7d" are accepted
+func (s *CLIService) historyRange(date, start, end string) (time.Time, time.Time, error) {
+	loc := s.location()
+	opts := s.dateOptions()
+	now := time.Now()
+	rangeStart := time.Time{}
+	rangeEnd := now.UTC()
+
+	if date != "" {
+		span, err := dates.Parse(date, now, loc, opts)
+		if err != nil {
+			return rangeStart, rangeEnd, err
+		}
+		rangeStart, rangeEnd = span.Start.UTC(), span.End.UTC()
 	} else if start != "" {
-		startDate, err = time.Parse("2006-01-02", start)
+		startSpan, err := dates.Parse(start, now, loc, opts)
 		if err != nil {
-			return history, err
+			return rangeStart, rangeEnd, err
 		}
-		startOfDay := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
+		rangeStart = startSpan.Start.UTC()
 
 		if end != "" {
-			endDate, err = time.Parse("2006-01-02", end)
+			endSpan, err := dates.Parse(end, now, loc, opts)
 			if err != nil {
-				return history, err
+				return rangeStart, rangeEnd, err
 			}
-			endDate = time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 59, 59, 999999999, endDate.Location())
+			rangeEnd = endSpan.End.Add(-time.Nanosecond).UTC()
 		}
+	}
 
-		history, err = s.HsRepo.GetSessionHistoryByRange(ctx, database.GetSessionHistoryByRangeParams{
-			ProgramName: programName,
-			StartTime:   endDate,
-			EndTime:     startOfDay,
-			Limit:       limit,
-		})
-	} else {
-		history, err = s.HsRepo.GetSessionHistory(ctx, database.GetSessionHistoryParams{
-			ProgramName: programName,
-			Limit:       limit,
+	return rangeStart, rangeEnd, nil
+}
+
+// Fetch archived sessions matching the same filters used for regular session history
+func (s *CLIService) getArchivedHistory(ctx context.Context, programName, date, start, end, device string, limit int64) ([]database.SessionHistory, error) {
+	rangeStart, rangeEnd, err := s.historyRange(date, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	archived, err := s.ArchRepo.GetArchivedSessions(ctx, database.GetArchivedSessionsParams{
+		ProgramName: sql.NullString{String: progname.Normalize(programName), Valid: programName != ""},
+		RangeEnd:    rangeEnd,
+		RangeStart:  rangeStart,
+		Device:      sql.NullString{String: device, Valid: device != ""},
+		Limit:       limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting archived sessions: %w", err)
+	}
+
+	history := make([]database.SessionHistory, 0, len(archived))
+	for _, a := range archived {
+		history = append(history, archivedToHistory(a))
+	}
+
+	return history, nil
+}
+
+// Passes every session matching the filters to emit as rows are read, without holding the full history in memory.
+// Archiving moves the oldest sessions, so archived sessions come first to keep chronological order
+func (s *CLIService) streamSessionHistory(ctx context.Context, programName, date, start, end, device string, includeArchive bool, emit func(database.SessionHistory)) error {
+	rangeStart, rangeEnd, err := s.historyRange(date, start, end)
+	if err != nil {
+		return err
+	}
+
+	nameFilter := sql.NullString{String: progname.Normalize(programName), Valid: programName != ""}
+	deviceFilter := sql.NullString{String: device, Valid: device != ""}
+
+	if includeArchive {
+		err = s.ArchRepo.StreamArchivedSessions(ctx, database.StreamArchivedSessionsParams{
+			ProgramName: nameFilter,
+			RangeEnd:    rangeEnd,
+			RangeStart:  rangeStart,
+			Device:      deviceFilter,
+		}, func(a database.SessionArchive) error {
+			emit(archivedToHistory(a))
+			return nil
 		})
+		if err != nil {
+			return fmt.Errorf("error streaming archived sessions: %w", err)
+		}
+	}
+
+	err = s.HsRepo.StreamSessionHistory(ctx, database.StreamSessionHistoryParams{
+		ProgramName: nameFilter,
+		RangeEnd:    rangeEnd,
+		RangeStart:  rangeStart,
+		Device:      deviceFilter,
+	}, func(session database.SessionHistory) error {
+		emit(session)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error streaming session history: %w", err)
+	}
+
+	return nil
+}
+
+// Joins the sessions of a program run split by system sleep into one, spanning the first start to the last end. The
+// duration is the sum of the parts, leaving out time asleep. The run is listed where its first part appears
+func mergeContinuations(history []database.SessionHistory) []database.SessionHistory {
+	merged := make([]database.SessionHistory, 0, len(history))
+	runs := make(map[string]int) // Continuation ID to index in merged
+
+	for _, session := range history {
+		metadata, err := repository.DecodeSessionMetadata(session.Metadata)
+		if err != nil || metadata.Continuation == "" {
+			merged = append(merged, session)
+			continue
+		}
+
+		i, ok := runs[metadata.Continuation]
+		if !ok {
+			runs[metadata.Continuation] = len(merged)
+			merged = append(merged, session)
+			continue
+		}
+		if session.StartTime.Before(merged[i].StartTime) {
+			merged[i].StartTime = session.StartTime
+		}
+		if session.EndTime.After(merged[i].EndTime) {
+			merged[i].EndTime = session.EndTime
+		}
+		merged[i].DurationSeconds += session.DurationSeconds
+	}
+
+	return merged
+}
+
+// Converts an archived session to the session history shape used for display
+func archivedToHistory(a database.SessionArchive) database.SessionHistory {
+	return database.SessionHistory{
+		ID:              a.ID,
+		ProgramName:     a.ProgramName,
+		StartTime:       a.StartTime,
+		EndTime:         a.EndTime,
+		DurationSeconds: a.DurationSeconds,
+		Metadata:        a.Metadata,
+		Device:          a.Device,
+	}
+}
+
+// Combine live and archived sessions in chronological order, keeping only the most recent limit entries
+func mergeHistory(history, archived []database.SessionHistory, limit int64) []database.SessionHistory {
+	merged := append(append([]database.SessionHistory{}, archived...), history...)
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].StartTime.Before(merged[j].StartTime)
+	})
+
+	if limit > 0 && int64(len(merged)) > limit {
+		merged = merged[int64(len(merged))-limit:]
 	}
 
-	return history, err
+	return merged
 }
 
 // Formats a time.Duration value to display hours, minutes or seconds
@@ -124,12 +380,12 @@ func (s *CLIService) formatDuration(prefix string, duration time.Duration) {
 }
 
 // Basic helper for formatting sessions printed in "history" command
-func printSession(session database.SessionHistory) {
+func (s *CLIService) printSession(session database.SessionHistory) {
 	duration := time.Duration(session.DurationSeconds) * time.Second
 	fmt.Printf("  %s | %s - %s | Duration: ",
 		session.ProgramName,
-		session.StartTime.Format("2006-01-02 15:04"),
-		session.EndTime.Format("2006-01-02 15:04"))
+		s.formatSessionTime(session.StartTime, false),
+		s.formatSessionTime(session.EndTime, false))
 
 	if duration < time.Minute {
 		fmt.Printf("%d seconds\n", int(duration.Seconds()))
@@ -142,13 +398,189 @@ func printSession(session database.SessionHistory) {
 	}
 }
 
-// Helper to save config and send refresh command to service
+// Returns the configured display timezone, falling back to system local time
+func (s *CLIService) location() *time.Location {
+	loc, err := s.Config.Location()
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// Returns the elapsed time of each program's running session, to add to its lifetime, or nil when the config leaves
+// running sessions out of totals
+func (s *CLIService) activeElapsed(ctx context.Context) (map[string]time.Duration, error) {
+	if !s.Config.IncludeActive() {
+		return nil, nil
+	}
+
+	active, err := s.AsRepo.GetAllActiveSessions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting active sessions: %w", err)
+	}
+
+	elapsed := make(map[string]time.Duration, len(active))
+	for _, session := range active {
+		elapsed[session.ProgramName] = max(time.Since(session.StartTime), 0)
+	}
+	return elapsed, nil
+}
+
+// Returns the configured week start and date format, used when parsing date filters
+func (s *CLIService) dateOptions() dates.Options {
+	return dates.Options{WeekStart: s.Config.WeekStartDay(), DateLayout: s.Config.DateLayout()}
+}
+
+// Formats t as a date in the display timezone and configured date format
+func (s *CLIService) formatDate(t time.Time) string {
+	return t.In(s.location()).Format(s.Config.DateLayout())
+}
+
+// Formats t as a time of day in the display timezone on the configured clock
+func (s *CLIService) formatClock(t time.Time, seconds bool) string {
+	return t.In(s.location()).Format(s.Config.TimeLayout(seconds))
+}
+
+// Formats t as a date and time of day in the display timezone, using the configured date format and clock
+func (s *CLIService) formatDateTime(t time.Time, seconds bool) string {
+	return s.formatDate(t) + " " + s.formatClock(t, seconds)
+}
+
+// Formats a session's start or end time, as time elapsed since it when relative display is set
+func (s *CLIService) formatSessionTime(t time.Time, seconds bool) string {
+	if s.Config != nil && s.Config.Display.Relative {
+		return formatAgo(time.Since(t))
+	}
+	return s.formatDateTime(t, seconds)
+}
+
+// Formats an elapsed duration in its largest whole unit, such as "35m ago" or "2h ago"
+func formatAgo(elapsed time.Duration) string {
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return fmt.Sprintf("%dm ago", int(elapsed.Minutes()))
+	case elapsed < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(elapsed.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(elapsed.Hours()/24))
+	}
+}
+
+// Queries the running service for its internal counters
+func (s *CLIService) getServiceMetrics() (ipc.Metrics, error) {
+	var metrics ipc.Metrics
+
+	resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionMetrics))
+	if err != nil {
+		return metrics, fmt.Errorf("service unreachable: %w", err)
+	}
+	if err := resp.Err(); err != nil {
+		return metrics, err
+	}
+	if err := resp.Decode(&metrics); err != nil {
+		return metrics, fmt.Errorf("error decoding service response: %w", err)
+	}
+
+	return metrics, nil
+}
+
+// Helper to save config and tell the service to reload it in place
 func (s *CLIService) saveAndNotify() error {
 	if err := s.Config.Save(); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
-	if err := s.ServiceCmd.WriteToService(); err != nil {
+
+	resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionReloadConfig))
+	if err == nil {
+		err = resp.Err()
+	}
+	if err != nil {
 		return fmt.Errorf("config saved but failed to notify service: %w", err)
 	}
+
+	return nil
+}
+
+// Arguments selecting the current profile when launching the service binary, none for the default profile
+func profileArgs() []string {
+	if profile.Name() == "" {
+		return nil
+	}
+	return []string{"--profile", profile.Name()}
+}
+
+// Determine absolute service binary path, defaulting to the service executable next to this CLI
+func resolveServiceBinary(binPath string) (string, error) {
+	if binPath == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate CLI executable: %w", err)
+		}
+		binPath = filepath.Join(filepath.Dir(exe), serviceBinary)
+	}
+
+	abs, err := filepath.Abs(binPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid service binary path: %w", err)
+	}
+
+	if _, err := os.Stat(abs); err != nil {
+		return "", fmt.Errorf("service binary not found at %s, provide it with --bin", abs)
+	}
+
+	return abs, nil
+}
+
+// Replaces the undo buffer with the rows a destructive operation is about to delete. Programs are always
+// captured, their session records only when withSessions is set. A nil programs list captures everything
+func snapshotForUndo(ctx context.Context, store repository.Store, operation string, programs []string, withSessions bool) error {
+	if err := clearUndo(ctx, store); err != nil {
+		return err
+	}
+
+	err := store.SaveUndoOperation(ctx, database.SaveUndoOperationParams{
+		Operation: operation,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("error saving undo operation: %w", err)
+	}
+
+	filters := []sql.NullString{{}}
+	if programs != nil {
+		filters = make([]sql.NullString, len(programs))
+		for i, program := range programs {
+			filters[i] = sql.NullString{String: program, Valid: true}
+		}
+	}
+
+	for _, filter := range filters {
+		if err := store.SnapshotPrograms(ctx, filter); err != nil {
+			return fmt.Errorf("error saving programs for undo: %w", err)
+		}
+		if !withSessions {
+			continue
+		}
+		if err := store.SnapshotSessionHistory(ctx, filter); err != nil {
+			return fmt.Errorf("error saving session records for undo: %w", err)
+		}
+		if err := store.SnapshotSessionArchive(ctx, filter); err != nil {
+			return fmt.Errorf("error saving archived records for undo: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Empties the undo buffer
+func clearUndo(ctx context.Context, store repository.Store) error {
+	if err := store.ClearUndoRows(ctx); err != nil {
+		return fmt.Errorf("error clearing undo buffer: %w", err)
+	}
+	if err := store.ClearUndoOperation(ctx); err != nil {
+		return fmt.Errorf("error clearing undo buffer: %w", err)
+	}
 	return nil
 }
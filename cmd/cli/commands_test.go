@@ -1,12 +1,36 @@
 package main_test
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	cli "github.com/jms-guy/timekeep/cmd/cli"
+	"github.com/jms-guy/timekeep/internal/api"
+	"github.com/jms-guy/timekeep/internal/config"
 	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/formula"
+	"github.com/jms-guy/timekeep/internal/harvest"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/policy"
+	"github.com/jms-guy/timekeep/internal/privacy"
+	"github.com/jms-guy/timekeep/internal/productivity"
+	"github.com/jms-guy/timekeep/internal/profile"
+	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/jms-guy/timekeep/internal/rounding"
+	"github.com/jms-guy/timekeep/internal/syncapi"
+	"github.com/jms-guy/timekeep/internal/team"
+	mysql "github.com/jms-guy/timekeep/sql"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,6 +54,44 @@ func setupTestServiceWithPrograms(t *testing.T, programNames ...string) (*cli.CL
 	return s, nil
 }
 
+// Splits the rows of a printed table into their first cell, with a day name following a date, and the rest of the
+// row with its bars dropped and its cells single spaced
+func tableRows(out string) map[string]string {
+	rows := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.FieldsFunc(line, func(r rune) bool { return r == ' ' || r == '\t' || (r >= '▀' && r <= '▟') })
+		if len(fields) == 0 {
+			continue
+		}
+		key, rest := fields[0], fields[1:]
+		if len(rest) > 0 && len(rest[0]) == 3 && strings.Contains("MonTueWedThuFriSatSun", rest[0]) {
+			key, rest = key+" "+rest[0], rest[1:]
+		}
+		rows[key] = strings.Join(rest, " ")
+	}
+	return rows
+}
+
+// Returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
+
 func createTestRecords(s *cli.CLIService, programName string) error {
 	err := s.HsRepo.AddToSessionHistory(context.Background(), database.AddToSessionHistoryParams{
 		ProgramName:     programName,
@@ -47,17 +109,45 @@ func TestAddPrograms(t *testing.T) {
 		t.Fatalf("Failed to setup test service: %v", err)
 	}
 
-	programsToAdd := []string{"notepad.exe", "code.exe"}
+	programsToAdd := []string{"Notepad.EXE", `C:\Program Files\Microsoft VS Code\Code.exe`}
 	err = s.AddPrograms(t.Context(), programsToAdd, "", "")
 	assert.Nil(t, err, "AddPrograms should not return error")
 
 	addedPrograms, err := s.PrRepo.GetAllProgramNames(t.Context())
 	assert.Nil(t, err, "GetAllProgramNames should not return error")
 
-	assert.ElementsMatch(t, programsToAdd, addedPrograms, "The repository should contain the added programs")
+	assert.ElementsMatch(t, []string{"notepad", "code"}, addedPrograms, "The repository should contain the added programs")
 	assert.Len(t, addedPrograms, len(programsToAdd), "The repository should have the correct number of programs")
 }
 
+func TestAddPrograms_Policy(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	s.Policy = &policy.Policy{
+		Exclude:    []string{"steam*"},
+		Categories: map[string]string{"code": "coding", "web:*": "browsing", "web:github.com": "coding"},
+	}
+
+	err = s.AddPrograms(t.Context(), []string{"Steam.exe", "steamwebhelper", "web:github.com", "web:example.com", "notepad"}, "notes", "")
+	assert.Nil(t, err, "AddPrograms should not return error")
+
+	programs, err := s.PrRepo.GetAllPrograms(t.Context())
+	assert.Nil(t, err, "GetAllPrograms should not return error")
+
+	categories := make(map[string]string)
+	for _, p := range programs {
+		categories[p.Name] = p.Category.String
+	}
+	assert.Equal(t, map[string]string{"code": "", "web:github.com": "coding", "web:example.com": "browsing", "notepad": "notes"}, categories, "Excluded programs should be skipped and mapped categories applied")
+
+	err = s.UpdateProgram(t.Context(), []string{"code"}, "games", "")
+	assert.ErrorContains(t, err, "managed policy", "A category set by the policy should not be changeable")
+	err = s.UpdateProgram(t.Context(), []string{"code"}, "coding", "timekeep")
+	assert.Nil(t, err, "Updating to the policy's category should be allowed")
+}
+
 func TestRemoveProgram(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -74,19 +164,19 @@ func TestRemoveProgram(t *testing.T) {
 		{
 			name:        "shoud remove one program",
 			all:         false,
-			expected:    []string{"code.exe"},
+			expected:    []string{"code"},
 			expectedMsg: "Should be a program remaining",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s, err := setupTestServiceWithPrograms(t, "notepad.exe", "code.exe")
+			s, err := setupTestServiceWithPrograms(t, "notepad", "code")
 			if err != nil {
 				t.Fatalf("Failed to setup test service: %v", err)
 			}
 
-			programToRemove := []string{"notepad.exe"}
+			programToRemove := []string{"notepad"}
 			err = s.RemovePrograms(t.Context(), programToRemove, tt.all)
 			assert.Nil(t, err, "RemovePrograms should not return err")
 
@@ -97,7 +187,7 @@ func TestRemoveProgram(t *testing.T) {
 }
 
 func TestGetList(t *testing.T) {
-	s, err := setupTestServiceWithPrograms(t, "notepad.exe", "code.exe")
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
 	if err != nil {
 		t.Fatalf("Failed to setup test service: %v", err)
 	}
@@ -117,7 +207,7 @@ func TestGetList_Empty(t *testing.T) {
 }
 
 func TestGetAllStats(t *testing.T) {
-	s, err := setupTestServiceWithPrograms(t, "notepad.exe", "code.exe")
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
 	if err != nil {
 		t.Fatalf("Failed to setup test service: %v", err)
 	}
@@ -137,37 +227,37 @@ func TestGetAllStats_Empty(t *testing.T) {
 }
 
 func TestGetStats(t *testing.T) {
-	s, err := setupTestServiceWithPrograms(t, "notepad.exe", "code.exe")
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
 	if err != nil {
 		t.Fatalf("Failed to setup test service: %v", err)
 	}
 
-	err = s.GetInfo(t.Context(), []string{"notepad.exe"})
+	err = s.GetInfo(t.Context(), []string{"notepad"})
 	assert.Nil(t, err, "GetStats should not err")
 }
 
 func TestGetSessionHistory(t *testing.T) {
-	s, err := setupTestServiceWithPrograms(t, "notepad.exe", "code.exe")
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
 	if err != nil {
 		t.Fatalf("Failed to setup test service: %v", err)
 	}
 
-	err = s.GetSessionHistory(t.Context(), []string{"code.exe"}, "", "", "", 25)
+	err = s.GetSessionHistory(t.Context(), []string{"code"}, "", "", "", "", 25, false, false)
 	assert.Nil(t, err, "GetSessionHistory should not err")
 }
 
 func TestResetStats(t *testing.T) {
-	s, err := setupTestServiceWithPrograms(t, "notepad.exe", "code.exe")
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
 	if err != nil {
 		t.Fatalf("Failed to setup test service: %v", err)
 	}
 
-	err = s.ResetStats(t.Context(), []string{"code.exe"}, false)
+	err = s.ResetStats(t.Context(), []string{"code"}, false)
 	assert.Nil(t, err, "ResetStats should not err")
 }
 
 func TestResetStats_NoArgs(t *testing.T) {
-	s, err := setupTestServiceWithPrograms(t, "notepad.exe", "code.exe")
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
 	if err != nil {
 		t.Fatalf("Failed to setup test service: %v", err)
 	}
@@ -177,7 +267,7 @@ func TestResetStats_NoArgs(t *testing.T) {
 }
 
 func TestResetStats_All(t *testing.T) {
-	s, err := setupTestServiceWithPrograms(t, "notepad.exe", "code.exe")
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
 	if err != nil {
 		t.Fatalf("Failed to setup test service: %v", err)
 	}
@@ -187,7 +277,7 @@ func TestResetStats_All(t *testing.T) {
 }
 
 func TestResetAllDatabase(t *testing.T) {
-	s, err := setupTestServiceWithPrograms(t, "notepad.exe", "code.exe")
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
 	if err != nil {
 		t.Fatalf("Failed to setup test service: %v", err)
 	}
@@ -198,25 +288,25 @@ func TestResetAllDatabase(t *testing.T) {
 	remainingPrograms, _ := s.PrRepo.GetAllProgramNames(t.Context())
 	assert.Len(t, remainingPrograms, 2, "after reset, programs should be unaffected")
 
-	allHistory, _ := s.HsRepo.GetSessionHistory(t.Context(), database.GetSessionHistoryParams{ProgramName: "notepad.exe", Limit: 25})
+	allHistory, _ := s.HsRepo.GetSessionHistory(t.Context(), database.GetSessionHistoryParams{ProgramName: "notepad", Limit: 25})
 	assert.Len(t, allHistory, 0, "after reset, there should be no session history")
 }
 
 func TestResetDatabaseForProgram(t *testing.T) {
-	s, err := setupTestServiceWithPrograms(t, "notepad.exe", "code.exe")
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
 	if err != nil {
 		t.Fatalf("Failed to setup test service: %v", err)
 	}
 
-	err = s.ResetDatabaseForProgram(t.Context(), "code.exe")
+	err = s.ResetDatabaseForProgram(t.Context(), "code")
 	assert.Nil(t, err, "ResetDatabaseForProgram should not err")
 
-	history, _ := s.HsRepo.GetSessionHistory(t.Context(), database.GetSessionHistoryParams{ProgramName: "code.exe", Limit: 25})
+	history, _ := s.HsRepo.GetSessionHistory(t.Context(), database.GetSessionHistoryParams{ProgramName: "code", Limit: 25})
 	assert.Len(t, history, 0, "after reset, there should be no session history")
 }
 
 func TestPingService(t *testing.T) {
-	s, err := setupTestServiceWithPrograms(t, "notepad.exe", "code.exe")
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
 	if err != nil {
 		t.Fatalf("Failed to setup test service: %v", err)
 	}
@@ -227,7 +317,7 @@ func TestPingService(t *testing.T) {
 }
 
 func TestGetActiveSessions(t *testing.T) {
-	s, err := setupTestServiceWithPrograms(t, "notepad.exe", "code.exe")
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
 	if err != nil {
 		t.Fatalf("Failed to setup test service: %v", err)
 	}
@@ -235,3 +325,1787 @@ func TestGetActiveSessions(t *testing.T) {
 	err = s.GetActiveSessions(t.Context())
 	assert.Nil(t, err, "GetActiveSessions should not err")
 }
+
+func TestGetSessionHistory_Date(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	err = s.GetSessionHistory(t.Context(), []string{}, time.Now().Format("2006-01-02"), "", "", "", 25, false, false)
+	assert.Nil(t, err, "GetSessionHistory should not err")
+
+	err = s.GetSessionHistory(t.Context(), []string{}, "not-a-date", "", "", "", 25, false, false)
+	assert.NotNil(t, err, "GetSessionHistory should err on malformed date")
+	assert.Contains(t, err.Error(), "YYYY-MM-DD", "error should list accepted formats")
+
+	for _, date := range []string{"today", "yesterday", "last monday", "7d", time.Now().Format("2006-01")} {
+		err = s.GetSessionHistory(t.Context(), []string{}, date, "", "", "", 25, false, false)
+		assert.Nil(t, err, "GetSessionHistory should accept date %q", date)
+	}
+
+	err = s.GetSessionHistory(t.Context(), []string{}, "", "2w", "yesterday", "", 25, false, false)
+	assert.Nil(t, err, "GetSessionHistory should accept relative start and end")
+}
+
+func TestResetStats_MultiplePrograms(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	err = s.ResetStats(t.Context(), []string{"notepad", "code"}, false)
+	assert.Nil(t, err, "ResetStats should not err")
+
+	for _, name := range []string{"notepad", "code"} {
+		history, _ := s.HsRepo.GetSessionHistory(t.Context(), database.GetSessionHistoryParams{ProgramName: name, Limit: 25})
+		assert.Len(t, history, 0, "after reset, there should be no session history for %s", name)
+	}
+}
+
+func TestRecalculateLifetimes(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	err = s.PrRepo.UpdateLifetime(t.Context(), database.UpdateLifetimeParams{Name: "code", LifetimeSeconds: 42})
+	assert.Nil(t, err, "UpdateLifetime should not err")
+
+	err = s.RecalculateLifetimes(t.Context(), []string{})
+	assert.Nil(t, err, "RecalculateLifetimes should not err")
+
+	for _, name := range []string{"notepad", "code"} {
+		program, err := s.PrRepo.GetProgramByName(t.Context(), name)
+		assert.Nil(t, err, "GetProgramByName should not err")
+		assert.Equal(t, int64(3600), program.LifetimeSeconds, "lifetime should match history for %s", name)
+		assert.Equal(t, int64(1), program.SessionCount, "session count should match history for %s", name)
+	}
+}
+
+func TestSessionMetadata(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "notepad")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	last, err := s.HsRepo.GetLastSessionForProgram(t.Context(), "notepad")
+	assert.Nil(t, err, "GetLastSessionForProgram should not err")
+
+	metadata, err := s.HsRepo.GetSessionMetadata(t.Context(), last.ID)
+	assert.Nil(t, err, "GetSessionMetadata should not err")
+	assert.Empty(t, metadata.Source, "metadata should be empty by default")
+
+	err = s.HsRepo.UpdateSessionMetadata(t.Context(), last.ID, repository.SessionMetadata{Source: repository.SourceManual, Machine: "desktop"})
+	assert.Nil(t, err, "UpdateSessionMetadata should not err")
+
+	metadata, err = s.HsRepo.GetSessionMetadata(t.Context(), last.ID)
+	assert.Nil(t, err, "GetSessionMetadata should not err")
+	assert.Equal(t, repository.SourceManual, metadata.Source)
+	assert.Equal(t, "desktop", metadata.Machine)
+}
+
+func TestGetSessionHistory_DeviceFilter(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "notepad")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+		ProgramName:     "notepad",
+		StartTime:       time.Now(),
+		EndTime:         time.Now().Add(time.Minute),
+		DurationSeconds: 60,
+		Device:          sql.NullString{String: "laptop", Valid: true},
+	})
+	assert.Nil(t, err, "AddToSessionHistory should not err")
+
+	all, _ := s.HsRepo.GetSessionHistory(t.Context(), database.GetSessionHistoryParams{ProgramName: "notepad", Limit: 25})
+	assert.Len(t, all, 2, "no device filter should return every session")
+
+	filtered, _ := s.HsRepo.GetSessionHistory(t.Context(), database.GetSessionHistoryParams{
+		ProgramName: "notepad",
+		Device:      sql.NullString{String: "laptop", Valid: true},
+		Limit:       25,
+	})
+	assert.Len(t, filtered, 1, "device filter should only return matching sessions")
+}
+
+func TestArchiveSessions(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "notepad")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	old := time.Now().AddDate(-2, 0, 0).UTC()
+	err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+		ProgramName:     "notepad",
+		StartTime:       old,
+		EndTime:         old.Add(time.Hour),
+		DurationSeconds: 3600,
+	})
+	assert.Nil(t, err, "AddToSessionHistory should not err")
+
+	err = s.ArchiveSessions(t.Context(), 12)
+	assert.Nil(t, err, "ArchiveSessions should not err")
+
+	history, _ := s.HsRepo.GetSessionHistory(t.Context(), database.GetSessionHistoryParams{ProgramName: "notepad", Limit: 25})
+	assert.Len(t, history, 1, "old session should be removed from history")
+
+	archived, _ := s.ArchRepo.GetArchivedSessions(t.Context(), database.GetArchivedSessionsParams{RangeEnd: time.Now().UTC(), Limit: 25})
+	assert.Len(t, archived, 1, "old session should be in the archive")
+
+	err = s.GetSessionHistory(t.Context(), []string{"notepad"}, "", "", "", "", 25, true, false)
+	assert.Nil(t, err, "GetSessionHistory with archive should not err")
+}
+
+func TestGetLiveActiveSessions(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "notepad")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	err = s.GetLiveActiveSessions()
+	assert.Nil(t, err, "GetLiveActiveSessions should not err")
+}
+
+func TestPingServiceHealth(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	err = s.PingService()
+	assert.Nil(t, err, "PingService should not err")
+}
+
+func TestGetSessionHistory_NoLimit(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	var streamed []string
+	err = s.HsRepo.StreamSessionHistory(t.Context(), database.StreamSessionHistoryParams{
+		RangeEnd: time.Now().Add(time.Minute),
+	}, func(session database.SessionHistory) error {
+		streamed = append(streamed, session.ProgramName)
+		return nil
+	})
+	assert.Nil(t, err, "StreamSessionHistory should not err")
+	assert.ElementsMatch(t, []string{"notepad", "code"}, streamed, "every session should be streamed")
+
+	err = s.GetSessionHistory(t.Context(), []string{"code"}, "", "", "", "", 0, true, false)
+	assert.Nil(t, err, "GetSessionHistory with no limit should not err")
+}
+
+func TestGetSessionHistory_Merged(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	err = s.PrRepo.AddProgram(t.Context(), database.AddProgramParams{Name: "code"})
+	assert.Nil(t, err, "AddProgram should not err")
+
+	// A run of code split by an hour of sleep, and a session of steam in between
+	run, err := repository.SessionMetadata{Continuation: "run-1"}.Encode()
+	assert.Nil(t, err)
+	start := time.Now().Add(-4 * time.Hour)
+	for _, session := range []database.AddToSessionHistoryParams{
+		{ProgramName: "code", StartTime: start, EndTime: start.Add(time.Hour), DurationSeconds: 3600, Metadata: run},
+		{ProgramName: "code", StartTime: start.Add(2 * time.Hour), EndTime: start.Add(150 * time.Minute), DurationSeconds: 1800, Metadata: run},
+	} {
+		err = s.HsRepo.AddToSessionHistory(t.Context(), session)
+		assert.Nil(t, err, "AddToSessionHistory should not err")
+	}
+
+	for _, limit := range []int64{25, 0} {
+		raw := captureStdout(t, func() {
+			err = s.GetSessionHistory(t.Context(), []string{"code"}, "", "", "", "", limit, false, false)
+			assert.Nil(t, err, "GetSessionHistory should not err")
+		})
+		assert.Equal(t, 2, strings.Count(raw, "code |"), "The raw view should list both parts, limit %d", limit)
+
+		merged := captureStdout(t, func() {
+			err = s.GetSessionHistory(t.Context(), nil, "", "", "", "", limit, false, true)
+			assert.Nil(t, err, "GetSessionHistory should not err")
+		})
+		lines := strings.Split(strings.TrimSpace(merged), "\n")
+		if assert.Len(t, lines, 2, "The merged view should list one run of code and the session of steam, limit %d", limit) {
+			assert.Contains(t, lines[0], "code |")
+			assert.Contains(t, lines[0], "Duration: 1h 30m", "The run should last its parts' time, leaving out sleep")
+			assert.Contains(t, lines[1], "steam |")
+		}
+	}
+}
+
+func TestGetRecentSessionsPerProgram(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	base := time.Now().Add(-24 * time.Hour)
+	for i := range 4 {
+		err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+			ProgramName:     "code",
+			StartTime:       base.Add(time.Duration(i) * time.Hour),
+			EndTime:         base.Add(time.Duration(i)*time.Hour + time.Minute),
+			DurationSeconds: 60,
+		})
+		assert.Nil(t, err, "AddToSessionHistory should not err")
+	}
+
+	recent, err := s.HsRepo.GetRecentSessionsPerProgram(t.Context(), 3)
+	assert.Nil(t, err, "GetRecentSessionsPerProgram should not err")
+
+	perProgram := map[string][]database.SessionHistory{}
+	for _, session := range recent {
+		perProgram[session.ProgramName] = append(perProgram[session.ProgramName], session)
+	}
+	assert.Len(t, perProgram["notepad"], 1, "programs with fewer sessions should return all of them")
+	assert.Len(t, perProgram["code"], 3, "programs should be capped at the requested number of sessions")
+	assert.True(t, perProgram["code"][0].EndTime.Before(perProgram["code"][2].EndTime), "sessions should be oldest first")
+	assert.Equal(t, base.Add(2*time.Hour).Unix(), perProgram["code"][0].StartTime.Unix(), "oldest sessions should be dropped")
+}
+
+func TestUndo(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	err = s.PrRepo.UpdateLifetime(t.Context(), database.UpdateLifetimeParams{Name: "code", LifetimeSeconds: 3600})
+	assert.Nil(t, err, "UpdateLifetime should not err")
+
+	err = s.ResetStats(t.Context(), nil, true)
+	assert.Nil(t, err, "ResetStats should not err")
+
+	err = s.Undo(t.Context())
+	assert.Nil(t, err, "Undo should not err")
+
+	history, _ := s.HsRepo.GetSessionHistory(t.Context(), database.GetSessionHistoryParams{ProgramName: "code", Limit: 25})
+	assert.Len(t, history, 1, "undo should restore session history")
+	program, _ := s.PrRepo.GetProgramByName(t.Context(), "code")
+	assert.Equal(t, int64(3600), program.LifetimeSeconds, "undo should restore lifetimes")
+
+	err = s.RemovePrograms(t.Context(), []string{"notepad"}, false)
+	assert.Nil(t, err, "RemovePrograms should not err")
+
+	err = s.Undo(t.Context())
+	assert.Nil(t, err, "Undo should not err")
+
+	programs, _ := s.PrRepo.GetAllProgramNames(t.Context())
+	assert.ElementsMatch(t, []string{"notepad", "code"}, programs, "undo should restore removed programs")
+
+	err = s.Undo(t.Context())
+	assert.Nil(t, err, "Undo with an empty buffer should not err")
+	history, _ = s.HsRepo.GetSessionHistory(t.Context(), database.GetSessionHistoryParams{ProgramName: "code", Limit: 25})
+	assert.Len(t, history, 1, "undo should only restore once")
+}
+
+func TestListStalePrograms(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "notepad", "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	err = s.PrRepo.AddProgram(t.Context(), database.AddProgramParams{Name: "unused"})
+	assert.Nil(t, err, "AddProgram should not err")
+
+	err = s.ListStalePrograms(t.Context(), "not-a-date", false, strings.NewReader(""))
+	assert.NotNil(t, err, "an invalid span should err")
+
+	err = s.ListStalePrograms(t.Context(), "30d", true, strings.NewReader("y\n"))
+	assert.Nil(t, err, "ListStalePrograms should not err")
+
+	programs, _ := s.PrRepo.GetAllProgramNames(t.Context())
+	assert.ElementsMatch(t, []string{"notepad", "code"}, programs, "only the program with no recent sessions should be removed")
+}
+
+func TestTokens(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	err = s.CreateToken(t.Context(), "dashboard", "superuser")
+	assert.NotNil(t, err, "an unknown scope should err")
+
+	err = s.CreateToken(t.Context(), "dashboard", ipc.ScopeRead)
+	assert.Nil(t, err, "CreateToken should not err")
+	err = s.CreateToken(t.Context(), "dashboard", ipc.ScopeAdmin)
+	assert.NotNil(t, err, "a duplicate name should err")
+
+	tokens, _ := s.TokenRepo.GetAllAPITokens(t.Context())
+	if assert.Len(t, tokens, 1) {
+		assert.Equal(t, ipc.ScopeRead, tokens[0].Scope)
+		assert.Len(t, tokens[0].TokenHash, 64, "only the token's hash should be stored")
+	}
+
+	err = s.RevokeTokens(t.Context(), []string{"dashboard"})
+	assert.Nil(t, err, "RevokeTokens should not err")
+	err = s.RevokeTokens(t.Context(), []string{"dashboard"})
+	assert.ErrorIs(t, err, sql.ErrNoRows, "revoking a missing token should report not found")
+}
+
+func TestMergeUpload(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	start := time.Now().Add(-2 * time.Hour).UTC()
+	upload := syncapi.Upload{Device: "laptop", Sessions: []syncapi.Session{
+		{ID: "a", Program: "code", Start: start, End: start.Add(time.Hour), DurationSeconds: 3600},
+		{ID: "b", Program: "firefox", Category: "browsing", Start: start, End: start.Add(30 * time.Minute), DurationSeconds: 1800},
+	}}
+
+	_, err = s.MergeUpload(t.Context(), syncapi.Upload{Sessions: upload.Sessions})
+	assert.NotNil(t, err, "an upload without a device should err")
+
+	result, err := s.MergeUpload(t.Context(), upload)
+	assert.Nil(t, err, "MergeUpload should not err")
+	assert.Equal(t, syncapi.UploadResult{Accepted: 2}, result)
+
+	result, err = s.MergeUpload(t.Context(), upload)
+	assert.Nil(t, err, "MergeUpload should not err")
+	assert.Equal(t, syncapi.UploadResult{Duplicates: 2}, result, "a repeated upload should change nothing")
+
+	program, err := s.PrRepo.GetProgramByName(t.Context(), "firefox")
+	assert.Nil(t, err, "uploaded programs should be added")
+	assert.Equal(t, int64(0), program.LifetimeSeconds, "lifetimes should count this device's time only")
+	assert.Equal(t, "browsing", program.Category.String)
+	assert.True(t, program.Remote, "programs only seen on other devices should not be monitored here")
+	code, _ := s.PrRepo.GetProgramByName(t.Context(), "code")
+	assert.False(t, code.Remote, "programs tracked here should stay monitored")
+	assert.Equal(t, int64(0), code.LifetimeSeconds, "lifetimes should count this device's time only")
+
+	err = s.AddPrograms(t.Context(), []string{"firefox"}, "", "")
+	assert.Nil(t, err, "AddPrograms should not err")
+	program, _ = s.PrRepo.GetProgramByName(t.Context(), "firefox")
+	assert.False(t, program.Remote, "adding a remote program should have it monitored here")
+
+	count, _ := s.HsRepo.GetCountOfSessionsForProgram(t.Context(), "firefox")
+	assert.Equal(t, int64(1), count)
+	last, _ := s.HsRepo.GetLastSessionForProgram(t.Context(), "firefox")
+	assert.Equal(t, "laptop", last.Device.String, "sessions should keep the device they were recorded on")
+}
+
+func TestTeamPending(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	ctx := t.Context()
+	cfg := &config.Config{Timezone: "UTC", Team: config.TeamConfig{Enabled: true, Categories: []string{"development"}}}
+
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	yesterday := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	_ = s.PrRepo.AddProgram(ctx, database.AddProgramParams{Name: "code", Category: sql.NullString{String: "development", Valid: true}})
+	_ = s.PrRepo.AddProgram(ctx, database.AddProgramParams{Name: "steam", Category: sql.NullString{String: "games", Valid: true}})
+	_ = s.PrRepo.AddProgram(ctx, database.AddProgramParams{Name: "notes"})
+	for _, session := range []database.AddToSessionHistoryParams{
+		{ProgramName: "code", StartTime: yesterday.Add(10 * time.Hour), EndTime: yesterday.Add(11 * time.Hour), DurationSeconds: 3600},
+		{ProgramName: "steam", StartTime: yesterday.Add(20 * time.Hour), EndTime: yesterday.Add(21 * time.Hour), DurationSeconds: 3600},
+		{ProgramName: "notes", StartTime: yesterday.Add(-30 * time.Minute), EndTime: yesterday.Add(30 * time.Minute), DurationSeconds: 3600},
+		{ProgramName: "code", StartTime: yesterday.Add(-48 * time.Hour), EndTime: yesterday.Add(-47 * time.Hour), DurationSeconds: 3600},
+	} {
+		assert.Nil(t, s.HsRepo.AddToSessionHistory(ctx, session))
+	}
+
+	reports, through, err := team.Pending(ctx, s.TxRepo, cfg, now)
+	assert.Nil(t, err, "Pending should not err")
+	assert.Equal(t, "2026-03-09", through)
+	assert.Equal(t, []team.Report{{Day: "2026-03-09", Totals: map[string]int64{"development": 3600, "other": 3600, "uncategorized": 1800}}}, reports,
+		"the first report should cover only yesterday, with unlisted categories reported as other")
+
+	cfg.Team.Endpoint = "http://127.0.0.1:0"
+	assert.Nil(t, team.Send(ctx, s.TxRepo, cfg.Team, nil, through), "recording the day as sent should not err")
+	reports, _, err = team.Pending(ctx, s.TxRepo, cfg, now)
+	assert.Nil(t, err, "Pending should not err")
+	assert.Empty(t, reports, "days already sent should not be reported again")
+}
+
+func TestHashedNames(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "firefox")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	ctx := t.Context()
+	store := s.TxRepo.(repository.TxStore)
+
+	path := filepath.Join(t.TempDir(), "names.json")
+	names, err := privacy.Load(path)
+	assert.Nil(t, err, "Load should create the names file")
+
+	renamed, err := privacy.Apply(ctx, store, names, true)
+	assert.Nil(t, err, "Apply should not err")
+	assert.Equal(t, 1, renamed)
+	stored, _ := store.GetAllProgramNames(ctx)
+	assert.Equal(t, []string{names.Hash("firefox")}, stored, "names should be stored hashed")
+	history, _ := store.GetAllSessionHistory(ctx, database.GetAllSessionHistoryParams{Limit: 10})
+	assert.Equal(t, names.Hash("firefox"), history[0].ProgramName, "sessions should refer to the hashed name")
+
+	hashed := repository.NewHashedStore(store, names)
+	programs, _ := hashed.GetAllProgramNames(ctx)
+	assert.Equal(t, []string{"firefox"}, programs, "the hashed view should show program names")
+	_, err = hashed.GetProgramByName(ctx, "firefox")
+	assert.Nil(t, err, "programs should be found by name")
+
+	assert.Nil(t, hashed.AddProgram(ctx, database.AddProgramParams{Name: "code"}))
+	reloaded, err := privacy.Load(path)
+	assert.Nil(t, err, "Load should read the names file")
+	assert.Equal(t, "code", reloaded.Name(names.Hash("code")), "new names should be recorded in the names file")
+	assert.Equal(t, "#0123456789abcdef01234567", reloaded.Name("#0123456789abcdef01234567"), "unknown hashes should be shown as stored")
+
+	renamed, err = privacy.Apply(ctx, store, reloaded, false)
+	assert.Nil(t, err, "Apply should not err")
+	assert.Equal(t, 2, renamed)
+	stored, _ = store.GetAllProgramNames(ctx)
+	assert.ElementsMatch(t, []string{"firefox", "code"}, stored, "names should be restored once hashing is off")
+}
+
+func TestSetIncognito(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	assert.Nil(t, s.SetIncognito(true, 2*time.Hour), "turning incognito on for a time should not err")
+	assert.Nil(t, s.SetIncognito(true, 0), "turning incognito on should not err")
+	assert.Nil(t, s.SetIncognito(false, 0), "turning incognito off should not err")
+}
+
+func TestPauseService(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	assert.Nil(t, s.PauseService(45*time.Minute), "pausing for a time should not err")
+	assert.Nil(t, s.PauseService(0), "pausing until resumed should not err")
+	assert.Nil(t, s.ResumeService(), "resuming should not err")
+}
+
+func TestShowDigest(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	start := time.Now().AddDate(0, 0, -7)
+	err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+		ProgramName:     "code",
+		StartTime:       start,
+		EndTime:         start.Add(time.Hour),
+		DurationSeconds: 3600,
+	})
+	assert.Nil(t, err, "AddToSessionHistory should not return error")
+
+	err = s.ShowDigest(t.Context(), "last week", false)
+	assert.Nil(t, err, "ShowDigest should not return error")
+
+	err = s.ShowDigest(t.Context(), "last week", true)
+	assert.ErrorContains(t, err, "no digest destination", "Sending without a destination should fail")
+}
+
+func TestShowToday_Streaks(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	err = s.PrRepo.AddProgram(t.Context(), database.AddProgramParams{Name: "code", Category: sql.NullString{String: "coding", Valid: true}})
+	assert.Nil(t, err, "AddProgram should not return error")
+	s.Config = &config.Config{Timezone: "UTC", Goals: config.GoalsConfig{Daily: map[string]string{"coding": "1h"}}}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for days := 1; days <= 3; days++ {
+		start := today.AddDate(0, 0, -days).Add(9 * time.Hour)
+		err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+			ProgramName:     "code",
+			StartTime:       start,
+			EndTime:         start.Add(90 * time.Minute),
+			DurationSeconds: 5400,
+		})
+		assert.Nil(t, err, "AddToSessionHistory should not return error")
+	}
+
+	err = s.ShowToday(t.Context())
+	assert.Nil(t, err, "ShowToday should not return error")
+
+	var list []database.Streak
+	err = s.TxRepo.WithTx(t.Context(), func(store repository.Store) error {
+		list, err = store.GetStreaks(t.Context())
+		return err
+	})
+	assert.Nil(t, err, "GetStreaks should not return error")
+	if assert.Len(t, list, 1, "One goal streak should be saved") {
+		assert.Equal(t, "coding", list[0].Name)
+		assert.Equal(t, int64(3), list[0].Current, "Three days in a row met the goal")
+		assert.Equal(t, today.AddDate(0, 0, -1).Format(time.DateOnly), list[0].Through, "Streak should be evaluated through yesterday")
+	}
+}
+
+func TestGroups(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "chrome", "firefox", "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	err = s.CreateGroup(t.Context(), "browsers", []string{"chrome", "Firefox.exe"})
+	assert.Nil(t, err, "CreateGroup should not return error")
+	members, err := s.GroupRepo.GetGroupMembers(t.Context(), "browsers")
+	assert.Nil(t, err, "GetGroupMembers should not return error")
+	assert.Equal(t, []string{"chrome", "firefox"}, members, "Group should hold the programs given")
+
+	err = s.CreateGroup(t.Context(), "browsers", []string{"code"})
+	assert.ErrorContains(t, err, "already exists", "Creating a group twice should fail")
+	err = s.CreateGroup(t.Context(), "my browsers", []string{"chrome"})
+	assert.ErrorContains(t, err, "invalid group name", "A name with spaces should be refused")
+	err = s.AddToGroup(t.Context(), "editors", []string{"code"})
+	assert.ErrorContains(t, err, "not found", "Adding to a missing group should fail")
+
+	err = s.PrRepo.RenameProgram(t.Context(), "firefox", "librewolf")
+	assert.Nil(t, err, "RenameProgram should not return error")
+	members, _ = s.GroupRepo.GetGroupMembers(t.Context(), "browsers")
+	assert.Equal(t, []string{"chrome", "librewolf"}, members, "Renaming a program should keep it in its groups")
+
+	// Group goals count the time of every program in the group
+	s.Config = &config.Config{Timezone: "UTC", Goals: config.GoalsConfig{Daily: map[string]string{"group:browsers": "1h"}}}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for days := 1; days <= 2; days++ {
+		start := today.AddDate(0, 0, -days).Add(9 * time.Hour)
+		for _, name := range []string{"chrome", "librewolf"} {
+			err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+				ProgramName:     name,
+				StartTime:       start,
+				EndTime:         start.Add(40 * time.Minute),
+				DurationSeconds: 2400,
+			})
+			assert.Nil(t, err, "AddToSessionHistory should not return error")
+		}
+	}
+	err = s.ShowToday(t.Context())
+	assert.Nil(t, err, "ShowToday should not return error")
+	var list []database.Streak
+	err = s.TxRepo.WithTx(t.Context(), func(store repository.Store) error {
+		list, err = store.GetStreaks(t.Context())
+		return err
+	})
+	assert.Nil(t, err, "GetStreaks should not return error")
+	if assert.Len(t, list, 1, "One goal streak should be saved") {
+		assert.Equal(t, "group:browsers", list[0].Name)
+		assert.Equal(t, int64(2), list[0].Current, "Programs of the group should add up to meet the goal")
+	}
+
+	err = s.ShowDistribution(t.Context(), "", "", "", "", "", "", "browsers", "", false)
+	assert.Nil(t, err, "ShowDistribution should not return error for a group")
+	err = s.ShowMonth(t.Context(), "", "", "", "browsers")
+	assert.Nil(t, err, "ShowMonth should not return error for a group")
+	err = s.ShowDistribution(t.Context(), "", "", "", "", "", "", "games", "", false)
+	assert.ErrorContains(t, err, "not found", "Reporting on a missing group should fail")
+
+	err = s.RemoveFromGroup(t.Context(), "browsers", []string{"chrome"})
+	assert.Nil(t, err, "RemoveFromGroup should not return error")
+	err = s.RemoveFromGroup(t.Context(), "browsers", []string{"chrome"})
+	assert.ErrorContains(t, err, "not in group", "Removing a program twice should fail")
+
+	err = s.DeleteGroups(t.Context(), []string{"browsers"})
+	assert.Nil(t, err, "DeleteGroups should not return error")
+	programs, _ := s.PrRepo.GetAllProgramNames(t.Context())
+	assert.Len(t, programs, 3, "Deleting a group should leave its programs tracked")
+	err = s.ListGroups(t.Context())
+	assert.Nil(t, err, "ListGroups should not return error")
+}
+
+func TestCompareProjects(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code", "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	err = s.UpdateProgram(t.Context(), []string{"code"}, "", "timekeep")
+	assert.Nil(t, err, "UpdateProgram should not return error")
+
+	start := time.Now().AddDate(0, 0, -2)
+	for _, name := range []string{"code", "steam"} {
+		err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+			ProgramName:     name,
+			StartTime:       start,
+			EndTime:         start.Add(time.Hour),
+			DurationSeconds: 3600,
+		})
+		assert.Nil(t, err, "AddToSessionHistory should not return error")
+	}
+
+	err = s.CompareProjects(t.Context(), []string{"timekeep", "other"}, "7d", "", false)
+	assert.Nil(t, err, "CompareProjects should not return error")
+
+	err = s.CompareProjects(t.Context(), nil, "7d", "", false)
+	assert.ErrorContains(t, err, "no projects given", "Comparing without projects should fail")
+
+	err = s.CompareProjects(t.Context(), []string{"timekeep"}, "", "", false)
+	assert.ErrorContains(t, err, "--since", "Comparing without a start should fail")
+}
+
+func TestShowDistribution(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code", "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	err = s.UpdateProgram(t.Context(), []string{"code"}, "", "timekeep")
+	assert.Nil(t, err, "UpdateProgram should not return error")
+
+	start := time.Date(2026, 3, 9, 9, 30, 0, 0, time.UTC)
+	err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+		ProgramName:     "code",
+		StartTime:       start,
+		EndTime:         start.Add(2 * time.Hour),
+		DurationSeconds: 7200,
+	})
+	assert.Nil(t, err, "AddToSessionHistory should not return error")
+
+	err = s.ShowDistribution(t.Context(), "2026-03-09", "", "", "", "", "timekeep", "", "", false)
+	assert.Nil(t, err, "ShowDistribution should not return error")
+
+	err = s.ShowDistribution(t.Context(), "", "", "", "Code.exe", "", "", "", "", false)
+	assert.Nil(t, err, "ShowDistribution should not return error for a program")
+
+	err = s.ShowDistribution(t.Context(), "", "", "", "", "games", "", "", "", false)
+	assert.Nil(t, err, "ShowDistribution should not return error without sessions")
+
+	err = s.ShowDistribution(t.Context(), "not a date", "", "", "", "", "", "", "", false)
+	assert.NotNil(t, err, "ShowDistribution should fail on a bad date")
+}
+
+func TestShowUntracked(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code", "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	for _, session := range []struct {
+		name  string
+		start time.Time
+		mins  int
+	}{
+		{"code", time.Date(2026, 3, 9, 9, 30, 0, 0, time.Local), 120},
+		{"steam", time.Date(2026, 3, 9, 10, 0, 0, 0, time.Local), 120},
+		{"code", time.Date(2026, 3, 14, 10, 0, 0, 0, time.Local), 60},
+	} {
+		err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+			ProgramName:     session.name,
+			StartTime:       session.start,
+			EndTime:         session.start.Add(time.Duration(session.mins) * time.Minute),
+			DurationSeconds: int64(session.mins * 60),
+		})
+		assert.Nil(t, err, "AddToSessionHistory should not return error")
+	}
+
+	// Overlapping sessions count once, and the Saturday session falls outside the workdays
+	rows := tableRows(captureStdout(t, func() {
+		err = s.ShowUntracked(t.Context(), "", "2026-03-09", "2026-03-15", "", "", "", "", "", false)
+		assert.Nil(t, err, "ShowUntracked should not return error")
+	}))
+	assert.Equal(t, "8h 0m 2h 30m 5h 30m 31.2%", rows["2026-03-09 Mon"])
+	assert.Equal(t, "8h 0m 0m 8h 0m 0.0%", rows["2026-03-13 Fri"])
+	assert.NotContains(t, rows, "2026-03-14 Sat")
+	assert.Equal(t, "40h 0m 2h 30m 37h 30m 6.2%", rows["Total"])
+
+	rows = tableRows(captureStdout(t, func() {
+		err = s.ShowUntracked(t.Context(), "2026-03-09", "", "", "steam", "", "", "", "", false)
+		assert.Nil(t, err, "ShowUntracked should not return error for a program")
+	}))
+	assert.Equal(t, "8h 0m 2h 0m 6h 0m 25.0%", rows["Total"])
+
+	out := captureStdout(t, func() {
+		err = s.ShowUntracked(t.Context(), "2026-03-14", "", "", "", "", "", "", "", false)
+		assert.Nil(t, err, "ShowUntracked should not return error without workdays")
+	})
+	assert.Contains(t, out, "No workday hours in range")
+
+	err = s.ShowUntracked(t.Context(), "", "", "", "", "", "", "", "", false)
+	assert.Nil(t, err, "ShowUntracked should default to this week")
+}
+
+func TestShowWorkspaces(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code", "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	s.Config = &config.Config{Timezone: "UTC", Workspaces: config.WorkspaceConfig{Labels: map[string]string{"1": "work", "2": "work", "3": "personal"}}}
+
+	start := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	for i, workspaces := range []map[string]int64{{"1": 1800, "2": 1200, "3": 600}, nil} {
+		meta, err := repository.SessionMetadata{Workspaces: workspaces}.Encode()
+		assert.Nil(t, err, "Encode should not return error")
+		err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+			ProgramName:     []string{"code", "steam"}[i],
+			StartTime:       start,
+			EndTime:         start.Add(time.Hour),
+			DurationSeconds: 3600,
+			Metadata:        meta,
+		})
+		assert.Nil(t, err, "AddToSessionHistory should not return error")
+	}
+
+	err = s.ShowWorkspaces(t.Context(), "2026-03-09", "", "", "", "", "", "", "", false)
+	assert.Nil(t, err, "ShowWorkspaces should not return error")
+
+	err = s.ShowWorkspaces(t.Context(), "", "", "", "steam", "", "", "", "", false)
+	assert.Nil(t, err, "ShowWorkspaces should not return error without recorded workspaces")
+
+	err = s.ShowWorkspaces(t.Context(), "not a date", "", "", "", "", "", "", "", false)
+	assert.NotNil(t, err, "ShowWorkspaces should fail on a bad date")
+}
+
+func TestShowProductivity(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	ctx := t.Context()
+	s.Config = &config.Config{Timezone: "UTC", Productivity: config.ProductivityConfig{
+		Categories: map[string]string{"development": "productive", "games": "distracting"},
+		Programs:   map[string]string{"slack": "neutral"},
+	}}
+
+	sum := productivity.Summary{}
+	sum.Add(s.Config, "code", "development", 3*time.Hour)
+	sum.Add(s.Config, "steam", "games", time.Hour)
+	sum.Add(s.Config, "notes", "", time.Hour)
+	assert.Equal(t, 60, sum.ProductivePercent(), "Three of five hours should be productive")
+	assert.Equal(t, 70, sum.Pulse(), "Pulse should average 100, 0 and neutral 50 by time")
+	assert.Equal(t, time.Hour, sum.Unrated, "Unrated time should be kept apart")
+	assert.Equal(t, "", s.Config.ProductivityRating("code", "development-tools"), "Unrated programs should have no rating")
+	assert.Equal(t, "neutral", s.Config.ProductivityRating("slack", "development"), "A program's rating should win over its category's")
+
+	_ = s.PrRepo.AddProgram(ctx, database.AddProgramParams{Name: "code", Category: sql.NullString{String: "development", Valid: true}})
+	_ = s.PrRepo.AddProgram(ctx, database.AddProgramParams{Name: "steam", Category: sql.NullString{String: "games", Valid: true}})
+	start := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	for _, session := range []database.AddToSessionHistoryParams{
+		{ProgramName: "code", StartTime: start, EndTime: start.Add(2 * time.Hour), DurationSeconds: 7200},
+		{ProgramName: "steam", StartTime: start.Add(10 * time.Hour), EndTime: start.Add(11 * time.Hour), DurationSeconds: 3600},
+		{ProgramName: "code", StartTime: start.AddDate(0, 0, 7), EndTime: start.AddDate(0, 0, 7).Add(time.Hour), DurationSeconds: 3600},
+	} {
+		assert.Nil(t, s.HsRepo.AddToSessionHistory(ctx, session))
+	}
+
+	err = s.ShowProductivity(ctx, "", "2026-03-09", "2026-03-17", "", "", "", "", "", false)
+	assert.Nil(t, err, "ShowProductivity should not return error")
+
+	err = s.ShowProductivity(ctx, "2026-03-09", "", "", "", "games", "", "", "", false)
+	assert.Nil(t, err, "ShowProductivity should not return error for a category")
+
+	s.Config.Productivity.Scale = map[string]int{"productive": 150}
+	assert.NotEmpty(t, s.Config.Validate(), "Validate should reject scores over 100")
+}
+
+func TestProductivityScores(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	ctx := t.Context()
+	s.Config = &config.Config{Timezone: "UTC", Productivity: config.ProductivityConfig{
+		Weights: map[string]float64{"development": 2, "games": -1},
+		Formula: "weighted / max(total, 1) * 10",
+	}}
+
+	f, err := formula.Parse("min(abs(-pulse), 2 * (total - 1)) / 0", []string{"pulse", "total"})
+	assert.Nil(t, err, "Parse should not return error")
+	assert.Equal(t, 0.0, f.Eval(map[string]float64{"pulse": 50, "total": 3}), "Division by zero should give 0")
+	for _, bad := range []string{"weighted +", "hours", "sqrt(total)", "min(total)", "(total"} {
+		_, err := formula.Parse(bad, config.ProductivityVariables)
+		assert.NotNil(t, err, "Parse should fail on %q", bad)
+	}
+
+	pf, err := productivity.Formula(s.Config)
+	assert.Nil(t, err, "Formula should not return error")
+	sum := productivity.Summary{}
+	sum.Add(s.Config, "code", "development", 3*time.Hour)
+	sum.Add(s.Config, "steam", "games", time.Hour)
+	assert.InDelta(t, 12.5, sum.Score(pf), 0.001, "Score should be 5 weighted hours over 4 hours, times 10")
+
+	_ = s.PrRepo.AddProgram(ctx, database.AddProgramParams{Name: "code", Category: sql.NullString{String: "development", Valid: true}})
+	start := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	for i := range 2 {
+		day := start.AddDate(0, 0, i)
+		assert.Nil(t, s.HsRepo.AddToSessionHistory(ctx, database.AddToSessionHistoryParams{ProgramName: "code", StartTime: day, EndTime: day.Add(2 * time.Hour), DurationSeconds: 7200}))
+	}
+
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "scores.csv")
+	err = s.ExportScores(ctx, "", "2026-03-09", "2026-03-10", false, cli.FormatCSV, csvPath)
+	assert.Nil(t, err, "ExportScores should not return error")
+	data, err := os.ReadFile(csvPath)
+	assert.Nil(t, err, "The CSV export should be written")
+	assert.Equal(t, "date,total_hours,productive_hours,distracting_hours,productive_percent,pulse,score\n2026-03-09,2,0,0,0,50,20\n2026-03-10,2,0,0,0,50,20\n", string(data))
+
+	err = s.ExportScores(ctx, "", "2026-03-09", "2026-03-10", false, cli.FormatJSON, filepath.Join(dir, "scores.json"))
+	assert.Nil(t, err, "ExportScores should write JSON")
+	err = s.ExportScores(ctx, "", "", "", false, "xml", "")
+	assert.NotNil(t, err, "ExportScores should fail on an unknown format")
+
+	err = s.ExportChart(ctx, cli.ChartScores, "2026-03", false, filepath.Join(dir, "scores.svg"))
+	assert.Nil(t, err, "ExportChart should render scores")
+	err = s.ExportChart(ctx, cli.ChartScores, "2026-03-09", false, filepath.Join(dir, "day.svg"))
+	assert.NotNil(t, err, "ExportChart should need two days of scores")
+
+	s.Config.Productivity.Formula = "weighted * focus"
+	assert.NotEmpty(t, s.Config.Validate(), "Validate should reject unknown formula variables")
+}
+
+func TestProjectEstimates(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	ctx := t.Context()
+	s.Config = &config.Config{Timezone: "UTC"}
+
+	_ = s.PrRepo.AddProgram(ctx, database.AddProgramParams{Name: "code", Project: sql.NullString{String: "website", Valid: true}})
+	_ = s.PrRepo.AddProgram(ctx, database.AddProgramParams{Name: "figma", Project: sql.NullString{String: "website", Valid: true}})
+	start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -10)
+	for i, name := range []string{"code", "figma", "code"} {
+		day := start.AddDate(0, 0, 4*i)
+		assert.Nil(t, s.HsRepo.AddToSessionHistory(ctx, database.AddToSessionHistoryParams{ProgramName: name, StartTime: day, EndTime: day.Add(3 * time.Hour), DurationSeconds: 10800}))
+	}
+
+	err = s.ShowBurndown(ctx, "website", cli.BurndownWeek, false)
+	assert.ErrorIs(t, err, sql.ErrNoRows, "Burn-down should need an estimate")
+
+	err = s.SetEstimate(ctx, "website", "40h", false)
+	assert.Nil(t, err, "SetEstimate should not return error")
+	err = s.SetEstimate(ctx, "website", "soon", false)
+	assert.NotNil(t, err, "SetEstimate should fail on a bad duration")
+	estimate, err := s.EstRepo.GetProjectEstimate(ctx, "website")
+	assert.Nil(t, err, "GetProjectEstimate should not return error")
+	assert.Equal(t, int64(40*3600), estimate.EstimateSeconds)
+
+	err = s.SetEstimate(ctx, "website", "8h", false)
+	assert.Nil(t, err, "SetEstimate should replace an estimate")
+	err = s.ListProjects(ctx, false)
+	assert.Nil(t, err, "ListProjects should not return error")
+	err = s.ShowBurndown(ctx, "website", cli.BurndownDay, false)
+	assert.Nil(t, err, "ShowBurndown should not return error")
+	err = s.ShowBurndown(ctx, "website", "month", false)
+	assert.NotNil(t, err, "ShowBurndown should fail on an unknown period")
+
+	err = s.SetEstimate(ctx, "website", "", true)
+	assert.Nil(t, err, "SetEstimate should clear an estimate")
+	err = s.SetEstimate(ctx, "website", "", true)
+	assert.ErrorIs(t, err, sql.ErrNoRows, "Clearing a missing estimate should fail")
+}
+
+func TestRounding(t *testing.T) {
+	up := rounding.Rule{Step: 15 * time.Minute, Mode: config.RoundUp}
+	assert.Equal(t, 15*time.Minute, up.Round(time.Minute), "Up should round to the next step")
+	assert.Equal(t, 30*time.Minute, up.Round(30*time.Minute), "Time on a step should stay")
+	nearest := rounding.Rule{Step: 6 * time.Minute, Mode: config.RoundNearest}
+	assert.Equal(t, 6*time.Minute, nearest.Round(9*time.Minute-time.Second), "Nearest should round down below halfway")
+	assert.Equal(t, 12*time.Minute, nearest.Round(9*time.Minute), "Nearest should round up from halfway")
+	down := rounding.Rule{Step: 15 * time.Minute, Mode: config.RoundDown}
+	assert.Equal(t, 0*time.Minute, down.Round(14*time.Minute), "Down should round to the last step")
+
+	sessions := []time.Duration{5 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	assert.Equal(t, 45*time.Minute, up.Day(sessions), "Per entry should add up rounded sessions")
+	up.PerDay = true
+	assert.Equal(t, 15*time.Minute, up.Day(sessions), "Per day should round the day's total")
+	assert.Equal(t, "rounded up to 15m per day", up.String())
+
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	ctx := t.Context()
+	_ = s.PrRepo.AddProgram(ctx, database.AddProgramParams{Name: "code", Project: sql.NullString{String: "website", Valid: true}})
+	start := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	for _, mins := range []int{7, 52} {
+		assert.Nil(t, s.HsRepo.AddToSessionHistory(ctx, database.AddToSessionHistoryParams{ProgramName: "code", StartTime: start, EndTime: start.Add(time.Duration(mins) * time.Minute), DurationSeconds: int64(mins * 60)}))
+		start = start.Add(time.Hour)
+	}
+
+	s.Config = &config.Config{Timezone: "UTC", Device: "laptop", Rounding: config.RoundingConfig{Increment: "15m", Mode: config.RoundUp}, Harvest: config.HarvestConfig{Project: 7, Task: 8}}
+	entries, err := harvest.Pending(ctx, s.TxRepo, s.Config, time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, err, "Pending should not return error")
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, 0.25, entries[0].Hours, "Entries should be rounded up")
+		assert.Equal(t, 1.0, entries[1].Hours, "Entries should be rounded up")
+	}
+	s.Config.Harvest.Mode = config.HarvestDaily
+	s.Config.Rounding.Per = config.RoundPerDay
+	entries, err = harvest.Pending(ctx, s.TxRepo, s.Config, time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, err, "Pending should not return error")
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, 1.0, entries[0].Hours, "A daily entry should round the day's 59 minutes")
+	}
+
+	err = s.ShowTimesheet(ctx, "2026-03-09", "", "", "", "", "", "", "", false)
+	assert.Nil(t, err, "ShowTimesheet should not return error")
+	s.Config.Rounding.Mode = "sideways"
+	assert.NotEmpty(t, s.Config.Validate(), "Validate should reject an unknown rounding mode")
+}
+
+func TestImportCSV(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	ctx := t.Context()
+	s.Config = &config.Config{Timezone: "UTC"}
+	_ = s.PrRepo.AddProgram(ctx, database.AddProgramParams{Name: "code"})
+	_ = s.PrRepo.AddProgram(ctx, database.AddProgramParams{Name: "chrome"})
+	assert.Nil(t, s.HsRepo.AddToSessionHistory(ctx, database.AddToSessionHistoryParams{ProgramName: "code", StartTime: time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC), EndTime: time.Date(2026, 3, 9, 10, 0, 0, 0, time.UTC), DurationSeconds: 3600}))
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	invalid := write("invalid.csv", "program,start,end\ncode,2026-03-08 09:00,2026-03-08 08:00\nslack,2026-03-08 09:00,2026-03-08 10:00\n")
+	err = s.ImportCSV(ctx, invalid, "program=1,start=2,end=3", ",", "", true, false, false)
+	assert.ErrorContains(t, err, "2 invalid rows", "Invalid rows should fail the import")
+
+	err = s.ImportCSV(ctx, invalid, "program=1,start=2", ",", "", true, false, false)
+	assert.ErrorContains(t, err, "end or the duration", "A mapping without end or duration should be rejected")
+
+	path := write("sessions.csv", "App;Began;Length\nCode;2026-03-09 09:30;1:00\nchrome;2026-03-09T09:30:00Z;45m\ncode;2026-03-08 20:00;30m\n")
+	mapping := "program=App,start=Began,duration=Length"
+	err = s.ImportCSV(ctx, path, mapping, ";", "", true, false, true)
+	assert.Nil(t, err, "A dry run should not return error")
+	err = s.ImportCSV(ctx, path, mapping, ";", "", true, false, false)
+	assert.ErrorContains(t, err, "1 row overlap", "Overlapping rows should fail the import")
+	history, _ := s.HsRepo.GetAllSessionHistory(ctx, database.GetAllSessionHistoryParams{Limit: -1})
+	assert.Len(t, history, 1, "A failed import should add nothing")
+
+	err = s.ImportCSV(ctx, path, mapping, ";", "", true, true, false)
+	assert.Nil(t, err, "ImportCSV should not return error")
+	history, _ = s.HsRepo.GetAllSessionHistory(ctx, database.GetAllSessionHistoryParams{Limit: -1})
+	assert.Len(t, history, 3, "Rows not overlapping should be imported")
+	for _, session := range []database.SessionHistory{history[0], history[2]} {
+		metadata, err := repository.DecodeSessionMetadata(session.Metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, repository.SourceImport, metadata.Source, "Imported sessions should be marked")
+	}
+	chrome, err := s.PrRepo.GetProgramByName(ctx, "chrome")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(45*60), chrome.LifetimeSeconds, "Imported time should add to the lifetime")
+
+	err = s.ImportCSV(ctx, path, mapping, ";", "", true, false, false)
+	assert.ErrorContains(t, err, "3 rows overlap", "Importing a file twice should be refused")
+}
+
+func TestUseProfile(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	t.Setenv("HOME", t.TempDir())
+
+	active, err := profile.Active()
+	assert.Nil(t, err)
+	assert.Empty(t, active, "No profile should be in use before switching")
+	inUse, _ := profile.InUse()
+	assert.True(t, inUse, "Every profile records before switching")
+
+	err = s.UseProfile("work")
+	assert.Nil(t, err, "UseProfile should not return error")
+	active, _ = profile.Active()
+	assert.Equal(t, "work", active)
+	inUse, active = profile.InUse()
+	assert.False(t, inUse, "The default profile should stand by for work")
+	assert.Equal(t, "work", active)
+	assert.Equal(t, "", profile.Name(), "Switching should leave this command's profile as it was")
+
+	err = s.ListProfiles()
+	assert.Nil(t, err, "ListProfiles should not return error")
+
+	err = s.UseProfile("default")
+	assert.Nil(t, err, "UseProfile should not return error")
+	inUse, _ = profile.InUse()
+	assert.True(t, inUse, "The default profile should record once switched back to")
+
+	err = s.UseProfile("Not Valid")
+	assert.NotNil(t, err, "Invalid profile names should be rejected")
+}
+
+func TestProtocolNegotiation(t *testing.T) {
+	assert.Nil(t, ipc.OKResponse(nil).Negotiate(), "Responses of the same version should be accepted")
+	assert.Equal(t, ipc.MinProtocolVersion, ipc.OKResponse(nil).MinVersion, "Responses should carry the oldest version understood")
+
+	var versionErr *ipc.VersionError
+	err := ipc.Response{Version: ipc.ProtocolVersion - 1, Code: ipc.CodeUnsupportedVersion, Error: "service supports protocol version 0"}.Negotiate()
+	if assert.ErrorAs(t, err, &versionErr, "An older service refusing the request should be a version error") {
+		assert.Contains(t, err.Error(), "please update the service")
+	}
+
+	err = ipc.Response{Version: ipc.ProtocolVersion + 1, MinVersion: ipc.ProtocolVersion + 1, OK: true}.Negotiate()
+	if assert.ErrorAs(t, err, &versionErr, "A service that dropped this version should be a version error") {
+		assert.Contains(t, err.Error(), "please update the CLI")
+	}
+
+	assert.Nil(t, ipc.Response{Version: ipc.ProtocolVersion + 1, MinVersion: ipc.MinProtocolVersion, OK: true}.Negotiate(), "A newer service still understanding this version should be accepted")
+}
+
+func TestLaunchFilter(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "nvim")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	launches := []*repository.LaunchContext{{Parent: "bash", Terminal: "tmux", User: "me"}, {Parent: "plasmashell", User: "me"}, nil}
+	for filter, want := range map[string]int{"terminal": 1, "gui": 1, "terminal=TMUX": 1, "terminal=kitty": 0, "parent=bash,user=me": 1, "user=me": 2} {
+		f, err := repository.ParseLaunchFilter(filter)
+		assert.Nil(t, err, "ParseLaunchFilter should not return error for %q", filter)
+		count := 0
+		for _, launch := range launches {
+			if f.Match(launch) {
+				count++
+			}
+		}
+		assert.Equal(t, want, count, "sessions matching %q", filter)
+	}
+
+	_, err = repository.ParseLaunchFilter("ssh")
+	assert.NotNil(t, err, "ParseLaunchFilter should fail on an unknown condition")
+
+	start := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	for i, launch := range launches {
+		meta, err := repository.SessionMetadata{Launch: launch}.Encode()
+		assert.Nil(t, err, "Encode should not return error")
+		err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+			ProgramName:     "nvim",
+			StartTime:       start.Add(time.Duration(i) * time.Hour),
+			EndTime:         start.Add(time.Duration(i)*time.Hour + 30*time.Minute),
+			DurationSeconds: 1800,
+			Metadata:        meta,
+		})
+		assert.Nil(t, err, "AddToSessionHistory should not return error")
+	}
+
+	err = s.ShowDistribution(t.Context(), "2026-03-09", "", "", "nvim", "", "", "", "terminal", false)
+	assert.Nil(t, err, "ShowDistribution should not return error with a launch filter")
+
+	err = s.ShowUntracked(t.Context(), "2026-03-09", "", "", "", "", "", "", "gui", false)
+	assert.Nil(t, err, "ShowUntracked should not return error with a launch filter")
+
+	err = s.ShowWorkspaces(t.Context(), "2026-03-09", "", "", "", "", "", "", "tty", false)
+	assert.NotNil(t, err, "ShowWorkspaces should fail on a bad launch filter")
+}
+
+func TestShowMonth(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code", "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	err = s.UpdateProgram(t.Context(), []string{"steam"}, "games", "")
+	assert.Nil(t, err, "UpdateProgram should not return error")
+
+	for _, session := range []struct {
+		name  string
+		start time.Time
+		mins  int
+	}{
+		{"code", time.Date(2026, 3, 9, 9, 0, 0, 0, time.Local), 300},
+		{"steam", time.Date(2026, 3, 14, 20, 0, 0, 0, time.Local), 90},
+		{"code", time.Date(2026, 3, 31, 10, 0, 0, 0, time.Local), 60},
+	} {
+		err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+			ProgramName:     session.name,
+			StartTime:       session.start,
+			EndTime:         session.start.Add(time.Duration(session.mins) * time.Minute),
+			DurationSeconds: int64(session.mins * 60),
+		})
+		assert.Nil(t, err, "AddToSessionHistory should not return error")
+	}
+
+	err = s.ShowMonth(t.Context(), "2026-03", "", "", "")
+	assert.Nil(t, err, "ShowMonth should not return error")
+
+	err = s.ShowMonth(t.Context(), "2026-03", "", "games", "")
+	assert.Nil(t, err, "ShowMonth should not return error for a category")
+
+	err = s.ShowMonth(t.Context(), "", "Code.exe", "", "")
+	assert.Nil(t, err, "ShowMonth should not return error for the current month")
+
+	err = s.ShowMonth(t.Context(), "March", "", "", "")
+	assert.ErrorContains(t, err, "invalid month", "ShowMonth should fail on a bad month")
+}
+
+func TestExportChart(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code", "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	err = s.UpdateProgram(t.Context(), []string{"steam"}, "games", "")
+	assert.Nil(t, err, "UpdateProgram should not return error")
+
+	for _, session := range []struct {
+		name  string
+		start time.Time
+		mins  int
+	}{
+		{"code", time.Date(2026, 3, 9, 9, 0, 0, 0, time.Local), 90},
+		{"steam", time.Date(2026, 3, 9, 20, 0, 0, 0, time.Local), 60},
+		{"code", time.Date(2026, 3, 9, 23, 30, 0, 0, time.Local), 60},
+	} {
+		err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+			ProgramName:     session.name,
+			StartTime:       session.start,
+			EndTime:         session.start.Add(time.Duration(session.mins) * time.Minute),
+			DurationSeconds: int64(session.mins * 60),
+		})
+		assert.Nil(t, err, "AddToSessionHistory should not return error")
+	}
+
+	dir := t.TempDir()
+	svg := filepath.Join(dir, "day.svg")
+	err = s.ExportChart(t.Context(), cli.ChartTimeline, "2026-03-09", false, svg)
+	assert.Nil(t, err, "ExportChart should not return error")
+	data, err := os.ReadFile(svg)
+	assert.Nil(t, err, "Chart should be written")
+	assert.Contains(t, string(data), "<svg", "Chart should be an SVG image")
+	assert.Contains(t, string(data), "steam", "Chart should label each program")
+	assert.Contains(t, string(data), "games", "Chart should list categories")
+
+	png := filepath.Join(dir, "day.png")
+	err = s.ExportChart(t.Context(), cli.ChartTimeline, "2026-03-09", false, png)
+	assert.Nil(t, err, "ExportChart should not return error for PNG")
+	data, err = os.ReadFile(png)
+	assert.Nil(t, err, "Chart should be written")
+	assert.True(t, bytes.HasPrefix(data, []byte("\x89PNG")), "Chart should be a PNG image")
+
+	err = s.ExportChart(t.Context(), "pie", "", false, svg)
+	assert.ErrorContains(t, err, "unknown chart type", "ExportChart should fail on an unknown type")
+}
+
+func TestExportReport(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code", "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	err = s.UpdateProgram(t.Context(), []string{"steam"}, "<games>", "")
+	assert.Nil(t, err, "UpdateProgram should not return error")
+
+	for _, session := range []struct {
+		name  string
+		start time.Time
+		mins  int
+	}{
+		{"code", time.Date(2026, 3, 9, 9, 0, 0, 0, time.Local), 90},
+		{"steam", time.Date(2026, 3, 9, 20, 0, 0, 0, time.Local), 60},
+		{"code", time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local), 120},
+	} {
+		err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+			ProgramName:     session.name,
+			StartTime:       session.start,
+			EndTime:         session.start.Add(time.Duration(session.mins) * time.Minute),
+			DurationSeconds: int64(session.mins * 60),
+		})
+		assert.Nil(t, err, "AddToSessionHistory should not return error")
+	}
+
+	dir := t.TempDir()
+	page := filepath.Join(dir, "week.html")
+	err = s.ExportReport(t.Context(), "", "2026-03-09", "2026-03-15", "", page)
+	assert.Nil(t, err, "ExportReport should not return error")
+	data, err := os.ReadFile(page)
+	assert.Nil(t, err, "Report should be written")
+	assert.Equal(t, 3, strings.Count(string(data), "<svg"), "HTML report should carry its charts inline as SVG")
+	assert.Contains(t, string(data), "Total: 4h 30m over 2 days", "Report should sum the range")
+	assert.NotContains(t, string(data), "<games>", "Names should be escaped")
+
+	doc := filepath.Join(dir, "week.md")
+	err = s.ExportReport(t.Context(), "", "2026-03-09", "2026-03-15", "", doc)
+	assert.Nil(t, err, "ExportReport should not return error for Markdown")
+	data, err = os.ReadFile(doc)
+	assert.Nil(t, err, "Report should be written")
+	assert.Equal(t, 3, strings.Count(string(data), "](data:image/png;base64,"), "Markdown report should carry its charts as base64 PNG")
+	assert.Contains(t, string(data), "| code | 3h 30m | 77.8% |", "Markdown report should list programs")
+
+	err = s.ExportReport(t.Context(), "2026-03-10", "", "", cli.ReportMarkdown, "")
+	assert.Nil(t, err, "ExportReport should not return error without sessions")
+
+	err = s.ExportReport(t.Context(), "", "", "", "pdf", "")
+	assert.ErrorContains(t, err, "unknown format", "ExportReport should fail on an unknown format")
+}
+
+func TestTrayStatus(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code", "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	err = s.UpdateProgram(t.Context(), []string{"code"}, "coding", "")
+	assert.Nil(t, err, "UpdateProgram should not return error")
+	s.Config = &config.Config{
+		Timezone: "UTC",
+		Goals:    config.GoalsConfig{Daily: map[string]string{"coding": "2h"}},
+		Limits:   config.LimitsConfig{Daily: map[string]string{"steam": "1h"}},
+	}
+
+	now := time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC)
+	err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+		ProgramName:     "code",
+		StartTime:       now.Add(-4 * time.Hour),
+		EndTime:         now.Add(-3 * time.Hour),
+		DurationSeconds: 3600,
+	})
+	assert.Nil(t, err, "AddToSessionHistory should not return error")
+
+	status, err := s.TrayStatus(t.Context(), now)
+	assert.Nil(t, err, "TrayStatus should not return error")
+	assert.Equal(t, time.Hour, status.Today)
+	assert.Empty(t, status.Alerts, "No goal or limit is reached yet")
+	assert.Equal(t, "Nothing running, 1h 0m today", status.Title())
+
+	err = s.AsRepo.CreateActiveSession(t.Context(), database.CreateActiveSessionParams{ProgramName: "code", StartTime: now.Add(-90 * time.Minute)})
+	assert.Nil(t, err, "CreateActiveSession should not return error")
+	err = s.AsRepo.CreateActiveSession(t.Context(), database.CreateActiveSessionParams{ProgramName: "steam", StartTime: now.Add(-70 * time.Minute)})
+	assert.Nil(t, err, "CreateActiveSession should not return error")
+
+	status, err = s.TrayStatus(t.Context(), now)
+	assert.Nil(t, err, "TrayStatus should not return error")
+	assert.Equal(t, "steam", status.Program, "The program started last should be shown")
+	assert.Equal(t, []string{"code", "steam"}, status.Running)
+	assert.Equal(t, 3*time.Hour+40*time.Minute, status.Today, "Running sessions should count towards today")
+	assert.Equal(t, []string{"coding met its 2h 0m goal", "steam over its 1h 0m limit"}, status.Alerts)
+	assert.Equal(t, "steam 1h 10m, 3h 40m today", status.Title())
+}
+
+func TestRunQuery(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	db, err := mysql.OpenLocalDatabase()
+	if err != nil {
+		t.Fatalf("Failed to open local database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("INSERT INTO tracked_programs (name, category) VALUES ('code', 'coding')")
+	assert.Nil(t, err, "Insert should not return error")
+
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	for _, format := range []string{"table", "csv", "json"} {
+		err = s.RunQuery(t.Context(), "SELECT name, category, project FROM tracked_programs", format)
+		assert.Nil(t, err, "RunQuery should not return error with format %s", format)
+	}
+
+	err = s.RunQuery(t.Context(), "DELETE FROM tracked_programs", "table")
+	assert.ErrorContains(t, err, "readonly", "Writing should be refused")
+
+	var count int
+	err = db.QueryRow("SELECT count(*) FROM tracked_programs").Scan(&count)
+	assert.Nil(t, err, "Count should not return error")
+	assert.Equal(t, 1, count, "Program should still be tracked")
+
+	err = s.RunQuery(t.Context(), "SELECT 1", "xml")
+	assert.ErrorContains(t, err, "unknown format", "Unknown formats should be refused")
+}
+
+func TestDataAPI(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code", "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	err = s.UpdateProgram(t.Context(), []string{"code"}, "coding", "")
+	assert.Nil(t, err, "UpdateProgram should not return error")
+
+	start := time.Now().Add(-3 * time.Hour).UTC().Truncate(time.Second)
+	for i, name := range []string{"code", "steam", "code"} {
+		err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+			ProgramName:     name,
+			StartTime:       start.Add(time.Duration(i) * time.Hour),
+			EndTime:         start.Add(time.Duration(i)*time.Hour + 30*time.Minute),
+			DurationSeconds: 1800,
+		})
+		assert.Nil(t, err, "AddToSessionHistory should not return error")
+	}
+
+	src := &api.Source{Store: s.TxRepo.(repository.TxStore)}
+	handler, err := api.NewHandler(src, func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer test" })
+	if err != nil {
+		t.Fatalf("Failed to create API handler: %v", err)
+	}
+	from := url.QueryEscape(start.Add(-time.Minute).Format(time.RFC3339))
+	get := func(target string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer test")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := get(api.TotalsPath + "?by=category&from=" + from)
+	assert.Equal(t, http.StatusOK, code, body)
+	assert.JSONEq(t, `{"totals":[{"key":"coding","seconds":3600},{"key":"","seconds":1800}]}`, body)
+
+	code, body = get(api.SessionsPath + "?program=code&limit=1&from=" + from)
+	assert.Equal(t, http.StatusOK, code, body)
+	assert.Contains(t, body, `"duration_seconds":1800`)
+	assert.Equal(t, 1, strings.Count(body, `"id"`), "limit should cap the sessions returned")
+
+	code, _ = get(api.TotalsPath + "?by=week")
+	assert.Equal(t, http.StatusBadRequest, code, "Unknown groupings should be refused")
+
+	query := `query Totals($from: String, $by: Grouping) {
+		programs(name: "code") { name category }
+		byProject: totals(from: $from, by: $by) { key seconds }
+		...Active
+	}
+	fragment Active on Query { active { program } }`
+	payload, _ := json.Marshal(map[string]any{"query": query, "variables": map[string]any{"from": start.Add(-time.Minute).Format(time.RFC3339), "by": "PROJECT"}})
+	req := httptest.NewRequest(http.MethodPost, api.GraphQLPath, bytes.NewReader(payload))
+	req.Header.Set("Authorization", "Bearer test")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"data":{"programs":[{"name":"code","category":"coding"}],"byProject":[{"key":"","seconds":5400}],"active":[]}}`, rec.Body.String())
+
+	code, body = get(api.GraphQLPath + "?query=" + url.QueryEscape("{ programs { name secret } }"))
+	assert.Equal(t, http.StatusOK, code, body)
+	assert.Contains(t, body, `cannot query field \"secret\" on type Program`)
+
+	code, _ = get(api.GraphQLPath + "?query=" + url.QueryEscape("mutation { programs { name } }"))
+	assert.Equal(t, http.StatusBadRequest, code, "Mutations should be refused")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, api.ProgramsPath, nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "Requests without a token should be refused")
+
+	code, _ = get(api.SessionsPath + "?limit=0")
+	assert.Equal(t, http.StatusBadRequest, code, "Limits below the document's minimum should be refused")
+	code, _ = get(api.ActivePath + "?since=today")
+	assert.Equal(t, http.StatusBadRequest, code, "Parameters missing from the document should be refused")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, api.OpenAPIPath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "The OpenAPI document should be public")
+	var doc map[string]any
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &doc), "The OpenAPI document should be JSON")
+	assert.Equal(t, "3.0.3", doc["openapi"])
+}
+
+func TestTestWebhooks(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t)
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	bodies := make(map[string]map[string]any)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&body), "Bodies should be JSON")
+		bodies[r.URL.Path] = body
+	}))
+	defer server.Close()
+
+	s.Config = &config.Config{Webhooks: []config.WebhookConfig{
+		{Name: "zap", URL: server.URL + "/zap", Format: config.WebhookFormatZapier, Categories: []string{"games"}},
+		{Name: "ifttt", URL: server.URL + "/ifttt", Format: config.WebhookFormatIFTTT},
+	}}
+
+	err = s.TestWebhooks(t.Context(), "", "end", "code", false)
+	assert.Nil(t, err, "TestWebhooks should not return error")
+	assert.Equal(t, "session.end", bodies["/zap"]["event"], "Filters shouldn't apply to tests")
+	assert.Equal(t, float64(45), bodies["/zap"]["duration_minutes"])
+	assert.Contains(t, bodies["/zap"], "project", "Zapier bodies should have every key")
+	assert.Equal(t, map[string]any{"value1": "code", "value2": "", "value3": "45"}, bodies["/ifttt"])
+
+	err = s.TestWebhooks(t.Context(), "missing", "end", "code", false)
+	assert.ErrorContains(t, err, "no webhook named", "Unknown names should fail")
+
+	err = s.TestWebhooks(t.Context(), "zap", "pause", "code", false)
+	assert.ErrorContains(t, err, "unknown event", "Unknown events should fail")
+}
+
+func TestExportTimewarrior(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	err = s.UpdateProgram(t.Context(), []string{"code"}, "coding", "my project")
+	assert.Nil(t, err, "UpdateProgram should not return error")
+
+	start := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+		ProgramName:     "code",
+		StartTime:       start,
+		EndTime:         start.Add(90 * time.Minute),
+		DurationSeconds: 5400,
+	})
+	assert.Nil(t, err, "AddToSessionHistory should not return error")
+
+	dir := t.TempDir()
+	err = s.ExportTimewarrior(t.Context(), nil, "2026-03-09", "", "", false, cli.TimewData, filepath.Join(dir, "out.data"))
+	assert.Nil(t, err, "ExportTimewarrior should not return error")
+	data, _ := os.ReadFile(filepath.Join(dir, "out.data"))
+	assert.Equal(t, "inc 20260309T090000Z - 20260309T103000Z # code coding \"my project\"\n", string(data))
+
+	err = s.ExportTimewarrior(t.Context(), []string{"code"}, "", "2026-03-01", "2026-03-31", false, cli.TimewJSON, filepath.Join(dir, "out.json"))
+	assert.Nil(t, err, "ExportTimewarrior should not return error")
+	data, _ = os.ReadFile(filepath.Join(dir, "out.json"))
+	assert.JSONEq(t, `[{"start":"20260309T090000Z","end":"20260309T103000Z","tags":["code","coding","my project"]}]`, string(data))
+
+	err = s.ExportTimewarrior(t.Context(), nil, "2026-03-09", "", "", false, cli.TimewTrack, filepath.Join(dir, "out.sh"))
+	assert.Nil(t, err, "ExportTimewarrior should not return error")
+	data, _ = os.ReadFile(filepath.Join(dir, "out.sh"))
+	assert.Contains(t, string(data), "timew track 20260309T090000Z - 20260309T103000Z 'code' 'coding' 'my project'\n")
+
+	err = s.ExportTimewarrior(t.Context(), nil, "", "", "", false, "csv", "")
+	assert.ErrorContains(t, err, "unknown format", "Unknown formats should fail")
+}
+
+func TestReportDevice(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code", "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	start := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	for i, device := range []string{"laptop", "desktop", "desktop"} {
+		err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+			ProgramName:     []string{"code", "steam", "steam"}[i],
+			StartTime:       start.Add(time.Duration(i) * time.Hour),
+			EndTime:         start.Add(time.Duration(i)*time.Hour + 30*time.Minute),
+			DurationSeconds: 1800,
+			Device:          database.NullString(device),
+		})
+		assert.Nil(t, err, "AddToSessionHistory should not return error")
+	}
+
+	dir := t.TempDir()
+	s.Device = "laptop"
+	err = s.ExportTimewarrior(t.Context(), nil, "2026-03-09", "", "", false, cli.TimewData, filepath.Join(dir, "out.data"))
+	assert.Nil(t, err, "ExportTimewarrior should not return error")
+	data, _ := os.ReadFile(filepath.Join(dir, "out.data"))
+	assert.Equal(t, "inc 20260309T090000Z - 20260309T093000Z # code\n", string(data), "Only laptop's session should be exported")
+
+	s.Device = "desktop"
+	err = s.ExportScores(t.Context(), "2026-03-09", "", "", false, cli.FormatJSON, filepath.Join(dir, "scores.json"))
+	assert.Nil(t, err, "ExportScores should not return error")
+	var scores []struct {
+		TotalHours float64 `json:"total_hours"`
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "scores.json"))
+	assert.Nil(t, json.Unmarshal(data, &scores))
+	if assert.Len(t, scores, 1) {
+		assert.Equal(t, 1.0, scores[0].TotalHours, "Only desktop's sessions should count")
+	}
+}
+
+func TestPushKimai(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code", "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	start := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	for _, name := range []string{"code", "steam"} {
+		err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+			ProgramName:     name,
+			StartTime:       start,
+			EndTime:         start.Add(90 * time.Minute),
+			DurationSeconds: 5400,
+		})
+		assert.Nil(t, err, "AddToSessionHistory should not return error")
+	}
+
+	var timesheets []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/timesheets", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var timesheet map[string]any
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&timesheet), "Timesheet should be JSON")
+		timesheets = append(timesheets, timesheet)
+	}))
+	defer server.Close()
+
+	s.Config = &config.Config{Timezone: "UTC", Kimai: config.KimaiConfig{
+		Enabled:  true,
+		Server:   server.URL,
+		Token:    "secret",
+		Mappings: []config.KimaiMapping{{SessionFilter: config.SessionFilter{Programs: []string{"co*"}}, Project: 4, Activity: 9}},
+	}}
+
+	err = s.PushKimai(t.Context(), "", false)
+	assert.Nil(t, err, "PushKimai should not return error")
+	assert.Len(t, timesheets, 1, "The first push should only cover today's mapped sessions")
+
+	err = s.PushKimai(t.Context(), "2026-03-01", false)
+	assert.Nil(t, err, "PushKimai should not return error")
+	if assert.Len(t, timesheets, 2, "Earlier sessions should be pushed from --since") {
+		assert.Equal(t, map[string]any{
+			"begin":       "2026-03-09T09:00:00",
+			"end":         "2026-03-09T10:30:00",
+			"project":     float64(4),
+			"activity":    float64(9),
+			"description": "code",
+		}, timesheets[1])
+	}
+
+	err = s.PushKimai(t.Context(), "2026-03-01", false)
+	assert.Nil(t, err, "PushKimai should not return error")
+	assert.Len(t, timesheets, 2, "Sessions should never be pushed twice")
+
+	s.Config.Kimai.Enabled = false
+	err = s.PushKimai(t.Context(), "", false)
+	assert.ErrorContains(t, err, "kimai is disabled", "Pushing while disabled should fail")
+}
+
+func TestPushHarvest(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code", "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	for _, session := range []struct {
+		name  string
+		start time.Time
+		mins  int
+	}{
+		{"code", time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC), 90},
+		{"code", time.Date(2026, 3, 9, 13, 0, 0, 0, time.UTC), 30},
+		{"steam", time.Date(2026, 3, 9, 20, 0, 0, 0, time.UTC), 60},
+		{"code", time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC), 60},
+	} {
+		err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+			ProgramName:     session.name,
+			StartTime:       session.start,
+			EndTime:         session.start.Add(time.Duration(session.mins) * time.Minute),
+			DurationSeconds: int64(session.mins * 60),
+		})
+		assert.Nil(t, err, "AddToSessionHistory should not return error")
+	}
+
+	existing := map[string]int64{}
+	var created []harvest.TimeEntry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/time_entries", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Equal(t, "123", r.Header.Get("Harvest-Account-Id"))
+
+		if r.Method == http.MethodGet {
+			key := r.URL.Query().Get("external_reference_id")
+			entries := []map[string]any{}
+			if id, ok := existing[key]; ok {
+				entries = append(entries, map[string]any{"id": id, "external_reference": map[string]string{"id": key}})
+			}
+			json.NewEncoder(w).Encode(map[string]any{"time_entries": entries})
+			return
+		}
+
+		var entry harvest.TimeEntry
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&entry), "Time entry should be JSON")
+		created = append(created, entry)
+		existing[entry.ExternalReference.ID] = int64(len(created))
+		json.NewEncoder(w).Encode(map[string]any{"id": len(created)})
+	}))
+	defer server.Close()
+
+	s.Config = &config.Config{Timezone: "UTC", Device: "laptop", Harvest: config.HarvestConfig{
+		Enabled:   true,
+		AccountID: "123",
+		Token:     "secret",
+		Server:    server.URL,
+		Mappings:  []config.HarvestMapping{{SessionFilter: config.SessionFilter{Programs: []string{"code"}}, Project: 7, Task: 8}},
+	}}
+
+	err = s.PushHarvest(t.Context(), "2026-03-09", false)
+	assert.Nil(t, err, "PushHarvest should not return error")
+	if assert.Len(t, created, 4, "Each mapped session should get an entry") {
+		assert.Equal(t, harvest.TimeEntry{
+			ProjectID:         7,
+			TaskID:            8,
+			SpentDate:         "2026-03-09",
+			Hours:             1.5,
+			Notes:             "code 09:00-10:30",
+			ExternalReference: harvest.Reference{ID: harvest.Key("laptop", "code", "2026-03-09T09:00:00Z"), GroupID: "timekeep"},
+		}, created[1])
+	}
+
+	err = s.PushHarvest(t.Context(), "2026-03-09", false)
+	assert.Nil(t, err, "PushHarvest should not return error")
+	assert.Len(t, created, 4, "Pushing again should not duplicate entries")
+
+	// The entry of March 10 already exists in Harvest, as if an earlier push was cut off before recording it
+	existing[harvest.Key("laptop", "2026-03-10", "7", "8")] = 99
+	s.Config.Harvest.Mode = config.HarvestDaily
+	err = s.PushHarvest(t.Context(), "2026-03-09", false)
+	assert.Nil(t, err, "PushHarvest should not return error")
+	if assert.Len(t, created, 5, "Daily mode should create an entry per completed day") {
+		assert.Equal(t, 2.0, created[4].Hours)
+		assert.Equal(t, "2026-03-09", created[4].SpentDate)
+		assert.Equal(t, "code 2h", created[4].Notes)
+	}
+
+	err = s.PushHarvest(t.Context(), "2026-03-09", false)
+	assert.Nil(t, err, "PushHarvest should not return error")
+	assert.Len(t, created, 5, "Pushing days again should not duplicate entries")
+
+	s.Config.Harvest.Enabled = false
+	err = s.PushHarvest(t.Context(), "", false)
+	assert.ErrorContains(t, err, "harvest is disabled", "Pushing while disabled should fail")
+}
+
+func TestShowStatusbar(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		t.Fatalf("Failed to get cache directory: %v", err)
+	}
+
+	err = s.AsRepo.CreateActiveSession(t.Context(), database.CreateActiveSessionParams{ProgramName: "code", StartTime: time.Now().Add(-time.Hour)})
+	assert.Nil(t, err, "CreateActiveSession should not return error")
+
+	for _, format := range []string{cli.StatusbarWaybar, cli.StatusbarPolybar, cli.StatusbarI3blocks, cli.StatusbarText} {
+		err = s.ShowStatusbar(t.Context(), format, cli.DefaultStatusbarCache)
+		assert.Nil(t, err, "ShowStatusbar should not return error for %s", format)
+	}
+
+	data, err := os.ReadFile(filepath.Join(cache, "timekeep", "statusbar.json"))
+	assert.Nil(t, err, "The status should be cached")
+	var cached struct {
+		Status cli.TrayStatus `json:"status"`
+	}
+	if assert.Nil(t, json.Unmarshal(data, &cached), "The cache should be JSON") {
+		assert.Equal(t, "code", cached.Status.Program)
+	}
+
+	err = s.ShowStatusbar(t.Context(), "dzen", 0)
+	assert.ErrorContains(t, err, "unknown format", "ShowStatusbar should fail on an unknown format")
+}
+
+func TestShowPrompt(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	err = s.UpdateProgram(t.Context(), []string{"code"}, "coding", "")
+	assert.Nil(t, err, "UpdateProgram should not return error")
+	err = s.AsRepo.CreateActiveSession(t.Context(), database.CreateActiveSessionParams{ProgramName: "code", StartTime: time.Now().Add(-time.Hour)})
+	assert.Nil(t, err, "CreateActiveSession should not return error")
+
+	for _, show := range []string{config.PromptSession, config.PromptToday, config.PromptGoal} {
+		s.Config = &config.Config{Prompt: config.PromptConfig{Show: show}, Goals: config.GoalsConfig{Daily: map[string]string{"coding": "8h"}}}
+		for _, style := range []string{cli.PromptStarship, cli.PromptOhMyPosh} {
+			err = s.ShowPrompt(t.Context(), style)
+			assert.Nil(t, err, "ShowPrompt should not return error for %s in %s", show, style)
+		}
+	}
+
+	s.Config = &config.Config{Prompt: config.PromptConfig{Show: "weekly"}}
+	assert.NotEmpty(t, s.Config.Validate(), "An unknown metric should be reported")
+	assert.Equal(t, config.PromptSession, s.Config.PromptShows(), "An unknown metric should fall back to the session")
+
+	err = s.ShowPrompt(t.Context(), "powerline")
+	assert.ErrorContains(t, err, "unknown style", "ShowPrompt should fail on an unknown style")
+}
+
+func TestFuzzyProgramNames(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "chrome", "chromium", "code")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	err = s.GetInfo(t.Context(), []string{"cod"})
+	assert.Nil(t, err, "A single match should be used")
+
+	err = s.UpdateProgram(t.Context(), []string{"chrme"}, "browser", "")
+	assert.Nil(t, err, "A close misspelling should be used")
+	program, err := s.PrRepo.GetProgramByName(t.Context(), "chrome")
+	assert.Nil(t, err, "GetProgramByName should not return error")
+	assert.Equal(t, "browser", program.Category.String, "The misspelled program should be updated")
+
+	err = s.GetSessionHistory(t.Context(), []string{"chr"}, "", "", "", "", 0, false, false)
+	assert.ErrorContains(t, err, "chr is not tracked, did you mean chrome or chromium?", "Several matches should be named without a terminal")
+
+	s.Input = strings.NewReader("2\n")
+	err = s.UpdateProgram(t.Context(), []string{"chr"}, "browser", "")
+	assert.Nil(t, err, "The program picked should be used")
+	program, err = s.PrRepo.GetProgramByName(t.Context(), "chromium")
+	assert.Nil(t, err, "GetProgramByName should not return error")
+	assert.Equal(t, "browser", program.Category.String, "The picked program should be updated")
+
+	s.Input = strings.NewReader("7\n")
+	err = s.GetInfo(t.Context(), []string{"chr"})
+	assert.ErrorContains(t, err, "no program picked", "An answer outside the list should fail")
+
+	err = s.GetInfo(t.Context(), []string{"steam"})
+	assert.ErrorContains(t, err, "steam is not tracked", "A name matching nothing should fail")
+}
+
+func TestShowTop(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "code", "steam")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+	// Running sessions are read from the service, which reports none, so the row in the database isn't counted
+	err = s.AsRepo.CreateActiveSession(t.Context(), database.CreateActiveSessionParams{ProgramName: "steam", StartTime: time.Now().Add(-time.Minute)})
+	assert.Nil(t, err, "CreateActiveSession should not return error")
+	err = s.HsRepo.AddToSessionHistory(t.Context(), database.AddToSessionHistoryParams{
+		ProgramName:     "code",
+		StartTime:       time.Now(),
+		EndTime:         time.Now().Add(30 * time.Minute),
+		DurationSeconds: 1800,
+	})
+	assert.Nil(t, err, "AddToSessionHistory should not return error")
+
+	out := captureStdout(t, func() {
+		err = s.ShowTop(t.Context(), false, 0, 0)
+		assert.Nil(t, err, "ShowTop should not return error")
+	})
+	assert.Contains(t, out, "2h 30m today, 0 programs running")
+	rows := tableRows(out)
+	assert.Equal(t, "code 1h 30m 60.0%", rows["1"], "The program with the most time should rank first")
+	assert.Equal(t, "steam 1h 0m 40.0%", rows["2"])
+
+	out = captureStdout(t, func() {
+		err = s.ShowTop(t.Context(), false, 0, 1)
+		assert.Nil(t, err, "ShowTop should not return error")
+	})
+	rows = tableRows(out)
+	assert.Equal(t, "code 1h 30m 60.0%", rows["1"])
+	assert.NotContains(t, rows, "2", "Programs past the limit should be left out")
+	assert.Contains(t, out, "... and 1 program more")
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	captureStdout(t, func() {
+		err = s.ShowTop(ctx, true, 10*time.Millisecond, 0)
+		assert.Nil(t, err, "ShowTop should stop without error when its context ends")
+	})
+}
+
+func TestRemoteRefusesLocalCommands(t *testing.T) {
+	s, err := setupTestServiceWithPrograms(t, "notepad.exe")
+	if err != nil {
+		t.Fatalf("Failed to setup test service: %v", err)
+	}
+
+	for _, args := range [][]string{{"rm", "notepad.exe"}, {"add", "code"}, {"config", "validate"}, {"undo"}, {"group", "create", "work", "code"}} {
+		root := s.RootCmd()
+		root.SetArgs(append([]string{"--host", "example.com", "--token", "secret"}, args...))
+		root.SetOut(io.Discard)
+		root.SetErr(io.Discard)
+		err := root.Execute()
+		if assert.NotNil(t, err, "%v should be refused with --host", args) {
+			assert.Contains(t, err.Error(), "can't be used with --host")
+		}
+	}
+
+	programs, err := s.PrRepo.GetAllProgramNames(context.Background())
+	assert.Nil(t, err)
+	assert.Contains(t, programs, "notepad.exe", "The local database should be left alone")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/streaks"
+)
+
+// Prints the time recorded for projects side by side from since up to until: their totals, their share of the time
+// compared and of all time recorded, and a week by week trend. A session counts towards its program's project
+func (s *CLIService) CompareProjects(ctx context.Context, projects []string, since, until string, includeArchive bool) error {
+	var names []string
+	for _, project := range projects {
+		if project = strings.TrimSpace(project); project != "" && !slices.Contains(names, project) {
+			names = append(names, project)
+		}
+	}
+	if len(names) == 0 {
+		return errors.New("no projects given, list them with --projects")
+	}
+	if since == "" {
+		return errors.New("no start of the window given, set --since")
+	}
+
+	rangeStart, rangeEnd, err := s.historyRange("", since, until)
+	if err != nil {
+		return err
+	}
+	if !rangeEnd.After(rangeStart) {
+		return errors.New("--until is before --since")
+	}
+
+	programs, err := s.PrRepo.GetAllPrograms(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting programs: %w", err)
+	}
+	projectOf := make(map[string]string, len(programs))
+	for _, program := range programs {
+		projectOf[program.Name] = program.Project.String
+	}
+
+	// Weeks overlapping the window, each clipped to it
+	var weeks []dates.Span
+	for start := rangeStart.In(s.location()); start.Before(rangeEnd); {
+		week, err := dates.Parse("this week", start, s.location(), s.dateOptions())
+		if err != nil {
+			return err
+		}
+		span := dates.Span{Start: start, End: week.End}
+		if span.End.After(rangeEnd) {
+			span.End = rangeEnd
+		}
+		weeks = append(weeks, span)
+		start = week.End
+	}
+
+	totals := make(map[string]time.Duration, len(names))
+	weekly := make(map[string][]time.Duration, len(names))
+	for _, name := range names {
+		weekly[name] = make([]time.Duration, len(weeks))
+	}
+	var all time.Duration
+
+	err = s.streamSessionHistory(ctx, "", "", since, until, s.Device, includeArchive, func(session database.SessionHistory) {
+		spent := streaks.Within(session, rangeStart, rangeEnd)
+		if spent <= 0 {
+			return
+		}
+		all += spent
+
+		project := projectOf[session.ProgramName]
+		if _, ok := weekly[project]; !ok {
+			return
+		}
+		totals[project] += spent
+		for i, week := range weeks {
+			weekly[project][i] += streaks.Within(session, week.Start, week.End)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	var compared time.Duration
+	for _, spent := range totals {
+		compared += spent
+	}
+
+	fmt.Printf("Projects from %s to %s\n\n", s.formatDate(rangeStart.In(s.location())), s.formatDate(rangeEnd.Add(-time.Nanosecond).In(s.location())))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROJECT\tTOTAL\tOF COMPARED\tOF ALL TIME")
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, formatSpent(totals[name]), percent(totals[name], compared), percent(totals[name], all))
+	}
+	fmt.Fprintf(w, "Compared\t%s\t\t%s\n", formatSpent(compared), percent(compared, all))
+	fmt.Fprintf(w, "All time recorded\t%s\t\t\n", formatSpent(all))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "WEEK\t%s\n", strings.Join(names, "\t"))
+	for i, week := range weeks {
+		row := []string{s.formatDate(week.Start)}
+		for _, name := range names {
+			row = append(row, formatSpent(weekly[name][i]))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// Formats part as a percentage of whole, "-" when whole is nothing
+func percent(part, whole time.Duration) string {
+	if whole <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(part)/float64(whole)*100)
+}
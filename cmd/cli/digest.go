@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/digest"
+)
+
+// Prints the digest of the week containing date, or sends it to the destinations in config with send. Sending on
+// demand leaves the schedule alone: the service still sends each week's digest when it's due
+func (s *CLIService) ShowDigest(ctx context.Context, date string, send bool) error {
+	span, err := dates.Parse(date, time.Now(), s.location(), s.dateOptions())
+	if err != nil {
+		return err
+	}
+	week, err := dates.Parse("this week", span.Start, s.location(), s.dateOptions())
+	if err != nil {
+		return err
+	}
+
+	d, err := digest.Build(ctx, s.PrRepo, s.GroupRepo, s.HsRepo, s.Config, week)
+	if err != nil {
+		return err
+	}
+
+	if !send {
+		fmt.Println(d.Title(s.Config.DateLayout()))
+		fmt.Println()
+		fmt.Print(d.Text())
+		return nil
+	}
+
+	if s.Config == nil || (!s.Config.Digest.Desktop && s.Config.Digest.Email.SMTP == "") {
+		return errors.New("no digest destination configured, set digest.desktop or digest.email.smtp")
+	}
+	if err := digest.Send(ctx, s.Config, d); err != nil {
+		return err
+	}
+	fmt.Println("Sent weekly digest")
+	return nil
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	mysql "github.com/jms-guy/timekeep/sql"
+)
+
+// Deadline for each network or subprocess check run by doctor
+const doctorCheckTimeout = 5 * time.Second
+
+// Outcome of a single doctor check
+type checkResult struct {
+	Name   string
+	Status string // pass, warn or fail
+	Detail string
+	Fix    string // Suggested remedy, shown for warn and fail
+}
+
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// Runs every diagnostic check, printing each result with a suggested fix for failures. With reconcile, the service
+// first aligns the database's active sessions with its own. Returns an error if any check failed, so scripts can test
+// the exit status
+func (s *CLIService) Doctor(ctx context.Context, reconcile bool) error {
+	var results []checkResult
+	results = append(results, s.checkService())
+	results = append(results, s.checkIPC())
+	results = append(results, s.checkDatabase()...)
+	if reconcile {
+		results = append(results, s.reconcileSessions())
+	} else {
+		results = append(results, s.checkSessions(ctx))
+	}
+	results = append(results, s.checkConfig())
+	results = append(results, s.checkIntegrations(ctx)...)
+	results = append(results, checkPermissions()...)
+
+	failed := 0
+	for _, result := range results {
+		fmt.Printf("[%s] %s: %s\n", strings.ToUpper(result.Status), result.Name, result.Detail)
+		if result.Status != checkPass && result.Fix != "" {
+			fmt.Printf("       Fix: %s\n", result.Fix)
+		}
+		if result.Status == checkFail {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+func (s *CLIService) checkService() checkResult {
+	status, err := s.GetServiceStatusString()
+	if err != nil {
+		return checkResult{"Service", checkFail, err.Error(), "Install it with 'timekeep service install', or start it with 'timekeep service start'"}
+	}
+	return checkResult{"Service", checkPass, status, ""}
+}
+
+func (s *CLIService) checkIPC() checkResult {
+	resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionHealth))
+	if err == nil {
+		err = resp.Err()
+	}
+	if err != nil {
+		return checkResult{"IPC", checkFail, fmt.Sprintf("service unreachable: %v", err), "Make sure the service is running as your user and the same --profile, then check its log"}
+	}
+
+	var health ipc.Health
+	if err := resp.Decode(&health); err != nil {
+		return checkResult{"IPC", checkFail, fmt.Sprintf("unreadable response: %v", err), "Update the CLI and service to the same version"}
+	}
+	if health.Database != "ok" {
+		return checkResult{"IPC", checkWarn, fmt.Sprintf("service %s answered, but its database reports: %s", health.Version, health.Database), "Restart the service with 'timekeep service stop' and 'timekeep service start'"}
+	}
+	if health.Version != s.Version {
+		return checkResult{"IPC", checkWarn, fmt.Sprintf("service is version %s, CLI is %s", health.Version, s.Version), "Install matching CLI and service versions"}
+	}
+
+	return checkResult{"IPC", checkPass, fmt.Sprintf("service %s answered, monitor %s", health.Version, health.Monitor), ""}
+}
+
+func (s *CLIService) checkDatabase() []checkResult {
+	if s.DB == nil {
+		return []checkResult{{"Database", checkWarn, "no local database open", "Run doctor without --host on the machine running the service"}}
+	}
+
+	health, err := mysql.CheckLocalDatabase(s.DB)
+	if err != nil {
+		return []checkResult{{"Database", checkFail, err.Error(), "Check the database file's permissions and that the disk isn't full"}}
+	}
+
+	results := make([]checkResult, 0, 2)
+	if health.Problem != "" {
+		results = append(results, checkResult{"Database", checkFail, fmt.Sprintf("%s is corrupt: %s", health.Path, health.Problem), "Restart the service, which salvages readable data into a new database on start"})
+	} else {
+		results = append(results, checkResult{"Database", checkPass, health.Path + " passed its integrity check", ""})
+	}
+
+	switch {
+	case health.Version > health.LatestVersion:
+		results = append(results, checkResult{"Schema", checkWarn, fmt.Sprintf("version %d is newer than this CLI supports (%d)", health.Version, health.LatestVersion), "Update the CLI to the service's version"})
+	case health.Version < health.LatestVersion:
+		results = append(results, checkResult{"Schema", checkFail, fmt.Sprintf("version %d, %d is current", health.Version, health.LatestVersion), "Restart the service to apply migrations"})
+	default:
+		results = append(results, checkResult{"Schema", checkPass, fmt.Sprintf("version %d is current", health.Version), ""})
+	}
+
+	return results
+}
+
+// Compares the active_sessions rows against the service's in-memory sessions, which drift apart after a crash
+func (s *CLIService) checkSessions(ctx context.Context) checkResult {
+	rows, err := s.AsRepo.GetAllActiveSessions(ctx)
+	if err != nil {
+		return checkResult{"Sessions", checkFail, fmt.Sprintf("error reading active sessions: %v", err), "Check the database with the checks above"}
+	}
+
+	resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionQueryActive))
+	if err == nil {
+		err = resp.Err()
+	}
+	var live []ipc.ActiveSession
+	if err == nil {
+		err = resp.Decode(&live)
+	}
+	if err != nil {
+		return checkResult{"Sessions", checkWarn, fmt.Sprintf("can't compare with the service: %v", err), "Start the service, then run doctor again"}
+	}
+
+	running := make(map[string]bool, len(live))
+	for _, session := range live {
+		running[session.Name] = true
+	}
+	stored := make(map[string]bool, len(rows))
+	var ghosts, missing []string
+	for _, row := range rows {
+		stored[row.ProgramName] = true
+		if !running[row.ProgramName] {
+			ghosts = append(ghosts, row.ProgramName)
+		}
+	}
+	for _, session := range live {
+		if !stored[session.Name] {
+			missing = append(missing, session.Name)
+		}
+	}
+
+	if len(ghosts) == 0 && len(missing) == 0 {
+		return checkResult{"Sessions", checkPass, fmt.Sprintf("%d active session(s) match the service", len(rows)), ""}
+	}
+	var detail []string
+	if len(ghosts) > 0 {
+		detail = append(detail, "stored but not running: "+strings.Join(ghosts, ", "))
+	}
+	if len(missing) > 0 {
+		detail = append(detail, "running but not stored: "+strings.Join(missing, ", "))
+	}
+	return checkResult{"Sessions", checkWarn, strings.Join(detail, "; "), "Run 'timekeep doctor --reconcile'"}
+}
+
+// Asks the service to align the active_sessions rows with its in-memory sessions, reporting what it changed
+func (s *CLIService) reconcileSessions() checkResult {
+	resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionReconcile))
+	if err == nil {
+		err = resp.Err()
+	}
+	var result ipc.Reconciliation
+	if err == nil {
+		err = resp.Decode(&result)
+	}
+	if err != nil {
+		return checkResult{"Sessions", checkFail, fmt.Sprintf("reconcile failed: %v", err), "Make sure the service is running and the same version as the CLI"}
+	}
+
+	if len(result.Removed) == 0 && len(result.Recreated) == 0 {
+		return checkResult{"Sessions", checkPass, "active sessions already matched the service", ""}
+	}
+	var detail []string
+	if len(result.Removed) > 0 {
+		detail = append(detail, "removed "+strings.Join(result.Removed, ", "))
+	}
+	if len(result.Recreated) > 0 {
+		detail = append(detail, "recreated "+strings.Join(result.Recreated, ", "))
+	}
+	return checkResult{"Sessions", checkPass, "reconciled: " + strings.Join(detail, "; "), ""}
+}
+
+func (s *CLIService) checkConfig() checkResult {
+	problems := s.Config.Validate()
+	if len(problems) > 0 {
+		return checkResult{"Config", checkFail, fmt.Sprintf("%d problem(s), first: %s", len(problems), problems[0]), "Run 'timekeep config validate' and fix each problem listed"}
+	}
+	return checkResult{"Config", checkPass, "valid", ""}
+}
+
+func (s *CLIService) checkIntegrations(ctx context.Context) []checkResult {
+	if s.Config == nil {
+		return nil
+	}
+
+	var results []checkResult
+	if s.Config.WakaTime.Enabled {
+		results = append(results, s.checkWakaTimeCLI(ctx))
+	}
+	if s.Config.Wakapi.Enabled {
+		results = append(results, checkWakapiServer(ctx, s.Config.Wakapi.Server))
+	}
+	return results
+}
+
+// Runs wakatime-cli --version to confirm the configured binary exists and executes
+func (s *CLIService) checkWakaTimeCLI(ctx context.Context) checkResult {
+	path := s.Config.WakaTime.CLIPath
+	if path == "" {
+		return checkResult{"WakaTime", checkFail, "cli_path is not set", "Set it with 'timekeep config --cli_path PATH'"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, doctorCheckTimeout)
+	defer cancel()
+	out, err := s.CmdExe.RunCommand(ctx, path, "--version")
+	if err != nil {
+		return checkResult{"WakaTime", checkFail, fmt.Sprintf("%s failed to run: %v", path, err), "Install wakatime-cli and set its full path with 'timekeep config --cli_path PATH'"}
+	}
+	return checkResult{"WakaTime", checkPass, fmt.Sprintf("%s %s", path, strings.TrimSpace(out)), ""}
+}
+
+// Confirms the Wakapi server answers HTTP requests. Any response counts, as only reachability is checked
+func checkWakapiServer(ctx context.Context, server string) checkResult {
+	if err := config.ValidateServerURL(server); err != nil {
+		return checkResult{"Wakapi", checkFail, err.Error(), "Set the server with 'timekeep config --server URL'"}
+	}
+	if !strings.Contains(server, "://") {
+		server = "http://" + server
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, doctorCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server, nil)
+	if err != nil {
+		return checkResult{"Wakapi", checkFail, err.Error(), "Set the server with 'timekeep config --server URL'"}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return checkResult{"Wakapi", checkFail, fmt.Sprintf("%s unreachable: %v", server, err), "Check the server address and that it's reachable from this machine"}
+	}
+	resp.Body.Close()
+
+	return checkResult{"Wakapi", checkPass, fmt.Sprintf("%s answered (%s)", server, resp.Status), ""}
+}
+
+// Checks that the database and config files, and their directories, can be read and written by the current user
+func checkPermissions() []checkResult {
+	var results []checkResult
+
+	if dbPath, err := mysql.DatabasePath(); err == nil {
+		results = append(results, checkWritable("Database permissions", dbPath))
+	}
+	if configPath, err := config.Path(); err == nil {
+		results = append(results, checkWritable("Config permissions", configPath))
+	}
+
+	return results
+}
+
+func checkWritable(name, path string) checkResult {
+	fix := fmt.Sprintf("Take ownership with 'sudo chown -R $USER %s'", filepath.Dir(path))
+	if runtime.GOOS == "windows" {
+		fix = "Run the terminal as the user that installed the service, or grant it write access to " + filepath.Dir(path)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return checkResult{name, checkFail, fmt.Sprintf("%s can't be opened for writing: %v", path, err), fix}
+	}
+	file.Close()
+
+	probe, err := os.CreateTemp(filepath.Dir(path), ".doctor-*")
+	if err != nil {
+		return checkResult{name, checkFail, fmt.Sprintf("%s isn't writable: %v", filepath.Dir(path), err), fix}
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	return checkResult{name, checkPass, path + " is readable and writable", ""}
+}
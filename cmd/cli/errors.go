@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/spf13/cobra"
+	"modernc.org/sqlite"
+)
+
+// Exit codes, so scripts can tell failures apart without parsing messages
+const (
+	exitFailure        = 1 // Any failure not listed below
+	exitInvalidArgs    = 2 // Unknown flags, wrong argument counts or unreadable values such as dates
+	exitNotFound       = 3 // The named program isn't tracked
+	exitUnreachable    = 4 // The service couldn't be connected to
+	exitDatabaseLocked = 5 // The database was locked by another process, or an operation timed out
+)
+
+// SQLite primary result codes for a locked database
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// Returned when a program named on the command line isn't tracked and matches no tracked program
+var errNotTracked = errors.New("not tracked")
+
+// Flag printing errors as JSON objects
+const jsonErrorsFlag = "json-errors"
+
+// Error codes printed by --json-errors, by exit code
+var errorCodes = map[int]string{
+	exitFailure:        "error",
+	exitInvalidArgs:    "invalid_args",
+	exitNotFound:       "not_found",
+	exitUnreachable:    "service_unreachable",
+	exitDatabaseLocked: "database_locked",
+}
+
+// Error printed by --json-errors
+type jsonError struct {
+	Code     string `json:"code"`
+	ExitCode int    `json:"exit_code"`
+	Message  string `json:"message"`
+}
+
+// Marks err as caused by the command line rather than the command failing
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+
+// Returns the exit code for err
+func exitCode(err error) int {
+	var usage usageError
+	var sqliteErr *sqlite.Error
+	switch {
+	case errors.As(err, &usage), errors.Is(err, dates.ErrInvalidDate):
+		return exitInvalidArgs
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, errNotTracked):
+		return exitNotFound
+	case errors.Is(err, ipc.ErrUnreachable):
+		return exitUnreachable
+	case errors.Is(err, repository.ErrTimeout):
+		return exitDatabaseLocked
+	case errors.As(err, &sqliteErr) && (sqliteErr.Code()&0xff == sqliteBusy || sqliteErr.Code()&0xff == sqliteLocked):
+		return exitDatabaseLocked
+	default:
+		return exitFailure
+	}
+}
+
+// Prints err, as a JSON object on stderr with --json-errors, and exits with its exit code
+func exitWithError(prefix string, err error, jsonErrors bool) {
+	code := exitCode(err)
+	if !jsonErrors {
+		fmt.Printf("%s: %v\n", prefix, err)
+		os.Exit(code)
+	}
+
+	encoder := json.NewEncoder(os.Stderr)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(jsonError{Code: errorCodes[code], ExitCode: code, Message: err.Error()})
+	os.Exit(code)
+}
+
+// Reports whether --json-errors is among args, checked before cobra parses them so setup failures are covered too
+func jsonErrorsRequested(args []string) bool {
+	for _, arg := range args {
+		if arg == "--"+jsonErrorsFlag || arg == "--"+jsonErrorsFlag+"=true" {
+			return true
+		}
+		if arg == "--" {
+			break
+		}
+	}
+	return false
+}
+
+// Marks argument and flag errors of cmd and its subcommands as usage errors, for their exit code
+func markUsageErrors(cmd *cobra.Command) {
+	cmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return usageError{err: err}
+	})
+	if validate := cmd.Args; validate != nil {
+		cmd.Args = func(cmd *cobra.Command, args []string) error {
+			if err := validate(cmd, args); err != nil {
+				return usageError{err: err}
+			}
+			return nil
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		markUsageErrors(sub)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jms-guy/timekeep/internal/database"
+)
+
+// Formats of 'timekeep export timew'
+const (
+	TimewJSON  = "json"  // Array of intervals, as 'timew export' writes them
+	TimewData  = "data"  // Lines of Timewarrior's data files
+	TimewTrack = "track" // Shell script recording each interval with 'timew track'
+)
+
+// Layout of times in Timewarrior intervals, always UTC
+const timewLayout = "20060102T150405Z"
+
+// Interval as Timewarrior exports and imports it
+type timewInterval struct {
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// Writes sessions as Timewarrior intervals, tagged with their program, category and project, to output or stdout.
+// The sessions are picked like those of 'timekeep history', oldest first. Timewarrior has no import command, so the
+// track format writes a script recording each interval through 'timew track'
+func (s *CLIService) ExportTimewarrior(ctx context.Context, args []string, date, start, end string, includeArchive bool, format, output string) error {
+	if format != TimewJSON && format != TimewData && format != TimewTrack {
+		return fmt.Errorf("unknown format %q, use json, data or track", format)
+	}
+	program := ""
+	if len(args) > 0 {
+		program = args[0]
+	}
+
+	programs, err := s.PrRepo.GetAllPrograms(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting programs: %w", err)
+	}
+	byName := make(map[string]database.TrackedProgram, len(programs))
+	for _, p := range programs {
+		byName[p.Name] = p
+	}
+
+	out := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("error creating %s: %w", output, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+
+	count := 0
+	var writeErr error
+	switch format {
+	case TimewJSON:
+		w.WriteString("[")
+	case TimewTrack:
+		w.WriteString("#!/bin/sh\n# Records sessions exported by timekeep in Timewarrior\nset -e\n")
+	}
+	err = s.streamSessionHistory(ctx, program, date, start, end, s.Device, includeArchive, func(session database.SessionHistory) {
+		if writeErr != nil {
+			return
+		}
+		interval := timewInterval{
+			Start: session.StartTime.UTC().Format(timewLayout),
+			End:   session.EndTime.UTC().Format(timewLayout),
+			Tags:  timewTags(session.ProgramName, byName[session.ProgramName]),
+		}
+
+		switch format {
+		case TimewData:
+			_, writeErr = fmt.Fprintln(w, timewLine(interval))
+		case TimewTrack:
+			_, writeErr = fmt.Fprintln(w, timewTrack(interval))
+		default:
+			var encoded []byte
+			if encoded, writeErr = json.Marshal(interval); writeErr == nil {
+				if count > 0 {
+					w.WriteString(",")
+				}
+				w.WriteString("\n")
+				_, writeErr = w.Write(encoded)
+			}
+		}
+		count++
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return fmt.Errorf("error writing intervals: %w", writeErr)
+	}
+	if format == TimewJSON {
+		if count > 0 {
+			w.WriteString("\n")
+		}
+		w.WriteString("]\n")
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error writing intervals: %w", err)
+	}
+
+	if output != "" {
+		fmt.Printf("Exported %s to %s\n", plural(int64(count), "interval"), output)
+	}
+	return nil
+}
+
+// Tags of a session: its program, and the program's category and project when set
+func timewTags(name string, program database.TrackedProgram) []string {
+	tags := []string{name}
+	for _, tag := range []string{program.Category.String, program.Project.String} {
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Formats an interval as a line of a Timewarrior data file, such as: inc 20240101T090000Z - 20240101T100000Z # code
+func timewLine(interval timewInterval) string {
+	line := "inc " + interval.Start + " - " + interval.End
+	if len(interval.Tags) == 0 {
+		return line
+	}
+	tags := make([]string, len(interval.Tags))
+	for i, tag := range interval.Tags {
+		tags[i] = timewQuote(tag)
+	}
+	return line + " # " + strings.Join(tags, " ")
+}
+
+// Quotes a tag holding spaces, quotes or a '#', as Timewarrior does
+func timewQuote(tag string) string {
+	if !strings.ContainsAny(tag, " \t\"#") {
+		return tag
+	}
+	return `"` + strings.ReplaceAll(tag, `"`, `\"`) + `"`
+}
+
+// Formats an interval as a 'timew track' command, with tags quoted for the shell
+func timewTrack(interval timewInterval) string {
+	line := "timew track " + interval.Start + " - " + interval.End
+	for _, tag := range interval.Tags {
+		line += " '" + strings.ReplaceAll(tag, "'", `'\''`) + "'"
+	}
+	return line
+}
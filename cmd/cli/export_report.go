@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/charts"
+	"github.com/jms-guy/timekeep/internal/streaks"
+)
+
+// Formats of 'timekeep export report'
+const (
+	ReportHTML     = "html"     // Page with its charts inline as SVG
+	ReportMarkdown = "markdown" // Document with its charts inline as base64 PNG images
+)
+
+// Programs listed in a report, and categories given a trend line
+const (
+	reportPrograms = 10
+	reportTrends   = 5
+)
+
+type reportRow struct {
+	Name  string
+	Time  string
+	Share string
+}
+
+type reportChart struct {
+	Title string
+	SVG   template.HTML // Chart of an HTML report
+	PNG   string        // Base64 chart of a Markdown report
+}
+
+type reportData struct {
+	Title      string
+	Summary    string
+	Charts     []reportChart
+	Categories []reportRow
+	Programs   []reportRow
+}
+
+var reportPage = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; color: #333; max-width: 960px; margin: 2em auto; padding: 0 1em; }
+figure { margin: 1.5em 0; }
+figure svg { width: 100%; height: auto; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { padding: 4px 12px; border-bottom: 1px solid #e5e5e5; text-align: left; }
+td + td { text-align: right; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Summary}}</p>
+{{range .Charts}}<figure>{{.SVG}}</figure>
+{{end}}{{if .Categories}}<h2>Categories</h2>
+<table>
+<tr><th>Category</th><th>Time</th><th>Share</th></tr>
+{{range .Categories}}<tr><td>{{.Name}}</td><td>{{.Time}}</td><td>{{.Share}}</td></tr>
+{{end}}</table>
+<h2>Programs</h2>
+<table>
+<tr><th>Program</th><th>Time</th><th>Share</th></tr>
+{{range .Programs}}<tr><td>{{.Name}}</td><td>{{.Time}}</td><td>{{.Share}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// Writes a report of the time recorded over the range picked like that of 'timekeep history', this week by default,
+// as a single HTML or Markdown file with its charts inline: time per day, the share of each category and the daily
+// trend of the largest categories, followed by tables of categories and programs. The format follows output's
+// extension when unset, and the report goes to stdout when output is empty
+func (s *CLIService) ExportReport(ctx context.Context, date, start, end, format, output string) error {
+	if format == "" {
+		format = ReportHTML
+		if ext := strings.ToLower(filepath.Ext(output)); ext == ".md" || ext == ".markdown" {
+			format = ReportMarkdown
+		}
+	}
+	if format != ReportHTML && format != ReportMarkdown {
+		return fmt.Errorf("unknown format %q, use html or markdown", format)
+	}
+	if date == "" && start == "" {
+		date = "this week"
+	}
+
+	rangeStart, rangeEnd, err := s.historyRange(date, start, end)
+	if err != nil {
+		return err
+	}
+	loc := s.location()
+	from := rangeStart.In(loc)
+	last := rangeEnd.Add(-time.Nanosecond).In(loc)
+	to := time.Date(last.Year(), last.Month(), last.Day()+1, 0, 0, 0, 0, loc)
+
+	days, err := streaks.DayTotals(ctx, s.PrRepo, s.GroupRepo, s.HsRepo, from, to, s.Device)
+	if err != nil {
+		return err
+	}
+
+	categories := map[string]time.Duration{}
+	programs := map[string]time.Duration{}
+	daily := make([]time.Duration, len(days))
+	var total time.Duration
+	var tracked int
+	for i, day := range days {
+		for category, spent := range day.Categories {
+			categories[category] += spent
+		}
+		for program, spent := range day.Programs {
+			programs[program] += spent
+			daily[i] += spent
+		}
+		total += daily[i]
+		if daily[i] > 0 {
+			tracked++
+		}
+	}
+
+	data := reportData{
+		Title:   fmt.Sprintf("Timekeep report, %s to %s", s.formatDate(from), s.formatDate(last)),
+		Summary: "Total: 0m",
+	}
+	if total > 0 {
+		data.Summary = fmt.Sprintf("Total: %s over %s, %s a day on average", formatSpent(total), plural(int64(tracked), "day"), formatSpent(total/time.Duration(tracked)))
+
+		names := byTime(categories)
+		for _, name := range names {
+			data.Categories = append(data.Categories, reportRow{Name: name, Time: formatSpent(categories[name]), Share: percent(categories[name], total)})
+		}
+		for i, name := range byTime(programs) {
+			if i == reportPrograms {
+				break
+			}
+			data.Programs = append(data.Programs, reportRow{Name: name, Time: formatSpent(programs[name]), Share: percent(programs[name], total)})
+		}
+
+		data.Charts, err = reportCharts(format, from, days, daily, names)
+		if err != nil {
+			return err
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("error creating %s: %w", output, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if format == ReportHTML {
+		err = reportPage.Execute(out, data)
+	} else {
+		_, err = io.WriteString(out, reportMarkdown(data))
+	}
+	if err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+
+	if output != "" {
+		fmt.Printf("Wrote the report to %s\n", output)
+	}
+	return nil
+}
+
+// Renders the charts of a report over days starting at from: time per day, the share of each category, and the daily
+// trend of the largest categories when there's more than a day. Categories are given largest first
+func reportCharts(format string, from time.Time, days []streaks.Totals, daily []time.Duration, categories []string) ([]reportChart, error) {
+	imageFormat := charts.FormatSVG
+	if format == ReportMarkdown {
+		imageFormat = charts.FormatPNG
+	}
+
+	var result []reportChart
+	render := func(title string, draw func(w io.Writer) error) error {
+		var buf bytes.Buffer
+		if err := draw(&buf); err != nil {
+			return err
+		}
+		if imageFormat == charts.FormatSVG {
+			result = append(result, reportChart{Title: title, SVG: template.HTML(buf.String())})
+		} else {
+			result = append(result, reportChart{Title: title, PNG: base64.StdEncoding.EncodeToString(buf.Bytes())})
+		}
+		return nil
+	}
+
+	dayLayout := "Mon 2"
+	if len(days) > 14 {
+		dayLayout = "2"
+	}
+	dayStarts := make([]time.Time, len(days))
+	bars := make([]charts.Value, len(days))
+	for i := range days {
+		dayStarts[i] = from.AddDate(0, 0, i)
+		bars[i] = charts.Value{Label: dayStarts[i].Format(dayLayout), Hours: daily[i].Hours()}
+	}
+	err := render("Time per day", func(w io.Writer) error {
+		return charts.Bars(w, imageFormat, "Time per day", bars)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var shares []charts.Value
+	for _, category := range categories {
+		var spent time.Duration
+		for _, day := range days {
+			spent += day.Categories[category]
+		}
+		shares = append(shares, charts.Value{Label: category, Hours: spent.Hours()})
+	}
+	err = render("Time per category", func(w io.Writer) error {
+		return charts.Pie(w, imageFormat, "Time per category", shares)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(days) < 2 {
+		return result, nil
+	}
+	var series []charts.Series
+	for i, category := range categories {
+		if i == reportTrends {
+			break
+		}
+		hours := make([]float64, len(days))
+		for j, day := range days {
+			hours[j] = day.Categories[category].Hours()
+		}
+		series = append(series, charts.Series{Name: category, Hours: hours})
+	}
+	err = render("Daily trend", func(w io.Writer) error {
+		return charts.Trend(w, imageFormat, "Daily trend", dayStarts, series)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Writes a report as Markdown, its charts as images inline as base64 PNG
+func reportMarkdown(data reportData) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n%s\n", data.Title, data.Summary)
+	for _, chart := range data.Charts {
+		fmt.Fprintf(&b, "\n![%s](data:image/png;base64,%s)\n", chart.Title, chart.PNG)
+	}
+
+	table := func(heading, column string, rows []reportRow) {
+		fmt.Fprintf(&b, "\n## %s\n\n| %s | Time | Share |\n| --- | ---: | ---: |\n", heading, column)
+		for _, row := range rows {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", strings.ReplaceAll(row.Name, "|", `\|`), row.Time, row.Share)
+		}
+	}
+	if len(data.Categories) > 0 {
+		table("Categories", "Category", data.Categories)
+		table("Programs", "Program", data.Programs)
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/jms-guy/timekeep/internal/productivity"
+)
+
+// Productivity of a day as 'timekeep export scores' writes it, times in hours
+type dayScore struct {
+	Date              string  `json:"date"`
+	TotalHours        float64 `json:"total_hours"`
+	ProductiveHours   float64 `json:"productive_hours"`
+	DistractingHours  float64 `json:"distracting_hours"`
+	ProductivePercent int     `json:"productive_percent"`
+	Pulse             int     `json:"pulse"`
+	Score             float64 `json:"score"`
+}
+
+// Writes the productivity of each day in the range picked like that of 'timekeep history', the last 30 days by
+// default, as CSV or JSON to output or stdout: hours recorded, productive and distracting, the productivity pulse and
+// the score by the weights and formula in config
+func (s *CLIService) ExportScores(ctx context.Context, date, start, end string, includeArchive bool, format, output string) error {
+	if format != FormatCSV && format != FormatJSON {
+		return fmt.Errorf("unknown format %q, use csv or json", format)
+	}
+	if date == "" && start == "" {
+		date = "30d"
+	}
+	f, err := productivity.Formula(s.Config)
+	if err != nil {
+		return err
+	}
+	days, summaries, err := s.productivityDays(ctx, date, start, end, "", "", "", "", "", includeArchive)
+	if err != nil {
+		return err
+	}
+
+	scores := make([]dayScore, len(days))
+	for i, day := range days {
+		sum := summaries[i]
+		scores[i] = dayScore{
+			Date:              day.Format("2006-01-02"),
+			TotalHours:        round2(sum.Total.Hours()),
+			ProductiveHours:   round2(sum.Productive.Hours()),
+			DistractingHours:  round2(sum.Distracting.Hours()),
+			ProductivePercent: sum.ProductivePercent(),
+			Pulse:             sum.Pulse(),
+			Score:             round2(sum.Score(f)),
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("error creating %s: %w", output, err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if format == FormatJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(scores); err != nil {
+			return fmt.Errorf("error writing scores: %w", err)
+		}
+	} else {
+		w := csv.NewWriter(out)
+		w.Write([]string{"date", "total_hours", "productive_hours", "distracting_hours", "productive_percent", "pulse", "score"})
+		for _, score := range scores {
+			w.Write([]string{
+				score.Date,
+				strconv.FormatFloat(score.TotalHours, 'f', -1, 64),
+				strconv.FormatFloat(score.ProductiveHours, 'f', -1, 64),
+				strconv.FormatFloat(score.DistractingHours, 'f', -1, 64),
+				strconv.Itoa(score.ProductivePercent),
+				strconv.Itoa(score.Pulse),
+				strconv.FormatFloat(score.Score, 'f', -1, 64),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return fmt.Errorf("error writing scores: %w", err)
+		}
+	}
+
+	if output != "" {
+		fmt.Printf("Wrote the scores of %s to %s\n", plural(int64(len(scores)), "day"), output)
+	}
+	return nil
+}
+
+// Rounds v to two decimals for export
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
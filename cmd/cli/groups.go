@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/progname"
+)
+
+// Checks a group name can be written as a group:<name> key in config and given to --group
+func validGroupName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t,:") {
+		return usageError{err: fmt.Errorf("invalid group name %q, use a name without spaces, commas or colons", name)}
+	}
+	return nil
+}
+
+// Creates a program group of the tracked programs given. Programs keep their own time, the group adds it up
+func (s *CLIService) CreateGroup(ctx context.Context, name string, programs []string) error {
+	if err := validGroupName(name); err != nil {
+		return err
+	}
+	members, err := s.GroupRepo.GetGroupMembers(ctx, name)
+	if err != nil {
+		return fmt.Errorf("error getting group %s: %w", name, err)
+	}
+	if len(members) > 0 {
+		return fmt.Errorf("group %s already exists, add programs to it with 'timekeep group add'", name)
+	}
+
+	if err := s.addGroupMembers(ctx, name, programs); err != nil {
+		return err
+	}
+	fmt.Printf("Created group %s of %d programs, set goals and limits on it as %s%s\n", name, len(programs), config.GroupPrefix, name)
+	return nil
+}
+
+// Adds tracked programs to an existing group
+func (s *CLIService) AddToGroup(ctx context.Context, name string, programs []string) error {
+	members, err := s.GroupRepo.GetGroupMembers(ctx, name)
+	if err != nil {
+		return fmt.Errorf("error getting group %s: %w", name, err)
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("group %s not found: %w", name, sql.ErrNoRows)
+	}
+
+	if err := s.addGroupMembers(ctx, name, programs); err != nil {
+		return err
+	}
+	fmt.Printf("Added %d programs to group %s\n", len(programs), name)
+	return nil
+}
+
+// Resolves programs to tracked programs and adds them to group
+func (s *CLIService) addGroupMembers(ctx context.Context, group string, programs []string) error {
+	names := make([]string, len(programs))
+	for i, program := range programs {
+		name, err := s.resolveProgram(ctx, program)
+		if err != nil {
+			return err
+		}
+		names[i] = name
+	}
+
+	for _, name := range names {
+		err := s.GroupRepo.AddGroupMember(ctx, database.AddGroupMemberParams{GroupName: group, ProgramName: name})
+		if err != nil {
+			return fmt.Errorf("error adding %s to group %s: %w", name, group, err)
+		}
+	}
+	return nil
+}
+
+// Takes programs out of a group. The group goes once its last program is taken out
+func (s *CLIService) RemoveFromGroup(ctx context.Context, name string, programs []string) error {
+	for _, program := range programs {
+		removed, err := s.GroupRepo.RemoveGroupMember(ctx, database.RemoveGroupMemberParams{GroupName: name, ProgramName: progname.Normalize(program)})
+		if err != nil {
+			return fmt.Errorf("error removing %s from group %s: %w", program, name, err)
+		}
+		if removed == 0 {
+			return fmt.Errorf("%s is not in group %s: %w", program, name, sql.ErrNoRows)
+		}
+		fmt.Printf("Removed %s from group %s\n", progname.Normalize(program), name)
+	}
+	return nil
+}
+
+// Deletes program groups. Their programs and sessions are left as they are
+func (s *CLIService) DeleteGroups(ctx context.Context, names []string) error {
+	for _, name := range names {
+		removed, err := s.GroupRepo.RemoveGroup(ctx, name)
+		if err != nil {
+			return fmt.Errorf("error deleting group %s: %w", name, err)
+		}
+		if removed == 0 {
+			return fmt.Errorf("group %s not found: %w", name, sql.ErrNoRows)
+		}
+		fmt.Printf("Deleted group %s\n", name)
+	}
+	return nil
+}
+
+// Prints program groups with their programs, and the goal and limit set on each in config
+func (s *CLIService) ListGroups(ctx context.Context) error {
+	members, err := s.GroupRepo.GetAllGroupMembers(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting groups: %w", err)
+	}
+	if len(members) == 0 {
+		fmt.Println("No program groups, create one with 'timekeep group create'")
+		return nil
+	}
+
+	var groups []string
+	programs := make(map[string][]string)
+	for _, member := range members {
+		if _, ok := programs[member.GroupName]; !ok {
+			groups = append(groups, member.GroupName)
+		}
+		programs[member.GroupName] = append(programs[member.GroupName], member.ProgramName)
+	}
+
+	for _, group := range groups {
+		line := fmt.Sprintf(" • %s: %s", group, strings.Join(programs[group], ", "))
+		if goal := s.Config.DailyGoal(config.GroupPrefix + group); goal > 0 {
+			line += fmt.Sprintf(" (goal %s)", formatSpent(goal))
+		}
+		if limit := s.Config.DailyLimit(config.GroupPrefix + group); limit > 0 {
+			line += fmt.Sprintf(" (limit %s)", formatSpent(limit))
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// Returns a check of program names against a --group, passing every program when there's none. Fails for a group
+// that doesn't exist
+func (s *CLIService) groupMatcher(ctx context.Context, group string) (func(string) bool, error) {
+	if group == "" {
+		return func(string) bool { return true }, nil
+	}
+	members, err := s.GroupRepo.GetGroupMembers(ctx, group)
+	if err != nil {
+		return nil, fmt.Errorf("error getting group %s: %w", group, err)
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("group %s not found, see 'timekeep group list': %w", group, sql.ErrNoRows)
+	}
+	inGroup := make(map[string]bool, len(members))
+	for _, member := range members {
+		inGroup[member] = true
+	}
+	return func(program string) bool { return inGroup[program] }, nil
+}
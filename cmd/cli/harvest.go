@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/harvest"
+)
+
+// Creates the Harvest time entries not yet pushed, covering sessions starting from since when it's set. With dryRun,
+// prints the entries that would be created instead, without creating or recording anything
+func (s *CLIService) PushHarvest(ctx context.Context, since string, dryRun bool) error {
+	if s.Config == nil {
+		return fmt.Errorf("no config loaded, set harvest.account_id and harvest.token")
+	}
+
+	now := time.Now()
+	var from time.Time
+	if since != "" {
+		span, err := dates.Parse(since, now, s.location(), s.dateOptions())
+		if err != nil {
+			return err
+		}
+		from = span.Start
+	}
+
+	if !dryRun {
+		created, err := harvest.Run(ctx, s.TxRepo, s.Config, now, from)
+		if err != nil {
+			if created > 0 {
+				return fmt.Errorf("created %s before failing: %w", timeEntries(created), err)
+			}
+			return err
+		}
+		if created == 0 {
+			fmt.Println("No time entries to create")
+			return nil
+		}
+		fmt.Printf("Created %s in Harvest\n", timeEntries(created))
+		return nil
+	}
+
+	entries, err := harvest.Pending(ctx, s.TxRepo, s.Config, now, from)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No time entries to create")
+		return nil
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Counts time entries, which plural can't spell
+func timeEntries(n int) string {
+	if n == 1 {
+		return "1 time entry"
+	}
+	return fmt.Sprintf("%d time entries", n)
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/progname"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Fields a CSV column can be mapped to with 'timekeep import csv --map'
+const (
+	importProgram  = "program"
+	importStart    = "start"
+	importEnd      = "end"
+	importDuration = "duration"
+)
+
+// Layouts CSV start and end times are read in when --time-format is unset, in the display timezone unless they carry
+// an offset
+var importLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+}
+
+// Session read from a row of an imported CSV file
+type importRow struct {
+	line    int
+	program string
+	start   time.Time
+	end     time.Time
+	overlap string // What the session overlaps, empty when it overlaps nothing
+}
+
+// Loads the sessions in a CSV file into session history, columns picked by mapping such as
+// "program=1,start=2,end=3", by number from 1 or, with header set, by the name in the first row. A duration column
+// may stand in for the end. Every row is checked before anything is written: invalid rows fail the import, as do
+// sessions overlapping recorded or other imported sessions of the same program unless skipOverlaps is set. With
+// dryRun set, the sessions are listed and nothing is written
+func (s *CLIService) ImportCSV(ctx context.Context, path, mapping, delimiter, timeFormat string, header, skipOverlaps, dryRun bool) error {
+	comma, size := utf8.DecodeRuneInString(delimiter)
+	if size == 0 || size != len(delimiter) {
+		return usageError{err: fmt.Errorf("invalid delimiter %q, use a single character", delimiter)}
+	}
+
+	// #nosec G304
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	var names []string
+	if header {
+		names, err = r.Read()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s is empty", path)
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", path, err)
+		}
+	}
+	columns, err := parseImportMap(mapping, names)
+	if err != nil {
+		return usageError{err: err}
+	}
+
+	programs, err := s.PrRepo.GetAllProgramNames(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting programs: %w", err)
+	}
+
+	var rows []importRow
+	var problems []string
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", path, err)
+		}
+		line, _ := r.FieldPos(0)
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		row, err := s.importRow(record, columns, timeFormat, programs)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		row.line = line
+		rows = append(rows, row)
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		return fmt.Errorf("%s has %s, nothing was imported", path, plural(int64(len(problems)), "invalid row"))
+	}
+	if len(rows) == 0 {
+		fmt.Printf("No sessions in %s\n", path)
+		return nil
+	}
+
+	overlaps, err := s.findImportOverlaps(ctx, rows)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		return s.previewImport(rows, overlaps, skipOverlaps)
+	}
+	if overlaps > 0 && !skipOverlaps {
+		for _, row := range rows {
+			if row.overlap != "" {
+				fmt.Fprintf(os.Stderr, "line %d: %s from %s overlaps %s\n", row.line, row.program, s.formatDateTime(row.start, false), row.overlap)
+			}
+		}
+		return fmt.Errorf("%s overlap sessions of the same program, nothing was imported. Pass --skip-overlaps to import the rest", plural(int64(overlaps), "row"))
+	}
+
+	host, _ := os.Hostname()
+	metadata, err := repository.SessionMetadata{Source: repository.SourceImport, Machine: host}.Encode()
+	if err != nil {
+		return err
+	}
+	device := s.Config.DeviceName()
+
+	imported := 0
+	err = s.TxRepo.WithTx(ctx, func(store repository.Store) error {
+		for _, row := range rows {
+			if row.overlap != "" {
+				continue
+			}
+			seconds := int64(row.end.Sub(row.start).Seconds())
+			err := store.AddToSessionHistory(ctx, database.AddToSessionHistoryParams{
+				ProgramName:     row.program,
+				StartTime:       row.start.UTC(),
+				EndTime:         row.end.UTC(),
+				DurationSeconds: seconds,
+				Metadata:        metadata,
+				Device:          sql.NullString{String: device, Valid: device != ""},
+			})
+			if err != nil {
+				return fmt.Errorf("error adding session of line %d: %w", row.line, err)
+			}
+			if err := store.UpdateLifetime(ctx, database.UpdateLifetimeParams{LifetimeSeconds: seconds, Name: row.program}); err != nil {
+				return fmt.Errorf("error updating lifetime of %s: %w", row.program, err)
+			}
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %s from %s", plural(int64(imported), "session"), path)
+	if overlaps > 0 {
+		fmt.Printf(", skipped %s overlapping recorded time", plural(int64(overlaps), "row"))
+	}
+	fmt.Println()
+
+	if err := s.notifyService(); err != nil {
+		return fmt.Errorf("sessions imported but failed to notify service: %w", err)
+	}
+	return nil
+}
+
+// Reads a --map value such as "program=1,start=2,end=3" into the index of each field's column. Columns may be named
+// by their header in names
+func parseImportMap(mapping string, names []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for pair := range strings.SplitSeq(mapping, ",") {
+		field, column, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		column = strings.TrimSpace(column)
+		if !ok || column == "" {
+			return nil, fmt.Errorf("invalid mapping %q, use field=column such as program=1", pair)
+		}
+		if !slices.Contains([]string{importProgram, importStart, importEnd, importDuration}, field) {
+			return nil, fmt.Errorf("unknown field %q, use program, start, end or duration", field)
+		}
+		if _, ok := columns[field]; ok {
+			return nil, fmt.Errorf("field %s is mapped twice", field)
+		}
+
+		if n, err := strconv.Atoi(column); err == nil {
+			if n < 1 {
+				return nil, fmt.Errorf("invalid column %d for %s, columns count from 1", n, field)
+			}
+			columns[field] = n - 1
+			continue
+		}
+		i := slices.IndexFunc(names, func(name string) bool { return strings.EqualFold(strings.TrimSpace(name), column) })
+		if i < 0 {
+			return nil, fmt.Errorf("no column named %q for %s", column, field)
+		}
+		columns[field] = i
+	}
+
+	if _, ok := columns[importProgram]; !ok {
+		return nil, errors.New("map the program column, such as program=1")
+	}
+	if _, ok := columns[importStart]; !ok {
+		return nil, errors.New("map the start column, such as start=2")
+	}
+	_, hasEnd := columns[importEnd]
+	_, hasDuration := columns[importDuration]
+	if hasEnd == hasDuration {
+		return nil, errors.New("map either the end or the duration column")
+	}
+	return columns, nil
+}
+
+// Reads and checks the session in a CSV record
+func (s *CLIService) importRow(record []string, columns map[string]int, timeFormat string, programs []string) (importRow, error) {
+	var row importRow
+	field := func(name string) (string, error) {
+		i := columns[name]
+		if i >= len(record) {
+			return "", fmt.Errorf("no column %d for %s", i+1, name)
+		}
+		value := strings.TrimSpace(record[i])
+		if value == "" {
+			return "", fmt.Errorf("%s is empty", name)
+		}
+		return value, nil
+	}
+
+	program, err := field(importProgram)
+	if err != nil {
+		return row, err
+	}
+	row.program = progname.Normalize(program)
+	if !slices.Contains(programs, row.program) {
+		return row, fmt.Errorf("%s is not tracked, add it with 'timekeep add %s'", row.program, row.program)
+	}
+
+	value, err := field(importStart)
+	if err != nil {
+		return row, err
+	}
+	if row.start, err = s.parseImportTime(value, timeFormat); err != nil {
+		return row, err
+	}
+
+	if _, ok := columns[importEnd]; ok {
+		value, err := field(importEnd)
+		if err != nil {
+			return row, err
+		}
+		if row.end, err = s.parseImportTime(value, timeFormat); err != nil {
+			return row, err
+		}
+	} else {
+		value, err := field(importDuration)
+		if err != nil {
+			return row, err
+		}
+		d, err := parseImportDuration(value)
+		if err != nil {
+			return row, err
+		}
+		row.end = row.start.Add(d)
+	}
+
+	if !row.end.After(row.start) {
+		return row, fmt.Errorf("session of %s ends before it starts", row.program)
+	}
+	if row.end.After(time.Now()) {
+		return row, fmt.Errorf("session of %s ends in the future", row.program)
+	}
+	return row, nil
+}
+
+// Parses a start or end time with layout, or any of importLayouts when it is empty
+func (s *CLIService) parseImportTime(value, layout string) (time.Time, error) {
+	layouts := importLayouts
+	if layout != "" {
+		layouts = []string{layout}
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, s.location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use YYYY-MM-DD HH:MM[:SS], RFC 3339 or --time-format", value)
+}
+
+// Parses a duration column, as a Go duration such as 1h30m or as H:MM[:SS]
+func parseImportDuration(value string) (time.Duration, error) {
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d, nil
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) == 2 || len(parts) == 3 {
+		var d time.Duration
+		units := []time.Duration{time.Hour, time.Minute, time.Second}
+		for i, part := range parts {
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid duration %q, use 1h30m or H:MM[:SS]", value)
+			}
+			d += time.Duration(n) * units[i]
+		}
+		if d > 0 {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid duration %q, use 1h30m or H:MM[:SS]", value)
+}
+
+// Marks the rows overlapping a recorded session of their program, or an earlier row of it, returning how many do
+func (s *CLIService) findImportOverlaps(ctx context.Context, rows []importRow) (int, error) {
+	overlaps := 0
+	for i := range rows {
+		row := &rows[i]
+		recorded, err := s.HsRepo.GetSessionHistoryByRange(ctx, database.GetSessionHistoryByRangeParams{
+			ProgramName: row.program,
+			StartTime:   row.end.UTC(),
+			EndTime:     row.start.UTC(),
+			Limit:       -1,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("error getting sessions of %s: %w", row.program, err)
+		}
+		for _, session := range recorded {
+			if session.StartTime.Before(row.end) && session.EndTime.After(row.start) {
+				row.overlap = "a recorded session from " + s.formatDateTime(session.StartTime, false)
+				break
+			}
+		}
+		if row.overlap == "" {
+			for _, other := range rows[:i] {
+				if other.overlap == "" && other.program == row.program && other.start.Before(row.end) && other.end.After(row.start) {
+					row.overlap = fmt.Sprintf("line %d", other.line)
+					break
+				}
+			}
+		}
+		if row.overlap != "" {
+			overlaps++
+		}
+	}
+	return overlaps, nil
+}
+
+// Lists the sessions an import would add, and those it would skip or fail on for overlapping
+func (s *CLIService) previewImport(rows []importRow, overlaps int, skipOverlaps bool) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LINE\tPROGRAM\tSTART\tEND\tDURATION\tSTATUS")
+	var total time.Duration
+	for _, row := range rows {
+		status := "import"
+		if row.overlap != "" {
+			status = "overlaps " + row.overlap
+		} else {
+			total += row.end.Sub(row.start)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", row.line, row.program, s.formatDateTime(row.start, false), s.formatDateTime(row.end, false), formatSpent(row.end.Sub(row.start)), status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nDry run: would import %s totaling %s", plural(int64(len(rows)-overlaps), "session"), formatSpent(total))
+	switch {
+	case overlaps > 0 && skipOverlaps:
+		fmt.Printf(", skipping %s", plural(int64(overlaps), "overlapping row"))
+	case overlaps > 0:
+		fmt.Printf(", but %s would stop the import without --skip-overlaps", plural(int64(overlaps), "overlapping row"))
+	}
+	fmt.Println()
+	return nil
+}
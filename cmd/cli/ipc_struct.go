@@ -1,20 +1,63 @@
 package main
 
+import "github.com/jms-guy/timekeep/internal/ipc"
+
 type (
-	realServiceCommander struct{}
-	testServiceCommander struct{}
+	realServiceCommander   struct{}
+	testServiceCommander   struct{}
+	remoteServiceCommander struct {
+		target ipc.RemoteTarget
+	}
 )
 
-type Command struct {
-	Action      string `json:"action"`
-	ProcessName string `json:"name,omitempty"`
-	ProcessID   int    `json:"pid,omitempty"`
-}
-
 type ServiceCommander interface {
 	WriteToService() error
+	Send(req ipc.Request) (ipc.Response, error)
+}
+
+// Tells the service to refresh its program list and config
+func (r *realServiceCommander) WriteToService() error {
+	resp, err := r.Send(ipc.NewRequest(ipc.ActionRefresh))
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
+// Sends a request over the service's pipe/socket and returns its response
+func (r *realServiceCommander) Send(req ipc.Request) (ipc.Response, error) {
+	return ipc.Call(req)
+}
+
+// Tells a remote service to refresh its program list and config
+func (r *remoteServiceCommander) WriteToService() error {
+	resp, err := r.Send(ipc.NewRequest(ipc.ActionRefresh))
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
+// Sends a request to a remote service over TLS and returns its response
+func (r *remoteServiceCommander) Send(req ipc.Request) (ipc.Response, error) {
+	return ipc.CallRemote(r.target, req)
 }
 
 func (r *testServiceCommander) WriteToService() error {
 	return nil
 }
+
+func (r *testServiceCommander) Send(req ipc.Request) (ipc.Response, error) {
+	switch req.Action {
+	case ipc.ActionQueryActive:
+		return ipc.OKResponse([]ipc.ActiveSession{}), nil
+	case ipc.ActionMetrics:
+		return ipc.OKResponse(ipc.Metrics{}), nil
+	case ipc.ActionHealth:
+		return ipc.OKResponse(ipc.Health{Version: "test", Database: "ok", Monitor: "running"}), nil
+	case ipc.ActionReconcile:
+		return ipc.OKResponse(ipc.Reconciliation{Removed: []string{}, Recreated: []string{}}), nil
+	default:
+		return ipc.OKResponse(nil), nil
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/kimai"
+)
+
+// Pushes the sessions not yet pushed to Kimai as timesheets, those starting from since when it's set. With dryRun,
+// prints the timesheets that would be pushed instead, without pushing or recording anything
+func (s *CLIService) PushKimai(ctx context.Context, since string, dryRun bool) error {
+	if s.Config == nil {
+		return fmt.Errorf("no config loaded, set kimai.server and kimai.token")
+	}
+
+	now := time.Now()
+	var from time.Time
+	if since != "" {
+		span, err := dates.Parse(since, now, s.location(), s.dateOptions())
+		if err != nil {
+			return err
+		}
+		from = span.Start
+	}
+
+	if !dryRun {
+		pushed, err := kimai.Run(ctx, s.TxRepo, s.Config, now, from)
+		if err != nil {
+			if pushed > 0 {
+				return fmt.Errorf("pushed %s before failing: %w", plural(int64(pushed), "session"), err)
+			}
+			return err
+		}
+		if pushed == 0 {
+			fmt.Println("No sessions to push")
+			return nil
+		}
+		fmt.Printf("Pushed %s to %s\n", plural(int64(pushed), "session"), s.Config.Kimai.Server)
+		return nil
+	}
+
+	entries, _, err := kimai.Pending(ctx, s.TxRepo, s.Config, now, from)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No sessions to push")
+		return nil
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry.Timesheet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
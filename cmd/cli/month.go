@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jms-guy/timekeep/internal/progname"
+	"github.com/jms-guy/timekeep/internal/streaks"
+)
+
+// Width of a day's cell in the month calendar
+const cellWidth = 9
+
+// Backgrounds of days in the month calendar, from the least time to the most
+var heatColors = []lipgloss.Color{"#0E4429", "#006D32", "#26A641", "#39D353"}
+
+// Prints a calendar of month, given as YYYY-MM and the current month when empty, with the time recorded on each day
+// shaded by how it compares to the busiest day. Counts only program, category or group when one is given
+func (s *CLIService) ShowMonth(ctx context.Context, month, program, category, group string) error {
+	loc := s.location()
+	now := time.Now().In(loc)
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	if month != "" {
+		t, err := time.ParseInLocation("2006-01", month, loc)
+		if err != nil {
+			return fmt.Errorf("invalid month %q, use YYYY-MM such as 2026-03", month)
+		}
+		first = t
+	}
+
+	days, err := streaks.DayTotals(ctx, s.PrRepo, s.GroupRepo, s.HsRepo, first, first.AddDate(0, 1, 0), s.Device)
+	if err != nil {
+		return err
+	}
+
+	program = progname.Normalize(program)
+	spent := make([]time.Duration, len(days))
+	var total, peak time.Duration
+	var tracked int
+	for i, day := range days {
+		switch {
+		case program != "":
+			spent[i] = day.Programs[program]
+		case category != "":
+			spent[i] = day.Categories[category]
+		case group != "":
+			spent[i] = day.Groups[group]
+		default:
+			for _, d := range day.Programs {
+				spent[i] += d
+			}
+		}
+		total += spent[i]
+		peak = max(peak, spent[i])
+		if spent[i] > 0 {
+			tracked++
+		}
+	}
+
+	title := first.Format("January 2006")
+	switch {
+	case program != "":
+		title += ", " + program
+	case category != "":
+		title += ", category " + category
+	case group != "":
+		title += ", group " + group
+	}
+	fmt.Println(lipgloss.NewStyle().Bold(true).Render(title))
+	fmt.Println()
+
+	weekStart := s.Config.WeekStartDay()
+	var header strings.Builder
+	for i := range 7 {
+		fmt.Fprintf(&header, "%-*s", cellWidth, ((weekStart + time.Weekday(i)) % 7).String()[:3])
+	}
+	fmt.Println(strings.TrimRight(header.String(), " "))
+
+	empty := lipgloss.NewStyle().Foreground(lipgloss.Color("#808080"))
+	offset := (int(first.Weekday()) - int(weekStart) + 7) % 7
+	for row := -offset; row < len(days); row += 7 {
+		var dayLine, timeLine strings.Builder
+		for i := row; i < row+7; i++ {
+			if i < 0 || i >= len(days) {
+				dayLine.WriteString(strings.Repeat(" ", cellWidth))
+				timeLine.WriteString(strings.Repeat(" ", cellWidth))
+				continue
+			}
+			fmt.Fprintf(&dayLine, "%-*d", cellWidth, i+1)
+			if spent[i] <= 0 {
+				timeLine.WriteString(empty.Render(fmt.Sprintf("%-*s", cellWidth-1, "·")) + " ")
+				continue
+			}
+			timeLine.WriteString(heat(spent[i], peak).Render(fmt.Sprintf("%-*s", cellWidth-1, formatSpent(spent[i]))) + " ")
+		}
+		fmt.Println(strings.TrimRight(dayLine.String(), " "))
+		fmt.Println(strings.TrimRight(timeLine.String(), " "))
+	}
+
+	fmt.Println()
+	if tracked == 0 {
+		fmt.Println("Total: 0m")
+		return nil
+	}
+	fmt.Printf("Total: %s over %s, %s a day on average\n", formatSpent(total), plural(int64(tracked), "day"), formatSpent(total/time.Duration(tracked)))
+
+	// Without colors, as when piped, the shades and so the legend show nothing
+	legend := "Less "
+	for _, color := range heatColors {
+		swatch := lipgloss.NewStyle().Background(color).Render("  ")
+		if swatch == "  " {
+			return nil
+		}
+		legend += swatch + " "
+	}
+	fmt.Println(legend + "More")
+	return nil
+}
+
+// Style of a day's time, shaded by its share of the busiest day's
+func heat(v, peak time.Duration) lipgloss.Style {
+	level := int(math.Ceil(float64(v)/float64(peak)*float64(len(heatColors)))) - 1
+	level = min(max(level, 0), len(heatColors)-1)
+	return lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF")).Background(heatColors[level])
+}
@@ -3,41 +3,123 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"os/exec"
-	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	systemdDest      = "org.freedesktop.systemd1"
+	systemdPath      = dbus.ObjectPath("/org/freedesktop/systemd1")
+	systemdManager   = "org.freedesktop.systemd1.Manager"
+	systemdUnitIface = "org.freedesktop.systemd1.Unit"
+	systemdSvcIface  = "org.freedesktop.systemd1.Service"
 )
 
 // Gets current service state for user
 func (s *CLIService) StatusService() error {
-	cmd := exec.Command("systemctl", "is-active", "timekeep.service")
-	output, err := cmd.Output()
+	status, err := queryServiceStatus()
 	if err != nil {
-		return fmt.Errorf("service not running: %v", err)
+		return err
 	}
 
-	status := strings.TrimSpace(string(output))
-	if status != "active" {
-		return fmt.Errorf("service is not active; Status: %s", status)
+	fmt.Printf("  Status: %s (%s)\n", status.State, status.SubState)
+	if status.State != "active" {
+		return fmt.Errorf("service is not active; Status: %s (%s)", status.State, status.SubState)
+	}
+	if status.PID != 0 {
+		fmt.Printf("  PID: %d\n", status.PID)
+	}
+	if !status.Since.IsZero() {
+		s.formatDuration("  Uptime: ", time.Since(status.Since))
 	}
-
-	fmt.Printf("  Status: %s\n", status)
 
 	return nil
 }
 
 // GetServiceStatusString returns the service status as a string
 func (s *CLIService) GetServiceStatusString() (string, error) {
-	cmd := exec.Command("systemctl", "is-active", "timekeep.service")
-	output, err := cmd.Output()
+	status, err := queryServiceStatus()
+	if err != nil {
+		return "", err
+	}
+	if status.State != "active" {
+		return "", fmt.Errorf("service is not active; Status: %s (%s)", status.State, status.SubState)
+	}
+
+	return fmt.Sprintf("%s (%s)", status.State, status.SubState), nil
+}
+
+// Queries systemd over D-Bus for the unit's state. The system manager is checked first, then the user's own manager,
+// since the unit can be installed in either
+func queryServiceStatus() (ServiceStatus, error) {
+	var errs []error
+	for _, connect := range []func(...dbus.ConnOption) (*dbus.Conn, error){dbus.ConnectSystemBus, dbus.ConnectSessionBus} {
+		status, err := queryUnit(connect)
+		if err == nil {
+			return status, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return ServiceStatus{}, fmt.Errorf("service not running: %w", errors.Join(errs...))
+}
+
+// Reads the unit's properties from one systemd manager. Units the manager doesn't know about are reported as errors
+func queryUnit(connect func(...dbus.ConnOption) (*dbus.Conn, error)) (ServiceStatus, error) {
+	conn, err := connect()
 	if err != nil {
-		return "", fmt.Errorf("service not running: %v", err)
+		return ServiceStatus{}, fmt.Errorf("error connecting to D-Bus: %w", err)
 	}
+	defer conn.Close()
 
-	status := strings.TrimSpace(string(output))
-	if status != "active" {
-		return "", fmt.Errorf("service is not active; Status: %s", status)
+	var unitPath dbus.ObjectPath
+	if err := conn.Object(systemdDest, systemdPath).Call(systemdManager+".LoadUnit", 0, serviceUnit()).Store(&unitPath); err != nil {
+		return ServiceStatus{}, fmt.Errorf("error loading unit %s: %w", serviceUnit(), err)
 	}
+	unit := conn.Object(systemdDest, unitPath)
 
+	var loadState string
+	if err := getProperty(unit, systemdUnitIface+".LoadState", &loadState); err != nil {
+		return ServiceStatus{}, err
+	}
+	if loadState == "not-found" {
+		return ServiceStatus{}, fmt.Errorf("unit %s not found", serviceUnit())
+	}
+
+	var status ServiceStatus
+	var pid uint32
+	var enteredUsec uint64
+	if err := getProperty(unit, systemdUnitIface+".ActiveState", &status.State); err != nil {
+		return ServiceStatus{}, err
+	}
+	if err := getProperty(unit, systemdUnitIface+".SubState", &status.SubState); err != nil {
+		return ServiceStatus{}, err
+	}
+	if err := getProperty(unit, systemdSvcIface+".MainPID", &pid); err != nil {
+		return ServiceStatus{}, err
+	}
+	if err := getProperty(unit, systemdUnitIface+".ActiveEnterTimestamp", &enteredUsec); err != nil {
+		return ServiceStatus{}, err
+	}
+
+	status.PID = int(pid)
+	if enteredUsec != 0 {
+		status.Since = time.UnixMicro(int64(enteredUsec))
+	}
 	return status, nil
 }
+
+// Reads a single D-Bus property into dest
+func getProperty(obj dbus.BusObject, name string, dest any) error {
+	value, err := obj.GetProperty(name)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", name, err)
+	}
+	if err := value.Store(dest); err != nil {
+		return fmt.Errorf("error reading %s: %w", name, err)
+	}
+	return nil
+}
@@ -3,71 +3,47 @@
 package main
 
 import (
-	"context"
 	"fmt"
-	"strconv"
-	"strings"
-)
 
-type ServiceState int
-
-const (
-	Ignore ServiceState = iota
-	Stopped
-	Start_Pending
-	Stop_Pending
-	Running
-	Continue_Pending
-	Pause_Pending
-	Paused
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/profile"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
 )
 
-var stateName = map[ServiceState]string{
-	Stopped:          "Stopped",
-	Start_Pending:    "Start Pending",
-	Stop_Pending:     "Stop Pending",
-	Running:          "Running",
-	Continue_Pending: "Continue Pending",
-	Pause_Pending:    "Pause Pending",
-	Paused:           "Paused",
+var stateName = map[svc.State]string{
+	svc.Stopped:         "Stopped",
+	svc.StartPending:    "Start Pending",
+	svc.StopPending:     "Stop Pending",
+	svc.Running:         "Running",
+	svc.ContinuePending: "Continue Pending",
+	svc.PausePending:    "Pause Pending",
+	svc.Paused:          "Paused",
+}
+
+var startTypeName = map[uint32]string{
+	mgr.StartAutomatic: "Automatic",
+	mgr.StartManual:    "Manual",
+	mgr.StartDisabled:  "Disabled",
 }
 
 // Gets current service state for user
 func (s *CLIService) StatusService() error {
-	stdoutResult, err := s.CmdExe.RunCommand(context.Background(), "sc.exe", "query", "Timekeep")
-	if err != nil {
-		return err
+	if profile.UserScope() {
+		fmt.Printf("  Status: %s (per-user agent)\n", s.agentStatus())
+		return nil
 	}
 
-	stdoutLines := strings.Split(stdoutResult, "\n")
-
-	stateStr := ""
-	for _, line := range stdoutLines {
-		trimmedLine := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmedLine, "STATE") {
-			stateStr = line
-			break
-		}
-	}
-	if stateStr == "" {
-		return fmt.Errorf("missing service state value")
-	}
-
-	parts := strings.Fields(stateStr)
-	if len(parts) < 3 {
-		return fmt.Errorf("malformed state line: %s", stateStr)
-	}
-
-	stateValStr := parts[2]
-	stateNum, err := strconv.Atoi(stateValStr)
+	status, err := queryServiceStatus()
 	if err != nil {
-		return fmt.Errorf("error converting state number '%s' to integer: %w", stateValStr, err)
+		return err
 	}
 
-	if state, ok := stateName[ServiceState(stateNum)]; ok {
-		fmt.Printf("  Status: %s\n", state)
-	} else {
-		fmt.Printf("  Status: Unknown state (%d)\n", stateNum)
+	fmt.Printf("  Status: %s\n", status.State)
+	fmt.Printf("  Start type: %s\n", status.StartType)
+	if status.PID != 0 {
+		fmt.Printf("  PID: %d\n", status.PID)
 	}
 
 	return nil
@@ -75,38 +51,65 @@ func (s *CLIService) StatusService() error {
 
 // GetServiceStatusString returns the service status as a string
 func (s *CLIService) GetServiceStatusString() (string, error) {
-	stdoutResult, err := s.CmdExe.RunCommand(context.Background(), "sc.exe", "query", "Timekeep")
+	if profile.UserScope() {
+		return s.agentStatus(), nil
+	}
+
+	status, err := queryServiceStatus()
 	if err != nil {
 		return "", err
 	}
+	return status.State, nil
+}
 
-	stdoutLines := strings.Split(stdoutResult, "\n")
+// Queries the SCM for the service's state, process ID and start type. Opens the SCM and service with query rights
+// only, so it works without Administrator privileges
+func queryServiceStatus() (ServiceStatus, error) {
+	scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return ServiceStatus{}, fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer windows.CloseServiceHandle(scm)
 
-	stateStr := ""
-	for _, line := range stdoutLines {
-		trimmedLine := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmedLine, "STATE") {
-			stateStr = line
-			break
-		}
+	name, err := windows.UTF16PtrFromString(serviceName())
+	if err != nil {
+		return ServiceStatus{}, err
+	}
+	handle, err := windows.OpenService(scm, name, windows.SERVICE_QUERY_STATUS|windows.SERVICE_QUERY_CONFIG)
+	if err != nil {
+		return ServiceStatus{}, fmt.Errorf("service %s is not installed: %w", serviceName(), err)
 	}
-	if stateStr == "" {
-		return "", fmt.Errorf("missing service state value")
+	service := &mgr.Service{Name: serviceName(), Handle: handle}
+	defer service.Close()
+
+	current, err := service.Query()
+	if err != nil {
+		return ServiceStatus{}, fmt.Errorf("failed to query service status: %w", err)
 	}
 
-	parts := strings.Fields(stateStr)
-	if len(parts) < 3 {
-		return "", fmt.Errorf("malformed state line: %s", stateStr)
+	status := ServiceStatus{State: stateName[current.State], PID: int(current.ProcessId)}
+	if status.State == "" {
+		status.State = fmt.Sprintf("Unknown state (%d)", current.State)
 	}
 
-	stateValStr := parts[2]
-	stateNum, err := strconv.Atoi(stateValStr)
-	if err != nil {
-		return "", fmt.Errorf("error converting state number '%s' to integer: %w", stateValStr, err)
+	if cfg, err := service.Config(); err == nil {
+		status.StartType = startTypeName[cfg.StartType]
+		if status.StartType == "" {
+			status.StartType = fmt.Sprintf("Unknown (%d)", cfg.StartType)
+		}
+		if cfg.StartType == mgr.StartAutomatic && cfg.DelayedAutoStart {
+			status.StartType = "Automatic (Delayed Start)"
+		}
 	}
 
-	if state, ok := stateName[ServiceState(stateNum)]; ok {
-		return state, nil
+	return status, nil
+}
+
+// Reports whether the per-user agent answers on its pipe. Agents have no SCM state
+func (s *CLIService) agentStatus() string {
+	resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionHealth))
+	if err != nil || !resp.OK {
+		return stateName[svc.Stopped]
 	}
-	return fmt.Sprintf("Unknown state (%d)", stateNum), nil
+	return stateName[svc.Running]
 }
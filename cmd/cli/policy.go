@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Prints the managed tracking policy in force: where it comes from, the programs it excludes and the categories it
+// sets
+func (s *CLIService) ShowPolicy() error {
+	if s.Config == nil || s.Config.Policy.Source == "" {
+		fmt.Println("No managed policy, policy.source is not set")
+		return nil
+	}
+	if s.Policy == nil {
+		fmt.Printf("Policy source %s has not been fetched by the service yet\n", s.Config.Policy.Source)
+		return nil
+	}
+
+	fmt.Printf("Policy from %s, fetched %s\n", s.Policy.Source, s.formatDateTime(s.Policy.FetchedAt.Local(), false))
+	if s.Policy.Source != s.Config.Policy.Source {
+		fmt.Printf("policy.source changed to %s, the service applies it at its next fetch\n", s.Config.Policy.Source)
+	}
+
+	if len(s.Policy.Exclude) > 0 {
+		fmt.Println("Never tracked:")
+		for _, pattern := range s.Policy.Exclude {
+			fmt.Printf(" • %s\n", pattern)
+		}
+	}
+
+	if len(s.Policy.Categories) > 0 {
+		patterns := make([]string, 0, len(s.Policy.Categories))
+		for pattern := range s.Policy.Categories {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+
+		fmt.Println("Categories:")
+		for _, pattern := range patterns {
+			fmt.Printf(" • %s: %s\n", pattern, s.Policy.Categories[pattern])
+		}
+	}
+	return nil
+}
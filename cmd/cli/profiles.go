@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/profile"
+)
+
+// Switches to the profile name, used by later commands given no --profile, and tells the service of each profile,
+// so that only that of name records and the others stand by
+func (s *CLIService) UseProfile(name string) error {
+	if _, remote := s.ServiceCmd.(*remoteServiceCommander); remote {
+		return usageError{err: errors.New("profiles are switched on this machine, not with --host")}
+	}
+	if name == "" {
+		name = profile.Default
+	}
+	profiles, err := profile.List()
+	if err != nil {
+		return fmt.Errorf("error listing profiles: %w", err)
+	}
+	if err := profile.Use(name); err != nil {
+		return usageError{err: err}
+	}
+
+	fmt.Printf("Switched to profile %s\n", name)
+	isNew := !slices.Contains(profiles, name)
+	if isNew {
+		fmt.Printf("Profile %s is new, install its service with 'timekeep service install'\n", name)
+		profiles = append(profiles, name)
+	}
+
+	if s.NoNotify {
+		return nil
+	}
+	for _, p := range profiles {
+		err := s.withProfile(p, func() error { return s.ServiceCmd.WriteToService() })
+		switch {
+		case p == name && !isNew && errors.Is(err, ipc.ErrUnreachable):
+			fmt.Printf("The service of profile %s isn't running, start it with 'timekeep service start'\n", name)
+		case err != nil && !errors.Is(err, ipc.ErrUnreachable):
+			fmt.Fprintf(os.Stderr, "Warning: failed to notify the service of profile %s: %v\n", p, err)
+		}
+	}
+	return nil
+}
+
+// Lists the profiles set up on this machine, which one is in use and the state of each one's service
+func (s *CLIService) ListProfiles() error {
+	profiles, err := profile.List()
+	if err != nil {
+		return fmt.Errorf("error listing profiles: %w", err)
+	}
+	active, err := profile.Active()
+	if err != nil {
+		return err
+	}
+	if active != "" && !slices.Contains(profiles, active) {
+		profiles = append(profiles, active)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROFILE\tIN USE\tSERVICE")
+	for _, p := range profiles {
+		inUse := ""
+		if p == active || (active == "" && p == profile.Default) {
+			inUse = "yes"
+		}
+		service := "not running"
+		_ = s.withProfile(p, func() error {
+			resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionHealth))
+			if err != nil || resp.Err() != nil {
+				return err
+			}
+			var health ipc.Health
+			if resp.Decode(&health) == nil {
+				service = health.Monitor
+			}
+			return nil
+		})
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p, inUse, service)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if active == "" {
+		fmt.Println("\nNo profile switched to, every profile's service records. Switch with 'timekeep profile use <name>'")
+	}
+	return nil
+}
+
+// Runs fn with the profile name selected, as for reaching the service of a profile other than this command's
+func (s *CLIService) withProfile(name string, fn func() error) error {
+	selected := profile.Name()
+	if err := profile.Set(name); err != nil {
+		return err
+	}
+	defer profile.Set(selected)
+	return fn()
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/streaks"
+)
+
+// Periods of 'timekeep project burndown'
+const (
+	BurndownDay  = "day"
+	BurndownWeek = "week"
+)
+
+// Sets the time a project is estimated to take, such as 40h, or clears it. Time counts towards the project of the
+// program it was recorded for, set with 'timekeep update --project'
+func (s *CLIService) SetEstimate(ctx context.Context, project, estimate string, remove bool) error {
+	project = strings.TrimSpace(project)
+	if project == "" {
+		return usageError{err: errors.New("no project given")}
+	}
+	if remove {
+		removed, err := s.EstRepo.RemoveProjectEstimate(ctx, project)
+		if err != nil {
+			return fmt.Errorf("error clearing estimate of %s: %w", project, err)
+		}
+		if removed == 0 {
+			return fmt.Errorf("project %s has no estimate: %w", project, sql.ErrNoRows)
+		}
+		fmt.Printf("Cleared the estimate of project %s\n", project)
+		return nil
+	}
+
+	d, err := time.ParseDuration(estimate)
+	if err != nil || d <= 0 {
+		return usageError{err: fmt.Errorf("invalid estimate %q, use a duration such as 40h or 90m", estimate)}
+	}
+	err = s.EstRepo.SetProjectEstimate(ctx, database.SetProjectEstimateParams{Project: project, EstimateSeconds: int64(d.Seconds()), SetAt: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("error setting estimate of %s: %w", project, err)
+	}
+
+	programs, err := s.PrRepo.GetAllPrograms(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting programs: %w", err)
+	}
+	fmt.Printf("Estimated project %s at %s\n", project, formatSpent(d))
+	if !slices.ContainsFunc(programs, func(p database.TrackedProgram) bool { return p.Project.String == project }) {
+		fmt.Printf("No tracked program is in project %s yet, add them with 'timekeep update --project %s'\n", project, project)
+	}
+	return nil
+}
+
+// Prints the time recorded for each project against its estimate: the time left or over, and the share used.
+// Projects without an estimate are listed with their time alone
+func (s *CLIService) ListProjects(ctx context.Context, includeArchive bool) error {
+	estimates, err := s.EstRepo.GetAllProjectEstimates(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting estimates: %w", err)
+	}
+	actual, err := s.projectTime(ctx, includeArchive, func(string, database.SessionHistory) {})
+	if err != nil {
+		return err
+	}
+
+	estimated := make(map[string]time.Duration, len(estimates))
+	for _, e := range estimates {
+		estimated[e.Project] = time.Duration(e.EstimateSeconds) * time.Second
+	}
+	var projects []string
+	for project := range actual {
+		projects = append(projects, project)
+	}
+	for project := range estimated {
+		if _, ok := actual[project]; !ok {
+			projects = append(projects, project)
+		}
+	}
+	if len(projects) == 0 {
+		fmt.Println("No projects, put programs in one with 'timekeep update --project'")
+		return nil
+	}
+	slices.Sort(projects)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROJECT\tACTUAL\tESTIMATE\tREMAINING\tUSED\t")
+	for _, project := range projects {
+		estimate, ok := estimated[project]
+		if !ok {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t\n", project, formatSpent(actual[project]))
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", project, formatSpent(actual[project]), formatSpent(estimate),
+			formatRemaining(estimate-actual[project]), percent(actual[project], estimate), bar(min(actual[project], estimate), estimate))
+	}
+	return w.Flush()
+}
+
+// Prints how the estimate of project burns down, by day or by week, from its first session to now: the time spent in
+// each period, the total so far and the time left. With time left, the pace so far projects when it runs out
+func (s *CLIService) ShowBurndown(ctx context.Context, project, by string, includeArchive bool) error {
+	if by != BurndownDay && by != BurndownWeek {
+		return usageError{err: fmt.Errorf("unknown period %q, use day or week", by)}
+	}
+	est, err := s.EstRepo.GetProjectEstimate(ctx, project)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("project %s has no estimate, set one with 'timekeep project estimate %s <duration>': %w", project, project, err)
+	}
+	if err != nil {
+		return fmt.Errorf("error getting estimate of %s: %w", project, err)
+	}
+	estimate := time.Duration(est.EstimateSeconds) * time.Second
+
+	var sessions []database.SessionHistory
+	_, err = s.projectTime(ctx, includeArchive, func(p string, session database.SessionHistory) {
+		if p == project {
+			sessions = append(sessions, session)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Burn-down of project %s, estimated at %s\n", project, formatSpent(estimate))
+	if len(sessions) == 0 {
+		fmt.Println("No time recorded for it yet")
+		return nil
+	}
+
+	loc := s.location()
+	now := time.Now()
+	first := now
+	for _, session := range sessions {
+		first = dates.Earlier(first, session.StartTime)
+	}
+	period := "today"
+	if by == BurndownWeek {
+		period = "this week"
+	}
+	current, err := dates.Parse(period, first.In(loc), loc, s.dateOptions())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tSPENT\tTOTAL\tREMAINING\t\n", strings.ToUpper(by))
+	var total time.Duration
+	periods := 0
+	for start := current.Start; start.Before(now); periods++ {
+		end := start.AddDate(0, 0, 1)
+		if by == BurndownWeek {
+			end = start.AddDate(0, 0, 7)
+		}
+		var spent time.Duration
+		for _, session := range sessions {
+			spent += streaks.Within(session, start, end)
+		}
+		total += spent
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.formatDate(start), formatSpent(spent), formatSpent(total), formatRemaining(estimate-total), bar(max(estimate-total, 0), estimate))
+		start = end
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	left := estimate - total
+	fmt.Println()
+	switch {
+	case left <= 0:
+		fmt.Printf("Over the estimate by %s, %s of it used\n", formatSpent(-left), percent(total, estimate))
+	case total <= 0:
+		fmt.Printf("%s left\n", formatSpent(left))
+	default:
+		pace := total / time.Duration(periods)
+		more := int((left + pace - 1) / pace)
+		fmt.Printf("%s left, %s of the estimate used. At %s a %s, it runs out in about %s\n", formatSpent(left), percent(total, estimate), formatSpent(pace), by, plural(int64(more), by))
+	}
+	return nil
+}
+
+// Returns the time recorded for each project, including sessions still running, passing every session of a program
+// in a project to emit as it goes
+func (s *CLIService) projectTime(ctx context.Context, includeArchive bool, emit func(project string, session database.SessionHistory)) (map[string]time.Duration, error) {
+	programs, err := s.PrRepo.GetAllPrograms(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting programs: %w", err)
+	}
+	projectOf := make(map[string]string, len(programs))
+	for _, program := range programs {
+		projectOf[program.Name] = program.Project.String
+	}
+
+	totals := make(map[string]time.Duration)
+	err = s.streamSessionHistory(ctx, "", "", "", "", "", includeArchive, func(session database.SessionHistory) {
+		if project := projectOf[session.ProgramName]; project != "" {
+			totals[project] += time.Duration(session.DurationSeconds) * time.Second
+			emit(project, session)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := s.AsRepo.GetAllActiveSessions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting active sessions: %w", err)
+	}
+	now := time.Now()
+	for _, session := range active {
+		if project := projectOf[session.ProgramName]; project != "" {
+			spent := now.Sub(session.StartTime)
+			totals[project] += spent
+			emit(project, database.SessionHistory{ProgramName: session.ProgramName, StartTime: session.StartTime, EndTime: now, DurationSeconds: int64(spent.Seconds())})
+		}
+	}
+	return totals, nil
+}
+
+// Formats time left of an estimate, or how far past it the time went
+func formatRemaining(left time.Duration) string {
+	if left < 0 {
+		return formatSpent(-left) + " over"
+	}
+	return formatSpent(left)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+)
+
+// Styles of 'timekeep prompt'
+const (
+	PromptStarship = "starship"   // Plain text for a custom module, styled by starship's config
+	PromptOhMyPosh = "oh-my-posh" // Text for a command segment, colored by oh-my-posh's color tags while an alert stands
+)
+
+// Prints the metric set by prompt.show for a shell prompt module: the running session, the time recorded today or the
+// progress towards a daily goal. Nothing is printed when there's nothing to show, which prompts take to hide the module.
+// The status is cached like that of 'timekeep statusbar', and when reading it takes longer than prompt.budget the last
+// cached status is shown instead, or nothing. Failures print nothing rather than an error, to keep the prompt clean
+func (s *CLIService) ShowPrompt(ctx context.Context, style string) error {
+	if style == "" {
+		style = PromptStarship
+	}
+	if style != PromptStarship && style != PromptOhMyPosh {
+		return fmt.Errorf("unknown style %q, use starship or oh-my-posh", style)
+	}
+
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, s.Config.PromptBudget())
+	defer cancel()
+
+	result := make(chan TrayStatus, 1)
+	go func() {
+		if status, err := s.cachedTrayStatus(ctx, now, DefaultStatusbarCache); err == nil {
+			result <- status
+		}
+		close(result)
+	}()
+
+	var status TrayStatus
+	var ok bool
+	select {
+	case status, ok = <-result:
+	case <-ctx.Done():
+	}
+	if !ok {
+		cached, found := readStatusCache()
+		if !found || !sameDay(cached.At, now, s.location()) {
+			return nil
+		}
+		status = cached.aged(now)
+	}
+
+	text := s.promptText(status)
+	if text == "" {
+		return nil
+	}
+	if len(status.Alerts) > 0 && style == PromptOhMyPosh {
+		text = fmt.Sprintf("<%s>%s</>", statusbarAlertColor, text)
+	}
+	fmt.Println(text)
+	return nil
+}
+
+// Returns the text of the metric set by prompt.show, empty when there's nothing to show
+func (s *CLIService) promptText(status TrayStatus) string {
+	switch s.Config.PromptShows() {
+	case config.PromptToday:
+		if status.Today <= 0 {
+			return ""
+		}
+		return formatSpent(status.Today)
+	case config.PromptGoal:
+		category, ok := promptGoal(s.Config, status)
+		if !ok {
+			return ""
+		}
+		return fmt.Sprintf("%s %s/%s", category, formatSpent(status.Goals[category]), formatSpent(s.Config.DailyGoal(category)))
+	default:
+		if status.Program == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s %s", status.Program, formatSpent(status.Elapsed))
+	}
+}
+
+// Picks the goal the prompt shows progress towards: that of the running program's category, or else the first goal
+// not met yet, or else the first goal
+func promptGoal(c *config.Config, status TrayStatus) (string, bool) {
+	if _, ok := status.Goals[status.Category]; ok && status.Program != "" {
+		return status.Category, true
+	}
+	categories := slices.Sorted(maps.Keys(status.Goals))
+	if len(categories) == 0 {
+		return "", false
+	}
+	for _, category := range categories {
+		if status.Goals[category] < c.DailyGoal(category) {
+			return category, true
+		}
+	}
+	return categories[0], true
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	mysql "github.com/jms-guy/timekeep/sql"
+)
+
+// Output formats of 'timekeep query'
+const (
+	FormatTable = "table"
+	FormatCSV   = "csv"
+	FormatJSON  = "json"
+)
+
+// Runs a SQL query against a read-only connection to the local database, printing the rows as a table, CSV or JSON
+func (s *CLIService) RunQuery(ctx context.Context, query, format string) error {
+	if strings.TrimSpace(query) == "" {
+		return errors.New("no query given")
+	}
+	switch format {
+	case FormatTable, FormatCSV, FormatJSON:
+	default:
+		return fmt.Errorf("unknown format %q, use table, csv or json", format)
+	}
+
+	db, err := mysql.OpenReadOnlyDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("error running query: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("error reading columns: %w", err)
+	}
+
+	var results [][]any
+	for rows.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return fmt.Errorf("error reading row: %w", err)
+		}
+		for i, value := range values {
+			values[i] = queryValue(value)
+		}
+		results = append(results, values)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error running query: %w", err)
+	}
+
+	switch format {
+	case FormatCSV:
+		return writeQueryCSV(os.Stdout, columns, results)
+	case FormatJSON:
+		return writeQueryJSON(os.Stdout, columns, results)
+	default:
+		return writeQueryTable(os.Stdout, columns, results)
+	}
+}
+
+// Converts a scanned column value to one that prints and encodes readably
+func queryValue(value any) any {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return v
+	}
+}
+
+func queryText(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+func writeQueryTable(out io.Writer, columns []string, rows [][]any) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.ToUpper(strings.Join(columns, "\t")))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, value := range row {
+			if value == nil {
+				cells[i] = "NULL"
+			} else {
+				// Tabs and newlines inside values would break the table's layout
+				cells[i] = strings.NewReplacer("\t", " ", "\n", " ", "\r", "").Replace(queryText(value))
+			}
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "(%s)\n", plural(int64(len(rows)), "row"))
+	return nil
+}
+
+func writeQueryCSV(out io.Writer, columns []string, rows [][]any) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			record[i] = queryText(value)
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Writes rows as a JSON array of objects keyed by column name, keeping column order
+func writeQueryJSON(out io.Writer, columns []string, rows [][]any) error {
+	var b strings.Builder
+	b.WriteString("[")
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n  {")
+		for j, value := range row {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			key, err := json.Marshal(columns[j])
+			if err != nil {
+				return err
+			}
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			b.Write(key)
+			b.WriteString(": ")
+			b.Write(encoded)
+		}
+		b.WriteString("}")
+	}
+	if len(rows) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("]\n")
+	_, err := io.WriteString(out, b.String())
+	return err
+}
@@ -0,0 +1,690 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/productivity"
+	"github.com/jms-guy/timekeep/internal/progname"
+	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/jms-guy/timekeep/internal/rounding"
+	"github.com/jms-guy/timekeep/internal/streaks"
+)
+
+// Returns a check of sessions against a --launch filter, as parsed by repository.ParseLaunchFilter, passing every
+// session when there's none
+func launchMatcher(launch string) (func(database.SessionHistory) bool, error) {
+	if launch == "" {
+		return func(database.SessionHistory) bool { return true }, nil
+	}
+	filter, err := repository.ParseLaunchFilter(launch)
+	if err != nil {
+		return nil, err
+	}
+	return func(session database.SessionHistory) bool {
+		metadata, err := repository.DecodeSessionMetadata(session.Metadata)
+		return err == nil && filter.Match(metadata.Launch)
+	}, nil
+}
+
+// Levels of sparkline characters, lowest first
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Width of the bars of report tables, at the largest share
+const barWidth = 24
+
+// Prints how the time of program, category, project or group, or of every program when none is given, spreads over
+// the hours of the day and the days of the week within the range picked like that of 'timekeep history', as shares of
+// the total with sparklines and bars. Sessions are split at each hour they run through, in the configured timezone
+func (s *CLIService) ShowDistribution(ctx context.Context, date, start, end, program, category, project, group, launch string, includeArchive bool) error {
+	program = progname.Normalize(program)
+	launched, err := launchMatcher(launch)
+	if err != nil {
+		return err
+	}
+	inGroup, err := s.groupMatcher(ctx, group)
+	if err != nil {
+		return err
+	}
+	rangeStart, rangeEnd, err := s.historyRange(date, start, end)
+	if err != nil {
+		return err
+	}
+
+	programs, err := s.PrRepo.GetAllPrograms(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting programs: %w", err)
+	}
+	byName := make(map[string]database.TrackedProgram, len(programs))
+	for _, p := range programs {
+		byName[p.Name] = p
+	}
+
+	loc := s.location()
+	var hours [24]time.Duration
+	var weekdays [7]time.Duration
+	var total time.Duration
+	err = s.streamSessionHistory(ctx, program, date, start, end, s.Device, includeArchive, func(session database.SessionHistory) {
+		p := byName[session.ProgramName]
+		if (category != "" && p.Category.String != category) || (project != "" && p.Project.String != project) || !inGroup(session.ProgramName) || !launched(session) {
+			return
+		}
+
+		from, to := session.StartTime, session.EndTime
+		if from.Before(rangeStart) {
+			from = rangeStart
+		}
+		if to.After(rangeEnd) {
+			to = rangeEnd
+		}
+		wall := session.EndTime.Sub(session.StartTime)
+		if !to.After(from) || wall <= 0 {
+			return
+		}
+		// Paused or merged sessions record less than their wall time, which is spread evenly
+		ratio := float64(session.DurationSeconds) * float64(time.Second) / float64(wall)
+
+		for t := from.In(loc); t.Before(to); {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			if next.After(to) {
+				next = to.In(loc)
+			}
+			spent := time.Duration(float64(next.Sub(t)) * ratio)
+			hours[t.Hour()] += spent
+			weekdays[t.Weekday()] += spent
+			total += spent
+			t = next
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	subject := "all programs"
+	switch {
+	case program != "":
+		subject = program
+	case category != "":
+		subject = "category " + category
+	case project != "":
+		subject = "project " + project
+	case group != "":
+		subject = "group " + group
+	}
+	if launch != "" {
+		subject += " launched " + launch
+	}
+	period := "all recorded time"
+	if !rangeStart.IsZero() {
+		period = fmt.Sprintf("%s to %s", s.formatDate(rangeStart.In(loc)), s.formatDate(rangeEnd.Add(-time.Nanosecond).In(loc)))
+	}
+	fmt.Printf("Distribution of %s, %s\n", subject, period)
+	fmt.Printf("Total: %s\n", formatSpent(total))
+	if total == 0 {
+		return nil
+	}
+
+	first := s.Config.WeekStartDay()
+	days := make([]time.Weekday, 7)
+	dayTotals := make([]time.Duration, 7)
+	for i := range days {
+		days[i] = (first + time.Weekday(i)) % 7
+		dayTotals[i] = weekdays[days[i]]
+	}
+
+	fmt.Printf("\nHours     %s\n", sparkline(hours[:]))
+	fmt.Printf("Weekdays  %s\n\n", sparkline(dayTotals))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOUR\tTIME\tSHARE\t")
+	peak := slices.Max(hours[:])
+	for hour, spent := range hours {
+		clock := time.Date(2000, 1, 1, hour, 0, 0, 0, loc)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.formatClock(clock, false), formatSpent(spent), percent(spent, total), bar(spent, peak))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WEEKDAY\tTIME\tSHARE\t")
+	peak = slices.Max(dayTotals)
+	for i, day := range days {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", day, formatSpent(dayTotals[i]), percent(dayTotals[i], total), bar(dayTotals[i], peak))
+	}
+	return w.Flush()
+}
+
+// Prints how the time of program, category, project or group, or of every program when none is given, splits over
+// the workspaces or virtual desktops it was spent on, within the range picked like that of 'timekeep history', over
+// every session by default. Workspaces are shown by their label in config (workspaces.labels), so those sharing a
+// label add up, and session time the service recorded no workspace for, as before workspaces.track was set, is listed apart
+func (s *CLIService) ShowWorkspaces(ctx context.Context, date, start, end, program, category, project, group, launch string, includeArchive bool) error {
+	program = progname.Normalize(program)
+	launched, err := launchMatcher(launch)
+	if err != nil {
+		return err
+	}
+	inGroup, err := s.groupMatcher(ctx, group)
+	if err != nil {
+		return err
+	}
+	rangeStart, rangeEnd, err := s.historyRange(date, start, end)
+	if err != nil {
+		return err
+	}
+
+	programs, err := s.PrRepo.GetAllPrograms(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting programs: %w", err)
+	}
+	byName := make(map[string]database.TrackedProgram, len(programs))
+	for _, p := range programs {
+		byName[p.Name] = p
+	}
+
+	spentOn := make(map[string]time.Duration)
+	var total, unrecorded time.Duration
+	err = s.streamSessionHistory(ctx, program, date, start, end, s.Device, includeArchive, func(session database.SessionHistory) {
+		p := byName[session.ProgramName]
+		if (category != "" && p.Category.String != category) || (project != "" && p.Project.String != project) || !inGroup(session.ProgramName) || !launched(session) {
+			return
+		}
+
+		from, to := dates.Later(session.StartTime, rangeStart), dates.Earlier(session.EndTime, rangeEnd)
+		wall := session.EndTime.Sub(session.StartTime)
+		if !to.After(from) || wall <= 0 {
+			return
+		}
+		// Sessions running past the range count the share of their time within it, spread evenly
+		ratio := float64(to.Sub(from)) / float64(wall)
+		duration := time.Duration(float64(session.DurationSeconds) * float64(time.Second) * ratio)
+		total += duration
+
+		metadata, _ := repository.DecodeSessionMetadata(session.Metadata)
+		var recorded time.Duration
+		for workspace, seconds := range metadata.Workspaces {
+			spent := time.Duration(float64(seconds) * float64(time.Second) * ratio)
+			spentOn[s.Config.WorkspaceLabel(workspace)] += spent
+			recorded += spent
+		}
+		if recorded < duration {
+			unrecorded += duration - recorded
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	subject := "all programs"
+	switch {
+	case program != "":
+		subject = program
+	case category != "":
+		subject = "category " + category
+	case project != "":
+		subject = "project " + project
+	case group != "":
+		subject = "group " + group
+	}
+	if launch != "" {
+		subject += " launched " + launch
+	}
+	period := "all recorded time"
+	if !rangeStart.IsZero() {
+		loc := s.location()
+		period = fmt.Sprintf("%s to %s", s.formatDate(rangeStart.In(loc)), s.formatDate(rangeEnd.Add(-time.Nanosecond).In(loc)))
+	}
+	fmt.Printf("Workspaces of %s, %s\n", subject, period)
+	fmt.Printf("Total: %s\n", formatSpent(total))
+	if total == 0 {
+		return nil
+	}
+	if len(spentOn) == 0 {
+		fmt.Println("No workspaces recorded, set workspaces.track in config to record them")
+		return nil
+	}
+
+	labels := make([]string, 0, len(spentOn))
+	for label := range spentOn {
+		labels = append(labels, label)
+	}
+	slices.SortFunc(labels, func(a, b string) int {
+		if spentOn[a] != spentOn[b] {
+			return int(spentOn[b] - spentOn[a])
+		}
+		return strings.Compare(a, b)
+	})
+	peak := spentOn[labels[0]]
+	if unrecorded > peak {
+		peak = unrecorded
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WORKSPACE\tTIME\tSHARE\t")
+	for _, label := range labels {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", label, formatSpent(spentOn[label]), percent(spentOn[label], total), bar(spentOn[label], peak))
+	}
+	if unrecorded >= time.Second {
+		fmt.Fprintf(w, "(not recorded)\t%s\t%s\t%s\n", formatSpent(unrecorded), percent(unrecorded, total), bar(unrecorded, peak))
+	}
+	return w.Flush()
+}
+
+// Draws values as a sparkline, one character each scaled to the largest, blank for nothing
+func sparkline(values []time.Duration) string {
+	peak := slices.Max(values)
+	var b strings.Builder
+	for _, v := range values {
+		if v <= 0 || peak <= 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		level := int(math.Ceil(float64(v)/float64(peak)*float64(len(sparks)))) - 1
+		b.WriteRune(sparks[max(level, 0)])
+	}
+	return b.String()
+}
+
+// Draws v as a bar of up to barWidth blocks, scaled to peak
+func bar(v, peak time.Duration) string {
+	if v <= 0 || peak <= 0 {
+		return ""
+	}
+	n := int(math.Round(float64(v) / float64(peak) * barWidth))
+	if n == 0 {
+		return "▏"
+	}
+	return strings.Repeat("█", n)
+}
+
+// Prints, for each workday in the range picked like that of 'timekeep history', this week by default, how much of the
+// configured workday hours sessions cover and how much is left untracked, idle or spent in programs not tracked.
+// Sessions of several programs at once count once, and those still running count up to now
+func (s *CLIService) ShowUntracked(ctx context.Context, date, start, end, program, category, project, group, launch string, includeArchive bool) error {
+	program = progname.Normalize(program)
+	launched, err := launchMatcher(launch)
+	if err != nil {
+		return err
+	}
+	inGroup, err := s.groupMatcher(ctx, group)
+	if err != nil {
+		return err
+	}
+	if date == "" && start == "" {
+		date = "this week"
+	}
+	rangeStart, rangeEnd, err := s.historyRange(date, start, end)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if rangeEnd.After(now) {
+		rangeEnd = now
+	}
+
+	programs, err := s.PrRepo.GetAllPrograms(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting programs: %w", err)
+	}
+	byName := make(map[string]database.TrackedProgram, len(programs))
+	for _, p := range programs {
+		byName[p.Name] = p
+	}
+	picked := func(name string) bool {
+		p := byName[name]
+		return (program == "" || name == program) && (category == "" || p.Category.String == category) && (project == "" || p.Project.String == project) && inGroup(name)
+	}
+
+	var spans [][2]time.Time
+	err = s.streamSessionHistory(ctx, program, date, start, end, s.Device, includeArchive, func(session database.SessionHistory) {
+		if picked(session.ProgramName) && launched(session) {
+			spans = append(spans, [2]time.Time{session.StartTime, session.EndTime})
+		}
+	})
+	if err != nil {
+		return err
+	}
+	active, err := s.activeSessions(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting active sessions: %w", err)
+	}
+	for _, session := range active {
+		if picked(session.ProgramName) && launch == "" { // Running sessions have no launch context stored yet
+			spans = append(spans, [2]time.Time{session.StartTime, now})
+		}
+	}
+	spans = mergeSpans(spans)
+
+	loc := s.location()
+	from, to := s.Config.WorkdayHours()
+	fmt.Printf("Workday coverage, %s to %s, %s to %s\n", s.formatDate(rangeStart.In(loc)), s.formatDate(rangeEnd.Add(-time.Nanosecond).In(loc)),
+		s.formatClock(time.Date(2000, 1, 1, 0, int(from/time.Minute), 0, 0, loc), false),
+		s.formatClock(time.Date(2000, 1, 1, 0, int(to/time.Minute), 0, 0, loc), false))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tWORKDAY\tTRACKED\tUNTRACKED\tCOVERED\t")
+	var workTotal, trackedTotal time.Duration
+	first := rangeStart.In(loc)
+	for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc); day.Before(rangeEnd); day = day.AddDate(0, 0, 1) {
+		if !s.Config.IsWorkday(day.Weekday()) {
+			continue
+		}
+		dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, int(from/time.Minute), 0, 0, loc)
+		dayEnd := time.Date(day.Year(), day.Month(), day.Day(), 0, int(to/time.Minute), 0, 0, loc)
+		dayStart, dayEnd = dates.Later(dayStart, rangeStart), dates.Earlier(dayEnd, rangeEnd)
+		if !dayEnd.After(dayStart) {
+			continue
+		}
+
+		var tracked time.Duration
+		for _, span := range spans {
+			if overlap := dates.Earlier(span[1], dayEnd).Sub(dates.Later(span[0], dayStart)); overlap > 0 {
+				tracked += overlap
+			}
+		}
+		work := dayEnd.Sub(dayStart)
+		workTotal += work
+		trackedTotal += tracked
+		fmt.Fprintf(w, "%s %s\t%s\t%s\t%s\t%s\t%s\n", s.formatDate(day), day.Weekday().String()[:3], formatSpent(work), formatSpent(tracked),
+			formatSpent(work-tracked), percent(tracked, work), bar(tracked, work))
+	}
+	if workTotal == 0 {
+		fmt.Println("No workday hours in range")
+		return nil
+	}
+	fmt.Fprintf(w, "Total\t%s\t%s\t%s\t%s\t\n", formatSpent(workTotal), formatSpent(trackedTotal), formatSpent(workTotal-trackedTotal), percent(trackedTotal, workTotal))
+	return w.Flush()
+}
+
+// Sorts spans by start and joins those that overlap or touch, so time covered by several counts once
+func mergeSpans(spans [][2]time.Time) [][2]time.Time {
+	slices.SortFunc(spans, func(a, b [2]time.Time) int { return a[0].Compare(b[0]) })
+	var merged [][2]time.Time
+	for _, span := range spans {
+		if n := len(merged); n > 0 && !span[0].After(merged[n-1][1]) {
+			merged[n-1][1] = dates.Later(merged[n-1][1], span[1])
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
+
+// Prints, for each day in the range picked like that of 'timekeep history', this week by default, how much of the
+// recorded time was productive and distracting by the ratings in config (productivity), with the day's productivity
+// pulse and, with weights or a formula set, its score, and the same for each week when the range spans several.
+// Sessions of unrated programs count as neutral
+func (s *CLIService) ShowProductivity(ctx context.Context, date, start, end, program, category, project, group, launch string, includeArchive bool) error {
+	if date == "" && start == "" {
+		date = "this week"
+	}
+	f, err := productivity.Formula(s.Config)
+	if err != nil {
+		return err
+	}
+	days, summaries, err := s.productivityDays(ctx, date, start, end, program, category, project, group, launch, includeArchive)
+	if err != nil {
+		return err
+	}
+	if len(days) == 0 {
+		fmt.Println("No days in range")
+		return nil
+	}
+
+	subject := "all programs"
+	switch {
+	case program != "":
+		subject = progname.Normalize(program)
+	case category != "":
+		subject = "category " + category
+	case project != "":
+		subject = "project " + project
+	case group != "":
+		subject = "group " + group
+	}
+	if launch != "" {
+		subject += " launched " + launch
+	}
+	fmt.Printf("Productivity of %s, %s to %s\n", subject, s.formatDate(days[0]), s.formatDate(days[len(days)-1]))
+	scoring := s.Config.ProductivityScoring()
+	if len(s.Config.Productivity.Programs) == 0 && len(s.Config.Productivity.Categories) == 0 && !scoring {
+		fmt.Println("No productivity ratings set, rate programs or categories under productivity in config")
+		return nil
+	}
+	columns := func(sum productivity.Summary) string {
+		row := productivityColumns(sum)
+		if scoring {
+			row += fmt.Sprintf("\t%.1f", sum.Score(f))
+		}
+		return row
+	}
+	header := "TOTAL\tPRODUCTIVE\tDISTRACTING\tPRODUCTIVE %\tPULSE\t"
+	if scoring {
+		header += "\tSCORE"
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "DATE\t%s\n", header)
+	var total productivity.Summary
+	var weeks []time.Time
+	byWeek := make(map[time.Time]productivity.Summary)
+	weekStart := s.Config.WeekStartDay()
+	for i, day := range days {
+		sum := summaries[i]
+		total = total.Plus(sum)
+		week := day.AddDate(0, 0, -((int(day.Weekday()) - int(weekStart) + 7) % 7))
+		if _, ok := byWeek[week]; !ok {
+			weeks = append(weeks, week)
+		}
+		byWeek[week] = byWeek[week].Plus(sum)
+		fmt.Fprintf(w, "%s %s\t%s\n", s.formatDate(day), day.Weekday().String()[:3], columns(sum))
+	}
+	fmt.Fprintf(w, "Total\t%s\n", columns(total))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(weeks) < 2 {
+		return nil
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "WEEK OF\t%s\n", header)
+	for _, week := range weeks {
+		fmt.Fprintf(w, "%s\t%s\n", s.formatDate(week), columns(byWeek[week]))
+	}
+	return w.Flush()
+}
+
+// Formats the columns of a productivity report row, the pulse drawn as a bar out of 100
+func productivityColumns(sum productivity.Summary) string {
+	if sum.Total <= 0 {
+		return fmt.Sprintf("%s\t-\t-\t-\t-\t", formatSpent(0))
+	}
+	return fmt.Sprintf("%s\t%s\t%s\t%d%%\t%d\t%s", formatSpent(sum.Total), formatSpent(sum.Productive), formatSpent(sum.Distracting),
+		sum.ProductivePercent(), sum.Pulse(), bar(time.Duration(sum.Pulse()), 100))
+}
+
+// Returns each day in the range picked like that of 'timekeep history', up to today, with the productivity of the
+// time recorded on it by program, category, project, group or launch when given. Sessions still running count up to
+// now, unless narrowed by launch
+func (s *CLIService) productivityDays(ctx context.Context, date, start, end, program, category, project, group, launch string, includeArchive bool) ([]time.Time, []productivity.Summary, error) {
+	program = progname.Normalize(program)
+	launched, err := launchMatcher(launch)
+	if err != nil {
+		return nil, nil, err
+	}
+	inGroup, err := s.groupMatcher(ctx, group)
+	if err != nil {
+		return nil, nil, err
+	}
+	rangeStart, rangeEnd, err := s.historyRange(date, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if rangeEnd.After(now) {
+		rangeEnd = now
+	}
+
+	programs, err := s.PrRepo.GetAllPrograms(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting programs: %w", err)
+	}
+	byName := make(map[string]database.TrackedProgram, len(programs))
+	for _, p := range programs {
+		byName[p.Name] = p
+	}
+	picked := func(name string) bool {
+		p := byName[name]
+		return (program == "" || name == program) && (category == "" || p.Category.String == category) && (project == "" || p.Project.String == project) && inGroup(name)
+	}
+
+	loc := s.location()
+	first := rangeStart.In(loc)
+	var days []time.Time
+	for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc); day.Before(rangeEnd); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	summaries := make([]productivity.Summary, len(days))
+	count := func(session database.SessionHistory) {
+		for i, day := range days {
+			from, to := dates.Later(day, rangeStart), dates.Earlier(day.AddDate(0, 0, 1), rangeEnd)
+			summaries[i].Add(s.Config, session.ProgramName, byName[session.ProgramName].Category.String, streaks.Within(session, from, to))
+		}
+	}
+
+	err = s.streamSessionHistory(ctx, program, date, start, end, s.Device, includeArchive, func(session database.SessionHistory) {
+		if picked(session.ProgramName) && launched(session) {
+			count(session)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	active, err := s.activeSessions(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting active sessions: %w", err)
+	}
+	for _, session := range active {
+		if picked(session.ProgramName) && launch == "" { // Running sessions have no launch context stored yet
+			count(database.SessionHistory{ProgramName: session.ProgramName, StartTime: session.StartTime, EndTime: now, DurationSeconds: int64(now.Sub(session.StartTime).Seconds())})
+		}
+	}
+	return days, summaries, nil
+}
+
+// Prints a timesheet of the completed sessions starting in the range picked like that of 'timekeep history', this week
+// by default: for each day and project, the sessions, their exact time and the time rounded as rounding in config sets,
+// as billed through Harvest and Kimai. Sessions count on the day they start, and those of programs in no project
+// under "(no project)"
+func (s *CLIService) ShowTimesheet(ctx context.Context, date, start, end, program, category, project, group, launch string, includeArchive bool) error {
+	program = progname.Normalize(program)
+	launched, err := launchMatcher(launch)
+	if err != nil {
+		return err
+	}
+	inGroup, err := s.groupMatcher(ctx, group)
+	if err != nil {
+		return err
+	}
+	if date == "" && start == "" {
+		date = "this week"
+	}
+	rangeStart, rangeEnd, err := s.historyRange(date, start, end)
+	if err != nil {
+		return err
+	}
+
+	programs, err := s.PrRepo.GetAllPrograms(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting programs: %w", err)
+	}
+	byName := make(map[string]database.TrackedProgram, len(programs))
+	for _, p := range programs {
+		byName[p.Name] = p
+	}
+
+	type row struct {
+		day      time.Time
+		project  string
+		sessions []time.Duration
+	}
+	var rows []*row
+	byKey := make(map[string]*row)
+	loc := s.location()
+	err = s.streamSessionHistory(ctx, program, date, start, end, s.Device, includeArchive, func(session database.SessionHistory) {
+		p := byName[session.ProgramName]
+		if (category != "" && p.Category.String != category) || (project != "" && p.Project.String != project) || !inGroup(session.ProgramName) || !launched(session) {
+			return
+		}
+		if session.StartTime.Before(rangeStart) || !session.StartTime.Before(rangeEnd) {
+			return
+		}
+		local := session.StartTime.In(loc)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		name := p.Project.String
+		if name == "" {
+			name = "(no project)"
+		}
+		key := day.Format(time.DateOnly) + "\x00" + name
+		r, ok := byKey[key]
+		if !ok {
+			r = &row{day: day, project: name}
+			byKey[key] = r
+			rows = append(rows, r)
+		}
+		r.sessions = append(r.sessions, time.Duration(session.DurationSeconds)*time.Second)
+	})
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(rows, func(a, b *row) int {
+		if c := a.day.Compare(b.day); c != 0 {
+			return c
+		}
+		return strings.Compare(a.project, b.project)
+	})
+
+	rule := rounding.Of(s.Config)
+	heading := fmt.Sprintf("Timesheet, %s to %s", s.formatDate(rangeStart.In(loc)), s.formatDate(rangeEnd.Add(-time.Nanosecond).In(loc)))
+	if rule.Exact() {
+		heading += ", exact time, set rounding in config to round it"
+	} else {
+		heading += ", " + rule.String()
+	}
+	fmt.Println(heading)
+	if len(rows) == 0 {
+		fmt.Println("No sessions in range")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tPROJECT\tSESSIONS\tEXACT\tROUNDED\t")
+	var exact, rounded time.Duration
+	count := 0
+	for _, r := range rows {
+		var spent time.Duration
+		for _, d := range r.sessions {
+			spent += d
+		}
+		billed := rule.Day(r.sessions)
+		exact += spent
+		rounded += billed
+		count += len(r.sessions)
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t\n", s.formatDate(r.day), r.project, len(r.sessions), formatSpent(spent), formatSpent(billed))
+	}
+	fmt.Fprintf(w, "Total\t\t%d\t%s\t%s\t\n", count, formatSpent(exact), formatSpent(rounded))
+	return w.Flush()
+}
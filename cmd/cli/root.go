@@ -3,18 +3,48 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/profile"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	_ "modernc.org/sqlite"
 )
 
 func (s *CLIService) RootCmd() *cobra.Command {
+	var target ipc.RemoteTarget
+
 	rootCmd := &cobra.Command{
 		Use:   "timekeep",
 		Short: "Timekeep is a process activity tracker",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Prompt and status bar output is read on every refresh, where a warning would only add noise
+			quiet := cmd.Name() == "validate" || cmd.Name() == "prompt" || cmd.Name() == "statusbar"
+			if problems := s.Config.Validate(); len(problems) > 0 && !quiet {
+				fmt.Fprintf(os.Stderr, "Warning: config file has %d problem(s), run 'timekeep config validate' for details\n", len(problems))
+			}
+
+			if stdin, ok := cmd.InOrStdin().(*os.File); ok && isTerminal(stdin) {
+				s.Input = stdin
+			}
+
+			if target.Addr == "" {
+				return nil
+			}
+			if target.Token == "" {
+				return fmt.Errorf("--token or %s required with --host", remoteTokenEnv)
+			}
+			if path := localOnlyPath(cmd); path != "" {
+				return usageError{err: fmt.Errorf("'timekeep %s' changes this machine's database or config, not the remote service's, so can't be used with --host", path)}
+			}
+			s.ServiceCmd = &remoteServiceCommander{target: target}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) == 0 {
 				return s.GetStats(cmd.Context())
@@ -23,6 +53,16 @@ func (s *CLIService) RootCmd() *cobra.Command {
 		},
 	}
 
+	// Applied by selectProfile before setup, registered here for help and validation
+	rootCmd.PersistentFlags().String("profile", os.Getenv(profile.Env), "Named profile, with its own database, config, IPC endpoint and service unit. The one switched to with 'profile use' by default")
+	rootCmd.PersistentFlags().StringVar(&target.Addr, "host", "", "Send service commands to a remote timekeep service (host[:port])")
+	rootCmd.PersistentFlags().StringVar(&target.Token, "token", os.Getenv(remoteTokenEnv), "Remote token configured on the service")
+	rootCmd.PersistentFlags().StringVar(&target.CAFile, "ca", "", "PEM certificate to trust for the remote service")
+	rootCmd.PersistentFlags().BoolVar(&target.Insecure, "insecure", false, "Skip verification of the remote service's certificate")
+	rootCmd.PersistentFlags().BoolVar(&s.NoNotify, "no-notify", false, "Don't refresh the service after changing programs, for batches ended with 'timekeep refresh'")
+	// Applied by Execute, registered here for help and validation
+	rootCmd.PersistentFlags().Bool(jsonErrorsFlag, false, "Print errors as JSON objects on stderr, with distinct exit codes per kind of failure")
+
 	wCmd := s.wakatimeIntegration()
 	wCmd.AddCommand(s.wakatimeStatus())
 	wCmd.AddCommand(s.wakatimeEnable())
@@ -33,8 +73,88 @@ func (s *CLIService) RootCmd() *cobra.Command {
 	wpCmd.AddCommand(s.wakapiEnable())
 	wpCmd.AddCommand(s.wakapiDisable())
 
+	configCmd := s.setConfigCmd()
+	configCmd.AddCommand(s.validateConfigCmd())
+
+	dbCmd := s.dbCmd()
+	dbCmd.AddCommand(s.recalcLifetimesCmd())
+	dbCmd.AddCommand(s.archiveCmd())
+
+	tokenCmd := s.tokenCmd()
+	tokenCmd.AddCommand(s.tokenCreateCmd())
+	tokenCmd.AddCommand(s.tokenListCmd())
+	tokenCmd.AddCommand(s.tokenRevokeCmd())
+
+	groupCmd := s.groupCmd()
+	groupCmd.AddCommand(s.groupCreateCmd())
+	groupCmd.AddCommand(s.groupAddCmd())
+	groupCmd.AddCommand(s.groupRemoveCmd())
+	groupCmd.AddCommand(s.groupDeleteCmd())
+	groupCmd.AddCommand(s.groupListCmd())
+
+	projectCmd := s.projectCmd()
+	projectCmd.AddCommand(s.projectEstimateCmd())
+	projectCmd.AddCommand(s.projectListCmd())
+	projectCmd.AddCommand(s.projectBurndownCmd())
+
+	teamCmd := s.teamCmd()
+	teamCmd.AddCommand(s.teamReportCmd())
+
+	kimaiCmd := s.kimaiCmd()
+	kimaiCmd.AddCommand(s.kimaiPushCmd())
+
+	harvestCmd := s.harvestCmd()
+	harvestCmd.AddCommand(s.harvestPushCmd())
+
+	webhookCmd := s.webhookCmd()
+	webhookCmd.AddCommand(s.webhookTestCmd())
+
+	exportCmd := s.exportCmd()
+	exportCmd.AddCommand(s.exportTimewCmd())
+	exportCmd.AddCommand(s.exportChartCmd())
+	exportCmd.AddCommand(s.exportReportCmd())
+	exportCmd.AddCommand(s.exportScoresCmd())
+
+	importCmd := s.importCmd()
+	importCmd.AddCommand(s.importCSVCmd())
+
+	profileCmd := s.profileCmd()
+	profileCmd.AddCommand(s.profileUseCmd())
+	profileCmd.AddCommand(s.profileListCmd())
+
+	svcCmd := s.serviceCmd()
+	svcCmd.AddCommand(s.serviceInstallCmd())
+	svcCmd.AddCommand(s.serviceUninstallCmd())
+	svcCmd.AddCommand(s.serviceStartCmd())
+	svcCmd.AddCommand(s.serviceStopCmd())
+
 	rootCmd.AddCommand(wCmd)
 	rootCmd.AddCommand(wpCmd)
+	rootCmd.AddCommand(dbCmd)
+	rootCmd.AddCommand(tokenCmd)
+	rootCmd.AddCommand(groupCmd)
+	rootCmd.AddCommand(projectCmd)
+	rootCmd.AddCommand(s.serverCmd())
+	rootCmd.AddCommand(s.syncCmd())
+	rootCmd.AddCommand(teamCmd)
+	rootCmd.AddCommand(kimaiCmd)
+	rootCmd.AddCommand(harvestCmd)
+	rootCmd.AddCommand(s.policyCmd())
+	rootCmd.AddCommand(s.digestCmd())
+	rootCmd.AddCommand(s.todayCmd())
+	rootCmd.AddCommand(s.monthCmd())
+	rootCmd.AddCommand(s.topCmd())
+	rootCmd.AddCommand(s.trayCmd())
+	rootCmd.AddCommand(s.statusbarCmd())
+	rootCmd.AddCommand(s.promptCmd())
+	rootCmd.AddCommand(s.compareCmd())
+	rootCmd.AddCommand(s.reportCmd())
+	rootCmd.AddCommand(s.queryCmd())
+	rootCmd.AddCommand(webhookCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(profileCmd)
+	rootCmd.AddCommand(svcCmd)
 	rootCmd.AddCommand(s.addProgramsCmd())
 	rootCmd.AddCommand(s.updateCmd())
 	rootCmd.AddCommand(s.removeProgramsCmd())
@@ -43,29 +163,105 @@ func (s *CLIService) RootCmd() *cobra.Command {
 	rootCmd.AddCommand(s.sessionHistoryCmd())
 	rootCmd.AddCommand(s.refreshCmd())
 	rootCmd.AddCommand(s.resetStatsCmd())
+	rootCmd.AddCommand(s.undoCmd())
 	rootCmd.AddCommand(s.statusServiceCmd())
+	rootCmd.AddCommand(s.pauseCmd())
+	rootCmd.AddCommand(s.resumeCmd())
+	rootCmd.AddCommand(s.incognitoCmd())
+	rootCmd.AddCommand(s.pingServiceCmd())
+	rootCmd.AddCommand(s.doctorCmd())
+	rootCmd.AddCommand(s.simulateCmd())
 	rootCmd.AddCommand(s.getActiveSessionsCmd())
 	rootCmd.AddCommand(s.getVersionCmd())
-	rootCmd.AddCommand(s.setConfigCmd())
+	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(s.statsCmd())
 
 	rootCmd.AddCommand(CompletionCmd)
+	markUsageErrors(rootCmd)
 
 	return rootCmd
 }
 
+// Environment variable read for the default --token value
+const remoteTokenEnv = "TIMEKEEP_REMOTE_TOKEN"
+
+// Selects the profile from --profile, the environment or else the profile switched to with 'timekeep profile use',
+// and the per-user scope when a Windows agent is registered, before the database and config are opened, as their
+// paths depend on them. Other flags are left for cobra to parse
+func selectProfile(args []string) error {
+	fs := pflag.NewFlagSet("profile", pflag.ContinueOnError)
+	fs.ParseErrorsWhitelist.UnknownFlags = true
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+
+	name := fs.String("profile", os.Getenv(profile.Env), "")
+	_ = fs.Parse(args) // Errors such as --help are reported by cobra
+	if *name == "" {
+		active, err := profile.Active()
+		if err != nil {
+			return err
+		}
+		*name = active
+	}
+
+	if err := profile.Set(*name); err != nil {
+		return err
+	}
+	detectUserScope()
+
+	return nil
+}
+
 func Execute() {
-	cliService, err := CLIServiceSetup()
-	if err != nil {
-		fmt.Printf("Failed to initialize CLI service: %v\n", err)
-		os.Exit(1)
+	jsonErrors := jsonErrorsRequested(os.Args[1:])
+
+	if err := selectProfile(os.Args[1:]); err != nil {
+		exitWithError("Invalid profile", usageError{err: err}, jsonErrors)
+	}
+
+	cliService := CreateCLIService(nil, nil, nil, nil, nil, &realServiceCommander{}, &realCommandExecutor{})
+	rootCmd := cliService.RootCmd()
+
+	// The prompt and status bar are mostly served from cache, opening the database only once they must read it
+	name := ""
+	if cmd, _, err := rootCmd.Find(os.Args[1:]); err == nil {
+		name = cmd.Name()
+	}
+	if err := cliService.Setup(name == "prompt" || name == "statusbar"); err != nil {
+		if name == "prompt" { // Failures print nothing in a shell prompt
+			return
+		}
+		exitWithError("Failed to initialize CLI service", err, jsonErrors)
 	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	if err := cliService.RootCmd().ExecuteContext(ctx); err != nil {
-		fmt.Printf("Command execution failed: %v\n", err)
-		os.Exit(1)
+	if jsonErrors {
+		// The JSON object replaces cobra's own error and usage output
+		rootCmd.SilenceErrors = true
+		rootCmd.SilenceUsage = true
+	}
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		exitWithError("Command execution failed", err, jsonErrors)
+	}
+}
+
+// Commands writing programs, sessions or config on this machine, which a remote service never reads
+var localOnlyCommands = []string{
+	"add", "update", "rm", "reset", "undo", "config", "import", "db",
+	"wakatime enable", "wakatime disable", "wakapi enable", "wakapi disable",
+	"group create", "group add", "group remove", "group delete",
+	"project estimate", "token create", "token revoke",
+}
+
+// Returns the path of cmd below the root when it is, or is under, one of localOnlyCommands
+func localOnlyPath(cmd *cobra.Command) string {
+	path := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	for _, local := range localOnlyCommands {
+		if path == local || strings.HasPrefix(path, local+" ") {
+			return path
+		}
 	}
+	return ""
 }
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/syncapi"
+)
+
+// Runs the sync server until ctx is done, merging sessions uploaded by other machines into this database's history
+// under their device labels. Clients authenticate with API tokens of the sync or admin scope
+func (s *CLIService) Serve(ctx context.Context, listen string) error {
+	if listen == "" {
+		listen = s.Config.ServerListen()
+	}
+	var certFile, keyFile string
+	if s.Config != nil {
+		certFile, keyFile = s.Config.Server.CertFile, s.Config.Server.KeyFile
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(syncapi.SessionsPath, s.handleSessions)
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("error opening sync server listener on %s: %w", listen, err)
+	}
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	stop := context.AfterFunc(ctx, func() { server.Close() })
+	defer stop()
+
+	if certFile != "" {
+		fmt.Printf("Sync server listening on %s (TLS)\n", listener.Addr())
+		err = server.ServeTLS(listener, certFile, keyFile)
+	} else {
+		fmt.Printf("Sync server listening on %s without TLS, serve it behind a TLS proxy or set server.cert_file and server.key_file\n", listener.Addr())
+		err = server.Serve(listener)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("sync server closed: %w", err)
+	}
+
+	fmt.Println("Sync server stopped")
+	return nil
+}
+
+// Accepts an upload of sessions from one device with POST, and returns sessions uploaded by other devices with GET
+func (s *CLIService) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tokenName, err := s.syncClient(r.Context(), r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		s.handlePull(w, r)
+		return
+	}
+
+	var upload syncapi.Upload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<20)).Decode(&upload); err != nil {
+		http.Error(w, "invalid upload", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.MergeUpload(r.Context(), upload)
+	if err != nil {
+		if errors.Is(err, syncapi.ErrInvalidUpload) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Printf("Failed to merge upload from %s: %v\n", upload.Device, err)
+		http.Error(w, "failed to store sessions", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("%s: received %d sessions from %s (token %s), %d new\n",
+		s.formatDateTime(time.Now(), true), len(upload.Sessions), upload.Device, tokenName, result.Accepted)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// Returns a page of the sessions uploaded by devices other than the caller's, after the caller's cursor
+func (s *CLIService) handlePull(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	cursor, err := strconv.ParseInt(query.Get(syncapi.CursorParam), 10, 64)
+	if err != nil && query.Get(syncapi.CursorParam) != "" {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+
+	page, err := syncapi.Log(r.Context(), s.SyncRepo, cursor, query.Get(syncapi.DeviceParam), syncapi.MaxPull)
+	if err != nil {
+		fmt.Printf("Failed to read sync log: %v\n", err)
+		http.Error(w, "failed to read sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// Returns the name of the API token a request was made with, provided its scope allows syncing
+func (s *CLIService) syncClient(ctx context.Context, r *http.Request) (string, error) {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return "", errors.New("missing API token")
+	}
+
+	apiToken, err := s.TokenRepo.GetAPITokenByHash(ctx, ipc.HashToken(token))
+	if err != nil {
+		return "", errors.New("invalid API token")
+	}
+	if apiToken.Scope != ipc.ScopeSync && apiToken.Scope != ipc.ScopeAdmin {
+		return "", fmt.Errorf("token scope %q does not allow syncing", apiToken.Scope)
+	}
+
+	touch := database.TouchAPITokenParams{LastUsedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true}, ID: apiToken.ID}
+	if err := s.TokenRepo.TouchAPIToken(ctx, touch); err != nil {
+		fmt.Printf("Failed to record use of token %s: %v\n", apiToken.Name, err)
+	}
+
+	return apiToken.Name, nil
+}
+
+// Stores an upload's sessions, skipping those uploaded before
+func (s *CLIService) MergeUpload(ctx context.Context, upload syncapi.Upload) (syncapi.UploadResult, error) {
+	return syncapi.Merge(ctx, s.SyncRepo, upload)
+}
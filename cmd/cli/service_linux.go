@@ -0,0 +1,293 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/profile"
+)
+
+// Linux service management through systemd units
+
+const serviceBinary = "timekeepd"
+
+// Linux services are already per user, each with its own socket and data
+func detectUserScope() {}
+
+// Unit name for the selected profile
+func serviceUnit() string {
+	return profile.Suffixed("timekeep") + ".service"
+}
+
+var unitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=Timekeep Process Tracker
+After=network.target
+
+[Service]
+Type=simple
+ExecStart={{.ExecStart}}
+Restart=always
+RestartSec=2s
+KillMode=process
+StandardOutput=journal
+StandardError=journal
+{{- if .System}}
+User={{.User}}
+Group={{.Group}}
+RuntimeDirectory=timekeep
+RuntimeDirectoryMode=1777
+RuntimeDirectoryPreserve=yes
+AmbientCapabilities=CAP_DAC_READ_SEARCH CAP_SYS_PTRACE
+CapabilityBoundingSet=CAP_DAC_READ_SEARCH CAP_SYS_PTRACE
+ProtectSystem=strict
+ProtectHome=read-only
+ReadWritePaths={{.DataDir}} {{.ConfigDir}} {{.WakaTimeDir}} -{{.WakaTimeConfig}}
+PrivateTmp=yes
+PrivateDevices=yes
+ProtectKernelTunables=yes
+ProtectKernelModules=yes
+ProtectKernelLogs=yes
+ProtectControlGroups=yes
+ProtectClock=yes
+ProtectHostname=yes
+RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6 AF_NETLINK
+RestrictNamespaces=yes
+{{- end}}
+NoNewPrivileges=yes
+LockPersonality=yes
+RestrictRealtime=yes
+RestrictSUIDSGID=yes
+SystemCallArchitectures=native
+
+[Install]
+WantedBy={{if .System}}multi-user.target{{else}}default.target{{end}}
+`))
+
+type unitConfig struct {
+	ExecStart string
+	System    bool
+	User      string
+	Group     string
+	DataDir   string
+	ConfigDir string
+	// wakatime-cli's own state and config, written when heartbeats go to WakaTime
+	WakaTimeDir    string
+	WakaTimeConfig string
+}
+
+// Writes a systemd unit for the service, reloads systemd and enables the unit. System units run as the invoking user
+func (s *CLIService) InstallService(binPath string, userUnit bool) error {
+	binPath, err := resolveServiceBinary(binPath)
+	if err != nil {
+		return err
+	}
+
+	if !userUnit && os.Geteuid() != 0 {
+		return fmt.Errorf("installing a system unit requires root, run with sudo or use --user")
+	}
+	if userUnit && os.Geteuid() == 0 {
+		return fmt.Errorf("user units must be installed as the user who will run them, not root")
+	}
+
+	owner, err := serviceOwner()
+	if err != nil {
+		return err
+	}
+
+	execStart := binPath
+	if strings.ContainsAny(execStart, " \t") {
+		execStart = strconv.Quote(execStart)
+	}
+	if args := profileArgs(); len(args) > 0 {
+		execStart += " " + strings.Join(args, " ")
+	}
+
+	cfg := unitConfig{
+		ExecStart: execStart,
+		System:    !userUnit,
+		User:      owner.Username,
+		DataDir:   filepath.Join(owner.HomeDir, ".local", "share", profile.Suffixed("timekeep")),
+		ConfigDir: filepath.Join(owner.HomeDir, ".config", profile.Suffixed("timekeep")),
+
+		WakaTimeDir:    filepath.Join(owner.HomeDir, ".wakatime"),
+		WakaTimeConfig: filepath.Join(owner.HomeDir, ".wakatime.cfg"),
+	}
+
+	group, err := user.LookupGroupId(owner.Gid)
+	if err != nil {
+		return fmt.Errorf("error looking up group for %s: %w", owner.Username, err)
+	}
+	cfg.Group = group.Name
+
+	// ReadWritePaths must exist before the unit starts, save the optional ("-") WakaTime config
+	for _, dir := range []string{cfg.DataDir, cfg.ConfigDir, cfg.WakaTimeDir} {
+		if err := ensureOwnedDir(dir, owner); err != nil {
+			return err
+		}
+	}
+
+	unitPath, err := unitFilePath(userUnit)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(unitPath), 0o755); err != nil {
+		return fmt.Errorf("error creating unit directory: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := unitTemplate.Execute(&buf, cfg); err != nil {
+		return fmt.Errorf("error rendering unit file: %w", err)
+	}
+	if err := os.WriteFile(unitPath, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("error writing unit file: %w", err)
+	}
+
+	if err := s.systemctl(userUnit, "daemon-reload"); err != nil {
+		return err
+	}
+	if err := s.systemctl(userUnit, "enable", serviceUnit()); err != nil {
+		return err
+	}
+
+	fmt.Printf("Unit written to %s and enabled\n", unitPath)
+	if userUnit {
+		if _, err := os.Stat(ipc.SocketDir); err != nil {
+			fmt.Printf("Warning: %s does not exist; user units cannot create it, so it must be created and owned by %s before starting\n", ipc.SocketDir, owner.Username)
+		}
+	}
+	fmt.Println("Customize with 'systemctl edit" + userFlag(userUnit) + " " + serviceUnit() + "', start with 'timekeep service start" + userFlag(userUnit) + "'")
+
+	return nil
+}
+
+// Disables and stops the unit, then removes its unit file
+func (s *CLIService) UninstallService(userUnit bool) error {
+	unitPath, err := unitFilePath(userUnit)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(unitPath); err != nil {
+		return fmt.Errorf("service unit not installed at %s", unitPath)
+	}
+
+	if err := s.systemctl(userUnit, "disable", "--now", serviceUnit()); err != nil {
+		return err
+	}
+	if err := os.Remove(unitPath); err != nil {
+		return fmt.Errorf("error removing unit file: %w", err)
+	}
+	if err := s.systemctl(userUnit, "daemon-reload"); err != nil {
+		return err
+	}
+
+	fmt.Printf("Service unit %s removed\n", unitPath)
+	return nil
+}
+
+// Starts the installed unit
+func (s *CLIService) StartService(userUnit bool) error {
+	if err := s.systemctl(userUnit, "start", serviceUnit()); err != nil {
+		return err
+	}
+
+	fmt.Println("Service started")
+	return nil
+}
+
+// Stops the running unit
+func (s *CLIService) StopService(userUnit bool) error {
+	if err := s.systemctl(userUnit, "stop", serviceUnit()); err != nil {
+		return err
+	}
+
+	fmt.Println("Service stopped")
+	return nil
+}
+
+// Runs systemctl against the system or user manager
+func (s *CLIService) systemctl(userUnit bool, args ...string) error {
+	if userUnit {
+		args = append([]string{"--user"}, args...)
+	}
+
+	if _, err := s.CmdExe.RunCommand(context.Background(), "systemctl", args...); err != nil {
+		return fmt.Errorf("error running systemctl %s: %w", strings.Join(args, " "), err)
+	}
+
+	return nil
+}
+
+// Returns path to the unit file for system or user installs
+func unitFilePath(userUnit bool) (string, error) {
+	if !userUnit {
+		return filepath.Join("/etc/systemd/system", serviceUnit()), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".config", "systemd", "user", serviceUnit()), nil
+}
+
+// User the service will run as; the sudo caller when run through sudo
+func serviceOwner() (*user.User, error) {
+	if name := os.Getenv("SUDO_USER"); name != "" && name != "root" {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return nil, fmt.Errorf("error looking up user %s: %w", name, err)
+		}
+		return u, nil
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		return nil, fmt.Errorf("error getting current user: %w", err)
+	}
+
+	return u, nil
+}
+
+// Creates directory if missing, handing ownership to the service user when running as root
+func ensureOwnedDir(dir string, owner *user.User) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating directory %s: %w", dir, err)
+	}
+
+	if os.Geteuid() != 0 {
+		return nil
+	}
+
+	uid, err := strconv.Atoi(owner.Uid)
+	if err != nil {
+		return fmt.Errorf("invalid uid for %s: %w", owner.Username, err)
+	}
+	gid, err := strconv.Atoi(owner.Gid)
+	if err != nil {
+		return fmt.Errorf("invalid gid for %s: %w", owner.Username, err)
+	}
+
+	if err := os.Chown(dir, uid, gid); err != nil {
+		return fmt.Errorf("error setting owner of %s: %w", dir, err)
+	}
+
+	return nil
+}
+
+func userFlag(userUnit bool) string {
+	if userUnit {
+		return " --user"
+	}
+	return ""
+}
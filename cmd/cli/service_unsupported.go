@@ -0,0 +1,25 @@
+//go:build !windows && !linux
+
+package main
+
+import "fmt"
+
+const serviceBinary = "timekeep-service"
+
+func detectUserScope() {}
+
+func (s *CLIService) InstallService(binPath string, userUnit bool) error {
+	return fmt.Errorf("service installation not supported on this platform")
+}
+
+func (s *CLIService) UninstallService(userUnit bool) error {
+	return fmt.Errorf("service removal not supported on this platform")
+}
+
+func (s *CLIService) StartService(userUnit bool) error {
+	return fmt.Errorf("service start not supported on this platform")
+}
+
+func (s *CLIService) StopService(userUnit bool) error {
+	return fmt.Errorf("service stop not supported on this platform")
+}
@@ -0,0 +1,184 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/profile"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Windows service management through the Service Control Manager
+
+const (
+	serviceDescription = "Timekeep process activity tracker"
+	serviceBinary      = "timekeep-service.exe"
+)
+
+// Service name for the selected profile
+func serviceName() string {
+	return profile.Suffixed("Timekeep")
+}
+
+// Registers the Timekeep service with the SCM, with automatic start and restart-on-failure recovery actions
+func (s *CLIService) InstallService(binPath string, userUnit bool) error {
+	if userUnit {
+		return s.installAgent(binPath)
+	}
+
+	binPath, err := resolveServiceBinary(binPath)
+	if err != nil {
+		return err
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager (are you running as Administrator?): %w", err)
+	}
+	defer m.Disconnect()
+
+	if existing, err := m.OpenService(serviceName()); err == nil {
+		existing.Close()
+		return fmt.Errorf("service %s already installed", serviceName())
+	}
+
+	// Only the installing user, besides SYSTEM and Administrators, may reach the service's pipe
+	owner := ipc.CurrentUserSID()
+	if owner == "" {
+		return fmt.Errorf("failed to read the current user's SID")
+	}
+	args := append(profileArgs(), "--owner", owner)
+
+	service, err := m.CreateService(serviceName(), binPath, mgr.Config{
+		DisplayName: serviceName(),
+		Description: serviceDescription,
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer service.Close()
+
+	recovery := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 60 * time.Second},
+	}
+	if err := service.SetRecoveryActions(recovery, uint32((24 * time.Hour).Seconds())); err != nil {
+		fmt.Printf("Warning: Failed to set service recovery actions: %v\n", err)
+	}
+
+	fmt.Printf("Service %s installed (%s)\n", serviceName(), binPath)
+	return nil
+}
+
+// Stops the service if running, and removes it from the SCM
+func (s *CLIService) UninstallService(userUnit bool) error {
+	if userUnit {
+		return s.uninstallAgent()
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager (are you running as Administrator?): %w", err)
+	}
+	defer m.Disconnect()
+
+	service, err := m.OpenService(serviceName())
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName())
+	}
+	defer service.Close()
+
+	if status, err := service.Query(); err == nil && status.State != svc.Stopped {
+		if err := stopAndWait(service); err != nil {
+			return err
+		}
+	}
+
+	if err := service.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service: %w", err)
+	}
+
+	fmt.Printf("Service %s uninstalled\n", serviceName())
+	return nil
+}
+
+// Starts the installed service
+func (s *CLIService) StartService(userUnit bool) error {
+	if userUnit {
+		return s.startAgent()
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	service, err := m.OpenService(serviceName())
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName())
+	}
+	defer service.Close()
+
+	if err := service.Start(); err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+
+	fmt.Printf("Service %s started\n", serviceName())
+	return nil
+}
+
+// Stops the running service, waiting for it to report stopped
+func (s *CLIService) StopService(userUnit bool) error {
+	if userUnit {
+		return s.stopAgent()
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	service, err := m.OpenService(serviceName())
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName())
+	}
+	defer service.Close()
+
+	if err := stopAndWait(service); err != nil {
+		return err
+	}
+
+	fmt.Printf("Service %s stopped\n", serviceName())
+	return nil
+}
+
+// Sends a stop control and polls until the service stops or the timeout passes
+func stopAndWait(service *mgr.Service) error {
+	status, err := service.Control(svc.Stop)
+	if err != nil {
+		return fmt.Errorf("failed to stop service: %w", err)
+	}
+
+	timeout := time.Now().Add(15 * time.Second)
+	for status.State != svc.Stopped {
+		if time.Now().After(timeout) {
+			return fmt.Errorf("timed out waiting for service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+
+		status, err = service.Query()
+		if err != nil {
+			return fmt.Errorf("failed to query service status: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Formats of 'timekeep statusbar'
+const (
+	StatusbarWaybar   = "waybar"   // JSON object of a custom module with "return-type": "json"
+	StatusbarPolybar  = "polybar"  // Line of a custom/script module, colored by polybar's format tags
+	StatusbarI3blocks = "i3blocks" // Full text, short text and color lines of a block
+	StatusbarText     = "text"     // Plain line, for any other bar
+)
+
+// Default time a status is reused by 'timekeep statusbar' before it's read from the database again
+const DefaultStatusbarCache = 5 * time.Second
+
+// Color of the polybar and i3blocks output while an alert stands. Waybar styles by class instead
+const statusbarAlertColor = "#e06c75"
+
+// Status cached between runs of 'timekeep statusbar', as of At
+type statusbarCache struct {
+	At     time.Time  `json:"at"`
+	Status TrayStatus `json:"status"`
+}
+
+// Prints the running program, its elapsed time and the time recorded today in the format a status bar module expects.
+// Bars run it every few seconds, so the status is cached for maxAge and aged from the cache meanwhile; a maxAge of 0
+// reads it fresh every time
+func (s *CLIService) ShowStatusbar(ctx context.Context, format string, maxAge time.Duration) error {
+	if format == "" {
+		format = StatusbarText
+	}
+	switch format {
+	case StatusbarWaybar, StatusbarPolybar, StatusbarI3blocks, StatusbarText:
+	default:
+		return fmt.Errorf("unknown format %q, use waybar, polybar, i3blocks or text", format)
+	}
+
+	status, err := s.cachedTrayStatus(ctx, time.Now(), maxAge)
+	if err != nil {
+		return err
+	}
+	fmt.Println(statusbarOutput(format, status))
+	return nil
+}
+
+// Returns the status as of now, from the cache when it's younger than maxAge and from the database otherwise, which is
+// only then opened. Failing to read or write the cache isn't an error: the status is simply read from the database
+func (s *CLIService) cachedTrayStatus(ctx context.Context, now time.Time, maxAge time.Duration) (TrayStatus, error) {
+	if maxAge > 0 {
+		if cached, ok := readStatusCache(); ok {
+			if age := now.Sub(cached.At); age >= 0 && age < maxAge && sameDay(cached.At, now, s.location()) {
+				return cached.aged(now), nil
+			}
+		}
+	}
+
+	if err := s.connect(); err != nil {
+		return TrayStatus{}, err
+	}
+	status, err := s.TrayStatus(ctx, now)
+	if err != nil {
+		return TrayStatus{}, err
+	}
+	if maxAge > 0 {
+		writeStatusCache(statusbarCache{At: now, Status: status})
+	}
+	return status, nil
+}
+
+// Returns the cached status as of now, counting the time since it was cached towards the running sessions
+func (c statusbarCache) aged(now time.Time) TrayStatus {
+	status := c.Status
+	age := now.Sub(c.At)
+	if age <= 0 {
+		return status
+	}
+	if status.Program != "" {
+		status.Elapsed += age
+		if _, ok := status.Goals[status.Category]; ok {
+			status.Goals = maps.Clone(status.Goals)
+			status.Goals[status.Category] += age
+		}
+	}
+	status.Today += age * time.Duration(len(status.Running))
+	return status
+}
+
+// Reads the cached status, reporting false when there's none
+func readStatusCache() (statusbarCache, bool) {
+	var cached statusbarCache
+	path, err := statusbarCachePath()
+	if err != nil {
+		return cached, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &cached) != nil || cached.At.IsZero() {
+		return cached, false
+	}
+	return cached, true
+}
+
+// Replaces the cached status, leaving the cache as it was when it can't be written
+func writeStatusCache(cached statusbarCache) {
+	path, err := statusbarCachePath()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(cached)
+	if err != nil || os.MkdirAll(filepath.Dir(path), 0o750) != nil {
+		return
+	}
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if os.WriteFile(tmp, data, 0o600) == nil {
+		_ = os.Rename(tmp, path)
+	}
+}
+
+// Location of the statusbar cache, in the user's cache directory
+func statusbarCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "timekeep", "statusbar.json"), nil
+}
+
+// Reports whether a and b fall on the same day in loc
+func sameDay(a, b time.Time, loc *time.Location) bool {
+	return a.In(loc).Format(time.DateOnly) == b.In(loc).Format(time.DateOnly)
+}
+
+// Renders status in format: the running program and its elapsed time with the time recorded today, or only the latter
+// when nothing runs
+func statusbarOutput(format string, status TrayStatus) string {
+	text := formatSpent(status.Today)
+	short := text
+	if status.Program != "" {
+		short = fmt.Sprintf("%s %s", status.Program, formatSpent(status.Elapsed))
+		text = fmt.Sprintf("%s · %s", short, text)
+	}
+	alert := len(status.Alerts) > 0
+
+	switch format {
+	case StatusbarWaybar:
+		class := "idle"
+		if status.Program != "" {
+			class = "running"
+		}
+		if alert {
+			class = "alert"
+		}
+		tooltip := status.Title()
+		if details := status.Description(); details != "" {
+			tooltip += "\n" + details
+		}
+		// Waybar reads text and tooltip as Pango markup
+		data, _ := json.Marshal(map[string]string{
+			"text":    html.EscapeString(text),
+			"alt":     class,
+			"tooltip": html.EscapeString(tooltip),
+			"class":   class,
+		})
+		return string(data)
+	case StatusbarPolybar:
+		// Polybar reads %{...} as format tags
+		text = strings.ReplaceAll(text, "%{", "%%{")
+		if alert {
+			return fmt.Sprintf("%%{F%s}%s%%{F-}", statusbarAlertColor, text)
+		}
+		return text
+	case StatusbarI3blocks:
+		lines := []string{text, short}
+		if alert {
+			lines = append(lines, statusbarAlertColor)
+		}
+		return strings.Join(lines, "\n")
+	default:
+		return text
+	}
+}
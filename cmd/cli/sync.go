@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jms-guy/timekeep/internal/syncapi"
+)
+
+// Pushes this machine's new sessions to the configured sync server and pulls those of the other machines
+func (s *CLIService) SyncSessions(ctx context.Context) error {
+	if s.Config == nil {
+		return fmt.Errorf("no config loaded, set sync.server and sync.token")
+	}
+
+	client, err := syncapi.NewClient(s.Config.Sync)
+	if err != nil {
+		return err
+	}
+
+	result, err := client.Sync(ctx, s.SyncRepo, s.Config.DeviceName())
+	if err != nil {
+		return fmt.Errorf("sync with %s failed after pushing %d and pulling %d sessions: %w", s.Config.Sync.Server, result.Pushed, result.Pulled, err)
+	}
+
+	fmt.Printf("Synced with %s: pushed %d sessions, pulled %d\n", s.Config.Sync.Server, result.Pushed, result.Pulled)
+
+	if result.Pulled > 0 {
+		if err := s.notifyService(); err != nil {
+			return fmt.Errorf("sessions synced but failed to notify service: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/team"
+)
+
+// Sends the daily category totals not yet reported to the team endpoint. With dryRun, prints exactly what would be
+// sent instead, without sending or recording anything
+func (s *CLIService) ReportTeam(ctx context.Context, dryRun bool) error {
+	if !dryRun {
+		sent, err := team.Run(ctx, s.SyncRepo, s.Config, time.Now())
+		if err != nil {
+			return err
+		}
+		if sent == 0 {
+			fmt.Println("No completed days to report")
+			return nil
+		}
+		fmt.Printf("Reported %d days to %s\n", sent, s.Config.Team.Endpoint)
+		return nil
+	}
+
+	reports, _, err := team.Pending(ctx, s.SyncRepo, s.Config, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		fmt.Println("No completed days to report")
+		return nil
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, report := range reports {
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
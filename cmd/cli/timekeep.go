@@ -1,8 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/ipc"
 	"github.com/spf13/cobra"
 )
 
@@ -92,17 +95,32 @@ func (s *CLIService) removeProgramsCmd() *cobra.Command {
 }
 
 func (s *CLIService) getListcmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:     "ls",
 		Aliases: []string{"LS", "list", "List", "LIST"},
 		Short:   "Lists programs being tracked by service",
+		Long:    "With --stale, lists only programs with no session in the given span, such as 30d, and with --clean offers to stop tracking each of them",
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 
+			stale, _ := cmd.Flags().GetString("stale")
+			clean, _ := cmd.Flags().GetBool("clean")
+			if stale != "" {
+				return s.ListStalePrograms(ctx, stale, clean, cmd.InOrStdin())
+			}
+			if clean {
+				return fmt.Errorf("--clean requires --stale")
+			}
+
 			return s.GetList(ctx)
 		},
 	}
+
+	cmd.Flags().String("stale", "", "List programs with no session since this date or span, in any history --date format (ex. 30d, 2025-01-01)")
+	cmd.Flags().Bool("clean", false, "With --stale, ask whether to stop tracking each stale program")
+
+	return cmd
 }
 
 func (s *CLIService) infoCmd() *cobra.Command {
@@ -129,7 +147,7 @@ func (s *CLIService) sessionHistoryCmd() *cobra.Command {
 		Use:     "history",
 		Aliases: []string{"History", "HISTORY"},
 		Short:   "Shows session history",
-		Long:    "If no args given, shows previous 25 sessions. Program name may be given as argument to filter only those sessions. Flags may be given to filter further, with OR without program name",
+		Long:    "If no args given, shows previous 25 sessions. Program name may be given as argument to filter only those sessions. Flags may be given to filter further, with OR without program name. A limit of 0 shows every matching session, read from the database as it is printed",
 		Args:    cobra.RangeArgs(0, 1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
@@ -137,16 +155,26 @@ func (s *CLIService) sessionHistoryCmd() *cobra.Command {
 			date, _ := cmd.Flags().GetString("date")
 			start, _ := cmd.Flags().GetString("start")
 			end, _ := cmd.Flags().GetString("end")
+			device, _ := cmd.Flags().GetString("device")
 			limit, _ := cmd.Flags().GetInt64("limit")
+			includeArchive, _ := cmd.Flags().GetBool("include-archive")
+			merged, _ := cmd.Flags().GetBool("merged")
+			if relative, _ := cmd.Flags().GetBool("relative"); relative {
+				s.UseRelativeTimes()
+			}
 
-			return s.GetSessionHistory(ctx, args, date, start, end, limit)
+			return s.GetSessionHistory(ctx, args, date, start, end, device, limit, includeArchive, merged)
 		},
 	}
 
-	cmd.Flags().String("date", "", "Filter session history by date")
-	cmd.Flags().String("start", "", "Filters session history by adding a starting date")
-	cmd.Flags().String("end", "", "Filters session history by adding an ending date")
-	cmd.Flags().Int64("limit", 25, "Adjusts number limit of sessions shown")
+	cmd.Flags().String("date", "", "Filter session history by date (YYYY-MM-DD, YYYY-MM, today, yesterday, last <weekday>, 7d, 2w)")
+	cmd.Flags().String("start", "", "Filters session history by adding a starting date, in any --date format")
+	cmd.Flags().String("end", "", "Filters session history by adding an ending date, in any --date format")
+	cmd.Flags().String("device", "", "Filters session history by the device it was recorded on")
+	cmd.Flags().Int64("limit", 25, "Adjusts number limit of sessions shown, 0 for all")
+	cmd.Flags().Bool("include-archive", false, "Include sessions moved to the archive by 'db archive'")
+	cmd.Flags().Bool("merged", false, "Show a program run split by system sleep as one session")
+	cmd.Flags().Bool("relative", false, "Show session times as time elapsed, such as '2h ago', rather than timestamps")
 
 	return cmd
 }
@@ -189,6 +217,40 @@ func (s *CLIService) resetStatsCmd() *cobra.Command {
 	return cmd
 }
 
+func (s *CLIService) undoCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "undo",
+		Aliases: []string{"Undo", "UNDO"},
+		Short:   "Undo the last reset or remove",
+		Long:    "Restores the programs and session records deleted by the last reset or remove command, if it ran within the undo window (config undo_window, default 24h)",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.Undo(cmd.Context())
+		},
+	}
+}
+
+func (s *CLIService) doctorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "doctor",
+		Aliases: []string{"Doctor", "DOCTOR"},
+		Short:   "Diagnose common problems",
+		Long:    "Checks the service, IPC connection, database integrity and schema version, active sessions, config, enabled integrations and file permissions, printing a suggested fix for each failure",
+		Args:    cobra.NoArgs,
+		// Failures are already reported per check
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reconcile, _ := cmd.Flags().GetBool("reconcile")
+
+			return s.Doctor(cmd.Context(), reconcile)
+		},
+	}
+
+	cmd.Flags().Bool("reconcile", false, "Have the service align the active sessions stored in the database with the sessions it's tracking")
+
+	return cmd
+}
+
 func (s *CLIService) statusServiceCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:     "status",
@@ -196,9 +258,106 @@ func (s *CLIService) statusServiceCmd() *cobra.Command {
 		Short:   "Gets current OS state of Timekeep service",
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return s.StatusService()
+			if err := s.StatusService(); err != nil {
+				return err
+			}
+			fmt.Print(s.monitorStatus())
+			return nil
+		},
+	}
+}
+
+func (s *CLIService) pauseCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "pause",
+		Aliases: []string{"Pause", "PAUSE", "snooze"},
+		Short:   "Pause tracking until resumed, or for a while with --for",
+		Long:    "Stops the service's process monitoring and heartbeats. With --for, tracking resumes by itself once the time is up, even if the service restarts in between; without it, tracking stays paused until 'timekeep resume' or a service restart",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			duration, _ := cmd.Flags().GetDuration("for")
+			if duration < 0 {
+				return fmt.Errorf("--for must be positive")
+			}
+
+			return s.PauseService(duration)
+		},
+	}
+
+	cmd.Flags().Duration("for", 0, "Resume tracking after this long, such as 45m")
+
+	return cmd
+}
+
+func (s *CLIService) resumeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "resume",
+		Aliases: []string{"Resume", "RESUME"},
+		Short:   "Resume tracking after a pause",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.ResumeService()
+		},
+	}
+}
+
+func (s *CLIService) incognitoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:       "incognito on|off",
+		Aliases:   []string{"Incognito", "INCOGNITO"},
+		Short:     "Stop the service observing or recording anything, until turned off",
+		Long:      "While incognito is on, the service watches no processes, sends no heartbeats and ignores browser and editor reports, storing nothing about the time, not even that it was incognito. Sessions open when it's turned on are closed as of that moment. Incognito ends with 'timekeep incognito off', after --for, or when the service restarts",
+		ValidArgs: []string{"on", "off"},
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			duration, _ := cmd.Flags().GetDuration("for")
+			if args[0] == "off" && duration != 0 {
+				return fmt.Errorf("--for applies to 'incognito on'")
+			}
+			if duration < 0 {
+				return fmt.Errorf("--for must be positive")
+			}
+
+			return s.SetIncognito(args[0] == "on", duration)
+		},
+	}
+
+	cmd.Flags().Duration("for", 0, "Turn incognito off again after this long, such as 2h")
+
+	return cmd
+}
+
+func (s *CLIService) pingServiceCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "ping",
+		Aliases: []string{"Ping", "PING"},
+		Short:   "Check the running service is responsive and healthy",
+		Long:    "Sends a health request to the service, reporting its version, uptime, database connectivity, monitor state, the provider reading the desktop session on Linux and round-trip latency",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.PingService()
+		},
+	}
+}
+
+func (s *CLIService) simulateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "simulate <events.json>",
+		Short: "Replay synthetic process events without touching the database",
+		Long:  "Runs the service binary in simulation mode, feeding start/stop events from a JSON file through the session manager against an in-memory database, then prints the resulting sessions and lifetime totals. Programs listed in the file are tracked, otherwise the programs tracked locally are used",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			binPath, _ := cmd.Flags().GetString("bin")
+			verbose, _ := cmd.Flags().GetBool("verbose")
+
+			return s.Simulate(cmd.Context(), args[0], binPath, verbose)
 		},
 	}
+
+	cmd.Flags().String("bin", "", "Path to the service binary, defaults to the service executable next to this CLI")
+	cmd.Flags().BoolP("verbose", "v", false, "Include the session manager's debug log")
+
+	return cmd
 }
 
 func (s *CLIService) getActiveSessionsCmd() *cobra.Command {
@@ -215,11 +374,21 @@ func (s *CLIService) getActiveSessionsCmd() *cobra.Command {
 				return s.CleanActiveSessions(ctx)
 			}
 
+			if relative, _ := cmd.Flags().GetBool("relative"); relative {
+				s.UseRelativeTimes()
+			}
+			live, _ := cmd.Flags().GetBool("live")
+			if live {
+				return s.GetLiveActiveSessions()
+			}
+
 			return s.GetActiveSessions(ctx)
 		},
 	}
 
 	cmd.Flags().Bool("clean", false, "Clear all active sessions and reset the count")
+	cmd.Flags().Bool("live", false, "Query the running service for its in-memory session state (tracked PIDs, start and last seen times)")
+	cmd.Flags().Bool("relative", false, "With --live, show start and last seen times as time elapsed, such as '2h ago'")
 
 	return cmd
 }
@@ -351,8 +520,15 @@ func (s *CLIService) setConfigCmd() *cobra.Command {
 			project, _ := cmd.Flags().GetString("global_project")
 			interval, _ := cmd.Flags().GetString("poll_interval")
 			grace, _ := cmd.Flags().GetInt("poll_grace")
+			timezone, _ := cmd.Flags().GetString("timezone")
+			device, _ := cmd.Flags().GetString("device")
+			scope, _ := cmd.Flags().GetString("scope")
+			var display config.DisplayConfig
+			display.WeekStart, _ = cmd.Flags().GetString("week_start")
+			display.Clock, _ = cmd.Flags().GetString("clock")
+			display.DateFormat, _ = cmd.Flags().GetString("date_format")
 
-			return s.SetConfig(cliPath, server, project, interval, grace)
+			return s.SetConfig(cliPath, server, project, interval, timezone, device, scope, grace, display)
 		},
 	}
 
@@ -360,21 +536,954 @@ func (s *CLIService) setConfigCmd() *cobra.Command {
 	cmd.Flags().String("server", "", "Set server address for user's wakapi instance")
 	cmd.Flags().String("global_project", "", "Set global project variable for WakaTime/Wakapi data sorting")
 	cmd.Flags().String("poll_interval", "", "Set the polling interval for process monitoring for Linux version")
+	cmd.Flags().String("timezone", "", "Set IANA timezone used for displaying times and day boundaries (ex. 'America/New_York', 'system')")
+	cmd.Flags().String("device", "", "Set device label recorded on sessions, defaults to the machine hostname")
+	cmd.Flags().String("scope", "", "Set whose processes are tracked: 'user' (the service's own user) or 'shared' (every user)")
+	cmd.Flags().String("week_start", "", "Set the first day of the week used by 'this week' and 'last week' (ex. 'sunday'), default monday")
+	cmd.Flags().String("clock", "", "Set how times of day are shown: '24h' or '12h'")
+	cmd.Flags().String("date_format", "", "Set how dates are shown using YYYY, MM and DD (ex. 'DD/MM/YYYY'), default YYYY-MM-DD")
 	cmd.Flags().Int("poll_grace", 3, "Set grace period for PIDs missed via polling (process will only register as finished after 'poll_interval * poll_grace' ex. '1s * 3 = 3s')")
 
 	return cmd
 }
 
-func (s *CLIService) statsCmd() *cobra.Command {
+func (s *CLIService) validateConfigCmd() *cobra.Command {
 	return &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config file for problems",
+		Long:  "Checks the config file for unknown keys, invalid durations, addresses and URLs, and settings missing for enabled features, printing how to fix each",
+		Args:  cobra.NoArgs,
+		// Problems are already listed
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.ValidateConfig()
+		},
+	}
+}
+
+// Adds --device to a report command, counting only the sessions recorded on one device
+func (s *CLIService) deviceFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&s.Device, "device", "", "Count only the sessions recorded on one device, by the label set with 'config --device'")
+}
+
+func (s *CLIService) statsCmd() *cobra.Command {
+	cmd := &cobra.Command{
 		Use:     "stats",
 		Aliases: []string{"statistics", "STATS"},
 		Short:   "Display comprehensive statistics",
-		Long:    "Shows service status, active sessions, tracked programs, and integration status",
+		Long:    "Shows service status, service metrics, active sessions, tracked programs, and integration status. --device counts only the sessions of one device",
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 			return s.GetStats(ctx)
 		},
 	}
+
+	s.deviceFlag(cmd)
+
+	return cmd
+}
+
+func (s *CLIService) dbCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "db",
+		Aliases: []string{"DB"},
+		Short:   "Database maintenance commands",
+	}
+}
+
+func (s *CLIService) recalcLifetimesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "recalc-lifetimes",
+		Aliases: []string{"recalc"},
+		Short:   "Recompute program lifetimes and session counts from session history",
+		Long:    "Recomputes lifetime totals and session counts from recorded session history in one transaction, fixing drift left by crashes, imports or interrupted writes, and prints those that changed. Accepts program names as arguments, else recalculates all programs",
+		Args:    cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			return s.RecalculateLifetimes(ctx, args)
+		},
+	}
+}
+
+func (s *CLIService) archiveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "archive",
+		Short: "Move old sessions into cold storage",
+		Long:  "Moves sessions that ended more than --months months ago out of session history into the archive table. Archived sessions still count towards lifetimes and can be viewed with 'history --include-archive'",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			months, _ := cmd.Flags().GetInt("months")
+
+			return s.ArchiveSessions(ctx, months)
+		},
+	}
+
+	cmd.Flags().Int("months", 12, "Archive sessions that ended more than this many months ago")
+
+	return cmd
+}
+
+func (s *CLIService) tokenCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "token",
+		Aliases: []string{"Token", "TOKEN", "tokens"},
+		Short:   "Manage API tokens for remote access",
+		Long:    "API tokens let integrations reach the service over remote access (--host) with least privilege: read tokens may only query service state, sync tokens may only upload sessions to 'timekeep server', admin tokens may do anything the remote token can",
+	}
+}
+
+func (s *CLIService) tokenCreateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create an API token",
+		Long:  "Creates an API token and prints it once. Only a hash of it is stored, so a lost token must be revoked and created again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			scope, _ := cmd.Flags().GetString("scope")
+
+			return s.CreateToken(cmd.Context(), args[0], scope)
+		},
+	}
+
+	cmd.Flags().String("scope", ipc.ScopeRead, "Token scope: read (query only), sync (upload to a sync server) or admin")
+
+	return cmd
+}
+
+func (s *CLIService) tokenListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List API tokens",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.ListTokens(cmd.Context())
+		},
+	}
+}
+
+func (s *CLIService) tokenRevokeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "revoke <name>...",
+		Aliases: []string{"rm"},
+		Short:   "Revoke API tokens",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.RevokeTokens(cmd.Context(), args)
+		},
+	}
+}
+
+func (s *CLIService) groupCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "group",
+		Aliases: []string{"Group", "GROUP", "groups"},
+		Short:   "Manage program groups",
+		Long:    "A program group, such as browsers of chrome, firefox and edge, adds up the time of its programs so reports, goals and limits can target them as a unit. Programs keep their own sessions and totals, and may be in several groups. Target a group with --group in 'timekeep report' and 'timekeep month', and with a group:<name> key in goals.daily and limits.daily in config",
+	}
+}
+
+func (s *CLIService) groupCreateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "create <name> <program>...",
+		Short: "Create a program group",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.CreateGroup(cmd.Context(), args[0], args[1:])
+		},
+	}
+}
+
+func (s *CLIService) groupAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add <name> <program>...",
+		Short: "Add programs to a group",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.AddToGroup(cmd.Context(), args[0], args[1:])
+		},
+	}
+}
+
+func (s *CLIService) groupRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <name> <program>...",
+		Short: "Take programs out of a group",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.RemoveFromGroup(cmd.Context(), args[0], args[1:])
+		},
+	}
+}
+
+func (s *CLIService) groupDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "delete <name>...",
+		Aliases: []string{"rm"},
+		Short:   "Delete program groups, leaving their programs tracked",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.DeleteGroups(cmd.Context(), args)
+		},
+	}
+}
+
+func (s *CLIService) groupListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List program groups",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.ListGroups(cmd.Context())
+		},
+	}
+}
+
+func (s *CLIService) projectCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "project",
+		Aliases: []string{"Project", "PROJECT", "projects"},
+		Short:   "Track projects against their estimates",
+		Long:    "A project gathers the time of the programs put in it with 'timekeep update --project'. Give a project an estimate of the time it should take, then follow the time recorded against it and how the estimate burns down",
+	}
+}
+
+func (s *CLIService) projectEstimateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "estimate <project> [duration]",
+		Short: "Set or clear the time a project is estimated to take",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			remove, _ := cmd.Flags().GetBool("clear")
+			if !remove && len(args) < 2 {
+				return usageError{err: errors.New("no estimate given, such as 40h, or --clear to clear it")}
+			}
+			estimate := ""
+			if len(args) > 1 {
+				estimate = args[1]
+			}
+			return s.SetEstimate(cmd.Context(), args[0], estimate, remove)
+		},
+	}
+
+	cmd.Flags().Bool("clear", false, "Clear the project's estimate")
+
+	return cmd
+}
+
+func (s *CLIService) projectListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List projects with their time against their estimates",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			includeArchive, _ := cmd.Flags().GetBool("include-archive")
+			return s.ListProjects(cmd.Context(), includeArchive)
+		},
+	}
+
+	cmd.Flags().Bool("include-archive", false, "Include sessions moved to the archive by 'db archive'")
+
+	return cmd
+}
+
+func (s *CLIService) projectBurndownCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "burndown <project>",
+		Short: "Show how a project's estimate burns down over time",
+		Long:  "Prints, for each day or week from the project's first session to now, the time spent on it, the total so far and the time left of its estimate, then how soon the estimate runs out at the pace so far",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			by, _ := cmd.Flags().GetString("by")
+			includeArchive, _ := cmd.Flags().GetBool("include-archive")
+			return s.ShowBurndown(cmd.Context(), args[0], by, includeArchive)
+		},
+	}
+
+	cmd.Flags().String("by", BurndownWeek, "Period of each row: day or week")
+	cmd.Flags().Bool("include-archive", false, "Include sessions moved to the archive by 'db archive'")
+
+	return cmd
+}
+
+func (s *CLIService) profileCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "profile",
+		Aliases: []string{"Profile", "PROFILE", "profiles"},
+		Short:   "Switch between named profiles",
+		Long:    "Each profile, such as work or personal, has its own database, config, integrations, goals and service. Switch to one with 'timekeep profile use' to have commands use it without --profile, and have only its service record while the others stand by",
+	}
+}
+
+func (s *CLIService) profileUseCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "use <name>",
+		Short: "Switch to a profile, for later commands and for recording",
+		Long:  "Makes the profile the one commands use when given no --profile or TIMEKEEP_PROFILE, and the one recording: the services of other profiles close their active sessions and stand by until switched back to. Use 'default' for the profile without a name",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.UseProfile(args[0])
+		},
+	}
+}
+
+func (s *CLIService) profileListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List profiles, the one in use and the state of their services",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.ListProfiles()
+		},
+	}
+}
+
+func (s *CLIService) serverCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "server",
+		Aliases: []string{"Server", "SERVER"},
+		Short:   "Run a sync server that other machines upload sessions to",
+		Long:    "Runs in the foreground, accepting sessions uploaded by other machines and merging them into this machine's database under their device labels, so history and stats cover every machine (filter with --device). Clients authenticate with API tokens of the sync or admin scope, see 'timekeep token create'",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listen, _ := cmd.Flags().GetString("listen")
+
+			return s.Serve(cmd.Context(), listen)
+		},
+	}
+
+	cmd.Flags().String("listen", "", "Address to listen on, overriding server.listen in config (default :7790)")
+
+	return cmd
+}
+
+func (s *CLIService) syncCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "sync",
+		Aliases: []string{"Sync", "SYNC"},
+		Short:   "Push and pull sessions through the sync server",
+		Long:    "Pushes sessions recorded on this machine since the last sync to the sync server in config (sync.server), then pulls the sessions other machines pushed, adding them to history under their device labels. Sessions carry IDs derived from this machine's device ID, so repeated or interrupted syncs never duplicate them. The service also syncs every sync.interval when set",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.SyncSessions(cmd.Context())
+		},
+	}
+}
+
+func (s *CLIService) teamCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "team",
+		Aliases: []string{"Team", "TEAM"},
+		Short:   "Team reporting of aggregated daily totals",
+		Long:    "When enabled in config (team.enabled, team.endpoint), each completed day's total time per category is posted to the team endpoint, with no program names or times. The service reports in the background; these commands preview or send reports on demand",
+	}
+}
+
+func (s *CLIService) teamReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Send daily totals not yet reported to the team endpoint",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+			return s.ReportTeam(cmd.Context(), dryRun)
+		},
+	}
+
+	cmd.Flags().Bool("dry-run", false, "Print the reports that would be sent, one JSON object per day, without sending them")
+
+	return cmd
+}
+
+func (s *CLIService) kimaiCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "kimai",
+		Aliases: []string{"Kimai", "KIMAI"},
+		Short:   "Push completed sessions to Kimai as timesheets",
+		Long:    "When enabled in config (kimai.enabled, kimai.server, kimai.token), this machine's completed sessions are pushed to Kimai as timesheets, under the Kimai project and activity kimai.mappings gives them. Sessions left without a project or activity aren't pushed, and none is pushed twice. The service pushes every kimai.interval; these commands preview or push on demand",
+	}
+}
+
+func (s *CLIService) kimaiPushCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "push",
+		Short: "Push sessions not yet pushed to Kimai",
+		Long:  "Pushes this machine's completed sessions not yet pushed to Kimai. Until the first push, only sessions from today on are pushed, unless --since says otherwise",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			since, _ := cmd.Flags().GetString("since")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+			return s.PushKimai(cmd.Context(), since, dryRun)
+		},
+	}
+
+	cmd.Flags().String("since", "", "Push only sessions starting from this date, in any --date format, such as 2026-03-01")
+	cmd.Flags().Bool("dry-run", false, "Print the timesheets that would be pushed, one JSON object per session, without pushing them")
+
+	return cmd
+}
+
+func (s *CLIService) harvestCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "harvest",
+		Aliases: []string{"Harvest", "HARVEST"},
+		Short:   "Create Harvest time entries from completed sessions",
+		Long:    "When enabled in config (harvest.enabled, harvest.account_id, harvest.token), this machine's completed sessions are pushed to Harvest as time entries, one per session or, with harvest.mode set to daily, one per completed day and task, under the Harvest project and task harvest.mappings gives them. Each entry carries a key derived from what it covers, so pushing again never duplicates entries. The service pushes every harvest.interval; these commands preview or push on demand",
+	}
+}
+
+func (s *CLIService) harvestPushCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "push",
+		Short: "Create Harvest time entries not yet created",
+		Long:  "Creates the Harvest time entries of this machine's completed sessions from the last day pushed on. Until the first push, only sessions from today on are pushed (yesterday on in daily mode), unless --since says otherwise",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			since, _ := cmd.Flags().GetString("since")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+			return s.PushHarvest(cmd.Context(), since, dryRun)
+		},
+	}
+
+	cmd.Flags().String("since", "", "Push sessions starting from this date, in any --date format, such as 2026-03-01")
+	cmd.Flags().Bool("dry-run", false, "Print the time entries that would be created, one JSON object per entry, without creating them")
+
+	return cmd
+}
+
+func (s *CLIService) digestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "digest",
+		Aliases: []string{"Digest", "DIGEST"},
+		Short:   "Show or send the weekly digest",
+		Long:    "Prints the weekly digest: the week's total time, compared with the week before, its categories, top programs and how programs with a daily limit kept to it. With digest.enabled set in config, the service sends the previous week's digest every digest.day at digest.time, as a desktop notification (digest.desktop), an email (digest.email) or both",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			week, _ := cmd.Flags().GetString("week")
+			send, _ := cmd.Flags().GetBool("send")
+
+			return s.ShowDigest(cmd.Context(), week, send)
+		},
+	}
+
+	cmd.Flags().String("week", "last week", "Week to summarize, any date within it such as \"this week\" or 2026-03-09")
+	cmd.Flags().Bool("send", false, "Send the digest to the destinations in config instead of printing it")
+
+	return cmd
+}
+
+func (s *CLIService) todayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "today",
+		Aliases: []string{"Today", "TODAY"},
+		Short:   "Show time recorded today against goals and limits",
+		Long:    "Prints the time recorded so far today, including sessions still running, by category and program. Categories with a daily goal (goals.daily in config) and programs with a daily limit (limits.daily) show how they stand against it, followed by the streaks of consecutive days meeting each goal or keeping under each limit, and the achievements long streaks have earned",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.ShowToday(cmd.Context())
+		},
+	}
+
+	s.deviceFlag(cmd)
+
+	return cmd
+}
+
+func (s *CLIService) statusbarCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "statusbar",
+		Aliases: []string{"Statusbar", "STATUSBAR"},
+		Short:   "Print the tracking status for a status bar module",
+		Long:    "Prints the running program, its elapsed time and the time recorded today in the format of a waybar, polybar or i3blocks module, or as plain text. Meant to be run by the bar every few seconds: the status is cached for --cache and read from the database again after",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			cache, _ := cmd.Flags().GetDuration("cache")
+			return s.ShowStatusbar(cmd.Context(), format, cache)
+		},
+	}
+
+	cmd.Flags().String("format", StatusbarText, "Output format: waybar, polybar, i3blocks or text")
+	cmd.Flags().Duration("cache", DefaultStatusbarCache, "How long a status is reused, 0 to read it fresh every time")
+
+	return cmd
+}
+
+func (s *CLIService) promptCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "prompt",
+		Aliases: []string{"Prompt", "PROMPT"},
+		Short:   "Print the tracking status for a shell prompt module",
+		Long:    "Prints the metric set by prompt.show in config for a starship custom module or an oh-my-posh command segment: the running session (default), the time recorded today, or progress towards a daily goal. Prints nothing when there's nothing to show. Stays within prompt.budget (50ms by default), showing the last cached status, or nothing, when the database is slower",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			style, _ := cmd.Flags().GetString("style")
+			return s.ShowPrompt(cmd.Context(), style)
+		},
+	}
+
+	cmd.Flags().String("style", PromptStarship, "Prompt the output is for: starship or oh-my-posh")
+
+	return cmd
+}
+
+func (s *CLIService) topCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "top",
+		Aliases: []string{"Top", "TOP"},
+		Short:   "Rank today's programs by time, live with --live",
+		Long:    "Ranks today's programs by the time recorded, including running sessions, with each one's share, running session and a bar. With --live, the ranking is redrawn every --interval until stopped with Ctrl+C, running sessions read from the service's in-memory state",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			live, _ := cmd.Flags().GetBool("live")
+			interval, _ := cmd.Flags().GetDuration("interval")
+			limit, _ := cmd.Flags().GetInt("limit")
+			return s.ShowTop(cmd.Context(), live, interval, limit)
+		},
+	}
+
+	cmd.Flags().Bool("live", false, "Keep redrawing the ranking until stopped")
+	cmd.Flags().Duration("interval", DefaultTopInterval, "How often the live ranking is redrawn")
+	cmd.Flags().Int("limit", DefaultTopLimit, "Most programs listed")
+	s.deviceFlag(cmd)
+
+	return cmd
+}
+
+func (s *CLIService) trayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "tray",
+		Aliases: []string{"Tray", "TRAY"},
+		Short:   "Show tracking in the desktop panel's tray",
+		Long:    "Linux - Shows an icon in the panel's tray through StatusNotifierItem (AppIndicator) until stopped: its tooltip has the running program, the time recorded today and the goals and limits reached, and clicking it shows the same as a notification. When a category meets its daily goal (goals.daily in config) or a program passes its daily limit (limits.daily), the icon gets a badge and a desktop notification is sent. Start it with the desktop session, such as from an autostart entry",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			interval, _ := cmd.Flags().GetDuration("interval")
+			return s.RunTray(cmd.Context(), interval)
+		},
+	}
+
+	cmd.Flags().Duration("interval", DefaultTrayInterval, "How often the tray refreshes")
+
+	return cmd
+}
+
+func (s *CLIService) monthCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "month [YYYY-MM]",
+		Aliases: []string{"Month", "MONTH"},
+		Short:   "Show a calendar of time recorded each day of a month",
+		Long:    "Prints a calendar of the month given, the current one by default, with the time recorded on each day shaded from the least to the busiest day. Weeks start on display.week_start. --program, --category or --group count only the time of that program, category or program group",
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			program, _ := cmd.Flags().GetString("program")
+			category, _ := cmd.Flags().GetString("category")
+			group, _ := cmd.Flags().GetString("group")
+
+			month := ""
+			if len(args) > 0 {
+				month = args[0]
+			}
+			return s.ShowMonth(cmd.Context(), month, program, category, group)
+		},
+	}
+
+	cmd.Flags().String("program", "", "Count only the time of one program")
+	cmd.Flags().String("category", "", "Count only the time of the programs in a category")
+	cmd.Flags().String("group", "", "Count only the time of the programs in a group")
+	s.deviceFlag(cmd)
+	cmd.MarkFlagsMutuallyExclusive("program", "category", "group")
+
+	return cmd
+}
+
+func (s *CLIService) compareCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "compare",
+		Aliases: []string{"Compare", "COMPARE"},
+		Short:   "Compare time spent on projects over a window",
+		Long:    "Prints the time recorded for each project given, side by side, from --since up to --until: totals, each project's share of the time compared and of all time recorded, and a week by week trend. Sessions count towards the project of their program, set with 'timekeep update --project'",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			projects, _ := cmd.Flags().GetStringSlice("projects")
+			since, _ := cmd.Flags().GetString("since")
+			until, _ := cmd.Flags().GetString("until")
+			includeArchive, _ := cmd.Flags().GetBool("include-archive")
+
+			return s.CompareProjects(cmd.Context(), projects, since, until, includeArchive)
+		},
+	}
+
+	cmd.Flags().StringSlice("projects", nil, "Projects to compare, separated by commas")
+	cmd.Flags().String("since", "", "Start of the window, in any 'history --date' format such as 2024-01-01")
+	cmd.Flags().String("until", "", "End of the window, in any 'history --date' format, default now")
+	cmd.Flags().Bool("include-archive", false, "Include sessions moved to the archive by 'db archive'")
+	s.deviceFlag(cmd)
+
+	return cmd
+}
+
+func (s *CLIService) reportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "report",
+		Aliases: []string{"Report", "REPORT"},
+		Short:   "Print a report of recorded time",
+		Long:    "Prints the report picked by flag over sessions picked like those of 'timekeep history'. --distribution shows how the time of a program, category or project, or of every program, spreads over the hours of the day and the days of the week, as shares with sparklines and bars, over every session by default. --untracked shows how much of each workday, set by workday in the config, sessions cover and how much is left untracked, over this week by default. --workspaces shows how time splits over the workspaces or virtual desktops it was spent on, recorded on Linux while workspaces.track is set in config, under the labels workspaces.labels gives them. --productivity shows how much of each day's and week's time was productive or distracting by the ratings set under productivity in config, with a productivity pulse from 0 to 100 and, with productivity.weights or productivity.formula set, a daily score, over this week by default. --timesheet lists the time of each day and project, exact and rounded as rounding in config sets for billing, over this week by default. --group reports on a program group as a unit. --launch narrows any report to sessions started a given way, such as from a terminal or by a parent process, as recorded by the process monitor",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			distribution, _ := cmd.Flags().GetBool("distribution")
+			untracked, _ := cmd.Flags().GetBool("untracked")
+			workspaces, _ := cmd.Flags().GetBool("workspaces")
+			productive, _ := cmd.Flags().GetBool("productivity")
+			timesheet, _ := cmd.Flags().GetBool("timesheet")
+			date, _ := cmd.Flags().GetString("date")
+			start, _ := cmd.Flags().GetString("start")
+			end, _ := cmd.Flags().GetString("end")
+			program, _ := cmd.Flags().GetString("program")
+			category, _ := cmd.Flags().GetString("category")
+			project, _ := cmd.Flags().GetString("project")
+			group, _ := cmd.Flags().GetString("group")
+			launch, _ := cmd.Flags().GetString("launch")
+			includeArchive, _ := cmd.Flags().GetBool("include-archive")
+
+			switch {
+			case distribution:
+				return s.ShowDistribution(cmd.Context(), date, start, end, program, category, project, group, launch, includeArchive)
+			case untracked:
+				return s.ShowUntracked(cmd.Context(), date, start, end, program, category, project, group, launch, includeArchive)
+			case workspaces:
+				return s.ShowWorkspaces(cmd.Context(), date, start, end, program, category, project, group, launch, includeArchive)
+			case productive:
+				return s.ShowProductivity(cmd.Context(), date, start, end, program, category, project, group, launch, includeArchive)
+			case timesheet:
+				return s.ShowTimesheet(cmd.Context(), date, start, end, program, category, project, group, launch, includeArchive)
+			}
+			return errors.New("no report picked, use --distribution, --untracked, --workspaces, --productivity or --timesheet")
+		},
+	}
+
+	cmd.Flags().Bool("distribution", false, "Show how time spreads over hours of the day and days of the week")
+	cmd.Flags().Bool("untracked", false, "Show how much of each workday is tracked and untracked")
+	cmd.Flags().Bool("workspaces", false, "Show how time splits over workspaces or virtual desktops")
+	cmd.Flags().Bool("productivity", false, "Show the share of productive time and the productivity pulse of each day")
+	cmd.Flags().Bool("timesheet", false, "Show the exact and rounded time of each day and project")
+	cmd.Flags().String("date", "", "Report on a date or span, in any 'history --date' format such as 'last week'")
+	cmd.Flags().String("start", "", "Report from a starting date, in any --date format")
+	cmd.Flags().String("end", "", "Report up to an ending date, in any --date format")
+	cmd.Flags().String("program", "", "Report on one program")
+	cmd.Flags().String("category", "", "Report on the programs in a category")
+	cmd.Flags().String("project", "", "Report on the programs in a project")
+	cmd.Flags().String("group", "", "Report on the programs in a group, made with 'timekeep group create'")
+	cmd.Flags().String("launch", "", "Report on sessions started a given way: terminal, gui, terminal=<program>, parent=<program>, user=<name>, comma separated")
+	cmd.Flags().Bool("include-archive", false, "Include sessions moved to the archive by 'db archive'")
+	cmd.MarkFlagsMutuallyExclusive("program", "category", "project", "group")
+	s.deviceFlag(cmd)
+	cmd.MarkFlagsMutuallyExclusive("distribution", "untracked", "workspaces", "productivity", "timesheet")
+
+	return cmd
+}
+
+func (s *CLIService) queryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "query <sql>",
+		Aliases: []string{"Query", "QUERY"},
+		Short:   "Run a read-only SQL query against the database",
+		Long:    "Runs a SQL query against the local SQLite database, opened read-only so no statement can change it, and prints the rows as a table, CSV or JSON. Tables include tracked_programs, active_sessions and session_history; list them all with \"SELECT name FROM sqlite_master WHERE type = 'table'\". Times are stored in UTC",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+
+			return s.RunQuery(cmd.Context(), args[0], format)
+		},
+	}
+
+	cmd.Flags().String("format", FormatTable, "Output format: table, csv or json")
+
+	return cmd
+}
+
+func (s *CLIService) webhookCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "webhook",
+		Aliases: []string{"Webhook", "WEBHOOK", "webhooks"},
+		Short:   "Session event webhooks",
+		Long:    "The service sends session starts and ends to the endpoints under webhooks in config as they happen, as JSON, in the zapier or ifttt formats, or in a body built from a template",
+	}
+}
+
+func (s *CLIService) webhookTestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test [name]",
+		Short: "Send an example event to the configured webhooks",
+		Long:  "Sends an example session event to every webhook in config, or the one named (by its name, or #N for the Nth), whatever its filters, and reports how each endpoint answered. Use it to try out an endpoint or an automation reacting to it",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			event, _ := cmd.Flags().GetString("event")
+			program, _ := cmd.Flags().GetString("program")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+			return s.TestWebhooks(cmd.Context(), name, event, program, dryRun)
+		},
+	}
+
+	cmd.Flags().String("event", "end", "Event to send: start, or end for a 45 minute session ending now")
+	cmd.Flags().String("program", "example", "Program named in the event")
+	cmd.Flags().Bool("dry-run", false, "Print the requests that would be sent without sending them")
+
+	return cmd
+}
+
+func (s *CLIService) exportCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "export",
+		Aliases: []string{"Export", "EXPORT"},
+		Short:   "Export sessions for other tools",
+	}
+}
+
+func (s *CLIService) exportTimewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "timew [program]",
+		Aliases: []string{"timewarrior"},
+		Short:   "Export sessions as Timewarrior intervals",
+		Long:    "Writes sessions as Timewarrior intervals tagged with their program, category and project: as JSON like 'timew export' writes, as lines of Timewarrior's data files, or as a shell script recording each interval with 'timew track'. Sessions are picked like those of 'timekeep history', every one by default",
+		Args:    cobra.RangeArgs(0, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			date, _ := cmd.Flags().GetString("date")
+			start, _ := cmd.Flags().GetString("start")
+			end, _ := cmd.Flags().GetString("end")
+			includeArchive, _ := cmd.Flags().GetBool("include-archive")
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+
+			return s.ExportTimewarrior(cmd.Context(), args, date, start, end, includeArchive, format, output)
+		},
+	}
+
+	cmd.Flags().String("date", "", "Export sessions of a date (YYYY-MM-DD, YYYY-MM, today, yesterday, last <weekday>, 7d, 2w)")
+	cmd.Flags().String("start", "", "Export sessions from a starting date, in any --date format")
+	cmd.Flags().String("end", "", "Export sessions up to an ending date, in any --date format")
+	cmd.Flags().Bool("include-archive", false, "Include sessions moved to the archive by 'db archive'")
+	s.deviceFlag(cmd)
+	cmd.Flags().String("format", TimewJSON, "Output format: json, as 'timew export' writes, data, the lines of Timewarrior's data files, or track, a script of 'timew track' commands")
+	cmd.Flags().String("output", "", "File to write to instead of stdout")
+
+	return cmd
+}
+
+func (s *CLIService) exportChartCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "chart",
+		Short: "Export a chart of sessions as an SVG or PNG image",
+		Long:  "Renders a chart of sessions as an image for notes and reports, written to --output as SVG or, for a .png file, PNG, or as SVG to stdout. The timeline chart shows the sessions of a day as bars, one row per program colored by category, including sessions still running. The scores chart shows the daily productivity score set by productivity.weights and productivity.formula in config as a line, over the last 30 days by default",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chartType, _ := cmd.Flags().GetString("type")
+			day, _ := cmd.Flags().GetString("day")
+			includeArchive, _ := cmd.Flags().GetBool("include-archive")
+			output, _ := cmd.Flags().GetString("output")
+
+			return s.ExportChart(cmd.Context(), chartType, day, includeArchive, output)
+		},
+	}
+
+	cmd.Flags().String("type", ChartTimeline, "Chart to render: timeline or scores")
+	s.deviceFlag(cmd)
+	cmd.Flags().String("day", "", "Chart sessions of a day or span, in any 'history --date' format. Today by default, the last 30 days for scores")
+	cmd.Flags().Bool("include-archive", false, "Include sessions moved to the archive by 'db archive'")
+	cmd.Flags().StringP("output", "o", "", "Image file to write, PNG if it ends in .png, else SVG. SVG to stdout if unset")
+
+	return cmd
+}
+
+func (s *CLIService) exportReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Export a report with charts as a single HTML or Markdown file",
+		Long:  "Writes a report of the time recorded over a range, this week by default, as one self-contained file to share or mail: the total, charts of the time per day, the share of each category and the daily trend of the largest categories, and tables of categories and the top programs. HTML reports carry their charts inline as SVG, Markdown reports as base64 PNG images",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			date, _ := cmd.Flags().GetString("date")
+			start, _ := cmd.Flags().GetString("start")
+			end, _ := cmd.Flags().GetString("end")
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+
+			return s.ExportReport(cmd.Context(), date, start, end, format, output)
+		},
+	}
+
+	cmd.Flags().String("date", "", "Report on a date or span, in any 'history --date' format such as 'last week'")
+	cmd.Flags().String("start", "", "Report from a starting date, in any --date format")
+	cmd.Flags().String("end", "", "Report up to an ending date, in any --date format")
+	s.deviceFlag(cmd)
+	cmd.Flags().String("format", "", "Output format: html or markdown. By the extension of --output when unset, else html")
+	cmd.Flags().StringP("output", "o", "", "File to write to instead of stdout")
+
+	return cmd
+}
+
+func (s *CLIService) exportScoresCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scores",
+		Short: "Export the daily productivity score series as CSV or JSON",
+		Long:  "Writes a row per day over a range, the last 30 days by default, with the hours recorded, productive and distracting, the productivity pulse and the score computed by productivity.formula in config from productivity.weights, for charting or analysis in other tools",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			date, _ := cmd.Flags().GetString("date")
+			start, _ := cmd.Flags().GetString("start")
+			end, _ := cmd.Flags().GetString("end")
+			includeArchive, _ := cmd.Flags().GetBool("include-archive")
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+
+			return s.ExportScores(cmd.Context(), date, start, end, includeArchive, format, output)
+		},
+	}
+
+	cmd.Flags().String("date", "", "Export the days of a date or span, in any 'history --date' format such as 'this month'")
+	cmd.Flags().String("start", "", "Export from a starting date, in any --date format")
+	cmd.Flags().String("end", "", "Export up to an ending date, in any --date format")
+	cmd.Flags().Bool("include-archive", false, "Include sessions moved to the archive by 'db archive'")
+	cmd.Flags().String("format", FormatCSV, "Output format: csv or json")
+	s.deviceFlag(cmd)
+	cmd.Flags().StringP("output", "o", "", "File to write to instead of stdout")
+
+	return cmd
+}
+
+func (s *CLIService) importCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "import",
+		Aliases: []string{"Import", "IMPORT"},
+		Short:   "Import sessions from other tools",
+	}
+}
+
+func (s *CLIService) importCSVCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "csv <file>",
+		Short: "Import sessions from a CSV file, such as a spreadsheet export",
+		Long:  "Adds a session to history for each row of a CSV file, mapping its columns with --map by number from 1 or by header name: program, start, and end or duration. Times without an offset are read in the display timezone, durations as 1h30m or H:MM. Every row is checked first and nothing is imported if any is invalid, names an untracked program, or overlaps a recorded or imported session of the same program, unless --skip-overlaps is passed. Use --dry-run to preview the sessions",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mapping, _ := cmd.Flags().GetString("map")
+			delimiter, _ := cmd.Flags().GetString("delimiter")
+			timeFormat, _ := cmd.Flags().GetString("time-format")
+			header, _ := cmd.Flags().GetBool("header")
+			skipOverlaps, _ := cmd.Flags().GetBool("skip-overlaps")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+			return s.ImportCSV(cmd.Context(), args[0], mapping, delimiter, timeFormat, header, skipOverlaps, dryRun)
+		},
+	}
+
+	cmd.Flags().String("map", "program=1,start=2,end=3", "Columns of the session fields, as field=column pairs: program, start, and end or duration")
+	cmd.Flags().String("delimiter", ",", "Character separating columns")
+	cmd.Flags().String("time-format", "", "Go layout of start and end times, such as '02/01/2006 15:04'. Common ISO formats by default")
+	cmd.Flags().Bool("header", true, "The first row names the columns and is skipped")
+	cmd.Flags().Bool("skip-overlaps", false, "Import the other rows when some overlap sessions of the same program")
+	cmd.Flags().Bool("dry-run", false, "List the sessions that would be imported without importing them")
+
+	return cmd
+}
+
+func (s *CLIService) policyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "policy",
+		Aliases: []string{"Policy", "POLICY"},
+		Short:   "Show the managed tracking policy in force",
+		Long:    "When policy.source is set in config, the service fetches the policy file from it every policy.interval. The policy lists programs that are never tracked and the categories programs are filed under, which take precedence over local settings. If the source can't be reached, the copy fetched last stays in force",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.ShowPolicy()
+		},
+	}
+}
+
+func (s *CLIService) serviceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "service",
+		Aliases: []string{"Service", "SERVICE"},
+		Short:   "Install, uninstall, start or stop the Timekeep service",
+	}
+
+	cmd.PersistentFlags().Bool("user", false, "Manage a per-user instance: a systemd user unit on Linux, a login agent on Windows")
+	cmd.PersistentFlags().Bool("system", false, "Manage the system-wide service (default)")
+	cmd.MarkFlagsMutuallyExclusive("user", "system")
+
+	return cmd
+}
+
+func (s *CLIService) serviceInstallCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install the Timekeep service",
+		Long:  "Registers the Timekeep service to start automatically, restarting it on failure. On Windows this requires Administrator privileges, or with --user registers a per-user agent started at login that only tracks your own session. On Linux this writes a sandboxed systemd unit, runs daemon-reload and enables it; system units require root",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			binPath, _ := cmd.Flags().GetString("bin")
+			userUnit, _ := cmd.Flags().GetBool("user")
+
+			return s.InstallService(binPath, userUnit)
+		},
+	}
+
+	cmd.Flags().String("bin", "", "Path to the service binary, defaults to the service executable next to this CLI")
+
+	return cmd
+}
+
+func (s *CLIService) serviceUninstallCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "uninstall",
+		Aliases: []string{"remove"},
+		Short:   "Stop and uninstall the Timekeep service",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userUnit, _ := cmd.Flags().GetBool("user")
+
+			return s.UninstallService(userUnit)
+		},
+	}
+}
+
+func (s *CLIService) serviceStartCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "start",
+		Short: "Start the Timekeep service",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userUnit, _ := cmd.Flags().GetBool("user")
+
+			return s.StartService(userUnit)
+		},
+	}
+}
+
+func (s *CLIService) serviceStopCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the Timekeep service",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userUnit, _ := cmd.Flags().GetBool("user")
+
+			return s.StopService(userUnit)
+		},
+	}
 }
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/focus"
+	"github.com/jms-guy/timekeep/internal/productivity"
+	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/jms-guy/timekeep/internal/streaks"
+)
+
+// Prints the time recorded so far today, by category, program and program group, how it stands against daily goals and limits, and
+// the streaks those have built up, with the day's productivity when ratings are set
+func (s *CLIService) ShowToday(ctx context.Context) error {
+	now := time.Now()
+	span, err := dates.Parse("today", now, s.location(), s.dateOptions())
+	if err != nil {
+		return err
+	}
+
+	days, err := streaks.DayTotals(ctx, s.PrRepo, s.GroupRepo, s.HsRepo, span.Start, span.End, s.Device)
+	if err != nil {
+		return err
+	}
+	totals := days[0]
+
+	sessions, err := focus.Sessions(ctx, s.PrRepo, s.HsRepo, span.Start, span.End, s.Device)
+	if err != nil {
+		return err
+	}
+
+	// Sessions still running count from the later of their start and midnight
+	active, err := s.activeSessions(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting active sessions: %w", err)
+	}
+	if len(active) > 0 {
+		programs, err := s.PrRepo.GetAllPrograms(ctx)
+		if err != nil {
+			return fmt.Errorf("error getting programs: %w", err)
+		}
+		categories := make(map[string]string, len(programs))
+		for _, program := range programs {
+			categories[program.Name] = program.Category.String
+		}
+		members, err := s.GroupRepo.GetAllGroupMembers(ctx)
+		if err != nil {
+			return fmt.Errorf("error getting groups: %w", err)
+		}
+		for _, session := range active {
+			spent := now.Sub(dates.Later(session.StartTime, span.Start))
+			if spent <= 0 {
+				continue
+			}
+			sessions = append(sessions, focus.Session{
+				Program:  session.ProgramName,
+				Category: categories[session.ProgramName],
+				Start:    session.StartTime,
+				End:      now,
+			})
+
+			category := categories[session.ProgramName]
+			if category == "" {
+				category = streaks.Uncategorized
+			}
+			totals.Categories[category] += spent
+			totals.Programs[session.ProgramName] += spent
+			for _, member := range members {
+				if member.ProgramName == session.ProgramName {
+					totals.Groups[member.GroupName] += spent
+				}
+			}
+		}
+	}
+
+	// Goals, and the limits of groups, are listed even before any time counts towards them
+	if s.Config != nil {
+		for key := range s.Config.Goals.Daily {
+			group, isGroup := config.GroupTarget(key)
+			switch {
+			case s.Config.DailyGoal(key) == 0:
+			case isGroup:
+				totals.Groups[group] += 0
+			default:
+				totals.Categories[key] += 0
+			}
+		}
+		for key := range s.Config.Limits.Daily {
+			if group, ok := config.GroupTarget(key); ok && s.Config.DailyLimit(key) > 0 {
+				totals.Groups[group] += 0
+			}
+		}
+	}
+
+	var total time.Duration
+	for _, spent := range totals.Programs {
+		total += spent
+	}
+	fmt.Printf("Today, %s\n", s.formatDate(span.Start))
+	fmt.Printf("Total: %s\n", formatSpent(total))
+
+	if len(totals.Categories) > 0 {
+		fmt.Println("\nCategories:")
+		for _, category := range byTime(totals.Categories) {
+			spent := totals.Categories[category]
+			line := fmt.Sprintf(" • %s: %s", category, formatSpent(spent))
+			if goal := s.Config.DailyGoal(category); goal > 0 {
+				line += fmt.Sprintf(" of %s goal", formatSpent(goal))
+				if spent >= goal {
+					line += " ✓"
+				}
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if len(totals.Programs) > 0 {
+		fmt.Println("\nPrograms:")
+		for _, program := range byTime(totals.Programs) {
+			spent := totals.Programs[program]
+			line := fmt.Sprintf(" • %s: %s", program, formatSpent(spent))
+			if limit := s.Config.DailyLimit(program); limit > 0 {
+				line += fmt.Sprintf(" of %s limit", formatSpent(limit))
+				if spent > limit {
+					line += " (over)"
+				}
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if len(totals.Groups) > 0 {
+		fmt.Println("\nGroups:")
+		for _, group := range byTime(totals.Groups) {
+			spent := totals.Groups[group]
+			line := fmt.Sprintf(" • %s: %s", group, formatSpent(spent))
+			if goal := s.Config.DailyGoal(config.GroupPrefix + group); goal > 0 {
+				line += fmt.Sprintf(" of %s goal", formatSpent(goal))
+				if spent >= goal {
+					line += " ✓"
+				}
+			}
+			if limit := s.Config.DailyLimit(config.GroupPrefix + group); limit > 0 {
+				line += fmt.Sprintf(" of %s limit", formatSpent(limit))
+				if spent > limit {
+					line += " (over)"
+				}
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if score, ok := focus.Compute(s.Config, sessions, span.Start, span.End); ok {
+		fmt.Printf("\nFocus: %d/100\n", score.Score)
+		fmt.Printf(" • %s in blocks of %s or more, %s\n", formatSpent(score.Focused), formatSpent(s.Config.FocusMinBlock()), plural(int64(score.Switches), "context switch"))
+		for _, c := range score.Contexts {
+			fmt.Printf(" • %s: %s, averaging %s\n", c.Context, plural(int64(c.Blocks), "block"), formatSpent(c.Average))
+		}
+	}
+
+	if s.Config != nil && (len(s.Config.Productivity.Programs) > 0 || len(s.Config.Productivity.Categories) > 0) {
+		var sum productivity.Summary
+		for _, session := range sessions {
+			sum.Add(s.Config, session.Program, session.Category, dates.Earlier(session.End, span.End).Sub(dates.Later(session.Start, span.Start)))
+		}
+		if sum.Total > 0 {
+			fmt.Printf("\nProductivity: %d%% productive, pulse %d/100\n", sum.ProductivePercent(), sum.Pulse())
+		}
+	}
+
+	list, err := s.updateStreaks(ctx)
+	if err != nil {
+		return err
+	}
+	if len(list) > 0 {
+		fmt.Println("\nStreaks:")
+		for _, streak := range list {
+			fmt.Printf(" • %s\n", describeStreak(streak))
+		}
+	}
+	return nil
+}
+
+// Brings streaks of the configured goals and limits up to date, returning them
+func (s *CLIService) updateStreaks(ctx context.Context) ([]database.Streak, error) {
+	if s.Config == nil || (len(s.Config.Goals.Daily) == 0 && len(s.Config.Limits.Daily) == 0) {
+		return nil, nil
+	}
+
+	var list []database.Streak
+	err := s.TxRepo.WithTx(ctx, func(store repository.Store) error {
+		var err error
+		list, err = streaks.Update(ctx, store, s.Config, time.Now())
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error updating streaks: %w", err)
+	}
+	return list, nil
+}
+
+// Describes a streak's current and best run and the achievements it has earned, such as
+// "coding goal met: 12 days in a row (best 30) 🏆 7, 30 days"
+func describeStreak(streak database.Streak) string {
+	line := fmt.Sprintf("%s: %s in a row (best %d)", streaks.Describe(streak), plural(streak.Current, "day"), streak.Best)
+	if reached := streaks.Achievements(streak); len(reached) > 0 {
+		line += " 🏆"
+		for i, milestone := range reached {
+			if i > 0 {
+				line += ","
+			}
+			line += fmt.Sprintf(" %d", milestone)
+		}
+		line += " days"
+	}
+	return line
+}
+
+// Formats a count of unit, such as "1 day" or "3 context switches"
+func plural(n int64, unit string) string {
+	switch {
+	case n == 1:
+		return fmt.Sprintf("%d %s", n, unit)
+	case strings.HasSuffix(unit, "ch") || strings.HasSuffix(unit, "s"):
+		return fmt.Sprintf("%d %ses", n, unit)
+	default:
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+}
+
+// Returns the names in totals, most time first
+func byTime(totals map[string]time.Duration) []string {
+	names := make([]string, 0, len(totals))
+	for name := range totals {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if totals[names[i]] != totals[names[j]] {
+			return totals[names[i]] > totals[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// Formats a duration rounded to the minute, such as "45m" or "2h 5m"
+func formatSpent(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/ipc"
+)
+
+// Creates an API token with the given scope, printing it once. Only its hash is stored
+func (s *CLIService) CreateToken(ctx context.Context, name, scope string) error {
+	if !ipc.ValidScope(scope) {
+		return usageError{err: fmt.Errorf("invalid scope %q, use %s, %s or %s", scope, ipc.ScopeRead, ipc.ScopeSync, ipc.ScopeAdmin)}
+	}
+
+	token, err := ipc.NewAPIToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.TokenRepo.CreateAPIToken(ctx, database.CreateAPITokenParams{
+		Name:      name,
+		Scope:     scope,
+		TokenHash: ipc.HashToken(token),
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating token %s: %w", name, err)
+	}
+
+	fmt.Printf("Created %s token %s. Store it now, it won't be shown again:\n%s\n", scope, name, token)
+	return nil
+}
+
+// Prints API tokens with their scope and when they were created and last used
+func (s *CLIService) ListTokens(ctx context.Context) error {
+	tokens, err := s.TokenRepo.GetAllAPITokens(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting tokens: %w", err)
+	}
+
+	if len(tokens) == 0 {
+		fmt.Println("No API tokens")
+		return nil
+	}
+
+	for _, token := range tokens {
+		lastUsed := "never used"
+		if token.LastUsedAt.Valid {
+			lastUsed = "last used " + s.formatDateTime(token.LastUsedAt.Time, false)
+		}
+		fmt.Printf(" • %s (%s) created %s, %s\n", token.Name, token.Scope, s.formatDateTime(token.CreatedAt, false), lastUsed)
+	}
+
+	return nil
+}
+
+// Deletes API tokens by name. Connections already authenticated with them stay open until closed
+func (s *CLIService) RevokeTokens(ctx context.Context, names []string) error {
+	for _, name := range names {
+		removed, err := s.TokenRepo.RemoveAPIToken(ctx, name)
+		if err != nil {
+			return fmt.Errorf("error revoking token %s: %w", name, err)
+		}
+		if removed == 0 {
+			return fmt.Errorf("token %s not found: %w", name, sql.ErrNoRows)
+		}
+		fmt.Printf("Revoked token %s\n", name)
+	}
+
+	return nil
+}
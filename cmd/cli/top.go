@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/streaks"
+)
+
+// Defaults of 'timekeep top': time between refreshes of the live view, and programs listed
+const (
+	DefaultTopInterval = time.Second
+	DefaultTopLimit    = 20
+)
+
+// Longest the live view goes without reading today's recorded time again. It's also read whenever a session starts or
+// ends, so this only catches changes made elsewhere, such as sessions synced from other machines
+const topReload = 30 * time.Second
+
+// Clears the terminal and moves the cursor home, before each frame of the live view
+const clearScreen = "\033[H\033[2J"
+
+// A program's line in the leaderboard
+type topRow struct {
+	Program  string
+	Category string
+	Today    time.Duration // Time recorded today, including the running session
+	Running  time.Duration // Time so far in the running session, 0 when not running
+}
+
+// Recorded time of today, read from the database and refreshed by the live view as sessions come and go
+type topState struct {
+	day        time.Time
+	loadedAt   time.Time
+	sessions   string // Key of the running sessions the totals were read alongside
+	recheck    bool   // Read the totals again on the next frame, as sessions changed
+	totals     streaks.Totals
+	categories map[string]string
+}
+
+// Prints today's programs ranked by their time, including running sessions, up to limit of them. With live, the
+// ranking is redrawn every interval until ctx ends, like top: running sessions come from the service's in-memory state,
+// and the time recorded today is read again whenever a session starts or ends. When the service can't be reached,
+// running sessions are read from the database instead
+func (s *CLIService) ShowTop(ctx context.Context, live bool, interval time.Duration, limit int) error {
+	if interval <= 0 {
+		interval = DefaultTopInterval
+	}
+	if limit <= 0 {
+		limit = DefaultTopLimit
+	}
+
+	var state topState
+	frame := func() error {
+		text, err := s.topFrame(ctx, &state, time.Now(), limit, live)
+		if err != nil {
+			return err
+		}
+		if live && isTerminal(os.Stdout) {
+			text = clearScreen + text
+		}
+		_, err = os.Stdout.WriteString(text)
+		return err
+	}
+
+	if err := frame(); err != nil || !live {
+		return err
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := frame(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Renders the leaderboard as of now, reading today's recorded time again when state is out of date
+func (s *CLIService) topFrame(ctx context.Context, state *topState, now time.Time, limit int, live bool) (string, error) {
+	span, err := dates.Parse("today", now, s.location(), s.dateOptions())
+	if err != nil {
+		return "", err
+	}
+	running, fromService, err := s.runningSessions(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// An ended session's time reaches history shortly after it leaves the running sessions, so the totals are read
+	// again on the frame after a change as well
+	key := sessionsKey(running)
+	changed := state.sessions != key
+	if changed || state.recheck || !state.day.Equal(span.Start) || now.Sub(state.loadedAt) >= topReload {
+		days, err := streaks.DayTotals(ctx, s.PrRepo, s.GroupRepo, s.HsRepo, span.Start, span.End, s.Device)
+		if err != nil {
+			return "", err
+		}
+		programs, err := s.PrRepo.GetAllPrograms(ctx)
+		if err != nil {
+			return "", fmt.Errorf("error getting programs: %w", err)
+		}
+		categories := make(map[string]string, len(programs))
+		for _, program := range programs {
+			categories[program.Name] = program.Category.String
+		}
+		*state = topState{day: span.Start, loadedAt: now, sessions: key, recheck: changed, totals: days[0], categories: categories}
+	}
+
+	rows := map[string]*topRow{}
+	row := func(program string) *topRow {
+		if rows[program] == nil {
+			rows[program] = &topRow{Program: program, Category: state.categories[program]}
+		}
+		return rows[program]
+	}
+	for program, spent := range state.totals.Programs {
+		row(program).Today += spent
+	}
+	for _, session := range running {
+		r := row(session.Name)
+		r.Running = max(now.Sub(session.StartAt), 0)
+		if spent := now.Sub(dates.Later(session.StartAt, span.Start)); spent > 0 {
+			r.Today += spent
+		}
+	}
+
+	ranked := make([]topRow, 0, len(rows))
+	var total time.Duration
+	for _, r := range rows {
+		ranked = append(ranked, *r)
+		total += r.Today
+	}
+	slices.SortFunc(ranked, func(a, b topRow) int {
+		return cmp.Or(cmp.Compare(b.Today, a.Today), strings.Compare(a.Program, b.Program))
+	})
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Timekeep top - %s %s - %s today, %s running\n\n", s.formatDate(now), s.formatClock(now, true), formatSpent(total), plural(int64(len(running)), "program"))
+	if len(ranked) == 0 {
+		b.WriteString("Nothing recorded today\n")
+	} else {
+		peak := ranked[0].Today
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "#\tPROGRAM\tCATEGORY\tTODAY\tSHARE\tSESSION\t")
+		for i, r := range ranked {
+			if i == limit {
+				break
+			}
+			session := ""
+			if r.Running > 0 {
+				session = "● " + formatSpent(r.Running)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", i+1, r.Program, r.Category, formatSpent(r.Today), percent(r.Today, total), session, bar(r.Today, peak))
+		}
+		w.Flush()
+		if more := len(ranked) - limit; more > 0 {
+			fmt.Fprintf(&b, "... and %s more\n", plural(int64(more), "program"))
+		}
+	}
+
+	if !fromService {
+		b.WriteString("\nService unreachable, running sessions are read from the database\n")
+	}
+	if live {
+		b.WriteString("\nPress Ctrl+C to quit\n")
+	}
+	return b.String(), nil
+}
+
+// Returns the running sessions from the service's in-memory state, or from the database when the service can't be
+// reached, reporting which. None run when --device names another device
+func (s *CLIService) runningSessions(ctx context.Context) ([]ipc.ActiveSession, bool, error) {
+	if !s.onLocalDevice() {
+		return nil, true, nil
+	}
+	resp, err := s.ServiceCmd.Send(ipc.NewRequest(ipc.ActionQueryActive))
+	if err == nil && resp.Err() == nil {
+		var active []ipc.ActiveSession
+		if err := resp.Decode(&active); err == nil {
+			return active, true, nil
+		}
+	}
+
+	rows, err := s.AsRepo.GetAllActiveSessions(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("error getting active sessions: %w", err)
+	}
+	active := make([]ipc.ActiveSession, 0, len(rows))
+	for _, row := range rows {
+		active = append(active, ipc.ActiveSession{Name: row.ProgramName, StartAt: row.StartTime})
+	}
+	return active, false, nil
+}
+
+// Returns a key that changes whenever a session starts or ends
+func sessionsKey(sessions []ipc.ActiveSession) string {
+	keys := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		keys = append(keys, session.Name+"@"+session.StartAt.UTC().Format(time.RFC3339Nano))
+	}
+	slices.Sort(keys)
+	return strings.Join(keys, ",")
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/streaks"
+)
+
+// Default time between refreshes of the tray companion
+const DefaultTrayInterval = 10 * time.Second
+
+// What the tray companion shows
+type TrayStatus struct {
+	Program  string                   // Running program started last, empty when none runs
+	Category string                   // Program's category
+	Elapsed  time.Duration            // Time so far in Program's session
+	Running  []string                 // Every running program
+	Today    time.Duration            // Time recorded today, including running sessions
+	Goals    map[string]time.Duration // Time today towards each daily goal, keyed as in goals.daily
+	Alerts   []string                 // Daily goals met and limits passed today, such as "steam over its 2h 0m limit"
+}
+
+// Line summing up the status, such as "code 1h 5m, 3h 20m today"
+func (t TrayStatus) Title() string {
+	if t.Program == "" {
+		return fmt.Sprintf("Nothing running, %s today", formatSpent(t.Today))
+	}
+	return fmt.Sprintf("%s %s, %s today", t.Program, formatSpent(t.Elapsed), formatSpent(t.Today))
+}
+
+// Lines detailing the status: the running programs and the alerts
+func (t TrayStatus) Description() string {
+	var lines []string
+	if len(t.Running) > 0 {
+		lines = append(lines, "Running: "+strings.Join(t.Running, ", "))
+	}
+	lines = append(lines, t.Alerts...)
+	return strings.Join(lines, "\n")
+}
+
+// Returns what the tray companion shows as of now: the running programs, the time recorded today and the goals and
+// limits set by goals.daily and limits.daily it has met or passed
+func (s *CLIService) TrayStatus(ctx context.Context, now time.Time) (TrayStatus, error) {
+	span, err := dates.Parse("today", now, s.location(), s.dateOptions())
+	if err != nil {
+		return TrayStatus{}, err
+	}
+	days, err := streaks.DayTotals(ctx, s.PrRepo, s.GroupRepo, s.HsRepo, span.Start, span.End, "")
+	if err != nil {
+		return TrayStatus{}, err
+	}
+	totals := days[0]
+
+	active, err := s.AsRepo.GetAllActiveSessions(ctx)
+	if err != nil {
+		return TrayStatus{}, fmt.Errorf("error getting active sessions: %w", err)
+	}
+	categories := map[string]string{}
+	var members []database.ProgramGroup
+	if len(active) > 0 {
+		programs, err := s.PrRepo.GetAllPrograms(ctx)
+		if err != nil {
+			return TrayStatus{}, fmt.Errorf("error getting programs: %w", err)
+		}
+		for _, program := range programs {
+			categories[program.Name] = program.Category.String
+		}
+		members, err = s.GroupRepo.GetAllGroupMembers(ctx)
+		if err != nil {
+			return TrayStatus{}, fmt.Errorf("error getting groups: %w", err)
+		}
+	}
+
+	var status TrayStatus
+	var latest time.Time
+	for _, session := range active {
+		status.Running = append(status.Running, session.ProgramName)
+		if session.StartTime.After(latest) {
+			latest = session.StartTime
+			status.Program, status.Elapsed = session.ProgramName, now.Sub(session.StartTime)
+			status.Category = categories[session.ProgramName]
+		}
+
+		spent := now.Sub(dates.Later(session.StartTime, span.Start))
+		if spent <= 0 {
+			continue
+		}
+		category := categories[session.ProgramName]
+		if category == "" {
+			category = streaks.Uncategorized
+		}
+		totals.Categories[category] += spent
+		totals.Programs[session.ProgramName] += spent
+		for _, member := range members {
+			if member.ProgramName == session.ProgramName {
+				totals.Groups[member.GroupName] += spent
+			}
+		}
+	}
+	slices.Sort(status.Running)
+	for _, spent := range totals.Programs {
+		status.Today += spent
+	}
+
+	if s.Config != nil {
+		for _, category := range slices.Sorted(maps.Keys(s.Config.Goals.Daily)) {
+			goal := s.Config.DailyGoal(category)
+			if goal <= 0 {
+				continue
+			}
+			if status.Goals == nil {
+				status.Goals = map[string]time.Duration{}
+			}
+			status.Goals[category] = totals.GoalTime(category)
+			if totals.GoalTime(category) >= goal {
+				status.Alerts = append(status.Alerts, fmt.Sprintf("%s met its %s goal", category, formatSpent(goal)))
+			}
+		}
+		for _, program := range slices.Sorted(maps.Keys(s.Config.Limits.Daily)) {
+			if limit := s.Config.DailyLimit(program); limit > 0 && totals.LimitTime(program) > limit {
+				status.Alerts = append(status.Alerts, fmt.Sprintf("%s over its %s limit", program, formatSpent(limit)))
+			}
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,220 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	sniInterface   = "org.kde.StatusNotifierItem"
+	sniPath        = dbus.ObjectPath("/StatusNotifierItem")
+	sniWatcher     = "org.kde.StatusNotifierWatcher"
+	sniWatcherPath = dbus.ObjectPath("/StatusNotifierWatcher")
+	notifyDest     = "org.freedesktop.Notifications"
+	notifyPath     = dbus.ObjectPath("/org/freedesktop/Notifications")
+)
+
+// Icons of the tray item, from the desktop's icon theme. The badge is laid over the icon while an alert stands
+const (
+	trayIcon      = "appointment-soon"
+	trayBadgeIcon = "emblem-important"
+)
+
+type sniPixmap struct {
+	Width  int32
+	Height int32
+	Data   []byte
+}
+
+type sniToolTip struct {
+	IconName    string
+	IconPixmap  []sniPixmap
+	Title       string
+	Description string
+}
+
+// Methods the tray host calls on the item. Clicking it shows the status as a notification
+type trayItem struct {
+	clicks chan struct{}
+}
+
+func (t trayItem) Activate(x, y int32) *dbus.Error {
+	select {
+	case t.clicks <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (t trayItem) SecondaryActivate(x, y int32) *dbus.Error {
+	return t.Activate(x, y)
+}
+
+func (t trayItem) ContextMenu(x, y int32) *dbus.Error {
+	return nil
+}
+
+func (t trayItem) Scroll(delta int32, orientation string) *dbus.Error {
+	return nil
+}
+
+// Shows a StatusNotifierItem in the panel's tray until ctx ends, refreshed every interval: the running program and the
+// time recorded today, with the running programs and alerts in its tooltip. When a daily goal is met or a limit passed,
+// the item asks for attention with a badge and a desktop notification is sent, once a day for each
+func (s *CLIService) RunTray(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultTrayInterval
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("error connecting to the session bus: %w", err)
+	}
+	defer conn.Close()
+
+	name := fmt.Sprintf("org.kde.StatusNotifierItem-%d-1", os.Getpid())
+	reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("error claiming bus name %s: %w", name, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("bus name %s is taken", name)
+	}
+
+	item := trayItem{clicks: make(chan struct{}, 1)}
+	if err := conn.Export(item, sniPath, sniInterface); err != nil {
+		return fmt.Errorf("error exporting tray item: %w", err)
+	}
+	props, err := prop.Export(conn, sniPath, prop.Map{sniInterface: {
+		"Category":          {Value: "ApplicationStatus", Emit: prop.EmitConst},
+		"Id":                {Value: "timekeep", Emit: prop.EmitConst},
+		"Title":             {Value: "Timekeep", Emit: prop.EmitTrue},
+		"Status":            {Value: "Active", Emit: prop.EmitTrue},
+		"WindowId":          {Value: int32(0), Emit: prop.EmitConst},
+		"IconName":          {Value: trayIcon, Emit: prop.EmitConst},
+		"IconPixmap":        {Value: []sniPixmap{}, Emit: prop.EmitConst},
+		"OverlayIconName":   {Value: "", Emit: prop.EmitTrue},
+		"AttentionIconName": {Value: trayIcon, Emit: prop.EmitConst},
+		"ToolTip":           {Value: sniToolTip{IconName: trayIcon, IconPixmap: []sniPixmap{}, Title: "Timekeep"}, Emit: prop.EmitTrue},
+		"ItemIsMenu":        {Value: false, Emit: prop.EmitConst},
+		"Menu":              {Value: dbus.ObjectPath("/NO_DBUSMENU"), Emit: prop.EmitConst},
+	}})
+	if err != nil {
+		return fmt.Errorf("error exporting tray item properties: %w", err)
+	}
+	node := &introspect.Node{
+		Name: string(sniPath),
+		Interfaces: []introspect.Interface{prop.IntrospectData, {
+			Name:       sniInterface,
+			Methods:    introspect.Methods(item),
+			Properties: props.Introspection(sniInterface),
+			Signals: []introspect.Signal{
+				{Name: "NewTitle"}, {Name: "NewIcon"}, {Name: "NewAttentionIcon"}, {Name: "NewOverlayIcon"}, {Name: "NewToolTip"},
+				{Name: "NewStatus", Args: []introspect.Arg{{Name: "status", Type: "s"}}},
+			},
+		}},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), sniPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return fmt.Errorf("error exporting tray item introspection: %w", err)
+	}
+
+	// The item registers again whenever the tray host's watcher (re)starts, such as when the panel restarts
+	if err := conn.AddMatchSignal(dbus.WithMatchInterface("org.freedesktop.DBus"), dbus.WithMatchMember("NameOwnerChanged"), dbus.WithMatchArg(0, sniWatcher)); err != nil {
+		return fmt.Errorf("error watching for the tray host: %w", err)
+	}
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+	register := func() error {
+		return conn.Object(sniWatcher, sniWatcherPath).Call(sniWatcher+".RegisterStatusNotifierItem", 0, name).Err
+	}
+	if err := register(); err != nil {
+		fmt.Println("No tray host found yet, waiting for the panel to show StatusNotifierItem icons")
+	}
+
+	var status TrayStatus
+	var shown sniToolTip
+	notified := map[string]bool{}
+	day := ""
+	refresh := func() {
+		now := time.Now()
+		next, err := s.TrayStatus(ctx, now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error refreshing tray: %v\n", err)
+			return
+		}
+		status = next
+
+		tip := sniToolTip{IconName: trayIcon, IconPixmap: []sniPixmap{}, Title: status.Title(), Description: status.Description()}
+		if tip.Title != shown.Title || tip.Description != shown.Description {
+			props.SetMust(sniInterface, "Title", tip.Title)
+			props.SetMust(sniInterface, "ToolTip", tip)
+			conn.Emit(sniPath, sniInterface+".NewTitle")
+			conn.Emit(sniPath, sniInterface+".NewToolTip")
+			shown = tip
+		}
+
+		state, overlay := "Active", ""
+		if len(status.Alerts) > 0 {
+			state, overlay = "NeedsAttention", trayBadgeIcon
+		}
+		if current, _ := props.Get(sniInterface, "Status"); current.Value() != state {
+			props.SetMust(sniInterface, "Status", state)
+			props.SetMust(sniInterface, "OverlayIconName", overlay)
+			conn.Emit(sniPath, sniInterface+".NewStatus", state)
+			conn.Emit(sniPath, sniInterface+".NewOverlayIcon")
+		}
+
+		if today := now.In(s.location()).Format(time.DateOnly); today != day {
+			day = today
+			clear(notified)
+		}
+		for _, alert := range status.Alerts {
+			if notified[alert] {
+				continue
+			}
+			notified[alert] = true
+			if err := trayNotify(conn, "Timekeep", alert); err != nil {
+				fmt.Fprintf(os.Stderr, "Error sending notification: %v\n", err)
+			}
+		}
+	}
+
+	refresh()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			refresh()
+		case <-item.clicks:
+			if err := trayNotify(conn, status.Title(), status.Description()); err != nil {
+				fmt.Fprintf(os.Stderr, "Error sending notification: %v\n", err)
+			}
+		case signal := <-signals:
+			if signal.Name != "org.freedesktop.DBus.NameOwnerChanged" || len(signal.Body) < 3 {
+				continue
+			}
+			if owner, _ := signal.Body[2].(string); owner != "" {
+				if err := register(); err != nil {
+					fmt.Fprintf(os.Stderr, "Error registering with the tray host: %v\n", err)
+				}
+			}
+		}
+	}
+}
+
+// Sends a desktop notification through the notification server on the session bus
+func trayNotify(conn *dbus.Conn, summary, body string) error {
+	return conn.Object(notifyDest, notifyPath).Call(notifyDest+".Notify", 0,
+		"Timekeep", uint32(0), trayIcon, summary, body, []string{}, map[string]dbus.Variant{}, int32(-1)).Err
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+func (s *CLIService) RunTray(ctx context.Context, interval time.Duration) error {
+	return errors.New("the tray companion needs a Linux desktop whose panel shows StatusNotifierItem icons")
+}
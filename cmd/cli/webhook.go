@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/webhook"
+)
+
+// Sends an example event to the webhooks in config, or the one named, whatever their filters, so an endpoint or an
+// automation built on it can be tried without waiting for a real session. With dryRun the requests are printed instead
+func (s *CLIService) TestWebhooks(ctx context.Context, name, event, program string, dryRun bool) error {
+	if s.Config == nil || len(s.Config.Webhooks) == 0 {
+		return errors.New("no webhooks configured, add them under webhooks in config")
+	}
+	switch event {
+	case "start", webhook.SessionStart:
+		event = webhook.SessionStart
+	case "end", webhook.SessionEnd:
+		event = webhook.SessionEnd
+	default:
+		return fmt.Errorf("unknown event %q, use start or end", event)
+	}
+
+	e := webhook.Sample(event, program, time.Now().UTC())
+	client := &http.Client{Timeout: webhook.Timeout}
+
+	found, failed := false, 0
+	for i, hook := range s.Config.Webhooks {
+		label := webhook.Label(hook, i)
+		if name != "" && name != label {
+			continue
+		}
+		found = true
+
+		note := ""
+		if !webhook.Matches(hook, e) {
+			note = " (its filters skip this event outside of tests)"
+		}
+
+		if dryRun {
+			body, err := webhook.Body(hook, e)
+			if err != nil {
+				fmt.Printf("%s: %v\n", label, err)
+				failed++
+				continue
+			}
+			method := strings.ToUpper(hook.Method)
+			if method == "" {
+				method = http.MethodPost
+			}
+			fmt.Printf("%s: %s %s%s\n%s\n\n", label, method, hook.URL, note, body)
+			continue
+		}
+
+		if err := webhook.Send(ctx, client, hook, e); err != nil {
+			fmt.Printf("%s: failed: %v\n", label, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: sent %s%s\n", label, event, note)
+	}
+
+	if !found {
+		return fmt.Errorf("no webhook named %q", name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of the webhooks failed", failed)
+	}
+	return nil
+}
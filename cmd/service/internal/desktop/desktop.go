@@ -0,0 +1,232 @@
+// Package desktop reads what the user is doing in their desktop session, the window with input focus, how long input
+// has been idle and the workspace in use, from whichever window manager or compositor runs it
+package desktop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Returned when the desktop session can't report what was asked, such as the workspace under GNOME, or when no
+	// supported desktop is found, as when the service runs outside a desktop session
+	ErrUnsupported = errors.New("not supported by the desktop session")
+	// Returned by ActiveWindow when no window has input focus, such as on an empty workspace
+	ErrNoWindow = errors.New("no window has focus")
+)
+
+// Window with input focus. Backends that can't tell its process leave PID at 0
+type Window struct {
+	PID   int    // Process owning the window
+	Class string // Application the window belongs to: the WM_CLASS class under X11, the app ID under Wayland
+	Title string
+}
+
+// Reads the state of the user's desktop session from one window manager or compositor. Methods are safe for
+// concurrent use, and return ErrUnsupported for what the backend can't read
+type Provider interface {
+	Name() string                                     // Backend in use, such as "x11" or "sway"
+	ActiveWindow(ctx context.Context) (Window, error) // Window with input focus
+	Idle(ctx context.Context) (time.Duration, error)  // Time since the last keyboard or pointer input
+	Workspace(ctx context.Context) (string, error)    // Name of the workspace or virtual desktop in use
+	Close() error                                     // Releases connections to the desktop session
+}
+
+// Provider used when no supported desktop session is found
+type none struct{}
+
+func (none) Name() string { return "none" }
+
+func (none) ActiveWindow(ctx context.Context) (Window, error) { return Window{}, ErrUnsupported }
+
+func (none) Idle(ctx context.Context) (time.Duration, error) { return 0, ErrUnsupported }
+
+func (none) Workspace(ctx context.Context) (string, error) { return "", ErrUnsupported }
+
+func (none) Close() error { return nil }
+
+// Reads the focused workspace from the output of "swaymsg -t get_workspaces -r"
+func parseSwayWorkspaces(data []byte) (string, error) {
+	var workspaces []struct {
+		Name    string `json:"name"`
+		Focused bool   `json:"focused"`
+	}
+	if err := json.Unmarshal(data, &workspaces); err != nil {
+		return "", fmt.Errorf("invalid swaymsg output: %w", err)
+	}
+	for _, w := range workspaces {
+		if w.Focused {
+			return w.Name, nil
+		}
+	}
+	return "", errors.New("sway reports no focused workspace")
+}
+
+// Node of the layout tree printed by "swaymsg -t get_tree -r"
+type swayNode struct {
+	Focused          bool      `json:"focused"`
+	PID              int       `json:"pid"`
+	AppID            *string   `json:"app_id"` // Null for X11 windows under Xwayland
+	Name             *string   `json:"name"`
+	WindowProperties *struct { // Set only for X11 windows under Xwayland
+		Class string `json:"class"`
+	} `json:"window_properties"`
+	Nodes         []swayNode `json:"nodes"`
+	FloatingNodes []swayNode `json:"floating_nodes"`
+}
+
+// Reads the focused window from the output of "swaymsg -t get_tree -r"
+func parseSwayTree(data []byte) (Window, error) {
+	var root swayNode
+	if err := json.Unmarshal(data, &root); err != nil {
+		return Window{}, fmt.Errorf("invalid swaymsg output: %w", err)
+	}
+
+	var find func(n *swayNode) *swayNode
+	find = func(n *swayNode) *swayNode {
+		if n.Focused {
+			return n
+		}
+		for _, children := range [][]swayNode{n.Nodes, n.FloatingNodes} {
+			for i := range children {
+				if found := find(&children[i]); found != nil {
+					return found
+				}
+			}
+		}
+		return nil
+	}
+	focused := find(&root)
+	if focused == nil || focused.PID == 0 { // Workspaces and outputs take focus when they hold no window
+		return Window{}, ErrNoWindow
+	}
+
+	window := Window{PID: focused.PID}
+	if focused.Name != nil {
+		window.Title = *focused.Name
+	}
+	switch {
+	case focused.AppID != nil:
+		window.Class = *focused.AppID
+	case focused.WindowProperties != nil:
+		window.Class = focused.WindowProperties.Class
+	}
+	return window, nil
+}
+
+// Reads the active workspace from the output of "hyprctl activeworkspace -j"
+func parseHyprlandWorkspace(data []byte) (string, error) {
+	var workspace struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &workspace); err != nil {
+		return "", fmt.Errorf("invalid hyprctl output: %w", err)
+	}
+	if workspace.Name != "" {
+		return workspace.Name, nil
+	}
+	return strconv.Itoa(workspace.ID), nil
+}
+
+// Reads the active window from the output of "hyprctl activewindow -j", an empty object when none has focus
+func parseHyprlandWindow(data []byte) (Window, error) {
+	var window struct {
+		PID   int    `json:"pid"`
+		Class string `json:"class"`
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(data, &window); err != nil {
+		return Window{}, fmt.Errorf("invalid hyprctl output: %w", err)
+	}
+	if window.PID <= 0 {
+		return Window{}, ErrNoWindow
+	}
+	return Window{PID: window.PID, Class: window.Class, Title: window.Title}, nil
+}
+
+var (
+	xpropDesktop = regexp.MustCompile(`_NET_CURRENT_DESKTOP\(CARDINAL\) = (\d+)`)
+	xpropNames   = regexp.MustCompile(`_NET_DESKTOP_NAMES\(UTF8_STRING\) = (.*)`)
+	xpropActive  = regexp.MustCompile(`_NET_ACTIVE_WINDOW\(WINDOW\): window id # (0x[0-9a-fA-F]+)`)
+	xpropPID     = regexp.MustCompile(`_NET_WM_PID\(CARDINAL\) = (\d+)`)
+	xpropClass   = regexp.MustCompile(`WM_CLASS\(STRING\) = (.*)`)
+	xpropTitle   = regexp.MustCompile(`_NET_WM_NAME\(UTF8_STRING\) = (.*)`)
+	xpropString  = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+)
+
+// Lists the quoted strings of an xprop value, such as the names of _NET_DESKTOP_NAMES
+func xpropStrings(value string) []string {
+	var values []string
+	for _, quoted := range xpropString.FindAllStringSubmatch(value, -1) {
+		values = append(values, strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(quoted[1]))
+	}
+	return values
+}
+
+// Reads the current desktop from the output of "xprop -root _NET_CURRENT_DESKTOP _NET_DESKTOP_NAMES", as set by EWMH
+// window managers. Desktops are named as the window manager names them, or numbered from 1 when it doesn't
+func parseXpropDesktop(output string) (string, error) {
+	match := xpropDesktop.FindStringSubmatch(output)
+	if match == nil {
+		return "", errors.New("window manager reports no current desktop")
+	}
+	index, err := strconv.Atoi(match[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid current desktop %q", match[1])
+	}
+
+	if names := xpropNames.FindStringSubmatch(output); names != nil {
+		if quoted := xpropStrings(names[1]); index < len(quoted) && quoted[index] != "" {
+			return quoted[index], nil
+		}
+	}
+	return strconv.Itoa(index + 1), nil
+}
+
+// Reads the ID of the active window from the output of "xprop -root _NET_ACTIVE_WINDOW", as set by EWMH window managers
+func parseXpropActive(output string) (string, error) {
+	match := xpropActive.FindStringSubmatch(output)
+	if match == nil {
+		return "", errors.New("window manager reports no active window")
+	}
+	if id, err := strconv.ParseUint(match[1], 0, 32); err != nil || id == 0 {
+		return "", ErrNoWindow
+	}
+	return match[1], nil
+}
+
+// Reads a window from the output of "xprop -id <window> _NET_WM_PID WM_CLASS _NET_WM_NAME". The class is the second
+// string of WM_CLASS, the first being the instance name
+func parseXpropWindow(output string) Window {
+	var window Window
+	if match := xpropPID.FindStringSubmatch(output); match != nil {
+		window.PID, _ = strconv.Atoi(match[1])
+	}
+	if match := xpropClass.FindStringSubmatch(output); match != nil {
+		if class := xpropStrings(match[1]); len(class) > 0 {
+			window.Class = class[len(class)-1]
+		}
+	}
+	if match := xpropTitle.FindStringSubmatch(output); match != nil {
+		if title := xpropStrings(match[1]); len(title) > 0 {
+			window.Title = title[0]
+		}
+	}
+	return window
+}
+
+// Reads the idle time from the output of "xprintidle", in milliseconds
+func parseXprintidle(output string) (time.Duration, error) {
+	ms, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid xprintidle output %q", strings.TrimSpace(output))
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
@@ -0,0 +1,91 @@
+//go:build linux
+
+package desktop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Picks the backend for the desktop session the service runs in, from its environment: sway and Hyprland over their
+// IPC, KDE Plasma and GNOME over D-Bus, other wlroots compositors over Wayland protocols, and X11 window managers over
+// EWMH. Backends connect when first asked, so Detect never fails; without a desktop session it returns a provider
+// reading nothing
+func Detect() Provider {
+	current := strings.ToUpper(os.Getenv("XDG_CURRENT_DESKTOP"))
+	wayland := os.Getenv("WAYLAND_DISPLAY") != ""
+
+	switch {
+	case os.Getenv("SWAYSOCK") != "":
+		return newWlroots("sway", swayIPC{})
+	case os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "":
+		return newWlroots("hyprland", hyprlandIPC{})
+	case wayland && strings.Contains(current, "KDE"):
+		return newKDE()
+	case wayland && strings.Contains(current, "GNOME"):
+		return newGNOME()
+	case wayland:
+		return newWlroots("wlroots", nil)
+	case os.Getenv("DISPLAY") != "":
+		return x11{}
+	}
+	return none{}
+}
+
+// Runs a command of the desktop session, returning its output. Errors carry what it wrote to stderr
+func run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return nil, fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(string(exit.Stderr)))
+		}
+		return nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+	return out, nil
+}
+
+// X11 window managers, read through the EWMH properties they set on the root window and windows with xprop, and the
+// XScreenSaver extension with xprintidle
+type x11 struct{}
+
+func (x11) Name() string { return "x11" }
+
+func (x11) ActiveWindow(ctx context.Context) (Window, error) {
+	out, err := run(ctx, "xprop", "-root", "_NET_ACTIVE_WINDOW")
+	if err != nil {
+		return Window{}, err
+	}
+	id, err := parseXpropActive(string(out))
+	if err != nil {
+		return Window{}, err
+	}
+	out, err = run(ctx, "xprop", "-id", id, "_NET_WM_PID", "WM_CLASS", "_NET_WM_NAME")
+	if err != nil {
+		return Window{}, err
+	}
+	return parseXpropWindow(string(out)), nil
+}
+
+func (x11) Idle(ctx context.Context) (time.Duration, error) {
+	out, err := run(ctx, "xprintidle")
+	if err != nil {
+		return 0, err
+	}
+	return parseXprintidle(string(out))
+}
+
+func (x11) Workspace(ctx context.Context) (string, error) {
+	out, err := run(ctx, "xprop", "-root", "_NET_CURRENT_DESKTOP", "_NET_DESKTOP_NAMES")
+	if err != nil {
+		return "", err
+	}
+	return parseXpropDesktop(string(out))
+}
+
+func (x11) Close() error { return nil }
@@ -0,0 +1,43 @@
+package desktop
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFocusedWindow(t *testing.T) {
+	sway := func(data string) func() (Window, error) {
+		return func() (Window, error) { return parseSwayTree([]byte(data)) }
+	}
+	hyprland := func(data string) func() (Window, error) {
+		return func() (Window, error) { return parseHyprlandWindow([]byte(data)) }
+	}
+	xprop := func(output string) func() (Window, error) {
+		return func() (Window, error) { return parseXpropWindow(output), nil }
+	}
+
+	tests := []struct {
+		name    string
+		parse   func() (Window, error)
+		want    Window
+		wantErr error
+	}{
+		{name: "sway wayland window", parse: sway(`{"nodes":[{"nodes":[{"focused":true,"pid":42,"app_id":"code","name":"main.go - Code"}]}]}`), want: Window{PID: 42, Class: "code", Title: "main.go - Code"}},
+		{name: "sway xwayland window", parse: sway(`{"nodes":[{"floating_nodes":[{"focused":true,"pid":7,"app_id":null,"name":"Steam","window_properties":{"class":"steam"}}]}]}`), want: Window{PID: 7, Class: "steam", Title: "Steam"}},
+		{name: "sway empty workspace", parse: sway(`{"nodes":[{"focused":true,"name":"1"}]}`), wantErr: ErrNoWindow},
+		{name: "sway nothing focused", parse: sway(`{"nodes":[{"pid":42,"app_id":"code"}]}`), wantErr: ErrNoWindow},
+		{name: "hyprland window", parse: hyprland(`{"pid":42,"class":"firefox","title":"Mozilla Firefox"}`), want: Window{PID: 42, Class: "firefox", Title: "Mozilla Firefox"}},
+		{name: "hyprland no window", parse: hyprland(`{}`), wantErr: ErrNoWindow},
+		{name: "xprop window", parse: xprop("_NET_WM_PID(CARDINAL) = 42\nWM_CLASS(STRING) = \"navigator\", \"firefox\"\n_NET_WM_NAME(UTF8_STRING) = \"Say \\\"hi\\\"\"\n"), want: Window{PID: 42, Class: "firefox", Title: `Say "hi"`}},
+		{name: "xprop window without a PID", parse: xprop("_NET_WM_PID:  not found.\nWM_CLASS(STRING) = \"xterm\"\n"), want: Window{Class: "xterm"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			window, err := tc.parse()
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.want, window)
+		})
+	}
+}
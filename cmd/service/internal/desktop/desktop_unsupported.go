@@ -0,0 +1,8 @@
+//go:build !linux
+
+package desktop
+
+// Desktop sessions are only read on Linux, elsewhere the provider reads nothing
+func Detect() Provider {
+	return none{}
+}
@@ -0,0 +1,115 @@
+//go:build linux
+
+package desktop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	mutterIdleDest  = "org.gnome.Mutter.IdleMonitor"
+	mutterIdlePath  = dbus.ObjectPath("/org/gnome/Mutter/IdleMonitor/Core")
+	windowCallsDest = "org.gnome.Shell"
+	windowCallsPath = dbus.ObjectPath("/org/gnome/Shell/Extensions/Windows")
+	windowCalls     = "org.gnome.Shell.Extensions.Windows"
+)
+
+// GNOME under Wayland. Idle time is read from Mutter's idle monitor over D-Bus. GNOME Shell tells other programs
+// nothing of its windows, so the active window is read only with the Window Calls extension installed, and the
+// workspace not at all
+type gnome struct {
+	mu   sync.Mutex
+	conn *dbus.Conn // Session bus, connected when first needed
+}
+
+func newGNOME() *gnome {
+	return &gnome{}
+}
+
+func (g *gnome) Name() string { return "gnome" }
+
+func (g *gnome) ActiveWindow(ctx context.Context) (Window, error) {
+	conn, err := g.bus()
+	if err != nil {
+		return Window{}, err
+	}
+	var list string
+	if err := conn.Object(windowCallsDest, windowCallsPath).CallWithContext(ctx, windowCalls+".List", 0).Store(&list); err != nil {
+		var dbusErr dbus.Error
+		if errors.As(err, &dbusErr) && dbusErr.Name == "org.freedesktop.DBus.Error.UnknownMethod" {
+			return Window{}, fmt.Errorf("%w: install the Window Calls GNOME Shell extension to read the active window", ErrUnsupported)
+		}
+		return Window{}, fmt.Errorf("error listing windows: %w", err)
+	}
+	return parseWindowCalls([]byte(list))
+}
+
+func (g *gnome) Idle(ctx context.Context) (time.Duration, error) {
+	conn, err := g.bus()
+	if err != nil {
+		return 0, err
+	}
+	var ms uint64
+	if err := conn.Object(mutterIdleDest, mutterIdlePath).CallWithContext(ctx, mutterIdleDest+".GetIdletime", 0).Store(&ms); err != nil {
+		return 0, fmt.Errorf("error reading idle time: %w", err)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func (g *gnome) Workspace(ctx context.Context) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (g *gnome) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.conn == nil {
+		return nil
+	}
+	err := g.conn.Close()
+	g.conn = nil
+	return err
+}
+
+// Returns the session bus connection, connecting when first needed and again after it drops
+func (g *gnome) bus() (*dbus.Conn, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.conn != nil && g.conn.Connected() {
+		return g.conn, nil
+	}
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the session bus: %w", err)
+	}
+	g.conn = conn
+	return conn, nil
+}
+
+// Reads the focused window from the JSON list the Window Calls extension returns
+func parseWindowCalls(data []byte) (Window, error) {
+	var windows []struct {
+		PID     int    `json:"pid"`
+		WMClass string `json:"wm_class"`
+		Title   string `json:"title"`
+		Focus   bool   `json:"focus"`
+	}
+	if err := json.Unmarshal(data, &windows); err != nil {
+		return Window{}, fmt.Errorf("invalid window list: %w", err)
+	}
+	for _, w := range windows {
+		if w.Focus {
+			return Window{PID: w.PID, Class: w.WMClass, Title: w.Title}, nil
+		}
+	}
+	return Window{}, ErrNoWindow
+}
@@ -0,0 +1,18 @@
+//go:build linux
+
+package desktop
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWindowCalls(t *testing.T) {
+	window, err := parseWindowCalls([]byte(`[{"pid":7,"wm_class":"steam","title":"Steam","focus":false},{"pid":42,"wm_class":"org.gnome.Terminal","title":"~","focus":true}]`))
+	assert.Nil(t, err)
+	assert.Equal(t, Window{PID: 42, Class: "org.gnome.Terminal", Title: "~"}, window)
+
+	_, err = parseWindowCalls([]byte(`[{"pid":7,"wm_class":"steam","focus":false}]`))
+	assert.ErrorIs(t, err, ErrNoWindow)
+}
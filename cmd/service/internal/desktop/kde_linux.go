@@ -0,0 +1,216 @@
+//go:build linux
+
+package desktop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	kwinDest        = "org.kde.KWin"
+	kwinScripting   = "org.kde.kwin.Scripting"
+	kwinDesktops    = "org.kde.KWin.VirtualDesktopManager"
+	kwinDesktopPath = dbus.ObjectPath("/VirtualDesktopManager")
+	kwinScript      = "timekeep-active-window" // Plugin name the script reporting the active window is loaded under
+	reportPath      = dbus.ObjectPath("/ActiveWindow")
+	reportInterface = "io.github.jmsguy.Timekeep.ActiveWindow"
+)
+
+// KWin script reporting the active window, and any change of its title, to the service's D-Bus connection. Handles
+// the KWin 6 API and that of KWin 5, which calls windows clients
+const kwinScriptSource = `
+var activated = workspace.windowActivated || workspace.clientActivated;
+var watched = null;
+function active() {
+	return workspace.activeWindow !== undefined ? workspace.activeWindow : workspace.activeClient;
+}
+function report() {
+	var w = active();
+	callDBus(%q, %q, %q, "Report", w ? String(w.pid) : "0", w ? String(w.resourceClass) : "", w ? String(w.caption) : "");
+}
+function watch(w) {
+	try {
+		if (watched) watched.captionChanged.disconnect(report);
+	} catch (e) {}
+	watched = w;
+	if (w) w.captionChanged.connect(report);
+	report();
+}
+activated.connect(watch);
+watch(active());
+`
+
+// KDE Plasma under Wayland. KWin runs a script reporting the active window over D-Bus, virtual desktops are read from
+// KWin over D-Bus, and idle time is followed over ext-idle-notify
+type kde struct {
+	wl waylandLink
+
+	mu     sync.Mutex
+	conn   *dbus.Conn // Session bus, connected when first needed
+	script string     // Path of the script file KWin runs, removed on Close
+	window Window
+	known  bool // The script has reported the active window
+}
+
+func newKDE() *kde {
+	return &kde{}
+}
+
+func (k *kde) Name() string { return "kde" }
+
+func (k *kde) ActiveWindow(ctx context.Context) (Window, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.script == "" {
+		if err := k.loadScript(); err != nil {
+			return Window{}, err
+		}
+	}
+	switch {
+	case !k.known:
+		return Window{}, errors.New("KWin hasn't reported the active window yet")
+	case k.window == Window{}:
+		return Window{}, ErrNoWindow
+	}
+	return k.window, nil
+}
+
+func (k *kde) Idle(ctx context.Context) (time.Duration, error) {
+	conn, err := k.wl.get()
+	if err != nil {
+		return 0, err
+	}
+	return conn.idle()
+}
+
+func (k *kde) Workspace(ctx context.Context) (string, error) {
+	k.mu.Lock()
+	conn, err := k.bus()
+	k.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	manager := conn.Object(kwinDest, kwinDesktopPath)
+	current, err := manager.GetProperty(kwinDesktops + ".current")
+	if err != nil {
+		return "", fmt.Errorf("error reading the current virtual desktop: %w", err)
+	}
+	listed, err := manager.GetProperty(kwinDesktops + ".desktops")
+	if err != nil {
+		return "", fmt.Errorf("error listing virtual desktops: %w", err)
+	}
+	var desktops []struct {
+		Position uint32
+		ID       string
+		Name     string
+	}
+	if err := listed.Store(&desktops); err != nil {
+		return "", fmt.Errorf("invalid virtual desktop list: %w", err)
+	}
+
+	id, _ := current.Value().(string)
+	for _, d := range desktops {
+		if d.ID == id {
+			if d.Name != "" {
+				return d.Name, nil
+			}
+			return strconv.Itoa(int(d.Position) + 1), nil
+		}
+	}
+	return "", errors.New("KWin reports no current virtual desktop")
+}
+
+func (k *kde) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.conn != nil {
+		if k.script != "" {
+			k.conn.Object(kwinDest, "/Scripting").Call(kwinScripting+".unloadScript", 0, kwinScript)
+			os.Remove(k.script)
+			k.script = ""
+		}
+		k.conn.Close()
+		k.conn = nil
+	}
+	return k.wl.close()
+}
+
+// Returns the session bus connection, connecting when first needed. Callers hold k.mu
+func (k *kde) bus() (*dbus.Conn, error) {
+	if k.conn != nil && k.conn.Connected() {
+		return k.conn, nil
+	}
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the session bus: %w", err)
+	}
+	if err := conn.Export(kwinReport{k}, reportPath, reportInterface); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error exporting the active window receiver: %w", err)
+	}
+	k.conn, k.script, k.known = conn, "", false
+	return conn, nil
+}
+
+// Has KWin run the script reporting the active window to this connection, replacing a copy left loaded by an
+// earlier run of the service. Callers hold k.mu
+func (k *kde) loadScript() error {
+	conn, err := k.bus()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp("", "timekeep-kwin-*.js")
+	if err != nil {
+		return fmt.Errorf("error writing KWin script: %w", err)
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintf(file, kwinScriptSource, conn.Names()[0], reportPath, reportInterface); err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("error writing KWin script: %w", err)
+	}
+
+	scripting := conn.Object(kwinDest, "/Scripting")
+	scripting.Call(kwinScripting+".unloadScript", 0, kwinScript)
+	var id int32
+	if err := scripting.Call(kwinScripting+".loadScript", 0, file.Name(), kwinScript).Store(&id); err != nil || id < 0 {
+		os.Remove(file.Name())
+		if err == nil {
+			err = fmt.Errorf("script id %d", id)
+		}
+		return fmt.Errorf("KWin refused the active window script: %w", err)
+	}
+	if err := scripting.Call(kwinScripting+".start", 0).Err; err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("error starting KWin script: %w", err)
+	}
+
+	k.script = file.Name()
+	return nil
+}
+
+// D-Bus object the KWin script reports the active window to
+type kwinReport struct {
+	k *kde
+}
+
+func (r kwinReport) Report(pid, class, title string) *dbus.Error {
+	window := Window{Class: class, Title: title}
+	window.PID, _ = strconv.Atoi(pid)
+
+	r.k.mu.Lock()
+	r.k.window, r.k.known = window, true
+	r.k.mu.Unlock()
+	return nil
+}
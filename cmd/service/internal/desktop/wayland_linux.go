@@ -0,0 +1,395 @@
+//go:build linux
+
+package desktop
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Inactivity after which the compositor reports the seat idle over ext-idle-notify. Shorter idle times read as none,
+// as the protocol only reports crossing it
+const idleThreshold = 30 * time.Second
+
+// How long the compositor has to list its globals when connecting
+const waylandHandshake = 2 * time.Second
+
+// Wayland interfaces, object IDs and codes used
+const (
+	wlSeat              = "wl_seat"
+	idleNotifier        = "ext_idle_notifier_v1"
+	idleNotification    = "ext_idle_notification_v1"
+	toplevelManager     = "zwlr_foreign_toplevel_manager_v1"
+	toplevelHandle      = "zwlr_foreign_toplevel_handle_v1"
+	wlDisplayID         = 1 // Object ID of wl_display, fixed by the protocol
+	toplevelActivated   = 2 // zwlr_foreign_toplevel_handle_v1 state of the window with focus
+	toplevelDestroyCode = 7 // zwlr_foreign_toplevel_handle_v1.destroy request
+)
+
+// A window listed by wlr-foreign-toplevel-management. Changes apply on the done event that ends them
+type toplevel struct {
+	current, pending Window
+	active, next     bool
+}
+
+// Connection to a Wayland compositor following the seat's idle state over ext-idle-notify and its windows over
+// wlr-foreign-toplevel-management, as far as it offers them. Events are read in the background until the connection
+// drops, after which the connection reports why
+type wayland struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+
+	mu        sync.Mutex
+	err       error                // Why the connection dropped, nil while it's up
+	idling    bool                 // Compositor offers ext-idle-notify
+	listing   bool                 // Compositor offers wlr-foreign-toplevel-management
+	idleSince time.Time            // When input stopped, zero while the seat is in use
+	windows   map[uint32]*toplevel // Windows by object ID
+	objects   map[uint32]string    // Interface of each object the events of are read
+	nextID    uint32
+	registry  uint32
+}
+
+// Path of the compositor's socket, from WAYLAND_DISPLAY relative to XDG_RUNTIME_DIR
+func waylandSocket() string {
+	name := os.Getenv("WAYLAND_DISPLAY")
+	if name == "" {
+		name = "wayland-0"
+	}
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), name)
+}
+
+// Connects to the compositor, binding the idle notifier and window list it offers. Fails when it offers neither
+func dialWayland() (*wayland, error) {
+	conn, err := net.Dial("unix", waylandSocket())
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the compositor: %w", err)
+	}
+	w := &wayland{conn: conn, windows: make(map[uint32]*toplevel), objects: make(map[uint32]string), nextID: wlDisplayID + 1}
+
+	globals, err := w.listGlobals()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	var seat uint32
+	if name, ok := globals[wlSeat]; ok {
+		seat = w.bind(name, wlSeat)
+	}
+	if name, ok := globals[idleNotifier]; ok && seat != 0 {
+		notifier := w.bind(name, idleNotifier)
+		notification := w.newID(idleNotification)
+		w.send(notifier, 1, notification, uint32(idleThreshold/time.Millisecond), seat) // get_idle_notification
+		w.idling = true
+	}
+	if name, ok := globals[toplevelManager]; ok {
+		w.bind(name, toplevelManager)
+		w.listing = true
+	}
+	if !w.idling && !w.listing {
+		conn.Close()
+		return nil, errors.New("compositor offers neither ext-idle-notify nor wlr-foreign-toplevel-management")
+	}
+
+	go w.read()
+	return w, nil
+}
+
+// Lists the names of the compositor's globals by interface, waiting for a sync callback to know the list is complete
+func (w *wayland) listGlobals() (map[string]uint32, error) {
+	w.registry = w.newID("wl_registry")
+	callback := w.newID("wl_callback")
+	w.send(wlDisplayID, 1, w.registry)                       // get_registry
+	if err := w.send(wlDisplayID, 0, callback); err != nil { // sync
+		return nil, fmt.Errorf("error writing to the compositor: %w", err)
+	}
+
+	w.conn.SetReadDeadline(time.Now().Add(waylandHandshake))
+	defer w.conn.SetReadDeadline(time.Time{})
+
+	globals := make(map[string]uint32)
+	for {
+		object, opcode, body, err := readMessage(w.conn)
+		if err != nil {
+			return nil, fmt.Errorf("error reading from the compositor: %w", err)
+		}
+		args := &wireArgs{body: body}
+		switch {
+		case object == wlDisplayID && opcode == 0:
+			return nil, displayError(args)
+		case object == w.registry && opcode == 0: // global
+			name, iface := args.uint(), args.string()
+			globals[iface] = name
+		case object == callback:
+			return globals, nil
+		}
+	}
+}
+
+// Allocates the ID of a new object of iface
+func (w *wayland) newID(iface string) uint32 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := w.nextID
+	w.nextID++
+	w.objects[id] = iface
+	return id
+}
+
+// Binds the registry global name as a new object of iface, at version 1 which has every event read
+func (w *wayland) bind(name uint32, iface string) uint32 {
+	id := w.newID(iface)
+	w.send(w.registry, 0, name, iface, uint32(1), id) // bind
+	return id
+}
+
+// Writes a request of object, taking uint32 and string arguments
+func (w *wayland) send(object uint32, opcode uint16, args ...any) error {
+	msg := encodeMessage(object, opcode, args...)
+
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	_, err := w.conn.Write(msg)
+	return err
+}
+
+// Encodes one message of object, taking uint32 and string arguments
+func encodeMessage(object uint32, opcode uint16, args ...any) []byte {
+	body := []byte{}
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case uint32:
+			body = binary.NativeEndian.AppendUint32(body, v)
+		case string:
+			body = binary.NativeEndian.AppendUint32(body, uint32(len(v)+1))
+			body = append(body, v...)
+			body = append(body, make([]byte, 4-len(v)%4)...) // NUL terminator and padding to 32 bits
+		}
+	}
+	msg := binary.NativeEndian.AppendUint32(nil, object)
+	msg = binary.NativeEndian.AppendUint32(msg, uint32(8+len(body))<<16|uint32(opcode))
+	return append(msg, body...)
+}
+
+// Reads events until the connection drops, recording why
+func (w *wayland) read() {
+	for {
+		object, opcode, body, err := readMessage(w.conn)
+		if err == nil {
+			err = w.handle(object, opcode, &wireArgs{body: body})
+		}
+		if err != nil {
+			w.mu.Lock()
+			w.err = fmt.Errorf("compositor connection lost: %w", err)
+			w.mu.Unlock()
+			w.conn.Close()
+			return
+		}
+	}
+}
+
+// Applies one event to the state followed
+func (w *wayland) handle(object uint32, opcode uint16, args *wireArgs) error {
+	if object == wlDisplayID {
+		if opcode == 0 {
+			return displayError(args)
+		}
+		return nil // delete_id
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch w.objects[object] {
+	case idleNotification:
+		switch opcode {
+		case 0: // idled
+			w.idleSince = time.Now().Add(-idleThreshold)
+		case 1: // resumed
+			w.idleSince = time.Time{}
+		}
+	case toplevelManager:
+		if opcode == 0 { // toplevel, announcing a new window
+			id := args.uint()
+			w.objects[id] = toplevelHandle
+			w.windows[id] = &toplevel{}
+		}
+	case toplevelHandle:
+		t := w.windows[object]
+		if t == nil {
+			return nil
+		}
+		switch opcode {
+		case 0: // title
+			t.pending.Title = args.string()
+		case 1: // app_id
+			t.pending.Class = args.string()
+		case 4: // state
+			t.next = false
+			for states := args.array(); len(states) >= 4; states = states[4:] {
+				if binary.NativeEndian.Uint32(states) == toplevelActivated {
+					t.next = true
+				}
+			}
+		case 5: // done
+			t.current, t.active = t.pending, t.next
+		case 6: // closed
+			delete(w.windows, object)
+			delete(w.objects, object)
+			w.send(object, toplevelDestroyCode)
+		}
+	}
+	return args.err
+}
+
+// Returns how long the seat has been idle, nothing until idleThreshold has passed
+func (w *wayland) idle() (time.Duration, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch {
+	case w.err != nil:
+		return 0, w.err
+	case !w.idling:
+		return 0, ErrUnsupported
+	case w.idleSince.IsZero():
+		return 0, nil
+	}
+	return time.Since(w.idleSince), nil
+}
+
+// Returns the activated window. wlr-foreign-toplevel-management doesn't tell its process
+func (w *wayland) activeWindow() (Window, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch {
+	case w.err != nil:
+		return Window{}, w.err
+	case !w.listing:
+		return Window{}, ErrUnsupported
+	}
+	for _, t := range w.windows {
+		if t.active {
+			return t.current, nil
+		}
+	}
+	return Window{}, ErrNoWindow
+}
+
+// Reports why the connection dropped, nil while it's up
+func (w *wayland) failed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *wayland) close() error {
+	return w.conn.Close()
+}
+
+// Reads one message: the object it's for, its opcode and its arguments
+func readMessage(r io.Reader) (uint32, uint16, []byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	object := binary.NativeEndian.Uint32(header[:4])
+	word := binary.NativeEndian.Uint32(header[4:])
+	size := int(word >> 16)
+	if size < 8 {
+		return 0, 0, nil, fmt.Errorf("invalid message size %d", size)
+	}
+	body := make([]byte, size-8)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, 0, nil, err
+	}
+	return object, uint16(word), body, nil
+}
+
+// Decodes the arguments of an event in order. A malformed event sets err and reads as zero values from then on
+type wireArgs struct {
+	body []byte
+	err  error
+}
+
+func (a *wireArgs) uint() uint32 {
+	if len(a.body) < 4 {
+		a.err = errors.New("truncated event")
+		a.body = nil
+		return 0
+	}
+	v := binary.NativeEndian.Uint32(a.body)
+	a.body = a.body[4:]
+	return v
+}
+
+// Strings and arrays are a length, then the bytes padded to 32 bits
+func (a *wireArgs) array() []byte {
+	n := uint64(a.uint())
+	padded := (n + 3) &^ 3
+	if uint64(len(a.body)) < padded {
+		a.err = errors.New("truncated event")
+		a.body = nil
+		return nil
+	}
+	v := a.body[:n]
+	a.body = a.body[padded:]
+	return v
+}
+
+func (a *wireArgs) string() string {
+	v := a.array()
+	if len(v) > 0 && v[len(v)-1] == 0 {
+		v = v[:len(v)-1]
+	}
+	return string(v)
+}
+
+// Decodes a wl_display.error event, sent when the compositor rejects a request
+func displayError(args *wireArgs) error {
+	object, code, message := args.uint(), args.uint(), args.string()
+	return fmt.Errorf("compositor error %d on object %d: %s", code, object, message)
+}
+
+// Connection to the compositor, dialed when first needed and again after it drops
+type waylandLink struct {
+	mu   sync.Mutex
+	conn *wayland
+}
+
+func (l *waylandLink) get() (*wayland, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil && l.conn.failed() == nil {
+		return l.conn, nil
+	}
+	conn, err := dialWayland()
+	if err != nil {
+		return nil, err
+	}
+	l.conn = conn
+	return conn, nil
+}
+
+func (l *waylandLink) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	err := l.conn.close()
+	l.conn = nil
+	return err
+}
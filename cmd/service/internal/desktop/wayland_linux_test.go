@@ -0,0 +1,223 @@
+//go:build linux
+
+package desktop
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Object IDs of the window list in the focus tests
+const (
+	testManager = 3
+	testEditor  = 10
+	testBrowser = 11
+)
+
+// Encodes a wl array of uint32 values, as the state event sends
+func wireStates(states ...uint32) []byte {
+	body := binary.NativeEndian.AppendUint32(nil, uint32(4*len(states)))
+	for _, state := range states {
+		body = binary.NativeEndian.AppendUint32(body, state)
+	}
+	return body
+}
+
+// Encodes an event carrying body as it is
+func rawMessage(object uint32, opcode uint16, body []byte) []byte {
+	msg := binary.NativeEndian.AppendUint32(nil, object)
+	msg = binary.NativeEndian.AppendUint32(msg, uint32(8+len(body))<<16|uint32(opcode))
+	return append(msg, body...)
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		object  uint32
+		opcode  uint16
+		body    []byte
+		wantErr bool
+	}{
+		{name: "no arguments", input: encodeMessage(7, 5), object: 7, opcode: 5, body: []byte{}},
+		{name: "uint", input: encodeMessage(2, 0, uint32(42)), object: 2, opcode: 0, body: binary.NativeEndian.AppendUint32(nil, 42)},
+		{name: "string", input: encodeMessage(2, 1, "code"), object: 2, opcode: 1, body: append(binary.NativeEndian.AppendUint32(nil, 5), "code\x00\x00\x00\x00"...)},
+		{name: "trailing message left unread", input: append(encodeMessage(4, 6), encodeMessage(5, 0)...), object: 4, opcode: 6, body: []byte{}},
+		{name: "empty", input: nil, wantErr: true},
+		{name: "truncated header", input: encodeMessage(7, 5)[:6], wantErr: true},
+		{name: "size of 4", input: append(binary.NativeEndian.AppendUint32(nil, 7), 0, 0, 4, 0), wantErr: true},
+		{name: "truncated body", input: encodeMessage(2, 1, "firefox")[:14], wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			object, opcode, body, err := readMessage(bytes.NewReader(tc.input))
+			if tc.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.object, object)
+			assert.Equal(t, tc.opcode, opcode)
+			assert.Equal(t, tc.body, body)
+		})
+	}
+}
+
+func TestWireArgs(t *testing.T) {
+	body := func(msg []byte) []byte { return msg[8:] }
+
+	tests := []struct {
+		name    string
+		body    []byte
+		read    func(a *wireArgs) any
+		want    any
+		wantErr bool
+	}{
+		{name: "uint", body: body(encodeMessage(1, 0, uint32(7))), read: func(a *wireArgs) any { return a.uint() }, want: uint32(7)},
+		{name: "string", body: body(encodeMessage(1, 0, "firefox")), read: func(a *wireArgs) any { return a.string() }, want: "firefox"},
+		{name: "string filling its padding", body: body(encodeMessage(1, 0, "abc")), read: func(a *wireArgs) any { return a.string() }, want: "abc"},
+		{name: "empty string", body: body(encodeMessage(1, 0, "")), read: func(a *wireArgs) any { return a.string() }, want: ""},
+		{name: "null string", body: binary.NativeEndian.AppendUint32(nil, 0), read: func(a *wireArgs) any { return a.string() }, want: ""},
+		{name: "array", body: wireStates(1, 2), read: func(a *wireArgs) any { return a.array() }, want: append(binary.NativeEndian.AppendUint32(nil, 1), binary.NativeEndian.AppendUint32(nil, 2)...)},
+		{name: "arguments in order", body: body(encodeMessage(1, 0, uint32(3), "main.go", uint32(9))), read: func(a *wireArgs) any { return []any{a.uint(), a.string(), a.uint()} }, want: []any{uint32(3), "main.go", uint32(9)}},
+		{name: "truncated uint", body: []byte{1, 2}, read: func(a *wireArgs) any { return a.uint() }, want: uint32(0), wantErr: true},
+		{name: "length past the body", body: body(encodeMessage(1, 0, "firefox"))[:8], read: func(a *wireArgs) any { return a.string() }, want: "", wantErr: true},
+		{name: "length of 4GiB", body: binary.NativeEndian.AppendUint32(nil, 0xffffffff), read: func(a *wireArgs) any { return a.array() }, want: []byte(nil), wantErr: true},
+		{name: "zero after an error", body: []byte{1}, read: func(a *wireArgs) any { return []any{a.uint(), a.string()} }, want: []any{uint32(0), ""}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args := &wireArgs{body: tc.body}
+			assert.Equal(t, tc.want, tc.read(args))
+			assert.Equal(t, tc.wantErr, args.err != nil)
+		})
+	}
+}
+
+func FuzzReadMessage(f *testing.F) {
+	f.Add(encodeMessage(1, 0, uint32(1), uint32(2), "unknown object"))
+	f.Add(encodeMessage(testEditor, 1, "code"))
+	f.Add(rawMessage(testEditor, 4, wireStates(toplevelActivated)))
+	f.Add(append(binary.NativeEndian.AppendUint32(nil, 7), 0, 0, 0xff, 0xff))
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		object, opcode, body, err := readMessage(bytes.NewReader(input))
+		if err != nil {
+			return
+		}
+		// A message read is exactly the bytes its header sizes
+		assert.Equal(t, rawMessage(object, opcode, body), input[:8+len(body)])
+	})
+}
+
+func FuzzWireArgs(f *testing.F) {
+	f.Add(encodeMessage(1, 0, uint32(3), "main.go")[8:], "main.go")
+	f.Add(wireStates(1, toplevelActivated), "")
+	f.Add(binary.NativeEndian.AppendUint32(nil, 0xfffffffd), "firefox")
+
+	f.Fuzz(func(t *testing.T, body []byte, s string) {
+		// Whatever the compositor sends decodes without panicking, and reads as zero values once malformed
+		args := &wireArgs{body: body}
+		for range 4 {
+			before := len(args.body)
+			v := args.array()
+			assert.LessOrEqual(t, len(v), before)
+			args.string()
+			args.uint()
+			if args.err != nil {
+				assert.Nil(t, args.body)
+				assert.Zero(t, args.uint())
+				assert.Empty(t, args.string())
+				break
+			}
+		}
+
+		// Strings sent decode back as they were, up to a NUL they hold
+		if len(s) > 1<<15 {
+			return
+		}
+		_, _, msg, err := readMessage(bytes.NewReader(encodeMessage(1, 0, s, uint32(len(s)))))
+		assert.Nil(t, err)
+		args = &wireArgs{body: msg}
+		got, n := args.string(), args.uint()
+		assert.Nil(t, args.err)
+		assert.Equal(t, uint32(len(s)), n)
+		if !strings.ContainsRune(s, 0) {
+			assert.Equal(t, s, got)
+		}
+	})
+}
+
+// Connection to a compositor that drops whatever is written to it
+func testWayland(t *testing.T) *wayland {
+	client, server := net.Pipe()
+	go io.Copy(io.Discard, server)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return &wayland{
+		conn:    client,
+		listing: true,
+		windows: make(map[uint32]*toplevel),
+		objects: map[uint32]string{testManager: toplevelManager},
+	}
+}
+
+func TestToplevelFocus(t *testing.T) {
+	opened := func(id uint32) []byte { return encodeMessage(testManager, 0, id) }
+	title := func(id uint32, s string) []byte { return encodeMessage(id, 0, s) }
+	appID := func(id uint32, s string) []byte { return encodeMessage(id, 1, s) }
+	state := func(id uint32, states ...uint32) []byte { return rawMessage(id, 4, wireStates(states...)) }
+	done := func(id uint32) []byte { return encodeMessage(id, 5) }
+	closed := func(id uint32) []byte { return encodeMessage(id, 6) }
+
+	editor := [][]byte{opened(testEditor), title(testEditor, "main.go - Code"), appID(testEditor, "code"), state(testEditor, 1, toplevelActivated), done(testEditor)}
+
+	tests := []struct {
+		name    string
+		events  [][]byte
+		want    Window
+		wantErr error
+	}{
+		{name: "no windows", wantErr: ErrNoWindow},
+		{name: "activated window", events: editor, want: Window{Class: "code", Title: "main.go - Code"}},
+		{name: "changes wait for done", events: append(editor[:len(editor):len(editor)], appID(testEditor, "firefox"), title(testEditor, "Firefox")), want: Window{Class: "code", Title: "main.go - Code"}},
+		{name: "retitled", events: append(editor[:len(editor):len(editor)], title(testEditor, "go.mod - Code"), done(testEditor)), want: Window{Class: "code", Title: "go.mod - Code"}},
+		{name: "not activated", events: [][]byte{opened(testEditor), appID(testEditor, "code"), state(testEditor, 1), done(testEditor)}, wantErr: ErrNoWindow},
+		{name: "focus moved", events: append(editor[:len(editor):len(editor)],
+			opened(testBrowser), appID(testBrowser, "firefox"), state(testBrowser, toplevelActivated), done(testBrowser),
+			state(testEditor), done(testEditor)), want: Window{Class: "firefox"}},
+		{name: "focused window closed", events: append(editor[:len(editor):len(editor)], closed(testEditor)), wantErr: ErrNoWindow},
+		{name: "events of unknown windows", events: [][]byte{appID(testBrowser, "firefox"), state(testBrowser, toplevelActivated), done(testBrowser)}, wantErr: ErrNoWindow},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := testWayland(t)
+			for _, event := range tc.events {
+				object, opcode, body, err := readMessage(bytes.NewReader(event))
+				assert.Nil(t, err)
+				assert.Nil(t, w.handle(object, opcode, &wireArgs{body: body}))
+			}
+			window, err := w.activeWindow()
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.want, window)
+		})
+	}
+}
+
+func TestHandleMalformedEvent(t *testing.T) {
+	w := testWayland(t)
+	assert.Nil(t, w.handle(testManager, 0, &wireArgs{body: binary.NativeEndian.AppendUint32(nil, testEditor)}))
+	assert.NotNil(t, w.handle(testEditor, 1, &wireArgs{body: binary.NativeEndian.AppendUint32(nil, 64)}), "An app_id longer than the event should fail")
+	assert.NotNil(t, w.handle(wlDisplayID, 0, &wireArgs{body: encodeMessage(1, 0, uint32(testEditor), uint32(1), "invalid object")[8:]}))
+}
@@ -0,0 +1,95 @@
+//go:build linux
+
+package desktop
+
+import (
+	"context"
+	"time"
+)
+
+// Queries a compositor over its own IPC, which tells more than Wayland protocols: the process of a window, and the
+// workspace in use
+type compositorIPC interface {
+	activeWindow(ctx context.Context) (Window, error)
+	workspace(ctx context.Context) (string, error)
+}
+
+// wlroots based compositors, followed over the ext-idle-notify and wlr-foreign-toplevel-management Wayland protocols.
+// sway and Hyprland are asked for windows and workspaces over their IPC instead
+type wlroots struct {
+	name string
+	ipc  compositorIPC // nil for compositors without an IPC read
+	wl   waylandLink
+}
+
+func newWlroots(name string, ipc compositorIPC) *wlroots {
+	return &wlroots{name: name, ipc: ipc}
+}
+
+func (w *wlroots) Name() string { return w.name }
+
+func (w *wlroots) ActiveWindow(ctx context.Context) (Window, error) {
+	if w.ipc != nil {
+		return w.ipc.activeWindow(ctx)
+	}
+	conn, err := w.wl.get()
+	if err != nil {
+		return Window{}, err
+	}
+	return conn.activeWindow()
+}
+
+func (w *wlroots) Idle(ctx context.Context) (time.Duration, error) {
+	conn, err := w.wl.get()
+	if err != nil {
+		return 0, err
+	}
+	return conn.idle()
+}
+
+func (w *wlroots) Workspace(ctx context.Context) (string, error) {
+	if w.ipc != nil {
+		return w.ipc.workspace(ctx)
+	}
+	return "", ErrUnsupported
+}
+
+func (w *wlroots) Close() error { return w.wl.close() }
+
+// sway, asked with swaymsg
+type swayIPC struct{}
+
+func (swayIPC) activeWindow(ctx context.Context) (Window, error) {
+	out, err := run(ctx, "swaymsg", "-t", "get_tree", "-r")
+	if err != nil {
+		return Window{}, err
+	}
+	return parseSwayTree(out)
+}
+
+func (swayIPC) workspace(ctx context.Context) (string, error) {
+	out, err := run(ctx, "swaymsg", "-t", "get_workspaces", "-r")
+	if err != nil {
+		return "", err
+	}
+	return parseSwayWorkspaces(out)
+}
+
+// Hyprland, asked with hyprctl
+type hyprlandIPC struct{}
+
+func (hyprlandIPC) activeWindow(ctx context.Context) (Window, error) {
+	out, err := run(ctx, "hyprctl", "activewindow", "-j")
+	if err != nil {
+		return Window{}, err
+	}
+	return parseHyprlandWindow(out)
+}
+
+func (hyprlandIPC) workspace(ctx context.Context) (string, error) {
+	out, err := run(ctx, "hyprctl", "activeworkspace", "-j")
+	if err != nil {
+		return "", err
+	}
+	return parseHyprlandWorkspace(out)
+}
@@ -0,0 +1,75 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Time to wait for writes to the config file to settle before reloading
+const configReloadDelay = 500 * time.Millisecond
+
+// Watches the config file, reloading it when it changes. The directory is watched rather than the file,
+// as editors often save by replacing the file. Returns when ctx is cancelled, or with an error if watching fails
+func (e *EventController) WatchConfig(ctx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) error {
+	logger = logs.Component(logger, logs.ComponentConfig)
+
+	path, err := config.Path()
+	if err != nil {
+		return fmt.Errorf("error getting config path: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating config watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("error watching config directory: %w", err)
+	}
+
+	logger.Info("Watching config file for changes")
+
+	reload := time.NewTimer(configReloadDelay)
+	reload.Stop()
+	defer reload.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return errors.New("config watcher closed")
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(path) {
+				continue
+			}
+			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) {
+				reload.Reset(configReloadDelay)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return errors.New("config watcher closed")
+			}
+			logger.Error("Config watcher error", "error", err)
+
+		case <-reload.C:
+			if err := e.ReloadConfig(ctx, logger, sm, pr, a, h); err != nil {
+				logger.Error("Failed to reload config", "error", err)
+			}
+		}
+	}
+}
@@ -3,157 +3,529 @@ package events
 import (
 	"bufio"
 	"context"
+	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"os/exec"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/jms-guy/timekeep/cmd/service/internal/desktop"
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
 	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
 	"github.com/jms-guy/timekeep/internal/config"
 	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/profile"
+	"github.com/jms-guy/timekeep/internal/progname"
 	"github.com/jms-guy/timekeep/internal/repository"
 )
 
 var Version = "dev"
 
-// Command details communicated by pipe
-type Command struct {
-	Action      string `json:"action"`
-	ProcessName string `json:"name,omitempty"`
-	ProcessID   int    `json:"pid,omitempty"`
-}
-
 type EventController struct {
-	PsProcess  *exec.Cmd          // Powershell process for Windows event monitoring
-	mu         sync.Mutex         // Mutex for context cancellations
-	MonCancel  context.CancelFunc // Monitoring function cancel context
-	WakaCancel context.CancelFunc // WakaTime function cancel context
-	Config     *config.Config     // Struct built from config file
-	Client     *http.Client       // Http Client for Wakapi heartbeat requests
-	version    string             // Timekeep version
+	PsProcess      *exec.Cmd                      // Powershell process for Windows event monitoring
+	mu             sync.Mutex                     // Mutex for context cancellations
+	reloadMu       sync.Mutex                     // Serializes config reloads from IPC requests and the file watcher
+	MonCancel      context.CancelFunc             // Monitoring function cancel context
+	WakaCancel     context.CancelFunc             // WakaTime function cancel context
+	Shutdown       context.CancelFunc             // Cancels the service context, set by the service on start
+	config         atomic.Pointer[config.Config]  // Struct built from config file, replaced whole on reload
+	Client         *http.Client                   // Http Client for Wakapi heartbeat requests
+	version        string                         // Timekeep version
+	paused         bool                           // Monitoring paused by SCM or IPC request
+	standby        string                         // Profile in use instead of this service's, monitoring stopped until it's switched back
+	pausedUntil    time.Time                      // When a snooze resumes monitoring, zero for a pause until resumed
+	resumeTimer    *time.Timer                    // Pending end of a snooze
+	Snoozes        repository.SnoozeRepository    // Persists the end of a snooze across service restarts
+	asleep         bool                           // System is sleeping, monitoring stopped until it wakes
+	incognito      bool                           // Incognito mode on, nothing observed or recorded
+	Incognitos     repository.IncognitoRepository // Persists incognito mode and its end across service restarts
+	incognitoUntil time.Time                      // When incognito mode turns itself off, never if zero
+	incognitoTimer *time.Timer                    // Pending end of incognito mode
+	startedAt      time.Time                      // Time the controller was created, reported as service uptime
+	Logs           *logs.Logs                     // Service logs, level adjusted on config reload
+	AuthToken      string                         // Token clients must present on the first request of a connection
+	Tokens         repository.TokenRepository     // API tokens accepted besides AuthToken, limited to their scope
+	Groups         repository.GroupRepository     // Program groups, whose daily limits count the time of all their programs
+	Trace          bool                           // Foreground debug run, log every process considered and keep the debug level on reload
+	Desktop        desktop.Provider               // Active window, idle time and workspace of the user's desktop session
+	traced         map[int]struct{}               // PIDs already reported by trace logging
+	lastBeat       map[beatKey]time.Time          // Time of the last heartbeat delivered per destination and program, for rate limiting
+	limitWarned    map[string]string              // Day each limits.daily key was last warned of being over its limit
+	refresh        *time.Timer                    // Pending debounced refresh
 }
 
+// Time a remote peer has to send its first, authenticated, request
+var remoteHandshakeTimeout = 10 * time.Second
+
+// Delay before a refresh request is applied. Further requests in this window restart it, so a burst of CLI changes
+// restarts the monitor once
+const refreshDebounce = 500 * time.Millisecond
+
 func NewEventController() *EventController {
-	return &EventController{version: Version}
+	return &EventController{version: Version, startedAt: time.Now()}
+}
+
+// Handles service requests read from pipe/socket connection. The first request must carry the service token,
+// otherwise the connection is closed. Versioned requests receive a response line, legacy unversioned messages
+// (monitor script events) do not
+func (e *EventController) HandleConnection(serviceCtx context.Context, logger *slog.Logger, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, conn net.Conn) {
+	e.serveConnection(serviceCtx, logger, s, pr, a, h, conn, false)
+}
+
+// Handles service requests read from a remote TLS connection, as HandleConnection does, except for shutting the
+// service down and the unversioned process events only monitor scripts on this machine send
+func (e *EventController) HandleRemoteConnection(serviceCtx context.Context, logger *slog.Logger, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, conn net.Conn) {
+	e.serveConnection(serviceCtx, logger, s, pr, a, h, conn, true)
 }
 
-// Handles service commands read from pipe/socket connection
-func (e *EventController) HandleConnection(serviceCtx context.Context, logger *log.Logger, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, conn net.Conn) {
+func (e *EventController) serveConnection(serviceCtx context.Context, logger *slog.Logger, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, conn net.Conn, remote bool) {
 	defer conn.Close()
 
-	logger.Println("INFO: Starting to read from connection.")
+	logger.Debug("Starting to read from connection")
 
+	// Remote peers are anyone on the network, and must authenticate promptly or lose the connection
+	if remote {
+		conn.SetReadDeadline(time.Now().Add(remoteHandshakeTimeout))
+	}
+
+	encoder := json.NewEncoder(conn)
 	scanner := bufio.NewScanner(conn)
+	scope := "" // Scope granted by the connection's token, empty until authenticated
 	for scanner.Scan() {
-		line := scanner.Text()
-
-		var cmd Command
-		if err := json.Unmarshal([]byte(line), &cmd); err != nil {
-			logger.Printf("ERROR: Failed to unmarshal JSON from line '%s': %s", line, err)
+		var req ipc.Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			// Lines aren't logged, they may hold a token, and an unauthenticated peer could fill the log
+			if scope == "" {
+				logger.Warn("Rejected connection with malformed first request", "error", err)
+				return
+			}
+			logger.Error("Failed to unmarshal request", "length", len(scanner.Bytes()), "error", err)
 			continue
 		}
 
-		cmd.ProcessName = strings.ToLower(cmd.ProcessName)
+		if scope == "" {
+			scope = e.TokenScope(serviceCtx, logger, req.Token)
+			if scope == "" {
+				logger.Warn("Rejected connection with invalid token", "action", req.Action)
+				if req.Version > 0 {
+					encoder.Encode(ipc.ErrorResponse(ipc.CodeUnauthorized, "invalid or missing service token"))
+				}
+				return
+			}
+			if remote {
+				conn.SetReadDeadline(time.Time{})
+			}
+		}
 
-		cmdCtx, cancel := context.WithTimeout(serviceCtx, 5*time.Second)
+		req.ProcessName = progname.Normalize(req.ProcessName)
 
-		switch cmd.Action {
-		case "process_start":
-			s.CreateSession(cmdCtx, logger, a, cmd.ProcessName, cmd.ProcessID)
-			logger.Printf("INFO: Called createSession for %s (PID: %d)", cmd.ProcessName, cmd.ProcessID)
-		case "process_stop":
-			s.EndSession(cmdCtx, logger, pr, a, h, cmd.ProcessName, cmd.ProcessID)
-			logger.Printf("INFO: Called endSession for %s (PID: %d)", cmd.ProcessName, cmd.ProcessID)
-		case "refresh":
-			e.RefreshProcessMonitor(serviceCtx, logger, s, pr, a, h)
-			logger.Println("INFO: Called refreshProcessMonitor")
-		default:
-			logger.Printf("WARN: Received unknown command action: %s", cmd.Action)
+		var resp ipc.Response
+		if remote && localOnly(req) {
+			logger.Warn("Rejected local-only action from remote connection", "action", req.Action)
+			resp = ipc.ErrorResponse(ipc.CodeForbidden, fmt.Sprintf("%q is not served to remote clients", req.Action))
+		} else if ipc.Permits(scope, req.Action) {
+			cmdCtx, cancel := context.WithTimeout(serviceCtx, 5*time.Second)
+			resp = e.handleRequest(serviceCtx, cmdCtx, logger, s, pr, a, h, req)
+			cancel()
+		} else {
+			logger.Warn("Rejected action outside token scope", "action", req.Action, "scope", scope)
+			resp = ipc.ErrorResponse(ipc.CodeForbidden, fmt.Sprintf("token scope %q does not allow %q", scope, req.Action))
 		}
 
-		cancel()
+		if req.Version == 0 {
+			continue
+		}
+
+		if err := encoder.Encode(resp); err != nil {
+			logger.Error("Failed to write response", "action", req.Action, "error", err)
+			return
+		}
+
+		if req.Action == ipc.ActionShutdown && resp.OK {
+			e.Shutdown()
+			return
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		logger.Printf("ERROR: Error reading from pipe: %s", err)
+		logger.Error("Error reading from connection", "error", err)
+	}
+}
+
+// Reports whether req may only come over the local pipe/socket
+func localOnly(req ipc.Request) bool {
+	switch req.Action {
+	case ipc.ActionShutdown:
+		return true
+	case ipc.ActionProcessStart, ipc.ActionProcessStop:
+		return req.Version == 0
 	}
+	return false
+}
+
+// Returns the current config. Reloads replace it rather than change it, so callers reading several settings should
+// keep the one returned
+func (e *EventController) Config() *config.Config {
+	return e.config.Load()
+}
+
+// Replaces the current config
+func (e *EventController) SetConfig(cfg *config.Config) {
+	e.config.Store(cfg)
+}
+
+// Returns the scope granted by token: admin for the local service token, or the remote token when remote access is
+// enabled, and the stored scope for an API token. Empty when the token is not accepted
+func (e *EventController) TokenScope(ctx context.Context, logger *slog.Logger, token string) string {
+	if ipc.ValidToken(e.AuthToken, token) {
+		return ipc.ScopeAdmin
+	}
+	if cfg := e.Config(); cfg != nil && cfg.Remote.Enabled && ipc.ValidToken(cfg.Remote.Token, token) {
+		return ipc.ScopeAdmin
+	}
+	if e.Tokens == nil || !strings.HasPrefix(token, ipc.APITokenPrefix) {
+		return ""
+	}
+
+	apiToken, err := e.Tokens.GetAPITokenByHash(ctx, ipc.HashToken(token))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("Failed to look up API token", "error", err)
+		}
+		return ""
+	}
+
+	touch := database.TouchAPITokenParams{LastUsedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true}, ID: apiToken.ID}
+	if err := e.Tokens.TouchAPIToken(ctx, touch); err != nil {
+		logger.Warn("Failed to record API token use", "token", apiToken.Name, "error", err)
+	}
+	logger.Debug("Authenticated with API token", "token", apiToken.Name, "scope", apiToken.Scope)
+
+	return apiToken.Scope
+}
+
+// Dispatches a single request to its handler, returning the response to send for versioned requests
+func (e *EventController) handleRequest(serviceCtx, cmdCtx context.Context, logger *slog.Logger, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, req ipc.Request) ipc.Response {
+	switch {
+	case req.Version > ipc.ProtocolVersion:
+		logger.Warn("Received request from a newer client, update the service", "version", req.Version, "supported", ipc.ProtocolVersion)
+		return ipc.ErrorResponse(ipc.CodeUnsupportedVersion, fmt.Sprintf("client speaks protocol version %d, newer than the service's %d: please update the service", req.Version, ipc.ProtocolVersion))
+	case req.Version > 0 && req.Version < ipc.MinProtocolVersion:
+		logger.Warn("Received request from an outdated client", "version", req.Version, "oldest", ipc.MinProtocolVersion)
+		return ipc.ErrorResponse(ipc.CodeUnsupportedVersion, fmt.Sprintf("client speaks protocol version %d, older than the service's oldest, %d: please update the CLI", req.Version, ipc.MinProtocolVersion))
+	}
+
+	// Drop events the monitor sent before it stopped for incognito
+	if (req.Action == ipc.ActionProcessStart || req.Action == ipc.ActionProcessStop) && e.Incognito() {
+		return ipc.OKResponse(nil)
+	}
+
+	switch req.Action {
+	case ipc.ActionProcessStart:
+		if req.ProcessName == "" || req.ProcessID == 0 {
+			return ipc.ErrorResponse(ipc.CodeBadRequest, "process name and pid required")
+		}
+		// Only the Windows monitor's unversioned reports come from process discovery, clients may not have PIDs ended
+		if !e.allowStart(cmdCtx, logger, s, h, progname.Normalize(req.ProcessName), req.ProcessID, req.Version == 0 && runtime.GOOS == "windows") {
+			return ipc.OKResponse(nil)
+		}
+		s.CreateSession(cmdCtx, logger, a, req.ProcessName, req.ProcessID)
+		if req.Parent != "" {
+			s.RecordLaunch(req.ProcessName, consoleLaunch(req.Parent))
+		}
+		logger.Debug("Called createSession", "program", req.ProcessName, "pid", req.ProcessID)
+	case ipc.ActionProcessStop:
+		if req.ProcessName == "" || req.ProcessID == 0 {
+			return ipc.ErrorResponse(ipc.CodeBadRequest, "process name and pid required")
+		}
+		s.EndSession(cmdCtx, logger, pr, a, h, req.ProcessName, req.ProcessID)
+		logger.Debug("Called endSession", "program", req.ProcessName, "pid", req.ProcessID)
+	case ipc.ActionRefresh:
+		e.RequestRefresh(serviceCtx, logger, s, pr, a, h)
+		logger.Debug("Scheduled refreshProcessMonitor")
+	case ipc.ActionReloadConfig:
+		if err := e.ReloadConfig(serviceCtx, logger, s, pr, a, h); err != nil {
+			logger.Error("Failed to reload config", "error", err)
+			return ipc.ErrorResponse(ipc.CodeInternal, err.Error())
+		}
+	case ipc.ActionPause:
+		var pause ipc.Pause
+		if len(req.Payload) > 0 {
+			if err := json.Unmarshal(req.Payload, &pause); err != nil {
+				return ipc.ErrorResponse(ipc.CodeBadRequest, "invalid pause payload")
+			}
+		}
+		if pause.Until.IsZero() {
+			e.Pause(logger)
+		} else {
+			e.Snooze(serviceCtx, logger, s, pr, a, h, pause.Until)
+		}
+	case ipc.ActionResume:
+		e.Resume(serviceCtx, logger, s, pr, a, h)
+	case ipc.ActionIncognito:
+		var mode ipc.Incognito
+		if err := json.Unmarshal(req.Payload, &mode); err != nil {
+			return ipc.ErrorResponse(ipc.CodeBadRequest, "incognito mode required")
+		}
+		e.SetIncognito(serviceCtx, logger, s, pr, a, h, mode)
+	case ipc.ActionQueryActive:
+		return ipc.OKResponse(s.Snapshot())
+	case ipc.ActionHealth:
+		return ipc.OKResponse(e.Health(cmdCtx, s, pr))
+	case ipc.ActionMetrics:
+		return ipc.OKResponse(s.Metrics.Snapshot())
+	case ipc.ActionReconcile:
+		result, err := s.Reconcile(cmdCtx, logger, a)
+		if err != nil {
+			logger.Error("Failed to reconcile active sessions", "error", err)
+			return ipc.ErrorResponse(ipc.CodeInternal, err.Error())
+		}
+		return ipc.OKResponse(result)
+	case ipc.ActionShutdown:
+		if e.Shutdown == nil {
+			return ipc.ErrorResponse(ipc.CodeInternal, "shutdown not available")
+		}
+		logger.Info("Received shutdown request")
+	default:
+		logger.Warn("Received unknown command action", "action", req.Action)
+		return ipc.ErrorResponse(ipc.CodeUnknownAction, fmt.Sprintf("unknown action %q", req.Action))
+	}
+
+	return ipc.OKResponse(nil)
+}
+
+// Shells and console hosts that start programs from a terminal on Windows
+var consoles = map[string]bool{"cmd": true, "powershell": true, "pwsh": true, "windowsterminal": true, "openconsole": true, "conhost": true, "bash": true, "wsl": true}
+
+// Builds the launch context of a process the Windows monitor reported started by parent. Windows doesn't tie processes
+// to terminals, so one started by a shell or console host counts as started in a terminal hosted by it
+func consoleLaunch(parent string) repository.LaunchContext {
+	launch := repository.LaunchContext{Parent: progname.Normalize(parent)}
+	if consoles[launch.Parent] {
+		launch.Terminal = launch.Parent
+	}
+	return launch
+}
+
+// Reports service version, start time, database connectivity and monitor state
+func (e *EventController) Health(ctx context.Context, s *sessions.SessionManager, pr repository.ProgramRepository) ipc.Health {
+	health := ipc.Health{
+		Version:   e.version,
+		StartedAt: e.startedAt.UTC(),
+		Database:  "ok",
+	}
+
+	programs, err := pr.GetAllPrograms(ctx)
+	if err != nil {
+		s.Metrics.DBErrors.Add(1)
+		health.Database = err.Error()
+	}
+	health.TrackedPrograms = len(programs)
+
+	health.Profile = profile.Name()
+
+	e.mu.Lock()
+	switch {
+	case e.incognito:
+		health.Monitor = "incognito"
+		health.IncognitoUntil = e.incognitoUntil
+	case e.standby != "":
+		health.Monitor = "standby"
+		health.ActiveProfile = e.standby
+	case e.paused:
+		health.Monitor = "paused"
+		health.PausedUntil = e.pausedUntil
+	case e.MonCancel != nil:
+		health.Monitor = "running"
+	default:
+		health.Monitor = "stopped"
+	}
+	e.mu.Unlock()
+
+	health.ActiveSessions = len(s.Snapshot())
+	if e.Desktop != nil {
+		health.Desktop = e.Desktop.Name()
+	}
+
+	return health
+}
+
+// Stops process monitoring and heartbeats until Resume is called, replacing any snooze
+func (e *EventController) Pause(logger *slog.Logger) {
+	e.clearSnooze(logger)
+	e.pause(logger)
+}
+
+func (e *EventController) pause(logger *slog.Logger) {
+	e.mu.Lock()
+	e.paused = true
+	e.mu.Unlock()
+
+	logger.Info("Pausing monitoring")
+	e.StopHeartbeats()
+	e.StopProcessMonitor()
+}
+
+// Restarts process monitoring and heartbeats after a pause or snooze
+func (e *EventController) Resume(serviceCtx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
+	e.mu.Lock()
+	e.paused = false
+	e.mu.Unlock()
+	e.clearSnooze(logger)
+
+	logger.Info("Resuming monitoring")
+	e.RefreshProcessMonitor(serviceCtx, logger, sm, pr, a, h)
+}
+
+// Reports whether monitoring is currently paused, or standing by while another profile is in use
+func (e *EventController) Paused() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.paused || e.standby != ""
+}
+
+// Schedules RefreshProcessMonitor after refreshDebounce, coalescing refresh requests received before it runs
+func (e *EventController) RequestRefresh(serviceCtx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.refresh != nil && e.refresh.Stop() {
+		logger.Debug("Coalesced refresh request into pending refresh")
+	}
+	e.refresh = time.AfterFunc(refreshDebounce, func() {
+		if serviceCtx.Err() != nil {
+			return
+		}
+		e.reloadMu.Lock()
+		defer e.reloadMu.Unlock()
+		e.RefreshProcessMonitor(serviceCtx, logger, sm, pr, a, h)
+	})
 }
 
 // Stops the currently running process monitoring script, and starts a new one with updated program list
-func (e *EventController) RefreshProcessMonitor(serviceCtx context.Context, logger *log.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
+func (e *EventController) RefreshProcessMonitor(serviceCtx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
 	e.StopHeartbeats()
 	e.StopProcessMonitor()
 
 	newConfig, err := config.Load()
 	if err != nil {
-		logger.Printf("ERROR: Failed to load config: %s", err)
+		logger.Error("Failed to load config", "error", err)
 		return
 	}
 
-	e.Config = newConfig
+	e.SetConfig(newConfig)
+	sm.SetDevice(newConfig.DeviceName())
+	sm.SetMaxSession(newConfig.MaxSessionLength())
 
 	programs, err := pr.GetAllPrograms(context.Background())
 	if err != nil {
-		logger.Printf("ERROR: Failed to get programs: %s", err)
+		sm.Metrics.DBErrors.Add(1)
+		logger.Error("Failed to get programs", "error", err)
 		return
 	}
 
-	if len(programs) > 0 {
-		toTrack := updateSessionsMapOnRefresh(sm, programs)
+	toTrack := sm.LoadPrograms(programs)
 
+	if e.CheckProfile(logger, sm, pr, a, h) {
+		logger.Info("Profile not in use, not restarting monitor")
+		return
+	}
+	if e.Paused() {
+		logger.Info("Monitoring paused, not restarting monitor")
+		return
+	}
+	if e.Asleep() {
+		logger.Info("System asleep, not restarting monitor")
+		return
+	}
+	if e.Incognito() {
+		logger.Info("Incognito, not restarting monitor")
+		return
+	}
+
+	if len(programs) > 0 {
 		e.StartMonitor(serviceCtx, logger, sm, pr, a, h, toTrack)
 	}
 
-	if e.Config.WakaTime.Enabled || e.Config.Wakapi.Enabled {
+	if newConfig.WakaTime.Enabled || newConfig.Wakapi.Enabled {
 		e.StartHeartbeats(serviceCtx, logger, sm)
 	}
 
-	logger.Printf("INFO: Process monitor refresh with %d programs", len(programs))
+	logger.Info("Process monitor refreshed", "programs", len(programs))
+}
+
+// Logs each problem found in the config, so typos that disable a feature show up in the service log
+func LogConfigProblems(logger *slog.Logger, cfg *config.Config) {
+	for _, problem := range cfg.Validate() {
+		logger.Warn("Config problem, run 'timekeep config validate'", "key", problem.Key, "problem", problem.Message)
+	}
 }
 
-// Takes list of programs from database, and updates session map by adding/removing/altering based on any changes from last database grab
-func updateSessionsMapOnRefresh(sm *sessions.SessionManager, programs []database.TrackedProgram) []string {
-	desired := make(map[string]struct{}, len(programs))
-	toTrack := make([]string, 0, len(programs))
+// Reloads the config file in place, restarting only the monitor or heartbeats when their settings changed
+func (e *EventController) ReloadConfig(serviceCtx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) error {
+	logger = logs.Component(logger, logs.ComponentConfig)
 
-	sm.Mu.Lock()
-	currentKeys := make([]string, 0, len(sm.Programs))
-	for k := range sm.Programs {
-		currentKeys = append(currentKeys, k)
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+
+	newConfig, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("error reloading config: %w", err)
 	}
 
-	for _, p := range programs {
-		name := p.Name
-		cat := ""
-		if p.Category.Valid {
-			cat = p.Category.String
-		}
-		proj := ""
-		if p.Project.Valid {
-			proj = p.Project.String
-		}
+	LogConfigProblems(logger, newConfig)
+
+	old := e.config.Swap(newConfig)
+	sm.SetDevice(newConfig.DeviceName())
+	sm.SetMaxSession(newConfig.MaxSessionLength())
+	if e.Logs != nil && !e.Trace {
+		e.Logs.SetLevel(newConfig.Log.Level)
+	}
+
+	monitorChanged := old == nil || old.PollInterval != newConfig.PollInterval || old.PollGrace != newConfig.PollGrace || old.Scope != newConfig.Scope
+	heartbeatsChanged := old == nil || old.WakaTime != newConfig.WakaTime || old.Wakapi != newConfig.Wakapi
 
-		sm.EnsureProgram(name, cat, proj)
-		desired[name] = struct{}{}
-		toTrack = append(toTrack, name)
+	if !monitorChanged && !heartbeatsChanged {
+		logger.Info("Config reloaded, no changes")
+		return nil
 	}
 
-	if len(desired) == 0 {
-		for _, k := range currentKeys {
-			delete(sm.Programs, k)
+	if e.Paused() || e.Incognito() {
+		logger.Info("Config reloaded while paused, changes apply on resume")
+		return nil
+	}
+
+	if monitorChanged {
+		programs, err := pr.GetAllPrograms(context.Background())
+		if err != nil {
+			sm.Metrics.DBErrors.Add(1)
+			return fmt.Errorf("error getting programs: %w", err)
 		}
-	} else {
-		for _, k := range currentKeys {
-			if _, keep := desired[k]; !keep {
-				delete(sm.Programs, k)
-			}
+
+		toTrack := sm.LoadPrograms(programs)
+		e.StopProcessMonitor()
+		if len(toTrack) > 0 {
+			e.StartMonitor(serviceCtx, logger, sm, pr, a, h, toTrack)
+		}
+		logger.Info("Config reloaded, process monitor restarted")
+	}
+
+	if heartbeatsChanged {
+		e.StopHeartbeats()
+		if newConfig.WakaTime.Enabled || newConfig.Wakapi.Enabled {
+			e.StartHeartbeats(serviceCtx, logger, sm)
 		}
+		logger.Info("Config reloaded, heartbeats reconfigured")
 	}
-	sm.Mu.Unlock()
 
-	return toTrack
+	return nil
 }
@@ -0,0 +1,142 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/repository"
+	mysql "github.com/jms-guy/timekeep/sql"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func testStore(t *testing.T) repository.Store {
+	db, err := mysql.OpenTestDatabase()
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return repository.NewSqliteStore(db)
+}
+
+// Sends req over conn and reads the service's response
+func roundTrip(t *testing.T, conn net.Conn, reader *bufio.Reader, req ipc.Request) ipc.Response {
+	data, _ := json.Marshal(req)
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	var resp ipc.Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return resp
+}
+
+func TestRemoteConnectionRefusesLocalActions(t *testing.T) {
+	store := testStore(t)
+	e := NewEventController()
+	e.AuthToken = "secret"
+	shutdown := false
+	e.Shutdown = func() { shutdown = true }
+	logger := slog.New(slog.DiscardHandler)
+	sm := sessions.NewSessionManager()
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go e.HandleRemoteConnection(t.Context(), logger, sm, store, store, store, server)
+	reader := bufio.NewReader(client)
+
+	req := ipc.NewRequest(ipc.ActionHealth)
+	req.Token = "secret"
+	resp := roundTrip(t, client, reader, req)
+	assert.True(t, resp.OK, "Remote clients should be served health")
+
+	resp = roundTrip(t, client, reader, ipc.NewRequest(ipc.ActionShutdown))
+	assert.False(t, resp.OK)
+	assert.Equal(t, ipc.CodeForbidden, resp.Code, "Remote clients should not shut the service down")
+	assert.False(t, shutdown)
+
+	assert.True(t, localOnly(ipc.Request{Action: ipc.ActionProcessStart}), "Unversioned process events come from local monitors only")
+	assert.False(t, localOnly(ipc.NewRequest(ipc.ActionProcessStart)))
+	assert.False(t, localOnly(ipc.NewRequest(ipc.ActionQueryActive)))
+
+	local, server := net.Pipe()
+	defer local.Close()
+	go e.HandleConnection(t.Context(), logger, sm, store, store, store, server)
+	req = ipc.NewRequest(ipc.ActionShutdown)
+	req.Token = "secret"
+	resp = roundTrip(t, local, bufio.NewReader(local), req)
+	assert.True(t, resp.OK, "Local clients should shut the service down")
+}
+
+func TestRemoteConnectionHandshake(t *testing.T) {
+	store := testStore(t)
+	e := NewEventController()
+	e.AuthToken = "secret"
+	logger := slog.New(slog.DiscardHandler)
+	sm := sessions.NewSessionManager()
+	timeout := remoteHandshakeTimeout
+	remoteHandshakeTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { remoteHandshakeTimeout = timeout })
+
+	// A first line that isn't a request closes the connection, rather than being skipped
+	client, server := net.Pipe()
+	defer client.Close()
+	go e.HandleRemoteConnection(t.Context(), logger, sm, store, store, store, server)
+	_, err := client.Write([]byte("not a request\n"))
+	assert.Nil(t, err)
+	_, err = client.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF, "A malformed first request should close the connection")
+
+	// Peers sending nothing are dropped after the handshake timeout
+	client, server = net.Pipe()
+	defer client.Close()
+	go e.HandleRemoteConnection(t.Context(), logger, sm, store, store, store, server)
+	_, err = client.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF, "A silent peer should be disconnected")
+
+	// Authenticated connections stay open past it
+	client, server = net.Pipe()
+	defer client.Close()
+	go e.HandleRemoteConnection(t.Context(), logger, sm, store, store, store, server)
+	reader := bufio.NewReader(client)
+	req := ipc.NewRequest(ipc.ActionHealth)
+	req.Token = "secret"
+	assert.True(t, roundTrip(t, client, reader, req).OK)
+	time.Sleep(2 * remoteHandshakeTimeout)
+	assert.True(t, roundTrip(t, client, reader, ipc.NewRequest(ipc.ActionHealth)).OK, "The deadline should be lifted once authenticated")
+}
+
+func TestProtocolVersions(t *testing.T) {
+	store := testStore(t)
+	e := NewEventController()
+	e.AuthToken = "secret"
+	logger := slog.New(slog.DiscardHandler)
+	sm := sessions.NewSessionManager()
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go e.HandleConnection(t.Context(), logger, sm, store, store, store, server)
+	reader := bufio.NewReader(client)
+
+	// CLIs from before the actions of version 2 are still served
+	resp := roundTrip(t, client, reader, ipc.Request{Version: 1, Action: ipc.ActionQueryActive, Token: "secret"})
+	assert.True(t, resp.OK, "Requests of the oldest version understood should be served")
+	assert.Equal(t, ipc.ProtocolVersion, resp.Version)
+
+	resp = roundTrip(t, client, reader, ipc.Request{Version: ipc.ProtocolVersion + 1, Action: "newer_action"})
+	assert.Equal(t, ipc.CodeUnsupportedVersion, resp.Code, "Requests of a newer version should be refused, not answered as unknown actions")
+	var versionErr *ipc.VersionError
+	assert.ErrorAs(t, resp.Negotiate(), &versionErr)
+}
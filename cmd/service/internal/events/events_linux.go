@@ -7,20 +7,27 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
 	"os"
+	"os/user"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
 	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/cmd/service/internal/supervisor"
+	"github.com/jms-guy/timekeep/internal/progname"
 	"github.com/jms-guy/timekeep/internal/repository"
 )
 
 // Linux specific event functions, handling PID tracking through /proc polling
 
-func (e *EventController) StartMonitor(parent context.Context, logger *log.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, programs []string) {
+func (e *EventController) StartMonitor(parent context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, programs []string) {
+	logger = logs.Component(logger, logs.ComponentMonitor)
+
 	e.mu.Lock()
 	if e.MonCancel != nil {
 		e.MonCancel()
@@ -30,49 +37,60 @@ func (e *EventController) StartMonitor(parent context.Context, logger *log.Logge
 	e.MonCancel = cancel
 	e.mu.Unlock()
 
-	go e.MonitorProcesses(ctx, logger, sm, pr, a, h, programs)
+	supervisor.Go(ctx, logger, &sm.Metrics.TaskRestarts, "monitor", func(ctx context.Context) error {
+		e.MonitorProcesses(ctx, logger, sm, pr, a, h, programs)
+		return errors.New("process monitor exited")
+	})
 }
 
 // Main process monitoring function for Linux version
-func (e *EventController) MonitorProcesses(ctx context.Context, logger *log.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, programs []string) {
-	logger.Println("INFO: Executing main process monitor")
+func (e *EventController) MonitorProcesses(ctx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, programs []string) {
+	if e.Config().SharedScope() {
+		logger.Info("Executing main process monitor", "scope", "shared")
+	} else {
+		logger.Info("Executing main process monitor", "scope", "user", "uid", os.Getuid())
+	}
+	if e.Trace {
+		logger.Debug("Tracing process matching", "programs", programs)
+	}
 
 	pollInterval := e.pollTime()
 	ticker := time.NewTicker(pollInterval)
 	defer ticker.Stop()
 
 	// Grace period for PID tracking, to allow for accidently missed PIDs while polling
-	if e.Config.PollGrace <= 0 {
-		e.Config.PollGrace = 3
+	polls := e.Config().PollGrace
+	if polls <= 0 {
+		polls = 3
 	}
-	grace := pollInterval * time.Duration(e.Config.PollGrace)
+	grace := pollInterval * time.Duration(polls)
 
 	for {
 		select {
 		case <-ctx.Done():
-			logger.Println("INFO: Monitor context cancelled")
+			logger.Info("Monitor context cancelled")
 			return
 		case <-ticker.C:
-			livePIDS := e.checkForProcessStartEvents(logger, sm, a)
+			livePIDS := e.checkForProcessStartEvents(logger, sm, a, h)
 			e.checkForProcessStopEvents(logger, sm, pr, a, h, livePIDS, grace)
 		}
 	}
 }
 
 // Polls /proc and loops over PID entries, looking for any new PIDS belonging to tracked programs
-func (e *EventController) checkForProcessStartEvents(logger *log.Logger, sm *sessions.SessionManager, a repository.ActiveRepository) map[int]struct{} {
+func (e *EventController) checkForProcessStartEvents(logger *slog.Logger, sm *sessions.SessionManager, a repository.ActiveRepository, h repository.HistoryRepository) map[int]struct{} {
 	entries, err := os.ReadDir("/proc") // Read /proc
 	if err != nil {
-		logger.Printf("ERROR: Couldn't read /proc: %s", err)
+		logger.Error("Couldn't read /proc", "error", err)
 		return nil
 	}
 
 	live := make(map[int]struct{})
-	for _, e := range entries { // Loop over PID entries
-		if !e.IsDir() {
+	for _, entry := range entries { // Loop over PID entries
+		if !entry.IsDir() {
 			continue
 		}
-		pid, ok := parsePID(e.Name())
+		pid, ok := parsePID(entry.Name())
 		if !ok {
 			continue
 		}
@@ -80,26 +98,43 @@ func (e *EventController) checkForProcessStartEvents(logger *log.Logger, sm *ses
 
 		identity, err := getProgramIdentity(pid)
 		if err != nil {
+			e.traceProcess(logger, pid, identity, err, false)
 			continue
 		}
 
-		sm.Mu.Lock()
-		_, match := sm.Programs[identity] // Is program being tracked?
-		if !match {
-			sm.Mu.Unlock()
+		if !sm.IsTracked(identity) { // Is program being tracked?
+			e.traceProcess(logger, pid, identity, nil, false)
 			continue
 		}
 
-		if t := sm.Programs[identity]; t != nil {
-			if _, exists := t.PIDs[pid]; exists {
-				t.LastSeen = time.Now()
-				sm.Mu.Unlock()
-				continue
-			}
+		if !e.inScope(pid) {
+			e.traceProcess(logger, pid, identity, errOtherUser, false)
+			continue
+		}
+
+		if t := sm.Lookup(identity); t != nil && t.Seen(pid, time.Now()) {
+			continue
 		}
-		sm.Mu.Unlock()
 
+		if !e.allowStart(context.Background(), logger, sm, h, identity, pid, true) {
+			continue
+		}
+
+		e.traceProcess(logger, pid, identity, nil, true)
 		sm.CreateSession(context.Background(), logger, a, identity, pid)
+		if launch, err := readLaunchContext(pid); err == nil {
+			sm.RecordLaunch(identity, launch)
+		}
+	}
+
+	if e.Trace {
+		e.mu.Lock()
+		for pid := range e.traced { // Forget exited PIDs, so reused PIDs are reported again
+			if _, ok := live[pid]; !ok {
+				delete(e.traced, pid)
+			}
+		}
+		e.mu.Unlock()
 	}
 
 	return live
@@ -107,12 +142,11 @@ func (e *EventController) checkForProcessStartEvents(logger *log.Logger, sm *ses
 
 // Takes the PID entries found in the previous check function, and compares them against map of active PIDs, to determine if
 // any active sessions need ending
-func (e *EventController) checkForProcessStopEvents(logger *log.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, livePIDs map[int]struct{}, grace time.Duration) {
+func (e *EventController) checkForProcessStopEvents(logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, livePIDs map[int]struct{}, grace time.Duration) {
 	if livePIDs == nil {
 		livePIDs = map[int]struct{}{}
 	}
 
-	sm.Mu.Lock()
 	type toEnd struct {
 		program string
 		pid     int
@@ -122,29 +156,84 @@ func (e *EventController) checkForProcessStopEvents(logger *log.Logger, sm *sess
 	now := time.Now()
 	// Loop tracked programs. For each PID currently being tracked, check if it exists in the live map. If it does, update last seen value,
 	// else schedule the PID to be removed from tracking
+	sm.Mu.RLock()
 	for program, t := range sm.Programs {
 		if t == nil {
 			continue
 		}
 
-		for pid := range t.PIDs {
-			if _, ok := livePIDs[pid]; ok {
-				t.LastSeen = now
-				continue
-			}
-
-			if now.Sub(t.LastSeen) >= grace {
-				ends = append(ends, toEnd{program, pid})
+		expired, pending := t.Missing(livePIDs, now, grace)
+		for _, pid := range expired {
+			ends = append(ends, toEnd{program, pid})
+		}
+		if e.Trace {
+			for _, pid := range pending {
+				logger.Debug("Tracked PID missed, within grace", "program", program, "pid", pid, "grace", grace)
 			}
 		}
 	}
-	sm.Mu.Unlock()
+	sm.Mu.RUnlock()
 
 	for _, eend := range ends {
+		if e.Trace {
+			logger.Debug("Tracked PID gone past grace, ending", "program", eend.program, "pid", eend.pid)
+		}
 		sm.EndSession(context.Background(), logger, pr, a, h, eend.program, eend.pid)
 	}
 }
 
+// Reports the matching decision for a newly seen process when tracing. Each PID is reported once while it lives
+func (e *EventController) traceProcess(logger *slog.Logger, pid int, identity string, err error, matched bool) {
+	if !e.Trace {
+		return
+	}
+	e.mu.Lock()
+	if e.traced == nil {
+		e.traced = make(map[int]struct{})
+	}
+	_, seen := e.traced[pid]
+	e.traced[pid] = struct{}{}
+	e.mu.Unlock()
+	if seen {
+		return
+	}
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist): // Kernel threads and processes that exited mid-poll
+		return
+	case errors.Is(err, errOtherUser):
+		logger.Debug("Process skipped, owned by another user", "pid", pid, "program", identity)
+	case errors.Is(err, fs.ErrPermission):
+		logger.Debug("Process skipped, identity not readable without CAP_SYS_PTRACE/CAP_DAC_READ_SEARCH", "pid", pid, "error", err)
+	case err != nil:
+		logger.Debug("Process skipped, identity not readable", "pid", pid, "error", err)
+	case matched:
+		logger.Debug("Process matched tracked program", "pid", pid, "program", identity)
+	default:
+		exe, _ := readExePath(pid)
+		argv0, _ := readCmdline(pid)
+		logger.Debug("Process not tracked", "pid", pid, "identity", identity, "exe", exe, "argv0", argv0)
+	}
+}
+
+// Reported when tracing processes of tracked programs that belong to other users
+var errOtherUser = errors.New("process owned by another user")
+
+// Reports whether pid is tracked under the configured scope: in user scope only processes owned by the user the
+// service runs as are, in shared scope every user's are
+func (e *EventController) inScope(pid int) bool {
+	if e.Config().SharedScope() {
+		return true
+	}
+
+	info, err := os.Stat(filepath.Join("/proc", strconv.Itoa(pid)))
+	if err != nil {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Getuid()
+}
+
 func (e *EventController) StopProcessMonitor() {
 	e.mu.Lock()
 	if e.MonCancel != nil {
@@ -156,10 +245,11 @@ func (e *EventController) StopProcessMonitor() {
 
 // Determine the polling interval of the monitoring process through config value - defaults to 1s
 func (e *EventController) pollTime() time.Duration {
-	if e.Config.PollInterval == "" {
+	interval := e.Config().PollInterval
+	if interval == "" {
 		return 1 * time.Second
 	}
-	d, err := time.ParseDuration(e.Config.PollInterval)
+	d, err := time.ParseDuration(interval)
 	if err != nil || d <= 0 {
 		return 1 * time.Second
 	}
@@ -201,12 +291,12 @@ func readCmdline(pid int) (string, error) {
 // Get identity of process by reading exe and cmdline paths
 func getProgramIdentity(pid int) (string, error) {
 	if exe, err := readExePath(pid); err == nil && exe != "" {
-		return normalizeBase(exe), nil
+		return progname.Normalize(exe), nil
 	} else if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
 		return "", err
 	}
 	if argv0, err := readCmdline(pid); err == nil && argv0 != "" {
-		return normalizeBase(argv0), nil
+		return progname.Normalize(argv0), nil
 	} else if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
 		return "", err
 	}
@@ -214,11 +304,87 @@ func getProgramIdentity(pid int) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return normalizeBase(strings.TrimSpace(string(b))), nil
+	return progname.Normalize(string(b)), nil
+}
+
+// Most ancestors walked looking for the program hosting a process's terminal
+const maxAncestors = 32
+
+// Reads how pid was started: the name of its parent, the user it runs as and, when it's attached to a terminal, the
+// program hosting that terminal. That's its first ancestor not attached to the same terminal, such as a terminal
+// emulator, the tmux server or sshd, or "console" when there's none below init, as on a virtual console
+func readLaunchContext(pid int) (repository.LaunchContext, error) {
+	ppid, tty, err := readStat(pid)
+	if err != nil {
+		return repository.LaunchContext{}, err
+	}
+
+	launch := repository.LaunchContext{User: processUser(pid)}
+	launch.Parent, _ = getProgramIdentity(ppid)
+	if tty == 0 {
+		return launch, nil
+	}
+
+	launch.Terminal = "console"
+	for ancestor, depth := ppid, 0; ancestor > 1 && depth < maxAncestors; depth++ {
+		parent, attached, err := readStat(ancestor)
+		if err != nil {
+			break
+		}
+		if attached != tty {
+			if host, err := getProgramIdentity(ancestor); err == nil {
+				launch.Terminal = host
+			}
+			break
+		}
+		ancestor = parent
+	}
+	return launch, nil
+}
+
+// Reads the parent PID and controlling terminal device of pid from /proc/{pid}/stat, the terminal being 0 for none
+func readStat(pid int) (int, int, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// The command name is in parentheses and may hold spaces or parentheses itself, so fields are counted from the last
+	// closing one: state, ppid, pgrp, session, tty_nr
+	end := strings.LastIndexByte(string(b), ')')
+	if end < 0 {
+		return 0, 0, fmt.Errorf("invalid stat for pid %d", pid)
+	}
+	fields := strings.Fields(string(b[end+1:]))
+	if len(fields) < 5 {
+		return 0, 0, fmt.Errorf("invalid stat for pid %d", pid)
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid parent pid for pid %d: %w", pid, err)
+	}
+	tty, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid terminal for pid %d: %w", pid, err)
+	}
+	return ppid, tty, nil
 }
 
-func normalizeBase(s string) string {
-	return strings.ToLower(filepath.Base(s))
+// Returns the name of the user owning pid, or their uid when it can't be looked up
+func processUser(pid int) string {
+	info, err := os.Stat(filepath.Join("/proc", strconv.Itoa(pid)))
+	if err != nil {
+		return ""
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	uid := strconv.Itoa(int(stat.Uid))
+	if u, err := user.LookupId(uid); err == nil {
+		return u.Username
+	}
+	return uid
 }
 
 func parsePID(name string) (int, bool) {
@@ -4,16 +4,23 @@ package events
 
 import (
 	"context"
-	"log"
+	"log/slog"
 
 	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
 	"github.com/jms-guy/timekeep/internal/repository"
 )
 
-func (e *EventController) MonitorProcesses(ctx context.Context, logger *log.Logger, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, programs []string) {
+func (e *EventController) MonitorProcesses(ctx context.Context, logger *slog.Logger, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, programs []string) {
 	return
 }
 
 func (e *EventController) StopProcessMonitor() {
 	return
 }
+
+func (e *EventController) WatchSleep(ctx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) error {
+	return nil
+}
+
+func (e *EventController) rescan(logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
+}
@@ -7,7 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
@@ -17,11 +17,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
 	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/cmd/service/internal/supervisor"
 )
 
 // Start WakaTime/Wakapi heartbeat ticker
-func (e *EventController) StartHeartbeats(parent context.Context, logger *log.Logger, sm *sessions.SessionManager) {
+func (e *EventController) StartHeartbeats(parent context.Context, logger *slog.Logger, sm *sessions.SessionManager) {
+	logger = logs.Component(logger, logs.ComponentHeartbeats)
+
 	newCtx, newCancel := context.WithCancel(parent)
 
 	e.mu.Lock()
@@ -33,8 +37,8 @@ func (e *EventController) StartHeartbeats(parent context.Context, logger *log.Lo
 		oldCancel()
 	}
 
-	if e.Config.Wakapi.Enabled && e.Client == nil {
-		logger.Println("INFO: Initializing Wakapi Http client")
+	if e.Config().Wakapi.Enabled && e.Client == nil {
+		logger.Info("Initializing Wakapi HTTP client")
 		e.Client = &http.Client{
 			Timeout: 30 * time.Second,
 			Transport: &http.Transport{
@@ -45,60 +49,201 @@ func (e *EventController) StartHeartbeats(parent context.Context, logger *log.Lo
 		}
 	}
 
-	logger.Println("INFO: Starting heartbeats")
+	logger.Info("Starting heartbeats")
+
+	// Batches are collected on the ticker and delivered by a separate sender, so a slow server never delays collection
+	queue := make(chan heartbeatBatch, heartbeatQueueSize)
+	sm.Metrics.HeartbeatQueue.Store(0)
 
-	go func(ctx context.Context) {
+	supervisor.Go(newCtx, logger, &sm.Metrics.TaskRestarts, "heartbeats", func(ctx context.Context) error {
 		ticker := time.NewTicker(time.Minute)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ctx.Done():
-				logger.Println("INFO: Stopping heartbeats")
-				return
+				logger.Info("Stopping heartbeats")
+				return nil
 			case <-ticker.C:
-				e.sendHeartbeats(ctx, logger, sm)
+				e.queueHeartbeats(logger, sm, queue)
+			}
+		}
+	})
+
+	supervisor.Go(newCtx, logger, &sm.Metrics.TaskRestarts, "heartbeat sender", func(ctx context.Context) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case batch := <-queue:
+				sm.Metrics.HeartbeatQueue.Store(int64(len(queue)))
+				e.sendHeartbeats(ctx, logger, sm, batch)
 			}
 		}
-	}(newCtx)
+	})
 }
 
-// Send specified heartbeats to WakaTime/Wakapi
-func (e *EventController) sendHeartbeats(ctx context.Context, logger *log.Logger, sm *sessions.SessionManager) {
-	type item struct{ program, category, project string }
-	items := []item{}
-	var err error
+// Heartbeat batches held while waiting for delivery. When full, the oldest batch is dropped
+const heartbeatQueueSize = 30
+
+// Programs due a heartbeat at one tick
+type heartbeatBatch struct {
+	items []heartbeatItem
+	at    time.Time
+}
+
+// Minimum gap between heartbeats for the same program. WakaTime plugins send at most one heartbeat per entity every
+// two minutes, as more frequent ones add no tracked time
+const heartbeatRateLimit = 2 * time.Minute
+
+// Heartbeat as accepted by wakatime-cli --extra-heartbeats and the Wakapi bulk endpoint
+type heartbeat struct {
+	Entity   string  `json:"entity"`
+	Type     string  `json:"type"`
+	Category string  `json:"category"`
+	Project  string  `json:"project,omitempty"`
+	Time     float64 `json:"time"`
+	IsWrite  bool    `json:"is_write"`
+}
+
+type heartbeatItem struct{ program, category, project string }
+
+// Destinations heartbeats are delivered to, each rate limited on its own
+const (
+	destWakaTime = "wakatime"
+	destWakapi   = "wakapi"
+)
+
+type beatKey struct{ dest, program string }
+
+// Collects running programs due a heartbeat and queues them for the sender, dropping the oldest queued batch if full
+func (e *EventController) queueHeartbeats(logger *slog.Logger, sm *sessions.SessionManager, queue chan heartbeatBatch) {
+	items := []heartbeatItem{}
 
-	sm.Mu.Lock()
+	sm.Mu.RLock()
 	for p, t := range sm.Programs {
-		if len(t.PIDs) > 0 && t.Category != "" {
-			items = append(items, item{p, t.Category, t.Project})
+		if category, project, running := t.Details(); running && category != "" {
+			items = append(items, heartbeatItem{p, category, project})
+		}
+	}
+	sm.Mu.RUnlock()
+
+	now := time.Now()
+	cfg := e.Config()
+	due := 0
+	if cfg.WakaTime.Enabled {
+		due += len(e.dueHeartbeats(destWakaTime, items, now))
+	}
+	if cfg.Wakapi.Enabled {
+		due += len(e.dueHeartbeats(destWakapi, items, now))
+	}
+	if due == 0 {
+		return
+	}
+
+	batch := heartbeatBatch{items: items, at: now}
+	for {
+		select {
+		case queue <- batch:
+			sm.Metrics.HeartbeatQueue.Store(int64(len(queue)))
+			return
+		default:
+		}
+
+		select {
+		case dropped := <-queue:
+			sm.Metrics.HeartbeatsDropped.Add(1)
+			logger.Warn("Heartbeat queue full, dropped oldest batch", "programs", len(dropped.items), "at", dropped.at)
+		default:
+		}
+	}
+}
+
+// Send a batch of heartbeats to WakaTime/Wakapi, as a single submission per destination. Programs are filtered again
+// for each destination, as a batch queued behind a slow delivery may repeat programs it has since delivered
+func (e *EventController) sendHeartbeats(ctx context.Context, logger *slog.Logger, sm *sessions.SessionManager, batch heartbeatBatch) {
+	now := batch.at
+	cfg := e.Config()
+
+	if items := e.dueHeartbeats(destWakaTime, batch.items, now); cfg.WakaTime.Enabled && len(items) > 0 {
+		beats := buildHeartbeats(items, cfg.WakaTime.GlobalProject, now)
+		if err := e.sendWakaTimeHeartbeats(ctx, logger, beats); err != nil {
+			sm.Metrics.HeartbeatsFailed.Add(int64(len(beats)))
+			logger.Error("Failed to send WakaTime heartbeats", "count", len(beats), "error", err)
+		} else {
+			e.recordHeartbeats(destWakaTime, items, now)
+			sm.Metrics.HeartbeatsSent.Add(int64(len(beats)))
+			logger.Info("WakaTime heartbeats sent", "count", len(beats))
 		}
 	}
-	sm.Mu.Unlock()
 
+	if items := e.dueHeartbeats(destWakapi, batch.items, now); cfg.Wakapi.Enabled && len(items) > 0 {
+		beats := buildHeartbeats(items, cfg.Wakapi.GlobalProject, now)
+		if err := e.sendWakapiHeartbeats(ctx, beats); err != nil {
+			sm.Metrics.HeartbeatsFailed.Add(int64(len(beats)))
+			logger.Error("Failed to send Wakapi heartbeats", "count", len(beats), "error", err)
+		} else {
+			e.recordHeartbeats(destWakapi, items, now)
+			sm.Metrics.HeartbeatsSent.Add(int64(len(beats)))
+			logger.Info("Wakapi heartbeats sent", "count", len(beats))
+		}
+	}
+}
+
+// Filters items to programs without a heartbeat delivered to dest in the last heartbeatRateLimit. Failed deliveries
+// aren't recorded, so their programs stay due and are retried on the next tick
+func (e *EventController) dueHeartbeats(dest string, items []heartbeatItem, now time.Time) []heartbeatItem {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	due := []heartbeatItem{}
 	for _, it := range items {
-		if e.Config.WakaTime.Enabled {
-			if err = e.sendWakaTimeHeartbeat(ctx, logger, it.program, it.category, it.project); err != nil {
-				logger.Printf("ERROR: Failed to send WakaTime heartbeat: %s", err)
-			} else {
-				logger.Printf("INFO: WakaTime heartbeat sent for %s, category %s", it.program, it.category)
-			}
+		if last, ok := e.lastBeat[beatKey{dest, it.program}]; ok && now.Sub(last) < heartbeatRateLimit {
+			continue
 		}
+		due = append(due, it)
+	}
 
-		if e.Config.Wakapi.Enabled {
-			if err := e.sendWakapiHeartbeat(ctx, it.program, it.category, it.project); err != nil {
-				logger.Printf("ERROR: Failed to send Wakapi heartbeat: %s", err)
-			} else {
-				logger.Printf("INFO: Wakapi heartbeat send for %s, category %s", it.program, it.category)
-			}
+	return due
+}
+
+// Records now as the time of the last heartbeat delivered to dest for the programs of items
+func (e *EventController) recordHeartbeats(dest string, items []heartbeatItem, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.lastBeat == nil {
+		e.lastBeat = make(map[beatKey]time.Time)
+	}
+	for _, it := range items {
+		e.lastBeat[beatKey{dest, it.program}] = now
+	}
+}
+
+// Builds one heartbeat per program, falling back to globalProject for programs without a project
+func buildHeartbeats(items []heartbeatItem, globalProject string, now time.Time) []heartbeat {
+	beats := make([]heartbeat, 0, len(items))
+	for _, it := range items {
+		project := globalProject
+		if it.project != "" {
+			project = it.project
 		}
+		beats = append(beats, heartbeat{
+			Entity:   it.program,
+			Type:     "app",
+			Category: it.category,
+			Project:  project,
+			Time:     float64(now.Unix()),
+		})
 	}
+	return beats
 }
 
-// Call the wakatime-cli heartbeat command
-func (e *EventController) sendWakaTimeHeartbeat(ctx context.Context, logger *log.Logger, program, category, project string) error {
-	cliPath := e.Config.WakaTime.CLIPath
+// Calls wakatime-cli once for a batch of heartbeats. The first is passed as arguments, the rest as JSON on stdin via
+// --extra-heartbeats
+func (e *EventController) sendWakaTimeHeartbeats(ctx context.Context, logger *slog.Logger, beats []heartbeat) error {
+	cfg := e.Config().WakaTime
+	cliPath := cfg.CLIPath
 
 	if cliPath == "" {
 		return fmt.Errorf("wakatime-cli path not set")
@@ -108,28 +253,34 @@ func (e *EventController) sendWakaTimeHeartbeat(ctx context.Context, logger *log
 		return fmt.Errorf("wakatime-cli not found at path: %s", cliPath)
 	}
 
-	projectToUse := e.Config.WakaTime.GlobalProject
-	if project != "" {
-		projectToUse = project
-	}
-
+	first := beats[0]
 	args := []string{
-		"--key", e.Config.WakaTime.APIKey,
-		"--entity", program,
-		"--entity-type", "app",
-		"--category", category,
+		"--key", cfg.APIKey,
+		"--entity", first.Entity,
+		"--entity-type", first.Type,
+		"--category", first.Category,
 		"--plugin", "timekeep/" + e.version,
 	}
 
-	if projectToUse != "" {
-		args = append(args, "--project", projectToUse)
+	if first.Project != "" {
+		args = append(args, "--project", first.Project)
 	}
 
 	args = append(args,
-		"--time", fmt.Sprintf("%f", float64(time.Now().Unix())),
+		"--time", fmt.Sprintf("%f", first.Time),
 		"--verbose",
 	)
 
+	var stdin []byte
+	if len(beats) > 1 {
+		extra, err := json.Marshal(beats[1:])
+		if err != nil {
+			return fmt.Errorf("error encoding extra heartbeats: %w", err)
+		}
+		stdin = append(extra, '\n')
+		args = append(args, "--extra-heartbeats")
+	}
+
 	execCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
@@ -142,6 +293,7 @@ func (e *EventController) sendWakaTimeHeartbeat(ctx context.Context, logger *log
 	)
 
 	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(stdin)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
@@ -153,70 +305,44 @@ func (e *EventController) sendWakaTimeHeartbeat(ctx context.Context, logger *log
 		exitCode := exitError.ExitCode()
 
 		if exitCode == 112 {
-			logger.Printf("INFO: wakatime-cli queued heartbeat (exit 112) in %v", duration)
+			logger.Info("wakatime-cli queued heartbeat (exit 112)", "duration", duration)
 			if stdout.Len() > 0 {
-				logger.Printf("DEBUG: stdout: %s", stdout.String())
+				logger.Debug("wakatime-cli output", "stdout", stdout.String())
 			}
 			return nil
 		}
 
 		if exitCode == 102 {
-			logger.Printf("WARNING: wakatime-cli API issue (exit 102) in %v", duration)
+			logger.Warn("wakatime-cli API issue (exit 102)", "duration", duration)
 			if stderr.Len() > 0 {
-				logger.Printf("DEBUG: stderr: %s", stderr.String())
+				logger.Debug("wakatime-cli output", "stderr", stderr.String())
 			}
 			return nil
 		}
 
-		logger.Printf("ERROR: wakatime-cli failed with exit code %d after %v", exitCode, duration)
-		logger.Printf("ERROR: stdout: %s", stdout.String())
-		logger.Printf("ERROR: stderr: %s", stderr.String())
+		logger.Error("wakatime-cli failed", "exit_code", exitCode, "duration", duration, "stdout", stdout.String(), "stderr", stderr.String())
 		return fmt.Errorf("wakatime-cli exited with code %d", exitCode)
 	} else if err != nil {
-		logger.Printf("ERROR: wakatime-cli failed after %v: %v", duration, err)
-		logger.Printf("ERROR: stdout: %s", stdout.String())
-		logger.Printf("ERROR: stderr: %s", stderr.String())
+		logger.Error("wakatime-cli failed", "duration", duration, "error", err, "stdout", stdout.String(), "stderr", stderr.String())
 		return fmt.Errorf("wakatime-cli execution failed: %v", err)
 	}
 
 	return nil
 }
 
-// Send heartbeat to user's wakapi server
-func (e *EventController) sendWakapiHeartbeat(ctx context.Context, program, category, project string) error {
-	if e.Config.Wakapi.Server == "" || e.Config.Wakapi.APIKey == "" {
+// Send a batch of heartbeats to the user's wakapi server in one request
+func (e *EventController) sendWakapiHeartbeats(ctx context.Context, beats []heartbeat) error {
+	cfg := e.Config().Wakapi
+	if cfg.Server == "" || cfg.APIKey == "" {
 		return fmt.Errorf("missing config variable")
 	}
 
-	apiURL, err := e.validateAndFormatWakapiURL(e.Config.Wakapi.Server)
+	apiURL, err := e.validateAndFormatWakapiURL(cfg.Server)
 	if err != nil {
 		return fmt.Errorf("invalid Wakapi server URL: %v", err)
 	}
 
-	projectToUse := e.Config.Wakapi.GlobalProject
-	if project != "" {
-		projectToUse = project
-	}
-
-	type Heartbeat struct {
-		Entity   string `json:"entity"`
-		Type     string `json:"type"`
-		Category string `json:"category"`
-		Project  string `json:"project"`
-		Time     int64  `json:"time"`
-		IsWrite  bool   `json:"is_write"`
-	}
-
-	heartbeat := Heartbeat{
-		Entity:   program,
-		Type:     "app",
-		Category: category,
-		Project:  projectToUse,
-		Time:     time.Now().Unix(),
-		IsWrite:  false,
-	}
-
-	heartbeatData, err := json.Marshal(heartbeat)
+	heartbeatData, err := json.Marshal(beats)
 	if err != nil {
 		return err
 	}
@@ -227,7 +353,7 @@ func (e *EventController) sendWakapiHeartbeat(ctx context.Context, program, cate
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(e.Config.Wakapi.APIKey)))
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(cfg.APIKey)))
 
 	userAgent := e.getUserAgent()
 	req.Header.Set("User-Agent", userAgent)
@@ -278,7 +404,7 @@ func (e *EventController) validateAndFormatWakapiURL(server string) (string, err
 		formatted += parsed.Path
 	}
 
-	return strings.TrimRight(formatted, "/") + "/api/heartbeat", nil
+	return strings.TrimRight(formatted, "/") + "/api/heartbeats", nil
 }
 
 // Create User Agent header for Wakapi request
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeatsRetriedUntilDelivered(t *testing.T) {
+	var status, received atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	t.Cleanup(server.Close)
+
+	e := NewEventController()
+	e.SetConfig(&config.Config{Wakapi: config.WakapiConfig{Enabled: true, Server: server.URL, APIKey: "key"}})
+	e.Client = server.Client()
+	logger := slog.New(slog.DiscardHandler)
+	sm := sessions.NewSessionManager()
+	items := []heartbeatItem{{program: "code", category: "coding"}}
+	now := time.Now()
+
+	e.sendHeartbeats(t.Context(), logger, sm, heartbeatBatch{items: items, at: now})
+	assert.Equal(t, int32(1), received.Load())
+	assert.Equal(t, int64(1), sm.Metrics.HeartbeatsFailed.Load())
+	assert.Len(t, e.dueHeartbeats(destWakapi, items, now.Add(time.Minute)), 1, "A failed heartbeat should stay due")
+
+	status.Store(http.StatusCreated)
+	e.sendHeartbeats(t.Context(), logger, sm, heartbeatBatch{items: items, at: now.Add(time.Minute)})
+	assert.Equal(t, int32(2), received.Load())
+	assert.Equal(t, int64(1), sm.Metrics.HeartbeatsSent.Load())
+	assert.Empty(t, e.dueHeartbeats(destWakapi, items, now.Add(2*time.Minute)), "A delivered heartbeat should be rate limited")
+
+	// A batch queued before the delivery repeats the program, and isn't sent again
+	e.sendHeartbeats(t.Context(), logger, sm, heartbeatBatch{items: items, at: now.Add(2 * time.Minute)})
+	assert.Equal(t, int32(2), received.Load())
+	assert.Len(t, e.dueHeartbeats(destWakapi, items, now.Add(time.Minute+heartbeatRateLimit)), 1)
+	assert.Len(t, e.dueHeartbeats(destWakaTime, items, now.Add(2*time.Minute)), 1, "Each destination should be rate limited on its own")
+}
@@ -6,15 +6,25 @@ import (
 	"bytes"
 	"context"
 	_ "embed"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
 	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/cmd/service/internal/supervisor"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/profile"
+	"github.com/jms-guy/timekeep/internal/progname"
 	"github.com/jms-guy/timekeep/internal/repository"
+	"golang.org/x/sys/windows"
 )
 
 // Windows specific functions handling process monitoring events, related to WMI event handling
@@ -26,7 +36,9 @@ var monitorScript string
 var premonitorScript string
 
 // Main process monitoring function for Windows version
-func (e *EventController) StartMonitor(parent context.Context, logger *log.Logger, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, programs []string) {
+func (e *EventController) StartMonitor(parent context.Context, logger *slog.Logger, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, programs []string) {
+	logger = logs.Component(logger, logs.ComponentMonitor)
+
 	e.mu.Lock()
 	if e.MonCancel != nil {
 		e.MonCancel()
@@ -35,44 +47,41 @@ func (e *EventController) StartMonitor(parent context.Context, logger *log.Logge
 	ctx, cancel := context.WithCancel(parent)
 	e.MonCancel = cancel
 	e.mu.Unlock()
-	e.startProcessMonitor(ctx, logger, programs)
+	restarted := false
+	supervisor.Go(ctx, logger, &s.Metrics.TaskRestarts, "monitor", func(ctx context.Context) error {
+		// Events raised while the subscription was down are lost, so pick up processes started in the gap.
+		// Processes that stopped in the gap are ended by the session validator
+		if restarted {
+			e.StartPreMonitor(logger, s, pr, a, h, programs)
+		}
+		restarted = true
+		return e.runProcessMonitor(ctx, logger, programs)
+	})
 }
 
-// Runs the powershell WMI script, to monitor process events
-func (e *EventController) startProcessMonitor(ctx context.Context, logger *log.Logger, programs []string) {
-	// Check if context is already cancelled
-	select {
-	case <-ctx.Done():
-		logger.Println("WARNING: Context already cancelled, not starting monitor")
-		return
-	default:
-	}
+// Runs the powershell WMI script to monitor process events, until ctx is cancelled or the script exits
+func (e *EventController) runProcessMonitor(ctx context.Context, logger *slog.Logger, programs []string) error {
+	programList := imageList(programs)
 
-	programList := strings.Join(programs, ",")
-
-	scriptTempDir := filepath.Join("C:\\", "ProgramData", "TimeKeep", "scripts_temp")
+	scriptTempDir := filepath.Join(profile.DataDir(), "scripts_temp")
 
 	if err := os.MkdirAll(scriptTempDir, 0o755); err != nil {
-		logger.Printf("ERROR: Failed to create PowerShell script temp directory '%s': %s", scriptTempDir, err)
-		return
+		return fmt.Errorf("error creating PowerShell script temp directory %s: %w", scriptTempDir, err)
 	}
 
 	tempFile, err := os.CreateTemp(scriptTempDir, "monitor*.ps1")
 	if err != nil {
-		logger.Printf("ERROR: Failed to create temp script file in '%s': %s", scriptTempDir, err)
-		return
+		return fmt.Errorf("error creating temp script file in %s: %w", scriptTempDir, err)
 	}
-
+	defer os.Remove(tempFile.Name())
 	defer tempFile.Close()
 
 	if _, err := tempFile.WriteString(monitorScript); err != nil {
-		logger.Printf("ERROR: Failed to write script: %s", err)
-		return
+		return fmt.Errorf("error writing script: %w", err)
 	}
 
 	if err := tempFile.Sync(); err != nil {
-		logger.Printf("ERROR: Failed to sync temp script file to disk: %s", err)
-		return
+		return fmt.Errorf("error syncing temp script file to disk: %w", err)
 	}
 
 	tempFile.Close()
@@ -80,46 +89,37 @@ func (e *EventController) startProcessMonitor(ctx context.Context, logger *log.L
 	time.Sleep(100 * time.Millisecond) // Pause to allow tempfile to finish writing before it attempts to execute
 
 	args := []string{"-ExecutionPolicy", "Bypass", "-File", tempFile.Name(), "-Programs", programList}
+	args = append(args, e.scopeArgs()...)
 	cmd := exec.CommandContext(ctx, "powershell", args...)
-	e.PsProcess = cmd
+	cmd.Env = append(os.Environ(), ipc.TokenEnv+"="+e.AuthToken, ipc.PipeEnv+"="+ipc.PipeBaseName())
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: windows.CREATE_NO_WINDOW} // No console window for user agents
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	logger.Println("INFO: Executing monitor script")
+	logger.Info("Executing monitor script")
 	if err := cmd.Start(); err != nil {
-		logger.Printf("ERROR: Failed to start PowerShell monitor: %s", err)
-		e.PsProcess = nil
-		if stderr.Len() > 0 {
-			logger.Printf("INFO: PowerShell stderr (on Start() failure): %s", stderr.String())
-		}
+		return fmt.Errorf("error starting PowerShell monitor: %w: %s", err, stderr.String())
 	}
 
-	// Goroutine to wait for the PowerShell process to exit and log its stderr/stdout
-	go func() {
-		defer os.Remove(tempFile.Name())
-
-		err := cmd.Wait()
+	e.mu.Lock()
+	e.PsProcess = cmd
+	e.mu.Unlock()
 
-		select {
-		case <-ctx.Done():
-			logger.Println("INFO: Powershell monitor stopped due to context cancellation")
-			return
-		default:
-		}
+	err = cmd.Wait()
 
-		if err != nil {
-			logger.Printf("ERROR: PowerShell monitor process exited with error: %s", err)
-		} else {
-			logger.Println("INFO: PowerShell monitor process exited successfully.")
-		}
+	if ctx.Err() != nil {
+		logger.Info("PowerShell monitor stopped due to context cancellation")
+		return nil
+	}
 
-		if stderr.Len() > 0 {
-			logger.Printf("PowerShell stderr output: %s", stderr.String())
-		} else {
-			logger.Println("INFO: No PowerShell stderr output.")
-		}
-	}()
+	if stderr.Len() > 0 {
+		logger.Info("PowerShell stderr output", "stderr", stderr.String())
+	}
+	if err != nil {
+		return fmt.Errorf("PowerShell monitor exited: %w", err)
+	}
+	return errors.New("PowerShell monitor exited")
 }
 
 // Stops the WMI powershell script
@@ -129,40 +129,41 @@ func (e *EventController) StopProcessMonitor() {
 		e.MonCancel()
 		e.MonCancel = nil
 	}
-	e.mu.Unlock()
-
 	if e.PsProcess != nil {
 		_ = e.PsProcess.Process.Kill()
 		e.PsProcess = nil
 	}
+	e.mu.Unlock()
 }
 
 // Runs the pre-monitoring script, gathering PIDs for tracked programs that are already running on service start
-func (e *EventController) StartPreMonitor(logger *log.Logger, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, programs []string) {
-	programList := strings.Join(programs, ",")
+func (e *EventController) StartPreMonitor(logger *slog.Logger, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, programs []string) {
+	logger = logs.Component(logger, logs.ComponentMonitor)
+
+	programList := imageList(programs)
 
-	scriptTempDir := filepath.Join("C:\\", "ProgramData", "TimeKeep", "scripts_temp")
+	scriptTempDir := filepath.Join(profile.DataDir(), "scripts_temp")
 
 	if err := os.MkdirAll(scriptTempDir, 0o755); err != nil {
-		logger.Printf("ERROR: Failed to create PowerShell script temp directory '%s': %s", scriptTempDir, err)
+		logger.Error("Failed to create PowerShell script temp directory", "dir", scriptTempDir, "error", err)
 		return
 	}
 
 	tempFile, err := os.CreateTemp(scriptTempDir, "premonitor*.ps1")
 	if err != nil {
-		logger.Printf("ERROR: Failed to create temp script file in '%s': %s", scriptTempDir, err)
+		logger.Error("Failed to create temp script file", "dir", scriptTempDir, "error", err)
 		return
 	}
 
 	defer tempFile.Close()
 
 	if _, err := tempFile.WriteString(premonitorScript); err != nil {
-		logger.Printf("ERROR: Failed to write script: %s", err)
+		logger.Error("Failed to write script", "error", err)
 		return
 	}
 
 	if err := tempFile.Sync(); err != nil {
-		logger.Printf("ERROR: Failed to sync temp script file to disk: %s", err)
+		logger.Error("Failed to sync temp script file to disk", "error", err)
 		return
 	}
 
@@ -171,16 +172,19 @@ func (e *EventController) StartPreMonitor(logger *log.Logger, s *sessions.Sessio
 	time.Sleep(100 * time.Millisecond)
 
 	args := []string{"-ExecutionPolicy", "Bypass", "-File", tempFile.Name(), "-Programs", programList}
+	args = append(args, e.scopeArgs()...)
 	cmd := exec.Command("powershell", args...)
+	cmd.Env = append(os.Environ(), ipc.TokenEnv+"="+e.AuthToken, ipc.PipeEnv+"="+ipc.PipeBaseName())
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: windows.CREATE_NO_WINDOW} // No console window for user agents
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	logger.Println("INFO: Executing pre-monitor script")
+	logger.Info("Executing pre-monitor script")
 	if err := cmd.Start(); err != nil {
-		logger.Printf("ERROR: Failed to start PowerShell script: %s", err)
+		logger.Error("Failed to start PowerShell script", "error", err)
 		if stderr.Len() > 0 {
-			logger.Printf("INFO: PowerShell stderr (on Start() failure): %s", stderr.String())
+			logger.Info("PowerShell stderr on start failure", "stderr", stderr.String())
 		}
 	}
 
@@ -190,15 +194,68 @@ func (e *EventController) StartPreMonitor(logger *log.Logger, s *sessions.Sessio
 		err := cmd.Wait()
 
 		if err != nil {
-			logger.Printf("ERROR: PowerShell pre-monitor process exited with error: %s", err)
+			logger.Error("PowerShell pre-monitor process exited with error", "error", err)
 		} else {
-			logger.Println("INFO: PowerShell pre-monitor process exited successfully.")
+			logger.Info("PowerShell pre-monitor process exited successfully")
 		}
 
 		if stderr.Len() > 0 {
-			logger.Printf("PowerShell stderr output: %s", stderr.String())
+			logger.Info("PowerShell stderr output", "stderr", stderr.String())
 		} else {
-			logger.Println("INFO: No PowerShell stderr output.")
+			logger.Debug("No PowerShell stderr output")
 		}
 	}()
 }
+
+// Session filter passed to the monitor scripts. A per-user agent watches its own login session. The system service
+// watches the processes of the user it was installed for, in whichever session they run, console or remote desktop.
+// Without a recorded owner, as for services installed by earlier versions, it watches the console session, so
+// processes are attributed to whoever is signed in at the machine. With the scope shared, every session is watched
+func (e *EventController) scopeArgs() []string {
+	if profile.UserScope() {
+		return []string{"-UserSession"}
+	}
+	if e.Config().SharedScope() {
+		return nil
+	}
+	if owner := profile.Owner(); owner != "" {
+		return []string{"-OwnerSid", owner}
+	}
+	return []string{"-SessionId", strconv.FormatUint(uint64(windows.WTSGetActiveConsoleSessionId()), 10)}
+}
+
+// Follows a change of console user for the system service in user scope without an owner: the previous user's
+// sessions are closed and the monitor restarted on the new console session, picking up programs the new user already
+// has running
+func (e *EventController) ConsoleChanged(serviceCtx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+
+	if profile.UserScope() || e.Config().SharedScope() || profile.Owner() != "" {
+		return
+	}
+
+	logger.Info("Console session changed, closing the previous user's sessions", "session", windows.WTSGetActiveConsoleSessionId())
+	e.StopProcessMonitor()
+
+	ctx, cancel := context.WithTimeout(context.Background(), sleepFlushTimeout)
+	flushed, remaining := sm.FlushSessions(ctx, logger, pr, a, h, repository.EndReasonSwitch)
+	cancel()
+	if remaining > 0 {
+		logger.Warn("Sessions left active after console change", "flushed", flushed, "remaining", remaining)
+	}
+
+	e.RefreshProcessMonitor(serviceCtx, logger, sm, pr, a, h)
+	if !e.Paused() && !e.Asleep() && !e.Incognito() {
+		e.rescan(logger, sm, pr, a, h)
+	}
+}
+
+// Joins the Windows image names of programs for the monitor scripts, which match against Win32_Process names
+func imageList(programs []string) string {
+	images := make([]string, 0, len(programs))
+	for _, program := range programs {
+		images = append(images, progname.ImageName(program))
+	}
+	return strings.Join(images, ",")
+}
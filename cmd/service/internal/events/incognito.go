@@ -0,0 +1,144 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Turns incognito mode on or off. Turning it on closes the active sessions as of now, as if their programs had
+// exited, then stops monitoring and heartbeats, and drops process events and activity reports, until it's turned off
+// or mode.Until passes. Turning it off restarts monitoring, unless paused, with running programs starting new
+// sessions. Nothing is stored about the incognito period itself, only that the mode is on and until when, so it
+// outlasts service restarts
+func (e *EventController) SetIncognito(serviceCtx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, mode ipc.Incognito) {
+	e.saveIncognito(logger, mode)
+
+	e.mu.Lock()
+	was := e.incognito
+	e.mu.Unlock()
+	e.incognitoAt(serviceCtx, logger, sm, pr, a, h, mode)
+
+	switch {
+	case mode.Enabled && !was:
+		logger.Info("Incognito on, closing active sessions", "until", mode.Until)
+		e.StopHeartbeats()
+		e.StopProcessMonitor()
+
+		ctx, cancel := context.WithTimeout(context.Background(), sleepFlushTimeout)
+		defer cancel()
+		flushed, remaining := sm.FlushSessions(ctx, logger, pr, a, h, repository.EndReasonExit)
+		if remaining > 0 {
+			logger.Warn("Sessions left active entering incognito", "flushed", flushed, "remaining", remaining)
+		}
+	case mode.Enabled:
+		logger.Info("Incognito extended", "until", mode.Until)
+	case was:
+		logger.Info("Incognito off, restarting monitoring")
+
+		e.reloadMu.Lock()
+		defer e.reloadMu.Unlock()
+
+		e.RefreshProcessMonitor(serviceCtx, logger, sm, pr, a, h)
+		if !e.Paused() && !e.Asleep() {
+			e.rescan(logger, sm, pr, a, h)
+		}
+	}
+}
+
+// Turns incognito mode back on if the service stopped while it was on and hasn't ended. Called on start, before the
+// monitor starts
+func (e *EventController) RestoreIncognito(serviceCtx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
+	if e.Incognitos == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snoozeSaveTimeout)
+	defer cancel()
+
+	saved, err := e.Incognitos.GetIncognito(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to read incognito mode", "error", err)
+		return
+	}
+
+	if saved.Until.Valid && !saved.Until.Time.After(time.Now()) {
+		logger.Info("Incognito ended while the service was stopped", "until", saved.Until.Time)
+		if err := e.Incognitos.ClearIncognito(ctx); err != nil {
+			logger.Warn("Failed to clear incognito mode", "error", err)
+		}
+		return
+	}
+
+	mode := ipc.Incognito{Enabled: true}
+	if saved.Until.Valid {
+		mode.Until = saved.Until.Time
+	}
+	e.incognitoAt(serviceCtx, logger, sm, pr, a, h, mode)
+	logger.Info("Incognito on", "until", mode.Until)
+}
+
+// Sets the incognito state to mode, scheduling its end at mode.Until and replacing that of any earlier one
+func (e *EventController) incognitoAt(serviceCtx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, mode ipc.Incognito) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.incognito = mode.Enabled
+	e.incognitoUntil = time.Time{}
+	if e.incognitoTimer != nil {
+		e.incognitoTimer.Stop()
+		e.incognitoTimer = nil
+	}
+	if !mode.Enabled || mode.Until.IsZero() {
+		return
+	}
+
+	e.incognitoUntil = mode.Until
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(mode.Until), func() {
+		e.mu.Lock()
+		current := e.incognitoTimer == timer
+		e.mu.Unlock()
+		if !current || serviceCtx.Err() != nil {
+			return
+		}
+		e.SetIncognito(serviceCtx, logger, sm, pr, a, h, ipc.Incognito{})
+	})
+	e.incognitoTimer = timer
+}
+
+// Saves mode, or forgets the saved one when it's off
+func (e *EventController) saveIncognito(logger *slog.Logger, mode ipc.Incognito) {
+	if e.Incognitos == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snoozeSaveTimeout)
+	defer cancel()
+
+	var err error
+	if mode.Enabled {
+		err = e.Incognitos.SaveIncognito(ctx, sql.NullTime{Time: mode.Until.UTC(), Valid: !mode.Until.IsZero()})
+	} else {
+		err = e.Incognitos.ClearIncognito(ctx)
+	}
+	if err != nil {
+		logger.Warn("Failed to save incognito mode, it ends if the service restarts", "error", err)
+	}
+}
+
+// Reports whether incognito mode is on
+func (e *EventController) Incognito() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.incognito
+}
@@ -0,0 +1,108 @@
+package events
+
+import (
+	"database/sql"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncognitoOutlastsRestart(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+	logger := slog.New(slog.DiscardHandler)
+
+	err := store.AddProgram(ctx, database.AddProgramParams{Name: "editor"})
+	assert.Nil(t, err)
+	programs, _ := store.GetAllPrograms(ctx)
+	sm := sessions.NewSessionManager()
+	sm.LoadPrograms(programs)
+	sm.CreateSession(ctx, logger, store, "editor", 42)
+
+	e := NewEventController()
+	e.Incognitos = store
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	e.SetIncognito(ctx, logger, sm, store, store, store, ipc.Incognito{Enabled: true, Until: until})
+	assert.True(t, e.Incognito())
+
+	last, err := store.GetLastSessionForProgram(ctx, "editor")
+	if assert.Nil(t, err, "The active session should be closed") {
+		metadata, _ := repository.DecodeSessionMetadata(last.Metadata)
+		assert.Equal(t, repository.EndReasonExit, metadata.EndReason, "Nothing should mark the session as ended by incognito")
+	}
+
+	restarted := NewEventController()
+	restarted.Incognitos = store
+	restarted.RestoreIncognito(ctx, logger, sm, store, store, store)
+	assert.True(t, restarted.Incognito(), "Incognito should be on again after a restart")
+	assert.True(t, until.Equal(restarted.incognitoUntil), "Incognito should end when it was set to")
+
+	err = store.SaveIncognito(ctx, sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true})
+	assert.Nil(t, err)
+	expired := NewEventController()
+	expired.Incognitos = store
+	expired.RestoreIncognito(ctx, logger, sm, store, store, store)
+	assert.False(t, expired.Incognito(), "Incognito that ended while stopped should stay off")
+	_, err = store.GetIncognito(ctx)
+	assert.ErrorIs(t, err, sql.ErrNoRows, "Incognito that ended should be forgotten")
+
+	err = store.SaveIncognito(ctx, sql.NullTime{})
+	assert.Nil(t, err)
+	indefinite := NewEventController()
+	indefinite.Incognitos = store
+	indefinite.RestoreIncognito(ctx, logger, sm, store, store, store)
+	assert.True(t, indefinite.Incognito(), "Incognito without an end should stay on")
+	assert.True(t, indefinite.incognitoUntil.IsZero())
+}
+
+func TestIncognitoRecordsNothing(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // Turning incognito off reloads the config
+	store := testStore(t)
+	ctx := t.Context()
+	logger := slog.New(slog.DiscardHandler)
+
+	assert.Nil(t, store.AddProgram(ctx, database.AddProgramParams{Name: "editor"}))
+	programs, _ := store.GetAllPrograms(ctx)
+	sm := sessions.NewSessionManager()
+	sm.LoadPrograms(programs)
+	assert.Nil(t, store.SaveIncognito(ctx, sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}))
+
+	// Incognito was on when the service stopped
+	e := NewEventController()
+	e.SetConfig(&config.Config{})
+	e.Incognitos = store
+	e.RestoreIncognito(ctx, logger, sm, store, store, store)
+	assert.True(t, e.Incognito())
+
+	event := func(action string) ipc.Response {
+		req := ipc.NewRequest(action)
+		req.ProcessName, req.ProcessID = "editor", 7
+		return e.handleRequest(ctx, ctx, logger, sm, store, store, store, req)
+	}
+	assert.True(t, event(ipc.ActionProcessStart).OK)
+	assert.Empty(t, sm.Snapshot(), "No session should start while incognito")
+	active, err := store.GetAllActiveSessions(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, active)
+	assert.True(t, event(ipc.ActionProcessStop).OK)
+	count, err := store.GetCountOfSessionsForProgram(ctx, "editor")
+	assert.Nil(t, err)
+	assert.Zero(t, count, "Nothing should be recorded while incognito")
+
+	// The same events are recorded once it's off
+	e.SetIncognito(ctx, logger, sm, store, store, store, ipc.Incognito{})
+	assert.False(t, e.Incognito())
+	assert.True(t, event(ipc.ActionProcessStart).OK)
+	assert.Len(t, sm.Snapshot(), 1)
+	assert.True(t, event(ipc.ActionProcessStop).OK)
+	count, err = store.GetCountOfSessionsForProgram(ctx, "editor")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+}
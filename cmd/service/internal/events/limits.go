@@ -0,0 +1,207 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Daily limit set in limits.daily, on a program or on a program group
+type dailyLimit struct {
+	key      string // Key of the limit in limits.daily, the program name or group:<name>
+	limit    time.Duration
+	programs []string // Programs whose time counts towards the limit
+}
+
+// Returns the limits set in limits.daily. Group limits count the time of the group's programs, groups being read only
+// while one has a limit
+func (e *EventController) dailyLimits(ctx context.Context, cfg *config.Config) ([]dailyLimit, error) {
+	var limits []dailyLimit
+	grouped := false
+	for key := range cfg.Limits.Daily {
+		limit := cfg.DailyLimit(key)
+		if limit == 0 {
+			continue
+		}
+		if _, ok := config.GroupTarget(key); ok {
+			grouped = true
+			continue
+		}
+		limits = append(limits, dailyLimit{key: key, limit: limit, programs: []string{key}})
+	}
+	if !grouped || e.Groups == nil {
+		return limits, nil
+	}
+
+	members, err := e.Groups.GetAllGroupMembers(ctx)
+	if err != nil {
+		return limits, fmt.Errorf("error getting groups: %w", err)
+	}
+	programs := make(map[string][]string)
+	for _, member := range members {
+		programs[member.GroupName] = append(programs[member.GroupName], member.ProgramName)
+	}
+	for group, list := range programs {
+		if limit := cfg.DailyLimit(config.GroupPrefix + group); limit > 0 {
+			limits = append(limits, dailyLimit{key: config.GroupPrefix + group, limit: limit, programs: list})
+		}
+	}
+	return limits, nil
+}
+
+// Reports whether a new process of name may start a session. Once the daily limit of the program, or of a group it
+// belongs to, is used up a warning is logged, and with limits.enforce set to kill the process is ended instead. Only
+// new launches are ended: processes started while the program is already running, such as those a running instance
+// spawns, are left alone. Processes are only ended when discovered is set, for PIDs the monitor found itself, and
+// once the OS confirms the PID is a process of name in the service's scope
+func (e *EventController) allowStart(ctx context.Context, logger *slog.Logger, sm *sessions.SessionManager, h repository.HistoryRepository, name string, pid int, discovered bool) bool {
+	cfg := e.Config()
+	if cfg == nil || len(cfg.Limits.Daily) == 0 {
+		return true
+	}
+	if t := sm.Lookup(name); t != nil {
+		if _, _, running := t.Details(); running {
+			return true
+		}
+	}
+
+	logger = logs.Component(logger, logs.ComponentLimits)
+	limits, err := e.dailyLimits(ctx, cfg)
+	if err != nil {
+		logger.Warn("Failed to read group limits", "error", err)
+	}
+	now := time.Now()
+	running := runningSince(sm)
+	for _, l := range limits {
+		if !slices.Contains(l.programs, name) {
+			continue
+		}
+		used, err := e.usedToday(ctx, h, l.programs, running, now)
+		if err != nil {
+			logger.Warn("Failed to check daily limit", "key", l.key, "error", err)
+			continue
+		}
+		if used < l.limit {
+			continue
+		}
+
+		if !cfg.KillOverLimit() || !discovered {
+			e.warnLimit(logger, l.key, used, l.limit, now)
+			continue
+		}
+
+		if err := e.endProcess(pid, name); err != nil {
+			logger.Error("Failed to end program over its daily limit", "program", name, "pid", pid, "key", l.key, "error", err)
+			return true
+		}
+		logger.Warn("Ended program over its daily limit", "program", name, "pid", pid, "used", used.Round(time.Second), "limit", l.limit, "key", l.key)
+		return false
+	}
+	return true
+}
+
+// Logs a warning for each daily limit of a running program, or of a group with one running, that ran out since the
+// last check. Running instances are never ended, only launches after the limit is reached
+func (e *EventController) CheckLimits(ctx context.Context, logger *slog.Logger, sm *sessions.SessionManager, h repository.HistoryRepository) {
+	cfg := e.Config()
+	if cfg == nil || len(cfg.Limits.Daily) == 0 || e.Paused() || e.Incognito() {
+		return
+	}
+
+	logger = logs.Component(logger, logs.ComponentLimits)
+	limits, err := e.dailyLimits(ctx, cfg)
+	if err != nil {
+		logger.Warn("Failed to read group limits", "error", err)
+	}
+	now := time.Now()
+	running := runningSince(sm)
+	for _, l := range limits {
+		if !slices.ContainsFunc(l.programs, func(program string) bool { _, ok := running[program]; return ok }) {
+			continue
+		}
+		used, err := e.usedToday(ctx, h, l.programs, running, now)
+		if err != nil {
+			logger.Warn("Failed to check daily limit", "key", l.key, "error", err)
+			continue
+		}
+		if used >= l.limit {
+			e.warnLimit(logger, l.key, used, l.limit, now)
+		}
+	}
+}
+
+// Returns when each running program's session started
+func runningSince(sm *sessions.SessionManager) map[string]time.Time {
+	running := make(map[string]time.Time)
+	for _, session := range sm.Snapshot() {
+		running[session.Name] = session.StartAt
+	}
+	return running
+}
+
+// Returns the time recorded for programs since the start of today, plus that of their sessions running since the
+// times given in running
+func (e *EventController) usedToday(ctx context.Context, h repository.HistoryRepository, programs []string, running map[string]time.Time, now time.Time) (time.Duration, error) {
+	loc, err := e.Config().Location()
+	if err != nil {
+		loc = time.Local
+	}
+	local := now.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	var used time.Duration
+	for _, name := range programs {
+		history, err := h.GetSessionHistoryByRange(ctx, database.GetSessionHistoryByRangeParams{
+			ProgramName: name,
+			StartTime:   now.UTC(),
+			EndTime:     midnight.UTC(),
+			Limit:       -1,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		for _, session := range history {
+			if !session.StartTime.Before(midnight) {
+				used += time.Duration(session.DurationSeconds) * time.Second
+			} else if session.EndTime.After(midnight) {
+				used += session.EndTime.Sub(midnight)
+			}
+		}
+		if start, ok := running[name]; ok && !start.IsZero() {
+			used += now.Sub(dates.Later(start, midnight))
+		}
+	}
+	return used, nil
+}
+
+// Logs that the program or group keyed in limits.daily is past its daily limit, once per key per day
+func (e *EventController) warnLimit(logger *slog.Logger, key string, used, limit time.Duration, now time.Time) {
+	day := now.Format(time.DateOnly)
+
+	e.mu.Lock()
+	if e.limitWarned == nil {
+		e.limitWarned = make(map[string]string)
+	}
+	warned := e.limitWarned[key] == day
+	e.limitWarned[key] = day
+	e.mu.Unlock()
+
+	if warned {
+		return
+	}
+	if group, ok := config.GroupTarget(key); ok {
+		logger.Warn("Group over its daily limit", "group", group, "used", used.Round(time.Second), "limit", limit)
+		return
+	}
+	logger.Warn("Program over its daily limit", "program", key, "used", used.Round(time.Second), "limit", limit)
+}
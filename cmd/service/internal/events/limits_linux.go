@@ -0,0 +1,39 @@
+//go:build linux
+
+package events
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Ends pid for being over its daily limit, after checking with the OS that it's a process of name in the service's
+// scope. The process is held by a pidfd while it's checked, so a PID reused in between isn't ended in its place
+func (e *EventController) endProcess(pid int, name string) error {
+	fd, err := unix.PidfdOpen(pid, 0)
+	if err != nil && !errors.Is(err, unix.ENOSYS) {
+		return fmt.Errorf("error opening process: %w", err)
+	}
+	if err == nil {
+		defer unix.Close(fd)
+	}
+
+	identity, err := getProgramIdentity(pid)
+	if err != nil {
+		return fmt.Errorf("error reading process: %w", err)
+	}
+	if identity != name {
+		return fmt.Errorf("process %d is %s, not %s", pid, identity, name)
+	}
+	if !e.inScope(pid) {
+		return errOtherUser
+	}
+
+	if fd < 0 { // Kernels before 5.3
+		return syscall.Kill(pid, syscall.SIGKILL)
+	}
+	return unix.PidfdSendSignal(fd, unix.SIGKILL, nil, 0)
+}
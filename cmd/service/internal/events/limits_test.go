@@ -0,0 +1,83 @@
+//go:build linux
+
+package events
+
+import (
+	"log/slog"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowStartEndsOnlyVerifiedProcesses(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+	now := time.Now()
+	for _, name := range []string{"sleep", "editor"} {
+		err := store.AddToSessionHistory(ctx, database.AddToSessionHistoryParams{ProgramName: name, StartTime: now.Add(-time.Minute), EndTime: now, DurationSeconds: 60})
+		assert.Nil(t, err)
+	}
+
+	e := NewEventController()
+	e.SetConfig(&config.Config{Limits: config.LimitsConfig{Daily: map[string]string{"sleep": "1s", "editor": "1s"}, Enforce: config.EnforceKill}})
+	logger := slog.New(slog.DiscardHandler)
+	sm := sessions.NewSessionManager()
+
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start sleep: %v", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+	pid := cmd.Process.Pid
+
+	assert.True(t, e.allowStart(ctx, logger, sm, store, "sleep", pid, false), "PIDs sent by clients should only be warned about")
+	assert.True(t, e.allowStart(ctx, logger, sm, store, "editor", pid, true), "A PID that isn't a process of the program should be left alone")
+	select {
+	case <-exited:
+		t.Fatal("The process should still be running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.False(t, e.allowStart(ctx, logger, sm, store, "sleep", pid, true), "A verified process over its limit should be ended")
+	select {
+	case err := <-exited:
+		assert.NotNil(t, err, "The process should have been killed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("The process should have been ended")
+	}
+}
+
+func TestUsedTodayInLocalZone(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("Timezone database unavailable: %v", err)
+	}
+	store := testStore(t)
+	ctx := t.Context()
+	at := func(day, hour, minute int) time.Time { return time.Date(2026, 3, day, hour, minute, 0, 0, loc) }
+
+	// Sessions are stored in UTC, where both of these fall on the 9th
+	sessions := []struct{ start, end time.Time }{
+		{at(9, 23, 30), at(10, 0, 30)}, // Across local midnight, half counted
+		{at(10, 1, 0), at(10, 2, 0)},
+		{at(9, 12, 0), at(9, 13, 0)}, // Yesterday
+	}
+	for _, s := range sessions {
+		err := store.AddToSessionHistory(ctx, database.AddToSessionHistoryParams{ProgramName: "editor", StartTime: s.start.UTC(), EndTime: s.end.UTC(), DurationSeconds: int64(s.end.Sub(s.start).Seconds())})
+		assert.Nil(t, err)
+	}
+
+	e := NewEventController()
+	e.SetConfig(&config.Config{Timezone: "Asia/Tokyo"})
+	now := at(10, 5, 0)
+	used, err := e.usedToday(ctx, store, []string{"editor"}, map[string]time.Time{"editor": at(10, 4, 0)}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 150*time.Minute, used, "Sessions since local midnight should count, however far the zone is from UTC")
+}
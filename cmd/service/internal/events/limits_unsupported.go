@@ -0,0 +1,9 @@
+//go:build !windows && !linux
+
+package events
+
+import "errors"
+
+func (e *EventController) endProcess(pid int, name string) error {
+	return errors.New("ending processes is not supported on this platform")
+}
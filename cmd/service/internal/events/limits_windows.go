@@ -0,0 +1,81 @@
+//go:build windows
+
+package events
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jms-guy/timekeep/internal/profile"
+	"github.com/jms-guy/timekeep/internal/progname"
+	"golang.org/x/sys/windows"
+)
+
+// Ends pid for being over its daily limit, after checking with the OS that its image is name and that it's a process
+// the service tracks, never one in the services session. The process is checked and ended through one
+// handle, so a PID reused in between isn't ended in its place
+func (e *EventController) endProcess(pid int, name string) error {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return fmt.Errorf("error opening process: %w", err)
+	}
+	defer windows.CloseHandle(handle)
+
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(handle, 0, &buf[0], &size); err != nil {
+		return fmt.Errorf("error reading process image: %w", err)
+	}
+	if image := progname.Normalize(windows.UTF16ToString(buf[:size])); image != name {
+		return fmt.Errorf("process %d is %s, not %s", pid, image, name)
+	}
+
+	var session uint32
+	if err := windows.ProcessIdToSessionId(uint32(pid), &session); err != nil {
+		return fmt.Errorf("error reading process session: %w", err)
+	}
+	if session == 0 {
+		return errors.New("process runs in the services session")
+	}
+	if !e.watches(handle, session) {
+		return errOtherUser
+	}
+
+	return windows.TerminateProcess(handle, 1)
+}
+
+// Reports whether the process of handle, running in session, is one the service tracks: one in the agent's own login
+// session, one run by the user the system service was installed for, or without an owner one in the console session.
+// With the scope shared every process is tracked
+func (e *EventController) watches(handle windows.Handle, session uint32) bool {
+	switch {
+	case profile.UserScope():
+		var own uint32
+		return windows.ProcessIdToSessionId(windows.GetCurrentProcessId(), &own) == nil && session == own
+	case e.Config().SharedScope():
+		return true
+	case profile.Owner() != "":
+		sid, err := processOwner(handle)
+		return err == nil && sid == profile.Owner()
+	default:
+		return session == windows.WTSGetActiveConsoleSessionId()
+	}
+}
+
+// Returns the SID of the user running the process of handle
+func processOwner(handle windows.Handle) (string, error) {
+	var token windows.Token
+	if err := windows.OpenProcessToken(handle, windows.TOKEN_QUERY, &token); err != nil {
+		return "", fmt.Errorf("error opening process token: %w", err)
+	}
+	defer token.Close()
+
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return "", fmt.Errorf("error reading process user: %w", err)
+	}
+	return user.User.Sid.String(), nil
+}
+
+// Reported when a process to end belongs to a session the service doesn't watch
+var errOtherUser = errors.New("process owned by another user")
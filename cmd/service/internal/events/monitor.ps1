@@ -1,51 +1,123 @@
 <#
     This script will query program names issued as command line arguments, and register for WMI start/stop events
-    related to those specific programs. Writing any actions returned to a named pipe opened by the service
+    related to those specific programs. Writing any actions returned to a named pipe opened by the service.
+    With -UserSession, only processes in this login session are reported, using instance events that don't
+    require administrator rights (WMI polls for those once a second, as process trace events are admin-only).
+    With -OwnerSid, only processes run by that user are reported, in any session, used by the system service to
+    follow the user it was installed for. With -SessionId, only processes in that session are reported, used by a
+    system service without an owner to follow the console session.
+    The script exits non-zero if the pipe disconnects or either subscription fails, so the service can restart
+    it and re-subscribe
 #>
 
 param(
-    [string]$Programs
+    [string]$Programs,
+    [switch]$UserSession,
+    [long]$SessionId = -1,
+    [string]$OwnerSid = ""
 )
 
 # Connect to named pipe opened by service
-$pipe = New-Object System.IO.Pipes.NamedPipeClientStream(".", "Timekeep", "Out")
+$pipeName = if ($env:TIMEKEEP_PIPE) { $env:TIMEKEEP_PIPE } else { "Timekeep" }
+$pipe = New-Object System.IO.Pipes.NamedPipeClientStream(".", $pipeName, "Out")
 $pipe.Connect()
 $writer = New-Object System.IO.StreamWriter($pipe)
 
 # Get programs to track from arguments
 $trackedPrograms = $Programs -split ","
-$whereClause = ($trackedPrograms | ForEach-Object { "ProcessName='$_'" }) -join " OR "
-
-$startQuery = "SELECT * FROM Win32_ProcessStartTrace WHERE $whereClause"
-$stopQuery = "SELECT * FROM Win32_ProcessStopTrace WHERE $whereClause"
-
-# Register for WMI events
-Register-WmiEvent -Query $startQuery -Action {
-    $processName = $Event.SourceEventArgs.NewEvent.ProcessName
-    $processID = $Event.SourceEventArgs.NewEvent.ProcessID
-    
-    $data = @{
-        action = "process_start"
-        name = $processName
-        pid = $processID
+
+if ($UserSession) {
+    $sessionId = (Get-Process -Id $PID).SessionId
+    $nameClause = ($trackedPrograms | ForEach-Object { "TargetInstance.Name='$_'" }) -join " OR "
+    $instanceClause = "TargetInstance ISA 'Win32_Process' AND TargetInstance.SessionId=$sessionId AND ($nameClause)"
+
+    $startQuery = "SELECT * FROM __InstanceCreationEvent WITHIN 1 WHERE $instanceClause"
+    $stopQuery = "SELECT * FROM __InstanceDeletionEvent WITHIN 1 WHERE $instanceClause"
+}
+else {
+    $whereClause = ($trackedPrograms | ForEach-Object { "ProcessName='$_'" }) -join " OR "
+    if ($SessionId -ge 0) {
+        $whereClause = "SessionID=$SessionId AND ($whereClause)"
     }
-    $writer.WriteLine(($data | ConvertTo-Json -Compress))
-    $writer.Flush()
+
+    $startQuery = "SELECT * FROM Win32_ProcessStartTrace WHERE $whereClause"
+    $stopQuery = "SELECT * FROM Win32_ProcessStopTrace WHERE $whereClause"
 }
 
-Register-WmiEvent -Query $stopQuery -Action {
-    $processName = $Event.SourceEventArgs.NewEvent.ProcessName
-    $processID = $Event.SourceEventArgs.NewEvent.ProcessID
-    
-    $data = @{
-        action = "process_stop"
-        name = $processName
-        pid = $processID
+# Register for WMI events, failing fast so the service restarts the script
+$ErrorActionPreference = "Stop"
+try {
+    $startJob = Register-WmiEvent -Query $startQuery -MessageData $OwnerSid -Action {
+        $newEvent = $Event.SourceEventArgs.NewEvent
+        # Trace events carry the process user's SID, which WQL can't filter on
+        if ($Event.MessageData -and (New-Object System.Security.Principal.SecurityIdentifier($newEvent.Sid, 0)).Value -ne $Event.MessageData) {
+            return
+        }
+        if ($newEvent.TargetInstance) {
+            $processName = $newEvent.TargetInstance.Name
+            $processID = $newEvent.TargetInstance.ProcessId
+            $parentID = $newEvent.TargetInstance.ParentProcessId
+        }
+        else {
+            $processName = $newEvent.ProcessName
+            $processID = $newEvent.ProcessID
+            $parentID = $newEvent.ParentProcessID
+        }
+        # Parent name for the session's launch context, empty when the parent already exited
+        $parent = Get-Process -Id $parentID -ErrorAction SilentlyContinue
+
+        $data = @{
+            token = $env:TIMEKEEP_TOKEN
+            action = "process_start"
+            name = $processName
+            pid = $processID
+            parent = if ($parent) { $parent.ProcessName } else { "" }
+        }
+        $writer.WriteLine(($data | ConvertTo-Json -Compress))
+        $writer.Flush()
+    }
+
+    $stopJob = Register-WmiEvent -Query $stopQuery -MessageData $OwnerSid -Action {
+        $newEvent = $Event.SourceEventArgs.NewEvent
+        if ($Event.MessageData -and (New-Object System.Security.Principal.SecurityIdentifier($newEvent.Sid, 0)).Value -ne $Event.MessageData) {
+            return
+        }
+        if ($newEvent.TargetInstance) {
+            $processName = $newEvent.TargetInstance.Name
+            $processID = $newEvent.TargetInstance.ProcessId
+        }
+        else {
+            $processName = $newEvent.ProcessName
+            $processID = $newEvent.ProcessID
+        }
+
+        $data = @{
+            token = $env:TIMEKEEP_TOKEN
+            action = "process_stop"
+            name = $processName
+            pid = $processID
+        }
+        $writer.WriteLine(($data | ConvertTo-Json -Compress))
+        $writer.Flush()
     }
-    $writer.WriteLine(($data | ConvertTo-Json -Compress))
-     $writer.Flush()
+}
+catch {
+    [Console]::Error.WriteLine("Failed to register WMI event subscription: $_")
+    exit 1
 }
 
+# Watch subscriptions and pipe, exiting so the service re-subscribes if either goes away
 while ($true) {
     Start-Sleep -Seconds 1
+
+    if (-not $pipe.IsConnected) {
+        [Console]::Error.WriteLine("Pipe to service disconnected")
+        exit 1
+    }
+    foreach ($job in @($startJob, $stopJob)) {
+        if ($job.State -eq "Failed" -or $job.State -eq "Stopped") {
+            [Console]::Error.WriteLine("WMI event subscription $($job.Name) is $($job.State)")
+            exit 1
+        }
+    }
 }
\ No newline at end of file
@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Longest active sessions are given to reach history when the system is about to sleep, inside the time the OS waits
+// for services to acknowledge it
+const sleepFlushTimeout = 2 * time.Second
+
+// Stops monitoring and heartbeats and moves every active session to history when the system is about to sleep, so
+// time spent asleep isn't recorded as usage
+func (e *EventController) Sleep(logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
+	e.mu.Lock()
+	if e.asleep {
+		e.mu.Unlock()
+		return
+	}
+	e.asleep = true
+	e.mu.Unlock()
+
+	logger.Info("System going to sleep, closing active sessions")
+	sm.Sleeping()
+	e.StopHeartbeats()
+	e.StopProcessMonitor()
+
+	ctx, cancel := context.WithTimeout(context.Background(), sleepFlushTimeout)
+	defer cancel()
+
+	flushed, remaining := sm.FlushSessions(ctx, logger, pr, a, h, repository.EndReasonSleep)
+	if remaining > 0 {
+		logger.Warn("Sessions left active before sleep", "flushed", flushed, "remaining", remaining)
+		return
+	}
+	logger.Info("Closed sessions for sleep", "flushed", flushed)
+}
+
+// Restarts monitoring and heartbeats after the system wakes, unless monitoring is paused. Programs still running
+// start new sessions
+func (e *EventController) Wake(serviceCtx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
+	e.mu.Lock()
+	if !e.asleep {
+		e.mu.Unlock()
+		return
+	}
+	e.asleep = false
+	e.mu.Unlock()
+
+	logger.Info("System woke from sleep, restarting monitoring")
+	sm.Woke()
+
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+
+	e.RefreshProcessMonitor(serviceCtx, logger, sm, pr, a, h)
+	if !e.Paused() && !e.Incognito() {
+		e.rescan(logger, sm, pr, a, h)
+	}
+}
+
+// Reports whether the system is asleep
+func (e *EventController) Asleep() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.asleep
+}
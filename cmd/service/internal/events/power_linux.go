@@ -0,0 +1,86 @@
+//go:build linux
+
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+const (
+	logindDest    = "org.freedesktop.login1"
+	logindPath    = dbus.ObjectPath("/org/freedesktop/login1")
+	logindManager = "org.freedesktop.login1.Manager"
+)
+
+// Follows logind's PrepareForSleep signal, closing sessions before the system sleeps and restarting monitoring after
+// it wakes. A delay inhibitor lock holds off sleep until sessions are written. Without a system bus or logind, sleep
+// isn't detected
+func (e *EventController) WatchSleep(ctx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) error {
+	logger = logs.Component(logger, logs.ComponentMonitor)
+
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		logger.Warn("System bus unavailable, sleep won't be detected", "error", err)
+		return nil
+	}
+	defer conn.Close()
+
+	if err := conn.AddMatchSignal(dbus.WithMatchInterface(logindManager), dbus.WithMatchMember("PrepareForSleep")); err != nil {
+		return fmt.Errorf("error subscribing to sleep signals: %w", err)
+	}
+
+	signals := make(chan *dbus.Signal, 4)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	lock := inhibitSleep(conn, logger)
+	defer func() { lock.Close() }()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case sig, ok := <-signals:
+			if !ok {
+				return errors.New("system bus connection closed")
+			}
+			if sig.Name != logindManager+".PrepareForSleep" || len(sig.Body) != 1 {
+				continue
+			}
+
+			if sleeping, _ := sig.Body[0].(bool); sleeping {
+				e.Sleep(logger, sm, pr, a, h)
+				lock.Close() // Let the system sleep
+				lock = nil
+			} else {
+				lock = inhibitSleep(conn, logger)
+				e.Wake(ctx, logger, sm, pr, a, h)
+			}
+		}
+	}
+}
+
+// Takes a logind delay lock on sleep, held until sessions are closed. Returns nil when logind refuses it, in which
+// case sleep isn't held off
+func inhibitSleep(conn *dbus.Conn, logger *slog.Logger) *os.File {
+	var fd dbus.UnixFD
+	err := conn.Object(logindDest, logindPath).Call(logindManager+".Inhibit", 0, "sleep", "Timekeep", "Closing active sessions", "delay").Store(&fd)
+	if err != nil {
+		logger.Debug("No sleep inhibitor lock, sessions may be closed after sleep starts", "error", err)
+		return nil
+	}
+	return os.NewFile(uintptr(fd), "sleep-inhibitor")
+}
+
+// The process monitor picks up running processes on its first poll
+func (e *EventController) rescan(logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
+}
@@ -0,0 +1,86 @@
+//go:build windows
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/profile"
+	"github.com/jms-guy/timekeep/internal/repository"
+	"golang.org/x/sys/windows"
+)
+
+// Power broadcast event types delivered with svc.PowerEvent
+const (
+	PowerSuspend         = 0x4  // PBT_APMSUSPEND
+	PowerResumeSuspend   = 0x7  // PBT_APMRESUMESUSPEND
+	PowerResumeAutomatic = 0x12 // PBT_APMRESUMEAUTOMATIC
+)
+
+const deviceNotifyCallback = 2 // DEVICE_NOTIFY_CALLBACK
+
+var (
+	powrprof                                   = windows.NewLazySystemDLL("powrprof.dll")
+	procPowerRegisterSuspendResumeNotification = powrprof.NewProc("PowerRegisterSuspendResumeNotification")
+	procPowerUnregisterSuspendResumeNotify     = powrprof.NewProc("PowerUnregisterSuspendResumeNotification")
+
+	// Callbacks can't be freed, so one is made for the process and passes events on to the agent's handler
+	powerCallback = windows.NewCallback(func(_ uintptr, eventType uint32, _ uintptr) uintptr {
+		if handle := powerHandler.Load(); handle != nil {
+			(*handle)(eventType)
+		}
+		return 0
+	})
+	powerHandler atomic.Pointer[func(uint32)]
+)
+
+// DEVICE_NOTIFY_SUBSCRIBE_PARAMETERS
+type deviceNotifySubscribeParameters struct {
+	callback uintptr
+	context  uintptr
+}
+
+// The service gets power events through the SCM, handled in its Execute loop. The per-user agent has no SCM, so it
+// registers for suspend and resume notifications itself. The system waits on the callback before sleeping, so
+// sessions are closed before it returns
+func (e *EventController) WatchSleep(ctx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) error {
+	if !profile.UserScope() {
+		return nil
+	}
+	logger = logs.Component(logger, logs.ComponentMonitor)
+
+	handle := func(eventType uint32) {
+		switch eventType {
+		case PowerSuspend:
+			e.Sleep(logger, sm, pr, a, h)
+		case PowerResumeSuspend, PowerResumeAutomatic:
+			go e.Wake(ctx, logger, sm, pr, a, h)
+		}
+	}
+	powerHandler.Store(&handle)
+	defer powerHandler.Store(nil)
+
+	params := deviceNotifySubscribeParameters{callback: powerCallback}
+	var registration uintptr
+	r, _, _ := procPowerRegisterSuspendResumeNotification.Call(deviceNotifyCallback, uintptr(unsafe.Pointer(&params)), uintptr(unsafe.Pointer(&registration)))
+	if r != 0 {
+		return fmt.Errorf("error registering for power notifications: %w", windows.Errno(r))
+	}
+	defer procPowerUnregisterSuspendResumeNotify.Call(registration)
+
+	<-ctx.Done()
+	return nil
+}
+
+// Process start events aren't raised for programs already running, so look for them
+func (e *EventController) rescan(logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
+	if programs := sm.TrackedNames(); len(programs) > 0 {
+		e.StartPreMonitor(logger, sm, pr, a, h, programs)
+	}
+}
@@ -1,17 +1,22 @@
 <#
     This script runs before the main process monitoring script. It queries for processes that belong to programs being tracked
     that are already running, and sends the service a synthetic "process_start" event, immediately opening an active session for
-    that program.
+    that program. With -UserSession, only processes in this login session are reported, with -OwnerSid only those run by
+    that user, and with -SessionId only those in the given session.
 #>
 param(
-    [string]$Programs
+    [string]$Programs,
+    [switch]$UserSession,
+    [long]$SessionId = -1,
+    [string]$OwnerSid = ""
 )
 
 # Fail fast on errors
 $ErrorActionPreference = "Stop"
 
 # Connect to named pipe opened by service
-$pipe = New-Object System.IO.Pipes.NamedPipeClientStream(".", "Timekeep", "Out")
+$pipeName = if ($env:TIMEKEEP_PIPE) { $env:TIMEKEEP_PIPE } else { "Timekeep" }
+$pipe = New-Object System.IO.Pipes.NamedPipeClientStream(".", $pipeName, "Out")
 $pipe.Connect()
 $writer = New-Object System.IO.StreamWriter($pipe)
 $writer.AutoFlush = $true
@@ -27,11 +32,25 @@ try {
     $set = @{}
     foreach ($n in $tracked) { $set[$n] = $true }
 
+    # Tracked programs first, so owners are only looked up for those
+    $processes = Get-CimInstance Win32_Process | Where-Object { $_.Name -and $set.ContainsKey($_.Name.ToLower()) }
+    if ($UserSession) {
+        $sessionId = (Get-Process -Id $PID).SessionId
+        $processes = $processes | Where-Object { $_.SessionId -eq $sessionId }
+    }
+    elseif ($OwnerSid) {
+        $processes = $processes | Where-Object { (Invoke-CimMethod -InputObject $_ -MethodName GetOwnerSid -ErrorAction SilentlyContinue).Sid -eq $OwnerSid }
+    }
+    elseif ($SessionId -ge 0) {
+        $processes = $processes | Where-Object { $_.SessionId -eq $SessionId }
+    }
+
     # Enumerate current processes and emit synthetic start events
-    Get-CimInstance Win32_Process | ForEach-Object {
+    $processes | ForEach-Object {
         $name = $_.Name
         if ($name -and $set.ContainsKey($name.ToLower())) {
             $data = @{
+                token  = $env:TIMEKEEP_TOKEN
                 action = "process_start"
                 name   = $name
                 pid    = [int]$_.ProcessId
@@ -42,6 +61,7 @@ try {
 }
 catch {
     $err = @{
+        token = $env:TIMEKEEP_TOKEN
         action = "ps_error"
         message = $_.Exception.Message
     }
@@ -0,0 +1,43 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/profile"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Checks whether the service's profile is the one in use, switched to with 'timekeep profile use', and reports whether
+// the service stands by. A service whose profile was switched away from closes its active sessions as of now and stops
+// monitoring and heartbeats, as if paused, until its profile is switched back to
+func (e *EventController) CheckProfile(logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) bool {
+	inUse, active := profile.InUse()
+	standby := ""
+	if !inUse {
+		standby = active
+	}
+
+	e.mu.Lock()
+	was := e.standby
+	e.standby = standby
+	e.mu.Unlock()
+
+	switch {
+	case standby != "" && was == "":
+		logger.Info("Switched to another profile, closing active sessions", "profile", active)
+		e.StopHeartbeats()
+		e.StopProcessMonitor()
+
+		ctx, cancel := context.WithTimeout(context.Background(), sleepFlushTimeout)
+		defer cancel()
+		flushed, remaining := sm.FlushSessions(ctx, logger, pr, a, h, repository.EndReasonProfile)
+		if remaining > 0 {
+			logger.Warn("Sessions left active switching profile", "flushed", flushed, "remaining", remaining)
+		}
+	case standby == "" && was != "":
+		logger.Info("Switched back to this profile")
+	}
+	return standby != ""
+}
@@ -0,0 +1,60 @@
+//go:build linux
+
+package events
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/profile"
+	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckProfileStandsBy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := profile.Set("work"); err != nil {
+		t.Fatalf("Failed to select profile: %v", err)
+	}
+	t.Cleanup(func() { profile.Set(profile.Default) })
+
+	store := testStore(t)
+	ctx := t.Context()
+	assert.Nil(t, store.AddProgram(ctx, database.AddProgramParams{Name: "editor"}))
+	programs, err := store.GetAllPrograms(ctx)
+	assert.Nil(t, err)
+	sm := sessions.NewSessionManager()
+	sm.LoadPrograms(programs)
+
+	e := NewEventController()
+	logger := slog.New(slog.DiscardHandler)
+	sm.CreateSession(ctx, logger, store, "editor", 1)
+
+	assert.False(t, e.CheckProfile(logger, sm, store, store, store), "With no profile switched to, every profile records")
+	assert.False(t, e.Paused())
+
+	assert.Nil(t, profile.Use("personal"))
+	assert.True(t, e.CheckProfile(logger, sm, store, store, store), "Another profile in use should put the service on standby")
+	assert.True(t, e.Paused())
+
+	active, err := store.GetAllActiveSessions(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, active, "Sessions should be closed on standby")
+	history, err := store.GetSessionHistory(ctx, database.GetSessionHistoryParams{ProgramName: "editor", Limit: 10})
+	assert.Nil(t, err)
+	if assert.Len(t, history, 1) {
+		metadata, err := repository.DecodeSessionMetadata(history[0].Metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, repository.EndReasonProfile, metadata.EndReason)
+	}
+	assert.True(t, e.CheckProfile(logger, sm, store, store, store), "Standby should hold while the other profile is in use")
+
+	assert.Nil(t, profile.Use("work"))
+	assert.False(t, e.CheckProfile(logger, sm, store, store, store), "Switching back should resume recording")
+	assert.False(t, e.Paused())
+
+	assert.Nil(t, profile.Use(profile.Default))
+	assert.True(t, e.CheckProfile(logger, sm, store, store, store), "The default profile in use should put named profiles on standby")
+}
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Longest a snooze's deadline is given to be saved or cleared
+const snoozeSaveTimeout = 5 * time.Second
+
+// Pauses monitoring until until, then resumes it. The deadline is saved, so a snooze outlasts service restarts
+func (e *EventController) Snooze(serviceCtx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, until time.Time) {
+	if e.Snoozes != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), snoozeSaveTimeout)
+		if err := e.Snoozes.SaveSnooze(ctx, until.UTC()); err != nil {
+			logger.Warn("Failed to save snooze, it ends if the service restarts", "error", err)
+		}
+		cancel()
+	}
+
+	e.resumeAt(serviceCtx, logger, sm, pr, a, h, until)
+	if !e.Paused() {
+		e.pause(logger)
+	}
+	logger.Info("Snoozing monitoring", "until", until)
+}
+
+// Pauses monitoring again if the service stopped during a snooze that hasn't ended. Called on start, before the
+// monitor starts
+func (e *EventController) RestoreSnooze(serviceCtx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
+	if e.Snoozes == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snoozeSaveTimeout)
+	defer cancel()
+
+	snooze, err := e.Snoozes.GetSnooze(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to read snooze", "error", err)
+		return
+	}
+
+	if !snooze.ResumeAt.After(time.Now()) {
+		logger.Info("Snooze ended while the service was stopped", "until", snooze.ResumeAt)
+		if err := e.Snoozes.ClearSnooze(ctx); err != nil {
+			logger.Warn("Failed to clear snooze", "error", err)
+		}
+		return
+	}
+
+	e.resumeAt(serviceCtx, logger, sm, pr, a, h, snooze.ResumeAt)
+	e.mu.Lock()
+	e.paused = true
+	e.mu.Unlock()
+	logger.Info("Monitoring snoozed", "until", snooze.ResumeAt)
+}
+
+// Schedules Resume at until, replacing the end of any earlier snooze
+func (e *EventController) resumeAt(serviceCtx context.Context, logger *slog.Logger, sm *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, until time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.resumeTimer != nil {
+		e.resumeTimer.Stop()
+	}
+	e.pausedUntil = until
+
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(until), func() {
+		e.mu.Lock()
+		current := e.resumeTimer == timer
+		e.mu.Unlock()
+		if !current || serviceCtx.Err() != nil {
+			return
+		}
+		logger.Info("Snooze over")
+		e.Resume(serviceCtx, logger, sm, pr, a, h)
+	})
+	e.resumeTimer = timer
+}
+
+// Cancels the end of a snooze, forgetting the saved deadline
+func (e *EventController) clearSnooze(logger *slog.Logger) {
+	e.mu.Lock()
+	snoozed := e.resumeTimer != nil
+	if snoozed {
+		e.resumeTimer.Stop()
+		e.resumeTimer = nil
+	}
+	e.pausedUntil = time.Time{}
+	e.mu.Unlock()
+
+	if !snoozed || e.Snoozes == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), snoozeSaveTimeout)
+	defer cancel()
+	if err := e.Snoozes.ClearSnooze(ctx); err != nil {
+		logger.Warn("Failed to clear snooze", "error", err)
+	}
+}
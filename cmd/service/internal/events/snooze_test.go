@@ -0,0 +1,81 @@
+package events
+
+import (
+	"database/sql"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnoozeOutlastsRestart(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // Resuming reloads the config
+	store := testStore(t)
+	ctx := t.Context()
+	logger := slog.New(slog.DiscardHandler)
+	sm := sessions.NewSessionManager()
+
+	e := NewEventController()
+	e.Snoozes = store
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	e.Snooze(ctx, logger, sm, store, store, store, until)
+	assert.True(t, e.Paused())
+	snooze, err := store.GetSnooze(ctx)
+	if assert.Nil(t, err, "The snooze should be saved") {
+		assert.True(t, until.Equal(snooze.ResumeAt))
+	}
+
+	restarted := NewEventController()
+	restarted.Snoozes = store
+	restarted.RestoreSnooze(ctx, logger, sm, store, store, store)
+	assert.True(t, restarted.Paused(), "A snooze should still pause monitoring after a restart")
+	assert.True(t, until.Equal(restarted.pausedUntil), "The snooze should end when it was set to, not %v", restarted.pausedUntil)
+
+	restarted.Resume(ctx, logger, sm, store, store, store)
+	assert.False(t, restarted.Paused())
+	_, err = store.GetSnooze(ctx)
+	assert.ErrorIs(t, err, sql.ErrNoRows, "Resuming early should forget the snooze")
+
+	assert.Nil(t, store.SaveSnooze(ctx, time.Now().Add(-time.Minute).UTC()))
+	expired := NewEventController()
+	expired.Snoozes = store
+	expired.RestoreSnooze(ctx, logger, sm, store, store, store)
+	assert.False(t, expired.Paused(), "A snooze that ended while stopped should not pause monitoring")
+	_, err = store.GetSnooze(ctx)
+	assert.ErrorIs(t, err, sql.ErrNoRows, "A snooze that ended should be forgotten")
+}
+
+func TestSnoozeResumesOnExpiry(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store := testStore(t)
+	ctx := t.Context()
+	logger := slog.New(slog.DiscardHandler)
+	sm := sessions.NewSessionManager()
+
+	e := NewEventController()
+	e.Snoozes = store
+	e.Snooze(ctx, logger, sm, store, store, store, time.Now().Add(100*time.Millisecond))
+	assert.True(t, e.Paused())
+	assert.Eventually(t, func() bool { return !e.Paused() }, 5*time.Second, 10*time.Millisecond, "Monitoring should resume when the snooze ends")
+	_, err := store.GetSnooze(ctx)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// A snooze restored after a restart ends on time too
+	assert.Nil(t, store.SaveSnooze(ctx, time.Now().Add(100*time.Millisecond).UTC()))
+	restarted := NewEventController()
+	restarted.Snoozes = store
+	restarted.RestoreSnooze(ctx, logger, sm, store, store, store)
+	assert.True(t, restarted.Paused())
+	assert.Eventually(t, func() bool { return !restarted.Paused() }, 5*time.Second, 10*time.Millisecond)
+	_, err = store.GetSnooze(ctx)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// A newer snooze replaces the end of the earlier one
+	e.Snooze(ctx, logger, sm, store, store, store, time.Now().Add(100*time.Millisecond))
+	e.Snooze(ctx, logger, sm, store, store, store, time.Now().Add(time.Hour))
+	time.Sleep(300 * time.Millisecond)
+	assert.True(t, e.Paused(), "The earlier end should no longer resume monitoring")
+	e.Resume(ctx, logger, sm, store, store, store)
+}
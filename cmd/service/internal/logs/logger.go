@@ -1,38 +1,143 @@
 package logs
 
 import (
-	"log"
+	"context"
+	"io"
+	"log/slog"
 	"os"
+	"strings"
+
+	"github.com/jms-guy/timekeep/internal/config"
+)
+
+// Key of the component attribute on log records
+const ComponentKey = "component"
+
+// Component names attached to log records, for filtering
+const (
+	ComponentService    = "service"
+	ComponentMonitor    = "monitor"
+	ComponentSessions   = "sessions"
+	ComponentHeartbeats = "heartbeats"
+	ComponentTransport  = "transport"
+	ComponentConfig     = "config"
+	ComponentSync       = "sync"
+	ComponentTeam       = "team"
+	ComponentKimai      = "kimai"
+	ComponentHarvest    = "harvest"
+	ComponentPolicy     = "policy"
+	ComponentLimits     = "limits"
+	ComponentDigest     = "digest"
+	ComponentWebhooks   = "webhooks"
+	ComponentWorkspaces = "workspaces"
 )
 
 type Logs struct {
-	Logger  *log.Logger // Logging object
-	LogFile *os.File    // Reference to the output log file
+	Logger  *slog.Logger   // Logging object
+	LogFile *RotatingFile  // Reference to the output log file, nil when logging to the journal
+	level   *slog.LevelVar // Minimum level logged, adjustable on config reload
 }
 
-// Creates logger object, and log file reference
-func NewLogs() (*Logs, error) {
+// Creates logger object, and log file reference, with level and format taken from config
+func NewLogs(cfg config.LogConfig) (*Logs, error) {
 	logPath, err := getLogPath()
 	if err != nil {
 		return nil, err
 	}
 
-	logger, f, err := CreateLogger(logPath)
+	out, f, err := openLogOutput(logPath, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Logs{Logger: logger, LogFile: f}, nil
+	level := new(slog.LevelVar)
+	level.Set(parseLevel(cfg.Level))
+
+	return &Logs{Logger: slog.New(newHandler(out, cfg.Format, level)), LogFile: f, level: level}, nil
 }
 
 func NewTestLogs() *Logs {
-	return &Logs{Logger: log.New(os.Stdout, "DEBUG: ", log.LstdFlags)}
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelDebug)
+
+	return &Logs{Logger: slog.New(&componentHandler{Handler: slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level})}), level: level}
+}
+
+// Returns a logger tagging its records with the given component
+func Component(logger *slog.Logger, name string) *slog.Logger {
+	return logger.With(ComponentKey, name)
+}
+
+// Changes the minimum level logged, used when config is reloaded
+func (l *Logs) SetLevel(level string) {
+	l.level.Set(parseLevel(level))
 }
 
 // Closes any open log files
 func (l *Logs) FileCleanup() {
 	if l.LogFile != nil {
-		l.Logger.Println("INFO: Closing log file connection")
+		l.Logger.Info("Closing log file connection")
 		l.LogFile.Close()
 	}
 }
+
+// Builds a JSON or text handler writing to out
+func newHandler(out io.Writer, format string, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if strings.EqualFold(format, "json") {
+		return &componentHandler{Handler: slog.NewJSONHandler(out, opts)}
+	}
+	return &componentHandler{Handler: slog.NewTextHandler(out, opts)}
+}
+
+// Handler keeping a single component attribute, so a component set further down a call chain replaces
+// the caller's instead of repeating the key
+type componentHandler struct {
+	slog.Handler
+	component string
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.component != "" {
+		r = r.Clone()
+		r.AddAttrs(slog.String(ComponentKey, h.component))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	component := h.component
+	rest := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		if attr.Key == ComponentKey {
+			component = attr.Value.String()
+			continue
+		}
+		rest = append(rest, attr)
+	}
+
+	inner := h.Handler
+	if len(rest) > 0 {
+		inner = inner.WithAttrs(rest)
+	}
+
+	return &componentHandler{Handler: inner, component: component}
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return &componentHandler{Handler: h.Handler.WithGroup(name), component: h.component}
+}
+
+// Converts a config level name to a slog level, defaulting to info
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -3,18 +3,33 @@
 package logs
 
 import (
-	"log"
+	"io"
 	"os"
 	"path/filepath"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/profile"
 )
 
-// Get path for logging file
+// Get path for logging file, kept in the data directory so sandboxed units can write it
 func getLogPath() (string, error) {
-	logDir := "/var/log/timekeep"
-	return filepath.Join(logDir, "timekeep.log"), nil
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", profile.Suffixed("timekeep"), "logs", "timekeep.log"), nil
 }
 
-func CreateLogger(logPath string) (*log.Logger, *os.File, error) {
-	logger := log.Default()
-	return logger, nil, nil
+// Logs go to stderr, collected by the journal, unless file logging is enabled in config
+func openLogOutput(logPath string, cfg config.LogConfig) (io.Writer, *RotatingFile, error) {
+	if !cfg.File {
+		return os.Stderr, nil, nil
+	}
+
+	f, err := OpenRotatingFile(logPath, cfg.MaxSizeMB, cfg.MaxBackups, cfg.MaxAgeDays)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, f, nil
 }
@@ -3,14 +3,16 @@
 package logs
 
 import (
-	"log"
+	"io"
 	"os"
+
+	"github.com/jms-guy/timekeep/internal/config"
 )
 
 func getLogPath() (string, error) {
 	return "", nil
 }
 
-func CreateLogger(logPath string) (*log.Logger, *os.File, error) {
-	return nil, nil, nil
+func openLogOutput(logPath string, cfg config.LogConfig) (io.Writer, *RotatingFile, error) {
+	return os.Stderr, nil, nil
 }
@@ -0,0 +1,189 @@
+package logs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default rotation settings, used when unset in config
+const (
+	defaultMaxSizeMB  = 10
+	defaultMaxBackups = 5
+	defaultMaxAgeDays = 30
+	maxFileAge        = 24 * time.Hour  // A log file is rotated at least this often
+	rotateRetry       = 5 * time.Minute // Wait before rotating again after a failure, writing on to the current file
+	backupTimeFormat  = "20060102-150405.000"
+)
+
+// Log file writer that rotates the file once it reaches a size limit or is a day old, keeping a
+// limited number of timestamped backups and removing backups past the retention age
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	file       *os.File
+	size       int64
+	openedAt   time.Time
+	retryAt    time.Time        // No rotation before this, set when one failed
+	now        func() time.Time // Clock, replaced in tests
+}
+
+// Opens the log file for appending, creating its directory if needed
+func OpenRotatingFile(path string, maxSizeMB, maxBackups, maxAgeDays int) (*RotatingFile, error) {
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultMaxSizeMB
+	}
+	if maxBackups <= 0 {
+		maxBackups = defaultMaxBackups
+	}
+	if maxAgeDays <= 0 {
+		maxAgeDays = defaultMaxAgeDays
+	}
+
+	// #nosec G301
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("ERROR: failed to create log directory: %w", err)
+	}
+
+	r := &RotatingFile{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+		maxAge:     time.Duration(maxAgeDays) * 24 * time.Hour,
+		now:        time.Now,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	r.removeOldBackups()
+
+	return r, nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	now := r.now()
+	if r.size > 0 && !now.Before(r.retryAt) && (r.size+int64(len(p)) > r.maxSize || now.Sub(r.openedAt) > maxFileAge) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Flushes the current log file to disk
+func (r *RotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Sync()
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// Opens the log file, picking up the size of any existing content
+func (r *RotatingFile) open() error {
+	// #nosec -- Log file not security issue
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	r.file = f
+	r.size = info.Size()
+	r.openedAt = r.now()
+	return nil
+}
+
+// Moves the current file aside as a timestamped backup and starts a new one. When the file can't be moved, as on
+// Windows while another process has it open, it is reopened and written on until a later retry
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	r.file = nil
+
+	now := r.now()
+	if err := os.Rename(r.path, r.backupName(now)); err != nil {
+		if openErr := r.open(); openErr != nil {
+			return fmt.Errorf("failed to rotate log file: %w, and to reopen it: %w", err, openErr)
+		}
+		r.retryAt = now.Add(rotateRetry)
+		return nil
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.removeOldBackups()
+	return nil
+}
+
+// Backup file name, ex. timekeep-20250930-150405.000.log
+func (r *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(r.path)
+	base := strings.TrimSuffix(r.path, ext)
+	return fmt.Sprintf("%s-%s%s", base, t.Format(backupTimeFormat), ext)
+}
+
+// Removes backups beyond the retention count or older than the retention age
+func (r *RotatingFile) removeOldBackups() {
+	ext := filepath.Ext(r.path)
+	pattern := strings.TrimSuffix(r.path, ext) + "-*" + ext
+
+	backups, err := filepath.Glob(pattern)
+	if err != nil {
+		return
+	}
+
+	// Timestamped names sort oldest first
+	sort.Strings(backups)
+
+	for i, backup := range backups {
+		remove := len(backups)-i > r.maxBackups
+		if !remove {
+			if info, err := os.Stat(backup); err == nil && r.now().Sub(info.ModTime()) > r.maxAge {
+				remove = true
+			}
+		}
+		if remove {
+			os.Remove(backup)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package logs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Opens a rotating file in a temporary directory, rotating past maxSize bytes, on a clock the test moves
+func testRotatingFile(t *testing.T, maxSize int64, maxBackups int) (*RotatingFile, *time.Time) {
+	now := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	r, err := OpenRotatingFile(filepath.Join(t.TempDir(), "timekeep.log"), 1, maxBackups, 30)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	r.maxSize = maxSize
+	r.now = func() time.Time { return now }
+	r.openedAt = now
+	return r, &now
+}
+
+func backups(t *testing.T, r *RotatingFile) []string {
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(r.path), "timekeep-*.log"))
+	assert.Nil(t, err)
+	return matches
+}
+
+func TestRotateOnSizeAndAge(t *testing.T) {
+	r, now := testRotatingFile(t, 10, 5)
+
+	_, err := r.Write([]byte("12345678\n"))
+	assert.Nil(t, err)
+	assert.Empty(t, backups(t, r), "A file under the limit should not be rotated")
+
+	_, err = r.Write([]byte("next\n"))
+	assert.Nil(t, err)
+	if assert.Len(t, backups(t, r), 1) {
+		data, _ := os.ReadFile(backups(t, r)[0])
+		assert.Equal(t, "12345678\n", string(data))
+		assert.Equal(t, r.backupName(*now), backups(t, r)[0])
+	}
+	data, _ := os.ReadFile(r.path)
+	assert.Equal(t, "next\n", string(data), "The write past the limit should start the new file")
+
+	*now = now.Add(maxFileAge + time.Minute)
+	_, err = r.Write([]byte("a day later\n"))
+	assert.Nil(t, err)
+	assert.Len(t, backups(t, r), 2, "A file a day old should be rotated whatever its size")
+}
+
+func TestRotateRetention(t *testing.T) {
+	r, now := testRotatingFile(t, 1, 2)
+
+	for range 4 {
+		*now = now.Add(time.Second)
+		_, err := r.Write([]byte("line\n"))
+		assert.Nil(t, err)
+	}
+	assert.Len(t, backups(t, r), 2, "Only the newest backups should be kept")
+	assert.Equal(t, r.backupName(*now), backups(t, r)[1])
+
+	// Backups past the retention age are removed, however few there are
+	old := backups(t, r)[0]
+	assert.Nil(t, os.Chtimes(old, now.AddDate(0, 0, -31), now.AddDate(0, 0, -31)))
+	r.removeOldBackups()
+	assert.NotContains(t, backups(t, r), old)
+	assert.Len(t, backups(t, r), 1)
+}
+
+func TestRotateRenameFailure(t *testing.T) {
+	r, now := testRotatingFile(t, 10, 5)
+
+	// A non-empty directory where the backup should go makes the rename fail
+	blocked := r.backupName(*now)
+	assert.Nil(t, os.MkdirAll(filepath.Join(blocked, "in-the-way"), 0o755))
+
+	_, err := r.Write([]byte("12345678\n"))
+	assert.Nil(t, err)
+	n, err := r.Write([]byte("kept\n"))
+	assert.Nil(t, err, "A failed rotation should not fail the write")
+	assert.Equal(t, 5, n)
+	_, err = r.Write([]byte("still\n"))
+	assert.Nil(t, err, "Writes should go on after a failed rotation")
+
+	data, _ := os.ReadFile(r.path)
+	assert.Equal(t, "12345678\nkept\nstill\n", string(data), "Lines should be written to the reopened file")
+
+	// Rotation is retried once the wait is over
+	assert.Nil(t, os.RemoveAll(blocked))
+	*now = now.Add(rotateRetry)
+	_, err = r.Write([]byte("rotated\n"))
+	assert.Nil(t, err)
+	assert.Len(t, backups(t, r), 1)
+	data, _ = os.ReadFile(r.path)
+	assert.Equal(t, "rotated\n", string(data))
+}
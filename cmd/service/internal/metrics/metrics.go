@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/jms-guy/timekeep/internal/ipc"
+)
+
+// Internal service counters, reported over IPC by the metrics action
+type Counters struct {
+	EventsProcessed   atomic.Int64 // Process start/stop events handled
+	SessionsCreated   atomic.Int64 // Active sessions opened
+	SessionsClosed    atomic.Int64 // Sessions moved to history
+	HeartbeatsSent    atomic.Int64 // WakaTime/Wakapi heartbeats sent
+	HeartbeatsFailed  atomic.Int64 // WakaTime/Wakapi heartbeats that failed
+	HeartbeatsDropped atomic.Int64 // Heartbeat batches dropped from a full send queue
+	HeartbeatQueue    atomic.Int64 // Heartbeat batches waiting to be sent
+	ValidatorCleanups atomic.Int64 // Stale sessions ended by the session validator
+	DBErrors          atomic.Int64 // Failed database operations
+	TaskRestarts      atomic.Int64 // Background tasks restarted by the supervisor after failing
+}
+
+// Copies current counter values
+func (c *Counters) Snapshot() ipc.Metrics {
+	return ipc.Metrics{
+		EventsProcessed:   c.EventsProcessed.Load(),
+		SessionsCreated:   c.SessionsCreated.Load(),
+		SessionsClosed:    c.SessionsClosed.Load(),
+		HeartbeatsSent:    c.HeartbeatsSent.Load(),
+		HeartbeatsFailed:  c.HeartbeatsFailed.Load(),
+		HeartbeatsDropped: c.HeartbeatsDropped.Load(),
+		HeartbeatQueue:    c.HeartbeatQueue.Load(),
+		ValidatorCleanups: c.ValidatorCleanups.Load(),
+		DBErrors:          c.DBErrors.Load(),
+		TaskRestarts:      c.TaskRestarts.Load(),
+	}
+}
@@ -0,0 +1,75 @@
+package sessions
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Pseudo PID holding open sessions fed by activity reports, such as the browser's active tab, rather than a process
+const ActivityPID = -1
+
+// Most distinct titles kept per activity session
+const maxTitles = 20
+
+// Records activity for the pseudo-program name, starting its session if it has none. A non-empty title is kept for
+// the session's metadata
+func (sm *SessionManager) Activity(ctx context.Context, logger *slog.Logger, a repository.ActiveRepository, name, title string) {
+	sm.CreateSession(ctx, logger, a, name, ActivityPID)
+	if title == "" {
+		return
+	}
+
+	t := sm.Lookup(name)
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if !slices.Contains(t.Titles, title) && len(t.Titles) < maxTitles {
+		t.Titles = append(t.Titles, title)
+	}
+	t.mu.Unlock()
+}
+
+// Ends the activity session of the pseudo-program name as of end, moving it to history with reason
+func (sm *SessionManager) EndActivity(ctx context.Context, logger *slog.Logger, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, name, reason string, end time.Time) {
+	logger = logs.Component(logger, logs.ComponentSessions)
+
+	t := sm.Lookup(name)
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	if _, ok := t.PIDs[ActivityPID]; !ok {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.PIDs, ActivityPID)
+	last := len(t.PIDs) == 0
+	if last {
+		t.writing++
+	}
+	t.mu.Unlock()
+
+	if last {
+		sm.moveSessionToHistory(ctx, logger, pr, a, h, name, reason, end)
+		t.endWrite()
+		sm.dropIdle(name, t)
+	}
+}
+
+// Returns the titles recorded for name's session
+func (sm *SessionManager) titles(name string) []string {
+	t := sm.Lookup(name)
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.Titles)
+}
@@ -0,0 +1,76 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Longest after waking that a program's new session is linked to the session sleep closed. Monitors find running
+// processes within seconds of waking, so later starts are fresh sessions
+const continuationWindow = 30 * time.Second
+
+// Clears continuations left from an earlier sleep, before sessions are closed for a new one
+func (sm *SessionManager) Sleeping() {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	sm.resuming = make(map[string]string)
+}
+
+// Records the time the system woke, opening the window for sessions to resume their pre-sleep sessions
+func (sm *SessionManager) Woke() {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	sm.wokeAt = sm.now()
+}
+
+// Returns the continuation ID to record for processName's session ending for reason. A session closed by sleep gets
+// one, kept for the session that resumes it; a resumed session keeps the ID it inherited
+func (sm *SessionManager) continuation(processName, reason string) string {
+	var id string
+	if t := sm.Lookup(processName); t != nil {
+		t.mu.Lock()
+		id = t.Continues
+		t.mu.Unlock()
+	}
+	if reason != repository.EndReasonSleep {
+		return id
+	}
+
+	if id == "" {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return ""
+		}
+		id = hex.EncodeToString(b)
+	}
+
+	sm.Mu.Lock()
+	if sm.resuming == nil {
+		sm.resuming = make(map[string]string)
+	}
+	sm.resuming[processName] = id
+	sm.Mu.Unlock()
+
+	return id
+}
+
+// Returns the continuation ID for a session of name starting at now, if sleep closed the program's last session and
+// the system woke within continuationWindow. Caller must not hold sm.Mu or the program's lock
+func (sm *SessionManager) takeContinuation(name string, now time.Time) string {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+
+	id, ok := sm.resuming[name]
+	if !ok {
+		return ""
+	}
+	delete(sm.resuming, name)
+
+	if sm.wokeAt.IsZero() || now.Sub(sm.wokeAt) > continuationWindow {
+		return ""
+	}
+	return id
+}
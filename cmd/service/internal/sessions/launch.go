@@ -0,0 +1,30 @@
+package sessions
+
+import "github.com/jms-guy/timekeep/internal/repository"
+
+// Records how name's running session was started, unless it already is. Monitors call it after CreateSession, with the
+// launch context of the process it was given
+func (sm *SessionManager) RecordLaunch(name string, launch repository.LaunchContext) {
+	t := sm.Lookup(name)
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.Launch == nil && len(t.PIDs) > 0 {
+		t.Launch = &launch
+	}
+}
+
+// Returns how name's session was started, nil when it isn't known
+func (sm *SessionManager) launch(name string) *repository.LaunchContext {
+	t := sm.Lookup(name)
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.Launch
+}
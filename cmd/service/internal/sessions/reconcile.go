@@ -0,0 +1,90 @@
+package sessions
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Largest difference between a row's start time and the in-memory start before the row is rewritten. Rows store
+// times without the monotonic reading, and a little rounding
+const startTolerance = time.Second
+
+// Aligns active_sessions rows with the in-memory sessions, which drift apart after a crash or a failed write. Rows for
+// programs with no running session are removed without recording history, as when those sessions ended is unknown.
+// Running programs with no row, or a row with a different start, get a row matching memory. The session lock is held
+// throughout, so no session starts or ends in between, and programs with a session write already under way are left
+// alone, their rows not yet matching memory
+func (sm *SessionManager) Reconcile(ctx context.Context, logger *slog.Logger, a repository.ActiveRepository) (ipc.Reconciliation, error) {
+	logger = logs.Component(logger, logs.ComponentSessions)
+	result := ipc.Reconciliation{Removed: []string{}, Recreated: []string{}}
+
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+
+	rows, err := a.GetAllActiveSessions(ctx)
+	if err != nil {
+		sm.Metrics.DBErrors.Add(1)
+		return result, fmt.Errorf("error getting active sessions: %w", err)
+	}
+
+	live := map[string]time.Time{}
+	busy := map[string]bool{}
+	for name, t := range sm.Programs {
+		if t == nil {
+			continue
+		}
+		t.mu.Lock()
+		if t.writing > 0 {
+			busy[name] = true
+		} else if len(t.PIDs) > 0 {
+			live[name] = t.StartAt
+		}
+		t.mu.Unlock()
+	}
+
+	stored := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		stored[row.ProgramName] = row.StartTime
+		if _, ok := live[row.ProgramName]; ok || busy[row.ProgramName] {
+			continue
+		}
+
+		if err := a.RemoveActiveSession(ctx, row.ProgramName); err != nil {
+			sm.Metrics.DBErrors.Add(1)
+			return result, fmt.Errorf("error removing active session for %s: %w", row.ProgramName, err)
+		}
+		result.Removed = append(result.Removed, row.ProgramName)
+		logger.Info("Removed active session with no running processes", "program", row.ProgramName, "start", row.StartTime)
+	}
+
+	for name, start := range live {
+		if row, ok := stored[name]; ok {
+			if diff := row.Sub(start); diff <= startTolerance && diff >= -startTolerance {
+				continue
+			}
+			if err := a.RemoveActiveSession(ctx, name); err != nil {
+				sm.Metrics.DBErrors.Add(1)
+				return result, fmt.Errorf("error removing active session for %s: %w", name, err)
+			}
+		}
+
+		if err := a.CreateActiveSession(ctx, database.CreateActiveSessionParams{ProgramName: name, StartTime: start.UTC()}); err != nil {
+			sm.Metrics.DBErrors.Add(1)
+			return result, fmt.Errorf("error creating active session for %s: %w", name, err)
+		}
+		result.Recreated = append(result.Recreated, name)
+		logger.Info("Recreated active session from running processes", "program", name, "start", start)
+	}
+
+	sort.Strings(result.Removed)
+	sort.Strings(result.Recreated)
+	return result, nil
+}
@@ -0,0 +1,113 @@
+package sessions
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/repository"
+	mysql "github.com/jms-guy/timekeep/sql"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func testStore(t *testing.T) repository.Store {
+	db, err := mysql.OpenTestDatabase()
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return repository.NewSqliteStore(db)
+}
+
+// Returns a session manager tracking names, with store holding the programs
+func testManager(t *testing.T, store repository.Store, names ...string) *SessionManager {
+	for _, name := range names {
+		err := store.AddProgram(t.Context(), database.AddProgramParams{Name: name})
+		assert.Nil(t, err)
+	}
+	programs, err := store.GetAllPrograms(t.Context())
+	assert.Nil(t, err)
+
+	sm := NewSessionManager()
+	sm.LoadPrograms(programs)
+	return sm
+}
+
+func TestReconcile(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+	logger := slog.New(slog.DiscardHandler)
+	sm := testManager(t, store, "editor", "browser", "terminal", "player")
+
+	// A running session whose row is intact, one whose row was lost and one whose row has the wrong start
+	sm.CreateSession(ctx, logger, store, "editor", 1)
+	sm.CreateSession(ctx, logger, store, "browser", 2)
+	sm.CreateSession(ctx, logger, store, "terminal", 3)
+	assert.Nil(t, store.RemoveActiveSession(ctx, "browser"))
+	assert.Nil(t, store.RemoveActiveSession(ctx, "terminal"))
+	wrongStart := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	assert.Nil(t, store.CreateActiveSession(ctx, database.CreateActiveSessionParams{ProgramName: "terminal", StartTime: wrongStart}))
+	// A row left behind by a session that ended while the service was down
+	assert.Nil(t, store.CreateActiveSession(ctx, database.CreateActiveSessionParams{ProgramName: "player", StartTime: wrongStart}))
+
+	result, err := sm.Reconcile(ctx, logger, store)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"player"}, result.Removed)
+	assert.Equal(t, []string{"browser", "terminal"}, result.Recreated)
+
+	rows, err := store.GetAllActiveSessions(ctx)
+	assert.Nil(t, err)
+	starts := map[string]time.Time{}
+	for _, row := range rows {
+		starts[row.ProgramName] = row.StartTime
+	}
+	assert.Len(t, starts, 3)
+	for _, name := range []string{"editor", "browser", "terminal"} {
+		t.Run(name, func(t *testing.T) {
+			start, ok := starts[name]
+			assert.True(t, ok)
+			assert.WithinDuration(t, sm.Lookup(name).StartAt, start, startTolerance)
+		})
+	}
+
+	result, err = sm.Reconcile(ctx, logger, store)
+	assert.Nil(t, err)
+	assert.Empty(t, result.Removed)
+	assert.Empty(t, result.Recreated)
+}
+
+func TestReconcileSkipsSessionsBeingWritten(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+	logger := slog.New(slog.DiscardHandler)
+	sm := testManager(t, store, "editor", "browser")
+
+	// editor's last process exited and its move to history hasn't written yet, browser's first process started and
+	// its row isn't written yet
+	sm.CreateSession(ctx, logger, store, "editor", 1)
+	editor := sm.Lookup("editor")
+	editor.mu.Lock()
+	delete(editor.PIDs, 1)
+	editor.writing++
+	editor.mu.Unlock()
+
+	sm.CreateSession(ctx, logger, store, "browser", 2)
+	assert.Nil(t, store.RemoveActiveSession(ctx, "browser"))
+	browser := sm.Lookup("browser")
+	done := browser.beginWrite()
+
+	result, err := sm.Reconcile(ctx, logger, store)
+	assert.Nil(t, err)
+	assert.Empty(t, result.Removed)
+	assert.Empty(t, result.Recreated)
+
+	// Once the writes are through, reconciling acts on them again
+	editor.endWrite()
+	done()
+	result, err = sm.Reconcile(ctx, logger, store)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"editor"}, result.Removed)
+	assert.Equal(t, []string{"browser"}, result.Recreated)
+}
@@ -2,40 +2,312 @@ package sessions
 
 import (
 	"context"
-	"log"
-	"strings"
+	"database/sql"
+	"log/slog"
+	"os"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
+	"github.com/jms-guy/timekeep/cmd/service/internal/metrics"
 	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/policy"
+	"github.com/jms-guy/timekeep/internal/progname"
 	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/jms-guy/timekeep/internal/webhook"
 )
 
+// In-memory state of a tracked program. Fields are guarded by mu, which is taken after sm.Mu when both are held
 type Tracked struct {
+	mu         sync.Mutex
+	Category   string
+	Project    string
+	PIDs       map[int]struct{}
+	StartAt    time.Time
+	LastSeen   time.Time
+	Continues  string                    // Continuation ID of the pre-sleep session this one resumes, empty for a fresh session
+	Titles     []string                  // Distinct titles reported for an activity session, recorded in its metadata
+	Workspaces map[string]time.Duration  // Time the session spent on each workspace, recorded in its metadata
+	Launch     *repository.LaunchContext // How the session's first process was started, recorded in its metadata
+	removed    bool                      // Dropped from sm.Programs, holders of a stale pointer must look the program up again
+	writing    int                       // Session writes under way, whose database rows don't yet match memory
+}
+
+// Records pid as seen at now if it is already tracked, reporting whether it was
+func (t *Tracked) Seen(pid int, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.PIDs[pid]; !ok {
+		return false
+	}
+	t.LastSeen = now
+	return true
+}
+
+// Returns the program's category and project, and whether any of its processes are running
+func (t *Tracked) Details() (category, project string, running bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Category, t.Project, len(t.PIDs) > 0
+}
+
+// Marks tracked PIDs present in live as seen at now. Returns the PIDs missing from live for at least grace, and those
+// missing but still within grace
+func (t *Tracked) Missing(live map[int]struct{}, now time.Time, grace time.Duration) (expired, pending []int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for pid := range t.PIDs {
+		if _, ok := live[pid]; ok {
+			t.LastSeen = now
+			continue
+		}
+
+		if now.Sub(t.LastSeen) >= grace {
+			expired = append(expired, pid)
+		} else {
+			pending = append(pending, pid)
+		}
+	}
+	return expired, pending
+}
+
+// Cached details of a program tracked in the database
+type ProgramInfo struct {
 	Category string
 	Project  string
-	PIDs     map[int]struct{}
-	StartAt  time.Time
-	LastSeen time.Time
 }
 
 type SessionManager struct {
 	Programs map[string]*Tracked
-	Mu       sync.Mutex
+	Mu       sync.RWMutex                        // Guards the Programs map and program cache. Each Tracked guards its own fields
+	catalog  map[string]ProgramInfo              // Tracked programs cached from the database, refreshed on startup and IPC refresh
+	device   atomic.Value                        // Device label recorded on sessions moved to history
+	Metrics  *metrics.Counters                   // Service counters, shared with the event controller
+	clock    func() time.Time                    // Time source for session timestamps, replaced when simulating
+	resuming map[string]string                   // Continuation IDs of sessions closed by sleep, by program. Guarded by Mu
+	wokeAt   time.Time                           // When the system last woke from sleep. Guarded by Mu
+	maxLen   atomic.Int64                        // Length at which running sessions are split, 0 for no cap
+	policy   atomic.Pointer[policy.Policy]       // Managed tracking policy, nil when none applies
+	notifier atomic.Pointer[func(webhook.Event)] // Receives each session started or ended, nil for none
 }
 
 func NewSessionManager() *SessionManager {
-	return &SessionManager{Programs: make(map[string]*Tracked)}
+	return &SessionManager{Programs: make(map[string]*Tracked), catalog: make(map[string]ProgramInfo), Metrics: &metrics.Counters{}}
+}
+
+// Sets the device label recorded on sessions moved to history
+func (sm *SessionManager) SetDevice(name string) {
+	sm.device.Store(name)
+}
+
+// Sets the length at which running sessions are split by SplitLongSessions, 0 to leave sessions uncapped
+func (sm *SessionManager) SetMaxSession(limit time.Duration) {
+	sm.maxLen.Store(int64(limit))
+}
+
+// Sets the managed tracking policy applied by LoadPrograms, nil for none
+func (sm *SessionManager) SetPolicy(p *policy.Policy) {
+	sm.policy.Store(p)
+}
+
+// Returns the managed tracking policy, nil when none applies
+func (sm *SessionManager) Policy() *policy.Policy {
+	return sm.policy.Load()
+}
+
+// Sets the function receiving each session started or ended, nil for none. It's called inline, so it must not block
+func (sm *SessionManager) SetNotifier(notify func(webhook.Event)) {
+	if notify == nil {
+		sm.notifier.Store(nil)
+		return
+	}
+	sm.notifier.Store(&notify)
+}
+
+func (sm *SessionManager) notify(e webhook.Event) {
+	if notify := sm.notifier.Load(); notify != nil {
+		(*notify)(e)
+	}
+}
+
+// Replaces the time source used for session timestamps
+func (sm *SessionManager) SetClock(clock func() time.Time) {
+	sm.clock = clock
+}
+
+func (sm *SessionManager) now() time.Time {
+	if sm.clock != nil {
+		return sm.clock()
+	}
+	return time.Now()
+}
+
+// Returns a copy of the in-memory session state for programs with running processes
+func (sm *SessionManager) Snapshot() []ipc.ActiveSession {
+	sm.Mu.RLock()
+	defer sm.Mu.RUnlock()
+
+	active := []ipc.ActiveSession{}
+	for name, t := range sm.Programs {
+		if t == nil {
+			continue
+		}
+
+		t.mu.Lock()
+		if len(t.PIDs) == 0 {
+			t.mu.Unlock()
+			continue
+		}
+
+		pids := make([]int, 0, len(t.PIDs))
+		for pid := range t.PIDs {
+			pids = append(pids, pid)
+		}
+		sort.Ints(pids)
+
+		active = append(active, ipc.ActiveSession{
+			Name:     name,
+			Category: t.Category,
+			Project:  t.Project,
+			PIDs:     pids,
+			StartAt:  t.StartAt,
+			LastSeen: t.LastSeen,
+		})
+		t.mu.Unlock()
+	}
+
+	sort.Slice(active, func(i, j int) bool { return active[i].Name < active[j].Name })
+
+	return active
+}
+
+// Internal session manager state, served by the diagnostics listener
+type DebugState struct {
+	Programs []ipc.ActiveSession `json:"programs"`        // Every in-memory entry, including programs with no running processes
+	Cached   []string            `json:"cached_programs"` // Tracked programs cached from the database
+	Metrics  ipc.Metrics         `json:"metrics"`
+}
+
+// Returns a copy of the session manager's internal state for diagnostics
+func (sm *SessionManager) DebugState() DebugState {
+	sm.Mu.RLock()
+	defer sm.Mu.RUnlock()
+
+	state := DebugState{Programs: []ipc.ActiveSession{}, Cached: []string{}, Metrics: sm.Metrics.Snapshot()}
+	for name, t := range sm.Programs {
+		if t == nil {
+			continue
+		}
+
+		t.mu.Lock()
+		pids := make([]int, 0, len(t.PIDs))
+		for pid := range t.PIDs {
+			pids = append(pids, pid)
+		}
+		sort.Ints(pids)
+		state.Programs = append(state.Programs, ipc.ActiveSession{
+			Name:     name,
+			Category: t.Category,
+			Project:  t.Project,
+			PIDs:     pids,
+			StartAt:  t.StartAt,
+			LastSeen: t.LastSeen,
+		})
+		t.mu.Unlock()
+	}
+	for name := range sm.catalog {
+		state.Cached = append(state.Cached, name)
+	}
+
+	sort.Slice(state.Programs, func(i, j int) bool { return state.Programs[i].Name < state.Programs[j].Name })
+	sort.Strings(state.Cached)
+
+	return state
+}
+
+// Replaces the cached tracked programs with those read from the database, adding, updating and dropping in-memory
+// sessions to match. Programs the managed policy excludes are left out, as are remote programs, known only from
+// sessions synced from other devices, and categories the policy sets override those stored. Returns the names of
+// programs to monitor
+func (sm *SessionManager) LoadPrograms(programs []database.TrackedProgram) []string {
+	toTrack := make([]string, 0, len(programs))
+	managed := sm.policy.Load()
+
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+
+	sm.catalog = make(map[string]ProgramInfo, len(programs))
+	for _, p := range programs {
+		if p.Remote || managed.Excluded(p.Name) {
+			continue
+		}
+		category := p.Category.String
+		if mapped, ok := managed.Category(p.Name); ok {
+			category = mapped
+		}
+		sm.EnsureProgram(p.Name, category, p.Project.String)
+		if !progname.IsPseudo(p.Name) {
+			toTrack = append(toTrack, p.Name)
+		}
+	}
+
+	for name := range sm.Programs {
+		if _, keep := sm.catalog[name]; !keep {
+			sm.drop(name)
+		}
+	}
+
+	return toTrack
+}
+
+// Reports whether name is a tracked program, without touching the database
+func (sm *SessionManager) IsTracked(name string) bool {
+	sm.Mu.RLock()
+	defer sm.Mu.RUnlock()
+
+	_, ok := sm.catalog[name]
+	return ok
 }
 
-// Make sure map is initialized, add program to map if not already present
+// Returns the names of tracked programs to monitor, sorted. Pseudo-programs have no process and are left out
+func (sm *SessionManager) TrackedNames() []string {
+	sm.Mu.RLock()
+	defer sm.Mu.RUnlock()
+
+	names := make([]string, 0, len(sm.catalog))
+	for name := range sm.catalog {
+		if !progname.IsPseudo(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the in-memory state for name, or nil if it has none
+func (sm *SessionManager) Lookup(name string) *Tracked {
+	sm.Mu.RLock()
+	defer sm.Mu.RUnlock()
+	return sm.Programs[name]
+}
+
+// Make sure map is initialized, add program to map and cache if not already present
 // Caller MUST hold sm.Mu Lock
 func (sm *SessionManager) EnsureProgram(name, category, project string) {
 	if sm.Programs == nil {
 		sm.Programs = make(map[string]*Tracked)
 	}
+	if sm.catalog == nil {
+		sm.catalog = make(map[string]ProgramInfo)
+	}
 
-	name = strings.ToLower(name)
+	name = progname.Normalize(name)
+	sm.catalog[name] = ProgramInfo{Category: category, Project: project}
 	tracked, ok := sm.Programs[name]
 
 	if !ok { // Program not in tracked list?
@@ -43,6 +315,9 @@ func (sm *SessionManager) EnsureProgram(name, category, project string) {
 		return
 	}
 
+	tracked.mu.Lock()
+	defer tracked.mu.Unlock()
+
 	if tracked.Category != category { // Category change?
 		tracked.Category = category
 	}
@@ -52,98 +327,221 @@ func (sm *SessionManager) EnsureProgram(name, category, project string) {
 	}
 }
 
-// If no process is running with given name, will create a new active session in database.
-// If there is already a process running with given name, new PID will be added to active session
-func (sm *SessionManager) CreateSession(ctx context.Context, logger *log.Logger, a repository.ActiveRepository, processName string, pid int) {
-	sm.Mu.Lock()
+// Returns the in-memory state for name, adding it from the program cache if missing. The returned Tracked is locked
+func (sm *SessionManager) lockTracked(name string) *Tracked {
+	for {
+		sm.Mu.RLock()
+		t := sm.Programs[name]
+		sm.Mu.RUnlock()
+
+		if t == nil {
+			sm.Mu.Lock()
+			if t = sm.Programs[name]; t == nil {
+				info := sm.catalog[name]
+				t = &Tracked{Category: info.Category, Project: info.Project, PIDs: make(map[int]struct{})}
+				sm.Programs[name] = t
+			}
+			sm.Mu.Unlock()
+		}
 
-	t := sm.Programs[processName]
+		t.mu.Lock()
+		if !t.removed {
+			return t
+		}
+		t.mu.Unlock() // Dropped between lookup and lock, look again
+	}
+}
+
+// Marks a session write as under way, so Reconcile leaves the program alone. Returns the func ending it
+func (t *Tracked) beginWrite() func() {
+	t.mu.Lock()
+	t.writing++
+	t.mu.Unlock()
+	return t.endWrite
+}
+
+// Ends a session write begun with beginWrite, or by incrementing writing under the lock
+func (t *Tracked) endWrite() {
+	t.mu.Lock()
+	t.writing--
+	t.mu.Unlock()
+}
+
+// Removes name from the Programs map
+// Caller MUST hold sm.Mu Lock
+func (sm *SessionManager) drop(name string) {
+	t := sm.Programs[name]
 	if t == nil {
-		t = &Tracked{PIDs: make(map[int]struct{})}
-		sm.Programs[processName] = t
+		return
 	}
 
+	t.mu.Lock()
+	t.removed = true
+	t.mu.Unlock()
+	delete(sm.Programs, name)
+}
+
+// Removes name from the Programs map, if it still maps to t and no processes were added since its session ended
+func (sm *SessionManager) dropIdle(name string, t *Tracked) {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+
+	if sm.Programs[name] != t {
+		return
+	}
+
+	t.mu.Lock()
+	idle := len(t.PIDs) == 0
+	t.mu.Unlock()
+	if idle {
+		sm.drop(name)
+	}
+}
+
+// If no process is running with given name, will create a new active session in database.
+// If there is already a process running with given name, new PID will be added to active session
+func (sm *SessionManager) CreateSession(ctx context.Context, logger *slog.Logger, a repository.ActiveRepository, processName string, pid int) {
+	logger = logs.Component(logger, logs.ComponentSessions)
+	sm.Metrics.EventsProcessed.Add(1)
+
+	t := sm.lockTracked(processName)
+
 	if _, ok := t.PIDs[pid]; ok {
-		t.LastSeen = time.Now()
-		sm.Mu.Unlock()
-		logger.Printf("INFO: PID %d already tracked for %s", pid, processName)
+		t.LastSeen = sm.now()
+		t.mu.Unlock()
+		logger.Debug("PID already tracked", "program", processName, "pid", pid)
 		return
 	}
 	t.PIDs[pid] = struct{}{}
 
-	now := time.Now()
-	if len(t.PIDs) == 1 {
+	now := sm.now()
+	first := len(t.PIDs) == 1
+	if first {
 		t.StartAt = now
+		t.Continues = ""
+		t.Titles = nil
+		t.Workspaces = nil
+		t.Launch = nil
 	}
 
 	t.LastSeen = now
-	sm.Mu.Unlock()
+	category, project := t.Category, t.Project
+	if first {
+		t.writing++
+		defer t.endWrite()
+	}
+	t.mu.Unlock()
+
+	if first {
+		if id := sm.takeContinuation(processName, now); id != "" {
+			t.mu.Lock()
+			t.Continues = id
+			t.mu.Unlock()
+			logger.Info("Session resumes pre-sleep session", "program", processName, "continuation", id)
+		}
+	}
 
-	if len(t.PIDs) == 1 {
-		params := database.CreateActiveSessionParams{ProgramName: processName, StartTime: now}
+	if first {
+		params := database.CreateActiveSessionParams{ProgramName: processName, StartTime: now.UTC()}
 		if err := a.CreateActiveSession(ctx, params); err != nil {
-			logger.Printf("ERROR: creating active session for %s: %v", processName, err)
+			sm.Metrics.DBErrors.Add(1)
+			logger.Error("Failed to create active session", "program", processName, "error", err)
 			return
 		}
-		logger.Printf("INFO: Created new session for %s at %s", processName, now)
+		sm.Metrics.SessionsCreated.Add(1)
+		logger.Info("Created new session", "program", processName, "start", now)
+		sm.notify(webhook.Event{Kind: webhook.SessionStart, Program: processName, Category: category, Project: project, Start: now})
 	} else {
-		logger.Printf("INFO: Added PID %d to existing session for %s", pid, processName)
+		logger.Info("Added PID to existing session", "program", processName, "pid", pid)
 	}
 }
 
 // Removes PID from sessions map, if there are still processes running with given name, session will not end.
 // If last process for given name ends, the active session is terminated, and session is moved into session history.
-func (sm *SessionManager) EndSession(ctx context.Context, logger *log.Logger, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, processName string, pid int) {
-	sm.Mu.Lock()
+func (sm *SessionManager) EndSession(ctx context.Context, logger *slog.Logger, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, processName string, pid int) {
+	logger = logs.Component(logger, logs.ComponentSessions)
+	sm.Metrics.EventsProcessed.Add(1)
 
-	t, ok := sm.Programs[processName]
-	if !ok {
-		sm.Mu.Unlock()
-		logger.Printf("INFO: No active session for %s (pid %d)", processName, pid)
+	t := sm.Lookup(processName)
+	if t == nil {
+		logger.Debug("No active session", "program", processName, "pid", pid)
 		return
 	}
 
+	t.mu.Lock()
 	if _, ok := t.PIDs[pid]; !ok {
-		sm.Mu.Unlock()
-		logger.Printf("INFO: PID %d not tracked for %s", pid, processName)
+		t.mu.Unlock()
+		logger.Debug("PID not tracked", "program", processName, "pid", pid)
 		return
 	}
 
 	delete(t.PIDs, pid)
+	t.LastSeen = sm.now()
+	last := len(t.PIDs) == 0
+	if last {
+		t.writing++
+	}
+	t.mu.Unlock()
 
-	now := time.Now()
-	t.LastSeen = now
-	sm.Mu.Unlock()
-
-	if len(t.PIDs) == 0 {
-		sm.MoveSessionToHistory(ctx, logger, pr, a, h, processName)
+	if last {
+		sm.MoveSessionToHistory(ctx, logger, pr, a, h, processName, repository.EndReasonExit)
+		t.endWrite()
 		// Remove from Programs map to allow fresh session creation
-		sm.Mu.Lock()
-		delete(sm.Programs, processName)
-		sm.Mu.Unlock()
+		sm.dropIdle(processName, t)
 	}
 }
 
-// Takes an active session and moves it into session history, ending active status
-func (sm *SessionManager) MoveSessionToHistory(ctx context.Context, logger *log.Logger, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, processName string) {
-	startTime, err := a.GetActiveSession(ctx, processName)
+// Takes an active session and moves it into session history, ending active status. The reason is recorded in the
+// session's metadata
+func (sm *SessionManager) MoveSessionToHistory(ctx context.Context, logger *slog.Logger, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, processName, reason string) {
+	sm.moveSessionToHistory(ctx, logger, pr, a, h, processName, reason, sm.now())
+}
+
+// Moves the active session for processName into session history as ending at end. Returns whether the session was
+// recorded in history
+func (sm *SessionManager) moveSessionToHistory(ctx context.Context, logger *slog.Logger, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, processName, reason string, end time.Time) bool {
+	logger = logs.Component(logger, logs.ComponentSessions)
+
+	start, err := sm.sessionStart(ctx, a, processName)
 	if err != nil {
-		logger.Printf("ERROR: Error getting active session from database: %s", err)
-		return
+		sm.Metrics.DBErrors.Add(1)
+		logger.Error("Failed to get active session from database", "program", processName, "error", err)
+		return false
+	}
+	measured, wall := sessionDuration(start, end)
+	startTime, endTime := start.UTC(), end.UTC()
+	duration := int64(measured.Seconds())
+
+	hostname, _ := os.Hostname()
+	meta := repository.SessionMetadata{Source: repository.SourceAuto, Machine: hostname, EndReason: reason, Continuation: sm.continuation(processName, reason), WindowTitles: sm.titles(processName), Workspaces: sm.workspaces(processName), Launch: sm.launch(processName)}
+	if skew := wall - measured; skew > clockSkewTolerance || skew < -clockSkewTolerance {
+		meta.WallSeconds = int64(wall.Seconds())
+		logger.Warn("System clock changed during session, recording measured duration", "program", processName, "measured", measured, "wall", wall)
 	}
-	endTime := time.Now()
-	duration := int64(endTime.Sub(startTime).Seconds())
+	if endTime.Before(startTime) { // Clock stepped back past the start, keep the bounds ordered
+		endTime = startTime.Add(measured)
+	}
+
+	metadata, err := meta.Encode()
+	if err != nil {
+		logger.Warn("Failed to encode session metadata", "program", processName, "error", err)
+	}
+
+	device, _ := sm.device.Load().(string)
 
 	archivedSession := database.AddToSessionHistoryParams{
 		ProgramName:     processName,
 		StartTime:       startTime,
 		EndTime:         endTime,
 		DurationSeconds: duration,
+		Metadata:        metadata,
+		Device:          sql.NullString{String: device, Valid: device != ""},
 	}
 	err = h.AddToSessionHistory(ctx, archivedSession)
 	if err != nil {
-		logger.Printf("ERROR: Error creating session history for %s: %s", processName, err)
-		return
+		sm.Metrics.DBErrors.Add(1)
+		logger.Error("Failed to create session history", "program", processName, "error", err)
+		return false
 	}
 
 	err = pr.UpdateLifetime(ctx, database.UpdateLifetimeParams{
@@ -151,59 +549,290 @@ func (sm *SessionManager) MoveSessionToHistory(ctx context.Context, logger *log.
 		LifetimeSeconds: duration,
 	})
 	if err != nil {
-		logger.Printf("ERROR: Error updating lifetime for %s: %s", processName, err)
+		sm.Metrics.DBErrors.Add(1)
+		logger.Error("Failed to update lifetime", "program", processName, "error", err)
 	}
 
 	err = a.RemoveActiveSession(ctx, processName)
 	if err != nil {
-		logger.Printf("ERROR: Error removing active session for %s: %s", processName, err)
+		sm.Metrics.DBErrors.Add(1)
+		logger.Error("Failed to remove active session", "program", processName, "error", err)
 	}
 
-	logger.Printf("INFO: Moved session for %s to history (duration: %d seconds)", processName, duration)
+	sm.Metrics.SessionsClosed.Add(1)
+	logger.Info("Moved session to history", "program", processName, "duration_seconds", duration, "reason", reason)
+
+	var category, project string
+	if t := sm.Lookup(processName); t != nil {
+		category, project, _ = t.Details()
+	}
+	sm.notify(webhook.Event{
+		Kind:     webhook.SessionEnd,
+		Program:  processName,
+		Category: category,
+		Project:  project,
+		Device:   device,
+		Start:    startTime,
+		End:      endTime,
+		Duration: measured,
+		Reason:   reason,
+	})
+	return true
 }
 
+// Splits sessions running longer than the max session length set by SetMaxSession, moving each full-length part to
+// history and starting the next part where it was cut. Keeps a program that never exits, such as a terminal, from
+// collecting its whole uptime in one session. Does nothing when no cap is set
+func (sm *SessionManager) SplitLongSessions(ctx context.Context, logger *slog.Logger, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
+	limit := time.Duration(sm.maxLen.Load())
+	if limit <= 0 {
+		return
+	}
+	logger = logs.Component(logger, logs.ComponentSessions)
+	now := sm.now()
+
+	sm.Mu.RLock()
+	due := map[string]*Tracked{}
+	for name, t := range sm.Programs {
+		if t == nil {
+			continue
+		}
+		t.mu.Lock()
+		if len(t.PIDs) > 0 && !t.StartAt.IsZero() && now.Sub(t.StartAt) >= limit {
+			due[name] = t
+		}
+		t.mu.Unlock()
+	}
+	sm.Mu.RUnlock()
+
+	for name, t := range due {
+		for ctx.Err() == nil {
+			if !sm.splitSession(ctx, logger, pr, a, h, name, t, now, limit) {
+				break
+			}
+		}
+	}
+}
+
+// Moves the first limit of name's session to history and starts the next part where it was cut, if the session ran
+// past limit by now. Reports whether a part was split off
+func (sm *SessionManager) splitSession(ctx context.Context, logger *slog.Logger, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, name string, t *Tracked, now time.Time, limit time.Duration) bool {
+	defer t.beginWrite()()
+
+	t.mu.Lock()
+	cut := t.StartAt.Add(limit)
+	t.mu.Unlock()
+	if now.Before(cut) {
+		return false
+	}
+
+	if !sm.moveSessionToHistory(ctx, logger, pr, a, h, name, repository.EndReasonSplit, cut) {
+		return false
+	}
+
+	t.mu.Lock()
+	if t.removed || len(t.PIDs) == 0 { // Program exited while its session was being split
+		t.mu.Unlock()
+		return false
+	}
+	t.StartAt = cut
+	t.Continues = ""
+	t.Workspaces = nil
+	t.mu.Unlock()
+
+	if err := a.CreateActiveSession(ctx, database.CreateActiveSessionParams{ProgramName: name, StartTime: cut.UTC()}); err != nil {
+		sm.Metrics.DBErrors.Add(1)
+		logger.Error("Failed to create active session", "program", name, "error", err)
+		return false
+	}
+	sm.Metrics.SessionsCreated.Add(1)
+	logger.Info("Split long session", "program", name, "at", cut, "max_session", limit)
+	return true
+}
+
+// Returns the start time of the active session for processName, from memory when the session was started by this
+// service, keeping its monotonic clock reading, otherwise from the database
+func (sm *SessionManager) sessionStart(ctx context.Context, a repository.ActiveRepository, processName string) (time.Time, error) {
+	var start time.Time
+	if t := sm.Lookup(processName); t != nil {
+		t.mu.Lock()
+		start = t.StartAt
+		t.mu.Unlock()
+	}
+
+	if !start.IsZero() {
+		return start, nil
+	}
+	return a.GetActiveSession(ctx, processName)
+}
+
+// Largest difference between the measured and wall-clock length of a session before it's treated as spanning a
+// clock change, such as an NTP step or a manual adjustment
+const clockSkewTolerance = 30 * time.Second
+
+// Measures a session between start and end. When both carry a monotonic clock reading the measured duration is
+// immune to clock changes; sessions restored from the database only have wall-clock times. Negative durations,
+// from the clock stepping back, are capped at zero
+func sessionDuration(start, end time.Time) (measured, wall time.Duration) {
+	measured = end.Sub(start)
+	wall = end.Round(0).Sub(start.Round(0))
+	if measured < 0 {
+		measured = 0
+	}
+	return measured, wall
+}
+
+// Moves every session with running processes to history and clears it from memory. Sessions not reached before
+// ctx expires are left in active_sessions. Returns the number of sessions flushed and left behind
+func (sm *SessionManager) FlushSessions(ctx context.Context, logger *slog.Logger, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository, reason string) (flushed, remaining int) {
+	sm.Mu.RLock()
+	programs := []string{}
+	tracked := []*Tracked{}
+	for name, t := range sm.Programs {
+		if t == nil {
+			continue
+		}
+		if _, _, running := t.Details(); running {
+			programs = append(programs, name)
+			tracked = append(tracked, t)
+		}
+	}
+	sm.Mu.RUnlock()
+
+	for i, name := range programs {
+		if ctx.Err() != nil {
+			return flushed, len(programs) - i
+		}
+
+		done := tracked[i].beginWrite()
+		sm.MoveSessionToHistory(ctx, logger, pr, a, h, name, reason)
+		done()
+		flushed++
+
+		sm.Mu.Lock()
+		sm.drop(name)
+		sm.Mu.Unlock()
+	}
+
+	return flushed, 0
+}
+
+const (
+	validateWorkers = 4               // Programs whose PIDs are checked concurrently during validation
+	pidCheckTimeout = 5 * time.Second // Longest a single PID check may take before the process is assumed running
+)
+
 // ValidateActiveSessions checks if tracked PIDs are still running and cleans up stale sessions
-// This is called periodically to handle cases where process_stop events are missed
-func (sm *SessionManager) ValidateActiveSessions(ctx context.Context, logger *log.Logger, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
-	sm.Mu.Lock()
-	programsToClean := []string{}
-	gracePeriod := 120 * time.Second // Give 2 minutes grace period before cleaning up
+// This is called periodically to handle cases where process_stop events are missed. Programs are checked concurrently
+// by a bounded pool of workers, so one slow or hung check doesn't hold up the rest
+func (sm *SessionManager) ValidateActiveSessions(ctx context.Context, logger *slog.Logger, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
+	logger = logs.Component(logger, logs.ComponentSessions)
+
+	type candidate struct {
+		program  string
+		tracked  *Tracked
+		pids     []int
+		lastSeen time.Time
+		gone     bool
+	}
 
+	sm.Mu.RLock()
+	candidates := []*candidate{}
 	for programName, tracked := range sm.Programs {
-		if tracked == nil || len(tracked.PIDs) == 0 {
+		if tracked == nil {
 			continue
 		}
 
-		// Check if any PIDs are still running
-		allPIDsGone := true
-		for pid := range tracked.PIDs {
-			if isProcessRunning(pid) {
-				allPIDsGone = false
-				break
+		tracked.mu.Lock()
+		if len(tracked.PIDs) > 0 {
+			c := &candidate{program: programName, tracked: tracked, lastSeen: tracked.LastSeen}
+			for pid := range tracked.PIDs {
+				c.pids = append(c.pids, pid)
 			}
+			candidates = append(candidates, c)
+		}
+		tracked.mu.Unlock()
+	}
+	sm.Mu.RUnlock()
+
+	// Check if any PIDs are still running, outside of lock so process events aren't blocked
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, validateWorkers)
+	for _, c := range candidates {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			c.gone = !anyProcessRunning(ctx, logger, c.program, c.pids)
+		}()
+	}
+	wg.Wait()
+
+	programsToClean := []*candidate{}
+	gracePeriod := 120 * time.Second // Give 2 minutes grace period before cleaning up
+
+	for _, c := range candidates {
+		if !c.gone {
+			continue
+		}
+
+		// Skip programs that saw a process event while being checked
+		c.tracked.mu.Lock()
+		changed := c.tracked.removed || !c.tracked.LastSeen.Equal(c.lastSeen)
+		c.tracked.mu.Unlock()
+		if changed {
+			continue
 		}
 
 		// If all PIDs are gone and grace period has passed, mark for cleanup
-		if allPIDsGone {
-			timeSinceLastSeen := time.Since(tracked.LastSeen)
-			if timeSinceLastSeen > gracePeriod {
-				logger.Printf("INFO: ValidateActiveSessions detected all PIDs gone for %s (last seen %v ago), cleaning up", programName, timeSinceLastSeen)
-				programsToClean = append(programsToClean, programName)
-			} else {
-				logger.Printf("INFO: ValidateActiveSessions detected all PIDs gone for %s, but within grace period (%v/%v)", programName, timeSinceLastSeen, gracePeriod)
-			}
+		timeSinceLastSeen := time.Since(c.lastSeen)
+		if timeSinceLastSeen > gracePeriod {
+			logger.Info("All PIDs gone, cleaning up session", "program", c.program, "last_seen_ago", timeSinceLastSeen)
+			programsToClean = append(programsToClean, c)
+		} else {
+			logger.Debug("All PIDs gone, within grace period", "program", c.program, "last_seen_ago", timeSinceLastSeen, "grace", gracePeriod)
 		}
 	}
-	sm.Mu.Unlock()
 
-	// Process cleanup outside of lock to avoid deadlock
-	for _, programName := range programsToClean {
-		sm.MoveSessionToHistory(ctx, logger, pr, a, h, programName)
+	for _, c := range programsToClean {
+		sm.Metrics.ValidatorCleanups.Add(1)
+		done := c.tracked.beginWrite()
+		sm.MoveSessionToHistory(ctx, logger, pr, a, h, c.program, repository.EndReasonStale)
+		done()
 		// Remove from Programs map to allow fresh session creation
 		sm.Mu.Lock()
-		delete(sm.Programs, programName)
+		if sm.Programs[c.program] == c.tracked {
+			sm.drop(c.program)
+		}
 		sm.Mu.Unlock()
 	}
 }
 
-
+// Reports whether any of pids is still running. A check that takes longer than pidCheckTimeout, or is interrupted by
+// ctx, counts as running so a hung OS call never ends a session
+func anyProcessRunning(ctx context.Context, logger *slog.Logger, program string, pids []int) bool {
+	for _, pid := range pids {
+		if pid == ActivityPID { // Activity sessions are ended by their source going quiet, not by a process
+			return true
+		}
+		result := make(chan bool, 1)
+		go func() { result <- isProcessRunning(pid) }()
+
+		timer := time.NewTimer(pidCheckTimeout)
+		select {
+		case running := <-result:
+			timer.Stop()
+			if running {
+				return true
+			}
+		case <-timer.C:
+			logger.Warn("PID check timed out, assuming process is running", "program", program, "pid", pid, "timeout", pidCheckTimeout)
+			return true
+		case <-ctx.Done():
+			timer.Stop()
+			return true
+		}
+	}
+	return false
+}
@@ -3,7 +3,6 @@
 package sessions
 
 import (
-	"os"
 	"syscall"
 )
 
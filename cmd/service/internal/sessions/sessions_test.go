@@ -0,0 +1,66 @@
+package sessions
+
+import (
+	"log/slog"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitLongSessions(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+	logger := slog.New(slog.DiscardHandler)
+	sm := testManager(t, store, "editor", "browser")
+	sm.SetMaxSession(time.Hour)
+
+	start := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	now := start
+	sm.SetClock(func() time.Time { return now })
+	sm.CreateSession(ctx, logger, store, "editor", 1)
+	now = start.Add(100 * time.Minute)
+	sm.CreateSession(ctx, logger, store, "browser", 2)
+
+	// editor has run for two and a half hours, browser for 50 minutes
+	now = start.Add(150 * time.Minute)
+	sm.SplitLongSessions(ctx, logger, store, store, store)
+
+	history, err := store.GetSessionHistory(ctx, database.GetSessionHistoryParams{ProgramName: "editor", Limit: 10})
+	assert.Nil(t, err)
+	sort.Slice(history, func(i, j int) bool { return history[i].StartTime.Before(history[j].StartTime) })
+	if assert.Len(t, history, 2, "The session should be cut into two full parts") {
+		for i, part := range history {
+			assert.True(t, start.Add(time.Duration(i)*time.Hour).Equal(part.StartTime), "part %d starts at %v", i, part.StartTime)
+			assert.True(t, start.Add(time.Duration(i+1)*time.Hour).Equal(part.EndTime), "part %d ends at %v", i, part.EndTime)
+			assert.Equal(t, int64(3600), part.DurationSeconds)
+			metadata, err := repository.DecodeSessionMetadata(part.Metadata)
+			assert.Nil(t, err)
+			assert.Equal(t, repository.EndReasonSplit, metadata.EndReason)
+		}
+	}
+
+	active, err := store.GetActiveSession(ctx, "editor")
+	assert.Nil(t, err)
+	assert.True(t, start.Add(2*time.Hour).Equal(active), "The running part should start where the last was cut, not %v", active)
+	assert.True(t, start.Add(2*time.Hour).Equal(sm.Lookup("editor").StartAt))
+
+	program, err := store.GetProgramByName(ctx, "editor")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7200), program.LifetimeSeconds)
+
+	count, err := store.GetCountOfSessionsForProgram(ctx, "browser")
+	assert.Nil(t, err)
+	assert.Zero(t, count, "A session under the limit should be left running")
+
+	// A program that exited has nothing left to split
+	sm.EndSession(ctx, logger, store, store, store, "editor", 1)
+	now = start.Add(5 * time.Hour)
+	sm.SplitLongSessions(ctx, logger, store, store, store)
+	count, err = store.GetCountOfSessionsForProgram(ctx, "editor")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+}
@@ -0,0 +1,42 @@
+package sessions
+
+import "time"
+
+// Credits elapsed time on workspace to every running session
+func (sm *SessionManager) CreditWorkspace(workspace string, elapsed time.Duration) {
+	sm.Mu.RLock()
+	defer sm.Mu.RUnlock()
+
+	for _, t := range sm.Programs {
+		if t == nil {
+			continue
+		}
+		t.mu.Lock()
+		if len(t.PIDs) > 0 {
+			if t.Workspaces == nil {
+				t.Workspaces = map[string]time.Duration{}
+			}
+			t.Workspaces[workspace] += elapsed
+		}
+		t.mu.Unlock()
+	}
+}
+
+// Returns the seconds name's session spent on each workspace, nil when none were recorded
+func (sm *SessionManager) workspaces(name string) map[string]int64 {
+	t := sm.Lookup(name)
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.Workspaces) == 0 {
+		return nil
+	}
+	seconds := make(map[string]int64, len(t.Workspaces))
+	for workspace, spent := range t.Workspaces {
+		seconds[workspace] = int64(spent.Seconds())
+	}
+	return seconds
+}
@@ -0,0 +1,67 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// Backoff bounds between restarts. A task that ran for at least resetAfter restarts with the initial backoff
+const (
+	initialBackoff = time.Second
+	maxBackoff     = time.Minute
+	resetAfter     = 5 * time.Minute
+)
+
+// Runs fn in a new goroutine, restarting it with exponential backoff when it returns an error or panics, until
+// ctx is cancelled. A nil return with ctx still live means the task finished on purpose, and isn't restarted.
+// Each restart is counted in restarts, if given
+func Go(ctx context.Context, logger *slog.Logger, restarts *atomic.Int64, name string, fn func(ctx context.Context) error) {
+	go func() {
+		backoff := initialBackoff
+
+		for {
+			started := time.Now()
+			err := run(ctx, fn)
+
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				logger.Debug("Task finished", "task", name)
+				return
+			}
+
+			if time.Since(started) >= resetAfter {
+				backoff = initialBackoff
+			}
+
+			logger.Error("Task failed, restarting", "task", name, "error", err, "backoff", backoff)
+			if restarts != nil {
+				restarts.Add(1)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff = min(backoff*2, maxBackoff)
+		}
+	}()
+}
+
+// Calls fn, converting a panic into an error carrying its stack
+func run(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	return fn(ctx)
+}
@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Sessions of pseudo-programs fed by reports from one source, such as the browser extension. At most one is open:
+// reporting another program ends the current one's session, as does no report within the idle timeout
+type activityFeed struct {
+	mu         sync.Mutex
+	current    string    // Pseudo-program with an open session, empty when none
+	lastReport time.Time // When current was last reported
+	idle       time.Duration
+	logger     *slog.Logger
+	s          *sessions.SessionManager
+	pr         repository.ProgramRepository
+	a          repository.ActiveRepository
+	h          repository.HistoryRepository
+}
+
+// Records activity for name at now, adding it as a tracked program with category and project when first seen and
+// ending the previous program's session if it differs. A non-empty title is kept in the session's metadata. Programs
+// the managed policy excludes are ignored, and a category it sets overrides the reported one
+func (f *activityFeed) report(ctx context.Context, name, category, project, title string, now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if name != f.current {
+		f.end(ctx, repository.EndReasonExit, now)
+	}
+
+	managed := f.s.Policy()
+	if managed.Excluded(name) {
+		return nil
+	}
+
+	if !f.s.IsTracked(name) {
+		if mapped, ok := managed.Category(name); ok {
+			category = mapped
+		}
+		params := database.AddProgramParams{Name: name, Category: database.NullString(category), Project: database.NullString(project)}
+		if err := f.pr.AddProgram(ctx, params); err != nil {
+			f.s.Metrics.DBErrors.Add(1)
+			return fmt.Errorf("error adding program %s: %w", name, err)
+		}
+		f.s.Mu.Lock()
+		f.s.EnsureProgram(name, category, project)
+		f.s.Mu.Unlock()
+		f.logger.Info("Tracking new activity program", "program", name, "category", category, "project", project)
+	}
+
+	f.s.Activity(ctx, f.logger, f.a, name, title)
+	f.current = name
+	f.lastReport = now
+	return nil
+}
+
+// Ends the current session as of at, if one is open
+func (f *activityFeed) stop(ctx context.Context, reason string, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.end(ctx, reason, at)
+}
+
+// Caller MUST hold f.mu
+func (f *activityFeed) end(ctx context.Context, reason string, at time.Time) {
+	if f.current != "" {
+		f.s.EndActivity(ctx, f.logger, f.pr, f.a, f.h, f.current, reason, at)
+		f.current = ""
+	}
+}
+
+// Ends the current session as of its last report once no report arrives within the idle timeout, until ctx is done
+func (f *activityFeed) watchIdle(ctx context.Context) {
+	ticker := time.NewTicker(min(f.idle/2, 30*time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.mu.Lock()
+			if f.current != "" && time.Since(f.lastReport) > f.idle {
+				f.logger.Debug("No report within idle timeout, ending session", "program", f.current, "idle_timeout", f.idle)
+				f.end(ctx, repository.EndReasonStale, f.lastReport)
+			}
+			f.mu.Unlock()
+		}
+	}
+}
+
+// Serves handler over HTTP on addr until ctx is done. name identifies the listener in logs and errors
+func serveHTTP(ctx context.Context, logger *slog.Logger, name, addr string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("error opening %s listener on %s: %w", name, addr, err)
+	}
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	stop := context.AfterFunc(ctx, func() { server.Close() })
+	defer stop()
+
+	logger.Info("Serving "+name, "addr", listener.Addr().String())
+
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s listener closed: %w", name, err)
+	}
+	if ctx.Err() != nil {
+		logger.Info("Closing " + name + " listener")
+	}
+	return nil
+}
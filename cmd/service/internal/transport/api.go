@@ -0,0 +1,47 @@
+package transport
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/events"
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
+	"github.com/jms-guy/timekeep/internal/api"
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Serves tracking data over REST and GraphQL on a loopback address when api is enabled in config. Requests must send
+// an API token with read or admin scope as a bearer token, apart from the GraphQL schema and OpenAPI document which
+// are public
+func (t *Transporter) ListenAPI(ctx context.Context, logger *slog.Logger, eventCtrl *events.EventController, store repository.Store) error {
+	logger = logs.Component(logger, logs.ComponentTransport)
+
+	cfg := eventCtrl.Config()
+	if !cfg.API.Enabled {
+		return nil
+	}
+	addr := cfg.APIListen()
+	if !config.IsLoopbackAddr(addr) {
+		logger.Error("api.listen must be a loopback address, not listening", "addr", addr)
+		return nil
+	}
+
+	src := &api.Source{Store: store, Config: eventCtrl.Config}
+	handler, err := api.NewHandler(src, func(r *http.Request) bool {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			return false
+		}
+		scope := eventCtrl.TokenScope(r.Context(), logger, token)
+		return scope == ipc.ScopeRead || scope == ipc.ScopeAdmin
+	})
+	if err != nil {
+		return err
+	}
+
+	return serveHTTP(ctx, logger, "data API", addr, handler)
+}
@@ -0,0 +1,142 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/events"
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/progname"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Active tab report posted by the browser extension
+type browserReport struct {
+	Domain string `json:"domain"`          // Host or URL of the active tab, empty when the browser lost focus
+	Title  string `json:"title,omitempty"` // Title of the active tab
+}
+
+// Origins of browser extensions, the only pages allowed to call the listener from a browser
+var extensionSchemes = []string{"chrome-extension://", "moz-extension://", "safari-web-extension://"}
+
+// Accepts active tab reports from the browser extension on a loopback address when browser is enabled in config,
+// recording time per site as "web:<domain>" pseudo-programs. A site's session ends when another site is reported,
+// the browser loses focus, or no report arrives within the idle timeout
+func (t *Transporter) ListenBrowser(ctx context.Context, logger *slog.Logger, eventCtrl *events.EventController, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) error {
+	logger = logs.Component(logger, logs.ComponentTransport)
+
+	cfg := eventCtrl.Config()
+	if !cfg.Browser.Enabled {
+		return nil
+	}
+	if cfg.Browser.Token == "" {
+		logger.Error("Browser activity enabled without browser.token set, not listening")
+		return nil
+	}
+	addr := cfg.BrowserListen()
+	if !config.IsLoopbackAddr(addr) {
+		logger.Error("browser.listen must be a loopback address, not listening", "addr", addr)
+		return nil
+	}
+
+	feed := &activityFeed{idle: cfg.BrowserIdle(), logger: logger, s: s, pr: pr, a: a, h: h}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/browser/activity", func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); allowedOrigin(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Allow-Methods", "POST")
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// Read on each report, so a reload changes the token and categories without restarting the listener
+		current := eventCtrl.Config().Browser
+		if !current.Enabled {
+			http.Error(w, "browser activity disabled", http.StatusServiceUnavailable)
+			return
+		}
+		if token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); !ipc.ValidToken(current.Token, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var report browserReport
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&report); err != nil {
+			http.Error(w, "invalid report", http.StatusBadRequest)
+			return
+		}
+		domain, err := siteDomain(report.Domain)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+		if eventCtrl.Paused() || eventCtrl.Asleep() || eventCtrl.Incognito() {
+			return
+		}
+
+		now := time.Now()
+		if domain == "" {
+			feed.stop(ctx, repository.EndReasonExit, now)
+			return
+		}
+		if err := feed.report(ctx, progname.WebPrefix+domain, current.Categories[domain], "", report.Title, now); err != nil {
+			logger.Error("Failed to record browser activity", "error", err)
+		}
+	})
+
+	go feed.watchIdle(ctx)
+
+	return serveHTTP(ctx, logger, "browser activity", addr, mux)
+}
+
+// Reports whether origin belongs to a browser extension
+func allowedOrigin(origin string) bool {
+	for _, scheme := range extensionSchemes {
+		if strings.HasPrefix(origin, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the site domain for a reported host or URL, lowercased and without port or "www.". Empty stays empty
+func siteDomain(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if strings.Contains(value, "://") {
+		u, err := url.Parse(value)
+		if err != nil || u.Hostname() == "" {
+			return "", fmt.Errorf("invalid domain %q", value)
+		}
+		value = u.Hostname()
+	} else if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+
+	domain := strings.TrimPrefix(strings.ToLower(value), "www.")
+	if domain == "" || strings.ContainsAny(domain, `/\ :`) {
+		return "", fmt.Errorf("invalid domain %q", value)
+	}
+	return domain, nil
+}
@@ -0,0 +1,82 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/events"
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/ipc"
+)
+
+// Serves pprof profiles, goroutine dumps and session manager internals over HTTP when debug.listen is set in config.
+// Requests must send the service's IPC token as a bearer token, and only loopback addresses are accepted
+func (t *Transporter) ListenDebug(ctx context.Context, logger *slog.Logger, eventCtrl *events.EventController, s *sessions.SessionManager) error {
+	logger = logs.Component(logger, logs.ComponentTransport)
+
+	addr := eventCtrl.Config().Debug.Listen
+	if addr == "" {
+		return nil
+	}
+	if !config.IsLoopbackAddr(addr) {
+		logger.Error("debug.listen must be a loopback address, not listening", "addr", addr)
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/goroutines", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/debug/pprof/goroutine?debug=2", http.StatusFound)
+	})
+	mux.HandleFunc("/debug/sessions", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(s.DebugState()); err != nil {
+			logger.Error("Failed to write session state", "error", err)
+		}
+	})
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("error opening debug listener on %s: %w", addr, err)
+	}
+
+	// Only those able to read the token file, as the CLI does, may see the service's internals
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ipc.ValidToken(eventCtrl.AuthToken, token) {
+			http.Error(w, "invalid or missing service token", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	stop := context.AfterFunc(ctx, func() { server.Close() })
+	defer stop()
+
+	logger.Info("Serving diagnostics", "addr", listener.Addr().String())
+
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("debug listener closed: %w", err)
+	}
+	if ctx.Err() != nil {
+		logger.Info("Closing debug listener")
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package transport
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/events"
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/progname"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Heartbeat sent by WakaTime editor plugins, holding the fields Timekeep uses. Unknown fields are ignored
+type heartbeat struct {
+	Entity   string  `json:"entity"`             // File path, app name or domain being worked on
+	Type     string  `json:"type"`               // file, app or domain
+	Category string  `json:"category,omitempty"` // coding, debugging, building, etc.
+	Time     float64 `json:"time"`               // Unix time in seconds, with fractions
+	Project  string  `json:"project,omitempty"`  // Project the entity belongs to, detected by the plugin
+	Language string  `json:"language,omitempty"`
+	Branch   string  `json:"branch,omitempty"`
+	IsWrite  bool    `json:"is_write,omitempty"`
+}
+
+// Project recorded for heartbeats whose plugin could not detect one
+const unknownProject = "unknown"
+
+// Category given to a project's program when its first heartbeat names none
+const defaultEditorCategory = "coding"
+
+// Accepts heartbeats from WakaTime editor plugins on a loopback address when editor is enabled in config, recording
+// time per project as "code:<project>" pseudo-programs with the files worked on kept in session metadata. Plugins
+// point their api_url at http://<listen>/api/v1. A project's session ends when a heartbeat for another project
+// arrives or none arrives within the idle timeout
+func (t *Transporter) ListenEditor(ctx context.Context, logger *slog.Logger, eventCtrl *events.EventController, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) error {
+	logger = logs.Component(logger, logs.ComponentTransport)
+
+	cfg := eventCtrl.Config()
+	if !cfg.Editor.Enabled {
+		return nil
+	}
+	if cfg.Editor.APIKey == "" {
+		logger.Error("Editor heartbeats enabled without editor.api_key set, not listening")
+		return nil
+	}
+	addr := cfg.EditorListen()
+	if !config.IsLoopbackAddr(addr) {
+		logger.Error("editor.listen must be a loopback address, not listening", "addr", addr)
+		return nil
+	}
+
+	feed := &activityFeed{idle: cfg.EditorIdle(), logger: logger, s: s, pr: pr, a: a, h: h}
+
+	record := func(beats []heartbeat) {
+		if eventCtrl.Paused() || eventCtrl.Asleep() || eventCtrl.Incognito() {
+			return
+		}
+
+		// Plugins queue heartbeats while the receiver is unreachable and send them later. Only those recent enough
+		// to still be within the idle timeout say anything about current activity
+		now := time.Now()
+		slices.SortStableFunc(beats, func(x, y heartbeat) int { return cmp.Compare(x.Time, y.Time) })
+		for _, beat := range beats {
+			at := time.Unix(0, int64(beat.Time*float64(time.Second)))
+			if now.Sub(at) > feed.idle {
+				continue
+			}
+
+			project := strings.TrimSpace(beat.Project)
+			if project == "" {
+				project = unknownProject
+			}
+			category := beat.Category
+			if category == "" {
+				category = defaultEditorCategory
+			}
+
+			name := progname.CodePrefix + strings.ToLower(project)
+			if err := feed.report(ctx, name, category, project, entityTitle(beat), now); err != nil {
+				logger.Error("Failed to record editor activity", "error", err)
+				return
+			}
+		}
+	}
+
+	handle := func(bulk bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			// Read on each request, so a reload changes the key without restarting the listener
+			current := eventCtrl.Config().Editor
+			if !current.Enabled {
+				http.Error(w, "editor heartbeats disabled", http.StatusServiceUnavailable)
+				return
+			}
+			if !ipc.ValidToken(current.APIKey, apiKey(r)) {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			body := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+			var beats []heartbeat
+			if bulk {
+				if err := body.Decode(&beats); err != nil {
+					http.Error(w, "invalid heartbeats", http.StatusBadRequest)
+					return
+				}
+			} else {
+				var beat heartbeat
+				if err := body.Decode(&beat); err != nil {
+					http.Error(w, "invalid heartbeat", http.StatusBadRequest)
+					return
+				}
+				beats = append(beats, beat)
+			}
+
+			// Answer the way the WakaTime API does, so plugins drop the heartbeats from their offline queue
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			if bulk {
+				responses := make([][2]any, 0, len(beats))
+				for _, beat := range beats {
+					responses = append(responses, [2]any{map[string]heartbeat{"data": beat}, http.StatusCreated})
+				}
+				json.NewEncoder(w).Encode(map[string]any{"responses": responses})
+			} else {
+				json.NewEncoder(w).Encode(map[string]heartbeat{"data": beats[0]})
+			}
+
+			record(beats)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/users/current/heartbeats", handle(false))
+	mux.HandleFunc("/api/v1/users/current/heartbeats.bulk", handle(true))
+
+	go feed.watchIdle(ctx)
+
+	return serveHTTP(ctx, logger, "editor heartbeats", addr, mux)
+}
+
+// Returns the API key a plugin sent: as WakaTime's basic auth of the base64-encoded key, a bearer token or the
+// api_key query parameter
+func apiKey(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if key, ok := strings.CutPrefix(auth, "Bearer "); ok {
+		return key
+	}
+	if encoded, ok := strings.CutPrefix(auth, "Basic "); ok {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return ""
+		}
+		return string(decoded)
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+// Returns what a heartbeat was about for the session's metadata: the file name for files, the entity otherwise
+func entityTitle(beat heartbeat) string {
+	entity := strings.TrimSpace(beat.Entity)
+	if beat.Type == "file" || beat.Type == "" {
+		if i := strings.LastIndexAny(entity, `/\`); i >= 0 {
+			entity = entity[i+1:]
+		}
+	}
+	return entity
+}
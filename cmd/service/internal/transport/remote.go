@@ -0,0 +1,171 @@
+package transport
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/events"
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Remote connections served at once. Further peers are refused until one closes
+const maxRemoteConnections = 16
+
+// Opens a TLS listener for remote CLI connections when enabled in config. Remote clients authenticate with
+// the configured remote token. Returns nil when remote access is disabled or misconfigured
+func (t *Transporter) ListenRemote(ctx context.Context, logger *slog.Logger, eventCtrl *events.EventController, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) error {
+	logger = logs.Component(logger, logs.ComponentTransport)
+
+	cfg := eventCtrl.Config().Remote
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Token == "" {
+		logger.Error("Remote access enabled without remote.token set, not listening")
+		return nil
+	}
+
+	cert, err := remoteCertificate(cfg)
+	if err != nil {
+		logger.Error("Failed to load remote TLS certificate", "error", err)
+		return nil
+	}
+
+	addr := cfg.Listen
+	if addr == "" {
+		addr = ":" + ipc.DefaultRemotePort
+	}
+
+	listener, err := tls.Listen("tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		return fmt.Errorf("error opening remote listener on %s: %w", addr, err)
+	}
+
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	logger.Info("Listening for remote connections", "addr", addr)
+
+	slots := make(chan struct{}, maxRemoteConnections)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				logger.Info("Closing remote listener")
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return fmt.Errorf("remote listener closed: %w", err)
+			}
+			logger.Error("Failed to accept remote connection", "error", err)
+			continue
+		}
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			logger.Debug("Refused remote connection, too many open", "remote", conn.RemoteAddr().String())
+			conn.Close()
+			continue
+		}
+		logger.Debug("Accepted remote connection", "remote", conn.RemoteAddr().String())
+
+		go func() {
+			defer func() { <-slots }()
+			eventCtrl.HandleRemoteConnection(ctx, logger, s, pr, a, h, conn)
+		}()
+	}
+}
+
+// Loads the configured certificate, or a self-signed one kept beside the config file, generating it on first use
+func remoteCertificate(cfg config.RemoteConfig) (tls.Certificate, error) {
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		return tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	}
+
+	configPath, err := config.Path()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	certFile := filepath.Join(filepath.Dir(configPath), "remote-cert.pem")
+	keyFile := filepath.Join(filepath.Dir(configPath), "remote-key.pem")
+
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		return cert, nil
+	}
+
+	if err := generateSelfSigned(certFile, keyFile); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
+
+// Writes a new self-signed certificate for this host. Clients trust it by passing a copy of the certificate with --ca
+func generateSelfSigned(certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("failed to generate serial: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "timekeep " + hostname},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+	}
+	if hostname != "" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal key: %w", err)
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return fmt.Errorf("failed to write certificate: %w", err)
+	}
+
+	return nil
+}
@@ -4,53 +4,74 @@ package transport
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
 	"net"
 	"os"
 
 	"github.com/jms-guy/timekeep/cmd/service/internal/events"
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
 	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/ipc"
 	"github.com/jms-guy/timekeep/internal/repository"
 )
 
-func (t *Transporter) Listen(ctx context.Context, logger *log.Logger, eventCtrl *events.EventController, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
-	socketDir := "/var/run/timekeep"
-	socketName := socketDir + "/timekeep.sock"
+// Listens on the user's unix socket until ctx is cancelled, handing connections from this user or root to the
+// event controller
+func (t *Transporter) Listen(ctx context.Context, logger *slog.Logger, eventCtrl *events.EventController, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) error {
+	logger = logs.Component(logger, logs.ComponentTransport)
 
-	if err := os.MkdirAll(socketDir, 0o755); err != nil {
-		logger.Printf("ERROR: Failed to create socket directory: %v", err)
-		return
+	socketName := ipc.SocketPath()
+
+	if err := ipc.PrepareUserDir(); err != nil {
+		return fmt.Errorf("error creating socket directory: %w", err)
 	}
 
-	os.Remove(socketName)
+	if err := os.Remove(socketName); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error removing stale socket: %w", err)
+	}
 
 	listener, err := net.Listen("unix", socketName)
 	if err != nil {
-		logger.Printf("ERROR: Failed to open socket connection")
-		return
+		return fmt.Errorf("error opening socket: %w", err)
 	}
 
-	if err := os.Chmod(socketName, 0o666); err != nil {
-		logger.Printf("WARNING: Could not set socket permissions: %v", err)
+	// Owner-only, other users are also refused by the peer credential check below
+	if err := os.Chmod(socketName, 0o600); err != nil {
+		logger.Warn("Could not set socket permissions", "error", err)
 	}
 
 	defer os.Remove(socketName)
 	defer listener.Close()
 
-	logger.Printf("INFO: Listening on Unix socket: %s", socketName)
+	stop := context.AfterFunc(ctx, func() { listener.Close() }) // Unblock Accept on shutdown
+	defer stop()
+
+	logger.Info("Listening on Unix socket", "path", socketName)
 
 	for {
-		select {
-		case <-ctx.Done():
-			logger.Println("INFO: Closing socket connection")
-			return
-		default:
-			conn, err := listener.Accept()
-			if err != nil {
-				logger.Printf("ERROR: Failed to accept connection: %s", err)
-				continue
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				logger.Info("Closing socket connection")
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return fmt.Errorf("socket listener closed: %w", err)
 			}
-			go eventCtrl.HandleConnection(ctx, logger, s, pr, a, h, conn)
+			logger.Error("Failed to accept connection", "error", err)
+			continue
 		}
+
+		uid, err := ipc.PeerUID(conn)
+		if err != nil || (uid != os.Getuid() && uid != 0) {
+			logger.Warn("Refused connection from another user", "uid", uid, "error", err)
+			conn.Close()
+			continue
+		}
+
+		go eventCtrl.HandleConnection(ctx, logger, s, pr, a, h, conn)
 	}
 }
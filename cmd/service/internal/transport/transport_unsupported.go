@@ -4,13 +4,13 @@ package transport
 
 import (
 	"context"
-	"log"
+	"log/slog"
 
 	"github.com/jms-guy/timekeep/cmd/service/internal/events"
 	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
 	"github.com/jms-guy/timekeep/internal/repository"
 )
 
-func (t *Transporter) Listen(ctx context.Context, logger *log.Logger, eventCtrl *events.EventController, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
-	return
+func (t *Transporter) Listen(ctx context.Context, logger *slog.Logger, eventCtrl *events.EventController, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) error {
+	return nil
 }
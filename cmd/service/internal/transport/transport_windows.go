@@ -4,37 +4,46 @@ package transport
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 
 	"github.com/Microsoft/go-winio"
 	"github.com/jms-guy/timekeep/cmd/service/internal/events"
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
 	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/ipc"
 	"github.com/jms-guy/timekeep/internal/repository"
 )
 
-// Opens a Windows named pipe connection, to listen for commands
-func (t *Transporter) Listen(ctx context.Context, logger *log.Logger, eventCtrl *events.EventController, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) {
-	pipeName := "\\\\.\\pipe\\Timekeep"
+// Opens a Windows named pipe connection, to listen for commands until ctx is cancelled
+func (t *Transporter) Listen(ctx context.Context, logger *slog.Logger, eventCtrl *events.EventController, s *sessions.SessionManager, pr repository.ProgramRepository, a repository.ActiveRepository, h repository.HistoryRepository) error {
+	logger = logs.Component(logger, logs.ComponentTransport)
 
-	pipe, err := winio.ListenPipe(pipeName, nil)
+	pipeName := ipc.PipeName()
+
+	pipe, err := winio.ListenPipe(pipeName, &winio.PipeConfig{SecurityDescriptor: ipc.PipeSecurityDescriptor()})
 	if err != nil {
-		logger.Printf("ERROR: Failed to create pipe: %s", err)
-		return
+		return fmt.Errorf("error creating pipe: %w", err)
 	}
 	defer pipe.Close()
 
+	stop := context.AfterFunc(ctx, func() { pipe.Close() }) // Unblock Accept on shutdown
+	defer stop()
+
 	for {
-		select {
-		case <-ctx.Done():
-			logger.Println("INFO: Stopping pipe listener")
-			return
-		default:
-			conn, err := pipe.Accept()
-			if err != nil {
-				logger.Printf("ERROR: Failed to accept connection: %s", err)
-				continue
+		conn, err := pipe.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				logger.Info("Stopping pipe listener")
+				return nil
+			}
+			if errors.Is(err, winio.ErrPipeListenerClosed) {
+				return fmt.Errorf("pipe listener closed: %w", err)
 			}
-			go eventCtrl.HandleConnection(ctx, logger, s, pr, a, h, conn)
+			logger.Error("Failed to accept connection", "error", err)
+			continue
 		}
+		go eventCtrl.HandleConnection(ctx, logger, s, pr, a, h, conn)
 	}
 }
@@ -3,16 +3,35 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
 
+	"github.com/jms-guy/timekeep/internal/profile"
 	_ "modernc.org/sqlite"
 )
 
 // Service entry point
 func main() {
 	debug := flag.Bool("debug", false, "Set debug mode")
+	simulate := flag.String("simulate", "", "Replay process events from a JSON file against an in-memory database and exit")
+	profileName := flag.String("profile", os.Getenv(profile.Env), "Named profile, with its own database, config and IPC endpoint")
+	userAgent := flag.Bool("user", false, "Windows: run as a per-user agent in the login session instead of a system service")
+	owner := flag.String("owner", "", "Windows: SID of the user the system service serves, set on install")
 
 	flag.Parse()
 
+	if err := profile.Set(*profileName); err != nil {
+		log.Fatalln(err)
+	}
+	profile.SetUserScope(*userAgent)
+	profile.SetOwner(*owner)
+
+	if *simulate != "" {
+		if err := RunSimulation(*simulate, os.Stdout, *debug); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
 	// OS specific RunService function
 	err := RunService("Timekeep", debug)
 	if err != nil {
@@ -4,23 +4,38 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
+
+	"github.com/jms-guy/timekeep/internal/profile"
 )
 
 // Linux specific service management functions
 
 func RunService(name string, isDebug *bool) error {
-	service, err := ServiceSetup()
+	args := flag.Args()
+	if len(args) > 0 && args[0] == "run" { // Foreground run, accepting --debug after the subcommand
+		runFlags := flag.NewFlagSet("run", flag.ExitOnError)
+		runFlags.BoolVar(isDebug, "debug", *isDebug, "Log every process considered and every matching decision")
+		profileName := runFlags.String("profile", profile.Name(), "Named profile, with its own database, config and IPC endpoint")
+		if err := runFlags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if err := profile.Set(*profileName); err != nil {
+			return err
+		}
+	}
+
+	service, err := ServiceSetup(*isDebug)
 	if err != nil {
 		return err
 	}
 	status, err := service.Manage()
 	if err != nil {
-		service.logger.Logger.Printf("%s: %v", status, err)
+		service.logger.Logger.Error(status, "error", err)
 		return err
 	}
 
@@ -32,11 +47,11 @@ func RunService(name string, isDebug *bool) error {
 func (s *timekeepService) Manage() (string, error) {
 	logger := s.logger.Logger
 
-	logger.Println("INFO: Starting Manage function")
-	usage := "Usage: timekeep install | remove | start | stop | status"
+	logger.Info("Starting Manage function")
+	usage := "Usage: timekeepd [run [--debug]] | install | remove | start | stop | status"
 
-	if len(os.Args) > 1 {
-		command := os.Args[1]
+	if args := flag.Args(); len(args) > 0 && args[0] != "run" {
+		command := args[0]
 		switch command {
 		case "install":
 			return s.daemon.Install()
@@ -53,61 +68,40 @@ func (s *timekeepService) Manage() (string, error) {
 		}
 	}
 
-	serviceCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	serviceCtx, shutdown := context.WithCancel(signalCtx)
+	defer shutdown()
+	s.eventCtrl.Shutdown = shutdown
+
+	s.eventCtrl.RestoreSnooze(serviceCtx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+	s.eventCtrl.RestoreIncognito(serviceCtx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+	s.eventCtrl.CheckProfile(s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+	paused := s.eventCtrl.Paused() || s.eventCtrl.Incognito()
+
 	programs, err := s.prRepo.GetAllPrograms(context.Background())
 	if err != nil {
 		return "ERROR: Failed to get programs", err
 	}
 	if len(programs) > 0 {
-		toTrack := []string{}
-		for _, program := range programs {
-			category := ""
-			if program.Category.Valid {
-				category = program.Category.String
-			}
-			project := ""
-			if program.Project.Valid {
-				project = program.Project.String
-			}
-			s.sessions.EnsureProgram(program.Name, category, project)
-
-			toTrack = append(toTrack, program.Name)
-		}
+		toTrack := s.sessions.LoadPrograms(programs)
 
-		s.eventCtrl.StartMonitor(serviceCtx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo, toTrack)
+		if !paused {
+			s.eventCtrl.StartMonitor(serviceCtx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo, toTrack)
+		}
 	}
 
-	if s.eventCtrl.Config.WakaTime.Enabled || s.eventCtrl.Config.Wakapi.Enabled {
+	if cfg := s.eventCtrl.Config(); !paused && (cfg.WakaTime.Enabled || cfg.Wakapi.Enabled) {
 		s.eventCtrl.StartHeartbeats(serviceCtx, s.logger.Logger, s.sessions)
 	}
 
-	go s.transport.Listen(serviceCtx, s.logger.Logger, s.eventCtrl, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
-
-	// Start periodic validation of active sessions to clean up stale entries
-	go s.startSessionValidator(serviceCtx)
+	s.superviseTasks(serviceCtx)
 
 	<-serviceCtx.Done()
 
-	s.logger.Logger.Println("INFO: Received shutdown signal")
+	s.logger.Logger.Info("Received shutdown signal")
 	s.closeService(s.logger.Logger)
 
 	return "INFO: Daemon stopped.", nil
 }
-
-// Periodically validates active sessions and cleans up stale entries where processes no longer exist
-func (s *timekeepService) startSessionValidator(ctx context.Context) {
-	ticker := time.NewTicker(60 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			s.logger.Logger.Println("INFO: Session validator stopped")
-			return
-		case <-ticker.C:
-			s.sessions.ValidateActiveSessions(ctx, s.logger.Logger, s.prRepo, s.asRepo, s.hsRepo)
-		}
-	}
-}
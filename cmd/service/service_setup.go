@@ -2,15 +2,30 @@ package main
 
 import (
 	"context"
-	"log"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
 
 	"github.com/jms-guy/timekeep/cmd/service/internal/daemons"
+	"github.com/jms-guy/timekeep/cmd/service/internal/desktop"
 	"github.com/jms-guy/timekeep/cmd/service/internal/events"
 	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
 	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/cmd/service/internal/supervisor"
 	"github.com/jms-guy/timekeep/cmd/service/internal/transport"
 	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/digest"
+	"github.com/jms-guy/timekeep/internal/harvest"
+	"github.com/jms-guy/timekeep/internal/ipc"
+	"github.com/jms-guy/timekeep/internal/kimai"
+	"github.com/jms-guy/timekeep/internal/policy"
+	"github.com/jms-guy/timekeep/internal/privacy"
 	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/jms-guy/timekeep/internal/syncapi"
+	"github.com/jms-guy/timekeep/internal/team"
+	"github.com/jms-guy/timekeep/internal/webhook"
 	mysql "github.com/jms-guy/timekeep/sql"
 )
 
@@ -19,6 +34,8 @@ type timekeepService struct {
 	prRepo    repository.ProgramRepository // Repository for tracked_programs database queries
 	asRepo    repository.ActiveRepository  // Repository for active_sessions database queries
 	hsRepo    repository.HistoryRepository // Repository for session_history database queries
+	txRepo    repository.TxRepository      // Transactions over every repository, used by sync
+	reports   repository.TxStore           // Every repository with program names readable, used by the weekly digest, data API, Kimai and Harvest
 	logger    *logs.Logs                   // Handles logging operations
 	eventCtrl *events.EventController      // Managing struct of OS-specific process monitoring functions & handling transport connection events
 	sessions  *sessions.SessionManager     // Managing struct for program sessions
@@ -26,18 +43,50 @@ type timekeepService struct {
 	daemon    daemons.DaemonManager        // Embedded daemon.Daemon struct wrapped by interface
 }
 
-func ServiceSetup() (*timekeepService, error) {
-	logger, err := logs.NewLogs()
+// Builds the service from config and the local database. Debug runs log verbose text output and trace process matching
+func ServiceSetup(debug bool) (*timekeepService, error) {
+	cfg, err := config.Load()
 	if err != nil {
 		return nil, err
 	}
 
-	db, err := mysql.OpenLocalDatabase()
+	if debug {
+		cfg.Log = config.LogConfig{Level: "debug", Format: "text"}
+	}
+
+	logger, err := logs.NewLogs(cfg.Log)
+	if err != nil {
+		return nil, err
+	}
+	events.LogConfigProblems(logs.Component(logger.Logger, logs.ComponentConfig), cfg)
+
+	db, recovery, upgrade, err := mysql.OpenLocalDatabaseRecovering(cfg.DeviceName())
 	if err != nil {
 		return nil, err
 	}
+	if recovery != nil {
+		logger.Logger.Error("DATABASE CORRUPTION DETECTED: readable data was salvaged into a new database, its totals recounted and the corrupt file quarantined, some history may be lost",
+			"problem", recovery.Problem, "quarantined", recovery.Quarantined, "salvaged", recovery.Salvaged, "unreadable", recovery.Failed)
+	}
+	if upgrade != nil {
+		logger.Logger.Info("Upgraded database schema", "from", upgrade.From, "to", upgrade.To)
+	}
+	if up := cfg.Upgraded(); up != nil {
+		logger.Logger.Info("Upgraded config file layout", "from", up.From, "to", up.To, "backup", up.Backup)
+		if err := mysql.RecordUpgrade(db, mysql.ComponentConfig, mysql.Upgrade{From: int64(up.From), To: int64(up.To)}); err != nil {
+			logger.Logger.Warn("Failed to record config upgrade", "error", err)
+		}
+	}
 
 	store := repository.NewSqliteStore(db)
+	store.SetTimeout(cfg.DatabaseTimeout())
+
+	// Monitoring works with program names; sync and team reports use the store as it is, so names leave the
+	// machine hashed
+	repos, err := privacy.Open(context.Background(), store, cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	d, err := daemons.NewDaemonManager()
 	if err != nil {
@@ -48,14 +97,36 @@ func ServiceSetup() (*timekeepService, error) {
 	sessions := sessions.NewSessionManager()
 	ts := transport.NewTransporter()
 
-	service := NewTimekeepService(store, store, store, logger, eventCtrl, sessions, ts, d)
+	service := NewTimekeepService(repos, repos, repos, logger, eventCtrl, sessions, ts, d)
 
-	config, err := config.Load()
+	service.eventCtrl.SetConfig(cfg)
+	service.eventCtrl.Logs = logger
+	service.eventCtrl.Trace = debug
+	service.eventCtrl.Desktop = desktop.Detect()
+	logger.Logger.Info("Reading desktop session", "provider", service.eventCtrl.Desktop.Name())
+
+	token, err := ipc.CreateToken()
 	if err != nil {
 		return nil, err
 	}
+	service.eventCtrl.AuthToken = token
+	service.eventCtrl.Tokens = store
+	service.eventCtrl.Snoozes = store
+	service.eventCtrl.Incognitos = store
+	service.eventCtrl.Groups = repos
+	service.txRepo = store
+	service.reports = repos
+	service.sessions.SetDevice(cfg.DeviceName())
+	service.sessions.SetMaxSession(cfg.MaxSessionLength())
 
-	service.eventCtrl.Config = config
+	// Until the policy source is reached, the copy fetched last applies
+	if cfg.Policy.Source != "" {
+		cached, err := policy.Load()
+		if err != nil {
+			logger.Logger.Warn("Failed to read cached policy", "error", err)
+		}
+		service.sessions.SetPolicy(cached)
+	}
 
 	return service, nil
 }
@@ -91,25 +162,394 @@ func NewTimekeepService(pr repository.ProgramRepository, ar repository.ActiveRep
 	}
 }
 
-// Service shutdown function to stopping running service goroutines, properly end active sessions and close any open files
-func (s *timekeepService) closeService(logger *log.Logger) {
-	logger.Println("INFO: Closing service")
-	if s.eventCtrl.Config.WakaTime.Enabled { // Stop WakaTime heartbeats
-		logger.Println("INFO: Stopping heartbeats")
-		s.eventCtrl.StopHeartbeats()
+// Starts the IPC listeners, config watcher and session validator under the supervisor, restarting any that fail
+func (s *timekeepService) superviseTasks(serviceCtx context.Context) {
+	logger := s.logger.Logger
+	restarts := &s.sessions.Metrics.TaskRestarts
+
+	supervisor.Go(serviceCtx, logger, restarts, "transport", func(ctx context.Context) error {
+		return s.transport.Listen(ctx, logger, s.eventCtrl, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+	})
+	supervisor.Go(serviceCtx, logger, restarts, "remote transport", func(ctx context.Context) error {
+		return s.transport.ListenRemote(ctx, logger, s.eventCtrl, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+	})
+	supervisor.Go(serviceCtx, logger, restarts, "browser listener", func(ctx context.Context) error {
+		return s.transport.ListenBrowser(ctx, logger, s.eventCtrl, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+	})
+	supervisor.Go(serviceCtx, logger, restarts, "editor listener", func(ctx context.Context) error {
+		return s.transport.ListenEditor(ctx, logger, s.eventCtrl, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+	})
+	supervisor.Go(serviceCtx, logger, restarts, "api listener", func(ctx context.Context) error {
+		return s.transport.ListenAPI(ctx, logger, s.eventCtrl, s.reports)
+	})
+	supervisor.Go(serviceCtx, logger, restarts, "debug listener", func(ctx context.Context) error {
+		return s.transport.ListenDebug(ctx, logger, s.eventCtrl, s.sessions)
+	})
+	supervisor.Go(serviceCtx, logger, restarts, "config watcher", func(ctx context.Context) error {
+		return s.eventCtrl.WatchConfig(ctx, logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+	})
+	supervisor.Go(serviceCtx, logger, restarts, "sleep watcher", func(ctx context.Context) error {
+		return s.eventCtrl.WatchSleep(ctx, logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+	})
+	// Periodic validation of active sessions, to clean up stale entries
+	supervisor.Go(serviceCtx, logger, restarts, "session validator", s.runSessionValidator)
+	supervisor.Go(serviceCtx, logger, restarts, "sync", s.runSync)
+	supervisor.Go(serviceCtx, logger, restarts, "team reports", s.runTeamReports)
+	supervisor.Go(serviceCtx, logger, restarts, "kimai", s.runKimai)
+	supervisor.Go(serviceCtx, logger, restarts, "harvest", s.runHarvest)
+	supervisor.Go(serviceCtx, logger, restarts, "policy", s.runPolicy)
+	supervisor.Go(serviceCtx, logger, restarts, "digest", s.runDigest)
+	supervisor.Go(serviceCtx, logger, restarts, "webhooks", s.runWebhooks)
+	supervisor.Go(serviceCtx, logger, restarts, "workspaces", s.runWorkspaces)
+
+	s.applyPendingRefresh(serviceCtx)
+}
+
+// Refreshes if the CLI recorded a change it couldn't deliver while the service was down. Also checked on each
+// validator run, in case the CLI gave up while the service was still starting
+func (s *timekeepService) applyPendingRefresh(serviceCtx context.Context) {
+	pending, err := ipc.TakeRefreshPending()
+	if err != nil {
+		s.logger.Logger.Warn("Failed to check for pending refresh", "error", err)
+		return
 	}
-	logger.Println("INFO: Stopping process monitor")
-	s.eventCtrl.StopProcessMonitor() // Stop any current monitoring function
+	if pending {
+		s.logger.Logger.Info("Applying refresh requested while the service was unreachable")
+		s.eventCtrl.RequestRefresh(serviceCtx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+	}
+}
+
+// Periodically validates active sessions and cleans up stale entries where processes no longer exist, and warns of
+// running programs past their daily limit
+func (s *timekeepService) runSessionValidator(ctx context.Context) error {
+	ticker := time.NewTicker(60 * time.Second)
+	defer ticker.Stop()
 
-	s.sessions.Mu.Lock()
-	for program, tracked := range s.sessions.Programs { // End any active sessions
-		if len(tracked.PIDs) != 0 {
-			logger.Println("INFO: Ending active sessions")
-			s.sessions.MoveSessionToHistory(context.Background(), s.logger.Logger, s.prRepo, s.asRepo, s.hsRepo, program)
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Logger.Info("Session validator stopped")
+			return nil
+		case <-ticker.C:
+			s.sessions.SplitLongSessions(ctx, s.logger.Logger, s.prRepo, s.asRepo, s.hsRepo)
+			s.sessions.ValidateActiveSessions(ctx, s.logger.Logger, s.prRepo, s.asRepo, s.hsRepo)
+			s.eventCtrl.CheckLimits(ctx, s.logger.Logger, s.sessions, s.hsRepo)
+			s.applyPendingRefresh(ctx)
 		}
 	}
+}
 
-	s.logger.FileCleanup() // Close open logging file
+// Runs task with the current config after each wait returned by interval, until ctx is cancelled. The interval is
+// read from the current config each time, so changes apply on config reload, and task is skipped while enabled
+// reports false. Tasks log their own failures, which are retried at the next interval
+func (s *timekeepService) runEvery(ctx context.Context, interval func(*config.Config) time.Duration, enabled func(*config.Config) bool, task func(*config.Config)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval(s.eventCtrl.Config())):
+		}
 
-	s.sessions.Mu.Unlock()
+		cfg := s.eventCtrl.Config()
+		if cfg == nil || !enabled(cfg) {
+			continue
+		}
+		task(cfg)
+	}
+}
+
+// Returns an interval for runEvery that doesn't depend on config
+func fixedInterval(d time.Duration) func(*config.Config) time.Duration {
+	return func(*config.Config) time.Duration { return d }
+}
+
+// How often background sync checks whether sync.interval has been set, while it isn't
+const syncIdleCheck = time.Minute
+
+// Syncs sessions with the sync server every sync.interval
+func (s *timekeepService) runSync(ctx context.Context) error {
+	logger := logs.Component(s.logger.Logger, logs.ComponentSync)
+
+	interval := func(cfg *config.Config) time.Duration {
+		if wait := cfg.SyncInterval(); wait > 0 {
+			return wait
+		}
+		return syncIdleCheck
+	}
+	enabled := func(cfg *config.Config) bool { return cfg.SyncInterval() > 0 }
+
+	return s.runEvery(ctx, interval, enabled, func(cfg *config.Config) {
+		client, err := syncapi.NewClient(cfg.Sync)
+		if err != nil {
+			logger.Error("Failed to set up sync", "error", err)
+			return
+		}
+
+		result, err := client.Sync(ctx, s.txRepo, cfg.DeviceName())
+		if err != nil {
+			logger.Error("Sync failed", "server", cfg.Sync.Server, "pushed", result.Pushed, "pulled", result.Pulled, "error", err)
+			return
+		}
+		logger.Info("Synced sessions", "server", cfg.Sync.Server, "pushed", result.Pushed, "pulled", result.Pulled)
+
+		if result.Pulled > 0 {
+			s.eventCtrl.RequestRefresh(ctx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+		}
+	})
+}
+
+// How often the service checks for completed days to report to the team endpoint
+const teamReportCheck = time.Hour
+
+// Sends each completed day's category totals to the team endpoint while team reporting is enabled
+func (s *timekeepService) runTeamReports(ctx context.Context) error {
+	logger := logs.Component(s.logger.Logger, logs.ComponentTeam)
+	enabled := func(cfg *config.Config) bool { return cfg.Team.Enabled }
+
+	return s.runEvery(ctx, fixedInterval(teamReportCheck), enabled, func(cfg *config.Config) {
+		sent, err := team.Run(ctx, s.txRepo, cfg, time.Now())
+		if err != nil {
+			logger.Error("Team report failed", "endpoint", cfg.Team.Endpoint, "error", err)
+			return
+		}
+		if sent > 0 {
+			logger.Info("Reported daily totals", "endpoint", cfg.Team.Endpoint, "days", sent)
+		}
+	})
+}
+
+// Pushes completed sessions to Kimai every kimai.interval while Kimai is enabled
+func (s *timekeepService) runKimai(ctx context.Context) error {
+	logger := logs.Component(s.logger.Logger, logs.ComponentKimai)
+	enabled := func(cfg *config.Config) bool { return cfg.Kimai.Enabled }
+
+	return s.runEvery(ctx, (*config.Config).KimaiInterval, enabled, func(cfg *config.Config) {
+		pushed, err := kimai.Run(ctx, s.reports, cfg, time.Now(), time.Time{})
+		if err != nil {
+			logger.Error("Kimai push failed", "server", cfg.Kimai.Server, "pushed", pushed, "error", err)
+			return
+		}
+		if pushed > 0 {
+			logger.Info("Pushed sessions to Kimai", "server", cfg.Kimai.Server, "sessions", pushed)
+		}
+	})
+}
+
+// Creates Harvest time entries every harvest.interval while Harvest is enabled
+func (s *timekeepService) runHarvest(ctx context.Context) error {
+	logger := logs.Component(s.logger.Logger, logs.ComponentHarvest)
+	enabled := func(cfg *config.Config) bool { return cfg.Harvest.Enabled }
+
+	return s.runEvery(ctx, (*config.Config).HarvestInterval, enabled, func(cfg *config.Config) {
+		created, err := harvest.Run(ctx, s.reports, cfg, time.Now(), time.Time{})
+		if err != nil {
+			logger.Error("Harvest push failed", "created", created, "error", err)
+			return
+		}
+		if created > 0 {
+			logger.Info("Created Harvest time entries", "entries", created)
+		}
+	})
+}
+
+// How often the service checks whether the weekly digest is due
+const digestCheck = 10 * time.Minute
+
+// Sends the weekly digest once it's due while the digest is enabled. A digest missed while the machine was off is
+// sent at the first check after it's back
+func (s *timekeepService) runDigest(ctx context.Context) error {
+	logger := logs.Component(s.logger.Logger, logs.ComponentDigest)
+	enabled := func(cfg *config.Config) bool { return cfg.Digest.Enabled }
+
+	return s.runEvery(ctx, fixedInterval(digestCheck), enabled, func(cfg *config.Config) {
+		sent, err := digest.Run(ctx, s.reports, cfg, time.Now())
+		if err != nil {
+			logger.Error("Weekly digest failed", "sent", sent, "error", err)
+			return
+		}
+		if sent {
+			logger.Info("Sent weekly digest")
+		}
+	})
+}
+
+// How often the workspace in use is sampled while workspaces.track is set
+const workspaceSample = 10 * time.Second
+
+// Credits the time between samples to the workspace in use, in every running session, while workspaces.track is set.
+// Time while paused or incognito isn't credited, nor gaps much longer than a sample, such as over system sleep. A
+// failure to read the workspace is logged once until it's read again
+func (s *timekeepService) runWorkspaces(ctx context.Context) error {
+	logger := logs.Component(s.logger.Logger, logs.ComponentWorkspaces)
+
+	ticker := time.NewTicker(workspaceSample)
+	defer ticker.Stop()
+
+	var last time.Time
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			elapsed := now.Sub(last)
+			last = now
+			if !s.eventCtrl.Config().TrackWorkspaces() || s.eventCtrl.Paused() || s.eventCtrl.Incognito() || elapsed > 2*workspaceSample {
+				continue
+			}
+
+			current, err := s.eventCtrl.Desktop.Workspace(ctx)
+			if err != nil {
+				if !failing {
+					logger.Warn("Failed to read the workspace in use, session time isn't split by workspace", "error", err)
+					failing = true
+				}
+				continue
+			}
+			if failing {
+				logger.Info("Reading the workspace in use again", "workspace", current)
+				failing = false
+			}
+			s.sessions.CreditWorkspace(current, elapsed)
+		}
+	}
+}
+
+// Events waiting to be sent to webhooks, beyond which new ones are dropped
+const webhookQueue = 100
+
+// Sends session events to the endpoints in webhooks as they happen, one at a time. Events arriving while the queue is
+// full, such as while an endpoint is slow to answer, are dropped rather than holding up tracking
+func (s *timekeepService) runWebhooks(ctx context.Context) error {
+	logger := logs.Component(s.logger.Logger, logs.ComponentWebhooks)
+
+	queue := make(chan webhook.Event, webhookQueue)
+	s.sessions.SetNotifier(func(e webhook.Event) {
+		if cfg := s.eventCtrl.Config(); cfg == nil || len(cfg.Webhooks) == 0 {
+			return
+		}
+		select {
+		case queue <- e:
+		default:
+			logger.Warn("Webhook queue full, dropping event", "event", e.Kind, "program", e.Program)
+		}
+	})
+	defer s.sessions.SetNotifier(nil)
+
+	client := &http.Client{Timeout: webhook.Timeout}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-queue:
+			if err := webhook.Dispatch(ctx, client, s.eventCtrl.Config(), e); err != nil {
+				logger.Warn("Failed to send webhook", "event", e.Kind, "program", e.Program, "error", err)
+			}
+		}
+	}
+}
+
+// How often the policy task checks whether policy.source has been set, while it isn't
+const policyIdleCheck = time.Minute
+
+// Fetches the managed policy from policy.source at start and every policy.interval, applying it when it changes.
+// When the source can't be reached the cached policy stays in force, and once policy.source is unset the policy is
+// dropped
+func (s *timekeepService) runPolicy(ctx context.Context) error {
+	logger := logs.Component(s.logger.Logger, logs.ComponentPolicy)
+
+	for {
+		cfg := s.eventCtrl.Config()
+		wait := policyIdleCheck
+		if cfg != nil && cfg.Policy.Source != "" {
+			wait = cfg.PolicyInterval()
+
+			fetched, err := policy.Fetch(ctx, cfg.Policy.Source)
+			if err != nil {
+				logger.Warn("Failed to fetch policy, keeping the current one", "source", cfg.Policy.Source, "error", err)
+			} else if !fetched.Equal(s.sessions.Policy()) {
+				s.applyPolicy(ctx, logger, fetched)
+			}
+		} else if s.sessions.Policy() != nil {
+			s.applyPolicy(ctx, logger, nil)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Puts managed in force: caches it, ends the sessions of programs it excludes, files mapped programs under their
+// category and reloads the tracked programs. A nil policy removes the one in force
+func (s *timekeepService) applyPolicy(ctx context.Context, logger *slog.Logger, managed *policy.Policy) {
+	if err := policy.Save(managed); err != nil {
+		logger.Warn("Failed to cache policy", "error", err)
+	}
+	s.sessions.SetPolicy(managed)
+
+	if managed == nil {
+		logger.Info("Policy source unset, dropping policy")
+		s.eventCtrl.RequestRefresh(ctx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+		return
+	}
+	logger.Info("Applying policy", "source", managed.Source, "exclusions", len(managed.Exclude), "categories", len(managed.Categories))
+
+	active, err := s.asRepo.GetAllActiveSessions(ctx)
+	if err != nil {
+		logger.Error("Failed to get active sessions", "error", err)
+	}
+	for _, session := range active {
+		if managed.Excluded(session.ProgramName) {
+			s.sessions.MoveSessionToHistory(ctx, s.logger.Logger, s.prRepo, s.asRepo, s.hsRepo, session.ProgramName, repository.EndReasonPolicy)
+		}
+	}
+
+	programs, err := s.prRepo.GetAllPrograms(ctx)
+	if err != nil {
+		logger.Error("Failed to get tracked programs", "error", err)
+		return
+	}
+	for _, p := range programs {
+		category, ok := managed.Category(p.Name)
+		if !ok || category == p.Category.String || managed.Excluded(p.Name) {
+			continue
+		}
+		params := database.UpdateCategoryParams{Category: sql.NullString{String: category, Valid: true}, Name: p.Name}
+		if err := s.prRepo.UpdateCategory(ctx, params); err != nil {
+			logger.Error("Failed to update category", "program", p.Name, "category", category, "error", err)
+		}
+	}
+
+	s.eventCtrl.RequestRefresh(ctx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+}
+
+// Time allowed to flush in-flight sessions to history on shutdown, kept within the SCM's stop wait
+const shutdownTimeout = 15 * time.Second
+
+// Service shutdown function to stopping running service goroutines, flush active sessions to history and close any open files
+func (s *timekeepService) closeService(logger *slog.Logger) {
+	logger.Info("Closing service")
+	logger.Info("Stopping heartbeats")
+	s.eventCtrl.StopHeartbeats()
+	logger.Info("Stopping process monitor")
+	s.eventCtrl.StopProcessMonitor() // Stop any current monitoring function
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	flushed, remaining := s.sessions.FlushSessions(ctx, s.logger.Logger, s.prRepo, s.asRepo, s.hsRepo, repository.EndReasonShutdown)
+	if remaining > 0 {
+		logger.Warn("Shutdown timeout reached, remaining sessions stay active until next start", "flushed", flushed, "remaining", remaining)
+	} else if flushed > 0 {
+		logger.Info("Flushed active sessions to history", "count", flushed)
+	}
+
+	if err := s.eventCtrl.Desktop.Close(); err != nil {
+		logger.Warn("Failed to close desktop session connection", "error", err)
+	}
+
+	s.logger.FileCleanup() // Close open logging file
 }
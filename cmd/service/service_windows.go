@@ -4,8 +4,16 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/jms-guy/timekeep/cmd/service/internal/events"
+	"github.com/jms-guy/timekeep/internal/profile"
+
+	"golang.org/x/sys/windows"
 	"golang.org/x/sys/windows/svc"
 	"golang.org/x/sys/windows/svc/debug"
 )
@@ -13,6 +21,14 @@ import (
 // Windows specific service management functions
 
 func RunService(name string, isDebug *bool) error {
+	if profile.UserScope() {
+		service, err := ServiceSetup(*isDebug)
+		if err != nil {
+			return err
+		}
+		return service.runAgent()
+	}
+
 	if *isDebug {
 		service, err := TestServiceSetup()
 		if err != nil {
@@ -20,7 +36,7 @@ func RunService(name string, isDebug *bool) error {
 		}
 		return debug.Run(name, service)
 	} else {
-		service, err := ServiceSetup()
+		service, err := ServiceSetup(false)
 		if err != nil {
 			return err
 		}
@@ -30,59 +46,29 @@ func RunService(name string, isDebug *bool) error {
 
 // Service execute method for Windows Handler interface
 func (s *timekeepService) Execute(args []string, r <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
-	s.logger.Logger.Println("INFO: Service Execute function entered.")
+	s.logger.Logger.Info("Service Execute function entered")
 
 	if s.logger.LogFile != nil {
 		err := s.logger.LogFile.Sync()
 		if err != nil {
-			s.logger.Logger.Printf("ERROR: Failed to sync log file: %v", err)
+			s.logger.Logger.Error("Failed to sync log file", "error", err)
 		}
 	}
 
 	// Signals that service can accept from SCM(Service Control Manager)
-	const cmdsAccepted = svc.AcceptStop | svc.AcceptShutdown | svc.AcceptPauseAndContinue
+	const cmdsAccepted = svc.AcceptStop | svc.AcceptShutdown | svc.AcceptPauseAndContinue | svc.AcceptPowerEvent | svc.AcceptSessionChange
 
 	status <- svc.Status{State: svc.StartPending}
 
 	serviceCtx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	s.eventCtrl.Shutdown = cancel
 
-	programs, err := s.prRepo.GetAllPrograms(context.Background())
-	if err != nil {
-		s.logger.Logger.Printf("ERROR: Failed to get programs: %s", err)
+	if err := s.startTracking(serviceCtx); err != nil {
+		s.logger.Logger.Error("Failed to start tracking", "error", err)
 		status <- svc.Status{State: svc.Stopped}
 		return false, 1
 	}
-	if len(programs) > 0 {
-		toTrack := []string{}
-		for _, program := range programs {
-			category := ""
-			if program.Category.Valid {
-				category = program.Category.String
-			}
-			project := ""
-			if program.Project.Valid {
-				project = program.Project.String
-			}
-			s.sessions.Mu.Lock()
-			s.sessions.EnsureProgram(program.Name, category, project)
-			s.sessions.Mu.Unlock()
-
-			toTrack = append(toTrack, program.Name)
-		}
-
-		s.eventCtrl.StartPreMonitor(s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo, toTrack)
-		s.eventCtrl.StartMonitor(serviceCtx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo, toTrack)
-	}
-
-	if s.eventCtrl.Config.WakaTime.Enabled || s.eventCtrl.Config.Wakapi.Enabled {
-		s.eventCtrl.StartHeartbeats(serviceCtx, s.logger.Logger, s.sessions)
-	}
-
-	go s.transport.Listen(serviceCtx, s.logger.Logger, s.eventCtrl, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
-
-	// Start periodic validation of active sessions to clean up stale entries
-	go s.startSessionValidator(serviceCtx)
 
 	status <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
 
@@ -90,35 +76,49 @@ func (s *timekeepService) Execute(args []string, r <-chan svc.ChangeRequest, sta
 loop:
 	for {
 		select {
+		case <-serviceCtx.Done(): // Shutdown requested over IPC
+			status <- svc.Status{State: svc.StopPending, WaitHint: uint32((shutdownTimeout + 5*time.Second).Milliseconds())}
+			s.logger.Logger.Info("Received shutdown request")
+			s.closeService(s.logger.Logger)
+			break loop
+
 		case c := <-r:
 			switch c.Cmd {
 			case svc.Interrogate: // Check current status of service
 				status <- c.CurrentStatus
 
 			case svc.Stop, svc.Shutdown: // Service needs to be stopped or shutdown
-				status <- svc.Status{State: svc.StopPending}
-				s.logger.Logger.Println("INFO: Received stop signal")
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32((shutdownTimeout + 5*time.Second).Milliseconds())}
+				s.logger.Logger.Info("Received stop signal")
 				s.closeService(s.logger.Logger)
-				s.eventCtrl.MonCancel()
-				s.eventCtrl.WakaCancel()
 				cancel()
 				break loop
 
 			case svc.Pause: // Service needs to be paused, without shutdown
 				status <- svc.Status{State: svc.Paused, Accepts: cmdsAccepted}
-				s.logger.Logger.Println("INFO: Pausing service")
-				if s.eventCtrl.Config.WakaTime.Enabled {
-					s.eventCtrl.StopHeartbeats()
-				}
-				s.eventCtrl.StopProcessMonitor()
+				s.logger.Logger.Info("Pausing service")
+				s.eventCtrl.Pause(s.logger.Logger)
 
 			case svc.Continue: // Resume paused execution state of service
 				status <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
-				s.logger.Logger.Println("INFO: Resuming service")
-				s.eventCtrl.RefreshProcessMonitor(serviceCtx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+				s.logger.Logger.Info("Resuming service")
+				s.eventCtrl.Resume(serviceCtx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+
+			case svc.PowerEvent: // System going to sleep or waking up
+				switch c.EventType {
+				case events.PowerSuspend:
+					s.eventCtrl.Sleep(s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+				case events.PowerResumeSuspend, events.PowerResumeAutomatic:
+					go s.eventCtrl.Wake(serviceCtx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+				}
+
+			case svc.SessionChange: // Another user signed in at, or switched to, the console
+				if c.EventType == windows.WTS_CONSOLE_CONNECT {
+					go s.eventCtrl.ConsoleChanged(serviceCtx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+				}
 
 			default:
-				s.logger.Logger.Printf("ERROR: Unexpected service control request #%d", c)
+				s.logger.Logger.Error("Unexpected service control request", "cmd", c.Cmd)
 			}
 		}
 	}
@@ -126,18 +126,55 @@ loop:
 	return false, 0
 }
 
-// Periodically validates active sessions and cleans up stale entries where processes no longer exist
-func (s *timekeepService) startSessionValidator(ctx context.Context) {
-	ticker := time.NewTicker(60 * time.Second)
-	defer ticker.Stop()
+// Starts the monitor, heartbeats, IPC listeners, config watcher and session validator. The monitor and heartbeats
+// stay off while a snooze or incognito mode from before the restart lasts, or while another profile is in use
+func (s *timekeepService) startTracking(serviceCtx context.Context) error {
+	s.eventCtrl.RestoreSnooze(serviceCtx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+	s.eventCtrl.RestoreIncognito(serviceCtx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+	s.eventCtrl.CheckProfile(s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo)
+	paused := s.eventCtrl.Paused() || s.eventCtrl.Incognito()
 
-	for {
-		select {
-		case <-ctx.Done():
-			s.logger.Logger.Println("INFO: Session validator stopped")
-			return
-		case <-ticker.C:
-			s.sessions.ValidateActiveSessions(ctx, s.logger.Logger, s.prRepo, s.asRepo, s.hsRepo)
+	programs, err := s.prRepo.GetAllPrograms(context.Background())
+	if err != nil {
+		return fmt.Errorf("error getting programs: %w", err)
+	}
+	if len(programs) > 0 {
+		toTrack := s.sessions.LoadPrograms(programs)
+
+		if !paused {
+			s.eventCtrl.StartPreMonitor(s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo, toTrack)
+			s.eventCtrl.StartMonitor(serviceCtx, s.logger.Logger, s.sessions, s.prRepo, s.asRepo, s.hsRepo, toTrack)
 		}
 	}
+
+	if cfg := s.eventCtrl.Config(); !paused && (cfg.WakaTime.Enabled || cfg.Wakapi.Enabled) {
+		s.eventCtrl.StartHeartbeats(serviceCtx, s.logger.Logger, s.sessions)
+	}
+
+	s.superviseTasks(serviceCtx)
+
+	return nil
+}
+
+// Runs as a per-user agent in the login session, until signalled or asked to shut down over IPC
+func (s *timekeepService) runAgent() error {
+	s.logger.Logger.Info("Starting per-user agent")
+
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serviceCtx, shutdown := context.WithCancel(signalCtx)
+	defer shutdown()
+	s.eventCtrl.Shutdown = shutdown
+
+	if err := s.startTracking(serviceCtx); err != nil {
+		return err
+	}
+
+	<-serviceCtx.Done()
+
+	s.logger.Logger.Info("Received shutdown signal")
+	s.closeService(s.logger.Logger)
+
+	return nil
 }
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/cmd/service/internal/logs"
+	"github.com/jms-guy/timekeep/cmd/service/internal/sessions"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/progname"
+	"github.com/jms-guy/timekeep/internal/repository"
+	mysql "github.com/jms-guy/timekeep/sql"
+)
+
+// Synthetic process events replayed by a simulation
+type simulation struct {
+	Programs []simProgram `json:"programs,omitempty"` // Programs to track, defaults to those in the local database
+	Events   []simEvent   `json:"events"`
+}
+
+type simProgram struct {
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+	Project  string `json:"project,omitempty"`
+}
+
+type simEvent struct {
+	At      time.Time `json:"at"`
+	Type    string    `json:"type"` // start or stop
+	Program string    `json:"program"`
+	PID     int       `json:"pid"`
+}
+
+// Replays the events in path through a session manager backed by an in-memory database, and writes the
+// resulting session history to w. The real database is only read, for tracked programs
+func RunSimulation(path string, w io.Writer, debug bool) error {
+	sim, err := loadSimulation(path)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if len(sim.Programs) == 0 {
+		sim.Programs, err = localPrograms(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	db, err := mysql.OpenTestDatabase()
+	if err != nil {
+		return fmt.Errorf("error opening simulation database: %w", err)
+	}
+	defer db.Close()
+
+	store := repository.NewSqliteStore(db)
+
+	logger := slog.New(slog.DiscardHandler)
+	if debug {
+		logger = logs.NewTestLogs().Logger
+	}
+
+	var now time.Time
+	sm := sessions.NewSessionManager()
+	sm.SetClock(func() time.Time { return now })
+
+	tracked := make(map[string]bool)
+	for _, p := range sim.Programs {
+		name := progname.Normalize(p.Name)
+		if err := store.AddProgram(ctx, database.AddProgramParams{
+			Name:     name,
+			Category: sql.NullString{String: p.Category, Valid: p.Category != ""},
+			Project:  sql.NullString{String: p.Project, Valid: p.Project != ""},
+		}); err != nil {
+			return fmt.Errorf("error adding program %s: %w", name, err)
+		}
+		sm.EnsureProgram(name, p.Category, p.Project)
+		tracked[name] = true
+	}
+
+	ignored := 0
+	for _, ev := range sim.Events {
+		now = ev.At
+		name := progname.Normalize(ev.Program)
+
+		if !tracked[name] { // Monitors only report tracked programs
+			ignored++
+			continue
+		}
+
+		switch ev.Type {
+		case "start":
+			sm.CreateSession(ctx, logger, store, name, ev.PID)
+		case "stop":
+			sm.EndSession(ctx, logger, store, store, store, name, ev.PID)
+		}
+	}
+
+	// End sessions still running at the last event, as the service does on shutdown
+	running := []string{}
+	for _, s := range sm.Snapshot() {
+		running = append(running, s.Name)
+		sm.MoveSessionToHistory(ctx, logger, store, store, store, s.Name, repository.EndReasonShutdown)
+	}
+
+	history, err := store.GetAllSessionHistory(ctx, database.GetAllSessionHistoryParams{Limit: -1})
+	if err != nil {
+		return fmt.Errorf("error getting simulated session history: %w", err)
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].StartTime.Before(history[j].StartTime) })
+
+	programs, err := store.GetAllPrograms(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting simulated programs: %w", err)
+	}
+
+	writeSimulation(w, sim, history, programs, running, ignored)
+
+	return nil
+}
+
+// Reads and validates a simulation file, ordering its events by time
+func loadSimulation(path string) (simulation, error) {
+	var sim simulation
+
+	// #nosec G304
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sim, fmt.Errorf("error reading simulation file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &sim); err != nil {
+		return sim, fmt.Errorf("error parsing simulation file: %w", err)
+	}
+
+	for i, ev := range sim.Events {
+		if ev.At.IsZero() {
+			return sim, fmt.Errorf("event %d: missing time", i+1)
+		}
+		if ev.Program == "" {
+			return sim, fmt.Errorf("event %d: missing program", i+1)
+		}
+		if ev.Type != "start" && ev.Type != "stop" {
+			return sim, fmt.Errorf("event %d: unknown type %q, expected start or stop", i+1, ev.Type)
+		}
+	}
+
+	sort.SliceStable(sim.Events, func(i, j int) bool { return sim.Events[i].At.Before(sim.Events[j].At) })
+
+	return sim, nil
+}
+
+// Gets the programs tracked in the local database
+func localPrograms(ctx context.Context) ([]simProgram, error) {
+	db, err := mysql.OpenLocalDatabase()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	programs, err := repository.NewSqliteStore(db).GetAllPrograms(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tracked programs: %w", err)
+	}
+
+	result := make([]simProgram, 0, len(programs))
+	for _, p := range programs {
+		result = append(result, simProgram{Name: p.Name, Category: p.Category.String, Project: p.Project.String})
+	}
+
+	return result, nil
+}
+
+// Writes the outcome of a simulation
+func writeSimulation(w io.Writer, sim simulation, history []database.SessionHistory, programs []database.TrackedProgram, running []string, ignored int) {
+	fmt.Fprintf(w, "Replayed %d events (%d for untracked programs ignored)\n\n", len(sim.Events), ignored)
+
+	fmt.Fprintln(w, "Sessions:")
+	if len(history) == 0 {
+		fmt.Fprintln(w, "  None")
+	}
+	for _, h := range history {
+		fmt.Fprintf(w, "  %-20s %s - %s  %s\n", h.ProgramName, h.StartTime.Format(time.RFC3339), h.EndTime.Format(time.RFC3339), time.Duration(h.DurationSeconds)*time.Second)
+	}
+
+	if len(running) > 0 {
+		fmt.Fprintf(w, "\nStill running at last event, ended there: %s\n", strings.Join(running, ", "))
+	}
+
+	fmt.Fprintln(w, "\nLifetime totals:")
+	for _, p := range programs {
+		fmt.Fprintf(w, "  %-20s %s\n", p.Name, time.Duration(p.LifetimeSeconds)*time.Second)
+	}
+}
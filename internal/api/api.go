@@ -0,0 +1,393 @@
+// Package api serves tracking data over HTTP for dashboards and other integrations: a REST endpoint per resource, and
+// a GraphQL endpoint fetching any combination of them in one request. Every endpoint is read-only
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/progname"
+	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/jms-guy/timekeep/internal/streaks"
+)
+
+// Paths of the REST endpoints, the GraphQL endpoint and the OpenAPI document
+const (
+	ProgramsPath = "/api/v1/programs"
+	SessionsPath = "/api/v1/sessions"
+	ActivePath   = "/api/v1/active"
+	TotalsPath   = "/api/v1/totals"
+	GraphQLPath  = "/graphql"
+	SchemaPath   = "/graphql/schema.graphql"
+	OpenAPIPath  = "/openapi.json"
+)
+
+// Sessions returned when no limit is given, and the most returned at once
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Groupings of totals
+const (
+	ByProgram  = "program"
+	ByCategory = "category"
+	ByProject  = "project"
+	ByGroup    = "group" // Program groups, a program counting towards each group it's in and not at all when in none
+	ByDay      = "day"
+)
+
+// Tracked program
+type Program struct {
+	Name            string `json:"name"`
+	Category        string `json:"category,omitempty"`
+	Project         string `json:"project,omitempty"`
+	LifetimeSeconds int64  `json:"lifetime_seconds"`
+}
+
+// Recorded session
+type Session struct {
+	ID              int64     `json:"id"`
+	Program         string    `json:"program"`
+	Device          string    `json:"device,omitempty"` // Machine the session was recorded on, empty for this one
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds int64     `json:"duration_seconds"`
+}
+
+// Session in progress
+type ActiveSession struct {
+	Program        string    `json:"program"`
+	Start          time.Time `json:"start"`
+	ElapsedSeconds int64     `json:"elapsed_seconds"`
+}
+
+// Time recorded under one key of a grouping, such as one category
+type Total struct {
+	Key     string `json:"key"` // Empty for time with no category or project
+	Seconds int64  `json:"seconds"`
+}
+
+// Filters of a sessions query
+type SessionQuery struct {
+	From    time.Time
+	To      time.Time
+	Program string
+	Device  string
+	Limit   int
+}
+
+// Source of the data served, read through a store with program names readable
+type Source struct {
+	Store  repository.Store
+	Config func() *config.Config // Current config, giving the timezone and week start of date arguments
+}
+
+func (src *Source) config() *config.Config {
+	if src.Config == nil {
+		return nil
+	}
+	return src.Config()
+}
+
+// Resolves the window between from and to, each a timestamp in RFC 3339 or a date in any 'history --date' format, such
+// as "today" or 2024-01-01. A date starts the window at its start and ends it at its end. Without from the window is
+// today. Without to, a date as from covers the span it names and a timestamp runs up to now
+func (src *Source) Window(from, to string) (time.Time, time.Time, error) {
+	cfg := src.config()
+	loc, err := cfg.Location()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	opts := dates.Options{WeekStart: cfg.WeekStartDay(), DateLayout: cfg.DateLayout()}
+	now := time.Now()
+
+	if from == "" {
+		from = "today"
+	}
+	start, spanEnd, isDate, err := parseTime(from, now, loc, opts)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %w", err)
+	}
+
+	end := now
+	switch {
+	case to != "":
+		if _, end, _, err = parseTime(to, now, loc, opts); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %w", err)
+		}
+	case isDate && spanEnd.Before(now):
+		end = spanEnd // A date alone covers the span it names
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, errors.New("window ends before it starts")
+	}
+	return start, end, nil
+}
+
+// Parses a timestamp, returned as both ends, or a date, returned as the start and end of its span and reported as one
+func parseTime(value string, now time.Time, loc *time.Location, opts dates.Options) (time.Time, time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, t, false, nil
+	}
+	span, err := dates.Parse(value, now, loc, opts)
+	if err != nil {
+		return time.Time{}, time.Time{}, false, err
+	}
+	return span.Start, span.End, true, nil
+}
+
+// Returns the tracked programs, or the one named by name
+func (src *Source) Programs(ctx context.Context, name string) ([]Program, error) {
+	programs, err := src.Store.GetAllPrograms(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting programs: %w", err)
+	}
+	name = progname.Normalize(name)
+
+	list := []Program{}
+	for _, program := range programs {
+		if name != "" && program.Name != name {
+			continue
+		}
+		list = append(list, Program{
+			Name:            program.Name,
+			Category:        program.Category.String,
+			Project:         program.Project.String,
+			LifetimeSeconds: program.LifetimeSeconds,
+		})
+	}
+	return list, nil
+}
+
+// Stops streaming once enough sessions are read
+var errEnough = errors.New("enough sessions")
+
+// Returns the sessions overlapping the query's window, oldest first, up to its limit
+func (src *Source) Sessions(ctx context.Context, q SessionQuery) ([]Session, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	params := database.StreamSessionHistoryParams{
+		ProgramName: database.NullString(progname.Normalize(q.Program)),
+		RangeStart:  q.From.UTC(),
+		RangeEnd:    q.To.UTC(),
+		Device:      database.NullString(q.Device),
+	}
+	list := []Session{}
+	err := src.Store.StreamSessionHistory(ctx, params, func(session database.SessionHistory) error {
+		if !session.EndTime.After(q.From) || !session.StartTime.Before(q.To) {
+			return nil
+		}
+		list = append(list, Session{
+			ID:              session.ID,
+			Program:         session.ProgramName,
+			Device:          session.Device.String,
+			Start:           session.StartTime,
+			End:             session.EndTime,
+			DurationSeconds: session.DurationSeconds,
+		})
+		if len(list) >= limit {
+			return errEnough
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEnough) {
+		return nil, fmt.Errorf("error reading session history: %w", err)
+	}
+	return list, nil
+}
+
+// Returns the sessions in progress
+func (src *Source) Active(ctx context.Context) ([]ActiveSession, error) {
+	sessions, err := src.Store.GetAllActiveSessions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting active sessions: %w", err)
+	}
+
+	now := time.Now()
+	list := []ActiveSession{}
+	for _, session := range sessions {
+		list = append(list, ActiveSession{
+			Program:        session.ProgramName,
+			Start:          session.StartTime,
+			ElapsedSeconds: int64(max(now.Sub(session.StartTime), 0).Seconds()),
+		})
+	}
+	return list, nil
+}
+
+// Returns the time recorded within [from, to) grouped by program, category, project, program group or day, most time
+// first, or by day in order. Sessions crossing the window's edges count their part inside it
+func (src *Source) Totals(ctx context.Context, from, to time.Time, by string) ([]Total, error) {
+	if by == "" {
+		by = ByProgram
+	}
+	if by != ByProgram && by != ByCategory && by != ByProject && by != ByGroup && by != ByDay {
+		return nil, fmt.Errorf("unknown grouping %q, use program, category, project, group or day", by)
+	}
+	loc, err := src.config().Location()
+	if err != nil {
+		return nil, err
+	}
+
+	programs, err := src.Store.GetAllPrograms(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting programs: %w", err)
+	}
+	byName := make(map[string]database.TrackedProgram, len(programs))
+	for _, program := range programs {
+		byName[program.Name] = program
+	}
+	groups := make(map[string][]string)
+	if by == ByGroup {
+		members, err := src.Store.GetAllGroupMembers(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting groups: %w", err)
+		}
+		for _, member := range members {
+			groups[member.ProgramName] = append(groups[member.ProgramName], member.GroupName)
+		}
+	}
+
+	totals := make(map[string]time.Duration)
+	params := database.StreamSessionHistoryParams{RangeStart: from.UTC(), RangeEnd: to.UTC()}
+	err = src.Store.StreamSessionHistory(ctx, params, func(session database.SessionHistory) error {
+		switch by {
+		case ByProgram:
+			totals[session.ProgramName] += streaks.Within(session, from, to)
+		case ByCategory:
+			totals[byName[session.ProgramName].Category.String] += streaks.Within(session, from, to)
+		case ByProject:
+			totals[byName[session.ProgramName].Project.String] += streaks.Within(session, from, to)
+		case ByGroup:
+			for _, group := range groups[session.ProgramName] {
+				totals[group] += streaks.Within(session, from, to)
+			}
+		case ByDay:
+			start := session.StartTime.In(loc)
+			for day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc); day.Before(session.EndTime) && day.Before(to); day = day.AddDate(0, 0, 1) {
+				next := day.AddDate(0, 0, 1)
+				if spent := streaks.Within(session, dates.Later(day, from), dates.Earlier(next, to)); spent > 0 {
+					totals[day.Format(time.DateOnly)] += spent
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading session history: %w", err)
+	}
+
+	list := []Total{}
+	for key, spent := range totals {
+		if spent > 0 {
+			list = append(list, Total{Key: key, Seconds: int64(spent.Seconds())})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if by != ByDay && list[i].Seconds != list[j].Seconds {
+			return list[i].Seconds > list[j].Seconds
+		}
+		return list[i].Key < list[j].Key
+	})
+	return list, nil
+}
+
+// Serves the endpoints of the OpenAPI document, REST and GraphQL, for requests authorize accepts. Fails if the
+// document and the handlers don't match
+func NewHandler(src *Source, authorize func(*http.Request) bool) (http.Handler, error) {
+	rest := func(fn func(r *http.Request) (any, error)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			body, err := fn(r)
+			if err != nil {
+				writeError(w, statusOf(err), err.Error())
+				return
+			}
+			writeJSON(w, http.StatusOK, body)
+		}
+	}
+
+	// Parameters are checked against the document before these run
+	handlers := map[string]http.HandlerFunc{
+		"listPrograms": rest(func(r *http.Request) (any, error) {
+			programs, err := src.Programs(r.Context(), r.URL.Query().Get("name"))
+			return map[string]any{"programs": programs}, err
+		}),
+		"listSessions": rest(func(r *http.Request) (any, error) {
+			query := r.URL.Query()
+			from, to, err := src.Window(query.Get("from"), query.Get("to"))
+			if err != nil {
+				return nil, badRequest(err)
+			}
+			limit, _ := strconv.Atoi(query.Get("limit"))
+			sessions, err := src.Sessions(r.Context(), SessionQuery{From: from, To: to, Program: query.Get("program"), Device: query.Get("device"), Limit: limit})
+			return map[string]any{"sessions": sessions}, err
+		}),
+		"listActiveSessions": rest(func(r *http.Request) (any, error) {
+			active, err := src.Active(r.Context())
+			return map[string]any{"active": active}, err
+		}),
+		"listTotals": rest(func(r *http.Request) (any, error) {
+			query := r.URL.Query()
+			from, to, err := src.Window(query.Get("from"), query.Get("to"))
+			if err != nil {
+				return nil, badRequest(err)
+			}
+			totals, err := src.Totals(r.Context(), from, to, query.Get("by"))
+			return map[string]any{"totals": totals}, err
+		}),
+		"queryGraphQL": src.serveGraphQL,
+		"postGraphQL":  src.serveGraphQL,
+		"getGraphQLSchema": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte(Schema))
+		},
+		"getOpenAPI": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(OpenAPI)
+		},
+	}
+
+	mux := http.NewServeMux()
+	if err := routeSpec(mux, OpenAPI, handlers, authorize); err != nil {
+		return nil, fmt.Errorf("error routing API: %w", err)
+	}
+	return mux, nil
+}
+
+// Error in a request's parameters, answered with 400 Bad Request
+type requestError struct{ err error }
+
+func (e requestError) Error() string { return e.err.Error() }
+
+func badRequest(err error) error { return requestError{err} }
+
+func statusOf(err error) int {
+	if errors.As(err, &requestError{}) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
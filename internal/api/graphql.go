@@ -0,0 +1,1121 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Schema of the GraphQL endpoint. Requests are queries using fields, arguments, aliases, variables, fragments and the
+// @include and @skip directives. Mutations, subscriptions and introspection aren't supported
+const Schema = `"Dates are timestamps in RFC 3339 or dates in any 'timekeep history --date' format, such as \"today\", \"last week\" or \"2024-01-01\""
+type Query {
+  "Tracked programs, or the one named"
+  programs(name: String): [Program!]!
+  "Sessions overlapping the window, oldest first. The window defaults to today, and limit to 100, at most 1000"
+  sessions(from: String, to: String, program: String, device: String, limit: Int): [Session!]!
+  "Sessions in progress"
+  active: [ActiveSession!]!
+  "Time recorded within the window, most first, or in order by day. The window defaults to today"
+  totals(from: String, to: String, by: Grouping = PROGRAM): [Total!]!
+}
+
+enum Grouping {
+  PROGRAM
+  CATEGORY
+  PROJECT
+  GROUP
+  DAY
+}
+
+type Program {
+  name: String!
+  category: String
+  project: String
+  lifetimeSeconds: Int!
+}
+
+type Session {
+  id: Int!
+  program: String!
+  "Machine the session was recorded on, null for this one"
+  device: String
+  start: String!
+  end: String!
+  durationSeconds: Int!
+}
+
+type ActiveSession {
+  program: String!
+  start: String!
+  elapsedSeconds: Int!
+}
+
+type Total {
+  "Program, category, project or YYYY-MM-DD day. Empty for time with no category or project"
+  key: String!
+  seconds: Int!
+}
+`
+
+// Largest GraphQL request accepted
+const maxGraphQLRequest = 1 << 20
+
+// Limits on a GraphQL query, so one request can't have the service run the same database query many times over or
+// recurse without end. The schema is two levels deep and has a handful of root fields, so no real query comes near them
+const (
+	maxQueryDepth   = 10  // Deepest nesting of selection sets, lists and input objects
+	maxAliases      = 20  // Most aliased fields in a request
+	maxRootFields   = 10  // Most root fields in an operation, each a database query, after fragments are spread
+	maxFieldsPerSet = 100 // Most fields selected on one object, after fragments are spread
+)
+
+// Body of a GraphQL request, as posted in JSON
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   *orderedMap    `json:"data,omitempty"`
+	Errors []graphQLError `json:"errors,omitempty"`
+}
+
+// Answers a GraphQL request, posted as JSON or sent with GET in the query, operationName and variables parameters
+func (src *Source) serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query, req.OperationName = query.Get("query"), query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeJSON(w, http.StatusBadRequest, graphQLResponse{Errors: []graphQLError{{Message: "invalid variables"}}})
+				return
+			}
+		}
+	case http.MethodPost:
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLRequest))
+		decoder.UseNumber()
+		if err := decoder.Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, graphQLResponse{Errors: []graphQLError{{Message: "invalid request body"}}})
+			return
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	doc, err := parseDocument(req.Query)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, graphQLResponse{Errors: []graphQLError{{Message: err.Error()}}})
+		return
+	}
+
+	e := &executor{ctx: r.Context(), src: src, doc: doc}
+	data, err := e.run(req.OperationName, req.Variables)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, graphQLResponse{Errors: []graphQLError{{Message: err.Error()}}})
+		return
+	}
+	writeJSON(w, http.StatusOK, graphQLResponse{Data: data, Errors: e.errs})
+}
+
+////////////////// Parsing //////////////////
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// Splits a GraphQL document into tokens, dropping whitespace, commas and comments
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, token{tokenPunct, "...", i})
+			i += 3
+		case strings.IndexByte("!$&()=:@[]{}|", c) >= 0:
+			tokens = append(tokens, token{tokenPunct, string(c), i})
+			i++
+		case c == '_' || isLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenName, src[start:i], start})
+		case c == '-' || isDigit(c):
+			start, kind := i, tokenInt
+			if c == '-' {
+				i++
+			}
+			digits := i
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			if i == digits {
+				return nil, fmt.Errorf("syntax error at %d: invalid number", start)
+			}
+			if i < len(src) && src[i] == '.' {
+				kind, i = tokenFloat, i+1
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				kind, i = tokenFloat, i+1
+				if i < len(src) && (src[i] == '+' || src[i] == '-') {
+					i++
+				}
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind, src[start:i], start})
+		case strings.HasPrefix(src[i:], `"""`):
+			end := strings.Index(src[i+3:], `"""`)
+			if end < 0 {
+				return nil, fmt.Errorf("syntax error at %d: unterminated string", i)
+			}
+			tokens = append(tokens, token{tokenString, src[i+3 : i+3+end], i})
+			i += end + 6
+		case c == '"':
+			value, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("syntax error at %d: %w", i, err)
+			}
+			tokens = append(tokens, token{tokenString, value, i})
+			i += n
+		default:
+			return nil, fmt.Errorf("syntax error at %d: unexpected character %q", i, c)
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(src)}), nil
+}
+
+// Reads the quoted string starting src, returning its value and the length of its source
+func lexString(src string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\n', '\r':
+			return "", 0, errors.New("unterminated string")
+		case '\\':
+			if i+1 >= len(src) {
+				return "", 0, errors.New("unterminated string")
+			}
+			i++
+			switch src[i] {
+			case '"', '\\', '/':
+				b.WriteByte(src[i])
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				var r rune
+				if i+4 >= len(src) {
+					return "", 0, errors.New("invalid unicode escape")
+				}
+				if _, err := fmt.Sscanf(src[i+1:i+5], "%04x", &r); err != nil {
+					return "", 0, errors.New("invalid unicode escape")
+				}
+				b.WriteRune(r)
+				i += 4
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c", src[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("unterminated string")
+}
+
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+type document struct {
+	operations []*operation
+	fragments  map[string][]selection
+}
+
+type operation struct {
+	kind       string // query, mutation or subscription
+	name       string
+	variables  []variableDef
+	selections []selection
+}
+
+type variableDef struct {
+	name       string
+	nonNull    bool
+	value      any // Default value
+	hasDefault bool
+}
+
+// Field, fragment spread or inline fragment of a selection set
+type selection struct {
+	alias      string
+	name       string // Field name, empty for fragments
+	args       []argument
+	directives []directive
+	selections []selection
+	spread     string // Name of a spread fragment
+	inline     bool   // Inline fragment, its fields in selections
+}
+
+type argument struct {
+	name  string
+	value any
+}
+
+type directive struct {
+	name string
+	args []argument
+}
+
+// Reference to a variable in a value
+type variable string
+
+// Enum value, such as DAY
+type enumValue string
+
+type parser struct {
+	tokens  []token
+	i       int
+	depth   int // Selection sets, lists and input objects being parsed
+	aliases int
+}
+
+// Enters a nested selection set, list or input object, failing past maxQueryDepth. Returns the func leaving it
+func (p *parser) nest() (func(), error) {
+	if p.depth >= maxQueryDepth {
+		return nil, fmt.Errorf("query nested deeper than %d levels", maxQueryDepth)
+	}
+	p.depth++
+	return func() { p.depth-- }, nil
+}
+
+func parseDocument(src string) (*document, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, errors.New("no query given")
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	doc := &document{fragments: make(map[string][]selection)}
+
+	for p.peek().kind != tokenEOF {
+		switch t := p.peek(); {
+		case t.kind == tokenPunct && t.value == "{":
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: selections})
+		case t.kind == tokenName && t.value == "fragment":
+			p.next()
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.keyword("on"); err != nil {
+				return nil, err
+			}
+			if _, err := p.name(); err != nil {
+				return nil, err
+			}
+			if _, err := p.directives(); err != nil {
+				return nil, err
+			}
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[name]; ok {
+				return nil, fmt.Errorf("fragment %q defined more than once", name)
+			}
+			doc.fragments[name] = selections
+		case t.kind == tokenName && (t.value == "query" || t.value == "mutation" || t.value == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, errors.New("no operation in query")
+	}
+	return doc, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.i] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+// Consumes the punctuator value if it's next, reporting whether it was
+func (p *parser) punct(value string) bool {
+	if t := p.peek(); t.kind == tokenPunct && t.value == value {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(value string) error {
+	if !p.punct(value) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *parser) keyword(value string) error {
+	if t := p.peek(); t.kind != tokenName || t.value != value {
+		return p.unexpected()
+	}
+	p.i++
+	return nil
+}
+
+func (p *parser) name() (string, error) {
+	t := p.peek()
+	if t.kind != tokenName {
+		return "", p.unexpected()
+	}
+	p.i++
+	return t.value, nil
+}
+
+func (p *parser) unexpected() error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return errors.New("syntax error: unexpected end of query")
+	}
+	return fmt.Errorf("syntax error at %d: unexpected %q", t.pos, t.value)
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: p.next().value}
+	if p.peek().kind == tokenName {
+		op.name = p.next().value
+	}
+
+	if p.punct("(") {
+		for !p.punct(")") {
+			if err := p.expect("$"); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			def := variableDef{name: name}
+			if def.nonNull, err = p.typeRef(); err != nil {
+				return nil, err
+			}
+			if p.punct("=") {
+				if def.value, err = p.value(true); err != nil {
+					return nil, err
+				}
+				def.hasDefault = true
+			}
+			op.variables = append(op.variables, def)
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	op.selections, err = p.selectionSet()
+	return op, err
+}
+
+// Reads a type reference such as [String!]!, reporting whether it's non-null
+func (p *parser) typeRef() (bool, error) {
+	if p.punct("[") {
+		if _, err := p.typeRef(); err != nil {
+			return false, err
+		}
+		if err := p.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.name(); err != nil {
+		return false, err
+	}
+	return p.punct("!"), nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	leave, err := p.nest()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	var selections []selection
+	for !p.punct("}") {
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, s)
+	}
+	if len(selections) == 0 {
+		return nil, errors.New("syntax error: empty selection set")
+	}
+	return selections, nil
+}
+
+func (p *parser) selection() (selection, error) {
+	var s selection
+	var err error
+
+	if p.punct("...") {
+		if t := p.peek(); t.kind == tokenName && t.value != "on" {
+			s.spread = p.next().value
+			s.directives, err = p.directives()
+			return s, err
+		}
+		if p.peek().kind == tokenName {
+			p.next() // on
+			if _, err := p.name(); err != nil {
+				return s, err
+			}
+		}
+		s.inline = true
+		if s.directives, err = p.directives(); err != nil {
+			return s, err
+		}
+		s.selections, err = p.selectionSet()
+		return s, err
+	}
+
+	if s.name, err = p.name(); err != nil {
+		return s, err
+	}
+	if p.punct(":") {
+		if p.aliases++; p.aliases > maxAliases {
+			return s, fmt.Errorf("query has more than %d aliases", maxAliases)
+		}
+		s.alias = s.name
+		if s.name, err = p.name(); err != nil {
+			return s, err
+		}
+	}
+	if s.args, err = p.arguments(false); err != nil {
+		return s, err
+	}
+	if s.directives, err = p.directives(); err != nil {
+		return s, err
+	}
+	if t := p.peek(); t.kind == tokenPunct && t.value == "{" {
+		s.selections, err = p.selectionSet()
+	}
+	return s, err
+}
+
+func (p *parser) arguments(constant bool) ([]argument, error) {
+	if !p.punct("(") {
+		return nil, nil
+	}
+	var args []argument
+	for !p.punct(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.value(constant)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, argument{name: name, value: value})
+	}
+	return args, nil
+}
+
+func (p *parser) directives() ([]directive, error) {
+	var directives []directive
+	for p.punct("@") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments(false)
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, directive{name: name, args: args})
+	}
+	return directives, nil
+}
+
+// Reads a value. Constant values, such as variable defaults, can't refer to variables
+func (p *parser) value(constant bool) (any, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenInt:
+		p.next()
+		var n int64
+		if _, err := fmt.Sscan(t.value, &n); err != nil {
+			return nil, fmt.Errorf("invalid integer %s", t.value)
+		}
+		return n, nil
+	case tokenFloat:
+		p.next()
+		var f float64
+		if _, err := fmt.Sscan(t.value, &f); err != nil {
+			return nil, fmt.Errorf("invalid number %s", t.value)
+		}
+		return f, nil
+	case tokenString:
+		p.next()
+		return t.value, nil
+	case tokenName:
+		p.next()
+		switch t.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return enumValue(t.value), nil
+	}
+
+	if !constant && p.punct("$") {
+		name, err := p.name()
+		return variable(name), err
+	}
+
+	open := p.peek()
+	if open.kind != tokenPunct || (open.value != "[" && open.value != "{") {
+		return nil, p.unexpected()
+	}
+	leave, err := p.nest()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	switch {
+	case p.punct("["):
+		list := []any{}
+		for !p.punct("]") {
+			value, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case p.punct("{"):
+		object := map[string]any{}
+		for !p.punct("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if object[name], err = p.value(constant); err != nil {
+				return nil, err
+			}
+		}
+		return object, nil
+	}
+	return nil, p.unexpected()
+}
+
+////////////////// Execution //////////////////
+
+// Object in a result, with its fields resolved
+type object struct {
+	typename string
+	fields   map[string]any
+}
+
+// JSON object keeping its keys in the order set, as GraphQL results follow the order of the query
+type orderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: make(map[string]any)}
+}
+
+func (m *orderedMap) set(key string, value any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+type executor struct {
+	ctx  context.Context
+	src  *Source
+	doc  *document
+	vars map[string]any
+	errs []graphQLError
+}
+
+// Runs the operation named, or the only one, returning its data. Field errors are collected in e.errs, leaving the
+// field null, while errors in the request itself stop it
+func (e *executor) run(operationName string, variables map[string]any) (*orderedMap, error) {
+	var op *operation
+	for _, candidate := range e.doc.operations {
+		if operationName == "" || candidate.name == operationName {
+			if op != nil {
+				return nil, errors.New("several operations in query, set operationName")
+			}
+			op = candidate
+		}
+	}
+	if op == nil {
+		return nil, fmt.Errorf("no operation named %q", operationName)
+	}
+	if op.kind != "query" {
+		return nil, fmt.Errorf("%s operations aren't supported, the API is read-only", op.kind)
+	}
+
+	e.vars = make(map[string]any, len(op.variables))
+	for _, def := range op.variables {
+		value, ok := variables[def.name]
+		if !ok && def.hasDefault {
+			value = def.value
+		}
+		if value == nil && def.nonNull {
+			return nil, fmt.Errorf("variable $%s is required", def.name)
+		}
+		e.vars[def.name] = value
+	}
+
+	fields, err := e.collect(op.selections, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) > maxRootFields {
+		return nil, fmt.Errorf("operation selects more than %d root fields", maxRootFields)
+	}
+
+	data := newOrderedMap()
+	for _, field := range fields {
+		key := responseKey(field)
+		if field.name == "__typename" {
+			data.set(key, "Query")
+			continue
+		}
+		value, err := e.resolveRoot(field)
+		if err == nil {
+			value, err = e.complete(value, field)
+		}
+		if err != nil {
+			e.errs = append(e.errs, graphQLError{Message: err.Error(), Path: []any{key}})
+			value = nil
+		}
+		data.set(key, value)
+	}
+	return data, nil
+}
+
+func responseKey(field selection) string {
+	if field.alias != "" {
+		return field.alias
+	}
+	return field.name
+}
+
+// Flattens fragments into the fields they select, leaving out those @skip or @include drop
+func (e *executor) collect(selections []selection, visiting map[string]bool) ([]selection, error) {
+	var fields []selection
+	for _, s := range selections {
+		include, err := e.included(s.directives)
+		if err != nil {
+			return nil, err
+		}
+		if !include {
+			continue
+		}
+
+		switch {
+		case s.spread != "":
+			fragment, ok := e.doc.fragments[s.spread]
+			if !ok {
+				return nil, fmt.Errorf("unknown fragment %q", s.spread)
+			}
+			if visiting[s.spread] {
+				return nil, fmt.Errorf("fragment %q spreads itself", s.spread)
+			}
+			visiting[s.spread] = true
+			spread, err := e.collect(fragment, visiting)
+			delete(visiting, s.spread)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, spread...)
+		case s.inline:
+			inline, err := e.collect(s.selections, visiting)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, inline...)
+		default:
+			fields = append(fields, s)
+		}
+		if len(fields) > maxFieldsPerSet {
+			return nil, fmt.Errorf("selection selects more than %d fields", maxFieldsPerSet)
+		}
+	}
+	return fields, nil
+}
+
+// Evaluates @include and @skip
+func (e *executor) included(directives []directive) (bool, error) {
+	for _, d := range directives {
+		if d.name != "include" && d.name != "skip" {
+			return false, fmt.Errorf("unknown directive @%s", d.name)
+		}
+		if len(d.args) != 1 || d.args[0].name != "if" {
+			return false, fmt.Errorf("directive @%s takes one argument, if", d.name)
+		}
+		value, ok := e.value(d.args[0].value).(bool)
+		if !ok {
+			return false, fmt.Errorf("argument if of @%s must be a Boolean", d.name)
+		}
+		if value == (d.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Substitutes variables in a value
+func (e *executor) value(v any) any {
+	switch v := v.(type) {
+	case variable:
+		return e.vars[string(v)]
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = e.value(item)
+		}
+		return list
+	case map[string]any:
+		object := make(map[string]any, len(v))
+		for key, item := range v {
+			object[key] = e.value(item)
+		}
+		return object
+	}
+	return v
+}
+
+// Returns a field's arguments with variables substituted, failing on any not in allowed
+func (e *executor) arguments(field selection, typename string, allowed ...string) (map[string]any, error) {
+	args := make(map[string]any, len(field.args))
+	for _, arg := range field.args {
+		known := false
+		for _, name := range allowed {
+			known = known || name == arg.name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown argument %q on field %s.%s", arg.name, typename, field.name)
+		}
+		args[arg.name] = e.value(arg.value)
+	}
+	return args, nil
+}
+
+func (e *executor) resolveRoot(field selection) (any, error) {
+	switch field.name {
+	case "programs":
+		args, err := e.arguments(field, "Query", "name")
+		if err != nil {
+			return nil, err
+		}
+		name, err := stringArg(args, "name")
+		if err != nil {
+			return nil, err
+		}
+		programs, err := e.src.Programs(e.ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]object, len(programs))
+		for i, program := range programs {
+			list[i] = object{"Program", map[string]any{
+				"name":            program.Name,
+				"category":        optional(program.Category),
+				"project":         optional(program.Project),
+				"lifetimeSeconds": program.LifetimeSeconds,
+			}}
+		}
+		return list, nil
+
+	case "sessions":
+		args, err := e.arguments(field, "Query", "from", "to", "program", "device", "limit")
+		if err != nil {
+			return nil, err
+		}
+		from, to, err := e.window(args)
+		if err != nil {
+			return nil, err
+		}
+		q := SessionQuery{From: from, To: to}
+		if q.Program, err = stringArg(args, "program"); err != nil {
+			return nil, err
+		}
+		if q.Device, err = stringArg(args, "device"); err != nil {
+			return nil, err
+		}
+		if q.Limit, err = intArg(args, "limit"); err != nil {
+			return nil, err
+		}
+		sessions, err := e.src.Sessions(e.ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]object, len(sessions))
+		for i, session := range sessions {
+			list[i] = object{"Session", map[string]any{
+				"id":              session.ID,
+				"program":         session.Program,
+				"device":          optional(session.Device),
+				"start":           session.Start.Format(time.RFC3339),
+				"end":             session.End.Format(time.RFC3339),
+				"durationSeconds": session.DurationSeconds,
+			}}
+		}
+		return list, nil
+
+	case "active":
+		if _, err := e.arguments(field, "Query"); err != nil {
+			return nil, err
+		}
+		active, err := e.src.Active(e.ctx)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]object, len(active))
+		for i, session := range active {
+			list[i] = object{"ActiveSession", map[string]any{
+				"program":        session.Program,
+				"start":          session.Start.Format(time.RFC3339),
+				"elapsedSeconds": session.ElapsedSeconds,
+			}}
+		}
+		return list, nil
+
+	case "totals":
+		args, err := e.arguments(field, "Query", "from", "to", "by")
+		if err != nil {
+			return nil, err
+		}
+		from, to, err := e.window(args)
+		if err != nil {
+			return nil, err
+		}
+		by := ByProgram
+		switch value := args["by"].(type) {
+		case nil:
+		case enumValue:
+			by = strings.ToLower(string(value))
+		case string: // Enum values given in variables arrive as strings
+			by = strings.ToLower(value)
+		default:
+			return nil, errors.New("argument by must be a Grouping")
+		}
+		totals, err := e.src.Totals(e.ctx, from, to, by)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]object, len(totals))
+		for i, total := range totals {
+			list[i] = object{"Total", map[string]any{"key": total.Key, "seconds": total.Seconds}}
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("cannot query field %q on type Query", field.name)
+}
+
+func (e *executor) window(args map[string]any) (time.Time, time.Time, error) {
+	from, err := stringArg(args, "from")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := stringArg(args, "to")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return e.src.Window(from, to)
+}
+
+// Selects the fields of field's selection set from a resolved value
+func (e *executor) complete(value any, field selection) (any, error) {
+	switch value := value.(type) {
+	case []object:
+		list := make([]any, len(value))
+		for i, item := range value {
+			completed, err := e.complete(item, field)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = completed
+		}
+		return list, nil
+
+	case object:
+		if len(field.selections) == 0 {
+			return nil, fmt.Errorf("field %q of type %s must have a selection of subfields", field.name, value.typename)
+		}
+		subfields, err := e.collect(field.selections, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		result := newOrderedMap()
+		for _, sub := range subfields {
+			if sub.name == "__typename" {
+				result.set(responseKey(sub), value.typename)
+				continue
+			}
+			v, ok := value.fields[sub.name]
+			if !ok {
+				return nil, fmt.Errorf("cannot query field %q on type %s", sub.name, value.typename)
+			}
+			if len(sub.args) > 0 {
+				return nil, fmt.Errorf("unknown argument %q on field %s.%s", sub.args[0].name, value.typename, sub.name)
+			}
+			if len(sub.selections) > 0 {
+				return nil, fmt.Errorf("field %q of type %s has no subfields to select", sub.name, value.typename)
+			}
+			result.set(responseKey(sub), v)
+		}
+		return result, nil
+	}
+	return value, nil
+}
+
+func stringArg(args map[string]any, name string) (string, error) {
+	switch value := args[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	default:
+		return "", fmt.Errorf("argument %s must be a String", name)
+	}
+}
+
+func intArg(args map[string]any, name string) (int, error) {
+	switch value := args[name].(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return int(value), nil
+	case json.Number: // From variables
+		n, err := value.Int64()
+		if err != nil {
+			return 0, fmt.Errorf("argument %s must be an Int", name)
+		}
+		return int(n), nil
+	case float64:
+		if value == math.Trunc(value) {
+			return int(value), nil
+		}
+	}
+	return 0, fmt.Errorf("argument %s must be an Int", name)
+}
+
+// Null for an empty string
+func optional(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/repository"
+	mysql "github.com/jms-guy/timekeep/sql"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+// Returns a handler serving a test database tracking names, authorizing every request
+func testHandler(t *testing.T, names ...string) http.Handler {
+	db, err := mysql.OpenTestDatabase()
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	store := repository.NewSqliteStore(db)
+	for _, name := range names {
+		err := store.AddProgram(t.Context(), database.AddProgramParams{Name: name, Category: database.NullString("coding")})
+		assert.Nil(t, err)
+	}
+
+	handler, err := NewHandler(&Source{Store: store}, func(*http.Request) bool { return true })
+	if err != nil {
+		t.Fatalf("Failed to build handler: %v", err)
+	}
+	return handler
+}
+
+// Posts query to the GraphQL endpoint of handler, returning the status and decoded response
+func postQuery(t *testing.T, handler http.Handler, query string) (int, map[string]any) {
+	body, _ := json.Marshal(map[string]string{"query": query})
+	req := httptest.NewRequest(http.MethodPost, GraphQLPath, strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var resp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, resp
+}
+
+// Returns the message of the first error in resp
+func firstError(resp map[string]any) string {
+	errs, _ := resp["errors"].([]any)
+	if len(errs) == 0 {
+		return ""
+	}
+	first, _ := errs[0].(map[string]any)
+	message, _ := first["message"].(string)
+	return message
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	handler := testHandler(t, "code", "vim")
+
+	code, resp := postQuery(t, handler, `query { editors: programs { name category } active { program } }`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, firstError(resp))
+
+	data, _ := resp["data"].(map[string]any)
+	assert.Equal(t, []any{
+		map[string]any{"name": "code", "category": "coding"},
+		map[string]any{"name": "vim", "category": "coding"},
+	}, data["editors"])
+	assert.Equal(t, []any{}, data["active"])
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	handler := testHandler(t, "code")
+
+	aliased := func(n int) string {
+		fields := make([]string, n)
+		for i := range fields {
+			fields[i] = "p" + strings.Repeat("x", i) + ": programs { name }"
+		}
+		return "{ " + strings.Join(fields, " ") + " }"
+	}
+	// Root fields spread through a fragment, with no aliases
+	spread := "{ ...f } fragment f on Query { " + strings.Repeat("active { program } ", maxRootFields+1) + "}"
+	// Subfields spread through fragments doubling at each level
+	doubling := "{ programs { ...f8 } } fragment f0 on Program { name }"
+	for i := 1; i <= 8; i++ {
+		doubling += fmt.Sprintf(" fragment f%d on Program { ...f%d ...f%d }", i, i-1, i-1)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{name: "depth", query: strings.Repeat("{ programs ", maxQueryDepth) + "{ name }" + strings.Repeat(" }", maxQueryDepth), err: "nested deeper"},
+		{name: "list depth", query: `{ programs(name: ` + strings.Repeat("[", maxQueryDepth+1) + strings.Repeat("]", maxQueryDepth+1) + `) { name } }`, err: "nested deeper"},
+		{name: "aliases", query: aliased(maxAliases + 1), err: "aliases"},
+		{name: "root fields", query: aliased(maxRootFields + 1), err: "root fields"},
+		{name: "spread root fields", query: spread, err: "root fields"},
+		{name: "spread subfields", query: doubling, err: "fields"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, resp := postQuery(t, handler, tc.query)
+			message := firstError(resp)
+			if code == http.StatusOK {
+				// Limits on subfields surface as field errors, leaving the field null
+				data, _ := resp["data"].(map[string]any)
+				assert.Nil(t, data["programs"])
+			} else {
+				assert.Equal(t, http.StatusBadRequest, code)
+			}
+			assert.Contains(t, message, tc.err)
+		})
+	}
+
+	code, resp := postQuery(t, handler, aliased(maxRootFields))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, firstError(resp))
+}
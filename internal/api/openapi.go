@@ -0,0 +1,173 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAPI document of the endpoints, served at OpenAPIPath for generating clients. NewHandler routes the operations
+// it lists, and only those, checking their parameters against it
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+// Parts of an OpenAPI document the handler is built from
+type openAPISpec struct {
+	Security   []map[string][]string                `json:"security"`
+	Paths      map[string]map[string]*specOperation `json:"paths"`
+	Components struct {
+		Parameters map[string]specParameter `json:"parameters"`
+	} `json:"components"`
+}
+
+type specOperation struct {
+	OperationID string                 `json:"operationId"`
+	Parameters  []specParameter        `json:"parameters"`
+	Security    *[]map[string][]string `json:"security"` // Overrides the document's, empty for public operations
+}
+
+type specParameter struct {
+	Ref      string     `json:"$ref"`
+	Name     string     `json:"name"`
+	In       string     `json:"in"`
+	Required bool       `json:"required"`
+	Schema   specSchema `json:"schema"`
+}
+
+type specSchema struct {
+	Type    string   `json:"type"`
+	Enum    []string `json:"enum"`
+	Minimum *int64   `json:"minimum"`
+	Maximum *int64   `json:"maximum"`
+}
+
+// Operation of the document, with its parameters resolved
+type route struct {
+	method     string
+	path       string
+	id         string
+	parameters []specParameter
+	public     bool
+}
+
+// Reads the routes of an OpenAPI document, in order of path and method
+func loadSpec(doc []byte) ([]route, error) {
+	var spec openAPISpec
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		return nil, fmt.Errorf("error reading OpenAPI document: %w", err)
+	}
+
+	var routes []route
+	for path, methods := range spec.Paths {
+		for method, op := range methods {
+			if op.OperationID == "" {
+				return nil, fmt.Errorf("operation %s %s has no operationId", method, path)
+			}
+			security := spec.Security
+			if op.Security != nil {
+				security = *op.Security
+			}
+
+			rt := route{method: strings.ToUpper(method), path: path, id: op.OperationID, public: len(security) == 0}
+			for _, param := range op.Parameters {
+				if ref := param.Ref; ref != "" {
+					var ok bool
+					if param, ok = spec.Components.Parameters[strings.TrimPrefix(ref, "#/components/parameters/")]; !ok {
+						return nil, fmt.Errorf("operation %s refers to unknown parameter %s", op.OperationID, ref)
+					}
+				}
+				if param.In != "query" {
+					return nil, fmt.Errorf("parameter %s of operation %s is in %s, only query parameters are supported", param.Name, op.OperationID, param.In)
+				}
+				rt.parameters = append(rt.parameters, param)
+			}
+			routes = append(routes, rt)
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].path != routes[j].path {
+			return routes[i].path < routes[j].path
+		}
+		return routes[i].method < routes[j].method
+	})
+	return routes, nil
+}
+
+// Routes each operation of the OpenAPI document to its handler, by operationId, checking the request's parameters and
+// authorization first. Fails if an operation has no handler or a handler no operation, so the two can't drift apart
+func routeSpec(mux *http.ServeMux, doc []byte, handlers map[string]http.HandlerFunc, authorize func(*http.Request) bool) error {
+	routes, err := loadSpec(doc)
+	if err != nil {
+		return err
+	}
+
+	routed := make(map[string]bool, len(routes))
+	for _, rt := range routes {
+		handler, ok := handlers[rt.id]
+		if !ok {
+			return fmt.Errorf("no handler for operation %s", rt.id)
+		}
+		routed[rt.id] = true
+
+		mux.HandleFunc(rt.method+" "+rt.path, func(w http.ResponseWriter, r *http.Request) {
+			if !rt.public && !authorize(r) {
+				writeError(w, http.StatusUnauthorized, "missing or invalid API token")
+				return
+			}
+			if err := checkParameters(rt.parameters, r); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			handler(w, r)
+		})
+	}
+	for id := range handlers {
+		if !routed[id] {
+			return fmt.Errorf("handler for operation %s missing from the OpenAPI document", id)
+		}
+	}
+	return nil
+}
+
+// Checks a request's query against the parameters of its operation, refusing any it doesn't declare
+func checkParameters(params []specParameter, r *http.Request) error {
+	query := r.URL.Query()
+	for name := range query {
+		if !slices.ContainsFunc(params, func(p specParameter) bool { return p.Name == name }) {
+			return fmt.Errorf("unknown parameter %q", name)
+		}
+	}
+
+	for _, param := range params {
+		value := query.Get(param.Name)
+		if value == "" {
+			if param.Required {
+				return fmt.Errorf("missing parameter %q", param.Name)
+			}
+			continue
+		}
+
+		if param.Schema.Type == "integer" {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q, must be an integer", param.Name, value)
+			}
+			if minimum := param.Schema.Minimum; minimum != nil && n < *minimum {
+				return fmt.Errorf("invalid %s %d, must be at least %d", param.Name, n, *minimum)
+			}
+			if maximum := param.Schema.Maximum; maximum != nil && n > *maximum {
+				return fmt.Errorf("invalid %s %d, must be at most %d", param.Name, n, *maximum)
+			}
+		}
+		if len(param.Schema.Enum) > 0 && !slices.Contains(param.Schema.Enum, value) {
+			return fmt.Errorf("invalid %s %q, use %s", param.Name, value, strings.Join(param.Schema.Enum, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+// Package charts renders recorded time as SVG or PNG images, drawn with go-chart's renderers, for embedding in notes
+// and reports
+package charts
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang/freetype/truetype"
+	chart "github.com/wcharczuk/go-chart/v2"
+	"github.com/wcharczuk/go-chart/v2/drawing"
+)
+
+// Image formats charts are rendered as
+const (
+	FormatSVG = "svg"
+	FormatPNG = "png"
+)
+
+// Layout of timeline charts, in pixels
+const (
+	timelineWidth = 1200
+	labelWidth    = 180
+	laneHeight    = 26
+	barHeight     = 18
+	headerHeight  = 56
+	footerHeight  = 44
+	margin        = 16
+	minTickWidth  = 40
+)
+
+var (
+	textColor = drawing.ColorFromHex("333333")
+	gridColor = drawing.ColorFromHex("E5E5E5")
+)
+
+// Sessions of one program, drawn as a row of bars
+type Lane struct {
+	Label string // Shown left of the row, such as the program name
+	Group string // Bars of lanes in the same group share a color, such as the program's category
+	Spans []Span // Sessions shown, cut to the chart's span
+}
+
+// Time a session ran
+type Span struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Returns the format of an image file by its extension, svg unless it's .png
+func FormatOf(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".png") {
+		return FormatPNG
+	}
+	return FormatSVG
+}
+
+// Draws lanes as a Gantt-style chart of from to to, with a tick on the hour in the location of from and a legend of
+// their groups, writing it to w as an SVG or PNG image
+func Timeline(w io.Writer, format, title string, from, to time.Time, lanes []Lane) error {
+	if !to.After(from) {
+		return fmt.Errorf("timeline ends before it starts")
+	}
+
+	height := headerHeight + max(len(lanes), 1)*laneHeight + footerHeight
+	r, err := renderer(format, timelineWidth, height)
+	if err != nil {
+		return err
+	}
+	font, err := chart.GetDefaultFont()
+	if err != nil {
+		return fmt.Errorf("error loading font: %w", err)
+	}
+
+	fill(r, chart.Box{Right: timelineWidth, Bottom: height}, drawing.ColorWhite)
+	text(r, font, 14, textColor, escape(format, title), margin, margin+14)
+
+	left, right := labelWidth, timelineWidth-margin
+	x := func(t time.Time) int {
+		return left + int(float64(right-left)*t.Sub(from).Seconds()/to.Sub(from).Seconds())
+	}
+
+	// Ticks every hour, or every few hours when they'd be too close
+	step := time.Hour
+	for step < to.Sub(from) && x(from.Add(step))-left < minTickWidth {
+		step *= 2
+	}
+	top, bottom := headerHeight, headerHeight+max(len(lanes), 1)*laneHeight
+	first := time.Date(from.Year(), from.Month(), from.Day(), from.Hour(), 0, 0, 0, from.Location())
+	for tick := first; !tick.After(to); tick = tick.Add(step) {
+		if tick.Before(from) {
+			continue
+		}
+		tx := x(tick)
+		fill(r, chart.Box{Left: tx, Right: tx + 1, Top: top - 4, Bottom: bottom}, gridColor)
+		if tick.Before(to) {
+			text(r, font, 9, textColor, tick.Format("15:04"), tx-12, top-8)
+		}
+	}
+
+	colors := map[string]drawing.Color{}
+	var groups []string
+	for i, lane := range lanes {
+		color, ok := colors[lane.Group]
+		if !ok {
+			color = chart.GetDefaultColor(len(groups))
+			colors[lane.Group] = color
+			groups = append(groups, lane.Group)
+		}
+
+		y := top + i*laneHeight
+		text(r, font, 10, textColor, escape(format, clip(r, font, lane.Label, labelWidth-2*margin)), margin, y+laneHeight/2+4)
+		for _, span := range lane.Spans {
+			start, end := span.Start, span.End
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			if !end.After(start) {
+				continue
+			}
+			fill(r, chart.Box{Left: x(start), Right: max(x(end), x(start)+1), Top: y + (laneHeight-barHeight)/2, Bottom: y + (laneHeight+barHeight)/2}, color)
+		}
+	}
+
+	legendX := left
+	for _, group := range groups {
+		if group == "" {
+			continue
+		}
+		fill(r, chart.Box{Left: legendX, Right: legendX + 10, Top: bottom + 18, Bottom: bottom + 28}, colors[group])
+		text(r, font, 10, textColor, escape(format, group), legendX+14, bottom+27)
+		legendX += 14 + measure(r, font, 10, group) + 20
+	}
+
+	if err := r.Save(w); err != nil {
+		return fmt.Errorf("error writing chart: %w", err)
+	}
+	return nil
+}
+
+func renderer(format string, width, height int) (chart.Renderer, error) {
+	provider, err := rendererProvider(format)
+	if err != nil {
+		return nil, err
+	}
+	return provider(width, height)
+}
+
+func fill(r chart.Renderer, box chart.Box, color drawing.Color) {
+	chart.Draw.Box(r, box, chart.Style{FillColor: color, StrokeColor: color, StrokeWidth: 0})
+}
+
+func text(r chart.Renderer, font *truetype.Font, size float64, color drawing.Color, body string, x, y int) {
+	chart.Draw.Text(r, body, x, y, chart.Style{Font: font, FontSize: size, FontColor: color})
+}
+
+func measure(r chart.Renderer, font *truetype.Font, size float64, body string) int {
+	return chart.Draw.MeasureText(r, body, chart.Style{Font: font, FontSize: size}).Width()
+}
+
+// Shortens body with an ellipsis until it fits in width
+func clip(r chart.Renderer, font *truetype.Font, body string, width int) string {
+	if measure(r, font, 10, body) <= width {
+		return body
+	}
+	runes := []rune(body)
+	for len(runes) > 1 && measure(r, font, 10, string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// Value of a bar or pie slice
+type Value struct {
+	Label string
+	Hours float64
+}
+
+// Series of a trend chart, hours on each of its days
+type Series struct {
+	Name  string
+	Hours []float64
+}
+
+// Layout of bar, pie and trend charts, in pixels
+const (
+	chartWidth  = 900
+	chartHeight = 360
+	maxBarWidth = 96
+	maxTicks    = 14
+)
+
+// Draws values as a bar chart of hours, writing it to w as an SVG or PNG image
+func Bars(w io.Writer, format, title string, values []Value) error {
+	provider, err := rendererProvider(format)
+	if err != nil {
+		return err
+	}
+
+	// Bars share one color and rise from zero, rather than from the smallest value
+	color := chart.GetDefaultColor(0)
+	bars := make([]chart.Value, len(values))
+	var peak float64
+	for i, v := range values {
+		bars[i] = chart.Value{Label: escape(format, v.Label), Value: v.Hours, Style: chart.Style{FillColor: color, StrokeColor: color}}
+		peak = max(peak, v.Hours)
+	}
+	spacing := 8
+	graph := chart.BarChart{
+		Title:        escape(format, title),
+		Width:        chartWidth,
+		Height:       chartHeight,
+		Background:   chart.Style{Padding: chart.Box{Top: 40, Left: 16, Right: 16, Bottom: 16}},
+		BarWidth:     min(maxBarWidth, max((chartWidth-120)/max(len(bars), 1)-spacing, 4)),
+		BarSpacing:   spacing,
+		UseBaseValue: true,
+		YAxis:        chart.YAxis{ValueFormatter: hoursFormatter, Range: &chart.ContinuousRange{Max: max(peak, 1)}},
+		Bars:         bars,
+	}
+	if err := graph.Render(provider, w); err != nil {
+		return fmt.Errorf("error rendering chart: %w", err)
+	}
+	return nil
+}
+
+// Draws values as a pie chart of their shares, writing it to w as an SVG or PNG image
+func Pie(w io.Writer, format, title string, values []Value) error {
+	provider, err := rendererProvider(format)
+	if err != nil {
+		return err
+	}
+
+	pieces := make([]chart.Value, len(values))
+	for i, v := range values {
+		pieces[i] = chart.Value{Label: escape(format, v.Label), Value: v.Hours}
+	}
+	// go-chart draws a pie's title over its slices, so it's drawn above them instead
+	width := chartHeight * 3 / 2
+	graph := chart.PieChart{
+		Width:      width,
+		Height:     chartHeight,
+		Background: chart.Style{Padding: chart.Box{Top: 48, Left: 16, Right: 16, Bottom: 16}},
+		Values:     pieces,
+		Elements: []chart.Renderable{func(r chart.Renderer, _ chart.Box, defaults chart.Style) {
+			chart.Draw.TextWithin(r, escape(format, title), chart.Box{Top: 16, Right: width, Bottom: 48}, chart.Style{
+				Font:                defaults.Font,
+				FontSize:            chart.DefaultTitleFontSize,
+				FontColor:           chart.DefaultTextColor,
+				TextHorizontalAlign: chart.TextHorizontalAlignCenter,
+			})
+		}},
+	}
+	if err := graph.Render(provider, w); err != nil {
+		return fmt.Errorf("error rendering chart: %w", err)
+	}
+	return nil
+}
+
+// Draws series as lines of hours over days, with a legend of their names, writing it to w as an SVG or PNG image.
+// Needs at least two days
+func Trend(w io.Writer, format, title string, days []time.Time, series []Series) error {
+	provider, err := rendererProvider(format)
+	if err != nil {
+		return err
+	}
+
+	graph := chart.Chart{
+		Title:      escape(format, title),
+		Width:      chartWidth,
+		Height:     chartHeight,
+		Background: chart.Style{Padding: chart.Box{Top: 40, Left: 16, Right: 16, Bottom: 16}},
+		XAxis:      chart.XAxis{Ticks: dayTicks(days)},
+		YAxis:      chart.YAxis{ValueFormatter: hoursFormatter},
+	}
+	var peak float64
+	for _, s := range series {
+		peak = max(peak, slices.Max(s.Hours))
+	}
+	graph.YAxis.Range = &chart.ContinuousRange{Max: max(peak, 1)}
+	for _, s := range series {
+		graph.Series = append(graph.Series, chart.TimeSeries{Name: escape(format, s.Name), XValues: days, YValues: s.Hours})
+	}
+	graph.Elements = []chart.Renderable{chart.Legend(&graph)}
+	if err := graph.Render(provider, w); err != nil {
+		return fmt.Errorf("error rendering chart: %w", err)
+	}
+	return nil
+}
+
+// Draws values as a line of scores over days, such as the daily productivity score, writing it to w as an SVG or PNG
+// image. The axis spans the values, taking in zero, so negative scores show below it. Needs at least two days
+func Scores(w io.Writer, format, title string, days []time.Time, values []float64) error {
+	provider, err := rendererProvider(format)
+	if err != nil {
+		return err
+	}
+
+	low, high := min(slices.Min(values), 0), max(slices.Max(values), 0)
+	if high == low {
+		high = low + 1
+	}
+	graph := chart.Chart{
+		Title:      escape(format, title),
+		Width:      chartWidth,
+		Height:     chartHeight,
+		Background: chart.Style{Padding: chart.Box{Top: 40, Left: 16, Right: 16, Bottom: 16}},
+		XAxis:      chart.XAxis{Ticks: dayTicks(days)},
+		YAxis:      chart.YAxis{ValueFormatter: scoreFormatter, Range: &chart.ContinuousRange{Min: low, Max: high}},
+		Series:     []chart.Series{chart.TimeSeries{XValues: days, YValues: values}},
+	}
+	if err := graph.Render(provider, w); err != nil {
+		return fmt.Errorf("error rendering chart: %w", err)
+	}
+	return nil
+}
+
+// Ticks of one day each, or every few days so no more than maxTicks are labeled
+func dayTicks(days []time.Time) []chart.Tick {
+	step := (len(days) + maxTicks - 1) / maxTicks
+	var ticks []chart.Tick
+	for i := 0; i < len(days); i += max(step, 1) {
+		ticks = append(ticks, chart.Tick{Value: chart.TimeToFloat64(days[i]), Label: days[i].Format("Jan 2")})
+	}
+	return ticks
+}
+
+// Escapes text for SVG, which go-chart writes as is
+func escape(format, body string) string {
+	if format == FormatSVG {
+		return html.EscapeString(body)
+	}
+	return body
+}
+
+func hoursFormatter(v any) string {
+	if hours, ok := v.(float64); ok {
+		return fmt.Sprintf("%.1fh", hours)
+	}
+	return ""
+}
+
+func scoreFormatter(v any) string {
+	if score, ok := v.(float64); ok {
+		return fmt.Sprintf("%.1f", score)
+	}
+	return ""
+}
+
+func rendererProvider(format string) (chart.RendererProvider, error) {
+	switch format {
+	case FormatSVG:
+		return chart.SVG, nil
+	case FormatPNG:
+		return chart.PNG, nil
+	}
+	return nil, fmt.Errorf("unknown image format %q, use svg or png", format)
+}
@@ -5,15 +5,255 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/jms-guy/timekeep/internal/dates"
 )
 
 // Main user configuration struct
 type Config struct {
-	WakaTime     WakaTimeConfig `json:"wakatime"`                // WakaTime integration variables
-	Wakapi       WakapiConfig   `json:"wakapi"`                  // Wakapi integration variables
-	PollInterval string         `json:"poll_interval,omitempty"` // Linux - monitor polling interval, default 1s
-	PollGrace    int            `json:"poll_grace,omitempty"`    // Linux - number representing the grace period granted to PIDs accidently missed by polling, default 3
+	Version      int                `json:"version"`                 // Layout version of this file, upgraded on load when older
+	WakaTime     WakaTimeConfig     `json:"wakatime"`                // WakaTime integration variables
+	Wakapi       WakapiConfig       `json:"wakapi"`                  // Wakapi integration variables
+	PollInterval string             `json:"poll_interval,omitempty"` // Linux - monitor polling interval, default 1s
+	PollGrace    int                `json:"poll_grace,omitempty"`    // Linux - number representing the grace period granted to PIDs accidently missed by polling, default 3
+	Timezone     string             `json:"timezone,omitempty"`      // IANA timezone used for display and day boundaries, default system
+	Device       string             `json:"device,omitempty"`        // Label recorded on sessions to identify this machine, default hostname
+	DBTimeout    string             `json:"db_timeout,omitempty"`    // Deadline for each database operation, default 10s
+	UndoWindow   string             `json:"undo_window,omitempty"`   // How long the last reset or remove can be undone, default 24h
+	MaxSession   string             `json:"max_session,omitempty"`   // Length at which a running session is split in two, disabled if unset
+	Scope        string             `json:"scope,omitempty"`         // Whose processes are tracked: user (the service's own) or shared (everyone's), default user
+	Display      DisplayConfig      `json:"display"`                 // Date and time formatting of CLI output
+	Log          LogConfig          `json:"log"`                     // Service logging settings
+	Remote       RemoteConfig       `json:"remote"`                  // TLS listener for remote CLI access
+	Browser      BrowserConfig      `json:"browser"`                 // Loopback listener for the browser extension
+	Editor       EditorConfig       `json:"editor"`                  // Loopback WakaTime-compatible heartbeat receiver for editor plugins
+	API          APIConfig          `json:"api"`                     // Loopback REST and GraphQL listener serving tracking data
+	Server       ServerConfig       `json:"server"`                  // Listener of "timekeep server", accepting sessions from other machines
+	Sync         SyncConfig         `json:"sync"`                    // Sync server this machine pushes sessions to and pulls them from
+	Team         TeamConfig         `json:"team"`                    // Opt-in reporting of daily totals per category to a team endpoint
+	Kimai        KimaiConfig        `json:"kimai"`                   // Kimai server completed sessions are pushed to as timesheets
+	Harvest      HarvestConfig      `json:"harvest"`                 // Harvest account completed sessions are pushed to as time entries
+	Privacy      PrivacyConfig      `json:"privacy"`                 // How program names are stored at rest
+	Policy       PolicyConfig       `json:"policy"`                  // Centrally managed exclusions and category mappings
+	Limits       LimitsConfig       `json:"limits"`                  // Daily time limits per program, and what happens past them
+	Goals        GoalsConfig        `json:"goals"`                   // Daily time goals per category
+	Focus        FocusConfig        `json:"focus"`                   // Parameters of the daily focus score
+	Productivity ProductivityConfig `json:"productivity"`            // Productive, neutral or distracting ratings of programs and categories
+	Workday      WorkdayConfig      `json:"workday"`                 // Hours and weekdays worked, for the untracked time report
+	Rounding     RoundingConfig     `json:"rounding"`                // Rounding of time in timesheets and billing exports, raw data staying exact
+	Prompt       PromptConfig       `json:"prompt"`                  // What "timekeep prompt" shows in a shell prompt
+	Workspaces   WorkspaceConfig    `json:"workspaces"`              // Recording of the workspace in use during sessions, and its labels
+	Digest       DigestConfig       `json:"digest"`                  // Weekly summary sent as a desktop notification or email
+	Webhooks     []WebhookConfig    `json:"webhooks,omitempty"`      // Endpoints sent session events as they happen
+	Debug        DebugConfig        `json:"debug"`                   // Diagnostics HTTP listener
+
+	unknown []Problem // Keys in the file that match no field, reported by Validate
+	upgrade *Upgrade  // Layout upgrade applied when the file was loaded
+}
+
+type DisplayConfig struct {
+	WeekStart     string `json:"week_start,omitempty"`     // First day of the week for week ranges, default monday
+	Clock         string `json:"clock,omitempty"`          // 24h or 12h, default 24h
+	DateFormat    string `json:"date_format,omitempty"`    // Date layout built from YYYY, MM and DD, default YYYY-MM-DD
+	Relative      bool   `json:"relative,omitempty"`       // Show session times as "2h ago" rather than timestamps
+	ExcludeActive bool   `json:"exclude_active,omitempty"` // Count only completed sessions in lifetimes, leaving out running ones
+}
+
+type DebugConfig struct {
+	Listen string `json:"listen,omitempty"` // Loopback address serving pprof and session internals, disabled if unset
+}
+
+type BrowserConfig struct {
+	Enabled     bool              `json:"enabled"`                // Accept active tab reports from the browser extension
+	Listen      string            `json:"listen,omitempty"`       // Loopback address to listen on, default 127.0.0.1:7781
+	Token       string            `json:"token,omitempty"`        // Token the extension must send as a bearer token
+	IdleTimeout string            `json:"idle_timeout,omitempty"` // How long without a report before the site's session ends, default 2m
+	Categories  map[string]string `json:"categories,omitempty"`   // Category given to each site's program when first seen, by domain
+}
+
+type EditorConfig struct {
+	Enabled     bool   `json:"enabled"`                // Accept heartbeats from WakaTime editor plugins
+	Listen      string `json:"listen,omitempty"`       // Loopback address to listen on, default 127.0.0.1:7782
+	APIKey      string `json:"api_key,omitempty"`      // Key the plugins must send, set as api_key in their WakaTime config
+	IdleTimeout string `json:"idle_timeout,omitempty"` // How long without a heartbeat before the project's session ends, default 15m
+}
+
+type APIConfig struct {
+	Enabled bool   `json:"enabled"`          // Serve tracking data to clients sending an API token with read or admin scope
+	Listen  string `json:"listen,omitempty"` // Loopback address to listen on, default 127.0.0.1:7783
+}
+
+type ServerConfig struct {
+	Listen   string `json:"listen,omitempty"`    // Address to listen on, default :7790
+	CertFile string `json:"cert_file,omitempty"` // TLS certificate, plain HTTP if unset
+	KeyFile  string `json:"key_file,omitempty"`  // TLS private key for cert_file
+}
+
+type SyncConfig struct {
+	Server   string `json:"server,omitempty"`   // Sync server URL, such as https://hub:7790, sync disabled if unset
+	Token    string `json:"token,omitempty"`    // API token of the sync scope created on the server
+	CAFile   string `json:"ca_file,omitempty"`  // PEM certificate to trust for the server, system roots if unset
+	Interval string `json:"interval,omitempty"` // How often the service syncs in the background, only on demand if unset
+}
+
+type TeamConfig struct {
+	Enabled    bool     `json:"enabled"`              // Report each completed day's totals per category
+	Endpoint   string   `json:"endpoint,omitempty"`   // URL the daily reports are posted to
+	Token      string   `json:"token,omitempty"`      // Sent as a bearer token, if set
+	Categories []string `json:"categories,omitempty"` // Categories reported by name, others are reported as "other". All if unset
+}
+
+type KimaiConfig struct {
+	Enabled     bool           `json:"enabled"`                // Push completed sessions to Kimai as timesheets
+	Server      string         `json:"server,omitempty"`       // Kimai address, such as https://kimai.example.com
+	Token       string         `json:"token,omitempty"`        // API token of the Kimai user the timesheets are recorded for
+	Project     int64          `json:"project,omitempty"`      // Kimai project ID of sessions no mapping gives one
+	Activity    int64          `json:"activity,omitempty"`     // Kimai activity ID of sessions no mapping gives one
+	Mappings    []KimaiMapping `json:"mappings,omitempty"`     // Kimai project and activity per program, category or project, first match first
+	MinDuration string         `json:"min_duration,omitempty"` // Only sessions at least this long
+	Interval    string         `json:"interval,omitempty"`     // How often the service pushes, default 15m
+}
+
+// Sessions picked by their program, matching every filter set, for mapping them to another time tracker
+type SessionFilter struct {
+	Programs   []string `json:"programs,omitempty"`   // Programs matching these patterns
+	Categories []string `json:"categories,omitempty"` // Programs in these categories
+	Projects   []string `json:"projects,omitempty"`   // Programs in these timekeep projects
+}
+
+// Kimai project and activity of the sessions the filter picks. Sessions left without a project or activity aren't
+// pushed
+type KimaiMapping struct {
+	SessionFilter
+	Project  int64 `json:"project,omitempty"`  // Kimai project ID, kimai.project if unset
+	Activity int64 `json:"activity,omitempty"` // Kimai activity ID, kimai.activity if unset
+}
+
+type HarvestConfig struct {
+	Enabled     bool             `json:"enabled"`                // Create Harvest time entries from completed sessions
+	AccountID   string           `json:"account_id,omitempty"`   // Harvest account ID, shown with the personal access token
+	Token       string           `json:"token,omitempty"`        // Harvest personal access token
+	Server      string           `json:"server,omitempty"`       // Harvest API address, default https://api.harvestapp.com
+	Mode        string           `json:"mode,omitempty"`         // sessions (default), an entry per session, or daily, an entry per day and task
+	Project     int64            `json:"project,omitempty"`      // Harvest project ID of sessions no mapping gives one
+	Task        int64            `json:"task,omitempty"`         // Harvest task ID of sessions no mapping gives one
+	Mappings    []HarvestMapping `json:"mappings,omitempty"`     // Harvest project and task per program, category or project, first match first
+	MinDuration string           `json:"min_duration,omitempty"` // Only sessions at least this long
+	Interval    string           `json:"interval,omitempty"`     // How often the service pushes, default 1h
+}
+
+// Harvest project and task of the sessions the filter picks. Sessions left without a project or task aren't pushed
+type HarvestMapping struct {
+	SessionFilter
+	Project int64 `json:"project,omitempty"` // Harvest project ID, harvest.project if unset
+	Task    int64 `json:"task,omitempty"`    // Harvest task ID, harvest.task if unset
+}
+
+type PrivacyConfig struct {
+	HashNames bool `json:"hash_names"` // Store salted hashes of program names, mapped back to names from a local file
+}
+
+type PolicyConfig struct {
+	Source   string `json:"source,omitempty"`   // URL or file path of the managed policy file, no policy if unset
+	Interval string `json:"interval,omitempty"` // How often the service fetches the policy, default 1h
+}
+
+type LimitsConfig struct {
+	Daily   map[string]string `json:"daily,omitempty"`   // Daily limit per program name or group:<name> program group, such as "2h"
+	Enforce string            `json:"enforce,omitempty"` // What happens past a limit: warn (default) or kill new instances
+}
+
+type GoalsConfig struct {
+	Daily map[string]string `json:"daily,omitempty"` // Time to spend each day per category or group:<name> program group, such as "2h"
+}
+
+type FocusConfig struct {
+	MinBlock    string `json:"min_block,omitempty"`    // Uninterrupted time in one category counted as focused, default 25m
+	MergeGap    string `json:"merge_gap,omitempty"`    // Break within one category that doesn't end its block, default 5m
+	SwitchLimit int    `json:"switch_limit,omitempty"` // Context switches per hour at which switching scores nothing, default 6
+	BlockWeight int    `json:"block_weight,omitempty"` // Percent of the score from time in focused blocks, the rest from switches, default 50
+}
+
+type ProductivityConfig struct {
+	Programs   map[string]string  `json:"programs,omitempty"`   // Rating per program name, over that of its category
+	Categories map[string]string  `json:"categories,omitempty"` // Rating per category, such as "coding": "productive"
+	Scale      map[string]int     `json:"scale,omitempty"`      // Pulse score from 0 to 100 of each rating, default productive 100, neutral 50 and distracting 0
+	Weights    map[string]float64 `json:"weights,omitempty"`    // Weight per category of each hour spent in it, for the daily score, default 0
+	Formula    string             `json:"formula,omitempty"`    // Daily score computed from the day's hours, such as "weighted / total * 10", default "weighted"
+}
+
+type RoundingConfig struct {
+	Increment string `json:"increment,omitempty"` // Step time is rounded to, such as 6m or 15m, unset for exact time
+	Mode      string `json:"mode,omitempty"`      // nearest (default), up or down
+	Per       string `json:"per,omitempty"`       // entry (default), rounding each session, or day, rounding each day's total
+}
+
+type WorkdayConfig struct {
+	Start string   `json:"start,omitempty"` // Time of day work starts, HH:MM, default 09:00
+	End   string   `json:"end,omitempty"`   // Time of day work ends, HH:MM, default 17:00
+	Days  []string `json:"days,omitempty"`  // Weekdays worked, default monday to friday
+}
+
+type WorkspaceConfig struct {
+	Track  bool              `json:"track"`            // Linux - record the workspace or virtual desktop in use while sessions run
+	Labels map[string]string `json:"labels,omitempty"` // Label per workspace name reported by the desktop, such as "1": "work", used by reports
+}
+
+type PromptConfig struct {
+	Show   string `json:"show,omitempty"`   // Metric shown: session (the running one, default), today (total) or goal (progress)
+	Budget string `json:"budget,omitempty"` // Longest the prompt may wait for the database before showing cached or no output, default 50ms
+}
+
+type DigestConfig struct {
+	Enabled bool        `json:"enabled"`        // Send a summary of the previous week once a week
+	Day     string      `json:"day,omitempty"`  // Weekday the digest is sent on, default monday
+	Time    string      `json:"time,omitempty"` // Time of day the digest is sent at, HH:MM, default 09:00
+	Desktop bool        `json:"desktop"`        // Show the digest as a desktop notification
+	Email   EmailConfig `json:"email"`          // Mail the digest, when smtp is set
+}
+
+type EmailConfig struct {
+	SMTP     string   `json:"smtp,omitempty"`     // SMTP server, host:port
+	Username string   `json:"username,omitempty"` // SMTP login, no authentication if unset
+	Password string   `json:"password,omitempty"` // SMTP password
+	From     string   `json:"from,omitempty"`     // Sender address
+	To       []string `json:"to,omitempty"`       // Recipient addresses
+}
+
+type WebhookConfig struct {
+	Name        string            `json:"name,omitempty"`         // Label used in logs
+	URL         string            `json:"url"`                    // Endpoint the events are sent to
+	Method      string            `json:"method,omitempty"`       // POST, PUT or PATCH, default POST
+	Headers     map[string]string `json:"headers,omitempty"`      // Headers sent with each event, such as Authorization
+	Template    string            `json:"template,omitempty"`     // Go template of the body, given the event, default the event as JSON
+	Format      string            `json:"format,omitempty"`       // Body sent without a template: event (default), zapier or ifttt
+	Events      []string          `json:"events,omitempty"`       // Events sent: session.start and session.end, default both
+	Programs    []string          `json:"programs,omitempty"`     // Only events of programs matching these patterns
+	Categories  []string          `json:"categories,omitempty"`   // Only events of programs in these categories
+	MinDuration string            `json:"min_duration,omitempty"` // Only ended sessions at least this long
+}
+
+type RemoteConfig struct {
+	Enabled  bool   `json:"enabled"`             // Listen for remote CLI connections
+	Listen   string `json:"listen,omitempty"`    // Address to listen on, default :7780
+	Token    string `json:"token,omitempty"`     // Token remote clients must present
+	CertFile string `json:"cert_file,omitempty"` // TLS certificate, a self-signed one is generated if unset
+	KeyFile  string `json:"key_file,omitempty"`  // TLS private key for cert_file
+}
+
+type LogConfig struct {
+	Level      string `json:"level,omitempty"`        // Minimum level logged: debug, info, warn or error, default info
+	Format     string `json:"format,omitempty"`       // Log record format: text or json, default text
+	File       bool   `json:"file,omitempty"`         // Linux - write to a rotated log file instead of the journal
+	MaxSizeMB  int    `json:"max_size_mb,omitempty"`  // Size at which the log file is rotated, default 10
+	MaxBackups int    `json:"max_backups,omitempty"`  // Number of rotated log files kept, default 5
+	MaxAgeDays int    `json:"max_age_days,omitempty"` // Days rotated log files are kept, default 30
 }
 
 type WakaTimeConfig struct {
@@ -32,6 +272,7 @@ type WakapiConfig struct {
 
 // Default config created on service start
 const defaultConfig = `{
+  "version": 1,
   "wakatime": {
     "enabled": false
   },
@@ -69,15 +310,39 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	upgraded, from, err := upgradeLayout(bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", configFile, describeDecodeError(bytes, err))
+	}
+
+	var upgrade *Upgrade
+	if from < CurrentVersion {
+		upgrade = &Upgrade{From: from, To: CurrentVersion, Backup: fmt.Sprintf("%s.v%d.bak", configFile, from)}
+		if err := os.WriteFile(upgrade.Backup, bytes, 0o600); err != nil {
+			return nil, fmt.Errorf("failed to back up config before upgrading: %w", err)
+		}
+		if err := os.WriteFile(configFile, upgraded, 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write upgraded config: %w", err)
+		}
+		bytes = upgraded
+	}
+
 	var config Config
 	err = json.Unmarshal(bytes, &config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config file: %w", err)
+		return nil, fmt.Errorf("failed to read config file %s: %w", configFile, describeDecodeError(bytes, err))
 	}
+	config.unknown = unknownKeys(bytes)
+	config.upgrade = upgrade
 
 	return &config, nil
 }
 
+// Location of the config file on disk
+func Path() (string, error) {
+	return getConfigLocation()
+}
+
 // Update the config file with new data
 func (c *Config) Save() error {
 	configFile, err := getConfigLocation()
@@ -85,6 +350,10 @@ func (c *Config) Save() error {
 		return err
 	}
 
+	if c.Version < CurrentVersion {
+		c.Version = CurrentVersion
+	}
+
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
@@ -96,3 +365,691 @@ func (c *Config) Save() error {
 
 	return nil
 }
+
+// Resolve the configured timezone, falling back to the system timezone when unset
+func (c *Config) Location() (*time.Location, error) {
+	if c == nil || c.Timezone == "" || c.Timezone == "system" {
+		return time.Local, nil
+	}
+
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", c.Timezone, err)
+	}
+
+	return loc, nil
+}
+
+// Deadline applied to each database operation when db_timeout is unset or invalid
+const DefaultDBTimeout = 10 * time.Second
+
+// Resolve the per-operation database deadline, falling back to DefaultDBTimeout
+func (c *Config) DatabaseTimeout() time.Duration {
+	if c == nil || c.DBTimeout == "" {
+		return DefaultDBTimeout
+	}
+
+	timeout, err := time.ParseDuration(c.DBTimeout)
+	if err != nil || timeout <= 0 {
+		return DefaultDBTimeout
+	}
+
+	return timeout
+}
+
+// Period the last destructive operation can be undone for when undo_window is unset or invalid
+const DefaultUndoWindow = 24 * time.Hour
+
+// Resolve how long `timekeep undo` can restore the last reset or remove, falling back to DefaultUndoWindow
+func (c *Config) UndoPeriod() time.Duration {
+	if c == nil || c.UndoWindow == "" {
+		return DefaultUndoWindow
+	}
+
+	window, err := time.ParseDuration(c.UndoWindow)
+	if err != nil || window <= 0 {
+		return DefaultUndoWindow
+	}
+
+	return window
+}
+
+// Tracking scopes: the service's own user only, or every user on the machine
+const (
+	ScopeUser   = "user"
+	ScopeShared = "shared"
+)
+
+// Reports whether processes of every user are tracked, rather than only those of the user the service runs for
+func (c *Config) SharedScope() bool {
+	return c != nil && c.Scope == ScopeShared
+}
+
+// Limit enforcement modes: log a warning once a program's daily limit is exceeded, or also end its new instances
+const (
+	EnforceWarn = "warn"
+	EnforceKill = "kill"
+)
+
+// Prefix of limits.daily and goals.daily keys naming a program group, made with 'timekeep group create'
+const GroupPrefix = "group:"
+
+// Returns the program group a limits.daily or goals.daily key names, and whether it names one
+func GroupTarget(key string) (string, bool) {
+	return strings.CutPrefix(key, GroupPrefix)
+}
+
+// Resolve the daily limit of a program, or of a group given as group:<name>, 0 when it has none
+func (c *Config) DailyLimit(name string) time.Duration {
+	if c == nil {
+		return 0
+	}
+
+	limit, err := time.ParseDuration(c.Limits.Daily[name])
+	if err != nil || limit <= 0 {
+		return 0
+	}
+	return limit
+}
+
+// Resolve the daily goal of a category, or of a group given as group:<name>, 0 when it has none
+func (c *Config) DailyGoal(category string) time.Duration {
+	if c == nil {
+		return 0
+	}
+
+	goal, err := time.ParseDuration(c.Goals.Daily[category])
+	if err != nil || goal <= 0 {
+		return 0
+	}
+	return goal
+}
+
+// Default parameters of the daily focus score
+const (
+	DefaultFocusMinBlock    = 25 * time.Minute
+	DefaultFocusMergeGap    = 5 * time.Minute
+	DefaultFocusSwitchLimit = 6
+	DefaultFocusBlockWeight = 50
+)
+
+// Resolve the uninterrupted time counted as a focused block, falling back to DefaultFocusMinBlock
+func (c *Config) FocusMinBlock() time.Duration {
+	if c != nil {
+		if d, err := time.ParseDuration(c.Focus.MinBlock); err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultFocusMinBlock
+}
+
+// Resolve the longest break that doesn't end a block, falling back to DefaultFocusMergeGap
+func (c *Config) FocusMergeGap() time.Duration {
+	if c != nil {
+		if d, err := time.ParseDuration(c.Focus.MergeGap); err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultFocusMergeGap
+}
+
+// Resolve the context switches per hour at which switching scores nothing, falling back to DefaultFocusSwitchLimit
+func (c *Config) FocusSwitchLimit() int {
+	if c == nil || c.Focus.SwitchLimit <= 0 {
+		return DefaultFocusSwitchLimit
+	}
+	return c.Focus.SwitchLimit
+}
+
+// Resolve the percent of the focus score from focused blocks, falling back to DefaultFocusBlockWeight
+func (c *Config) FocusBlockWeight() int {
+	if c == nil || c.Focus.BlockWeight <= 0 || c.Focus.BlockWeight > 100 {
+		return DefaultFocusBlockWeight
+	}
+	return c.Focus.BlockWeight
+}
+
+// Ratings of the default productivity scale
+const (
+	RatingProductive  = "productive"
+	RatingNeutral     = "neutral"
+	RatingDistracting = "distracting"
+)
+
+// Pulse score of each rating when productivity.scale is unset
+var DefaultProductivityScale = map[string]int{RatingProductive: 100, RatingNeutral: 50, RatingDistracting: 0}
+
+// Resolve the ratings programs and categories may be given, with their pulse scores, falling back to
+// DefaultProductivityScale
+func (c *Config) ProductivityScale() map[string]int {
+	if c == nil || len(c.Productivity.Scale) == 0 {
+		return DefaultProductivityScale
+	}
+	return c.Productivity.Scale
+}
+
+// Resolve the productivity rating of program, filed under category: its own, else its category's, empty when neither
+// is rated or the rating isn't on the scale
+func (c *Config) ProductivityRating(program, category string) string {
+	if c == nil {
+		return ""
+	}
+	rating, ok := c.Productivity.Programs[program]
+	if !ok {
+		rating = c.Productivity.Categories[category]
+	}
+	if _, ok := c.ProductivityScale()[rating]; !ok {
+		return ""
+	}
+	return rating
+}
+
+// Variables of productivity.formula, each day's hours except pulse, the day's productivity pulse
+var ProductivityVariables = []string{"weighted", "total", "productive", "neutral", "distracting", "unrated", "pulse"}
+
+// Daily score formula used when productivity.formula is unset: the day's hours, each times the weight of its category
+const DefaultProductivityFormula = "weighted"
+
+// Reports whether a daily productivity score is computed, set by productivity.weights or productivity.formula
+func (c *Config) ProductivityScoring() bool {
+	return c != nil && (len(c.Productivity.Weights) > 0 || c.Productivity.Formula != "")
+}
+
+// Resolve the weight of each hour spent in category for the daily productivity score, 0 when unweighted
+func (c *Config) ProductivityWeight(category string) float64 {
+	if c == nil {
+		return 0
+	}
+	return c.Productivity.Weights[category]
+}
+
+// Resolve the daily productivity score formula, falling back to DefaultProductivityFormula
+func (c *Config) ProductivityFormula() string {
+	if c == nil || c.Productivity.Formula == "" {
+		return DefaultProductivityFormula
+	}
+	return c.Productivity.Formula
+}
+
+// Rounding modes
+const (
+	RoundNearest = "nearest" // To the nearest step, halfway rounding up
+	RoundUp      = "up"      // Up to the next step, as most billing does
+	RoundDown    = "down"    // Down to the last step
+)
+
+// What rounding applies to
+const (
+	RoundPerEntry = "entry" // Each session on its own, totals adding up rounded sessions
+	RoundPerDay   = "day"   // The total of each day, its sessions kept exact
+)
+
+// Resolve the step time is rounded to in timesheets and billing exports, 0 when time is kept exact
+func (c *Config) RoundingIncrement() time.Duration {
+	if c == nil {
+		return 0
+	}
+	d, err := time.ParseDuration(c.Rounding.Increment)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// Reports whether new instances of programs past their daily limit are ended
+func (c *Config) KillOverLimit() bool {
+	return c != nil && c.Limits.Enforce == EnforceKill
+}
+
+// Default hours and weekdays of the workday
+const (
+	DefaultWorkdayStart = "09:00"
+	DefaultWorkdayEnd   = "17:00"
+)
+
+// Weekdays worked when workday.days is unset
+var DefaultWorkdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+// Resolve the times of day work starts and ends, as offsets from midnight, falling back to DefaultWorkdayStart and
+// DefaultWorkdayEnd when either is unset or they don't make a span
+func (c *Config) WorkdayHours() (time.Duration, time.Duration) {
+	start, end := DefaultWorkdayStart, DefaultWorkdayEnd
+	if c != nil && c.Workday.Start != "" {
+		start = c.Workday.Start
+	}
+	if c != nil && c.Workday.End != "" {
+		end = c.Workday.End
+	}
+
+	from, okFrom := clockOffset(start)
+	to, okTo := clockOffset(end)
+	if !okFrom || !okTo || to <= from {
+		from, _ = clockOffset(DefaultWorkdayStart)
+		to, _ = clockOffset(DefaultWorkdayEnd)
+	}
+	return from, to
+}
+
+// Reports whether day is worked, falling back to DefaultWorkdays when workday.days names none
+func (c *Config) IsWorkday(day time.Weekday) bool {
+	var days []time.Weekday
+	if c != nil {
+		for _, name := range c.Workday.Days {
+			if d, ok := dates.ParseWeekday(name); ok {
+				days = append(days, d)
+			}
+		}
+	}
+	if len(days) == 0 {
+		days = DefaultWorkdays
+	}
+	return slices.Contains(days, day)
+}
+
+// Default weekday and time of day the weekly digest is sent
+const (
+	DefaultDigestDay  = time.Monday
+	DefaultDigestTime = "09:00"
+)
+
+// Resolve the weekday the digest is sent on, falling back to DefaultDigestDay
+func (c *Config) DigestDay() time.Weekday {
+	if c != nil {
+		if day, ok := dates.ParseWeekday(c.Digest.Day); ok {
+			return day
+		}
+	}
+	return DefaultDigestDay
+}
+
+// Resolve the time of day the digest is sent at, as an offset from midnight, falling back to DefaultDigestTime
+func (c *Config) DigestTime() time.Duration {
+	value := DefaultDigestTime
+	if c != nil && c.Digest.Time != "" {
+		value = c.Digest.Time
+	}
+
+	at, ok := clockOffset(value)
+	if !ok {
+		at, _ = clockOffset(DefaultDigestTime)
+	}
+	return at
+}
+
+// Reports whether the service records the workspace in use while sessions run
+func (c *Config) TrackWorkspaces() bool {
+	return c != nil && c.Workspaces.Track
+}
+
+// Resolve the label reports show for a workspace, its name when it has none
+func (c *Config) WorkspaceLabel(name string) string {
+	if c != nil && c.Workspaces.Labels[name] != "" {
+		return c.Workspaces.Labels[name]
+	}
+	return name
+}
+
+// Metrics "timekeep prompt" shows: the running session, the total recorded today, or progress towards a daily goal
+const (
+	PromptSession = "session"
+	PromptToday   = "today"
+	PromptGoal    = "goal"
+)
+
+// Longest "timekeep prompt" waits for the database when prompt.budget is unset
+const DefaultPromptBudget = 50 * time.Millisecond
+
+// Resolve the metric "timekeep prompt" shows, falling back to PromptSession
+func (c *Config) PromptShows() string {
+	if c == nil {
+		return PromptSession
+	}
+	switch c.Prompt.Show {
+	case PromptToday, PromptGoal:
+		return c.Prompt.Show
+	default:
+		return PromptSession
+	}
+}
+
+// Resolve the latency budget of "timekeep prompt", falling back to DefaultPromptBudget
+func (c *Config) PromptBudget() time.Duration {
+	if c == nil || c.Prompt.Budget == "" {
+		return DefaultPromptBudget
+	}
+
+	budget, err := time.ParseDuration(c.Prompt.Budget)
+	if err != nil || budget <= 0 {
+		return DefaultPromptBudget
+	}
+	return budget
+}
+
+// Parses an HH:MM time of day as an offset from midnight
+func clockOffset(value string) (time.Duration, bool) {
+	at, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute, true
+}
+
+// Default address and idle timeout of the browser activity listener
+const (
+	DefaultBrowserListen      = "127.0.0.1:7781"
+	DefaultBrowserIdleTimeout = 2 * time.Minute
+)
+
+// Resolve the browser activity listener's address, falling back to DefaultBrowserListen
+func (c *Config) BrowserListen() string {
+	if c == nil || c.Browser.Listen == "" {
+		return DefaultBrowserListen
+	}
+	return c.Browser.Listen
+}
+
+// Resolve how long a site's session stays open without a report, falling back to DefaultBrowserIdleTimeout
+func (c *Config) BrowserIdle() time.Duration {
+	if c == nil || c.Browser.IdleTimeout == "" {
+		return DefaultBrowserIdleTimeout
+	}
+
+	timeout, err := time.ParseDuration(c.Browser.IdleTimeout)
+	if err != nil || timeout <= 0 {
+		return DefaultBrowserIdleTimeout
+	}
+
+	return timeout
+}
+
+// Default address and idle timeout of the editor heartbeat receiver. WakaTime plugins send a heartbeat at least every
+// 2 minutes while typing, and WakaTime itself counts up to 15 minutes between heartbeats as coding time
+const (
+	DefaultEditorListen      = "127.0.0.1:7782"
+	DefaultEditorIdleTimeout = 15 * time.Minute
+)
+
+// Resolve the editor heartbeat receiver's address, falling back to DefaultEditorListen
+func (c *Config) EditorListen() string {
+	if c == nil || c.Editor.Listen == "" {
+		return DefaultEditorListen
+	}
+	return c.Editor.Listen
+}
+
+// Resolve how long a project's session stays open without a heartbeat, falling back to DefaultEditorIdleTimeout
+func (c *Config) EditorIdle() time.Duration {
+	if c == nil || c.Editor.IdleTimeout == "" {
+		return DefaultEditorIdleTimeout
+	}
+
+	timeout, err := time.ParseDuration(c.Editor.IdleTimeout)
+	if err != nil || timeout <= 0 {
+		return DefaultEditorIdleTimeout
+	}
+
+	return timeout
+}
+
+// Events webhooks are sent
+const (
+	WebhookSessionStart = "session.start"
+	WebhookSessionEnd   = "session.end"
+)
+
+// Webhook body formats
+const (
+	WebhookFormatEvent  = "event"  // The event as JSON
+	WebhookFormatZapier = "zapier" // Flat JSON with every key always present, as Zapier's catch hooks map fields
+	WebhookFormatIFTTT  = "ifttt"  // value1 to value3, as IFTTT's Webhooks service takes
+)
+
+// Resolve the shortest ended session the webhook is sent, 0 for no minimum
+func (w WebhookConfig) MinSession() time.Duration {
+	d, err := time.ParseDuration(w.MinDuration)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Default interval between pushes of completed sessions to Kimai
+const DefaultKimaiInterval = 15 * time.Minute
+
+// Shortest Kimai push interval applied
+const MinKimaiInterval = time.Minute
+
+// Resolve how often the service pushes sessions to Kimai, falling back to DefaultKimaiInterval
+func (c *Config) KimaiInterval() time.Duration {
+	if c == nil || c.Kimai.Interval == "" {
+		return DefaultKimaiInterval
+	}
+
+	interval, err := time.ParseDuration(c.Kimai.Interval)
+	if err != nil || interval <= 0 {
+		return DefaultKimaiInterval
+	}
+
+	return max(interval, MinKimaiInterval)
+}
+
+// Resolve the shortest session pushed to Kimai, 0 for no minimum
+func (k KimaiConfig) MinSession() time.Duration {
+	d, err := time.ParseDuration(k.MinDuration)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Reports whether the filter picks a session of program, in category and project. Programs are matched as patterns
+// like those of the managed policy
+func (f SessionFilter) Matches(program, category, project string) bool {
+	if len(f.Programs) > 0 && !slices.ContainsFunc(f.Programs, func(pattern string) bool {
+		ok, _ := path.Match(strings.ToLower(strings.TrimSpace(pattern)), program)
+		return ok
+	}) {
+		return false
+	}
+	if len(f.Categories) > 0 && !slices.Contains(f.Categories, category) {
+		return false
+	}
+	if len(f.Projects) > 0 && !slices.Contains(f.Projects, project) {
+		return false
+	}
+	return true
+}
+
+// Harvest time entry modes
+const (
+	HarvestSessions = "sessions" // An entry per session
+	HarvestDaily    = "daily"    // An entry per completed day, project and task, summing its sessions
+)
+
+// Default interval between pushes of time entries to Harvest
+const DefaultHarvestInterval = time.Hour
+
+// Shortest Harvest push interval applied
+const MinHarvestInterval = time.Minute
+
+// Resolve how often the service pushes time entries to Harvest, falling back to DefaultHarvestInterval
+func (c *Config) HarvestInterval() time.Duration {
+	if c == nil || c.Harvest.Interval == "" {
+		return DefaultHarvestInterval
+	}
+
+	interval, err := time.ParseDuration(c.Harvest.Interval)
+	if err != nil || interval <= 0 {
+		return DefaultHarvestInterval
+	}
+
+	return max(interval, MinHarvestInterval)
+}
+
+// Resolve the shortest session pushed to Harvest, 0 for no minimum
+func (h HarvestConfig) MinSession() time.Duration {
+	d, err := time.ParseDuration(h.MinDuration)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Default address of the data API listener
+const DefaultAPIListen = "127.0.0.1:7783"
+
+// Resolve the data API listener's address, falling back to DefaultAPIListen
+func (c *Config) APIListen() string {
+	if c == nil || c.API.Listen == "" {
+		return DefaultAPIListen
+	}
+	return c.API.Listen
+}
+
+// Default address of the sync server started by "timekeep server"
+const DefaultServerListen = ":7790"
+
+// Resolve the sync server's address, falling back to DefaultServerListen
+func (c *Config) ServerListen() string {
+	if c == nil || c.Server.Listen == "" {
+		return DefaultServerListen
+	}
+	return c.Server.Listen
+}
+
+// Shortest background sync interval applied, so a typo doesn't hammer the server
+const MinSyncInterval = time.Minute
+
+// Resolve how often the service syncs with the sync server, 0 when background sync is off
+func (c *Config) SyncInterval() time.Duration {
+	if c == nil || c.Sync.Server == "" || c.Sync.Interval == "" {
+		return 0
+	}
+
+	interval, err := time.ParseDuration(c.Sync.Interval)
+	if err != nil || interval <= 0 {
+		return 0
+	}
+
+	return max(interval, MinSyncInterval)
+}
+
+// Default interval between fetches of the managed policy file
+const DefaultPolicyInterval = time.Hour
+
+// Shortest policy fetch interval applied
+const MinPolicyInterval = time.Minute
+
+// Resolve how often the service fetches the managed policy file, falling back to DefaultPolicyInterval
+func (c *Config) PolicyInterval() time.Duration {
+	if c == nil || c.Policy.Interval == "" {
+		return DefaultPolicyInterval
+	}
+
+	interval, err := time.ParseDuration(c.Policy.Interval)
+	if err != nil || interval <= 0 {
+		return DefaultPolicyInterval
+	}
+
+	return max(interval, MinPolicyInterval)
+}
+
+// Shortest max session length applied, so ordinary sessions are never split
+const MinMaxSession = time.Hour
+
+// Resolve the length at which running sessions are split, raised to MinMaxSession. Returns 0 when unset or invalid,
+// leaving sessions uncapped
+func (c *Config) MaxSessionLength() time.Duration {
+	if c == nil || c.MaxSession == "" {
+		return 0
+	}
+
+	limit, err := time.ParseDuration(c.MaxSession)
+	if err != nil || limit <= 0 {
+		return 0
+	}
+
+	return max(limit, MinMaxSession)
+}
+
+// Reports whether the elapsed time of running sessions is added to displayed lifetimes
+func (c *Config) IncludeActive() bool {
+	return c == nil || !c.Display.ExcludeActive
+}
+
+const (
+	Clock24h = "24h"
+	Clock12h = "12h"
+)
+
+// Resolve the first day of the week, falling back to Monday
+func (c *Config) WeekStartDay() time.Weekday {
+	if c != nil {
+		if day, ok := dates.ParseWeekday(c.Display.WeekStart); ok {
+			return day
+		}
+	}
+	return time.Monday
+}
+
+// Resolve the Go layout for displayed dates, falling back to 2006-01-02
+func (c *Config) DateLayout() string {
+	if c == nil || c.Display.DateFormat == "" {
+		return time.DateOnly
+	}
+	layout, err := dateLayout(c.Display.DateFormat)
+	if err != nil {
+		return time.DateOnly
+	}
+	return layout
+}
+
+// Resolve the Go layout for displayed times of day on the configured clock, with or without seconds
+func (c *Config) TimeLayout(seconds bool) string {
+	twelve := c != nil && c.Display.Clock == Clock12h
+	switch {
+	case twelve && seconds:
+		return "3:04:05 PM"
+	case twelve:
+		return "3:04 PM"
+	case seconds:
+		return "15:04:05"
+	default:
+		return "15:04"
+	}
+}
+
+// Checks that format is a date format made of YYYY, MM, DD and separators
+func ValidateDateFormat(format string) error {
+	_, err := dateLayout(format)
+	return err
+}
+
+// Converts a date format such as DD/MM/YYYY into a Go layout. Each of YYYY, MM and DD must appear exactly once
+func dateLayout(format string) (string, error) {
+	layout := strings.ToUpper(format)
+	for _, token := range []string{"YYYY", "MM", "DD"} {
+		if strings.Count(layout, token) != 1 {
+			return "", fmt.Errorf("date format %q must contain YYYY, MM and DD once each", format)
+		}
+	}
+	layout = strings.NewReplacer("YYYY", "2006", "MM", "01", "DD", "02").Replace(layout)
+	if strings.ContainsAny(layout, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+		return "", fmt.Errorf("date format %q may only contain YYYY, MM, DD and separators", format)
+	}
+	return layout, nil
+}
+
+// Label identifying this machine on recorded sessions, falling back to the hostname
+func (c *Config) DeviceName() string {
+	if c != nil && c.Device != "" {
+		return c.Device
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+
+	return hostname
+}
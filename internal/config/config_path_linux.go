@@ -5,6 +5,8 @@ package config
 import (
 	"os"
 	"path/filepath"
+
+	"github.com/jms-guy/timekeep/internal/profile"
 )
 
 func getConfigLocation() (string, error) {
@@ -12,7 +14,7 @@ func getConfigLocation() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	path := filepath.Join(home, ".config", "timekeep", "config.json")
+	path := filepath.Join(home, ".config", profile.Suffixed("timekeep"), "config.json")
 
 	return path, nil
 }
@@ -2,9 +2,13 @@
 
 package config
 
-import "path/filepath"
+import (
+	"path/filepath"
+
+	"github.com/jms-guy/timekeep/internal/profile"
+)
 
 func getConfigLocation() (string, error) {
-	configDir := `C:\ProgramData\Timekeep\config`
+	configDir := filepath.Join(profile.DataDir(), "config")
 	return filepath.Join(configDir, "config.json"), nil
 }
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Layout version of the config file written by this build
+const CurrentVersion = 1
+
+// A layout change applied to the config file when it was loaded
+type Upgrade struct {
+	From   int
+	To     int
+	Backup string // Copy of the file as it was before the upgrade
+}
+
+// Steps converting a config file from the layout version at their index to the next one
+var upgrades = []func(raw map[string]any){
+	upgradeUnversioned,
+}
+
+// Files written before the layout was versioned. Durations hand-written as bare numbers are taken as seconds, and a
+// quoted poll_grace as the number it holds, both of which used to fail to load
+func upgradeUnversioned(raw map[string]any) {
+	for _, key := range []string{"poll_interval", "db_timeout", "undo_window"} {
+		if seconds, ok := raw[key].(float64); ok {
+			raw[key] = strconv.FormatFloat(seconds, 'f', -1, 64) + "s"
+		}
+	}
+	if grace, ok := raw["poll_grace"].(string); ok {
+		if n, err := strconv.Atoi(grace); err == nil {
+			raw["poll_grace"] = n
+		}
+	}
+}
+
+// Converts data to the current layout, returning the converted file and the version it was at. Files at the current
+// version, or written by a newer build, are returned unchanged
+func upgradeLayout(data []byte) ([]byte, int, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, err
+	}
+	if raw == nil {
+		raw = map[string]any{}
+	}
+
+	from := 0
+	if v, ok := raw["version"].(float64); ok {
+		from = int(v)
+	}
+	if from >= CurrentVersion {
+		return data, from, nil
+	}
+
+	for version := from; version < CurrentVersion; version++ {
+		upgrades[version](raw)
+	}
+	raw["version"] = CurrentVersion
+
+	upgraded, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, from, fmt.Errorf("failed to marshal upgraded config: %w", err)
+	}
+	return upgraded, from, nil
+}
+
+// The layout upgrade applied when the config was loaded, nil if the file was already current
+func (c *Config) Upgraded() *Upgrade {
+	if c == nil {
+		return nil
+	}
+	return c.upgrade
+}
@@ -0,0 +1,597 @@
+package config
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/formula"
+	"github.com/jms-guy/timekeep/internal/progname"
+)
+
+// A single problem found in the config file
+type Problem struct {
+	Key     string // Dotted path of the offending key, such as log.level
+	Message string // What is wrong, and how to fix it
+}
+
+func (p Problem) String() string {
+	return p.Key + ": " + p.Message
+}
+
+// Checks the loaded values, returning every problem found along with unknown keys seen when the file was loaded
+func (c *Config) Validate() []Problem {
+	if c == nil {
+		return nil
+	}
+
+	problems := append([]Problem(nil), c.unknown...)
+	add := func(key, format string, args ...any) {
+		problems = append(problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Version > CurrentVersion {
+		add("version", "written by a newer timekeep (layout %d, this build reads %d), settings it added are ignored", c.Version, CurrentVersion)
+	}
+
+	checkDuration := func(key, value string) {
+		if value == "" {
+			return
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			add(key, "invalid duration %q, use a positive value with a unit such as \"1s\", \"500ms\" or \"2h\"", value)
+		}
+	}
+	checkDuration("poll_interval", c.PollInterval)
+	checkDuration("db_timeout", c.DBTimeout)
+	checkDuration("undo_window", c.UndoWindow)
+	checkDuration("max_session", c.MaxSession)
+	if limit, err := time.ParseDuration(c.MaxSession); err == nil && limit > 0 && limit < MinMaxSession {
+		add("max_session", "%s is shorter than the 1h minimum, which is used instead", c.MaxSession)
+	}
+
+	if c.PollGrace < 0 {
+		add("poll_grace", "must be 0 or more, got %d", c.PollGrace)
+	}
+	if c.Timezone != "" && c.Timezone != "system" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			add("timezone", "unknown timezone %q, use an IANA name such as \"America/New_York\" or \"system\"", c.Timezone)
+		}
+	}
+
+	switch c.Scope {
+	case "", ScopeUser, ScopeShared:
+	default:
+		add("scope", "unknown scope %q, use user or shared", c.Scope)
+	}
+
+	if c.Display.WeekStart != "" {
+		if _, ok := dates.ParseWeekday(c.Display.WeekStart); !ok {
+			add("display.week_start", "unknown weekday %q, use a day name such as monday or sunday", c.Display.WeekStart)
+		}
+	}
+	switch c.Display.Clock {
+	case "", Clock24h, Clock12h:
+	default:
+		add("display.clock", "unknown clock %q, use 24h or 12h", c.Display.Clock)
+	}
+	if c.Display.DateFormat != "" {
+		if _, err := dateLayout(c.Display.DateFormat); err != nil {
+			add("display.date_format", "%v, such as \"DD/MM/YYYY\"", err)
+		}
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		add("log.level", "unknown level %q, use debug, info, warn or error", c.Log.Level)
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "", "text", "json":
+	default:
+		add("log.format", "unknown format %q, use text or json", c.Log.Format)
+	}
+	for key, value := range map[string]int{"log.max_size_mb": c.Log.MaxSizeMB, "log.max_backups": c.Log.MaxBackups, "log.max_age_days": c.Log.MaxAgeDays} {
+		if value < 0 {
+			add(key, "must be 0 or more, got %d", value)
+		}
+	}
+
+	if c.WakaTime.Enabled && c.WakaTime.APIKey == "" {
+		add("wakatime.api_key", "required while wakatime is enabled, set it with `timekeep wakatime enable --api_key KEY`")
+	}
+	if c.WakaTime.CLIPath != "" {
+		if _, err := os.Stat(c.WakaTime.CLIPath); err != nil {
+			add("wakatime.cli_path", "%q not found, set the full path to the wakatime-cli binary", c.WakaTime.CLIPath)
+		}
+	} else if c.WakaTime.Enabled {
+		add("wakatime.cli_path", "required while wakatime is enabled, set it with `timekeep config --cli_path PATH`")
+	}
+
+	if c.Wakapi.Server != "" {
+		if err := ValidateServerURL(c.Wakapi.Server); err != nil {
+			add("wakapi.server", "%v", err)
+		}
+	} else if c.Wakapi.Enabled {
+		add("wakapi.server", "required while wakapi is enabled, set it with `timekeep config --server URL`")
+	}
+	if c.Wakapi.Enabled && c.Wakapi.APIKey == "" {
+		add("wakapi.api_key", "required while wakapi is enabled, set it with `timekeep wakapi enable --api_key KEY`")
+	}
+
+	if c.Remote.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Remote.Listen); err != nil {
+			add("remote.listen", "invalid address %q, use host:port or :port such as \":7780\"", c.Remote.Listen)
+		}
+	}
+	if c.Remote.Enabled && c.Remote.Token == "" {
+		add("remote.token", "required while remote is enabled, set a long random secret")
+	}
+	if (c.Remote.CertFile == "") != (c.Remote.KeyFile == "") {
+		add("remote.cert_file", "cert_file and key_file must be set together")
+	}
+
+	if c.Browser.Listen != "" && !IsLoopbackAddr(c.Browser.Listen) {
+		add("browser.listen", "invalid address %q, use a loopback address such as \"127.0.0.1:7781\"", c.Browser.Listen)
+	}
+	if c.Browser.Enabled && c.Browser.Token == "" {
+		add("browser.token", "required while browser is enabled, set a long random secret and enter it in the extension")
+	}
+	checkDuration("browser.idle_timeout", c.Browser.IdleTimeout)
+
+	if c.Editor.Listen != "" && !IsLoopbackAddr(c.Editor.Listen) {
+		add("editor.listen", "invalid address %q, use a loopback address such as \"127.0.0.1:7782\"", c.Editor.Listen)
+	}
+	if c.Editor.Enabled && c.Editor.APIKey == "" {
+		add("editor.api_key", "required while editor is enabled, set a UUID and use it as api_key in the plugins' WakaTime config")
+	}
+	checkDuration("editor.idle_timeout", c.Editor.IdleTimeout)
+
+	if c.API.Listen != "" && !IsLoopbackAddr(c.API.Listen) {
+		add("api.listen", "invalid address %q, use a loopback address such as \"127.0.0.1:7783\"", c.API.Listen)
+	}
+
+	if c.Server.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Server.Listen); err != nil {
+			add("server.listen", "invalid address %q, use host:port or :port such as \":7790\"", c.Server.Listen)
+		}
+	}
+	if (c.Server.CertFile == "") != (c.Server.KeyFile == "") {
+		add("server.cert_file", "cert_file and key_file must be set together")
+	}
+
+	if c.Sync.Server != "" {
+		if u, err := url.Parse(c.Sync.Server); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("sync.server", "invalid URL %q, use the server's address such as \"https://hub:7790\"", c.Sync.Server)
+		}
+		if c.Sync.Token == "" {
+			add("sync.token", "required while sync.server is set, create one on the server with 'timekeep token create <name> --scope sync'")
+		}
+	}
+	checkDuration("sync.interval", c.Sync.Interval)
+
+	if c.Team.Endpoint != "" {
+		if u, err := url.Parse(c.Team.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("team.endpoint", "invalid URL %q, use the team server's report URL such as \"https://team.example.com/reports\"", c.Team.Endpoint)
+		}
+	} else if c.Team.Enabled {
+		add("team.endpoint", "required while team is enabled, set the URL reports are posted to")
+	}
+
+	checkFilter := func(key string, filter SessionFilter) {
+		for _, pattern := range filter.Programs {
+			if _, err := path.Match(pattern, ""); err != nil {
+				add(key+".programs", "invalid pattern %q", pattern)
+			}
+		}
+	}
+
+	if c.Kimai.Server != "" {
+		if u, err := url.Parse(c.Kimai.Server); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("kimai.server", "invalid URL %q, use the Kimai address such as \"https://kimai.example.com\"", c.Kimai.Server)
+		}
+	} else if c.Kimai.Enabled {
+		add("kimai.server", "required while kimai is enabled, set the Kimai address")
+	}
+	if c.Kimai.Enabled && c.Kimai.Token == "" {
+		add("kimai.token", "required while kimai is enabled, create an API token in your Kimai user profile")
+	}
+	if c.Kimai.Project < 0 {
+		add("kimai.project", "invalid project ID %d", c.Kimai.Project)
+	}
+	if c.Kimai.Activity < 0 {
+		add("kimai.activity", "invalid activity ID %d", c.Kimai.Activity)
+	}
+	for i, mapping := range c.Kimai.Mappings {
+		key := fmt.Sprintf("kimai.mappings[%d]", i)
+		if mapping.Project < 0 {
+			add(key+".project", "invalid project ID %d", mapping.Project)
+		}
+		if mapping.Activity < 0 {
+			add(key+".activity", "invalid activity ID %d", mapping.Activity)
+		}
+		checkFilter(key, mapping.SessionFilter)
+	}
+	if c.Kimai.Enabled && (c.Kimai.Project == 0 || c.Kimai.Activity == 0) && len(c.Kimai.Mappings) == 0 {
+		add("kimai", "enabled but no session gets both a project and an activity, set project and activity or mappings")
+	}
+	checkDuration("kimai.min_duration", c.Kimai.MinDuration)
+	checkDuration("kimai.interval", c.Kimai.Interval)
+
+	if c.Harvest.Server != "" {
+		if u, err := url.Parse(c.Harvest.Server); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("harvest.server", "invalid URL %q, leave it unset for Harvest's own API", c.Harvest.Server)
+		}
+	}
+	if c.Harvest.Enabled && c.Harvest.AccountID == "" {
+		add("harvest.account_id", "required while harvest is enabled, shown with your personal access token in Harvest's developer settings")
+	}
+	if c.Harvest.Enabled && c.Harvest.Token == "" {
+		add("harvest.token", "required while harvest is enabled, create a personal access token in Harvest's developer settings")
+	}
+	switch c.Harvest.Mode {
+	case "", HarvestSessions, HarvestDaily:
+	default:
+		add("harvest.mode", "unknown mode %q, use sessions or daily", c.Harvest.Mode)
+	}
+	if c.Harvest.Project < 0 {
+		add("harvest.project", "invalid project ID %d", c.Harvest.Project)
+	}
+	if c.Harvest.Task < 0 {
+		add("harvest.task", "invalid task ID %d", c.Harvest.Task)
+	}
+	for i, mapping := range c.Harvest.Mappings {
+		key := fmt.Sprintf("harvest.mappings[%d]", i)
+		if mapping.Project < 0 {
+			add(key+".project", "invalid project ID %d", mapping.Project)
+		}
+		if mapping.Task < 0 {
+			add(key+".task", "invalid task ID %d", mapping.Task)
+		}
+		checkFilter(key, mapping.SessionFilter)
+	}
+	if c.Harvest.Enabled && (c.Harvest.Project == 0 || c.Harvest.Task == 0) && len(c.Harvest.Mappings) == 0 {
+		add("harvest", "enabled but no session gets both a project and a task, set project and task or mappings")
+	}
+	checkDuration("harvest.min_duration", c.Harvest.MinDuration)
+	checkDuration("harvest.interval", c.Harvest.Interval)
+
+	if c.Policy.Source != "" {
+		if u, err := url.Parse(c.Policy.Source); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			if u.Host == "" {
+				add("policy.source", "invalid URL %q", c.Policy.Source)
+			}
+		} else if !filepath.IsAbs(c.Policy.Source) {
+			add("policy.source", "invalid source %q, use an http(s) URL or an absolute file path", c.Policy.Source)
+		}
+	}
+	checkDuration("policy.interval", c.Policy.Interval)
+
+	for name, limit := range c.Limits.Daily {
+		if group, ok := GroupTarget(name); ok {
+			if group == "" {
+				add("limits.daily", "group key %q names no group, use group:<name>", name)
+			}
+		} else if name != progname.Normalize(name) {
+			add("limits.daily", "program %q not in canonical form, use %q as shown by 'timekeep ls'", name, progname.Normalize(name))
+		}
+		checkDuration("limits.daily."+name, limit)
+	}
+	switch c.Limits.Enforce {
+	case "", EnforceWarn, EnforceKill:
+	default:
+		add("limits.enforce", "unknown mode %q, use warn or kill", c.Limits.Enforce)
+	}
+
+	for category, goal := range c.Goals.Daily {
+		if group, ok := GroupTarget(category); ok && group == "" {
+			add("goals.daily", "group key %q names no group, use group:<name>", category)
+		}
+		checkDuration("goals.daily."+category, goal)
+	}
+
+	checkDuration("focus.min_block", c.Focus.MinBlock)
+	checkDuration("focus.merge_gap", c.Focus.MergeGap)
+	if c.Focus.SwitchLimit < 0 {
+		add("focus.switch_limit", "must be 0 or more, got %d", c.Focus.SwitchLimit)
+	}
+	if c.Focus.BlockWeight < 0 || c.Focus.BlockWeight > 100 {
+		add("focus.block_weight", "must be a percentage from 1 to 100, got %d", c.Focus.BlockWeight)
+	}
+
+	scale := c.ProductivityScale()
+	ratings := strings.Join(slices.Sorted(maps.Keys(scale)), ", ")
+	for rating, score := range c.Productivity.Scale {
+		if score < 0 || score > 100 {
+			add("productivity.scale."+rating, "must be a pulse score from 0 to 100, got %d", score)
+		}
+	}
+	for name, rating := range c.Productivity.Programs {
+		if name != progname.Normalize(name) {
+			add("productivity.programs", "program %q not in canonical form, use %q as shown by 'timekeep ls'", name, progname.Normalize(name))
+		}
+		if _, ok := scale[rating]; !ok {
+			add("productivity.programs."+name, "unknown rating %q, use one of %s", rating, ratings)
+		}
+	}
+	for category, rating := range c.Productivity.Categories {
+		if _, ok := scale[rating]; !ok {
+			add("productivity.categories."+category, "unknown rating %q, use one of %s", rating, ratings)
+		}
+	}
+	if _, err := formula.Parse(c.ProductivityFormula(), ProductivityVariables); err != nil {
+		add("productivity.formula", "%v", err)
+	}
+
+	checkDuration("rounding.increment", c.Rounding.Increment)
+	switch c.Rounding.Mode {
+	case "", RoundNearest, RoundUp, RoundDown:
+	default:
+		add("rounding.mode", "unknown mode %q, use nearest, up or down", c.Rounding.Mode)
+	}
+	switch c.Rounding.Per {
+	case "", RoundPerEntry, RoundPerDay:
+	default:
+		add("rounding.per", "unknown value %q, use entry or day", c.Rounding.Per)
+	}
+
+	if c.Workday.Start != "" {
+		if _, ok := clockOffset(c.Workday.Start); !ok {
+			add("workday.start", "invalid time %q, use HH:MM on a 24h clock such as \"09:00\"", c.Workday.Start)
+		}
+	}
+	if c.Workday.End != "" {
+		if _, ok := clockOffset(c.Workday.End); !ok {
+			add("workday.end", "invalid time %q, use HH:MM on a 24h clock such as \"17:00\"", c.Workday.End)
+		}
+	}
+	if c.Workday.Start != "" || c.Workday.End != "" {
+		start, okStart := clockOffset(cmp.Or(c.Workday.Start, DefaultWorkdayStart))
+		end, okEnd := clockOffset(cmp.Or(c.Workday.End, DefaultWorkdayEnd))
+		if okStart && okEnd && end <= start {
+			add("workday.end", "must be after workday.start, the default 09:00 to 17:00 is used instead")
+		}
+	}
+	for _, day := range c.Workday.Days {
+		if _, ok := dates.ParseWeekday(day); !ok {
+			add("workday.days", "unknown weekday %q, use day names such as \"monday\"", day)
+		}
+	}
+
+	switch c.Prompt.Show {
+	case "", PromptSession, PromptToday, PromptGoal:
+	default:
+		add("prompt.show", "unknown metric %q, use session, today or goal", c.Prompt.Show)
+	}
+	checkDuration("prompt.budget", c.Prompt.Budget)
+
+	if c.Digest.Day != "" {
+		if _, ok := dates.ParseWeekday(c.Digest.Day); !ok {
+			add("digest.day", "unknown weekday %q, use a day name such as \"monday\"", c.Digest.Day)
+		}
+	}
+	if c.Digest.Time != "" {
+		if _, err := time.Parse("15:04", c.Digest.Time); err != nil {
+			add("digest.time", "invalid time %q, use HH:MM on a 24h clock such as \"09:00\"", c.Digest.Time)
+		}
+	}
+	if c.Digest.Email.SMTP != "" {
+		if _, _, err := net.SplitHostPort(c.Digest.Email.SMTP); err != nil {
+			add("digest.email.smtp", "invalid address %q, use host:port such as \"smtp.example.com:587\"", c.Digest.Email.SMTP)
+		}
+		if c.Digest.Email.From == "" {
+			add("digest.email.from", "required while digest.email.smtp is set, set the sender address")
+		}
+		if len(c.Digest.Email.To) == 0 {
+			add("digest.email.to", "required while digest.email.smtp is set, list the addresses the digest is sent to")
+		}
+	}
+	if c.Digest.Enabled && !c.Digest.Desktop && c.Digest.Email.SMTP == "" {
+		add("digest", "enabled but sent nowhere, set desktop or email.smtp")
+	}
+
+	for i, hook := range c.Webhooks {
+		key := fmt.Sprintf("webhooks[%d]", i)
+		if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add(key+".url", "invalid URL %q, use an http or https URL", hook.URL)
+		}
+		switch strings.ToUpper(hook.Method) {
+		case "", "POST", "PUT", "PATCH":
+		default:
+			add(key+".method", "unsupported method %q, use POST, PUT or PATCH", hook.Method)
+		}
+		for _, event := range hook.Events {
+			if event != WebhookSessionStart && event != WebhookSessionEnd {
+				add(key+".events", "unknown event %q, use %q or %q", event, WebhookSessionStart, WebhookSessionEnd)
+			}
+		}
+		switch hook.Format {
+		case "", WebhookFormatEvent, WebhookFormatZapier, WebhookFormatIFTTT:
+		default:
+			add(key+".format", "unknown format %q, use event, zapier or ifttt", hook.Format)
+		}
+		if hook.Format != "" && hook.Template != "" {
+			add(key+".format", "format and template can't both be set, the template replaces the format")
+		}
+		checkDuration(key+".min_duration", hook.MinDuration)
+	}
+
+	if c.Debug.Listen != "" && !IsLoopbackAddr(c.Debug.Listen) {
+		add("debug.listen", "invalid address %q, use a loopback address such as \"127.0.0.1:6060\"", c.Debug.Listen)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Key < problems[j].Key })
+	return problems
+}
+
+// Reports whether addr is a host:port with a loopback IP or localhost as its host
+func IsLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return host == "localhost" || (ip != nil && ip.IsLoopback())
+}
+
+// Checks that server is an http or https address, a bare host being taken as http like the service does
+func ValidateServerURL(server string) error {
+	address := server
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("invalid URL %q, use a full address such as \"https://wakapi.example.com\"", server)
+	}
+	return nil
+}
+
+// Returns a problem for each key in data that doesn't map to a field of Config, suggesting the nearest known key
+func unknownKeys(data []byte) []Problem {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil
+	}
+
+	var problems []Problem
+	walkKeys(raw, reflect.TypeOf(Config{}), "", &problems)
+	return problems
+}
+
+// Checks the keys of raw against the fields of struct type t. Like encoding/json, keys match field names in any case
+func walkKeys(raw map[string]any, t reflect.Type, prefix string, problems *[]Problem) {
+	fields := map[string]reflect.Type{}
+	jsonFields(t, fields)
+
+	for _, key := range slices.Sorted(maps.Keys(raw)) {
+		field, ok := fieldFor(key, fields)
+		if !ok {
+			message := "unknown key, it is ignored"
+			if suggestion := nearestKey(key, fields); suggestion != "" {
+				message = fmt.Sprintf("unknown key, did you mean %q?", suggestion)
+			}
+			*problems = append(*problems, Problem{Key: prefix + key, Message: message})
+			continue
+		}
+		walkValue(raw[key], field, prefix+key, problems)
+	}
+}
+
+// Checks the keys of the objects within value, decoded into type t, such as the entries of a list of structs
+func walkValue(value any, t reflect.Type, path string, problems *[]Problem) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			walkKeys(v, t, path+".", problems)
+		case reflect.Map:
+			for _, key := range slices.Sorted(maps.Keys(v)) {
+				walkValue(v[key], t.Elem(), path+"."+key, problems)
+			}
+		}
+	case []any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, item := range v {
+				walkValue(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+}
+
+// Adds the JSON name and type of each field of struct type t to fields, including those of embedded structs
+func jsonFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			jsonFields(field.Type, fields)
+			continue
+		}
+		if name != "" && name != "-" {
+			fields[name] = field.Type
+		}
+	}
+}
+
+// Returns the type of the field key decodes into, preferring an exact match as encoding/json does
+func fieldFor(key string, fields map[string]reflect.Type) (reflect.Type, bool) {
+	if field, ok := fields[key]; ok {
+		return field, true
+	}
+	for name, field := range fields {
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return nil, false
+}
+
+// Returns the known key closest to key by edit distance, if one is close enough to be a likely typo
+func nearestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		if d := progname.EditDistance(strings.ToLower(key), name); d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// Describes a config file decoding error with the line and column or key it concerns
+func describeDecodeError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line, col := position(data, syntaxErr.Offset)
+		return fmt.Errorf("invalid JSON at line %d, column %d: %w", line, col, err)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("%s must be %s, not %s", typeErr.Field, jsonKind(typeErr.Type), typeErr.Value)
+	}
+
+	return err
+}
+
+// Names the JSON value expected for a config field of type t
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int64:
+		return "a number"
+	case reflect.String:
+		return "a quoted string"
+	case reflect.Struct:
+		return "an object"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// Converts a byte offset into a 1-based line and column
+func position(data []byte, offset int64) (int, int) {
+	offset = min(offset, int64(len(data)))
+	before := data[:offset]
+	line := strings.Count(string(before), "\n") + 1
+	col := int(offset) - strings.LastIndex(string(before), "\n")
+	return line, col
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []Problem
+	}{
+		{name: "known keys", data: `{"timezone": "UTC", "log": {"level": "info"}}`},
+		{name: "keys in any case", data: `{"Webhooks": [{"URL": "https://example.com"}], "LOG": {"Level": "info"}}`},
+		{name: "map entries", data: `{"limits": {"daily": {"anything": "2h"}}}`},
+		{name: "not an object", data: `[1, 2]`},
+		{
+			name: "top level typo",
+			data: `{"timezon": "UTC"}`,
+			want: []Problem{{Key: "timezon", Message: `unknown key, did you mean "timezone"?`}},
+		},
+		{
+			name: "nested typo",
+			data: `{"log": {"levle": "info"}}`,
+			want: []Problem{{Key: "log.levle", Message: `unknown key, did you mean "level"?`}},
+		},
+		{
+			name: "unlike any key",
+			data: `{"somethingelse": true}`,
+			want: []Problem{{Key: "somethingelse", Message: "unknown key, it is ignored"}},
+		},
+		{
+			name: "webhook entries",
+			data: `{"webhooks": [{"url": "https://a.example.com"}, {"url": "https://b.example.com", "programz": ["code"]}]}`,
+			want: []Problem{{Key: "webhooks[1].programz", Message: `unknown key, did you mean "programs"?`}},
+		},
+		{
+			name: "kimai mappings, with the embedded filter",
+			data: `{"kimai": {"mappings": [{"programs": ["code"], "project": 1, "activty": 2}]}}`,
+			want: []Problem{{Key: "kimai.mappings[0].activty", Message: `unknown key, did you mean "activity"?`}},
+		},
+		{
+			name: "harvest mappings",
+			data: `{"harvest": {"mappings": [{"categories": ["coding"], "tsak": 2}]}}`,
+			want: []Problem{{Key: "harvest.mappings[0].tsak", Message: `unknown key, did you mean "task"?`}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, unknownKeys([]byte(tc.data)))
+		})
+	}
+}
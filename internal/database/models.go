@@ -15,12 +15,111 @@ type ActiveSession struct {
 	StartTime   time.Time
 }
 
+type ApiToken struct {
+	ID         int64
+	Name       string
+	Scope      string
+	TokenHash  string
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+}
+
+type DigestState struct {
+	ID       int64
+	LastWeek string
+	SentAt   time.Time
+}
+
+type ExportState struct {
+	Target        string
+	PushedThrough int64
+	PushedAt      time.Time
+}
+
+type HarvestLog struct {
+	Key       string
+	EntryID   int64
+	SpentDate string
+	PushedAt  time.Time
+}
+
+type Incognito struct {
+	ID    int64
+	Until sql.NullTime
+}
+
+type ProgramGroup struct {
+	GroupName   string
+	ProgramName string
+}
+
+type ProjectEstimate struct {
+	Project         string
+	EstimateSeconds int64
+	SetAt           time.Time
+}
+
+type SessionArchive struct {
+	ID              int64
+	ProgramName     string
+	StartTime       time.Time
+	EndTime         time.Time
+	DurationSeconds int64
+	Metadata        sql.NullString
+	Device          sql.NullString
+	ArchivedAt      time.Time
+}
+
 type SessionHistory struct {
 	ID              int64
 	ProgramName     string
 	StartTime       time.Time
 	EndTime         time.Time
 	DurationSeconds int64
+	Metadata        sql.NullString
+	Device          sql.NullString
+}
+
+type Snooze struct {
+	ID       int64
+	ResumeAt time.Time
+}
+
+type Streak struct {
+	Kind    string
+	Name    string
+	Current int64
+	Best    int64
+	Through string
+}
+
+type SyncLog struct {
+	Seq             int64
+	SyncID          string
+	Device          string
+	ProgramName     string
+	Category        sql.NullString
+	Project         sql.NullString
+	StartTime       time.Time
+	EndTime         time.Time
+	DurationSeconds int64
+	Metadata        sql.NullString
+	ReceivedAt      time.Time
+	DeviceID        string
+}
+
+type SyncState struct {
+	ID            int64
+	DeviceID      string
+	PushedThrough int64
+	PullCursor    int64
+	LastSync      sql.NullTime
+}
+
+type TeamState struct {
+	ID         int64
+	LastDay    string
+	ReportedAt time.Time
 }
 
 type TrackedProgram struct {
@@ -29,4 +128,18 @@ type TrackedProgram struct {
 	LifetimeSeconds int64
 	Category        sql.NullString
 	Project         sql.NullString
+	Remote          bool
+	SessionCount    int64
+}
+
+type UndoOperation struct {
+	ID        int64
+	Operation string
+	CreatedAt time.Time
+}
+
+type UndoRow struct {
+	ID        int64
+	TableName string
+	Data      string
 }
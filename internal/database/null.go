@@ -0,0 +1,8 @@
+package database
+
+import "database/sql"
+
+// Returns s as a nullable column value, null when s is empty
+func NullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -7,12 +7,13 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 )
 
 const addToSessionHistory = `-- name: AddToSessionHistory :exec
-INSERT INTO session_history (program_name, start_time, end_time, duration_seconds)
-VALUES (?, ?, ?, ?)
+INSERT INTO session_history (program_name, start_time, end_time, duration_seconds, metadata, device)
+VALUES (?, ?, ?, ?, ?, ?)
 `
 
 type AddToSessionHistoryParams struct {
@@ -20,6 +21,8 @@ type AddToSessionHistoryParams struct {
 	StartTime       time.Time
 	EndTime         time.Time
 	DurationSeconds int64
+	Metadata        sql.NullString
+	Device          sql.NullString
 }
 
 func (q *Queries) AddToSessionHistory(ctx context.Context, arg AddToSessionHistoryParams) error {
@@ -28,21 +31,29 @@ func (q *Queries) AddToSessionHistory(ctx context.Context, arg AddToSessionHisto
 		arg.StartTime,
 		arg.EndTime,
 		arg.DurationSeconds,
+		arg.Metadata,
+		arg.Device,
 	)
 	return err
 }
 
 const getAllSessionHistory = `-- name: GetAllSessionHistory :many
-SELECT id, program_name, start_time, end_time, duration_seconds FROM (
-    SELECT id, program_name, start_time, end_time, duration_seconds FROM session_history
+SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM (
+    SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM session_history
+    WHERE IFNULL(?, '') IN ('', device)
     ORDER BY end_time DESC
     LIMIT ?
 ) AS results
 ORDER BY end_time ASC
 `
 
-func (q *Queries) GetAllSessionHistory(ctx context.Context, limit int64) ([]SessionHistory, error) {
-	rows, err := q.db.QueryContext(ctx, getAllSessionHistory, limit)
+type GetAllSessionHistoryParams struct {
+	Device sql.NullString
+	Limit  int64
+}
+
+func (q *Queries) GetAllSessionHistory(ctx context.Context, arg GetAllSessionHistoryParams) ([]SessionHistory, error) {
+	rows, err := q.db.QueryContext(ctx, getAllSessionHistory, arg.Device, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -56,6 +67,8 @@ func (q *Queries) GetAllSessionHistory(ctx context.Context, limit int64) ([]Sess
 			&i.StartTime,
 			&i.EndTime,
 			&i.DurationSeconds,
+			&i.Metadata,
+			&i.Device,
 		); err != nil {
 			return nil, err
 		}
@@ -71,9 +84,10 @@ func (q *Queries) GetAllSessionHistory(ctx context.Context, limit int64) ([]Sess
 }
 
 const getAllSessionHistoryByDate = `-- name: GetAllSessionHistoryByDate :many
-SELECT id, program_name, start_time, end_time, duration_seconds FROM (
-    SELECT id, program_name, start_time, end_time, duration_seconds FROM session_history
+SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM (
+    SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM session_history
     WHERE start_time <= ? AND end_time >= ?
+      AND IFNULL(?, '') IN ('', device)
     ORDER BY start_time DESC
     LIMIT ?
 ) AS results
@@ -83,11 +97,17 @@ ORDER BY start_time ASC
 type GetAllSessionHistoryByDateParams struct {
 	StartTime time.Time
 	EndTime   time.Time
+	Device    sql.NullString
 	Limit     int64
 }
 
 func (q *Queries) GetAllSessionHistoryByDate(ctx context.Context, arg GetAllSessionHistoryByDateParams) ([]SessionHistory, error) {
-	rows, err := q.db.QueryContext(ctx, getAllSessionHistoryByDate, arg.StartTime, arg.EndTime, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getAllSessionHistoryByDate,
+		arg.StartTime,
+		arg.EndTime,
+		arg.Device,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -101,6 +121,8 @@ func (q *Queries) GetAllSessionHistoryByDate(ctx context.Context, arg GetAllSess
 			&i.StartTime,
 			&i.EndTime,
 			&i.DurationSeconds,
+			&i.Metadata,
+			&i.Device,
 		); err != nil {
 			return nil, err
 		}
@@ -116,9 +138,10 @@ func (q *Queries) GetAllSessionHistoryByDate(ctx context.Context, arg GetAllSess
 }
 
 const getAllSessionHistoryByRange = `-- name: GetAllSessionHistoryByRange :many
-SELECT id, program_name, start_time, end_time, duration_seconds FROM (
-    SELECT id, program_name, start_time, end_time, duration_seconds FROM session_history
+SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM (
+    SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM session_history
     WHERE start_time <= ? AND end_time >= ?
+      AND IFNULL(?, '') IN ('', device)
     ORDER BY start_time DESC
     LIMIT ?
 ) AS results
@@ -128,11 +151,17 @@ ORDER BY start_time ASC
 type GetAllSessionHistoryByRangeParams struct {
 	StartTime time.Time
 	EndTime   time.Time
+	Device    sql.NullString
 	Limit     int64
 }
 
 func (q *Queries) GetAllSessionHistoryByRange(ctx context.Context, arg GetAllSessionHistoryByRangeParams) ([]SessionHistory, error) {
-	rows, err := q.db.QueryContext(ctx, getAllSessionHistoryByRange, arg.StartTime, arg.EndTime, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getAllSessionHistoryByRange,
+		arg.StartTime,
+		arg.EndTime,
+		arg.Device,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -146,6 +175,8 @@ func (q *Queries) GetAllSessionHistoryByRange(ctx context.Context, arg GetAllSes
 			&i.StartTime,
 			&i.EndTime,
 			&i.DurationSeconds,
+			&i.Metadata,
+			&i.Device,
 		); err != nil {
 			return nil, err
 		}
@@ -173,7 +204,7 @@ func (q *Queries) GetCountOfSessionsForProgram(ctx context.Context, programName
 }
 
 const getLastSessionForProgram = `-- name: GetLastSessionForProgram :one
-SELECT id, program_name, start_time, end_time, duration_seconds FROM session_history
+SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM session_history
 WHERE session_history.program_name = ?
 ORDER BY end_time DESC
 LIMIT 1
@@ -188,14 +219,69 @@ func (q *Queries) GetLastSessionForProgram(ctx context.Context, programName stri
 		&i.StartTime,
 		&i.EndTime,
 		&i.DurationSeconds,
+		&i.Metadata,
+		&i.Device,
 	)
 	return i, err
 }
 
+const getSessionMetadata = `-- name: GetSessionMetadata :one
+SELECT metadata FROM session_history
+WHERE id = ?
+`
+
+func (q *Queries) GetSessionMetadata(ctx context.Context, id int64) (sql.NullString, error) {
+	row := q.db.QueryRowContext(ctx, getSessionMetadata, id)
+	var metadata sql.NullString
+	err := row.Scan(&metadata)
+	return metadata, err
+}
+
+const getRecentSessionsPerProgram = `-- name: GetRecentSessionsPerProgram :many
+SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM (
+    SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device, ROW_NUMBER() OVER (PARTITION BY program_name ORDER BY end_time DESC) AS recency
+    FROM session_history
+) AS ranked
+WHERE recency <= ?
+ORDER BY program_name, end_time ASC
+`
+
+func (q *Queries) GetRecentSessionsPerProgram(ctx context.Context, perProgram int64) ([]SessionHistory, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentSessionsPerProgram, perProgram)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SessionHistory
+	for rows.Next() {
+		var i SessionHistory
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProgramName,
+			&i.StartTime,
+			&i.EndTime,
+			&i.DurationSeconds,
+			&i.Metadata,
+			&i.Device,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getSessionHistory = `-- name: GetSessionHistory :many
-SELECT id, program_name, start_time, end_time, duration_seconds FROM (
-    SELECT id, program_name, start_time, end_time, duration_seconds FROM session_history
+SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM (
+    SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM session_history
     WHERE program_name = ?
+      AND IFNULL(?, '') IN ('', device)
     ORDER BY end_time DESC
     LIMIT ?
 ) AS results
@@ -204,11 +290,12 @@ ORDER BY end_time ASC
 
 type GetSessionHistoryParams struct {
 	ProgramName string
+	Device      sql.NullString
 	Limit       int64
 }
 
 func (q *Queries) GetSessionHistory(ctx context.Context, arg GetSessionHistoryParams) ([]SessionHistory, error) {
-	rows, err := q.db.QueryContext(ctx, getSessionHistory, arg.ProgramName, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getSessionHistory, arg.ProgramName, arg.Device, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -222,6 +309,8 @@ func (q *Queries) GetSessionHistory(ctx context.Context, arg GetSessionHistoryPa
 			&i.StartTime,
 			&i.EndTime,
 			&i.DurationSeconds,
+			&i.Metadata,
+			&i.Device,
 		); err != nil {
 			return nil, err
 		}
@@ -237,10 +326,11 @@ func (q *Queries) GetSessionHistory(ctx context.Context, arg GetSessionHistoryPa
 }
 
 const getSessionHistoryByDate = `-- name: GetSessionHistoryByDate :many
-SELECT id, program_name, start_time, end_time, duration_seconds FROM (
-    SELECT id, program_name, start_time, end_time, duration_seconds FROM session_history
-    WHERE program_name = ? 
+SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM (
+    SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM session_history
+    WHERE program_name = ?
       AND start_time <= ? AND end_time >= ?
+      AND IFNULL(?, '') IN ('', device)
     ORDER BY start_time DESC
     LIMIT ?
 ) AS results
@@ -251,6 +341,7 @@ type GetSessionHistoryByDateParams struct {
 	ProgramName string
 	StartTime   time.Time
 	EndTime     time.Time
+	Device      sql.NullString
 	Limit       int64
 }
 
@@ -259,6 +350,7 @@ func (q *Queries) GetSessionHistoryByDate(ctx context.Context, arg GetSessionHis
 		arg.ProgramName,
 		arg.StartTime,
 		arg.EndTime,
+		arg.Device,
 		arg.Limit,
 	)
 	if err != nil {
@@ -274,6 +366,8 @@ func (q *Queries) GetSessionHistoryByDate(ctx context.Context, arg GetSessionHis
 			&i.StartTime,
 			&i.EndTime,
 			&i.DurationSeconds,
+			&i.Metadata,
+			&i.Device,
 		); err != nil {
 			return nil, err
 		}
@@ -289,10 +383,11 @@ func (q *Queries) GetSessionHistoryByDate(ctx context.Context, arg GetSessionHis
 }
 
 const getSessionHistoryByRange = `-- name: GetSessionHistoryByRange :many
-SELECT id, program_name, start_time, end_time, duration_seconds FROM (
-    SELECT id, program_name, start_time, end_time, duration_seconds FROM session_history
+SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM (
+    SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM session_history
     WHERE program_name = ?
       AND start_time <= ? AND end_time >= ?
+      AND IFNULL(?, '') IN ('', device)
     ORDER BY start_time DESC
     LIMIT ?
 ) AS results
@@ -303,6 +398,7 @@ type GetSessionHistoryByRangeParams struct {
 	ProgramName string
 	StartTime   time.Time
 	EndTime     time.Time
+	Device      sql.NullString
 	Limit       int64
 }
 
@@ -311,6 +407,7 @@ func (q *Queries) GetSessionHistoryByRange(ctx context.Context, arg GetSessionHi
 		arg.ProgramName,
 		arg.StartTime,
 		arg.EndTime,
+		arg.Device,
 		arg.Limit,
 	)
 	if err != nil {
@@ -326,6 +423,8 @@ func (q *Queries) GetSessionHistoryByRange(ctx context.Context, arg GetSessionHi
 			&i.StartTime,
 			&i.EndTime,
 			&i.DurationSeconds,
+			&i.Metadata,
+			&i.Device,
 		); err != nil {
 			return nil, err
 		}
@@ -358,3 +457,19 @@ func (q *Queries) RemoveRecordsForProgram(ctx context.Context, programName strin
 	_, err := q.db.ExecContext(ctx, removeRecordsForProgram, programName)
 	return err
 }
+
+const updateSessionMetadata = `-- name: UpdateSessionMetadata :exec
+UPDATE session_history
+SET metadata = ?
+WHERE id = ?
+`
+
+type UpdateSessionMetadataParams struct {
+	Metadata sql.NullString
+	ID       int64
+}
+
+func (q *Queries) UpdateSessionMetadata(ctx context.Context, arg UpdateSessionMetadataParams) error {
+	_, err := q.db.ExecContext(ctx, updateSessionMetadata, arg.Metadata, arg.ID)
+	return err
+}
@@ -0,0 +1,111 @@
+package database
+
+// Hand-written streaming queries. sqlc only generates queries that collect every row into a slice, so these live
+// outside the generated files and hand each row to a callback instead
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const streamSessionHistory = `
+SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM session_history
+WHERE IFNULL(?, '') IN ('', program_name)
+  AND start_time <= ? AND end_time >= ?
+  AND IFNULL(?, '') IN ('', device)
+ORDER BY start_time ASC
+`
+
+type StreamSessionHistoryParams struct {
+	ProgramName sql.NullString
+	RangeEnd    time.Time
+	RangeStart  time.Time
+	Device      sql.NullString
+}
+
+// Calls fn for each session history row matching arg, oldest first. Stops at and returns the first error from fn
+func (q *Queries) StreamSessionHistory(ctx context.Context, arg StreamSessionHistoryParams, fn func(SessionHistory) error) error {
+	rows, err := q.db.QueryContext(ctx, streamSessionHistory,
+		arg.ProgramName,
+		arg.RangeEnd,
+		arg.RangeStart,
+		arg.Device,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i SessionHistory
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProgramName,
+			&i.StartTime,
+			&i.EndTime,
+			&i.DurationSeconds,
+			&i.Metadata,
+			&i.Device,
+		); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+const streamArchivedSessions = `
+SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device, archived_at FROM session_archive
+WHERE IFNULL(?, '') IN ('', program_name)
+  AND start_time <= ? AND end_time >= ?
+  AND IFNULL(?, '') IN ('', device)
+ORDER BY start_time ASC
+`
+
+type StreamArchivedSessionsParams struct {
+	ProgramName sql.NullString
+	RangeEnd    time.Time
+	RangeStart  time.Time
+	Device      sql.NullString
+}
+
+// Calls fn for each archived session matching arg, oldest first. Stops at and returns the first error from fn
+func (q *Queries) StreamArchivedSessions(ctx context.Context, arg StreamArchivedSessionsParams, fn func(SessionArchive) error) error {
+	rows, err := q.db.QueryContext(ctx, streamArchivedSessions,
+		arg.ProgramName,
+		arg.RangeEnd,
+		arg.RangeStart,
+		arg.Device,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i SessionArchive
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProgramName,
+			&i.StartTime,
+			&i.EndTime,
+			&i.DurationSeconds,
+			&i.Metadata,
+			&i.Device,
+			&i.ArchivedAt,
+		); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
@@ -11,8 +11,9 @@ import (
 )
 
 const addProgram = `-- name: AddProgram :exec
-INSERT OR IGNORE INTO tracked_programs (name, category, project)
+INSERT INTO tracked_programs (name, category, project)
 VALUES (?, ?, ?)
+ON CONFLICT (name) DO UPDATE SET remote = FALSE
 `
 
 type AddProgramParams struct {
@@ -26,6 +27,22 @@ func (q *Queries) AddProgram(ctx context.Context, arg AddProgramParams) error {
 	return err
 }
 
+const addRemoteProgram = `-- name: AddRemoteProgram :exec
+INSERT OR IGNORE INTO tracked_programs (name, category, project, remote)
+VALUES (?, ?, ?, TRUE)
+`
+
+type AddRemoteProgramParams struct {
+	Name     string
+	Category sql.NullString
+	Project  sql.NullString
+}
+
+func (q *Queries) AddRemoteProgram(ctx context.Context, arg AddRemoteProgramParams) error {
+	_, err := q.db.ExecContext(ctx, addRemoteProgram, arg.Name, arg.Category, arg.Project)
+	return err
+}
+
 const getAllProgramNames = `-- name: GetAllProgramNames :many
 SELECT name FROM tracked_programs
 `
@@ -54,7 +71,7 @@ func (q *Queries) GetAllProgramNames(ctx context.Context) ([]string, error) {
 }
 
 const getAllPrograms = `-- name: GetAllPrograms :many
-SELECT id, name, lifetime_seconds, category, project FROM tracked_programs
+SELECT id, name, lifetime_seconds, category, project, remote, session_count FROM tracked_programs
 `
 
 func (q *Queries) GetAllPrograms(ctx context.Context) ([]TrackedProgram, error) {
@@ -72,6 +89,8 @@ func (q *Queries) GetAllPrograms(ctx context.Context) ([]TrackedProgram, error)
 			&i.LifetimeSeconds,
 			&i.Category,
 			&i.Project,
+			&i.Remote,
+			&i.SessionCount,
 		); err != nil {
 			return nil, err
 		}
@@ -87,7 +106,7 @@ func (q *Queries) GetAllPrograms(ctx context.Context) ([]TrackedProgram, error)
 }
 
 const getProgramByName = `-- name: GetProgramByName :one
-SELECT id, name, lifetime_seconds, category, project FROM tracked_programs
+SELECT id, name, lifetime_seconds, category, project, remote, session_count FROM tracked_programs
 WHERE name = ?
 `
 
@@ -100,10 +119,62 @@ func (q *Queries) GetProgramByName(ctx context.Context, name string) (TrackedPro
 		&i.LifetimeSeconds,
 		&i.Category,
 		&i.Project,
+		&i.Remote,
+		&i.SessionCount,
 	)
 	return i, err
 }
 
+const recalculateAllLifetimes = `-- name: RecalculateAllLifetimes :exec
+UPDATE tracked_programs
+SET lifetime_seconds = (
+    SELECT COALESCE(SUM(duration_seconds), 0) FROM session_history
+    WHERE session_history.program_name = tracked_programs.name AND IFNULL(session_history.device, '') IN ('', ?1)
+) + (
+    SELECT COALESCE(SUM(duration_seconds), 0) FROM session_archive
+    WHERE session_archive.program_name = tracked_programs.name AND IFNULL(session_archive.device, '') IN ('', ?1)
+), session_count = (
+    SELECT COUNT(*) FROM session_history
+    WHERE session_history.program_name = tracked_programs.name AND IFNULL(session_history.device, '') IN ('', ?1)
+) + (
+    SELECT COUNT(*) FROM session_archive
+    WHERE session_archive.program_name = tracked_programs.name AND IFNULL(session_archive.device, '') IN ('', ?1)
+)
+`
+
+func (q *Queries) RecalculateAllLifetimes(ctx context.Context, device string) error {
+	_, err := q.db.ExecContext(ctx, recalculateAllLifetimes, device)
+	return err
+}
+
+const recalculateLifetimeForProgram = `-- name: RecalculateLifetimeForProgram :exec
+UPDATE tracked_programs
+SET lifetime_seconds = (
+    SELECT COALESCE(SUM(duration_seconds), 0) FROM session_history
+    WHERE session_history.program_name = tracked_programs.name AND IFNULL(session_history.device, '') IN ('', ?1)
+) + (
+    SELECT COALESCE(SUM(duration_seconds), 0) FROM session_archive
+    WHERE session_archive.program_name = tracked_programs.name AND IFNULL(session_archive.device, '') IN ('', ?1)
+), session_count = (
+    SELECT COUNT(*) FROM session_history
+    WHERE session_history.program_name = tracked_programs.name AND IFNULL(session_history.device, '') IN ('', ?1)
+) + (
+    SELECT COUNT(*) FROM session_archive
+    WHERE session_archive.program_name = tracked_programs.name AND IFNULL(session_archive.device, '') IN ('', ?1)
+)
+WHERE name = ?2
+`
+
+type RecalculateLifetimeForProgramParams struct {
+	Device string
+	Name   string
+}
+
+func (q *Queries) RecalculateLifetimeForProgram(ctx context.Context, arg RecalculateLifetimeForProgramParams) error {
+	_, err := q.db.ExecContext(ctx, recalculateLifetimeForProgram, arg.Device, arg.Name)
+	return err
+}
+
 const removeAllPrograms = `-- name: RemoveAllPrograms :exec
 DELETE FROM tracked_programs
 `
@@ -125,7 +196,7 @@ func (q *Queries) RemoveProgram(ctx context.Context, name string) error {
 
 const resetAllLifetimes = `-- name: ResetAllLifetimes :exec
 UPDATE tracked_programs 
-SET lifetime_seconds = 0
+SET lifetime_seconds = 0, session_count = 0
 `
 
 func (q *Queries) ResetAllLifetimes(ctx context.Context) error {
@@ -135,7 +206,7 @@ func (q *Queries) ResetAllLifetimes(ctx context.Context) error {
 
 const resetLifetimeForProgram = `-- name: ResetLifetimeForProgram :exec
 UPDATE tracked_programs 
-SET lifetime_seconds = 0 
+SET lifetime_seconds = 0, session_count = 0
 WHERE name = ?
 `
 
@@ -162,7 +233,7 @@ func (q *Queries) UpdateCategory(ctx context.Context, arg UpdateCategoryParams)
 
 const updateLifetime = `-- name: UpdateLifetime :exec
 UPDATE tracked_programs
-SET lifetime_seconds = lifetime_seconds + ?
+SET lifetime_seconds = lifetime_seconds + ?, session_count = session_count + 1
 WHERE name = ?
 `
 
@@ -0,0 +1,142 @@
+// Package dates parses the dates accepted by date filter flags, absolute or relative to now, into spans of time
+package dates
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Returned, wrapped, for values that aren't an accepted date
+var ErrInvalidDate = errors.New("invalid date")
+
+// Formats listed in parse errors
+const Accepted = "YYYY-MM-DD, YYYY-MM, today, yesterday, this week, last week, last <weekday> (e.g. last monday), <N>d or <N>w (e.g. 7d, the last 7 days including today)"
+
+// Most days a count like "7d" or "2w" may cover, about a century. Larger counts would overflow time arithmetic
+const MaxDays = 36500
+
+// Display preferences affecting how dates are read
+type Options struct {
+	WeekStart  time.Weekday // First day of "this week" and "last week"
+	DateLayout string       // Additional layout accepted for days, such as "02/01/2006", ignored if empty
+}
+
+// Time covered by a parsed date, from the start of its first day up to, not including, End
+type Span struct {
+	Start time.Time
+	End   time.Time
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Returns the weekday named by name, such as "monday"
+func ParseWeekday(name string) (time.Weekday, bool) {
+	weekday, ok := weekdays[strings.ToLower(strings.TrimSpace(name))]
+	return weekday, ok
+}
+
+// Parses value into the span it names, in loc with relative dates counted from now. A day covers midnight to midnight,
+// a week seven days from opts.WeekStart, a month its whole calendar month
+func Parse(value string, now time.Time, loc *time.Location, opts Options) (Span, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch value {
+	case "today":
+		return days(today, 1), nil
+	case "yesterday":
+		return days(today.AddDate(0, 0, -1), 1), nil
+	case "this week":
+		return days(weekStart(today, opts.WeekStart), 7), nil
+	case "last week":
+		return days(weekStart(today, opts.WeekStart).AddDate(0, 0, -7), 7), nil
+	}
+
+	if name, ok := strings.CutPrefix(value, "last "); ok {
+		if weekday, ok := weekdays[strings.TrimSpace(name)]; ok {
+			back := (int(today.Weekday())-int(weekday)+6)%7 + 1 // 1 to 7 days ago, never today
+			return days(today.AddDate(0, 0, -back), 1), nil
+		}
+	}
+
+	if n, unit, ok := count(value); ok {
+		perUnit := 1
+		if unit == 'w' {
+			perUnit = 7
+		}
+		if n > MaxDays/perUnit {
+			return Span{}, fmt.Errorf("%w %q, counts go back at most %d days", ErrInvalidDate, value, MaxDays)
+		}
+		total := n * perUnit
+		return days(today.AddDate(0, 0, 1-total), total), nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return days(t, 1), nil
+	}
+	if t, err := time.ParseInLocation("2006-01", value, loc); err == nil {
+		return Span{Start: t, End: t.AddDate(0, 1, 0)}, nil
+	}
+	if opts.DateLayout != "" {
+		if t, err := time.ParseInLocation(opts.DateLayout, value, loc); err == nil {
+			return days(t, 1), nil
+		}
+	}
+
+	return Span{}, fmt.Errorf("%w %q, accepted formats: %s", ErrInvalidDate, value, Accepted)
+}
+
+// Midnight starting the week containing today
+func weekStart(today time.Time, first time.Weekday) time.Time {
+	back := (int(today.Weekday()) - int(first) + 7) % 7
+	return today.AddDate(0, 0, -back)
+}
+
+// Span of n days from the midnight start
+func days(start time.Time, n int) Span {
+	return Span{Start: start, End: start.AddDate(0, 0, n)}
+}
+
+// Splits a count like "7d" or "2w" into its number and unit
+func count(value string) (int, byte, bool) {
+	if len(value) < 2 {
+		return 0, 0, false
+	}
+	unit := value[len(value)-1]
+	if unit != 'd' && unit != 'w' {
+		return 0, 0, false
+	}
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || n <= 0 {
+		return 0, 0, false
+	}
+	return n, unit, true
+}
+
+// Returns the later of a and b
+func Later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// Returns the earlier of a and b
+func Earlier(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,64 @@
+package dates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Timezone database unavailable: %v", err)
+	}
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	}
+	// Wednesday 12 June 2024, in the afternoon
+	now := time.Date(2024, 6, 12, 15, 30, 0, 0, loc)
+
+	tests := []struct {
+		name  string
+		value string
+		now   time.Time
+		start time.Time
+		end   time.Time
+	}{
+		{name: "today", value: "today", now: now, start: date(2024, 6, 12), end: date(2024, 6, 13)},
+		{name: "yesterday", value: "Yesterday", now: now, start: date(2024, 6, 11), end: date(2024, 6, 12)},
+		{name: "last weekday", value: "last monday", now: now, start: date(2024, 6, 10), end: date(2024, 6, 11)},
+		{name: "last weekday is never today", value: "last wednesday", now: now, start: date(2024, 6, 5), end: date(2024, 6, 6)},
+		{name: "days", value: "7d", now: now, start: date(2024, 6, 6), end: date(2024, 6, 13)},
+		{name: "weeks", value: "2w", now: now, start: date(2024, 5, 30), end: date(2024, 6, 13)},
+		{name: "this week", value: "this week", now: now, start: date(2024, 6, 10), end: date(2024, 6, 17)},
+		{name: "month", value: "2024-06", now: now, start: date(2024, 6, 1), end: date(2024, 7, 1)},
+		{name: "day", value: "2024-06-03", now: now, start: date(2024, 6, 3), end: date(2024, 6, 4)},
+		// Clocks go forward on 10 March 2024, making the day 23 hours long
+		{name: "spring forward day", value: "2024-03-10", now: now, start: date(2024, 3, 10), end: date(2024, 3, 11)},
+		{name: "days across spring forward", value: "3d", now: time.Date(2024, 3, 11, 9, 0, 0, 0, loc), start: date(2024, 3, 9), end: date(2024, 3, 12)},
+		{name: "yesterday across fall back", value: "yesterday", now: time.Date(2024, 11, 4, 0, 30, 0, 0, loc), start: date(2024, 11, 3), end: date(2024, 11, 4)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			span, err := Parse(tc.value, tc.now, loc, Options{WeekStart: time.Monday})
+			assert.Nil(t, err)
+			assert.True(t, tc.start.Equal(span.Start), "start %v, want %v", span.Start, tc.start)
+			assert.True(t, tc.end.Equal(span.End), "end %v, want %v", span.End, tc.end)
+		})
+	}
+
+	span, err := Parse("2024-03-10", now, loc, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, 23*time.Hour, span.End.Sub(span.Start), "A spring forward day should last 23 hours")
+
+	span, err = Parse("36500d", now, loc, Options{})
+	assert.Nil(t, err)
+	assert.True(t, span.Start.Before(span.End))
+
+	for _, value := range []string{"someday", "0d", "-3d", "36501d", "5215w", "999999999999999999d", "9223372036854775807d", "1317624576693539401w"} {
+		_, err = Parse(value, now, loc, Options{})
+		assert.ErrorIs(t, err, ErrInvalidDate, "%q should be refused", value)
+	}
+}
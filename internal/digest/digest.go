@@ -0,0 +1,319 @@
+// Package digest builds the weekly digest: the previous week's total time, its top categories and programs, its focus
+// score and how programs with a daily limit kept to it. The service sends it once a week as a desktop notification, an
+// email or both
+package digest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/dates"
+	"github.com/jms-guy/timekeep/internal/focus"
+	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/jms-guy/timekeep/internal/streaks"
+)
+
+// Programs listed in a digest, by time recorded
+const TopPrograms = 5
+
+// Category time without one is listed under
+const Uncategorized = "uncategorized"
+
+// Time recorded for one category or program
+type Total struct {
+	Name string
+	Time time.Duration
+}
+
+// How a program with a daily limit kept to it over the week
+type LimitOutcome struct {
+	Program  string // Program name, or group:<name> for the limit of a program group
+	Limit    time.Duration
+	DaysOver int // Days the program went past its limit
+	Days     int // Days in the week
+}
+
+// Summary of one week
+type Digest struct {
+	Week       dates.Span
+	Total      time.Duration
+	Previous   time.Duration // Total of the week before
+	Categories []Total       // Every category, most time first
+	Programs   []Total       // The TopPrograms programs with the most time, most first
+	Limits     []LimitOutcome
+	Focus      int // Average focus score of the days with time recorded
+	FocusDays  int // Days with time recorded, 0 leaving Focus unset
+}
+
+// Returns the week the latest digest due by now covers, and whether it's still to be sent given the start of the
+// last week sent, YYYY-MM-DD. A digest is due from digest.time on digest.day, covering the last full week before it
+func Due(cfg *config.Config, lastWeek string, now time.Time) (dates.Span, bool, error) {
+	loc, err := cfg.Location()
+	if err != nil {
+		return dates.Span{}, false, err
+	}
+
+	local := now.In(loc)
+	back := (int(local.Weekday()) - int(cfg.DigestDay()) + 7) % 7
+	at := cfg.DigestTime()
+	scheduled := time.Date(local.Year(), local.Month(), local.Day()-back, int(at/time.Hour), int(at%time.Hour/time.Minute), 0, 0, loc)
+	if local.Before(scheduled) {
+		scheduled = scheduled.AddDate(0, 0, -7)
+	}
+
+	week, err := dates.Parse("last week", scheduled, loc, dates.Options{WeekStart: cfg.WeekStartDay()})
+	if err != nil {
+		return dates.Span{}, false, err
+	}
+	return week, week.Start.Format(time.DateOnly) != lastWeek, nil
+}
+
+// Builds the digest of week from recorded sessions
+func Build(ctx context.Context, pr repository.ProgramRepository, g repository.GroupRepository, h repository.HistoryRepository, cfg *config.Config, week dates.Span) (*Digest, error) {
+	programs, err := pr.GetAllPrograms(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting programs: %w", err)
+	}
+	categories := make(map[string]string, len(programs))
+	for _, program := range programs {
+		categories[program.Name] = program.Category.String
+	}
+	members, err := g.GetAllGroupMembers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting groups: %w", err)
+	}
+	// Limits each program's time counts towards, keyed as in limits.daily
+	limitKeys := make(map[string][]string)
+	for _, program := range programs {
+		if cfg.DailyLimit(program.Name) > 0 {
+			limitKeys[program.Name] = append(limitKeys[program.Name], program.Name)
+		}
+	}
+	for _, member := range members {
+		if key := config.GroupPrefix + member.GroupName; cfg.DailyLimit(key) > 0 {
+			limitKeys[member.ProgramName] = append(limitKeys[member.ProgramName], key)
+		}
+	}
+
+	var days []time.Time
+	for day := week.Start; day.Before(week.End); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+
+	digest := &Digest{Week: week}
+	byCategory := make(map[string]time.Duration)
+	byProgram := make(map[string]time.Duration)
+	limited := make(map[string][]time.Duration) // Time per day of programs and groups with a daily limit
+	var sessions []focus.Session
+
+	previous := week.Start.AddDate(0, 0, -7)
+	params := database.StreamSessionHistoryParams{RangeStart: previous.UTC(), RangeEnd: week.End.UTC()}
+	err = h.StreamSessionHistory(ctx, params, func(session database.SessionHistory) error {
+		digest.Previous += streaks.Within(session, previous, week.Start)
+
+		spent := streaks.Within(session, week.Start, week.End)
+		if spent <= 0 {
+			return nil
+		}
+		digest.Total += spent
+		byProgram[session.ProgramName] += spent
+		sessions = append(sessions, focus.Session{
+			Program:  session.ProgramName,
+			Category: categories[session.ProgramName],
+			Start:    session.StartTime,
+			End:      session.EndTime,
+		})
+
+		category := categories[session.ProgramName]
+		if category == "" {
+			category = Uncategorized
+		}
+		byCategory[category] += spent
+
+		for _, key := range limitKeys[session.ProgramName] {
+			perDay := limited[key]
+			if perDay == nil {
+				perDay = make([]time.Duration, len(days))
+				limited[key] = perDay
+			}
+			for i, day := range days {
+				perDay[i] += streaks.Within(session, day, day.AddDate(0, 0, 1))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading session history: %w", err)
+	}
+
+	var scores int
+	for _, day := range days {
+		if score, ok := focus.Compute(cfg, sessions, day, day.AddDate(0, 0, 1)); ok {
+			scores += score.Score
+			digest.FocusDays++
+		}
+	}
+	if digest.FocusDays > 0 {
+		digest.Focus = int(math.Round(float64(scores) / float64(digest.FocusDays)))
+	}
+
+	digest.Categories = sorted(byCategory)
+	digest.Programs = sorted(byProgram)
+	if len(digest.Programs) > TopPrograms {
+		digest.Programs = digest.Programs[:TopPrograms]
+	}
+
+	var limits map[string]string
+	if cfg != nil {
+		limits = cfg.Limits.Daily
+	}
+	for name := range limits {
+		limit := cfg.DailyLimit(name)
+		if limit == 0 {
+			continue
+		}
+		outcome := LimitOutcome{Program: name, Limit: limit, Days: len(days)}
+		for _, spent := range limited[name] {
+			if spent > limit {
+				outcome.DaysOver++
+			}
+		}
+		digest.Limits = append(digest.Limits, outcome)
+	}
+	sort.Slice(digest.Limits, func(i, j int) bool { return digest.Limits[i].Program < digest.Limits[j].Program })
+
+	return digest, nil
+}
+
+func sorted(totals map[string]time.Duration) []Total {
+	list := make([]Total, 0, len(totals))
+	for name, spent := range totals {
+		list = append(list, Total{Name: name, Time: spent})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Time != list[j].Time {
+			return list[i].Time > list[j].Time
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Title of the digest, naming its week with dates laid out as layout
+func (d *Digest) Title(layout string) string {
+	return fmt.Sprintf("Timekeep weekly digest: %s - %s", d.Week.Start.Format(layout), d.Week.End.AddDate(0, 0, -1).Format(layout))
+}
+
+// Renders the digest as plain text
+func (d *Digest) Text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Total: %s", formatDuration(d.Total))
+	switch {
+	case d.Previous == 0:
+	case d.Total >= d.Previous:
+		fmt.Fprintf(&b, " (%s more than the week before)", formatDuration(d.Total-d.Previous))
+	default:
+		fmt.Fprintf(&b, " (%s less than the week before)", formatDuration(d.Previous-d.Total))
+	}
+	b.WriteString("\n")
+
+	switch {
+	case d.FocusDays == 1:
+		fmt.Fprintf(&b, "Focus: %d/100 on the one day with time recorded\n", d.Focus)
+	case d.FocusDays > 1:
+		fmt.Fprintf(&b, "Focus: %d/100 on average over %d days with time recorded\n", d.Focus, d.FocusDays)
+	}
+
+	if len(d.Categories) > 0 {
+		b.WriteString("\nCategories:\n")
+		for _, category := range d.Categories {
+			fmt.Fprintf(&b, " • %s: %s\n", category.Name, formatDuration(category.Time))
+		}
+	}
+
+	if len(d.Programs) > 0 {
+		b.WriteString("\nTop programs:\n")
+		for i, program := range d.Programs {
+			fmt.Fprintf(&b, " %d. %s: %s\n", i+1, program.Name, formatDuration(program.Time))
+		}
+	}
+
+	if len(d.Limits) > 0 {
+		b.WriteString("\nDaily limits:\n")
+		for _, limit := range d.Limits {
+			fmt.Fprintf(&b, " • %s: kept under %s on %d of %d days\n", limit.Program, formatDuration(limit.Limit), limit.Days-limit.DaysOver, limit.Days)
+		}
+	}
+
+	return b.String()
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// Sends the digest to every destination configured: a desktop notification with digest.desktop, an email with
+// digest.email.smtp. Returns the first failure, after trying every destination
+func Send(ctx context.Context, cfg *config.Config, d *Digest) error {
+	title, body := d.Title(cfg.DateLayout()), d.Text()
+
+	var errs []error
+	if cfg.Digest.Desktop {
+		if err := notify(ctx, title, body); err != nil {
+			errs = append(errs, fmt.Errorf("error showing desktop notification: %w", err))
+		}
+	}
+	if cfg.Digest.Email.SMTP != "" {
+		if err := mail(cfg.Digest.Email, title, body); err != nil {
+			errs = append(errs, fmt.Errorf("error sending email: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Sends the latest digest due by now, unless it was sent already, reporting whether one was sent. The week is
+// recorded as sent even if some destinations failed, so a broken mail server doesn't repeat notifications
+func Run(ctx context.Context, store repository.Store, cfg *config.Config, now time.Time) (bool, error) {
+	if cfg == nil || !cfg.Digest.Enabled {
+		return false, errors.New("weekly digest is disabled, set digest.enabled")
+	}
+
+	lastWeek := ""
+	state, err := store.GetDigestState(ctx)
+	switch {
+	case err == nil:
+		lastWeek = state.LastWeek
+	case !errors.Is(err, sql.ErrNoRows):
+		return false, fmt.Errorf("error getting digest state: %w", err)
+	}
+
+	week, due, err := Due(cfg, lastWeek, now)
+	if err != nil || !due {
+		return false, err
+	}
+
+	digest, err := Build(ctx, store, store, store, cfg, week)
+	if err != nil {
+		return false, err
+	}
+	sendErr := Send(ctx, cfg, digest)
+
+	err = store.SaveDigestState(ctx, database.SaveDigestStateParams{LastWeek: week.Start.Format(time.DateOnly), SentAt: time.Now().UTC()})
+	if err != nil {
+		return true, fmt.Errorf("error saving digest state: %w", err)
+	}
+	return true, sendErr
+}
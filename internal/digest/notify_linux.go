@@ -0,0 +1,20 @@
+//go:build linux
+
+package digest
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Shows a desktop notification with notify-send, which needs the session bus of a logged-in user, as the user service
+// has
+func notify(ctx context.Context, title, body string) error {
+	out, err := exec.CommandContext(ctx, "notify-send", "--app-name=Timekeep", title, body).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("notify-send failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
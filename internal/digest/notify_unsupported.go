@@ -0,0 +1,12 @@
+//go:build !windows && !linux
+
+package digest
+
+import (
+	"context"
+	"errors"
+)
+
+func notify(ctx context.Context, title, body string) error {
+	return errors.New("desktop notifications are not supported on this platform")
+}
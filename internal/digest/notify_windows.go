@@ -0,0 +1,20 @@
+//go:build windows
+
+package digest
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Shows a message box in every session on the machine with msg.exe, which reaches the desktop from the system service
+// as well as from the per-user agent
+func notify(ctx context.Context, title, body string) error {
+	out, err := exec.CommandContext(ctx, "msg.exe", "*", title+"\r\n\r\n"+body).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("msg.exe failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
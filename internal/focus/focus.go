@@ -0,0 +1,186 @@
+// Package focus scores how focused a day was from how its sessions fragment: how often attention switched between
+// contexts, and how much time went into uninterrupted blocks in one context. A context is a category, or for a
+// program without one the program itself
+package focus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// A stretch of time spent in a program
+type Session struct {
+	Program  string
+	Category string
+	Start    time.Time
+	End      time.Time
+}
+
+// Context the session counts towards
+func (s Session) context() string {
+	if s.Category != "" {
+		return s.Category
+	}
+	return s.Program
+}
+
+// Uninterrupted time in one context
+type Block struct {
+	Context string
+	Start   time.Time
+	End     time.Time
+}
+
+// Blocks of one context over a day
+type ContextBlocks struct {
+	Context string
+	Blocks  int
+	Average time.Duration // Average block length
+}
+
+// Focus score of a day, with the figures it's computed from
+type Score struct {
+	Score    int // 0 to 100
+	Switches int // Changes of context from one block to the next
+	Tracked  time.Duration
+	Focused  time.Duration   // Time in blocks of at least focus.min_block
+	Contexts []ContextBlocks // Most time first
+}
+
+// Reads the sessions recorded within [from, to) on device, or on every device when it's empty, filed under their
+// program's category
+func Sessions(ctx context.Context, pr repository.ProgramRepository, h repository.HistoryRepository, from, to time.Time, device string) ([]Session, error) {
+	programs, err := pr.GetAllPrograms(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting programs: %w", err)
+	}
+	categories := make(map[string]string, len(programs))
+	for _, program := range programs {
+		categories[program.Name] = program.Category.String
+	}
+
+	var sessions []Session
+	params := database.StreamSessionHistoryParams{RangeStart: from.UTC(), RangeEnd: to.UTC(), Device: database.NullString(device)}
+	err = h.StreamSessionHistory(ctx, params, func(session database.SessionHistory) error {
+		sessions = append(sessions, Session{
+			Program:  session.ProgramName,
+			Category: categories[session.ProgramName],
+			Start:    session.StartTime,
+			End:      session.EndTime,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading session history: %w", err)
+	}
+	return sessions, nil
+}
+
+// Joins the parts of sessions within [from, to) into blocks, in order. At any moment attention is taken to be on the
+// running session started last, so a short interruption splits a block but the time after it counts again. Blocks of
+// one context with a break of at most gap between them, when nothing else ran, are joined into one
+func Blocks(sessions []Session, from, to time.Time, gap time.Duration) []Block {
+	var clipped []Session
+	var bounds []time.Time
+	for _, session := range sessions {
+		if session.Start.Before(from) {
+			session.Start = from
+		}
+		if session.End.After(to) {
+			session.End = to
+		}
+		if session.End.After(session.Start) {
+			clipped = append(clipped, session)
+			bounds = append(bounds, session.Start, session.End)
+		}
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i].Before(bounds[j]) })
+
+	var blocks []Block
+	for i := 1; i < len(bounds); i++ {
+		start, end := bounds[i-1], bounds[i]
+		if !end.After(start) {
+			continue
+		}
+
+		var current *Session
+		for j := range clipped {
+			session := &clipped[j]
+			if !session.Start.After(start) && !session.End.Before(end) && (current == nil || session.Start.After(current.Start)) {
+				current = session
+			}
+		}
+		if current == nil {
+			continue
+		}
+
+		if n := len(blocks); n > 0 {
+			last := &blocks[n-1]
+			if last.Context == current.context() && !start.After(last.End.Add(gap)) {
+				last.End = end
+				continue
+			}
+		}
+		blocks = append(blocks, Block{Context: current.context(), Start: start, End: end})
+	}
+	return blocks
+}
+
+// Scores the sessions within [from, to) with the parameters in cfg's focus section, reporting false when nothing was
+// recorded. The score weighs the share of time spent in focused blocks against the rate of context switches per hour,
+// which scores nothing at focus.switch_limit
+func Compute(cfg *config.Config, sessions []Session, from, to time.Time) (Score, bool) {
+	blocks := Blocks(sessions, from, to, cfg.FocusMergeGap())
+	if len(blocks) == 0 {
+		return Score{}, false
+	}
+
+	var score Score
+	minBlock := cfg.FocusMinBlock()
+	byContext := make(map[string]*ContextBlocks)
+	spent := make(map[string]time.Duration)
+	for i, block := range blocks {
+		length := block.End.Sub(block.Start)
+		score.Tracked += length
+		if length >= minBlock {
+			score.Focused += length
+		}
+		if i > 0 && blocks[i-1].Context != block.Context {
+			score.Switches++
+		}
+
+		c := byContext[block.Context]
+		if c == nil {
+			c = &ContextBlocks{Context: block.Context}
+			byContext[block.Context] = c
+		}
+		c.Blocks++
+		spent[block.Context] += length
+	}
+	for name, c := range byContext {
+		c.Average = (spent[name] / time.Duration(c.Blocks)).Truncate(time.Second)
+		score.Contexts = append(score.Contexts, *c)
+	}
+	sort.Slice(score.Contexts, func(i, j int) bool {
+		a, b := score.Contexts[i].Context, score.Contexts[j].Context
+		if spent[a] != spent[b] {
+			return spent[a] > spent[b]
+		}
+		return a < b
+	})
+
+	focused := float64(score.Focused) / float64(score.Tracked)
+	rate := float64(score.Switches) / score.Tracked.Hours()
+	steady := math.Max(0, 1-rate/float64(cfg.FocusSwitchLimit()))
+	weight := float64(cfg.FocusBlockWeight()) / 100
+	score.Score = int(math.Round(100 * (weight*focused + (1-weight)*steady)))
+
+	return score, true
+}
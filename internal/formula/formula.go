@@ -0,0 +1,220 @@
+// Package formula parses and evaluates the arithmetic formulas set in config, such as the productivity score's
+// "weighted / total * 10": numbers, named variables, + - * / with parentheses, and the functions min, max and abs
+package formula
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Parsed formula, evaluated against values of its variables
+type Formula struct {
+	src  string
+	eval func(vars map[string]float64) float64
+}
+
+// Functions formulas may call, with the number of arguments each takes
+var functions = map[string]int{"min": 2, "max": 2, "abs": 1}
+
+// Parses src, accepting only the variables in names. Fails on syntax errors and unknown variables or functions
+func Parse(src string, names []string) (*Formula, error) {
+	p := &parser{src: src, names: names}
+	p.next()
+	eval, err := p.sum()
+	if err == nil && p.tok != "" {
+		err = fmt.Errorf("unexpected %q", p.tok)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid formula %q: %w", src, err)
+	}
+	return &Formula{src: src, eval: eval}, nil
+}
+
+// Evaluates the formula with vars, variables missing from it counting as 0. Division by zero gives 0, so a day with
+// no time scores 0 rather than failing
+func (f *Formula) Eval(vars map[string]float64) float64 {
+	return f.eval(vars)
+}
+
+func (f *Formula) String() string {
+	return f.src
+}
+
+type parser struct {
+	src   string
+	pos   int
+	tok   string // Current token, empty at the end
+	names []string
+}
+
+// Reads the next token: a number, a name, or a single character
+func (p *parser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	switch {
+	case p.pos == len(p.src):
+	case isNumber(rune(p.src[p.pos])):
+		for p.pos < len(p.src) && isNumber(rune(p.src[p.pos])) {
+			p.pos++
+		}
+	case isName(rune(p.src[p.pos])):
+		for p.pos < len(p.src) && (isName(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+	p.tok = p.src[start:p.pos]
+}
+
+// sum = product { ("+" | "-") product }
+func (p *parser) sum() (func(map[string]float64) float64, error) {
+	left, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == "+" || p.tok == "-" {
+		op := p.tok
+		p.next()
+		right, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "+" {
+			left = func(v map[string]float64) float64 { return l(v) + right(v) }
+		} else {
+			left = func(v map[string]float64) float64 { return l(v) - right(v) }
+		}
+	}
+	return left, nil
+}
+
+// product = unary { ("*" | "/") unary }
+func (p *parser) product() (func(map[string]float64) float64, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == "*" || p.tok == "/" {
+		op := p.tok
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "*" {
+			left = func(v map[string]float64) float64 { return l(v) * right(v) }
+		} else {
+			left = func(v map[string]float64) float64 {
+				if d := right(v); d != 0 {
+					return l(v) / d
+				}
+				return 0
+			}
+		}
+	}
+	return left, nil
+}
+
+// unary = "-" unary | operand
+func (p *parser) unary() (func(map[string]float64) float64, error) {
+	if p.tok != "-" {
+		return p.operand()
+	}
+	p.next()
+	inner, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return func(v map[string]float64) float64 { return -inner(v) }, nil
+}
+
+// operand = number | name | name "(" sum { "," sum } ")" | "(" sum ")"
+func (p *parser) operand() (func(map[string]float64) float64, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return nil, errors.New("unexpected end")
+	case tok == "(":
+		p.next()
+		inner, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok != ")" {
+			return nil, errors.New("missing )")
+		}
+		p.next()
+		return inner, nil
+	case isNumber(rune(tok[0])):
+		n, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", tok)
+		}
+		p.next()
+		return func(map[string]float64) float64 { return n }, nil
+	case isName(rune(tok[0])):
+		p.next()
+		if p.tok == "(" {
+			return p.call(tok)
+		}
+		if !slices.Contains(p.names, tok) {
+			return nil, fmt.Errorf("unknown variable %q, use %s", tok, strings.Join(p.names, ", "))
+		}
+		return func(v map[string]float64) float64 { return v[tok] }, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", tok)
+}
+
+// Parses the arguments of a call to name, the opening parenthesis being the current token
+func (p *parser) call(name string) (func(map[string]float64) float64, error) {
+	arity, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q, use min, max or abs", name)
+	}
+	var args []func(map[string]float64) float64
+	for {
+		p.next()
+		arg, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.tok != "," {
+			break
+		}
+	}
+	if p.tok != ")" {
+		return nil, fmt.Errorf("missing ) after the arguments of %s", name)
+	}
+	p.next()
+	if len(args) != arity {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", name, arity, len(args))
+	}
+
+	switch name {
+	case "min":
+		return func(v map[string]float64) float64 { return math.Min(args[0](v), args[1](v)) }, nil
+	case "max":
+		return func(v map[string]float64) float64 { return math.Max(args[0](v), args[1](v)) }, nil
+	default:
+		return func(v map[string]float64) float64 { return math.Abs(args[0](v)) }, nil
+	}
+}
+
+func isNumber(r rune) bool {
+	return unicode.IsDigit(r) || r == '.'
+}
+
+func isName(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
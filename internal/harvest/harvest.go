@@ -0,0 +1,389 @@
+// Package harvest creates Harvest time entries from completed sessions, one per session or one per day and task, with
+// the Harvest project and task of each picked by the mappings in config and its hours rounded as rounding sets. Every
+// entry carries a key derived from what it covers, recorded once Harvest accepts it, so pushing the same sessions
+// again never duplicates entries
+package harvest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/jms-guy/timekeep/internal/rounding"
+)
+
+// Harvest's API address, used unless harvest.server is set
+const DefaultServer = "https://api.harvestapp.com"
+
+// Path of Harvest's time entry API, under the server address
+const TimeEntriesPath = "/v2/time_entries"
+
+// Group of the external references set on entries, telling them apart from those of other integrations
+const ReferenceGroup = "timekeep"
+
+// Sent as the User-Agent, which Harvest requires to name the application
+const userAgent = "Timekeep (https://github.com/jms-guy/timekeep)"
+
+// Sessions read from the database at once
+const pageSize = 500
+
+// Layout of spent dates
+const dayLayout = "2006-01-02"
+
+// Body posted to Harvest for an entry
+type TimeEntry struct {
+	ProjectID         int64     `json:"project_id"`
+	TaskID            int64     `json:"task_id"`
+	SpentDate         string    `json:"spent_date"`
+	Hours             float64   `json:"hours"`
+	Notes             string    `json:"notes,omitempty"`
+	ExternalReference Reference `json:"external_reference"`
+}
+
+// External reference of an entry, its ID being the entry's idempotency key
+type Reference struct {
+	ID      string `json:"id"`
+	GroupID string `json:"group_id"`
+}
+
+// Returns the Harvest project and task of a session of program, 0 for either when no mapping or default gives one.
+// The first mapping whose filters all match applies, its unset IDs falling back to harvest.project and harvest.task
+func Assign(cfg config.HarvestConfig, program, category, project string) (int64, int64) {
+	for _, mapping := range cfg.Mappings {
+		if !mapping.Matches(program, category, project) {
+			continue
+		}
+		projectID, taskID := mapping.Project, mapping.Task
+		if projectID == 0 {
+			projectID = cfg.Project
+		}
+		if taskID == 0 {
+			taskID = cfg.Task
+		}
+		return projectID, taskID
+	}
+	return cfg.Project, cfg.Task
+}
+
+// Returns the idempotency key of an entry covering parts, such as a session's device, program and start
+func Key(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return ReferenceGroup + "-" + hex.EncodeToString(sum[:12])
+}
+
+// Returns the entries of this machine's sessions starting from since that haven't been pushed, oldest first.
+// Sessions shorter than harvest.min_duration or given no project and task are left out, and in daily mode those of
+// today, which isn't over. A zero since stands for the last day pushed, or before anything is pushed today (yesterday
+// in daily mode), so enabling Harvest never floods it with earlier history
+func Pending(ctx context.Context, tx repository.TxRepository, cfg *config.Config, now, since time.Time) ([]TimeEntry, error) {
+	loc, err := cfg.Location()
+	if err != nil {
+		return nil, err
+	}
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	daily := cfg.Harvest.Mode == config.HarvestDaily
+	device := cfg.DeviceName()
+	rule := rounding.Of(cfg)
+
+	var entries []TimeEntry
+	err = tx.WithTx(ctx, func(store repository.Store) error {
+		if since.IsZero() {
+			last, err := store.GetLastHarvestDay(ctx)
+			if err != nil {
+				return fmt.Errorf("error getting last Harvest day: %w", err)
+			}
+			switch {
+			case last != "":
+				if since, err = time.ParseInLocation(dayLayout, last, loc); err != nil {
+					return fmt.Errorf("invalid last Harvest day %q: %w", last, err)
+				}
+			case daily:
+				since = today.AddDate(0, 0, -1)
+			default:
+				since = today
+			}
+		}
+
+		keys, err := store.GetHarvestKeysSince(ctx, since.In(loc).Format(dayLayout))
+		if err != nil {
+			return fmt.Errorf("error getting pushed Harvest entries: %w", err)
+		}
+		pushed := make(map[string]bool, len(keys))
+		for _, key := range keys {
+			pushed[key] = true
+		}
+
+		var days []*day
+		byKey := make(map[string]*day)
+		var afterID int64
+		for {
+			rows, err := store.GetSessionsToExport(ctx, database.GetSessionsToExportParams{
+				AfterID: afterID,
+				Since:   since.UTC(),
+				Device:  device,
+				Max:     pageSize,
+			})
+			if err != nil {
+				return fmt.Errorf("error getting sessions to push: %w", err)
+			}
+
+			for _, row := range rows {
+				afterID = row.ID
+				duration := time.Duration(row.DurationSeconds) * time.Second
+				if duration < cfg.Harvest.MinSession() {
+					continue
+				}
+				project, task := Assign(cfg.Harvest, row.ProgramName, row.Category.String, row.Project.String)
+				if project == 0 || task == 0 {
+					continue
+				}
+				start, end := row.StartTime.In(loc), row.EndTime.In(loc)
+				spent := start.Format(dayLayout)
+
+				if !daily {
+					key := Key(device, row.ProgramName, row.StartTime.UTC().Format(time.RFC3339))
+					if hours := roundHours(rule.Round(duration)); hours > 0 && !pushed[key] {
+						entries = append(entries, TimeEntry{
+							ProjectID:         project,
+							TaskID:            task,
+							SpentDate:         spent,
+							Hours:             hours,
+							Notes:             fmt.Sprintf("%s %s-%s", row.ProgramName, start.Format("15:04"), end.Format("15:04")),
+							ExternalReference: Reference{ID: key, GroupID: ReferenceGroup},
+						})
+					}
+					continue
+				}
+
+				if !start.Before(today) {
+					continue
+				}
+				key := Key(device, spent, fmt.Sprint(project), fmt.Sprint(task))
+				if pushed[key] {
+					continue
+				}
+				d, ok := byKey[key]
+				if !ok {
+					d = &day{key: key, spent: spent, project: project, task: task, programs: make(map[string]time.Duration)}
+					byKey[key] = d
+					days = append(days, d)
+				}
+				d.programs[row.ProgramName] += duration
+				d.sessions = append(d.sessions, duration)
+			}
+			if len(rows) < pageSize {
+				break
+			}
+		}
+
+		sort.SliceStable(days, func(i, j int) bool { return days[i].spent < days[j].spent })
+		for _, d := range days {
+			if entry, ok := d.entry(rule); ok {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Sessions of a day under one project and task, for daily mode
+type day struct {
+	key      string
+	spent    string
+	project  int64
+	task     int64
+	programs map[string]time.Duration
+	sessions []time.Duration
+}
+
+// Returns the day's entry, its hours rounded by rule and its notes listing the exact time of each program, longest
+// first
+func (d *day) entry(rule rounding.Rule) (TimeEntry, bool) {
+	names := make([]string, 0, len(d.programs))
+	for name := range d.programs {
+		names = append(names, name)
+	}
+	hours := roundHours(rule.Day(d.sessions))
+	if hours == 0 {
+		return TimeEntry{}, false
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if d.programs[names[i]] != d.programs[names[j]] {
+			return d.programs[names[i]] > d.programs[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	notes := make([]string, len(names))
+	for i, name := range names {
+		notes[i] = name + " " + formatMinutes(d.programs[name])
+	}
+	return TimeEntry{
+		ProjectID:         d.project,
+		TaskID:            d.task,
+		SpentDate:         d.spent,
+		Hours:             hours,
+		Notes:             strings.Join(notes, ", "),
+		ExternalReference: Reference{ID: d.key, GroupID: ReferenceGroup},
+	}, true
+}
+
+// Formats d in hours and minutes, such as 1h 30m
+func formatMinutes(d time.Duration) string {
+	minutes := int64(d.Round(time.Minute).Minutes())
+	switch {
+	case minutes < 60:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	}
+	return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+}
+
+// Hours of d to two decimals, as Harvest keeps them
+func roundHours(d time.Duration) float64 {
+	return math.Round(d.Hours()*100) / 100
+}
+
+// Creates entries in Harvest in order, recording each once Harvest accepts it. An entry Harvest already holds, such
+// as one created just before an interrupted push could record it, is recorded without being created again. Returns
+// how many were created
+func Send(ctx context.Context, tx repository.TxRepository, cfg config.HarvestConfig, entries []TimeEntry) (int, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	created := 0
+	for _, entry := range entries {
+		id, err := find(ctx, client, cfg, entry.ExternalReference.ID)
+		if err != nil {
+			return created, fmt.Errorf("error checking Harvest for the entry of %s: %w", entry.SpentDate, err)
+		}
+		if id == 0 {
+			if id, err = create(ctx, client, cfg, entry); err != nil {
+				return created, fmt.Errorf("error creating the entry of %s: %w", entry.SpentDate, err)
+			}
+			created++
+		}
+
+		err = tx.WithTx(ctx, func(store repository.Store) error {
+			return store.AddHarvestEntry(ctx, database.AddHarvestEntryParams{
+				Key:       entry.ExternalReference.ID,
+				EntryID:   id,
+				SpentDate: entry.SpentDate,
+				PushedAt:  time.Now().UTC(),
+			})
+		})
+		if err != nil {
+			return created, fmt.Errorf("error recording Harvest entry: %w", err)
+		}
+	}
+	return created, nil
+}
+
+// Pushes the pending entries when Harvest is enabled, returning how many were created
+func Run(ctx context.Context, tx repository.TxRepository, cfg *config.Config, now, since time.Time) (int, error) {
+	if cfg == nil || !cfg.Harvest.Enabled {
+		return 0, errors.New("harvest is disabled, set harvest.enabled, harvest.account_id and harvest.token")
+	}
+	if cfg.Harvest.AccountID == "" || cfg.Harvest.Token == "" {
+		return 0, errors.New("no Harvest account configured, set harvest.account_id and harvest.token")
+	}
+
+	entries, err := Pending(ctx, tx, cfg, now, since)
+	if err != nil {
+		return 0, err
+	}
+	return Send(ctx, tx, cfg.Harvest, entries)
+}
+
+// Returns the ID of the entry in Harvest with the external reference key, 0 if there's none
+func find(ctx context.Context, client *http.Client, cfg config.HarvestConfig, key string) (int64, error) {
+	var page struct {
+		TimeEntries []struct {
+			ID                int64      `json:"id"`
+			ExternalReference *Reference `json:"external_reference"`
+		} `json:"time_entries"`
+	}
+	query := url.Values{"external_reference_id": {key}}
+	if err := do(ctx, client, cfg, http.MethodGet, TimeEntriesPath+"?"+query.Encode(), nil, &page); err != nil {
+		return 0, err
+	}
+
+	// Compared again, so a Harvest ignoring the filter can't pass another entry off as this one
+	for _, entry := range page.TimeEntries {
+		if ref := entry.ExternalReference; ref != nil && ref.ID == key {
+			return entry.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// Creates entry in Harvest, returning its ID
+func create(ctx context.Context, client *http.Client, cfg config.HarvestConfig, entry TimeEntry) (int64, error) {
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := do(ctx, client, cfg, http.MethodPost, TimeEntriesPath, entry, &created); err != nil {
+		return 0, err
+	}
+	return created.ID, nil
+}
+
+func do(ctx context.Context, client *http.Client, cfg config.HarvestConfig, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	server := cfg.Server
+	if server == "" {
+		server = DefaultServer
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(server, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	req.Header.Set("Harvest-Account-Id", cfg.AccountID)
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("harvest returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error reading Harvest response: %w", err)
+	}
+	return nil
+}
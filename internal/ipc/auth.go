@@ -0,0 +1,112 @@
+package ipc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Environment variable used to hand the token to child processes, such as the Windows monitor scripts
+const TokenEnv = "TIMEKEEP_TOKEN"
+
+// Generates a new random token for this service run and writes it to the token file, readable only by
+// clients allowed to talk to the service
+func CreateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+
+	path, err := TokenPath()
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeTokenFile(path, token); err != nil {
+		return "", fmt.Errorf("failed to write token file: %w", err)
+	}
+
+	return token, nil
+}
+
+// Reads the token written by the running service, after checking the file is the service's own
+func LoadToken() (string, error) {
+	path, err := TokenPath()
+	if err != nil {
+		return "", err
+	}
+	if err := checkTokenFile(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("refusing service token: %w", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service token (is the service running, and for this user?): %w", err)
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Reports whether got matches the expected token, in constant time
+func ValidToken(expected, got string) bool {
+	if expected == "" || got == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(got)) == 1
+}
+
+// Scopes of API tokens created with "timekeep token create"
+const (
+	ScopeRead  = "read"  // Query service state only
+	ScopeSync  = "sync"  // Upload sessions to a sync server, no service actions
+	ScopeAdmin = "admin" // Every action, as with the service or remote token
+)
+
+// Prefix of API tokens, telling them apart from the service and remote tokens
+const APITokenPrefix = "tk_"
+
+// Actions a read-only API token may perform
+var readActions = map[string]bool{
+	ActionQueryActive: true,
+	ActionHealth:      true,
+	ActionMetrics:     true,
+}
+
+// Generates a new API token. Only its hash is stored, so it is shown once on creation
+func NewAPIToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return APITokenPrefix + hex.EncodeToString(b), nil
+}
+
+// Returns the hash under which an API token is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Reports whether scope is a known API token scope
+func ValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeSync || scope == ScopeAdmin
+}
+
+// Reports whether a connection authenticated with scope may perform action
+func Permits(scope, action string) bool {
+	switch scope {
+	case ScopeAdmin:
+		return true
+	case ScopeRead:
+		return readActions[action]
+	default:
+		return false
+	}
+}
@@ -0,0 +1,170 @@
+package ipc
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// Default time allowed for a request/response round trip
+const DefaultTimeout = 5 * time.Second
+
+// Default port the service listens on for remote connections
+const DefaultRemotePort = "7780"
+
+// Remote service to send requests to over TLS
+type RemoteTarget struct {
+	Addr     string // host or host:port
+	Token    string // Remote token configured on the service
+	CAFile   string // PEM certificate(s) to trust, such as the service's self-signed certificate
+	Insecure bool   // Skip certificate verification
+}
+
+// Matched by errors.Is for failures to connect to the service, as opposed to failed requests
+var ErrUnreachable = errors.New("service unreachable")
+
+// Connection failure, keeping the underlying error's message while matching ErrUnreachable
+type unreachableError struct {
+	err error
+}
+
+func (e unreachableError) Error() string        { return e.err.Error() }
+func (e unreachableError) Unwrap() error        { return e.err }
+func (e unreachableError) Is(target error) bool { return target == ErrUnreachable }
+
+// Marks err as a failure to connect to the service
+func unreachable(err error) error {
+	return unreachableError{err: err}
+}
+
+// Sends a request to the running service and waits for its response
+func Call(req Request) (Response, error) {
+	if req.Token == "" {
+		token, err := LoadToken()
+		if err != nil {
+			return Response{}, unreachable(err)
+		}
+		req.Token = token
+	}
+
+	conn, err := Dial()
+	if err != nil {
+		return Response{}, err
+	}
+	defer conn.Close()
+
+	return roundTrip(conn, req)
+}
+
+// Sends a request to a service on another machine over TLS and waits for its response
+func CallRemote(target RemoteTarget, req Request) (Response, error) {
+	if target.Token == "" {
+		return Response{}, fmt.Errorf("remote token required")
+	}
+	req.Token = target.Token
+
+	addr := target.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, DefaultRemotePort)
+	}
+
+	tlsConfig, err := target.tlsConfig()
+	if err != nil {
+		return Response{}, err
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: DefaultTimeout}, "tcp", addr, tlsConfig)
+	if err != nil {
+		return Response{}, unreachable(fmt.Errorf("failed to connect to %s: %w", addr, err))
+	}
+	defer conn.Close()
+
+	return roundTrip(conn, req)
+}
+
+// Writes a request line and reads one response line
+func roundTrip(conn net.Conn, req Request) (Response, error) {
+	if req.Version == 0 {
+		req.Version = ProtocolVersion
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(DefaultTimeout)); err != nil {
+		return Response{}, fmt.Errorf("failed to set connection deadline: %w", err)
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, fmt.Errorf("failed to write request: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return Response{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if err := resp.Negotiate(); err != nil {
+		return Response{}, err
+	}
+
+	return resp, nil
+}
+
+// Builds the client TLS config. With a CA file the server certificate must chain to it, without checking
+// the host name, so a copied self-signed service certificate can be trusted directly
+func (t RemoteTarget) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if t.Insecure {
+		cfg.InsecureSkipVerify = true // #nosec G402 -- explicitly requested by the user
+		return cfg, nil
+	}
+
+	if t.CAFile == "" {
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(t.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", t.CAFile)
+	}
+
+	cfg.InsecureSkipVerify = true // #nosec G402 -- chain verified against the given roots below
+	cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("server sent no certificate")
+		}
+
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("invalid server certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+
+	return cfg, nil
+}
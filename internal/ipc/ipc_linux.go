@@ -0,0 +1,161 @@
+//go:build linux
+
+package ipc
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/jms-guy/timekeep/internal/profile"
+	"golang.org/x/sys/unix"
+)
+
+// Shared runtime directory, writable by every user. Each user's service keeps its socket and token in an
+// owner-only directory inside it, named after the uid
+const SocketDir = "/var/run/timekeep"
+
+// Runtime directory of the current user's service
+func UserDir() string {
+	return filepath.Join(SocketDir, strconv.Itoa(os.Getuid()))
+}
+
+// Unix socket path for the current user's service
+func SocketPath() string {
+	return filepath.Join(UserDir(), profile.Suffixed("timekeep")+".sock")
+}
+
+// Token file path for the current user's service
+func TokenPath() (string, error) {
+	return filepath.Join(UserDir(), profile.Suffixed("timekeep")+".token"), nil
+}
+
+// Creates the user's runtime directory, owner-only. As any user can write to SocketDir, another may have created
+// the directory first, so an existing one must be a real directory owned by this user and closed to others
+func PrepareUserDir() error {
+	dir := UserDir()
+	// #nosec G301 -- shared directory, the per-user directory below is owner-only
+	if err := os.MkdirAll(SocketDir, 0o755); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, 0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if err := ownedByUser(info); err != nil {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("%s is accessible to other users (mode %v)", dir, info.Mode().Perm())
+	}
+	return nil
+}
+
+// Fails unless info describes a file owned by the current user
+func ownedByUser(info fs.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("cannot read file owner")
+	}
+	if int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("owned by another user (uid %d)", st.Uid)
+	}
+	return nil
+}
+
+// Connects to the unix socket opened by the service, refusing sockets not owned by this user or root
+func Dial() (net.Conn, error) {
+	conn, err := net.Dial("unix", SocketPath())
+	if err != nil {
+		return nil, unreachable(fmt.Errorf("failed to connect to socket: %v", err))
+	}
+
+	uid, err := PeerUID(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if uid != os.Getuid() && uid != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("socket %s is served by another user (uid %d)", SocketPath(), uid)
+	}
+
+	return conn, nil
+}
+
+// Returns the uid of the process on the other end of a unix socket connection
+func PeerUID(conn net.Conn) (int, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return -1, fmt.Errorf("not a unix socket connection")
+	}
+
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return -1, fmt.Errorf("failed to get socket descriptor: %w", err)
+	}
+
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return -1, fmt.Errorf("failed to read peer credentials: %w", err)
+	}
+	if credErr != nil {
+		return -1, fmt.Errorf("failed to read peer credentials: %w", credErr)
+	}
+
+	return int(cred.Uid), nil
+}
+
+// Writes the token readable by the owner only, as a new file in the user's runtime directory
+func writeTokenFile(path, token string) error {
+	if err := PrepareUserDir(); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale token file: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL|unix.O_NOFOLLOW, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(token); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Fails unless the token file is a regular file owned by the current user and closed to others, so a token
+// planted by another user is never trusted
+func checkTokenFile(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("token file %s is not a regular file", path)
+	}
+	if err := ownedByUser(info); err != nil {
+		return fmt.Errorf("token file %s: %w", path, err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("token file %s is accessible to other users (mode %v)", path, info.Mode().Perm())
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build !windows && !linux
+
+package ipc
+
+import (
+	"fmt"
+	"net"
+)
+
+func Dial() (net.Conn, error) {
+	return nil, fmt.Errorf("service communication not supported on this platform")
+}
+
+func TokenPath() (string, error) {
+	return "", fmt.Errorf("service communication not supported on this platform")
+}
+
+func writeTokenFile(path, token string) error {
+	return fmt.Errorf("service communication not supported on this platform")
+}
+
+func checkTokenFile(path string) error {
+	return fmt.Errorf("service communication not supported on this platform")
+}
@@ -0,0 +1,110 @@
+//go:build windows
+
+package ipc
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/Microsoft/go-winio"
+	"github.com/jms-guy/timekeep/internal/profile"
+	"golang.org/x/sys/windows"
+)
+
+// Environment variable handing the pipe name, without the \\.\pipe\ prefix, to the monitor scripts
+const PipeEnv = "TIMEKEEP_PIPE"
+
+// Name of the selected profile's pipe, without the \\.\pipe\ prefix. Pipes share one namespace across login
+// sessions, so per-user agents include the user's SID
+func PipeBaseName() string {
+	if profile.UserScope() {
+		return profile.Suffixed("Timekeep") + "-" + CurrentUserSID()
+	}
+	return profile.Suffixed("Timekeep")
+}
+
+// Named pipe path for the selected profile's service
+func PipeName() string {
+	return `\\.\pipe\` + PipeBaseName()
+}
+
+// Security descriptor for the service's pipe: full control for SYSTEM and Administrators, and read/write for the
+// user the service serves. A per-user agent's pipe is limited to SYSTEM and its user
+func PipeSecurityDescriptor() string {
+	if profile.UserScope() {
+		return "D:P(A;;GA;;;SY)(A;;GA;;;" + CurrentUserSID() + ")"
+	}
+	return "D:P(A;;GA;;;SY)(A;;GA;;;BA)" + ownerACE("GRGW")
+}
+
+// Security descriptor for the token file, following the pipe's access
+func tokenSecurityDescriptor() string {
+	if profile.UserScope() {
+		return "D:P(A;;FA;;;SY)(A;;FA;;;" + CurrentUserSID() + ")"
+	}
+	return "D:P(A;;FA;;;SY)(A;;FA;;;BA)" + ownerACE("FR")
+}
+
+// Entry granting rights to the system service's owner. Services installed without an owner, or with one that
+// isn't a valid SID, are reachable by SYSTEM and Administrators only
+func ownerACE(rights string) string {
+	sid, err := windows.StringToSid(profile.Owner())
+	if err != nil {
+		return ""
+	}
+	return "(A;;" + rights + ";;;" + sid.String() + ")"
+}
+
+// SID of the user running this process
+func CurrentUserSID() string {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return ""
+	}
+	return user.User.Sid.String()
+}
+
+// Token file path, next to the service's other data
+func TokenPath() (string, error) {
+	return filepath.Join(profile.DataDir(), "ipc.token"), nil
+}
+
+// Connects to the named pipe opened by the service
+func Dial() (net.Conn, error) {
+	conn, err := winio.DialPipe(PipeName(), nil)
+	if err != nil {
+		return nil, unreachable(fmt.Errorf("failed to connect to service pipe: %v", err))
+	}
+	return conn, nil
+}
+
+// Writes the token, replacing inherited file permissions with the restricted descriptor
+func writeTokenFile(path, token string) error {
+	// #nosec G301
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(token), 0o600); err != nil {
+		return err
+	}
+
+	sd, err := windows.SecurityDescriptorFromString(tokenSecurityDescriptor())
+	if err != nil {
+		return fmt.Errorf("invalid token security descriptor: %w", err)
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return fmt.Errorf("failed to read token DACL: %w", err)
+	}
+
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION, nil, nil, dacl, nil)
+}
+
+// The token lives in the service's data directory under a protected DACL, which other users can't write to, and
+// is owned by the service account rather than the CLI's user, so there is no owner to compare
+func checkTokenFile(path string) error {
+	return nil
+}
@@ -0,0 +1,49 @@
+package ipc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Marker file recording a refresh the CLI couldn't deliver, kept next to the token file
+func pendingPath() (string, error) {
+	path, err := TokenPath()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".pending", nil
+}
+
+// Records that a refresh couldn't be delivered, so the service refreshes once it's back up
+func MarkRefreshPending() error {
+	path, err := pendingPath()
+	if err != nil {
+		return err
+	}
+
+	// #nosec G306
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		return fmt.Errorf("failed to write pending refresh marker: %w", err)
+	}
+	return nil
+}
+
+// Removes the pending refresh marker, reporting whether one was set
+func TakeRefreshPending() (bool, error) {
+	path, err := pendingPath()
+	if err != nil {
+		return false, err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to remove pending refresh marker: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,199 @@
+package ipc
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Current version of the CLI <-> service message protocol. Requests without a version are legacy fire-and-forget
+// messages (such as those written by the Windows monitor script) and receive no response. Raised whenever actions or
+// payloads are added, so that a service too old for them refuses the request as from a newer version, naming the
+// side to update, rather than as an unknown action
+//
+//  1. process_start, process_stop, refresh, pause, resume, query_active, reload_config and shutdown
+//  2. health, metrics, reconcile and incognito, and the Pause payload of pause
+const ProtocolVersion = 2
+
+// Oldest protocol version still understood, by the service of requests and by the CLI of responses. Raised when a
+// change drops support for older messages, so that mismatched versions are refused rather than misread
+const MinProtocolVersion = 1
+
+// Actions understood by the service
+const (
+	ActionProcessStart = "process_start" // A tracked process started
+	ActionProcessStop  = "process_stop"  // A tracked process stopped
+	ActionRefresh      = "refresh"       // Reload programs and config, restarting the monitor
+	ActionPause        = "pause"         // Stop monitoring and heartbeats without shutting down, with an optional Pause payload
+	ActionResume       = "resume"        // Resume monitoring after a pause
+	ActionQueryActive  = "query_active"  // Return in-memory session state
+	ActionReloadConfig = "reload_config" // Reload config file and reconfigure in place
+	ActionShutdown     = "shutdown"      // Stop the service
+	ActionHealth       = "health"        // Return service health details
+	ActionMetrics      = "metrics"       // Return internal service counters
+	ActionReconcile    = "reconcile"     // Align active_sessions rows with in-memory sessions
+	ActionIncognito    = "incognito"     // Turn incognito mode on or off, with an Incognito payload
+)
+
+// Error codes returned in responses
+const (
+	CodeBadRequest         = "bad_request"         // Request could not be decoded or was missing fields
+	CodeUnknownAction      = "unknown_action"      // Action is not recognised by the service
+	CodeUnsupportedVersion = "unsupported_version" // Request version is newer than the service understands
+	CodeInternal           = "internal"            // Service failed while handling the request
+	CodeUnauthorized       = "unauthorized"        // Connection did not present a valid service token
+	CodeForbidden          = "forbidden"           // Token's scope does not allow the action
+)
+
+// Message sent to the service, one JSON object per line
+type Request struct {
+	Version     int             `json:"version,omitempty"`
+	Action      string          `json:"action"`
+	ProcessName string          `json:"name,omitempty"`
+	ProcessID   int             `json:"pid,omitempty"`
+	Parent      string          `json:"parent,omitempty"` // Name of the parent process, sent by the Windows monitor with process_start
+	Token       string          `json:"token,omitempty"`  // Service token, required on the first request of a connection
+	Payload     json.RawMessage `json:"payload,omitempty"`
+}
+
+// Reply written by the service for versioned requests, one JSON object per line
+type Response struct {
+	Version    int             `json:"version"`
+	MinVersion int             `json:"min_version,omitempty"` // Oldest protocol version the service understands
+	OK         bool            `json:"ok"`
+	Code       string          `json:"code,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// In-memory state of a program session, returned by query_active
+type ActiveSession struct {
+	Name     string    `json:"name"`
+	Category string    `json:"category,omitempty"`
+	Project  string    `json:"project,omitempty"`
+	PIDs     []int     `json:"pids"`
+	StartAt  time.Time `json:"start_at"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Changes made to active_sessions rows to match the service's in-memory sessions, returned by reconcile
+type Reconciliation struct {
+	Removed   []string `json:"removed"`   // Programs with a row but no running session
+	Recreated []string `json:"recreated"` // Running programs whose row was missing or had a different start
+}
+
+// Pause requested by the pause action. Without a payload, or with Until zero, monitoring stays paused until resumed
+type Pause struct {
+	Until time.Time `json:"until,omitzero"` // When monitoring resumes by itself, surviving service restarts
+}
+
+// Incognito mode requested by the incognito action. While on, the service observes and records nothing
+type Incognito struct {
+	Enabled bool      `json:"enabled"`
+	Until   time.Time `json:"until,omitzero"` // When incognito turns itself off, never if zero
+}
+
+// Service health details, returned by health
+type Health struct {
+	Version         string    `json:"version"`
+	StartedAt       time.Time `json:"started_at"`
+	Database        string    `json:"database"` // "ok", or the error hit when querying it
+	Monitor         string    `json:"monitor"`  // "running", "paused", "standby", "incognito" or "stopped"
+	TrackedPrograms int       `json:"tracked_programs"`
+	ActiveSessions  int       `json:"active_sessions"`
+	PausedUntil     time.Time `json:"paused_until,omitzero"`    // When a snooze ends, set only while snoozed
+	IncognitoUntil  time.Time `json:"incognito_until,omitzero"` // When incognito ends, set only while it's on with a time limit
+	Desktop         string    `json:"desktop,omitempty"`        // Backend reading the desktop session, such as "x11" or "sway", "none" outside one
+	Profile         string    `json:"profile,omitempty"`        // Profile the service records for, empty for the default profile
+	ActiveProfile   string    `json:"active_profile,omitempty"` // Profile switched to with 'timekeep profile use', set only while the service stands by for it
+}
+
+// Internal service counters since start, returned by metrics
+type Metrics struct {
+	EventsProcessed   int64 `json:"events_processed"`
+	SessionsCreated   int64 `json:"sessions_created"`
+	SessionsClosed    int64 `json:"sessions_closed"`
+	HeartbeatsSent    int64 `json:"heartbeats_sent"`
+	HeartbeatsFailed  int64 `json:"heartbeats_failed"`
+	HeartbeatsDropped int64 `json:"heartbeats_dropped"`
+	HeartbeatQueue    int64 `json:"heartbeat_queue"`
+	ValidatorCleanups int64 `json:"validator_cleanups"`
+	DBErrors          int64 `json:"db_errors"`
+	TaskRestarts      int64 `json:"task_restarts"`
+}
+
+// Error returned to callers when the service responds with a failure
+type ResponseError struct {
+	Code    string
+	Message string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("service error (%s): %s", e.Code, e.Message)
+}
+
+// Returned when the CLI and the service speak protocol versions the other doesn't understand, naming the side to
+// update
+type VersionError struct {
+	Client  int // Protocol version of this binary
+	Service int // Protocol version of the service
+}
+
+func (e *VersionError) Error() string {
+	if e.Client > e.Service {
+		return fmt.Sprintf("the service speaks protocol version %d, older than this CLI's %d: please update the service and restart it", e.Service, e.Client)
+	}
+	return fmt.Sprintf("the service speaks protocol version %d and no longer understands this CLI's %d: please update the CLI", e.Service, e.Client)
+}
+
+// Create a versioned request for the given action
+func NewRequest(action string) Request {
+	return Request{Version: ProtocolVersion, Action: action}
+}
+
+// Build a successful response, marshalling data if given
+func OKResponse(data any) Response {
+	resp := Response{Version: ProtocolVersion, MinVersion: MinProtocolVersion, OK: true}
+	if data == nil {
+		return resp
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return ErrorResponse(CodeInternal, fmt.Sprintf("failed to marshal response: %s", err))
+	}
+	resp.Data = b
+
+	return resp
+}
+
+// Build a failed response with the given code
+func ErrorResponse(code, message string) Response {
+	return Response{Version: ProtocolVersion, MinVersion: MinProtocolVersion, OK: false, Code: code, Error: message}
+}
+
+// Checks that the response comes from a service speaking a protocol version this binary understands, and that
+// understood the request. Services from before negotiation send no minimum version and refuse newer requests with
+// CodeUnsupportedVersion
+func (r Response) Negotiate() error {
+	if r.Code == CodeUnsupportedVersion || (r.Version > 0 && r.Version < MinProtocolVersion) || r.MinVersion > ProtocolVersion {
+		return &VersionError{Client: ProtocolVersion, Service: r.Version}
+	}
+	return nil
+}
+
+// Convert a failed response into an error, nil if the response succeeded
+func (r Response) Err() error {
+	if r.OK {
+		return nil
+	}
+	return &ResponseError{Code: r.Code, Message: r.Error}
+}
+
+// Unmarshal response data into v
+func (r Response) Decode(v any) error {
+	if len(r.Data) == 0 {
+		return fmt.Errorf("response contained no data")
+	}
+	return json.Unmarshal(r.Data, v)
+}
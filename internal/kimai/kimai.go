@@ -0,0 +1,209 @@
+// Package kimai pushes completed sessions to a Kimai server as timesheets, with the Kimai project and activity of
+// each picked by the mappings in config, for those who invoice from Kimai
+package kimai
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/jms-guy/timekeep/internal/rounding"
+)
+
+// Key of the export state recording the last session pushed
+const Target = "kimai"
+
+// Path of Kimai's timesheet API, under the server address
+const TimesheetsPath = "/api/timesheets"
+
+// Sessions read from the database at once
+const pageSize = 500
+
+// Layout of timesheet times, which Kimai reads in the timezone of its user
+const timeLayout = "2006-01-02T15:04:05"
+
+// Body posted to Kimai for a session
+type Timesheet struct {
+	Begin       string `json:"begin"`
+	End         string `json:"end"`
+	Project     int64  `json:"project"`
+	Activity    int64  `json:"activity"`
+	Description string `json:"description,omitempty"`
+}
+
+// Timesheet of a session not yet pushed
+type Entry struct {
+	SessionID int64
+	Timesheet Timesheet
+}
+
+// Returns the Kimai project and activity of a session of program, 0 for either when no mapping or default gives one.
+// The first mapping whose filters all match applies, its unset IDs falling back to kimai.project and kimai.activity
+func Assign(cfg config.KimaiConfig, program, category, project string) (int64, int64) {
+	for _, mapping := range cfg.Mappings {
+		if !mapping.Matches(program, category, project) {
+			continue
+		}
+		projectID, activityID := mapping.Project, mapping.Activity
+		if projectID == 0 {
+			projectID = cfg.Project
+		}
+		if activityID == 0 {
+			activityID = cfg.Activity
+		}
+		return projectID, activityID
+	}
+	return cfg.Project, cfg.Activity
+}
+
+// Returns the timesheets of this machine's sessions recorded after the last one pushed, oldest first, and the ID of
+// the last session they cover. Sessions starting before since, shorter than kimai.min_duration or given no project
+// and activity are covered but left out. Before anything is pushed, a zero since stands for the start of today, so
+// enabling Kimai never floods it with earlier history. With rounding set, each timesheet ends where its session's
+// rounded time does
+func Pending(ctx context.Context, tx repository.TxRepository, cfg *config.Config, now, since time.Time) ([]Entry, int64, error) {
+	loc, err := cfg.Location()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rule := rounding.Of(cfg)
+	var entries []Entry
+	var through int64
+	err = tx.WithTx(ctx, func(store repository.Store) error {
+		state, err := store.GetExportState(ctx, Target)
+		switch {
+		case err == nil:
+			through = state.PushedThrough
+		case !errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("error getting Kimai push state: %w", err)
+		case since.IsZero():
+			local := now.In(loc)
+			since = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		}
+
+		for {
+			rows, err := store.GetSessionsToExport(ctx, database.GetSessionsToExportParams{
+				AfterID: through,
+				Since:   since.UTC(),
+				Device:  cfg.DeviceName(),
+				Max:     pageSize,
+			})
+			if err != nil {
+				return fmt.Errorf("error getting sessions to push: %w", err)
+			}
+
+			for _, row := range rows {
+				through = row.ID
+				if time.Duration(row.DurationSeconds)*time.Second < cfg.Kimai.MinSession() {
+					continue
+				}
+				project, activity := Assign(cfg.Kimai, row.ProgramName, row.Category.String, row.Project.String)
+				if project == 0 || activity == 0 {
+					continue
+				}
+				end := row.EndTime
+				if !rule.Exact() {
+					end = row.StartTime.Add(rule.Round(time.Duration(row.DurationSeconds) * time.Second))
+				}
+				entries = append(entries, Entry{SessionID: row.ID, Timesheet: Timesheet{
+					Begin:       row.StartTime.In(loc).Format(timeLayout),
+					End:         end.In(loc).Format(timeLayout),
+					Project:     project,
+					Activity:    activity,
+					Description: row.ProgramName,
+				}})
+			}
+			if len(rows) < pageSize {
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, through, nil
+}
+
+// Posts entries to Kimai in order, recording each session as pushed once Kimai accepts it, then records through,
+// so sessions are never pushed twice. Returns how many were pushed
+func Send(ctx context.Context, tx repository.TxRepository, cfg config.KimaiConfig, entries []Entry, through int64) (int, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	for i, entry := range entries {
+		if err := post(ctx, client, cfg, entry.Timesheet); err != nil {
+			return i, fmt.Errorf("error pushing session of %s at %s: %w", entry.Timesheet.Description, entry.Timesheet.Begin, err)
+		}
+		if err := saveState(ctx, tx, entry.SessionID); err != nil {
+			return i + 1, err
+		}
+	}
+
+	if through == 0 {
+		return len(entries), nil
+	}
+	return len(entries), saveState(ctx, tx, through)
+}
+
+// Pushes the pending sessions when Kimai is enabled, returning how many were pushed
+func Run(ctx context.Context, tx repository.TxRepository, cfg *config.Config, now, since time.Time) (int, error) {
+	if cfg == nil || !cfg.Kimai.Enabled {
+		return 0, errors.New("kimai is disabled, set kimai.enabled, kimai.server and kimai.token")
+	}
+	if cfg.Kimai.Server == "" || cfg.Kimai.Token == "" {
+		return 0, errors.New("no Kimai server configured, set kimai.server and kimai.token")
+	}
+
+	entries, through, err := Pending(ctx, tx, cfg, now, since)
+	if err != nil {
+		return 0, err
+	}
+	return Send(ctx, tx, cfg.Kimai, entries, through)
+}
+
+func saveState(ctx context.Context, tx repository.TxRepository, through int64) error {
+	err := tx.WithTx(ctx, func(store repository.Store) error {
+		return store.SaveExportState(ctx, database.SaveExportStateParams{Target: Target, PushedThrough: through, PushedAt: time.Now().UTC()})
+	})
+	if err != nil {
+		return fmt.Errorf("error saving Kimai push state: %w", err)
+	}
+	return nil
+}
+
+func post(ctx context.Context, client *http.Client, cfg config.KimaiConfig, timesheet Timesheet) error {
+	body, err := json.Marshal(timesheet)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(cfg.Server, "/")+TimesheetsPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("kimai returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// Package policy handles the tracking policy an administrator manages centrally for machines running Timekeep: programs
+// that must never be tracked, and the category programs are filed under. The service fetches the policy file from
+// policy.source and keeps a copy next to the config, which the CLI reads
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/progname"
+)
+
+// Name of the cached policy file, kept in the config directory
+const cacheFile = "policy.json"
+
+// Largest policy file accepted
+const maxPolicySize = 1 << 20
+
+// Tracking policy, as published by an administrator. Program names and patterns use the names shown by 'timekeep ls',
+// and patterns the syntax of path.Match, such as "web:*" or "steam*"
+type Policy struct {
+	Exclude    []string          `json:"exclude,omitempty"`    // Programs never tracked, by name or pattern
+	Categories map[string]string `json:"categories,omitempty"` // Category programs are filed under, by name or pattern
+
+	Source    string    `json:"source,omitempty"`    // Where the policy was fetched from, set on fetch
+	FetchedAt time.Time `json:"fetched_at,omitzero"` // When the policy was fetched, set on fetch
+}
+
+// Reports whether name must not be tracked. A nil policy excludes nothing
+func (p *Policy) Excluded(name string) bool {
+	if p == nil {
+		return false
+	}
+	name = progname.Normalize(name)
+	for _, pattern := range p.Exclude {
+		if matches(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the category name must be filed under, if the policy sets one. A mapping of the exact name wins over
+// patterns, and of patterns the longest matching one, so specific entries override catch-alls
+func (p *Policy) Category(name string) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	name = progname.Normalize(name)
+	if category, ok := p.Categories[name]; ok {
+		return category, true
+	}
+
+	best := ""
+	for pattern := range p.Categories {
+		if matches(pattern, name) && (len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best)) {
+			best = pattern
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	return p.Categories[best], true
+}
+
+// Reports whether two policies hold the same rules
+func (p *Policy) Equal(other *Policy) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+	if !slices.Equal(p.Exclude, other.Exclude) || len(p.Categories) != len(other.Categories) {
+		return false
+	}
+	for pattern, category := range p.Categories {
+		if other.Categories[pattern] != category {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks the policy's patterns and categories, returning the first problem found
+func (p *Policy) Validate() error {
+	for _, pattern := range p.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil || strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("invalid exclude pattern %q", pattern)
+		}
+	}
+	for pattern, category := range p.Categories {
+		if _, err := path.Match(pattern, ""); err != nil || strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("invalid category pattern %q", pattern)
+		}
+		if strings.TrimSpace(category) == "" {
+			return fmt.Errorf("empty category for %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Reports whether a canonical program name matches pattern, which is case-insensitive
+func matches(pattern, name string) bool {
+	ok, _ := path.Match(strings.ToLower(strings.TrimSpace(pattern)), name)
+	return ok
+}
+
+// Fetches the policy from source: an http(s) URL, or the path of a file such as one deployed by group policy
+func Fetch(ctx context.Context, source string) (*Policy, error) {
+	var data []byte
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching policy: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("error fetching policy: server returned %s", resp.Status)
+		}
+		if data, err = io.ReadAll(io.LimitReader(resp.Body, maxPolicySize+1)); err != nil {
+			return nil, fmt.Errorf("error fetching policy: %w", err)
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(source); err != nil {
+			return nil, fmt.Errorf("error reading policy: %w", err)
+		}
+	}
+	if len(data) > maxPolicySize {
+		return nil, fmt.Errorf("policy larger than %d bytes", maxPolicySize)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	policy.Source = source
+	policy.FetchedAt = time.Now().UTC()
+
+	return &policy, nil
+}
+
+// Location of the cached policy
+func CachePath() (string, error) {
+	path, err := config.Path()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), cacheFile), nil
+}
+
+// Reads the cached policy, nil when none has been fetched
+func Load() (*Policy, error) {
+	path, err := CachePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading cached policy: %w", err)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid cached policy %s: %w", path, err)
+	}
+	return &policy, nil
+}
+
+// Writes policy to the cache, or removes the cache when policy is nil
+func Save(policy *Policy) error {
+	path, err := CachePath()
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error removing cached policy: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create policy cache directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error caching policy: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error caching policy: %w", err)
+	}
+	return nil
+}
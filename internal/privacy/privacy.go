@@ -0,0 +1,330 @@
+// Package privacy keeps program names out of the database when privacy.hash_names is set. Names are stored as salted
+// hashes, and the salt and the mapping back to names live in a local file next to the config, so database backups
+// and synced sessions don't reveal what software runs on the machine
+package privacy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Marks a stored name as a hash. Program names never start with it
+const HashPrefix = "#"
+
+// Hex digits of the HMAC kept in a stored name
+const hashLength = 24
+
+// Name of the mapping file, kept in the config directory
+const namesFile = "names.json"
+
+// Waiting on the lock file serializing writes of the mapping file between processes
+const (
+	lockWait  = 5 * time.Second       // Longest wait for another process to release it
+	lockStale = 30 * time.Second      // Age past which it was left behind, no write taking this long
+	lockPoll  = 10 * time.Millisecond // Interval between attempts to take it
+)
+
+// Contents of the mapping file
+type namesData struct {
+	Salt  string            `json:"salt"`  // Hex-encoded key of the HMAC names are hashed with
+	Names map[string]string `json:"names"` // Program names by stored name
+}
+
+// Salt and mapping of hashed program names, shared through a file by the CLI and the service. Safe for concurrent use
+type Names struct {
+	path string
+
+	mu      sync.Mutex
+	salt    []byte
+	names   map[string]string
+	modTime time.Time // Modification time of the file when last read
+}
+
+// Location of the mapping file
+func NamesPath() (string, error) {
+	path, err := config.Path()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), namesFile), nil
+}
+
+// Reads the mapping file at path, creating it with a new random salt if it doesn't exist
+func Load(path string) (*Names, error) {
+	n := &Names{path: path}
+	err := n.read()
+	if errors.Is(err, fs.ErrNotExist) {
+		err = n.create()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Returns the stored name for a program name
+func (n *Names) Hash(name string) string {
+	mac := hmac.New(sha256.New, n.salt)
+	mac.Write([]byte(name))
+	return HashPrefix + hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
+
+// Returns the stored name for a program name, adding it to the mapping file if it's new
+func (n *Names) Record(name string) (string, error) {
+	stored := n.Hash(name)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.names[stored]; ok {
+		return stored, nil
+	}
+	if err := n.save(map[string]string{stored: name}); err != nil {
+		return "", err
+	}
+	return stored, nil
+}
+
+// Returns the program name for a stored name. Stored names that aren't hashes are returned as they are, and so are
+// hashes missing from the mapping, such as those of sessions synced from machines with another salt
+func (n *Names) Name(stored string) string {
+	if !IsHash(stored) {
+		return stored
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if name, ok := n.names[stored]; ok {
+		return name
+	}
+
+	// Another process may have recorded it since the file was read
+	if info, err := os.Stat(n.path); err == nil && !info.ModTime().Equal(n.modTime) {
+		if err := n.read(); err == nil {
+			if name, ok := n.names[stored]; ok {
+				return name
+			}
+		}
+	}
+	return stored
+}
+
+// Reports whether a stored name is a hash
+func IsHash(stored string) bool {
+	return strings.HasPrefix(stored, HashPrefix) && len(stored) == len(HashPrefix)+hashLength
+}
+
+func (n *Names) read() error {
+	info, err := os.Stat(n.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(n.path)
+	if err != nil {
+		return err
+	}
+
+	var file namesData
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid names file %s: %w", n.path, err)
+	}
+	salt, err := hex.DecodeString(file.Salt)
+	if err != nil || len(salt) == 0 {
+		return fmt.Errorf("invalid salt in names file %s", n.path)
+	}
+
+	n.salt = salt
+	n.names = file.Names
+	if n.names == nil {
+		n.names = make(map[string]string)
+	}
+	n.modTime = info.ModTime()
+	return nil
+}
+
+// Creates the mapping file with a new salt. If another process created it first, its salt is used instead
+func (n *Names) create() error {
+	if err := os.MkdirAll(filepath.Dir(n.path), 0o750); err != nil {
+		return fmt.Errorf("failed to create names file directory: %w", err)
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(namesData{Salt: hex.EncodeToString(salt), Names: map[string]string{}}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(n.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return n.read()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create names file: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write names file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write names file: %w", err)
+	}
+	return n.read()
+}
+
+// Adds names to the mapping file, merged with what other processes recorded since it was read. The CLI and the
+// service both write it, so the read, merge and write happen under the lock file, or a mapping could be lost for good.
+// Called with mu held
+func (n *Names) save(add map[string]string) error {
+	unlock, err := n.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := n.read(); err != nil {
+		return err
+	}
+	for stored, name := range add {
+		n.names[stored] = name
+	}
+
+	data, err := json.MarshalIndent(namesData{Salt: hex.EncodeToString(n.salt), Names: n.names}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.%d.tmp", n.path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write names file: %w", err)
+	}
+	if err := os.Rename(tmp, n.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write names file: %w", err)
+	}
+
+	if info, err := os.Stat(n.path); err == nil {
+		n.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Takes the lock file beside the mapping file, returning the function releasing it. A lock older than lockStale is
+// taken to be left by a process that died holding it, and is broken
+func (n *Names) lock() (func(), error) {
+	path := n.path + ".lock"
+	deadline := time.Now().Add(lockWait)
+	for {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("failed to lock names file: %w", err)
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("names file is locked by another process, remove %s if none is running", path)
+		}
+		time.Sleep(lockPoll)
+	}
+}
+
+// Returns the store the CLI and service work through. With privacy.hash_names set, it's a view of store keeping
+// program names hashed, and names still stored in the clear are hashed first. Once unset, names hashed before are
+// restored from the mapping file
+func Open(ctx context.Context, store repository.TxStore, cfg *config.Config) (repository.TxStore, error) {
+	path, err := NamesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg == nil || !cfg.Privacy.HashNames {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return store, nil
+		}
+		names, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := Apply(ctx, store, names, false); err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+
+	names, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := Apply(ctx, store, names, true); err != nil {
+		return nil, err
+	}
+	return repository.NewHashedStore(store, names), nil
+}
+
+// Rewrites the tracked programs, and every session referring to them, to hashed names, or back to program names when
+// hash is false, returning how many programs were renamed. Hashes missing from the mapping are left as they are. The
+// undo buffer is cleared, as it holds rows under the old names
+func Apply(ctx context.Context, tx repository.TxRepository, names *Names, hash bool) (int, error) {
+	renamed := 0
+	err := tx.WithTx(ctx, func(store repository.Store) error {
+		stored, err := store.GetAllProgramNames(ctx)
+		if err != nil {
+			return fmt.Errorf("error getting programs: %w", err)
+		}
+
+		for _, name := range stored {
+			var to string
+			switch {
+			case hash && !IsHash(name):
+				if to, err = names.Record(name); err != nil {
+					return err
+				}
+			case !hash && IsHash(name):
+				if to = names.Name(name); to == name {
+					continue
+				}
+			default:
+				continue
+			}
+
+			if err := store.RenameProgram(ctx, name, to); err != nil {
+				return fmt.Errorf("error renaming program: %w", err)
+			}
+			renamed++
+		}
+
+		if renamed == 0 {
+			return nil
+		}
+		if err := store.ClearUndoRows(ctx); err != nil {
+			return fmt.Errorf("error clearing undo buffer: %w", err)
+		}
+		return store.ClearUndoOperation(ctx)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error rewriting program names: %w", err)
+	}
+	return renamed, nil
+}
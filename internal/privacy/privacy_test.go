@@ -0,0 +1,62 @@
+package privacy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordFromSeveralProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), namesFile)
+	first, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to create names file: %v", err)
+	}
+
+	// Each Names stands for a process with the file open, such as the CLI and the service
+	var wg sync.WaitGroup
+	for p := range 4 {
+		names, err := Load(path)
+		assert.Nil(t, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				_, err := names.Record(fmt.Sprintf("program-%d-%d", p, i))
+				assert.Nil(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	loaded, err := Load(path)
+	assert.Nil(t, err)
+	assert.Len(t, loaded.names, 400, "No process should lose names recorded by another")
+	assert.Equal(t, "program-3-99", first.Name(first.Hash("program-3-99")))
+
+	_, err = os.Stat(path + ".lock")
+	assert.ErrorIs(t, err, os.ErrNotExist, "The lock should be released")
+	tmps, _ := filepath.Glob(path + "*.tmp")
+	assert.Empty(t, tmps)
+}
+
+func TestStaleLockBroken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), namesFile)
+	names, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to create names file: %v", err)
+	}
+
+	assert.Nil(t, os.WriteFile(path+".lock", nil, 0o600))
+	old := time.Now().Add(-2 * lockStale)
+	assert.Nil(t, os.Chtimes(path+".lock", old, old))
+
+	stored, err := names.Record("code")
+	assert.Nil(t, err, "A lock left by a dead process should be broken")
+	assert.Equal(t, "code", names.Name(stored))
+}
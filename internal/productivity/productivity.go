@@ -0,0 +1,97 @@
+// Package productivity rates recorded time by the productivity rating of its program or category, set in config, and
+// sums it up as the share of productive time and a productivity pulse: the average pulse score of the time's ratings,
+// from 0 to 100, as RescueTime's is. A score tuned by the user, from category weights and a formula, is computed
+// alongside
+package productivity
+
+import (
+	"math"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/formula"
+)
+
+// Pulse score of neutral time. Time rated above it counts as productive, below it as distracting, and time of
+// programs without a rating counts at it
+const Neutral = 50
+
+// Productivity of a stretch of time, such as a day
+type Summary struct {
+	Total       time.Duration
+	Productive  time.Duration // Time rated above Neutral
+	Distracting time.Duration // Time rated below Neutral
+	Unrated     time.Duration // Time of programs without a rating
+	weighted    float64       // Seconds of time times their pulse score
+	scored      float64       // Hours of time times the weight of their category
+}
+
+// Counts time spent in program, filed under category, at the pulse score of its rating and the weight of its category
+// under cfg
+func (s *Summary) Add(cfg *config.Config, program, category string, spent time.Duration) {
+	if spent <= 0 {
+		return
+	}
+	score := Neutral
+	if rating := cfg.ProductivityRating(program, category); rating != "" {
+		score = cfg.ProductivityScale()[rating]
+	} else {
+		s.Unrated += spent
+	}
+
+	s.Total += spent
+	s.weighted += spent.Seconds() * float64(score)
+	s.scored += spent.Hours() * cfg.ProductivityWeight(category)
+	switch {
+	case score > Neutral:
+		s.Productive += spent
+	case score < Neutral:
+		s.Distracting += spent
+	}
+}
+
+// Adds up two summaries, such as the days of a week
+func (s Summary) Plus(o Summary) Summary {
+	return Summary{
+		Total:       s.Total + o.Total,
+		Productive:  s.Productive + o.Productive,
+		Distracting: s.Distracting + o.Distracting,
+		Unrated:     s.Unrated + o.Unrated,
+		weighted:    s.weighted + o.weighted,
+		scored:      s.scored + o.scored,
+	}
+}
+
+// Share of the time that was productive, from 0 to 100
+func (s Summary) ProductivePercent() int {
+	if s.Total <= 0 {
+		return 0
+	}
+	return int(math.Round(float64(s.Productive) / float64(s.Total) * 100))
+}
+
+// Productivity pulse: the average pulse score of the time, weighted by its length. Neutral when nothing was recorded
+func (s Summary) Pulse() int {
+	if s.Total <= 0 {
+		return Neutral
+	}
+	return int(math.Round(s.weighted / s.Total.Seconds()))
+}
+
+// Parses the daily score formula set in cfg
+func Formula(cfg *config.Config) (*formula.Formula, error) {
+	return formula.Parse(cfg.ProductivityFormula(), config.ProductivityVariables)
+}
+
+// Score of the time by f, given its hours, weighted hours and pulse as the variables of config.ProductivityVariables
+func (s Summary) Score(f *formula.Formula) float64 {
+	return f.Eval(map[string]float64{
+		"weighted":    s.scored,
+		"total":       s.Total.Hours(),
+		"productive":  s.Productive.Hours(),
+		"neutral":     (s.Total - s.Productive - s.Distracting).Hours(),
+		"distracting": s.Distracting.Hours(),
+		"unrated":     s.Unrated.Hours(),
+		"pulse":       float64(s.Pulse()),
+	})
+}
@@ -0,0 +1,143 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Environment variable selecting the profile when --profile isn't given
+const Env = "TIMEKEEP_PROFILE"
+
+// Name of the profile used when none is selected, keeping the original paths and service names
+const Default = "default"
+
+var (
+	validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+	current   string
+	userScope bool
+	owner     string
+)
+
+// Selects the profile this process works with. Each profile has its own database, config, IPC endpoint and
+// service unit. Must be called before any of those are opened
+func Set(name string) error {
+	if name == "" || name == Default {
+		current = ""
+		return nil
+	}
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use up to 32 lowercase letters, digits, '-' or '_'", name)
+	}
+	current = name
+	return nil
+}
+
+// Returns the selected profile, empty for the default profile
+func Name() string {
+	return current
+}
+
+// Returns base suffixed with "-<profile>" for named profiles, or base unchanged for the default profile
+func Suffixed(base string) string {
+	if current == "" {
+		return base
+	}
+	return base + "-" + current
+}
+
+// Selects the per-user scope on Windows: an agent running in the login session, with its own data directory and
+// IPC endpoint, instead of the machine-wide service
+func SetUserScope(user bool) {
+	userScope = user
+}
+
+// Reports whether the per-user scope is selected
+func UserScope() bool {
+	return userScope
+}
+
+// Sets the SID of the user the machine-wide Windows service was installed for, the only user besides SYSTEM and
+// Administrators allowed on its IPC endpoint
+func SetOwner(sid string) {
+	owner = sid
+}
+
+// Returns the SID set with SetOwner, empty when the service was installed without one
+func Owner() string {
+	return owner
+}
+
+// Returns the profile switched to with Use, empty when none was
+func Active() (string, error) {
+	path, err := activeLocation()
+	if err != nil || path == "" {
+		return "", err
+	}
+	// #nosec G304
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading active profile: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Switches to the profile name, used by commands run without --profile or TIMEKEEP_PROFILE. Once a profile is
+// switched to, the services of the others stand by, so only one profile records at a time
+func Use(name string) error {
+	if name != Default && !validName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use up to 32 lowercase letters, digits, '-' or '_'", name)
+	}
+	path, err := activeLocation()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return errors.New("profiles can't be switched on this platform")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("error creating profile directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(name+"\n"), 0o600); err != nil {
+		return fmt.Errorf("error saving active profile: %w", err)
+	}
+	return nil
+}
+
+// Reports whether the selected profile is in use: no profile was switched to, or the selected one was. Also returns
+// the profile in use
+func InUse() (bool, string) {
+	active, err := Active()
+	if err != nil || active == "" {
+		return true, active
+	}
+	if active == Default {
+		return current == "", active
+	}
+	return active == current, active
+}
+
+// Returns the profiles that have a config or data directory, the default profile first
+func List() ([]string, error) {
+	dirs, err := profileDirs()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{Default}
+	for _, dir := range dirs {
+		_, name, ok := strings.Cut(filepath.Base(dir), "-")
+		if ok && validName.MatchString(name) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names[1:])
+	return names, nil
+}
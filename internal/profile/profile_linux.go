@@ -0,0 +1,34 @@
+//go:build linux
+
+package profile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// File recording the profile switched to, kept in the default profile's config directory
+func activeLocation() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "timekeep", "profile"), nil
+}
+
+// Config and data directories of named profiles
+func profileDirs() ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	config, err := filepath.Glob(filepath.Join(home, ".config", "timekeep-*"))
+	if err != nil {
+		return nil, err
+	}
+	data, err := filepath.Glob(filepath.Join(home, ".local", "share", "timekeep-*"))
+	if err != nil {
+		return nil, err
+	}
+	return append(config, data...), nil
+}
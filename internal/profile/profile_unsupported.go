@@ -0,0 +1,11 @@
+//go:build !windows && !linux
+
+package profile
+
+func activeLocation() (string, error) {
+	return "", nil
+}
+
+func profileDirs() ([]string, error) {
+	return nil, nil
+}
@@ -0,0 +1,39 @@
+//go:build windows
+
+package profile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Root data directory for the selected profile and scope: ProgramData for the system service, LocalAppData for
+// a per-user agent
+func DataDir() string {
+	if userScope {
+		if local := os.Getenv("LOCALAPPDATA"); local != "" {
+			return filepath.Join(local, Suffixed("Timekeep"))
+		}
+	}
+	return filepath.Join(`C:\ProgramData`, Suffixed("TimeKeep"))
+}
+
+// File recording the profile switched to, kept in the default profile's data directory
+func activeLocation() (string, error) {
+	if userScope {
+		if local := os.Getenv("LOCALAPPDATA"); local != "" {
+			return filepath.Join(local, "Timekeep", "profile"), nil
+		}
+	}
+	return filepath.Join(`C:\ProgramData`, "TimeKeep", "profile"), nil
+}
+
+// Data directories of named profiles
+func profileDirs() ([]string, error) {
+	if userScope {
+		if local := os.Getenv("LOCALAPPDATA"); local != "" {
+			return filepath.Glob(filepath.Join(local, "Timekeep-*"))
+		}
+	}
+	return filepath.Glob(filepath.Join(`C:\ProgramData`, "TimeKeep-*"))
+}
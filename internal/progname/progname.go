@@ -0,0 +1,105 @@
+// Package progname turns process and program names into the canonical form stored in the database, shared by the
+// CLI and the monitors so a program is named the same on every platform
+package progname
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Prefix of pseudo-programs recording time per website, such as "web:github.com", fed by the browser extension
+const WebPrefix = "web:"
+
+// Prefix of pseudo-programs recording time per editor project, such as "code:timekeep", fed by WakaTime plugins
+const CodePrefix = "code:"
+
+// Windows executable extension, stripped so "Chrome.EXE" and "chrome" are the same program
+const windowsExt = ".exe"
+
+// Returns the canonical name for a program: the base name when given a path, case-folded, without a .exe extension
+func Normalize(name string) string {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.ToLower(name)
+	return strings.TrimSuffix(name, windowsExt)
+}
+
+// Returns the Windows image name for a canonical program name, as reported by Win32_Process
+func ImageName(name string) string {
+	return name + windowsExt
+}
+
+// Reports whether name is a pseudo-program fed by activity reports, which has no process to monitor
+func IsPseudo(name string) bool {
+	return strings.HasPrefix(name, WebPrefix) || strings.HasPrefix(name, CodePrefix)
+}
+
+// Returns the names query likely means, best matches first: names starting with query, or else containing it, or else
+// containing its letters in order ("chr" for "chrome"), or else a close misspelling. Query is taken in canonical form
+func Match(query string, names []string) []string {
+	query = Normalize(query)
+	if query == "" {
+		return nil
+	}
+
+	tiers := []func(name string) bool{
+		func(name string) bool { return strings.HasPrefix(name, query) },
+		func(name string) bool { return strings.Contains(name, query) },
+		func(name string) bool { return isSubsequence(query, name) },
+		func(name string) bool { return EditDistance(query, name) <= max(1, min(2, len([]rune(query))/3)) },
+	}
+	for _, matches := range tiers {
+		var found []string
+		for _, name := range names {
+			if matches(name) {
+				found = append(found, name)
+			}
+		}
+		if len(found) > 0 {
+			slices.SortFunc(found, func(a, b string) int {
+				return cmp.Or(cmp.Compare(len(a), len(b)), cmp.Compare(a, b))
+			})
+			return found
+		}
+	}
+	return nil
+}
+
+// Reports whether the letters of sub appear in s in order
+func isSubsequence(sub, s string) bool {
+	for _, r := range s {
+		if sub == "" {
+			break
+		}
+		if first, size := utf8.DecodeRuneInString(sub); r == first {
+			sub = sub[size:]
+		}
+	}
+	return sub == ""
+}
+
+// Returns the number of single letter edits turning a into b
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/database"
+)
+
+// Maps program names to the names stored for them in the database, and back
+type NameHasher interface {
+	Hash(name string) string            // Stored name for a program name
+	Record(name string) (string, error) // Stored name for a program name, remembering the mapping for Name
+	Name(stored string) string          // Program name for a stored name, the stored name itself if it's unknown
+}
+
+// Store keeping program names hashed at rest: names are hashed on the way into the database and mapped back on the
+// way out, so callers see program names throughout. Sync, team and token queries pass through unchanged, so what
+// leaves the machine carries the names as stored
+type hashedStore struct {
+	Store
+	tx    TxRepository
+	names NameHasher
+}
+
+// Wraps store so the program names it stores are those given by names
+func NewHashedStore(store TxStore, names NameHasher) TxStore {
+	return &hashedStore{Store: store, tx: store, names: names}
+}
+
+func (h *hashedStore) WithTx(ctx context.Context, fn func(store Store) error) error {
+	return h.tx.WithTx(ctx, func(store Store) error {
+		return fn(&hashedStore{Store: store, names: h.names})
+	})
+}
+
+func (h *hashedStore) hashNull(name sql.NullString) sql.NullString {
+	if name.Valid {
+		name.String = h.names.Hash(name.String)
+	}
+	return name
+}
+
+func (h *hashedStore) sessions(sessions []database.SessionHistory, err error) ([]database.SessionHistory, error) {
+	for i := range sessions {
+		sessions[i].ProgramName = h.names.Name(sessions[i].ProgramName)
+	}
+	return sessions, err
+}
+
+// //////////////// Program Repository //////////////////
+func (h *hashedStore) AddProgram(ctx context.Context, arg database.AddProgramParams) error {
+	name, err := h.names.Record(arg.Name)
+	if err != nil {
+		return err
+	}
+	arg.Name = name
+	return h.Store.AddProgram(ctx, arg)
+}
+
+func (h *hashedStore) AddRemoteProgram(ctx context.Context, arg database.AddRemoteProgramParams) error {
+	name, err := h.names.Record(arg.Name)
+	if err != nil {
+		return err
+	}
+	arg.Name = name
+	return h.Store.AddRemoteProgram(ctx, arg)
+}
+
+func (h *hashedStore) GetAllProgramNames(ctx context.Context) ([]string, error) {
+	names, err := h.Store.GetAllProgramNames(ctx)
+	for i := range names {
+		names[i] = h.names.Name(names[i])
+	}
+	return names, err
+}
+
+func (h *hashedStore) GetAllPrograms(ctx context.Context) ([]database.TrackedProgram, error) {
+	programs, err := h.Store.GetAllPrograms(ctx)
+	for i := range programs {
+		programs[i].Name = h.names.Name(programs[i].Name)
+	}
+	return programs, err
+}
+
+func (h *hashedStore) GetProgramByName(ctx context.Context, name string) (database.TrackedProgram, error) {
+	program, err := h.Store.GetProgramByName(ctx, h.names.Hash(name))
+	if err == nil {
+		program.Name = name
+	}
+	return program, err
+}
+
+func (h *hashedStore) RemoveProgram(ctx context.Context, name string) error {
+	return h.Store.RemoveProgram(ctx, h.names.Hash(name))
+}
+
+func (h *hashedStore) ResetLifetimeForProgram(ctx context.Context, name string) error {
+	return h.Store.ResetLifetimeForProgram(ctx, h.names.Hash(name))
+}
+
+func (h *hashedStore) UpdateLifetime(ctx context.Context, arg database.UpdateLifetimeParams) error {
+	arg.Name = h.names.Hash(arg.Name)
+	return h.Store.UpdateLifetime(ctx, arg)
+}
+
+func (h *hashedStore) UpdateCategory(ctx context.Context, arg database.UpdateCategoryParams) error {
+	arg.Name = h.names.Hash(arg.Name)
+	return h.Store.UpdateCategory(ctx, arg)
+}
+
+func (h *hashedStore) UpdateProject(ctx context.Context, arg database.UpdateProjectParams) error {
+	arg.Name = h.names.Hash(arg.Name)
+	return h.Store.UpdateProject(ctx, arg)
+}
+
+func (h *hashedStore) RecalculateLifetimeForProgram(ctx context.Context, arg database.RecalculateLifetimeForProgramParams) error {
+	arg.Name = h.names.Hash(arg.Name)
+	return h.Store.RecalculateLifetimeForProgram(ctx, arg)
+}
+
+func (h *hashedStore) RenameProgram(ctx context.Context, oldName, newName string) error {
+	stored, err := h.names.Record(newName)
+	if err != nil {
+		return err
+	}
+	return h.Store.RenameProgram(ctx, h.names.Hash(oldName), stored)
+}
+
+// //////////////// Active Repository //////////////////
+func (h *hashedStore) CreateActiveSession(ctx context.Context, arg database.CreateActiveSessionParams) error {
+	name, err := h.names.Record(arg.ProgramName)
+	if err != nil {
+		return err
+	}
+	arg.ProgramName = name
+	return h.Store.CreateActiveSession(ctx, arg)
+}
+
+func (h *hashedStore) GetActiveSession(ctx context.Context, programName string) (time.Time, error) {
+	return h.Store.GetActiveSession(ctx, h.names.Hash(programName))
+}
+
+func (h *hashedStore) GetAllActiveSessions(ctx context.Context) ([]database.ActiveSession, error) {
+	sessions, err := h.Store.GetAllActiveSessions(ctx)
+	for i := range sessions {
+		sessions[i].ProgramName = h.names.Name(sessions[i].ProgramName)
+	}
+	return sessions, err
+}
+
+func (h *hashedStore) RemoveActiveSession(ctx context.Context, programName string) error {
+	return h.Store.RemoveActiveSession(ctx, h.names.Hash(programName))
+}
+
+// //////////////// History Repository //////////////////
+func (h *hashedStore) AddToSessionHistory(ctx context.Context, arg database.AddToSessionHistoryParams) error {
+	name, err := h.names.Record(arg.ProgramName)
+	if err != nil {
+		return err
+	}
+	arg.ProgramName = name
+	return h.Store.AddToSessionHistory(ctx, arg)
+}
+
+func (h *hashedStore) GetCountOfSessionsForProgram(ctx context.Context, programName string) (int64, error) {
+	return h.Store.GetCountOfSessionsForProgram(ctx, h.names.Hash(programName))
+}
+
+func (h *hashedStore) GetLastSessionForProgram(ctx context.Context, programName string) (database.SessionHistory, error) {
+	session, err := h.Store.GetLastSessionForProgram(ctx, h.names.Hash(programName))
+	if err == nil {
+		session.ProgramName = programName
+	}
+	return session, err
+}
+
+func (h *hashedStore) RemoveRecordsForProgram(ctx context.Context, programName string) error {
+	return h.Store.RemoveRecordsForProgram(ctx, h.names.Hash(programName))
+}
+
+func (h *hashedStore) GetSessionHistory(ctx context.Context, arg database.GetSessionHistoryParams) ([]database.SessionHistory, error) {
+	arg.ProgramName = h.names.Hash(arg.ProgramName)
+	return h.sessions(h.Store.GetSessionHistory(ctx, arg))
+}
+
+func (h *hashedStore) GetRecentSessionsPerProgram(ctx context.Context, perProgram int64) ([]database.SessionHistory, error) {
+	return h.sessions(h.Store.GetRecentSessionsPerProgram(ctx, perProgram))
+}
+
+func (h *hashedStore) GetAllSessionHistory(ctx context.Context, arg database.GetAllSessionHistoryParams) ([]database.SessionHistory, error) {
+	return h.sessions(h.Store.GetAllSessionHistory(ctx, arg))
+}
+
+func (h *hashedStore) GetSessionHistoryByDate(ctx context.Context, arg database.GetSessionHistoryByDateParams) ([]database.SessionHistory, error) {
+	arg.ProgramName = h.names.Hash(arg.ProgramName)
+	return h.sessions(h.Store.GetSessionHistoryByDate(ctx, arg))
+}
+
+func (h *hashedStore) GetAllSessionHistoryByDate(ctx context.Context, arg database.GetAllSessionHistoryByDateParams) ([]database.SessionHistory, error) {
+	return h.sessions(h.Store.GetAllSessionHistoryByDate(ctx, arg))
+}
+
+func (h *hashedStore) GetSessionHistoryByRange(ctx context.Context, arg database.GetSessionHistoryByRangeParams) ([]database.SessionHistory, error) {
+	arg.ProgramName = h.names.Hash(arg.ProgramName)
+	return h.sessions(h.Store.GetSessionHistoryByRange(ctx, arg))
+}
+
+func (h *hashedStore) GetAllSessionHistoryByRange(ctx context.Context, arg database.GetAllSessionHistoryByRangeParams) ([]database.SessionHistory, error) {
+	return h.sessions(h.Store.GetAllSessionHistoryByRange(ctx, arg))
+}
+
+func (h *hashedStore) StreamSessionHistory(ctx context.Context, arg database.StreamSessionHistoryParams, fn func(database.SessionHistory) error) error {
+	arg.ProgramName = h.hashNull(arg.ProgramName)
+	return h.Store.StreamSessionHistory(ctx, arg, func(session database.SessionHistory) error {
+		session.ProgramName = h.names.Name(session.ProgramName)
+		return fn(session)
+	})
+}
+
+// //////////////// Archive Repository //////////////////
+func (h *hashedStore) GetArchivedSessions(ctx context.Context, arg database.GetArchivedSessionsParams) ([]database.SessionArchive, error) {
+	arg.ProgramName = h.hashNull(arg.ProgramName)
+	sessions, err := h.Store.GetArchivedSessions(ctx, arg)
+	for i := range sessions {
+		sessions[i].ProgramName = h.names.Name(sessions[i].ProgramName)
+	}
+	return sessions, err
+}
+
+func (h *hashedStore) StreamArchivedSessions(ctx context.Context, arg database.StreamArchivedSessionsParams, fn func(database.SessionArchive) error) error {
+	arg.ProgramName = h.hashNull(arg.ProgramName)
+	return h.Store.StreamArchivedSessions(ctx, arg, func(session database.SessionArchive) error {
+		session.ProgramName = h.names.Name(session.ProgramName)
+		return fn(session)
+	})
+}
+
+func (h *hashedStore) RemoveArchivedRecordsForProgram(ctx context.Context, programName string) error {
+	return h.Store.RemoveArchivedRecordsForProgram(ctx, h.names.Hash(programName))
+}
+
+// //////////////// Undo Repository //////////////////
+func (h *hashedStore) SnapshotPrograms(ctx context.Context, name sql.NullString) error {
+	return h.Store.SnapshotPrograms(ctx, h.hashNull(name))
+}
+
+func (h *hashedStore) SnapshotSessionHistory(ctx context.Context, programName sql.NullString) error {
+	return h.Store.SnapshotSessionHistory(ctx, h.hashNull(programName))
+}
+
+func (h *hashedStore) SnapshotSessionArchive(ctx context.Context, programName sql.NullString) error {
+	return h.Store.SnapshotSessionArchive(ctx, h.hashNull(programName))
+}
+
+// Sessions are exported to the user's own time tracker, so with their program names
+func (h *hashedStore) GetSessionsToExport(ctx context.Context, arg database.GetSessionsToExportParams) ([]database.GetSessionsToExportRow, error) {
+	rows, err := h.Store.GetSessionsToExport(ctx, arg)
+	for i := range rows {
+		rows[i].ProgramName = h.names.Name(rows[i].ProgramName)
+	}
+	return rows, err
+}
+
+// //////////////// Group Repository //////////////////
+func (h *hashedStore) AddGroupMember(ctx context.Context, arg database.AddGroupMemberParams) error {
+	name, err := h.names.Record(arg.ProgramName)
+	if err != nil {
+		return err
+	}
+	arg.ProgramName = name
+	return h.Store.AddGroupMember(ctx, arg)
+}
+
+func (h *hashedStore) GetAllGroupMembers(ctx context.Context) ([]database.ProgramGroup, error) {
+	members, err := h.Store.GetAllGroupMembers(ctx)
+	for i := range members {
+		members[i].ProgramName = h.names.Name(members[i].ProgramName)
+	}
+	return members, err
+}
+
+func (h *hashedStore) GetGroupMembers(ctx context.Context, groupName string) ([]string, error) {
+	names, err := h.Store.GetGroupMembers(ctx, groupName)
+	for i := range names {
+		names[i] = h.names.Name(names[i])
+	}
+	return names, err
+}
+
+func (h *hashedStore) RemoveGroupMember(ctx context.Context, arg database.RemoveGroupMemberParams) (int64, error) {
+	arg.ProgramName = h.names.Hash(arg.ProgramName)
+	return h.Store.RemoveGroupMember(ctx, arg)
+}
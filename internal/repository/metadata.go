@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Session origins recorded in metadata
+const (
+	SourceAuto   = "auto"   // Recorded by the service's process monitor
+	SourceManual = "manual" // Entered by the user
+	SourceImport = "import" // Brought in from an external file or service
+)
+
+// Reasons a session ended, recorded in metadata
+const (
+	EndReasonExit     = "exit"     // Last process of the program exited
+	EndReasonStale    = "stale"    // Ended by the session validator after its processes disappeared unseen
+	EndReasonShutdown = "shutdown" // Flushed to history when the service stopped
+	EndReasonSleep    = "sleep"    // Closed when the system went to sleep
+	EndReasonSwitch   = "switch"   // Closed when another user took over the console
+	EndReasonSplit    = "split"    // Cut at the configured max session length, the program's next session carrying on from it
+	EndReasonPolicy   = "policy"   // Closed when the managed policy excluded the program
+	EndReasonProfile  = "profile"  // Closed when another profile was switched to
+)
+
+// Free-form data attached to a session history record, stored as JSON in the metadata column
+type SessionMetadata struct {
+	Source       string           `json:"source,omitempty"`        // Where the session came from, one of the Source* constants
+	Machine      string           `json:"machine,omitempty"`       // Hostname of the machine the session was recorded on
+	WindowTitles []string         `json:"window_titles,omitempty"` // Sampled window titles seen during the session
+	EndReason    string           `json:"end_reason,omitempty"`    // Why the session ended, one of the EndReason* constants
+	WallSeconds  int64            `json:"wall_seconds,omitempty"`  // Wall-clock length, set when the system clock changed during the session
+	Continuation string           `json:"continuation,omitempty"`  // Shared by the sessions of one program run split by system sleep
+	Workspaces   map[string]int64 `json:"workspaces,omitempty"`    // Seconds spent on each workspace or virtual desktop, when workspaces.track is set
+	Launch       *LaunchContext   `json:"launch,omitempty"`        // How the program's first process was started, for sessions of the process monitor
+	Extra        map[string]any   `json:"extra,omitempty"`         // Integration specific values
+}
+
+// How the first process of a session was started
+type LaunchContext struct {
+	Parent   string `json:"parent,omitempty"`   // Name of its parent process
+	Terminal string `json:"terminal,omitempty"` // Program hosting the terminal it was started in, such as a terminal emulator, tmux or sshd, empty outside a terminal
+	User     string `json:"user,omitempty"`     // Account it ran as
+}
+
+// Picks sessions by how they were started, parsed from a comma separated list of conditions by ParseLaunchFilter.
+// Sessions recorded without a launch context match none
+type LaunchFilter struct {
+	Terminal *bool  // Started in a terminal or not, unset for either
+	Host     string // Program hosting the terminal
+	Parent   string
+	User     string
+}
+
+// Parses a launch filter: "terminal" or "gui" for sessions started in a terminal or outside one, "terminal=<program>"
+// for those in a terminal hosted by program, "parent=<program>" and "user=<name>". Every condition listed must hold
+func ParseLaunchFilter(s string) (LaunchFilter, error) {
+	var f LaunchFilter
+	for _, cond := range strings.Split(s, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(cond), "=")
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "gui":
+			f.Terminal = new(bool)
+		case "terminal":
+			inTerminal := true
+			f.Terminal = &inTerminal
+			f.Host = value
+		case "parent":
+			f.Parent = value
+		case "user":
+			f.User = value
+		default:
+			return LaunchFilter{}, fmt.Errorf("invalid launch condition %q, expected terminal, gui, terminal=<program>, parent=<program> or user=<name>", cond)
+		}
+	}
+	return f, nil
+}
+
+// Reports whether a session launched as l matches the filter. Program names compare case-insensitively
+func (f LaunchFilter) Match(l *LaunchContext) bool {
+	if l == nil {
+		return false
+	}
+	if f.Terminal != nil && *f.Terminal != (l.Terminal != "") {
+		return false
+	}
+	return (f.Host == "" || strings.EqualFold(f.Host, l.Terminal)) &&
+		(f.Parent == "" || strings.EqualFold(f.Parent, l.Parent)) &&
+		(f.User == "" || f.User == l.User)
+}
+
+// Marshal metadata for storage, empty metadata is stored as NULL
+func (m SessionMetadata) Encode() (sql.NullString, error) {
+	if m.Source == "" && m.Machine == "" && len(m.WindowTitles) == 0 && m.EndReason == "" && m.WallSeconds == 0 && m.Continuation == "" && len(m.Workspaces) == 0 && m.Launch == nil && len(m.Extra) == 0 {
+		return sql.NullString{}, nil
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to marshal session metadata: %w", err)
+	}
+
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
+// Unmarshal a stored metadata column, NULL yields empty metadata
+func DecodeSessionMetadata(raw sql.NullString) (SessionMetadata, error) {
+	var m SessionMetadata
+	if !raw.Valid || raw.String == "" {
+		return m, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw.String), &m); err != nil {
+		return m, fmt.Errorf("failed to unmarshal session metadata: %w", err)
+	}
+
+	return m, nil
+}
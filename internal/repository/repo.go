@@ -2,6 +2,9 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/jms-guy/timekeep/internal/database"
@@ -11,6 +14,7 @@ import (
 
 type ProgramRepository interface {
 	AddProgram(ctx context.Context, arg database.AddProgramParams) error
+	AddRemoteProgram(ctx context.Context, arg database.AddRemoteProgramParams) error
 	GetAllProgramNames(ctx context.Context) ([]string, error)
 	GetAllPrograms(ctx context.Context) ([]database.TrackedProgram, error)
 	GetProgramByName(ctx context.Context, name string) (database.TrackedProgram, error)
@@ -21,6 +25,9 @@ type ProgramRepository interface {
 	UpdateLifetime(ctx context.Context, arg database.UpdateLifetimeParams) error
 	UpdateCategory(ctx context.Context, arg database.UpdateCategoryParams) error
 	UpdateProject(ctx context.Context, arg database.UpdateProjectParams) error
+	RecalculateAllLifetimes(ctx context.Context, device string) error
+	RecalculateLifetimeForProgram(ctx context.Context, arg database.RecalculateLifetimeForProgramParams) error
+	RenameProgram(ctx context.Context, oldName, newName string) error
 }
 
 type ActiveRepository interface {
@@ -38,143 +45,815 @@ type HistoryRepository interface {
 	RemoveAllRecords(ctx context.Context) error
 	RemoveRecordsForProgram(ctx context.Context, programName string) error
 	GetSessionHistory(ctx context.Context, arg database.GetSessionHistoryParams) ([]database.SessionHistory, error)
-	GetAllSessionHistory(ctx context.Context, limit int64) ([]database.SessionHistory, error)
+	GetRecentSessionsPerProgram(ctx context.Context, perProgram int64) ([]database.SessionHistory, error)
+	GetAllSessionHistory(ctx context.Context, arg database.GetAllSessionHistoryParams) ([]database.SessionHistory, error)
 	GetSessionHistoryByDate(ctx context.Context, arg database.GetSessionHistoryByDateParams) ([]database.SessionHistory, error)
 	GetAllSessionHistoryByDate(ctx context.Context, arg database.GetAllSessionHistoryByDateParams) ([]database.SessionHistory, error)
 	GetSessionHistoryByRange(ctx context.Context, arg database.GetSessionHistoryByRangeParams) ([]database.SessionHistory, error)
 	GetAllSessionHistoryByRange(ctx context.Context, arg database.GetAllSessionHistoryByRangeParams) ([]database.SessionHistory, error)
+	StreamSessionHistory(ctx context.Context, arg database.StreamSessionHistoryParams, fn func(database.SessionHistory) error) error
+	GetSessionMetadata(ctx context.Context, id int64) (SessionMetadata, error)
+	UpdateSessionMetadata(ctx context.Context, id int64, metadata SessionMetadata) error
 }
 
+type ArchiveRepository interface {
+	ArchiveSessionsBefore(ctx context.Context, arg database.ArchiveSessionsBeforeParams) (int64, error)
+	RemoveSessionsBefore(ctx context.Context, endTime time.Time) error
+	GetArchivedSessions(ctx context.Context, arg database.GetArchivedSessionsParams) ([]database.SessionArchive, error)
+	StreamArchivedSessions(ctx context.Context, arg database.StreamArchivedSessionsParams, fn func(database.SessionArchive) error) error
+	RemoveAllArchivedRecords(ctx context.Context) error
+	RemoveArchivedRecordsForProgram(ctx context.Context, programName string) error
+}
+
+type UndoRepository interface {
+	SaveUndoOperation(ctx context.Context, arg database.SaveUndoOperationParams) error
+	GetUndoOperation(ctx context.Context) (database.UndoOperation, error)
+	ClearUndoOperation(ctx context.Context) error
+	ClearUndoRows(ctx context.Context) error
+	SnapshotPrograms(ctx context.Context, name sql.NullString) error
+	SnapshotSessionHistory(ctx context.Context, programName sql.NullString) error
+	SnapshotSessionArchive(ctx context.Context, programName sql.NullString) error
+	RestorePrograms(ctx context.Context) (int64, error)
+	RestoreSessionHistory(ctx context.Context) (int64, error)
+	RestoreSessionArchive(ctx context.Context) (int64, error)
+}
+
+type TokenRepository interface {
+	CreateAPIToken(ctx context.Context, arg database.CreateAPITokenParams) error
+	GetAllAPITokens(ctx context.Context) ([]database.ApiToken, error)
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (database.ApiToken, error)
+	TouchAPIToken(ctx context.Context, arg database.TouchAPITokenParams) error
+	RemoveAPIToken(ctx context.Context, name string) (int64, error)
+}
+
+type SyncRepository interface {
+	AddSyncedSession(ctx context.Context, arg database.AddSyncedSessionParams) (int64, error)
+	GetSyncedSessionsAfter(ctx context.Context, arg database.GetSyncedSessionsAfterParams) ([]database.SyncLog, error)
+	GetSyncState(ctx context.Context) (database.SyncState, error)
+	SaveSyncState(ctx context.Context, arg database.SaveSyncStateParams) error
+	GetSessionsToPush(ctx context.Context, arg database.GetSessionsToPushParams) ([]database.GetSessionsToPushRow, error)
+}
+
+type TeamRepository interface {
+	GetTeamState(ctx context.Context) (database.TeamState, error)
+	SaveTeamState(ctx context.Context, arg database.SaveTeamStateParams) error
+}
+
+type DigestRepository interface {
+	GetDigestState(ctx context.Context) (database.DigestState, error)
+	SaveDigestState(ctx context.Context, arg database.SaveDigestStateParams) error
+}
+
+type StreakRepository interface {
+	GetStreaks(ctx context.Context) ([]database.Streak, error)
+	SaveStreak(ctx context.Context, arg database.SaveStreakParams) error
+}
+
+type ExportRepository interface {
+	GetExportState(ctx context.Context, target string) (database.ExportState, error)
+	SaveExportState(ctx context.Context, arg database.SaveExportStateParams) error
+	GetSessionsToExport(ctx context.Context, arg database.GetSessionsToExportParams) ([]database.GetSessionsToExportRow, error)
+}
+
+type HarvestRepository interface {
+	AddHarvestEntry(ctx context.Context, arg database.AddHarvestEntryParams) error
+	GetHarvestKeysSince(ctx context.Context, spentDate string) ([]string, error)
+	GetLastHarvestDay(ctx context.Context) (string, error)
+}
+
+type GroupRepository interface {
+	AddGroupMember(ctx context.Context, arg database.AddGroupMemberParams) error
+	GetAllGroupMembers(ctx context.Context) ([]database.ProgramGroup, error)
+	GetGroupMembers(ctx context.Context, groupName string) ([]string, error)
+	RemoveGroupMember(ctx context.Context, arg database.RemoveGroupMemberParams) (int64, error)
+	RemoveGroup(ctx context.Context, groupName string) (int64, error)
+}
+
+type EstimateRepository interface {
+	SetProjectEstimate(ctx context.Context, arg database.SetProjectEstimateParams) error
+	GetProjectEstimate(ctx context.Context, project string) (database.ProjectEstimate, error)
+	GetAllProjectEstimates(ctx context.Context) ([]database.ProjectEstimate, error)
+	RemoveProjectEstimate(ctx context.Context, project string) (int64, error)
+}
+
+type SnoozeRepository interface {
+	GetSnooze(ctx context.Context) (database.Snooze, error)
+	SaveSnooze(ctx context.Context, resumeAt time.Time) error
+	ClearSnooze(ctx context.Context) error
+}
+
+type IncognitoRepository interface {
+	GetIncognito(ctx context.Context) (database.Incognito, error)
+	SaveIncognito(ctx context.Context, until sql.NullTime) error
+	ClearIncognito(ctx context.Context) error
+}
+
+// Combined repository view, handed to transactional callbacks
+type Store interface {
+	ProgramRepository
+	ActiveRepository
+	HistoryRepository
+	ArchiveRepository
+	UndoRepository
+	TokenRepository
+	SyncRepository
+	TeamRepository
+	SnoozeRepository
+	IncognitoRepository
+	DigestRepository
+	StreakRepository
+	ExportRepository
+	HarvestRepository
+	GroupRepository
+	EstimateRepository
+}
+
+type TxRepository interface {
+	WithTx(ctx context.Context, fn func(store Store) error) error
+}
+
+// Store that also runs transactions, as the SQLite store does
+type TxStore interface {
+	Store
+	TxRepository
+}
+
+// Returned, wrapped, when a database operation runs past the store's timeout
+var ErrTimeout = errors.New("database operation timed out")
+
 type sqliteStore struct {
-	db *database.Queries
+	conn    *sql.DB
+	db      *database.Queries
+	timeout time.Duration // Deadline applied to each operation, none if zero
 }
 
-func NewSqliteStore(queries *database.Queries) *sqliteStore {
-	return &sqliteStore{db: queries}
+func NewSqliteStore(conn *sql.DB) *sqliteStore {
+	return &sqliteStore{conn: conn, db: database.New(conn)}
+}
+
+// Sets the deadline applied to each repository operation, so a locked database or slow disk fails the operation
+// instead of blocking the caller. Zero disables it
+func (s *sqliteStore) SetTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+// Returns ctx bounded by the store's per-operation timeout
+func (s *sqliteStore) opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+// Wraps err with ErrTimeout when the operation's deadline expired
+func (s *sqliteStore) timedOut(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %w", ErrTimeout, s.timeout, err)
+	}
+	return err
+}
+
+// Runs fn against a store bound to a single transaction, committing only if fn returns nil
+func (s *sqliteStore) WithTx(ctx context.Context, fn func(store Store) error) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(&sqliteStore{conn: s.conn, db: s.db.WithTx(tx), timeout: s.timeout}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
 }
 
 // //////////////// Program Repository //////////////////
 func (s *sqliteStore) AddProgram(ctx context.Context, arg database.AddProgramParams) error {
-	return s.db.AddProgram(ctx, arg)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.AddProgram(ctx, arg))
+}
+
+func (s *sqliteStore) AddRemoteProgram(ctx context.Context, arg database.AddRemoteProgramParams) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.AddRemoteProgram(ctx, arg))
 }
 
 func (s *sqliteStore) GetAllProgramNames(ctx context.Context) ([]string, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
 	results, err := s.db.GetAllProgramNames(ctx)
-	return results, err
+	return results, s.timedOut(ctx, err)
 }
 
 func (s *sqliteStore) GetAllPrograms(ctx context.Context) ([]database.TrackedProgram, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
 	results, err := s.db.GetAllPrograms(ctx)
-	return results, err
+	return results, s.timedOut(ctx, err)
 }
 
 func (s *sqliteStore) GetProgramByName(ctx context.Context, name string) (database.TrackedProgram, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
 	result, err := s.db.GetProgramByName(ctx, name)
-	return result, err
+	return result, s.timedOut(ctx, err)
 }
 
 func (s *sqliteStore) RemoveAllPrograms(ctx context.Context) error {
-	return s.db.RemoveAllPrograms(ctx)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.RemoveAllPrograms(ctx))
 }
 
 func (s *sqliteStore) RemoveProgram(ctx context.Context, name string) error {
-	return s.db.RemoveProgram(ctx, name)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.RemoveProgram(ctx, name))
 }
 
 func (s *sqliteStore) ResetAllLifetimes(ctx context.Context) error {
-	return s.db.ResetAllLifetimes(ctx)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.ResetAllLifetimes(ctx))
 }
 
 func (s *sqliteStore) ResetLifetimeForProgram(ctx context.Context, name string) error {
-	return s.db.ResetLifetimeForProgram(ctx, name)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.ResetLifetimeForProgram(ctx, name))
 }
 
 func (s *sqliteStore) UpdateLifetime(ctx context.Context, arg database.UpdateLifetimeParams) error {
-	return s.db.UpdateLifetime(ctx, arg)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.UpdateLifetime(ctx, arg))
 }
 
 func (s *sqliteStore) UpdateCategory(ctx context.Context, arg database.UpdateCategoryParams) error {
-	return s.db.UpdateCategory(ctx, arg)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.UpdateCategory(ctx, arg))
 }
 
 func (s *sqliteStore) UpdateProject(ctx context.Context, arg database.UpdateProjectParams) error {
-	return s.db.UpdateProject(ctx, arg)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.UpdateProject(ctx, arg))
+}
+
+func (s *sqliteStore) RecalculateAllLifetimes(ctx context.Context, device string) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.RecalculateAllLifetimes(ctx, device))
+}
+
+func (s *sqliteStore) RecalculateLifetimeForProgram(ctx context.Context, arg database.RecalculateLifetimeForProgramParams) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.RecalculateLifetimeForProgram(ctx, arg))
+}
+
+// Renames a program along with its active session, history, archived and synced sessions. Callers run it in a
+// transaction so no table is left referring to the old name
+func (s *sqliteStore) RenameProgram(ctx context.Context, oldName, newName string) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+
+	if err := s.db.RenameProgram(ctx, database.RenameProgramParams{NewName: newName, OldName: oldName}); err != nil {
+		return s.timedOut(ctx, err)
+	}
+	if err := s.db.RenameActiveSession(ctx, database.RenameActiveSessionParams{NewName: newName, OldName: oldName}); err != nil {
+		return s.timedOut(ctx, err)
+	}
+	if err := s.db.RenameSessionHistory(ctx, database.RenameSessionHistoryParams{NewName: newName, OldName: oldName}); err != nil {
+		return s.timedOut(ctx, err)
+	}
+	if err := s.db.RenameSessionArchive(ctx, database.RenameSessionArchiveParams{NewName: newName, OldName: oldName}); err != nil {
+		return s.timedOut(ctx, err)
+	}
+	if err := s.db.RenameGroupMember(ctx, database.RenameGroupMemberParams{NewName: newName, OldName: oldName}); err != nil {
+		return s.timedOut(ctx, err)
+	}
+	return s.timedOut(ctx, s.db.RenameSyncedSessions(ctx, database.RenameSyncedSessionsParams{NewName: newName, OldName: oldName}))
 }
 
 ////////////////// Active Repository //////////////////
 
 func (s *sqliteStore) CreateActiveSession(ctx context.Context, arg database.CreateActiveSessionParams) error {
-	return s.db.CreateActiveSession(ctx, arg)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.CreateActiveSession(ctx, arg))
 }
 
 func (s *sqliteStore) GetActiveSession(ctx context.Context, programName string) (time.Time, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
 	result, err := s.db.GetActiveSession(ctx, programName)
-	return result, err
+	return result, s.timedOut(ctx, err)
 }
 
 func (s *sqliteStore) GetAllActiveSessions(ctx context.Context) ([]database.ActiveSession, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
 	result, err := s.db.GetAllActiveSessions(ctx)
-	return result, err
+	return result, s.timedOut(ctx, err)
 }
 
 func (s *sqliteStore) RemoveActiveSession(ctx context.Context, programName string) error {
-	return s.db.RemoveActiveSession(ctx, programName)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.RemoveActiveSession(ctx, programName))
 }
 
 func (s *sqliteStore) RemoveAllSessions(ctx context.Context) error {
-	return s.db.RemoveAllSessions(ctx)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.RemoveAllSessions(ctx))
 }
 
 ////////////////// History Repository //////////////////
 
 func (s *sqliteStore) AddToSessionHistory(ctx context.Context, arg database.AddToSessionHistoryParams) error {
-	return s.db.AddToSessionHistory(ctx, arg)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.AddToSessionHistory(ctx, arg))
 }
 
 func (s *sqliteStore) GetCountOfSessionsForProgram(ctx context.Context, programName string) (int64, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
 	result, err := s.db.GetCountOfSessionsForProgram(ctx, programName)
-	return result, err
+	return result, s.timedOut(ctx, err)
 }
 
 func (s *sqliteStore) GetLastSessionForProgram(ctx context.Context, programName string) (database.SessionHistory, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
 	result, err := s.db.GetLastSessionForProgram(ctx, programName)
-	return result, err
+	return result, s.timedOut(ctx, err)
 }
 
 func (s *sqliteStore) RemoveAllRecords(ctx context.Context) error {
-	return s.db.RemoveAllRecords(ctx)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.RemoveAllRecords(ctx))
 }
 
 func (s *sqliteStore) RemoveRecordsForProgram(ctx context.Context, programName string) error {
-	return s.db.RemoveRecordsForProgram(ctx, programName)
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.RemoveRecordsForProgram(ctx, programName))
 }
 
 func (s *sqliteStore) GetSessionHistory(ctx context.Context, arg database.GetSessionHistoryParams) ([]database.SessionHistory, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
 	results, err := s.db.GetSessionHistory(ctx, arg)
-	return results, err
+	return results, s.timedOut(ctx, err)
 }
 
-func (s *sqliteStore) GetAllSessionHistory(ctx context.Context, limit int64) ([]database.SessionHistory, error) {
-	results, err := s.db.GetAllSessionHistory(ctx, limit)
-	return results, err
+func (s *sqliteStore) GetRecentSessionsPerProgram(ctx context.Context, perProgram int64) ([]database.SessionHistory, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	results, err := s.db.GetRecentSessionsPerProgram(ctx, perProgram)
+	return results, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) GetAllSessionHistory(ctx context.Context, arg database.GetAllSessionHistoryParams) ([]database.SessionHistory, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	results, err := s.db.GetAllSessionHistory(ctx, arg)
+	return results, s.timedOut(ctx, err)
 }
 
 func (s *sqliteStore) GetSessionHistoryByDate(ctx context.Context, arg database.GetSessionHistoryByDateParams) ([]database.SessionHistory, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
 	results, err := s.db.GetSessionHistoryByDate(ctx, arg)
-	return results, err
+	return results, s.timedOut(ctx, err)
 }
 
 func (s *sqliteStore) GetAllSessionHistoryByDate(ctx context.Context, arg database.GetAllSessionHistoryByDateParams) ([]database.SessionHistory, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
 	results, err := s.db.GetAllSessionHistoryByDate(ctx, arg)
-	return results, err
+	return results, s.timedOut(ctx, err)
 }
 
 func (s *sqliteStore) GetSessionHistoryByRange(ctx context.Context, arg database.GetSessionHistoryByRangeParams) ([]database.SessionHistory, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
 	results, err := s.db.GetSessionHistoryByRange(ctx, arg)
-	return results, err
+	return results, s.timedOut(ctx, err)
 }
 
 func (s *sqliteStore) GetAllSessionHistoryByRange(ctx context.Context, arg database.GetAllSessionHistoryByRangeParams) ([]database.SessionHistory, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
 	results, err := s.db.GetAllSessionHistoryByRange(ctx, arg)
-	return results, err
+	return results, s.timedOut(ctx, err)
+}
+
+// Streams run for as long as the caller reads, so are bounded only by ctx
+func (s *sqliteStore) StreamSessionHistory(ctx context.Context, arg database.StreamSessionHistoryParams, fn func(database.SessionHistory) error) error {
+	return s.db.StreamSessionHistory(ctx, arg, fn)
+}
+
+func (s *sqliteStore) GetSessionMetadata(ctx context.Context, id int64) (SessionMetadata, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	raw, err := s.db.GetSessionMetadata(ctx, id)
+	if err != nil {
+		return SessionMetadata{}, s.timedOut(ctx, err)
+	}
+	return DecodeSessionMetadata(raw)
+}
+
+func (s *sqliteStore) UpdateSessionMetadata(ctx context.Context, id int64, metadata SessionMetadata) error {
+	raw, err := metadata.Encode()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.UpdateSessionMetadata(ctx, database.UpdateSessionMetadataParams{Metadata: raw, ID: id}))
+}
+
+////////////////// Archive Repository //////////////////
+
+func (s *sqliteStore) ArchiveSessionsBefore(ctx context.Context, arg database.ArchiveSessionsBeforeParams) (int64, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	result, err := s.db.ArchiveSessionsBefore(ctx, arg)
+	return result, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) RemoveSessionsBefore(ctx context.Context, endTime time.Time) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.RemoveSessionsBefore(ctx, endTime))
+}
+
+func (s *sqliteStore) GetArchivedSessions(ctx context.Context, arg database.GetArchivedSessionsParams) ([]database.SessionArchive, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	results, err := s.db.GetArchivedSessions(ctx, arg)
+	return results, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) StreamArchivedSessions(ctx context.Context, arg database.StreamArchivedSessionsParams, fn func(database.SessionArchive) error) error {
+	return s.db.StreamArchivedSessions(ctx, arg, fn)
+}
+
+func (s *sqliteStore) RemoveAllArchivedRecords(ctx context.Context) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.RemoveAllArchivedRecords(ctx))
+}
+
+func (s *sqliteStore) RemoveArchivedRecordsForProgram(ctx context.Context, programName string) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.RemoveArchivedRecordsForProgram(ctx, programName))
+}
+
+// //////////////// Undo Repository //////////////////
+func (s *sqliteStore) SaveUndoOperation(ctx context.Context, arg database.SaveUndoOperationParams) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.SaveUndoOperation(ctx, arg))
+}
+
+func (s *sqliteStore) GetUndoOperation(ctx context.Context) (database.UndoOperation, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	op, err := s.db.GetUndoOperation(ctx)
+	return op, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) ClearUndoOperation(ctx context.Context) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.ClearUndoOperation(ctx))
+}
+
+func (s *sqliteStore) ClearUndoRows(ctx context.Context) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.ClearUndoRows(ctx))
+}
+
+func (s *sqliteStore) SnapshotPrograms(ctx context.Context, name sql.NullString) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.SnapshotPrograms(ctx, name))
+}
+
+func (s *sqliteStore) SnapshotSessionHistory(ctx context.Context, programName sql.NullString) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.SnapshotSessionHistory(ctx, programName))
+}
+
+func (s *sqliteStore) SnapshotSessionArchive(ctx context.Context, programName sql.NullString) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.SnapshotSessionArchive(ctx, programName))
+}
+
+func (s *sqliteStore) RestorePrograms(ctx context.Context) (int64, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	restored, err := s.db.RestorePrograms(ctx)
+	return restored, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) RestoreSessionHistory(ctx context.Context) (int64, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	restored, err := s.db.RestoreSessionHistory(ctx)
+	return restored, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) RestoreSessionArchive(ctx context.Context) (int64, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	restored, err := s.db.RestoreSessionArchive(ctx)
+	return restored, s.timedOut(ctx, err)
+}
+
+////////////////// Token Repository //////////////////
+
+func (s *sqliteStore) CreateAPIToken(ctx context.Context, arg database.CreateAPITokenParams) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.CreateAPIToken(ctx, arg))
+}
+
+func (s *sqliteStore) GetAllAPITokens(ctx context.Context) ([]database.ApiToken, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	results, err := s.db.GetAllAPITokens(ctx)
+	return results, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) GetAPITokenByHash(ctx context.Context, tokenHash string) (database.ApiToken, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	result, err := s.db.GetAPITokenByHash(ctx, tokenHash)
+	return result, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) TouchAPIToken(ctx context.Context, arg database.TouchAPITokenParams) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.TouchAPIToken(ctx, arg))
+}
+
+func (s *sqliteStore) RemoveAPIToken(ctx context.Context, name string) (int64, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	removed, err := s.db.RemoveAPIToken(ctx, name)
+	return removed, s.timedOut(ctx, err)
+}
+
+////////////////// Sync Repository //////////////////
+
+func (s *sqliteStore) AddSyncedSession(ctx context.Context, arg database.AddSyncedSessionParams) (int64, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	added, err := s.db.AddSyncedSession(ctx, arg)
+	return added, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) GetSyncedSessionsAfter(ctx context.Context, arg database.GetSyncedSessionsAfterParams) ([]database.SyncLog, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	results, err := s.db.GetSyncedSessionsAfter(ctx, arg)
+	return results, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) GetSyncState(ctx context.Context) (database.SyncState, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	result, err := s.db.GetSyncState(ctx)
+	return result, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) SaveSyncState(ctx context.Context, arg database.SaveSyncStateParams) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.SaveSyncState(ctx, arg))
+}
+
+func (s *sqliteStore) GetSessionsToPush(ctx context.Context, arg database.GetSessionsToPushParams) ([]database.GetSessionsToPushRow, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	results, err := s.db.GetSessionsToPush(ctx, arg)
+	return results, s.timedOut(ctx, err)
+}
+
+////////////////// Team Repository //////////////////
+
+func (s *sqliteStore) GetTeamState(ctx context.Context) (database.TeamState, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	result, err := s.db.GetTeamState(ctx)
+	return result, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) SaveTeamState(ctx context.Context, arg database.SaveTeamStateParams) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.SaveTeamState(ctx, arg))
+}
+
+////////////////// Snooze Repository //////////////////
+
+func (s *sqliteStore) GetSnooze(ctx context.Context) (database.Snooze, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	result, err := s.db.GetSnooze(ctx)
+	return result, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) SaveSnooze(ctx context.Context, resumeAt time.Time) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.SaveSnooze(ctx, resumeAt))
+}
+
+func (s *sqliteStore) ClearSnooze(ctx context.Context) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.ClearSnooze(ctx))
+}
+
+////////////////// Incognito Repository //////////////////
+
+func (s *sqliteStore) GetIncognito(ctx context.Context) (database.Incognito, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	result, err := s.db.GetIncognito(ctx)
+	return result, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) SaveIncognito(ctx context.Context, until sql.NullTime) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.SaveIncognito(ctx, until))
+}
+
+func (s *sqliteStore) ClearIncognito(ctx context.Context) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.ClearIncognito(ctx))
+}
+
+////////////////// Digest Repository //////////////////
+
+func (s *sqliteStore) GetDigestState(ctx context.Context) (database.DigestState, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	result, err := s.db.GetDigestState(ctx)
+	return result, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) SaveDigestState(ctx context.Context, arg database.SaveDigestStateParams) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.SaveDigestState(ctx, arg))
+}
+
+////////////////// Streak Repository //////////////////
+
+func (s *sqliteStore) GetStreaks(ctx context.Context) ([]database.Streak, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	results, err := s.db.GetStreaks(ctx)
+	return results, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) SaveStreak(ctx context.Context, arg database.SaveStreakParams) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.SaveStreak(ctx, arg))
+}
+
+////////////////// Export Repository //////////////////
+
+func (s *sqliteStore) GetExportState(ctx context.Context, target string) (database.ExportState, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	result, err := s.db.GetExportState(ctx, target)
+	return result, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) SaveExportState(ctx context.Context, arg database.SaveExportStateParams) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.SaveExportState(ctx, arg))
+}
+
+func (s *sqliteStore) GetSessionsToExport(ctx context.Context, arg database.GetSessionsToExportParams) ([]database.GetSessionsToExportRow, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	results, err := s.db.GetSessionsToExport(ctx, arg)
+	return results, s.timedOut(ctx, err)
+}
+
+////////////////// Harvest Repository //////////////////
+
+func (s *sqliteStore) AddHarvestEntry(ctx context.Context, arg database.AddHarvestEntryParams) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.AddHarvestEntry(ctx, arg))
+}
+
+func (s *sqliteStore) GetHarvestKeysSince(ctx context.Context, spentDate string) ([]string, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	results, err := s.db.GetHarvestKeysSince(ctx, spentDate)
+	return results, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) GetLastHarvestDay(ctx context.Context) (string, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	result, err := s.db.GetLastHarvestDay(ctx)
+	return result, s.timedOut(ctx, err)
+}
+
+////////////////// Group Repository //////////////////
+
+func (s *sqliteStore) AddGroupMember(ctx context.Context, arg database.AddGroupMemberParams) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.AddGroupMember(ctx, arg))
+}
+
+func (s *sqliteStore) GetAllGroupMembers(ctx context.Context) ([]database.ProgramGroup, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	results, err := s.db.GetAllGroupMembers(ctx)
+	return results, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) GetGroupMembers(ctx context.Context, groupName string) ([]string, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	results, err := s.db.GetGroupMembers(ctx, groupName)
+	return results, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) RemoveGroupMember(ctx context.Context, arg database.RemoveGroupMemberParams) (int64, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	removed, err := s.db.RemoveGroupMember(ctx, arg)
+	return removed, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) RemoveGroup(ctx context.Context, groupName string) (int64, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	removed, err := s.db.RemoveGroup(ctx, groupName)
+	return removed, s.timedOut(ctx, err)
+}
+
+////////////////// Estimate Repository //////////////////
+
+func (s *sqliteStore) SetProjectEstimate(ctx context.Context, arg database.SetProjectEstimateParams) error {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	return s.timedOut(ctx, s.db.SetProjectEstimate(ctx, arg))
+}
+
+func (s *sqliteStore) GetProjectEstimate(ctx context.Context, project string) (database.ProjectEstimate, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	result, err := s.db.GetProjectEstimate(ctx, project)
+	return result, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) GetAllProjectEstimates(ctx context.Context) ([]database.ProjectEstimate, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	results, err := s.db.GetAllProjectEstimates(ctx)
+	return results, s.timedOut(ctx, err)
+}
+
+func (s *sqliteStore) RemoveProjectEstimate(ctx context.Context, project string) (int64, error) {
+	ctx, cancel := s.opContext(ctx)
+	defer cancel()
+	removed, err := s.db.RemoveProjectEstimate(ctx, project)
+	return removed, s.timedOut(ctx, err)
 }
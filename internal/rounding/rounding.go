@@ -0,0 +1,93 @@
+// Package rounding rounds recorded time as billing wants it, to steps such as 6 or 15 minutes, by session or by day,
+// following rounding in config. It applies only where time leaves as a timesheet or export; sessions stay exact
+package rounding
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+)
+
+// Rounding of time to a step
+type Rule struct {
+	Step   time.Duration // Step rounded to, 0 for exact time
+	Mode   string        // config.RoundNearest, config.RoundUp or config.RoundDown
+	PerDay bool          // Days' totals are rounded rather than each session
+}
+
+// Returns the rule set by rounding in cfg
+func Of(cfg *config.Config) Rule {
+	if cfg == nil {
+		return Rule{}
+	}
+	mode := cfg.Rounding.Mode
+	if mode == "" {
+		mode = config.RoundNearest
+	}
+	return Rule{Step: cfg.RoundingIncrement(), Mode: mode, PerDay: cfg.Rounding.Per == config.RoundPerDay}
+}
+
+// Reports whether the rule changes anything
+func (r Rule) Exact() bool {
+	return r.Step <= 0
+}
+
+// Rounds d to the rule's step
+func (r Rule) Round(d time.Duration) time.Duration {
+	if r.Exact() || d <= 0 {
+		return d
+	}
+	switch r.Mode {
+	case config.RoundUp:
+		return (d + r.Step - 1) / r.Step * r.Step
+	case config.RoundDown:
+		return d / r.Step * r.Step
+	}
+	return (d + r.Step/2) / r.Step * r.Step
+}
+
+// Returns the rounded total of a day's sessions, given how long each was: the sum of the rounded sessions, or the
+// rounded sum with PerDay set
+func (r Rule) Day(sessions []time.Duration) time.Duration {
+	var total, rounded time.Duration
+	for _, d := range sessions {
+		total += d
+		rounded += r.Round(d)
+	}
+	if r.PerDay {
+		return r.Round(total)
+	}
+	return rounded
+}
+
+// Describes the rule, such as "rounded up to 15m per day", empty for exact time
+func (r Rule) String() string {
+	if r.Exact() {
+		return ""
+	}
+	per := "per session"
+	if r.PerDay {
+		per = "per day"
+	}
+	how := "to the nearest"
+	switch r.Mode {
+	case config.RoundUp:
+		how = "up to"
+	case config.RoundDown:
+		how = "down to"
+	}
+	return "rounded " + how + " " + formatStep(r.Step) + " " + per
+}
+
+// Formats a step without trailing zero units, such as 15m or 1h
+func formatStep(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
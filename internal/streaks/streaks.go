@@ -0,0 +1,255 @@
+// Package streaks keeps track of runs of consecutive days meeting a daily goal or keeping under a daily limit, and the
+// achievements earned by long runs. Streaks are brought up to date from session history whenever they're shown
+package streaks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Kinds of streak
+const (
+	KindGoal  = "goal"  // Days a category or group met its goals.daily time, named by its key there
+	KindLimit = "limit" // Days a program or group kept within its limits.daily time, named by its key there
+)
+
+// Days looked back over when a streak is first evaluated, so a goal set today picks up the run leading up to it
+const MaxLookback = 365
+
+// Streak lengths that earn an achievement
+var Milestones = []int64{7, 30, 100, 365}
+
+// Category time without one is counted under
+const Uncategorized = "uncategorized"
+
+// Time recorded on one day, per category, per program and per program group
+type Totals struct {
+	Categories map[string]time.Duration
+	Programs   map[string]time.Duration
+	Groups     map[string]time.Duration // Time of the group's programs added up
+}
+
+// Time counted towards the goals.daily goal keyed name: that of a category, or of a group for group:<name> keys
+func (t Totals) GoalTime(name string) time.Duration {
+	if group, ok := config.GroupTarget(name); ok {
+		return t.Groups[group]
+	}
+	return t.Categories[name]
+}
+
+// Time counted towards the limits.daily limit keyed name: that of a program, or of a group for group:<name> keys
+func (t Totals) LimitTime(name string) time.Duration {
+	if group, ok := config.GroupTarget(name); ok {
+		return t.Groups[group]
+	}
+	return t.Programs[name]
+}
+
+// Returns the time recorded on each day from first up to, not including, end, one entry per day. Only sessions
+// recorded on device count, those of every device when it's empty
+func DayTotals(ctx context.Context, pr repository.ProgramRepository, g repository.GroupRepository, h repository.HistoryRepository, first, end time.Time, device string) ([]Totals, error) {
+	programs, err := pr.GetAllPrograms(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting programs: %w", err)
+	}
+	categories := make(map[string]string, len(programs))
+	for _, program := range programs {
+		categories[program.Name] = program.Category.String
+	}
+	members, err := g.GetAllGroupMembers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting groups: %w", err)
+	}
+	groups := make(map[string][]string)
+	for _, member := range members {
+		groups[member.ProgramName] = append(groups[member.ProgramName], member.GroupName)
+	}
+
+	var days []time.Time
+	for day := first; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	totals := make([]Totals, len(days))
+	for i := range totals {
+		totals[i] = Totals{Categories: make(map[string]time.Duration), Programs: make(map[string]time.Duration), Groups: make(map[string]time.Duration)}
+	}
+
+	params := database.StreamSessionHistoryParams{RangeStart: first.UTC(), RangeEnd: end.UTC(), Device: database.NullString(device)}
+	err = h.StreamSessionHistory(ctx, params, func(session database.SessionHistory) error {
+		category := categories[session.ProgramName]
+		if category == "" {
+			category = Uncategorized
+		}
+		for i, day := range days {
+			spent := Within(session, day, day.AddDate(0, 0, 1))
+			if spent <= 0 {
+				continue
+			}
+			totals[i].Categories[category] += spent
+			totals[i].Programs[session.ProgramName] += spent
+			for _, group := range groups[session.ProgramName] {
+				totals[i].Groups[group] += spent
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading session history: %w", err)
+	}
+	return totals, nil
+}
+
+// Returns the time of a session that falls within [from, to). A session entirely inside counts its recorded duration,
+// one crossing a boundary the wall-clock part inside
+func Within(session database.SessionHistory, from, to time.Time) time.Duration {
+	if !session.StartTime.Before(from) && !session.EndTime.After(to) {
+		return time.Duration(session.DurationSeconds) * time.Second
+	}
+
+	start, end := session.StartTime, session.EndTime
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start).Truncate(time.Second)
+}
+
+// Reports whether a day's totals keep the streak of kind and name going
+func kept(cfg *config.Config, kind, name string, totals Totals) bool {
+	if kind == KindGoal {
+		return totals.GoalTime(name) >= cfg.DailyGoal(name)
+	}
+	return totals.LimitTime(name) <= cfg.DailyLimit(name)
+}
+
+// Brings the streaks of every configured goal and limit up to yesterday, the last complete day, and returns them
+// ordered by kind and name. Streaks of goals or limits no longer configured are kept but not returned
+func Update(ctx context.Context, store repository.Store, cfg *config.Config, now time.Time) ([]database.Streak, error) {
+	loc, err := cfg.Location()
+	if err != nil {
+		return nil, err
+	}
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	saved, err := store.GetStreaks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting streaks: %w", err)
+	}
+	byKey := make(map[[2]string]database.Streak, len(saved))
+	for _, streak := range saved {
+		byKey[[2]string{streak.Kind, streak.Name}] = streak
+	}
+
+	var streaks []database.Streak
+	first := today
+	if cfg != nil {
+		for category := range cfg.Goals.Daily {
+			if cfg.DailyGoal(category) > 0 {
+				streaks = append(streaks, streak(byKey, KindGoal, category, today))
+			}
+		}
+		for program := range cfg.Limits.Daily {
+			if cfg.DailyLimit(program) > 0 {
+				streaks = append(streaks, streak(byKey, KindLimit, program, today))
+			}
+		}
+	}
+	for _, s := range streaks {
+		if from := nextDay(s.Through, today); from.Before(first) {
+			first = from
+		}
+	}
+	sort.Slice(streaks, func(i, j int) bool {
+		if streaks[i].Kind != streaks[j].Kind {
+			return streaks[i].Kind < streaks[j].Kind
+		}
+		return streaks[i].Name < streaks[j].Name
+	})
+	if !first.Before(today) {
+		return streaks, nil
+	}
+
+	totals, err := DayTotals(ctx, store, store, store, first, today, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for i := range streaks {
+		s := &streaks[i]
+		from := nextDay(s.Through, today)
+		if !from.Before(today) {
+			continue
+		}
+		for n, day := 0, first; day.Before(today); n, day = n+1, day.AddDate(0, 0, 1) {
+			if day.Before(from) {
+				continue
+			}
+			if s.Kind == KindLimit && len(totals[n].Programs) == 0 { // Nothing recorded, such as a day off, neither counts nor breaks
+				continue
+			}
+			if kept(cfg, s.Kind, s.Name, totals[n]) {
+				s.Current++
+				s.Best = max(s.Best, s.Current)
+			} else {
+				s.Current = 0
+			}
+		}
+		s.Through = today.AddDate(0, 0, -1).Format(time.DateOnly)
+
+		err := store.SaveStreak(ctx, database.SaveStreakParams{Kind: s.Kind, Name: s.Name, Current: s.Current, Best: s.Best, Through: s.Through})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error saving %s streak of %s: %w", s.Kind, s.Name, err))
+		}
+	}
+	return streaks, errors.Join(errs...)
+}
+
+// Returns the saved streak of kind and name, or a new one evaluated from MaxLookback days before today
+func streak(saved map[[2]string]database.Streak, kind, name string, today time.Time) database.Streak {
+	if s, ok := saved[[2]string{kind, name}]; ok {
+		return s
+	}
+	return database.Streak{Kind: kind, Name: name, Through: today.AddDate(0, 0, -MaxLookback-1).Format(time.DateOnly)}
+}
+
+// Returns the day after through, a YYYY-MM-DD date in today's location. An unreadable date is treated as a new streak
+func nextDay(through string, today time.Time) time.Time {
+	day, err := time.ParseInLocation(time.DateOnly, through, today.Location())
+	if err != nil {
+		return today.AddDate(0, 0, -MaxLookback)
+	}
+	return day.AddDate(0, 0, 1)
+}
+
+// Returns the milestones a streak has reached at its best
+func Achievements(s database.Streak) []int64 {
+	var reached []int64
+	for _, milestone := range Milestones {
+		if s.Best >= milestone {
+			reached = append(reached, milestone)
+		}
+	}
+	return reached
+}
+
+// Describes what keeping a streak means, such as "coding goal met" or "steam kept under limit"
+func Describe(s database.Streak) string {
+	if s.Kind == KindGoal {
+		return s.Name + " goal met"
+	}
+	return s.Name + " kept under limit"
+}
@@ -0,0 +1,253 @@
+package syncapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Client of a sync server, pushing this machine's sessions and pulling those of the others
+type Client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// Outcome of a sync
+type Result struct {
+	Pushed int // Sessions of this machine the server didn't have yet
+	Pulled int // Sessions of other machines added here
+}
+
+// Creates a client for the configured sync server
+func NewClient(cfg config.SyncConfig) (*Client, error) {
+	if cfg.Server == "" {
+		return nil, errors.New("no sync server configured, set sync.server and sync.token")
+	}
+	if cfg.Token == "" {
+		return nil, errors.New("no sync token configured, create one on the server with 'timekeep token create <name> --scope sync' and set sync.token")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading sync CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return &Client{
+		server: strings.TrimSuffix(cfg.Server, "/"),
+		token:  cfg.Token,
+		http:   &http.Client{Transport: transport, Timeout: time.Minute},
+	}, nil
+}
+
+// Pushes sessions recorded on this machine since the last sync, labelled with device, then pulls the sessions other
+// machines uploaded since. Progress is saved after each page, so an interrupted sync carries on where it stopped
+func (c *Client) Sync(ctx context.Context, tx repository.TxRepository, device string) (Result, error) {
+	var result Result
+
+	state, err := loadState(ctx, tx)
+	if err != nil {
+		return result, err
+	}
+
+	for {
+		var rows []database.GetSessionsToPushRow
+		err := tx.WithTx(ctx, func(store repository.Store) error {
+			var err error
+			rows, err = store.GetSessionsToPush(ctx, database.GetSessionsToPushParams{
+				AfterID: state.PushedThrough,
+				Device:  device,
+				Max:     MaxUpload,
+			})
+			return err
+		})
+		if err != nil {
+			return result, fmt.Errorf("error getting sessions to push: %w", err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		upload := Upload{Device: device, DeviceID: state.DeviceID}
+		for _, row := range rows {
+			session := Session{
+				ID:              SessionID(state.DeviceID, row.ProgramName, row.StartTime),
+				Program:         row.ProgramName,
+				Category:        row.Category.String,
+				Project:         row.Project.String,
+				Start:           row.StartTime.UTC(),
+				End:             row.EndTime.UTC(),
+				DurationSeconds: row.DurationSeconds,
+			}
+			if row.Metadata.Valid {
+				session.Metadata = json.RawMessage(row.Metadata.String)
+			}
+			upload.Sessions = append(upload.Sessions, session)
+		}
+
+		var uploaded UploadResult
+		if err := c.do(ctx, http.MethodPost, SessionsPath, upload, &uploaded); err != nil {
+			return result, fmt.Errorf("error pushing sessions: %w", err)
+		}
+		result.Pushed += uploaded.Accepted
+
+		state.PushedThrough = rows[len(rows)-1].ID
+		if err := saveState(ctx, tx, state); err != nil {
+			return result, err
+		}
+	}
+
+	for {
+		query := url.Values{CursorParam: {strconv.FormatInt(state.PullCursor, 10)}, DeviceParam: {state.DeviceID}}
+		var page PullResult
+		if err := c.do(ctx, http.MethodGet, SessionsPath+"?"+query.Encode(), nil, &page); err != nil {
+			return result, fmt.Errorf("error pulling sessions: %w", err)
+		}
+
+		// Merged a device at a time, as uploads are
+		for start := 0; start < len(page.Sessions); {
+			end := start
+			upload := Upload{Device: page.Sessions[start].Device, DeviceID: page.Sessions[start].DeviceID}
+			for end < len(page.Sessions) && page.Sessions[end].DeviceID == upload.DeviceID {
+				upload.Sessions = append(upload.Sessions, page.Sessions[end].Session)
+				end++
+			}
+			merged, err := Merge(ctx, tx, upload)
+			if err != nil {
+				return result, fmt.Errorf("error merging sessions from %s: %w", upload.Device, err)
+			}
+			result.Pulled += merged.Accepted
+			start = end
+		}
+
+		state.PullCursor = page.Cursor
+		if err := saveState(ctx, tx, state); err != nil {
+			return result, err
+		}
+		if !page.More {
+			break
+		}
+	}
+
+	state.LastSync = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	return result, saveState(ctx, tx, state)
+}
+
+// Sends a request to the sync server, encoding body and decoding the response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, reader)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("sync server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// Loads the sync state, creating it with a new device ID on first sync
+func loadState(ctx context.Context, tx repository.TxRepository) (database.SyncState, error) {
+	var state database.SyncState
+	err := tx.WithTx(ctx, func(store repository.Store) error {
+		var err error
+		state, err = store.GetSyncState(ctx)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return fmt.Errorf("failed to generate device ID: %w", err)
+		}
+		id[6] = id[6]&0x0f | 0x40 // Version 4
+		id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+		state = database.SyncState{DeviceID: formatUUID(id)}
+
+		return store.SaveSyncState(ctx, database.SaveSyncStateParams{DeviceID: state.DeviceID})
+	})
+	if err != nil {
+		return state, fmt.Errorf("error loading sync state: %w", err)
+	}
+
+	return state, nil
+}
+
+func saveState(ctx context.Context, tx repository.TxRepository, state database.SyncState) error {
+	err := tx.WithTx(ctx, func(store repository.Store) error {
+		return store.SaveSyncState(ctx, database.SaveSyncStateParams{
+			DeviceID:      state.DeviceID,
+			PushedThrough: state.PushedThrough,
+			PullCursor:    state.PullCursor,
+			LastSync:      state.LastSync,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("error saving sync state: %w", err)
+	}
+	return nil
+}
+
+// Returns the UUID of a session recorded on deviceID, derived from the program and start time so pushing the same
+// session again is recognised by the server
+func SessionID(deviceID, program string, start time.Time) string {
+	sum := sha256.Sum256([]byte(deviceID + "\x00" + program + "\x00" + start.UTC().Format(time.RFC3339Nano)))
+	id := sum[:16]
+	id[6] = id[6]&0x0f | 0x50 // Version 5, name-based
+	id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+	return formatUUID(id)
+}
+
+func formatUUID(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,149 @@
+package syncapi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/repository"
+)
+
+// Returned, wrapped, for uploads rejected as malformed
+var ErrInvalidUpload = errors.New("invalid upload")
+
+// Checks an upload before any of it is stored
+func Validate(upload Upload) error {
+	if strings.TrimSpace(upload.Device) == "" {
+		return fmt.Errorf("%w: no device", ErrInvalidUpload)
+	}
+	if len(upload.Sessions) > MaxUpload {
+		return fmt.Errorf("%w: %d sessions, at most %d are accepted at once", ErrInvalidUpload, len(upload.Sessions), MaxUpload)
+	}
+	for i, session := range upload.Sessions {
+		switch {
+		case session.ID == "":
+			return fmt.Errorf("%w: session %d has no id", ErrInvalidUpload, i)
+		case session.Program == "":
+			return fmt.Errorf("%w: session %s has no program", ErrInvalidUpload, session.ID)
+		case session.End.Before(session.Start), session.DurationSeconds < 0:
+			return fmt.Errorf("%w: session %s ends before it starts", ErrInvalidUpload, session.ID)
+		case len(session.Metadata) > 0 && !json.Valid(session.Metadata):
+			return fmt.Errorf("%w: session %s has invalid metadata", ErrInvalidUpload, session.ID)
+		}
+	}
+	return nil
+}
+
+// Stores an upload's sessions in one transaction. Sessions are recorded in the sync log by ID, so those seen before
+// are skipped; new ones are added to session history under the upload's device. Their programs are added as remote
+// programs, reported on but not monitored here, and lifetimes, which count this device's time only, are left alone.
+// Used by the sync server for uploads and by clients for the sessions they pull
+func Merge(ctx context.Context, tx repository.TxRepository, upload Upload) (UploadResult, error) {
+	if err := Validate(upload); err != nil {
+		return UploadResult{}, err
+	}
+
+	deviceID := upload.DeviceID
+	if deviceID == "" {
+		deviceID = upload.Device
+	}
+
+	var result UploadResult
+	now := time.Now().UTC()
+	err := tx.WithTx(ctx, func(store repository.Store) error {
+		for _, session := range upload.Sessions {
+			metadata := sql.NullString{String: string(session.Metadata), Valid: len(session.Metadata) > 0 && string(session.Metadata) != "null"}
+			category := sql.NullString{String: session.Category, Valid: session.Category != ""}
+			project := sql.NullString{String: session.Project, Valid: session.Project != ""}
+
+			added, err := store.AddSyncedSession(ctx, database.AddSyncedSessionParams{
+				SyncID:          session.ID,
+				Device:          upload.Device,
+				DeviceID:        deviceID,
+				ProgramName:     session.Program,
+				Category:        category,
+				Project:         project,
+				StartTime:       session.Start.UTC(),
+				EndTime:         session.End.UTC(),
+				DurationSeconds: session.DurationSeconds,
+				Metadata:        metadata,
+				ReceivedAt:      now,
+			})
+			if err != nil {
+				return fmt.Errorf("error logging session %s: %w", session.ID, err)
+			}
+			if added == 0 {
+				result.Duplicates++
+				continue
+			}
+
+			err = store.AddRemoteProgram(ctx, database.AddRemoteProgramParams{Name: session.Program, Category: category, Project: project})
+			if err != nil {
+				return fmt.Errorf("error adding program %s: %w", session.Program, err)
+			}
+			err = store.AddToSessionHistory(ctx, database.AddToSessionHistoryParams{
+				ProgramName:     session.Program,
+				StartTime:       session.Start.UTC(),
+				EndTime:         session.End.UTC(),
+				DurationSeconds: session.DurationSeconds,
+				Metadata:        metadata,
+				Device:          sql.NullString{String: upload.Device, Valid: true},
+			})
+			if err != nil {
+				return fmt.Errorf("error adding session %s: %w", session.ID, err)
+			}
+			result.Accepted++
+		}
+		return nil
+	})
+	if err != nil {
+		return UploadResult{}, err
+	}
+
+	return result, nil
+}
+
+// Returns up to limit sessions logged after cursor that weren't uploaded by excludeDeviceID, and the cursor to pull
+// from next
+func Log(ctx context.Context, tx repository.TxRepository, cursor int64, excludeDeviceID string, limit int) (PullResult, error) {
+	result := PullResult{Cursor: cursor}
+	err := tx.WithTx(ctx, func(store repository.Store) error {
+		rows, err := store.GetSyncedSessionsAfter(ctx, database.GetSyncedSessionsAfterParams{
+			After:           cursor,
+			ExcludeDeviceID: excludeDeviceID,
+			Max:             int64(limit) + 1,
+		})
+		if err != nil {
+			return fmt.Errorf("error getting synced sessions: %w", err)
+		}
+
+		if len(rows) > limit {
+			rows = rows[:limit]
+			result.More = true
+		}
+		for _, row := range rows {
+			session := Session{
+				ID:              row.SyncID,
+				Program:         row.ProgramName,
+				Category:        row.Category.String,
+				Project:         row.Project.String,
+				Start:           row.StartTime.UTC(),
+				End:             row.EndTime.UTC(),
+				DurationSeconds: row.DurationSeconds,
+			}
+			if row.Metadata.Valid {
+				session.Metadata = json.RawMessage(row.Metadata.String)
+			}
+			result.Sessions = append(result.Sessions, RemoteSession{Device: row.Device, DeviceID: row.DeviceID, Session: session})
+			result.Cursor = row.Seq
+		}
+		return nil
+	})
+
+	return result, err
+}
@@ -0,0 +1,64 @@
+// Package syncapi defines the HTTP API served by "timekeep server", through which machines share their sessions
+// with one combined store
+package syncapi
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Path of the sessions endpoint, authenticated with an API token of the sync or admin scope as a bearer token.
+// Sessions are uploaded with POST, and those uploaded by other devices fetched with GET, passing the cursor of the
+// last page and the caller's device ID as the CursorParam and DeviceParam query parameters
+const SessionsPath = "/sync/v1/sessions"
+
+// Query parameters of a pull
+const (
+	CursorParam = "after"
+	DeviceParam = "device_id"
+)
+
+// Most sessions accepted in one upload
+const MaxUpload = 5000
+
+// Most sessions returned by one pull
+const MaxPull = 1000
+
+// Completed session as sent between machines
+type Session struct {
+	ID              string          `json:"id"`                 // UUID identifying the session across machines, making uploads idempotent
+	Program         string          `json:"program"`            // Canonical program name
+	Category        string          `json:"category,omitempty"` // Category of the program on the recording machine
+	Project         string          `json:"project,omitempty"`  // Project of the program on the recording machine
+	Start           time.Time       `json:"start"`
+	End             time.Time       `json:"end"`
+	DurationSeconds int64           `json:"duration_seconds"`
+	Metadata        json.RawMessage `json:"metadata,omitempty"` // Session metadata as stored by the recording machine
+}
+
+// Body of an upload: sessions recorded on one device
+type Upload struct {
+	Device   string    `json:"device"`              // Label of the machine the sessions were recorded on
+	DeviceID string    `json:"device_id,omitempty"` // Stable ID of that machine, its sessions are left out of its pulls
+	Sessions []Session `json:"sessions"`
+}
+
+// Response to an upload
+type UploadResult struct {
+	Accepted   int `json:"accepted"`   // Sessions added to the store
+	Duplicates int `json:"duplicates"` // Sessions already uploaded before, ignored
+}
+
+// Session uploaded by another device, as returned by a pull
+type RemoteSession struct {
+	Device   string `json:"device"`
+	DeviceID string `json:"device_id"`
+	Session
+}
+
+// Response to a pull
+type PullResult struct {
+	Sessions []RemoteSession `json:"sessions"`
+	Cursor   int64           `json:"cursor"` // Pass as CursorParam to get the sessions after these
+	More     bool            `json:"more"`   // More sessions follow this page
+}
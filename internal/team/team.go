@@ -0,0 +1,208 @@
+// Package team reports aggregated daily totals per category to a team endpoint, for organizations that want
+// utilization data without seeing which programs anyone ran or when
+package team
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/jms-guy/timekeep/internal/repository"
+	"github.com/jms-guy/timekeep/internal/streaks"
+)
+
+// Categories reported in place of real ones
+const (
+	Uncategorized = "uncategorized" // Programs with no category
+	Other         = "other"         // Categories left out of team.categories
+)
+
+// Most days reported at once after a gap, such as the machine being off for a while
+const MaxBackfill = 30
+
+// Layout of Report.Day
+const dayLayout = "2006-01-02"
+
+// Body posted to the team endpoint, one per day. Holds nothing but the day and the seconds recorded per category
+type Report struct {
+	Day    string           `json:"day"`    // Date in the reporter's timezone, YYYY-MM-DD
+	Totals map[string]int64 `json:"totals"` // Seconds recorded per category
+}
+
+// Returns the reports for the completed days after the last one sent, oldest first, and the last day they cover.
+// The first run covers only yesterday, so enabling team reporting never discloses earlier history. Days without
+// recorded time are left out
+func Pending(ctx context.Context, tx repository.TxRepository, cfg *config.Config, now time.Time) ([]Report, string, error) {
+	loc, err := cfg.Location()
+	if err != nil {
+		return nil, "", err
+	}
+
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	first := today.AddDate(0, 0, -1)
+
+	var reports []Report
+	err = tx.WithTx(ctx, func(store repository.Store) error {
+		state, err := store.GetTeamState(ctx)
+		switch {
+		case err == nil:
+			last, err := time.ParseInLocation(dayLayout, state.LastDay, loc)
+			if err != nil {
+				return fmt.Errorf("invalid last reported day %q: %w", state.LastDay, err)
+			}
+			first = last.AddDate(0, 0, 1)
+			if earliest := today.AddDate(0, 0, -MaxBackfill); first.Before(earliest) {
+				first = earliest
+			}
+		case !errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("error getting team report state: %w", err)
+		}
+		if !first.Before(today) {
+			return nil
+		}
+
+		programs, err := store.GetAllPrograms(ctx)
+		if err != nil {
+			return fmt.Errorf("error getting programs: %w", err)
+		}
+		categories := make(map[string]string, len(programs))
+		for _, program := range programs {
+			categories[program.Name] = reportedCategory(program.Category.String, cfg.Team.Categories)
+		}
+
+		var days []time.Time
+		for day := first; day.Before(today); day = day.AddDate(0, 0, 1) {
+			days = append(days, day)
+		}
+		totals := make([]map[string]int64, len(days))
+
+		params := database.StreamSessionHistoryParams{RangeStart: first.UTC(), RangeEnd: today.UTC()}
+		err = store.StreamSessionHistory(ctx, params, func(session database.SessionHistory) error {
+			category, ok := categories[session.ProgramName]
+			if !ok {
+				category = Uncategorized
+			}
+			for i, day := range days {
+				seconds := int64(streaks.Within(session, day, day.AddDate(0, 0, 1)).Seconds())
+				if seconds <= 0 {
+					continue
+				}
+				if totals[i] == nil {
+					totals[i] = make(map[string]int64)
+				}
+				totals[i][category] += seconds
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error reading session history: %w", err)
+		}
+
+		for i, day := range days {
+			if totals[i] != nil {
+				reports = append(reports, Report{Day: day.Format(dayLayout), Totals: totals[i]})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if !first.Before(today) {
+		return nil, "", nil
+	}
+
+	return reports, today.AddDate(0, 0, -1).Format(dayLayout), nil
+}
+
+// Posts reports to the team endpoint in order, recording through as the last day sent once all are accepted
+func Send(ctx context.Context, tx repository.TxRepository, cfg config.TeamConfig, reports []Report, through string) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, report := range reports {
+		if err := post(ctx, client, cfg, report); err != nil {
+			return fmt.Errorf("error reporting %s: %w", report.Day, err)
+		}
+	}
+
+	if through == "" {
+		return nil
+	}
+	err := tx.WithTx(ctx, func(store repository.Store) error {
+		return store.SaveTeamState(ctx, database.SaveTeamStateParams{LastDay: through, ReportedAt: time.Now().UTC()})
+	})
+	if err != nil {
+		return fmt.Errorf("error saving team report state: %w", err)
+	}
+	return nil
+}
+
+// Sends the pending reports when team reporting is enabled, returning how many were sent
+func Run(ctx context.Context, tx repository.TxRepository, cfg *config.Config, now time.Time) (int, error) {
+	if cfg == nil || !cfg.Team.Enabled {
+		return 0, errors.New("team reporting is disabled, set team.enabled and team.endpoint")
+	}
+	if cfg.Team.Endpoint == "" {
+		return 0, errors.New("no team endpoint configured, set team.endpoint")
+	}
+
+	reports, through, err := Pending(ctx, tx, cfg, now)
+	if err != nil {
+		return 0, err
+	}
+	if err := Send(ctx, tx, cfg.Team, reports, through); err != nil {
+		return 0, err
+	}
+
+	return len(reports), nil
+}
+
+func post(ctx context.Context, client *http.Client, cfg config.TeamConfig, report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("team endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Returns the category a program's time is reported under, given the categories allowed by name
+func reportedCategory(category string, allowed []string) string {
+	switch {
+	case category == "":
+		return Uncategorized
+	case len(allowed) > 0 && !slices.Contains(allowed, category):
+		return Other
+	default:
+		return category
+	}
+}
@@ -0,0 +1,252 @@
+// Package webhook sends session events to the HTTP endpoints listed in webhooks, as they happen. Each webhook can pick
+// the events it's sent, build its body from a Go template and add headers, so services such as ntfy or Matrix can be
+// targeted directly
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+)
+
+// Events sent
+const (
+	SessionStart = config.WebhookSessionStart
+	SessionEnd   = config.WebhookSessionEnd
+)
+
+// How long an endpoint has to answer
+const Timeout = 10 * time.Second
+
+// A session starting or ending, the data given to body templates
+type Event struct {
+	Kind     string // SessionStart or SessionEnd
+	Program  string
+	Category string
+	Project  string
+	Device   string
+	Start    time.Time
+	End      time.Time     // Zero for SessionStart
+	Duration time.Duration // Zero for SessionStart
+	Reason   string        // Why the session ended, such as exit or sleep
+}
+
+// Whole minutes of the session, for templates
+func (e Event) Minutes() int64 {
+	return int64(e.Duration.Minutes())
+}
+
+// Body of the event format, sent by default
+type payload struct {
+	Event           string `json:"event"`
+	Program         string `json:"program"`
+	Category        string `json:"category,omitempty"`
+	Project         string `json:"project,omitempty"`
+	Device          string `json:"device,omitempty"`
+	Start           string `json:"start"`
+	End             string `json:"end,omitempty"`
+	DurationSeconds int64  `json:"duration_seconds,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+}
+
+// Body of the zapier format: flat keys, all of them always present so automations can map them, and ISO 8601 times
+type flatPayload struct {
+	ID              string `json:"id"` // Same for the same event, so automations can drop repeats
+	Event           string `json:"event"`
+	Program         string `json:"program"`
+	Category        string `json:"category"`
+	Project         string `json:"project"`
+	Device          string `json:"device"`
+	Start           string `json:"start"`
+	End             string `json:"end"`
+	DurationSeconds int64  `json:"duration_seconds"`
+	DurationMinutes int64  `json:"duration_minutes"`
+	Reason          string `json:"reason"`
+	OccurredAt      string `json:"occurred_at"` // When the session started or ended
+}
+
+// Body of the ifttt format, the three values IFTTT's Webhooks service passes on to applets
+type iftttPayload struct {
+	Value1 string `json:"value1"` // Program
+	Value2 string `json:"value2"` // Category
+	Value3 string `json:"value3"` // Minutes the session lasted, empty for a start
+}
+
+// Functions available to body templates, besides Go's own
+var funcs = template.FuncMap{
+	// Encodes a value as JSON, such as a quoted and escaped string
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Reports whether hook is sent e: its kind is among hook's events, its program matches one of hook's programs and
+// its category is one of hook's categories, when set, and an ended session lasted at least hook's min_duration. With
+// min_duration set, starts aren't sent, as their length isn't known yet. Programs and categories match in any case
+func Matches(hook config.WebhookConfig, e Event) bool {
+	if len(hook.Events) > 0 && !slices.Contains(hook.Events, e.Kind) {
+		return false
+	}
+	program := strings.ToLower(e.Program)
+	if len(hook.Programs) > 0 && !slices.ContainsFunc(hook.Programs, func(pattern string) bool {
+		ok, _ := path.Match(strings.ToLower(strings.TrimSpace(pattern)), program)
+		return ok
+	}) {
+		return false
+	}
+	category := strings.ToLower(e.Category)
+	if len(hook.Categories) > 0 && !slices.ContainsFunc(hook.Categories, func(c string) bool {
+		return strings.ToLower(strings.TrimSpace(c)) == category
+	}) {
+		return false
+	}
+	if minimum := hook.MinSession(); minimum > 0 && (e.Kind != SessionEnd || e.Duration < minimum) {
+		return false
+	}
+	return true
+}
+
+// Renders the body hook is sent for e: its template executed with the event, or the event in hook's format
+func Body(hook config.WebhookConfig, e Event) ([]byte, error) {
+	if hook.Template != "" {
+		tmpl, err := template.New("webhook").Funcs(funcs).Option("missingkey=error").Parse(hook.Template)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing template: %w", err)
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, e); err != nil {
+			return nil, fmt.Errorf("error executing template: %w", err)
+		}
+		return b.Bytes(), nil
+	}
+
+	var end string
+	if !e.End.IsZero() {
+		end = e.End.Format(time.RFC3339)
+	}
+
+	switch hook.Format {
+	case config.WebhookFormatZapier:
+		occurred := e.Start
+		if !e.End.IsZero() {
+			occurred = e.End
+		}
+		return json.Marshal(flatPayload{
+			ID:              fmt.Sprintf("%s-%s-%d", e.Kind, e.Program, e.Start.Unix()),
+			Event:           e.Kind,
+			Program:         e.Program,
+			Category:        e.Category,
+			Project:         e.Project,
+			Device:          e.Device,
+			Start:           e.Start.Format(time.RFC3339),
+			End:             end,
+			DurationSeconds: int64(e.Duration.Seconds()),
+			DurationMinutes: e.Minutes(),
+			Reason:          e.Reason,
+			OccurredAt:      occurred.Format(time.RFC3339),
+		})
+	case config.WebhookFormatIFTTT:
+		p := iftttPayload{Value1: e.Program, Value2: e.Category}
+		if e.Kind == SessionEnd {
+			p.Value3 = fmt.Sprint(e.Minutes())
+		}
+		return json.Marshal(p)
+	}
+
+	return json.Marshal(payload{
+		Event:           e.Kind,
+		Program:         e.Program,
+		Category:        e.Category,
+		Project:         e.Project,
+		Device:          e.Device,
+		Start:           e.Start.Format(time.RFC3339),
+		End:             end,
+		DurationSeconds: int64(e.Duration.Seconds()),
+		Reason:          e.Reason,
+	})
+}
+
+// Returns an example event of kind for program, a session of 45 minutes ending at now or one starting at now, for
+// trying out webhooks
+func Sample(kind, program string, now time.Time) Event {
+	now = now.Truncate(time.Second)
+	if kind == SessionStart {
+		return Event{Kind: SessionStart, Program: program, Start: now}
+	}
+	return Event{Kind: SessionEnd, Program: program, Start: now.Add(-45 * time.Minute), End: now, Duration: 45 * time.Minute, Reason: "exit"}
+}
+
+// Sends e to hook
+func Send(ctx context.Context, client *http.Client, hook config.WebhookConfig, e Event) error {
+	body, err := Body(hook, e)
+	if err != nil {
+		return err
+	}
+
+	method := strings.ToUpper(hook.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, method, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if hook.Template == "" {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	for key, value := range hook.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Sends e to every webhook in cfg it matches, returning the errors of those that failed
+func Dispatch(ctx context.Context, client *http.Client, cfg *config.Config, e Event) error {
+	if cfg == nil {
+		return nil
+	}
+
+	var errs []error
+	for i, hook := range cfg.Webhooks {
+		if !Matches(hook, e) {
+			continue
+		}
+		if err := Send(ctx, client, hook, e); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", Label(hook, i), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Names a webhook in messages: its name, or its position in the config
+func Label(hook config.WebhookConfig, i int) string {
+	if hook.Name != "" {
+		return hook.Name
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
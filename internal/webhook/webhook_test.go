@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatches(t *testing.T) {
+	start := Event{Kind: SessionStart, Program: "code", Category: "coding"}
+	end := Event{Kind: SessionEnd, Program: "code", Category: "coding", Duration: 30 * time.Minute}
+
+	tests := []struct {
+		name  string
+		hook  config.WebhookConfig
+		event Event
+		want  bool
+	}{
+		{name: "no filters", event: start, want: true},
+		{name: "event listed", hook: config.WebhookConfig{Events: []string{SessionEnd}}, event: end, want: true},
+		{name: "event not listed", hook: config.WebhookConfig{Events: []string{SessionEnd}}, event: start, want: false},
+		{name: "program glob", hook: config.WebhookConfig{Programs: []string{"steam", "co*"}}, event: start, want: true},
+		{name: "program glob in another case", hook: config.WebhookConfig{Programs: []string{" CO* "}}, event: start, want: true},
+		{name: "program event in another case", hook: config.WebhookConfig{Programs: []string{"code"}}, event: Event{Kind: SessionStart, Program: "Code"}, want: true},
+		{name: "program not matched", hook: config.WebhookConfig{Programs: []string{"steam", "c?"}}, event: start, want: false},
+		{name: "category listed", hook: config.WebhookConfig{Categories: []string{"gaming", "coding"}}, event: start, want: true},
+		{name: "category in another case", hook: config.WebhookConfig{Categories: []string{" Coding "}}, event: start, want: true},
+		{name: "category not listed", hook: config.WebhookConfig{Categories: []string{"gaming"}}, event: start, want: false},
+		{name: "no category", hook: config.WebhookConfig{Categories: []string{"coding"}}, event: Event{Kind: SessionStart, Program: "code"}, want: false},
+		{name: "long enough", hook: config.WebhookConfig{MinDuration: "30m"}, event: end, want: true},
+		{name: "too short", hook: config.WebhookConfig{MinDuration: "31m"}, event: end, want: false},
+		{name: "start with min_duration", hook: config.WebhookConfig{MinDuration: "1m"}, event: start, want: false},
+		{name: "invalid min_duration", hook: config.WebhookConfig{MinDuration: "soon"}, event: start, want: true},
+		{name: "every filter", hook: config.WebhookConfig{Events: []string{SessionEnd}, Programs: []string{"code"}, Categories: []string{"coding"}, MinDuration: "10m"}, event: end, want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Matches(tc.hook, tc.event))
+		})
+	}
+}
+
+func TestBody(t *testing.T) {
+	started := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	end := Event{Kind: SessionEnd, Program: "code", Category: "coding", Project: "timekeep", Start: started, End: started.Add(90 * time.Minute), Duration: 90 * time.Minute, Reason: "exit"}
+	start := Event{Kind: SessionStart, Program: "code", Start: started}
+
+	tests := []struct {
+		name    string
+		hook    config.WebhookConfig
+		event   Event
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "event format",
+			event: end,
+			want:  `{"event":"session.end","program":"code","category":"coding","project":"timekeep","start":"2026-03-09T09:00:00Z","end":"2026-03-09T10:30:00Z","duration_seconds":5400,"reason":"exit"}`,
+		},
+		{
+			name:  "event format start",
+			event: start,
+			want:  `{"event":"session.start","program":"code","start":"2026-03-09T09:00:00Z"}`,
+		},
+		{
+			name:  "zapier",
+			hook:  config.WebhookConfig{Format: config.WebhookFormatZapier},
+			event: start,
+			want:  `{"id":"session.start-code-1773046800","event":"session.start","program":"code","category":"","project":"","device":"","start":"2026-03-09T09:00:00Z","end":"","duration_seconds":0,"duration_minutes":0,"reason":"","occurred_at":"2026-03-09T09:00:00Z"}`,
+		},
+		{
+			name:  "ifttt",
+			hook:  config.WebhookConfig{Format: config.WebhookFormatIFTTT},
+			event: end,
+			want:  `{"value1":"code","value2":"coding","value3":"90"}`,
+		},
+		{
+			name:  "ifttt start",
+			hook:  config.WebhookConfig{Format: config.WebhookFormatIFTTT},
+			event: start,
+			want:  `{"value1":"code","value2":"","value3":""}`,
+		},
+		{
+			name:  "template",
+			hook:  config.WebhookConfig{Template: `{{.Program}} ({{.Category}}) ran {{.Minutes}} minutes, ended by {{.Reason}}`},
+			event: end,
+			want:  "code (coding) ran 90 minutes, ended by exit",
+		},
+		{
+			name:  "template json function",
+			hook:  config.WebhookConfig{Template: `{"text": {{json .Program}}}`},
+			event: Event{Program: `say "hi"`},
+			want:  `{"text": "say \"hi\""}`,
+		},
+		{name: "template syntax error", hook: config.WebhookConfig{Template: `{{.Program`}, event: end, wantErr: true},
+		{name: "template unknown field", hook: config.WebhookConfig{Template: `{{.Missing}}`}, event: end, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := Body(tc.hook, tc.event)
+			if tc.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, string(body))
+		})
+	}
+}
@@ -31,31 +31,10 @@ GROUP_NAME=$(id -gn)
 
 sudo mkdir -p /var/run/timekeep
 sudo chown "$USER_NAME":"$GROUP_NAME" /var/run/timekeep
-sudo chmod 755 /var/run/timekeep
-
-sudo tee /etc/systemd/system/timekeep.service > /dev/null <<EOF
-[Unit]
-Description=TimeKeep Process Tracker
-After=network.target
-
-[Service]
-Type=simple
-ExecStart=/usr/local/bin/timekeepd
-StandardOutput=journal
-StandardError=journal
-KillMode=process
-Restart=always
-RestartSec=2s
-User=$USER_NAME
-Group=$GROUP_NAME
-
-[Install]
-WantedBy=multi-user.target
-EOF
-
-sudo systemctl daemon-reload
-sudo systemctl enable timekeep.service
-sudo systemctl start timekeep.service
+sudo chmod 1777 /var/run/timekeep
+
+sudo timekeep service install --system --bin /usr/local/bin/timekeepd
+sudo timekeep service start
 
 timekeep completion bash | sudo tee /etc/bash_completion.d/timekeep >/dev/null
 source /etc/bash_completion
@@ -6,10 +6,11 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 
-	"github.com/jms-guy/timekeep/internal/database"
 	"github.com/pressly/goose/v3"
 )
 
@@ -17,45 +18,83 @@ import (
 var embedMigrations embed.FS
 
 // Open database connection with embedded migrations
-func OpenLocalDatabase() (*database.Queries, error) {
+func OpenLocalDatabase() (*sql.DB, error) {
+	db, _, err := openLocalDatabase()
+	return db, err
+}
+
+// Opens the local database and applies pending migrations, returning the upgrade made if the database was at an
+// older schema version
+func openLocalDatabase() (*sql.DB, *Upgrade, error) {
 	dbPath, err := getDatabasePath()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// #nosec G301
 	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
-		return nil, fmt.Errorf("failed to create database directory: %w", err)
+		return nil, nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
 	db, err := sql.Open("sqlite", dbPath+"?_busy_timeout=5000&_journal_mode=WAL")
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	goose.SetBaseFS(embedMigrations)
 	goose.SetLogger(log.New(io.Discard, "", 0))
 
 	if err = goose.SetDialect("sqlite"); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	if err = goose.Up(db, "schema"); err != nil {
+	upgrade, err := migrate(db)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return db, upgrade, nil
+}
+
+// Opens the local database read-only, for queries the user writes. The file is opened in read-only mode and the
+// connection set to refuse writes, so no statement can change it. Migrations aren't applied
+func OpenReadOnlyDatabase() (*sql.DB, error) {
+	dbPath, err := getDatabasePath()
+	if err != nil {
 		return nil, err
 	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
 
-	queries := database.New(db)
+	path := filepath.ToSlash(dbPath)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // Windows drive paths, file:///C:/...
+	}
+	uri := (&url.URL{Scheme: "file", Path: path}).String()
 
-	return queries, nil
+	db, err := sql.Open("sqlite", uri+"?mode=ro&_pragma=query_only(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	return db, nil
 }
 
 // Opens functional in-memory testing database
-func OpenTestDatabase() (*database.Queries, error) {
+func OpenTestDatabase() (*sql.DB, error) {
 	db, err := sql.Open("sqlite", ":memory:")
 	if err != nil {
 		return nil, err
 	}
 
+	// Each connection to :memory: is a separate database, pin the pool to one so transactions see the schema
+	db.SetMaxOpenConns(1)
+
 	goose.SetBaseFS(embedMigrations)
 	goose.SetLogger(log.New(io.Discard, "", 0))
 
@@ -67,7 +106,5 @@ func OpenTestDatabase() (*database.Queries, error) {
 		return nil, err
 	}
 
-	queries := database.New(db)
-
-	return queries, nil
+	return db, nil
 }
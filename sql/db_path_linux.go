@@ -5,6 +5,8 @@ package sql
 import (
 	"os"
 	"path/filepath"
+
+	"github.com/jms-guy/timekeep/internal/profile"
 )
 
 // Gets database directory path for Linux
@@ -13,7 +15,7 @@ func getDatabasePath() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	dbPath := filepath.Join(home, ".local", "share", "timekeep", "timekeep.db")
+	dbPath := filepath.Join(home, ".local", "share", profile.Suffixed("timekeep"), "timekeep.db")
 
 	return dbPath, nil
 }
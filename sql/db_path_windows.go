@@ -3,10 +3,12 @@ package sql
 
 import (
 	"path/filepath"
+
+	"github.com/jms-guy/timekeep/internal/profile"
 )
 
 // Gets database directory path for Windows
 func getDatabasePath() (string, error) {
-	dataDir := `C:\ProgramData\TimeKeep`
+	dataDir := profile.DataDir()
 	return filepath.Join(dataDir, "timekeep.db"), nil
 }
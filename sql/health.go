@@ -0,0 +1,55 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/pressly/goose/v3"
+)
+
+// State of the local database file, as reported by diagnostics
+type Health struct {
+	Path          string // Location of the database file
+	Problem       string // Integrity check findings, empty when the database is sound
+	Version       int64  // Newest schema migration applied to the database
+	LatestVersion int64  // Newest schema migration embedded in this binary
+}
+
+// Location of the local database file
+func DatabasePath() (string, error) {
+	return getDatabasePath()
+}
+
+// Checks the integrity and schema version of the local database through db, an open connection to it
+func CheckLocalDatabase(db *sql.DB) (Health, error) {
+	path, err := getDatabasePath()
+	if err != nil {
+		return Health{}, err
+	}
+	health := Health{Path: path, Problem: integrityProblem(path)}
+
+	goose.SetBaseFS(embedMigrations)
+	goose.SetLogger(log.New(io.Discard, "", 0))
+	if err := goose.SetDialect("sqlite"); err != nil {
+		return health, err
+	}
+
+	health.Version, err = goose.GetDBVersion(db)
+	if err != nil {
+		return health, fmt.Errorf("error reading schema version: %w", err)
+	}
+
+	migrations, err := goose.CollectMigrations("schema", 0, goose.MaxVersion)
+	if err != nil {
+		return health, fmt.Errorf("error reading embedded migrations: %w", err)
+	}
+	last, err := migrations.Last()
+	if err != nil {
+		return health, fmt.Errorf("error reading embedded migrations: %w", err)
+	}
+	health.LatestVersion = last.Version
+
+	return health, nil
+}
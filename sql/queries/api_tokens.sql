@@ -0,0 +1,20 @@
+-- name: CreateAPIToken :exec
+INSERT INTO api_tokens (name, scope, token_hash, created_at)
+VALUES (?, ?, ?, ?);
+
+-- name: GetAllAPITokens :many
+SELECT * FROM api_tokens
+ORDER BY name;
+
+-- name: GetAPITokenByHash :one
+SELECT * FROM api_tokens
+WHERE token_hash = ?;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = ?
+WHERE id = ?;
+
+-- name: RemoveAPIToken :execrows
+DELETE FROM api_tokens
+WHERE name = ?;
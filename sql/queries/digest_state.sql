@@ -0,0 +1,7 @@
+-- name: GetDigestState :one
+SELECT * FROM digest_state
+WHERE id = 1;
+
+-- name: SaveDigestState :exec
+INSERT OR REPLACE INTO digest_state (id, last_week, sent_at)
+VALUES (1, ?, ?);
@@ -0,0 +1,16 @@
+-- name: GetExportState :one
+SELECT * FROM export_state
+WHERE target = ?;
+
+-- name: SaveExportState :exec
+INSERT OR REPLACE INTO export_state (target, pushed_through, pushed_at)
+VALUES (?, ?, ?);
+
+-- name: GetSessionsToExport :many
+SELECT h.id, h.program_name, h.start_time, h.end_time, h.duration_seconds, p.category, p.project
+FROM session_history h
+LEFT JOIN tracked_programs p ON p.name = h.program_name
+WHERE h.id > sqlc.arg('after_id') AND h.start_time >= sqlc.arg('since')
+  AND IFNULL(h.device, '') IN ('', sqlc.arg('device'))
+ORDER BY h.id
+LIMIT sqlc.arg('max');
@@ -0,0 +1,10 @@
+-- name: AddHarvestEntry :exec
+INSERT OR IGNORE INTO harvest_log (key, entry_id, spent_date, pushed_at)
+VALUES (?, ?, ?, ?);
+
+-- name: GetHarvestKeysSince :many
+SELECT key FROM harvest_log
+WHERE spent_date >= ?;
+
+-- name: GetLastHarvestDay :one
+SELECT CAST(IFNULL(MAX(spent_date), '') AS TEXT) AS spent_date FROM harvest_log;
@@ -0,0 +1,10 @@
+-- name: GetIncognito :one
+SELECT * FROM incognito
+WHERE id = 1;
+
+-- name: SaveIncognito :exec
+INSERT OR REPLACE INTO incognito (id, until)
+VALUES (1, ?);
+
+-- name: ClearIncognito :exec
+DELETE FROM incognito;
@@ -0,0 +1,20 @@
+-- name: AddGroupMember :exec
+INSERT OR IGNORE INTO program_groups (group_name, program_name)
+VALUES (?, ?);
+
+-- name: GetAllGroupMembers :many
+SELECT * FROM program_groups
+ORDER BY group_name, program_name;
+
+-- name: GetGroupMembers :many
+SELECT program_name FROM program_groups
+WHERE group_name = ?
+ORDER BY program_name;
+
+-- name: RemoveGroup :execrows
+DELETE FROM program_groups
+WHERE group_name = ?;
+
+-- name: RemoveGroupMember :execrows
+DELETE FROM program_groups
+WHERE group_name = ? AND program_name = ?;
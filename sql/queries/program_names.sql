@@ -0,0 +1,23 @@
+-- name: RenameProgram :exec
+UPDATE tracked_programs SET name = sqlc.arg(new_name)
+WHERE name = sqlc.arg(old_name);
+
+-- name: RenameActiveSession :exec
+UPDATE active_sessions SET program_name = sqlc.arg(new_name)
+WHERE program_name = sqlc.arg(old_name);
+
+-- name: RenameSessionHistory :exec
+UPDATE session_history SET program_name = sqlc.arg(new_name)
+WHERE program_name = sqlc.arg(old_name);
+
+-- name: RenameSessionArchive :exec
+UPDATE session_archive SET program_name = sqlc.arg(new_name)
+WHERE program_name = sqlc.arg(old_name);
+
+-- name: RenameSyncedSessions :exec
+UPDATE sync_log SET program_name = sqlc.arg(new_name)
+WHERE program_name = sqlc.arg(old_name);
+
+-- name: RenameGroupMember :exec
+UPDATE OR IGNORE program_groups SET program_name = sqlc.arg(new_name)
+WHERE program_name = sqlc.arg(old_name);
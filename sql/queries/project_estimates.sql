@@ -0,0 +1,15 @@
+-- name: SetProjectEstimate :exec
+INSERT OR REPLACE INTO project_estimates (project, estimate_seconds, set_at)
+VALUES (?, ?, ?);
+
+-- name: GetProjectEstimate :one
+SELECT * FROM project_estimates
+WHERE project = ?;
+
+-- name: GetAllProjectEstimates :many
+SELECT * FROM project_estimates
+ORDER BY project;
+
+-- name: RemoveProjectEstimate :execrows
+DELETE FROM project_estimates
+WHERE project = ?;
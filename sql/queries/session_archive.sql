@@ -0,0 +1,24 @@
+-- name: ArchiveSessionsBefore :execrows
+INSERT INTO session_archive (program_name, start_time, end_time, duration_seconds, metadata, device, archived_at)
+SELECT program_name, start_time, end_time, duration_seconds, metadata, device, sqlc.arg('archived_at')
+FROM session_history
+WHERE end_time < sqlc.arg('cutoff');
+
+-- name: RemoveSessionsBefore :exec
+DELETE FROM session_history
+WHERE end_time < ?;
+
+-- name: GetArchivedSessions :many
+SELECT * FROM session_archive
+WHERE IFNULL(sqlc.narg('program_name'), '') IN ('', program_name)
+  AND start_time <= sqlc.arg('range_end') AND end_time >= sqlc.arg('range_start')
+  AND IFNULL(sqlc.narg('device'), '') IN ('', device)
+ORDER BY start_time DESC
+LIMIT sqlc.arg('limit');
+
+-- name: RemoveAllArchivedRecords :exec
+DELETE FROM session_archive;
+
+-- name: RemoveArchivedRecordsForProgram :exec
+DELETE FROM session_archive
+WHERE session_archive.program_name = ?;
\ No newline at end of file
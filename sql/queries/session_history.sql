@@ -1,6 +1,6 @@
 -- name: AddToSessionHistory :exec
-INSERT INTO session_history (program_name, start_time, end_time, duration_seconds)
-VALUES (?, ?, ?, ?);
+INSERT INTO session_history (program_name, start_time, end_time, duration_seconds, metadata, device)
+VALUES (?, ?, ?, ?, ?, ?);
 
 -- name: GetLastSessionForProgram :one 
 SELECT * FROM session_history
@@ -19,10 +19,19 @@ DELETE FROM session_history;
 DELETE FROM session_history
 WHERE session_history.program_name = ?;
 
+-- name: GetRecentSessionsPerProgram :many
+SELECT id, program_name, start_time, end_time, duration_seconds, metadata, device FROM (
+    SELECT *, ROW_NUMBER() OVER (PARTITION BY program_name ORDER BY end_time DESC) AS recency
+    FROM session_history
+) AS ranked
+WHERE recency <= sqlc.arg('per_program')
+ORDER BY program_name, end_time ASC;
+
 -- name: GetSessionHistory :many
 SELECT * FROM (
     SELECT * FROM session_history
     WHERE program_name = ?
+      AND IFNULL(sqlc.narg('device'), '') IN ('', device)
     ORDER BY end_time DESC
     LIMIT ?
 ) AS results
@@ -31,8 +40,9 @@ ORDER BY end_time ASC;
 -- name: GetSessionHistoryByDate :many
 SELECT * FROM (
     SELECT * FROM session_history
-    WHERE program_name = ? 
+    WHERE program_name = ?
       AND start_time <= ? AND end_time >= ?
+      AND IFNULL(sqlc.narg('device'), '') IN ('', device)
     ORDER BY start_time DESC
     LIMIT ?
 ) AS results
@@ -43,6 +53,7 @@ SELECT * FROM (
     SELECT * FROM session_history
     WHERE program_name = ?
       AND start_time <= ? AND end_time >= ?
+      AND IFNULL(sqlc.narg('device'), '') IN ('', device)
     ORDER BY start_time DESC
     LIMIT ?
 ) AS results
@@ -51,6 +62,7 @@ ORDER BY start_time ASC;
 -- name: GetAllSessionHistory :many
 SELECT * FROM (
     SELECT * FROM session_history
+    WHERE IFNULL(sqlc.narg('device'), '') IN ('', device)
     ORDER BY end_time DESC
     LIMIT ?
 ) AS results
@@ -60,6 +72,7 @@ ORDER BY end_time ASC;
 SELECT * FROM (
     SELECT * FROM session_history
     WHERE start_time <= ? AND end_time >= ?
+      AND IFNULL(sqlc.narg('device'), '') IN ('', device)
     ORDER BY start_time DESC
     LIMIT ?
 ) AS results
@@ -69,7 +82,17 @@ ORDER BY start_time ASC;
 SELECT * FROM (
     SELECT * FROM session_history
     WHERE start_time <= ? AND end_time >= ?
+      AND IFNULL(sqlc.narg('device'), '') IN ('', device)
     ORDER BY start_time DESC
     LIMIT ?
 ) AS results
-ORDER BY start_time ASC;
\ No newline at end of file
+ORDER BY start_time ASC;
+
+-- name: GetSessionMetadata :one
+SELECT metadata FROM session_history
+WHERE id = ?;
+
+-- name: UpdateSessionMetadata :exec
+UPDATE session_history
+SET metadata = ?
+WHERE id = ?;
\ No newline at end of file
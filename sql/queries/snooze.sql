@@ -0,0 +1,10 @@
+-- name: GetSnooze :one
+SELECT * FROM snooze
+WHERE id = 1;
+
+-- name: SaveSnooze :exec
+INSERT OR REPLACE INTO snooze (id, resume_at)
+VALUES (1, ?);
+
+-- name: ClearSnooze :exec
+DELETE FROM snooze;
@@ -0,0 +1,7 @@
+-- name: GetStreaks :many
+SELECT * FROM streaks
+ORDER BY kind, name;
+
+-- name: SaveStreak :exec
+INSERT OR REPLACE INTO streaks (kind, name, current, best, through)
+VALUES (?, ?, ?, ?, ?);
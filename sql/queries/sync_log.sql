@@ -0,0 +1,26 @@
+-- name: AddSyncedSession :execrows
+INSERT OR IGNORE INTO sync_log (sync_id, device, device_id, program_name, category, project, start_time, end_time,
+    duration_seconds, metadata, received_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetSyncedSessionsAfter :many
+SELECT * FROM sync_log
+WHERE seq > sqlc.arg('after') AND device_id != sqlc.arg('exclude_device_id')
+ORDER BY seq
+LIMIT sqlc.arg('max');
+
+-- name: GetSyncState :one
+SELECT * FROM sync_state
+WHERE id = 1;
+
+-- name: SaveSyncState :exec
+INSERT OR REPLACE INTO sync_state (id, device_id, pushed_through, pull_cursor, last_sync)
+VALUES (1, ?, ?, ?, ?);
+
+-- name: GetSessionsToPush :many
+SELECT h.id, h.program_name, h.start_time, h.end_time, h.duration_seconds, h.metadata, p.category, p.project
+FROM session_history h
+LEFT JOIN tracked_programs p ON p.name = h.program_name
+WHERE h.id > sqlc.arg('after_id') AND IFNULL(h.device, '') IN ('', sqlc.arg('device'))
+ORDER BY h.id
+LIMIT sqlc.arg('max');
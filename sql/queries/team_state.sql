@@ -0,0 +1,7 @@
+-- name: GetTeamState :one
+SELECT * FROM team_state
+WHERE id = 1;
+
+-- name: SaveTeamState :exec
+INSERT OR REPLACE INTO team_state (id, last_day, reported_at)
+VALUES (1, ?, ?);
@@ -9,8 +9,13 @@ SELECT name FROM tracked_programs;
 SELECT * FROM tracked_programs;
 
 -- name: AddProgram :exec
-INSERT OR IGNORE INTO tracked_programs (name, category, project)
-VALUES (?, ?, ?);
+INSERT INTO tracked_programs (name, category, project)
+VALUES (?, ?, ?)
+ON CONFLICT (name) DO UPDATE SET remote = FALSE;
+
+-- name: AddRemoteProgram :exec
+INSERT OR IGNORE INTO tracked_programs (name, category, project, remote)
+VALUES (?, ?, ?, TRUE);
 
 -- name: RemoveProgram :exec
 DELETE FROM tracked_programs
@@ -18,7 +23,7 @@ WHERE name = ?;
 
 -- name: UpdateLifetime :exec
 UPDATE tracked_programs
-SET lifetime_seconds = lifetime_seconds + ?
+SET lifetime_seconds = lifetime_seconds + ?, session_count = session_count + 1
 WHERE name = ?;
 
 -- name: RemoveAllPrograms :exec
@@ -26,12 +31,12 @@ DELETE FROM tracked_programs;
 
 -- name: ResetLifetimeForProgram :exec
 UPDATE tracked_programs 
-SET lifetime_seconds = 0 
+SET lifetime_seconds = 0, session_count = 0
 WHERE name = ?;
 
 -- name: ResetAllLifetimes :exec
 UPDATE tracked_programs 
-SET lifetime_seconds = 0;
+SET lifetime_seconds = 0, session_count = 0;
 
 -- name: UpdateCategory :exec 
 UPDATE tracked_programs
@@ -41,4 +46,37 @@ WHERE name = ?;
 -- name: UpdateProject :exec
 UPDATE tracked_programs
 SET project = ?
-WHERE name = ?;
\ No newline at end of file
+WHERE name = ?;
+
+-- name: RecalculateAllLifetimes :exec
+UPDATE tracked_programs
+SET lifetime_seconds = (
+    SELECT COALESCE(SUM(duration_seconds), 0) FROM session_history
+    WHERE session_history.program_name = tracked_programs.name AND IFNULL(session_history.device, '') IN ('', sqlc.arg('device'))
+) + (
+    SELECT COALESCE(SUM(duration_seconds), 0) FROM session_archive
+    WHERE session_archive.program_name = tracked_programs.name AND IFNULL(session_archive.device, '') IN ('', sqlc.arg('device'))
+), session_count = (
+    SELECT COUNT(*) FROM session_history
+    WHERE session_history.program_name = tracked_programs.name AND IFNULL(session_history.device, '') IN ('', sqlc.arg('device'))
+) + (
+    SELECT COUNT(*) FROM session_archive
+    WHERE session_archive.program_name = tracked_programs.name AND IFNULL(session_archive.device, '') IN ('', sqlc.arg('device'))
+);
+
+-- name: RecalculateLifetimeForProgram :exec
+UPDATE tracked_programs
+SET lifetime_seconds = (
+    SELECT COALESCE(SUM(duration_seconds), 0) FROM session_history
+    WHERE session_history.program_name = tracked_programs.name AND IFNULL(session_history.device, '') IN ('', sqlc.arg('device'))
+) + (
+    SELECT COALESCE(SUM(duration_seconds), 0) FROM session_archive
+    WHERE session_archive.program_name = tracked_programs.name AND IFNULL(session_archive.device, '') IN ('', sqlc.arg('device'))
+), session_count = (
+    SELECT COUNT(*) FROM session_history
+    WHERE session_history.program_name = tracked_programs.name AND IFNULL(session_history.device, '') IN ('', sqlc.arg('device'))
+) + (
+    SELECT COUNT(*) FROM session_archive
+    WHERE session_archive.program_name = tracked_programs.name AND IFNULL(session_archive.device, '') IN ('', sqlc.arg('device'))
+)
+WHERE name = sqlc.arg('name');
\ No newline at end of file
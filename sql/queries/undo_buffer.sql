@@ -0,0 +1,63 @@
+-- name: SaveUndoOperation :exec
+INSERT OR REPLACE INTO undo_operation (id, operation, created_at)
+VALUES (1, ?, ?);
+
+-- name: GetUndoOperation :one
+SELECT * FROM undo_operation
+WHERE id = 1;
+
+-- name: ClearUndoOperation :exec
+DELETE FROM undo_operation;
+
+-- name: ClearUndoRows :exec
+DELETE FROM undo_rows;
+
+-- name: SnapshotPrograms :exec
+INSERT INTO undo_rows (table_name, data)
+SELECT 'tracked_programs', json_object('name', name, 'lifetime_seconds', lifetime_seconds, 'category', category, 'project', project, 'remote', remote,
+    'session_count', session_count)
+FROM tracked_programs
+WHERE IFNULL(sqlc.narg('name'), '') IN ('', name);
+
+-- name: SnapshotSessionHistory :exec
+INSERT INTO undo_rows (table_name, data)
+SELECT 'session_history', json_object('program_name', program_name, 'start_time', start_time, 'end_time', end_time,
+    'duration_seconds', duration_seconds, 'metadata', metadata, 'device', device)
+FROM session_history
+WHERE IFNULL(sqlc.narg('program_name'), '') IN ('', program_name);
+
+-- name: SnapshotSessionArchive :exec
+INSERT INTO undo_rows (table_name, data)
+SELECT 'session_archive', json_object('program_name', program_name, 'start_time', start_time, 'end_time', end_time,
+    'duration_seconds', duration_seconds, 'metadata', metadata, 'device', device, 'archived_at', archived_at)
+FROM session_archive
+WHERE IFNULL(sqlc.narg('program_name'), '') IN ('', program_name);
+
+-- name: RestorePrograms :execrows
+INSERT INTO tracked_programs (name, lifetime_seconds, category, project, remote, session_count)
+SELECT json_extract(data, '$.name'), json_extract(data, '$.lifetime_seconds'),
+    json_extract(data, '$.category'), json_extract(data, '$.project'), IFNULL(json_extract(data, '$.remote'), FALSE),
+    IFNULL(json_extract(data, '$.session_count'), 0)
+FROM undo_rows
+WHERE table_name = 'tracked_programs'
+ON CONFLICT (name) DO UPDATE SET
+    lifetime_seconds = tracked_programs.lifetime_seconds + excluded.lifetime_seconds,
+    session_count = tracked_programs.session_count + excluded.session_count,
+    category = IFNULL(tracked_programs.category, excluded.category),
+    project = IFNULL(tracked_programs.project, excluded.project),
+    remote = tracked_programs.remote AND excluded.remote;
+
+-- name: RestoreSessionHistory :execrows
+INSERT INTO session_history (program_name, start_time, end_time, duration_seconds, metadata, device)
+SELECT json_extract(data, '$.program_name'), json_extract(data, '$.start_time'), json_extract(data, '$.end_time'),
+    json_extract(data, '$.duration_seconds'), json_extract(data, '$.metadata'), json_extract(data, '$.device')
+FROM undo_rows
+WHERE table_name = 'session_history';
+
+-- name: RestoreSessionArchive :execrows
+INSERT INTO session_archive (program_name, start_time, end_time, duration_seconds, metadata, device, archived_at)
+SELECT json_extract(data, '$.program_name'), json_extract(data, '$.start_time'), json_extract(data, '$.end_time'),
+    json_extract(data, '$.duration_seconds'), json_extract(data, '$.metadata'), json_extract(data, '$.device'),
+    json_extract(data, '$.archived_at')
+FROM undo_rows
+WHERE table_name = 'session_archive';
@@ -0,0 +1,393 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jms-guy/timekeep/internal/database"
+	"github.com/pressly/goose/v3"
+)
+
+// Outcome of salvaging a corrupt database on startup
+type Recovery struct {
+	Problem     string            // Why the database was judged corrupt
+	Quarantined string            // Path the corrupt database was moved to
+	Salvaged    map[string]int64  // Rows copied into the new database, by table
+	Failed      map[string]string // Tables not fully read, with the last error
+}
+
+const (
+	salvageBatch       = 500  // Rows copied per read of the corrupt database
+	maxSalvageFailures = 1000 // Read errors tolerated per table before the rest of it is given up
+	maxProblems        = 5    // Integrity check messages kept in Recovery.Problem
+)
+
+// SQLite result codes for a damaged database file
+const (
+	sqliteCorrupt = 11 // SQLITE_CORRUPT
+	sqliteNotADB  = 26 // SQLITE_NOTADB
+)
+
+// Opens the local database like OpenLocalDatabase, checking its integrity first. A corrupt database is salvaged:
+// readable rows are copied into a new database, the corrupt files are moved aside, and the new database takes their
+// place, with program lifetimes and session counts recounted for device from the sessions that survived. The returned
+// Recovery is non-nil when that happened, and the Upgrade when migrations were applied
+func OpenLocalDatabaseRecovering(device string) (*sql.DB, *Recovery, *Upgrade, error) {
+	dbPath, err := getDatabasePath()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var rec *Recovery
+	if problem := integrityProblem(dbPath); problem != "" {
+		rec, err = recoverDatabase(dbPath, problem)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("database at %s is corrupt (%s) and could not be recovered: %w", dbPath, problem, err)
+		}
+	}
+
+	db, upgrade, err := openLocalDatabase()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Totals were copied as they stood before the loss, and would count sessions no longer there
+	if rec != nil {
+		if err := database.New(db).RecalculateAllLifetimes(context.Background(), device); err != nil {
+			db.Close()
+			return nil, nil, nil, fmt.Errorf("error recounting totals of the recovered database: %w", err)
+		}
+	}
+	return db, rec, upgrade, nil
+}
+
+// Runs a quick integrity check on the database at path, describing any corruption found. A missing database, or one
+// that can't be checked for other reasons, such as being locked, isn't reported
+func integrityProblem(path string) string {
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return ""
+	}
+	defer db.Close()
+
+	rows, err := db.Query("PRAGMA quick_check")
+	if err != nil {
+		if isCorrupt(err) {
+			return err.Error()
+		}
+		return ""
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err == nil && result != "ok" {
+			problems = append(problems, strings.Split(result, "\n")...)
+		}
+	}
+	if err := rows.Err(); err != nil && isCorrupt(err) {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > maxProblems {
+		problems = append(problems[:maxProblems], fmt.Sprintf("and %d more", len(problems)-maxProblems))
+	}
+	return strings.Join(problems, "; ")
+}
+
+// Reports whether err is SQLite reporting a damaged database file
+func isCorrupt(err error) bool {
+	var coded interface{ Code() int }
+	if errors.As(err, &coded) {
+		code := coded.Code() & 0xff // Extended codes carry the primary code in the low byte
+		return code == sqliteCorrupt || code == sqliteNotADB
+	}
+	return false
+}
+
+// Copies what can still be read from the corrupt database at dbPath into a new database built to the same schema
+// version, then swaps the new database in and keeps the corrupt files under a timestamped name
+func recoverDatabase(dbPath, problem string) (*Recovery, error) {
+	rec := &Recovery{Problem: problem, Salvaged: make(map[string]int64), Failed: make(map[string]string)}
+
+	newPath := dbPath + ".recovering"
+	if err := moveDatabaseFiles(newPath, ""); err != nil {
+		return nil, err
+	}
+
+	corrupt, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer corrupt.Close()
+
+	fresh, err := sql.Open("sqlite", newPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fresh.Close()
+
+	goose.SetBaseFS(embedMigrations)
+	goose.SetLogger(log.New(io.Discard, "", 0))
+
+	if err = goose.SetDialect("sqlite"); err != nil {
+		return nil, err
+	}
+
+	// Rows are copied at the corrupt database's schema version, so later migrations still apply to them
+	version, err := goose.GetDBVersion(corrupt)
+	if err != nil || version <= 0 {
+		err = goose.Up(fresh, "schema")
+	} else {
+		err = goose.UpTo(fresh, "schema", version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating recovery database: %w", err)
+	}
+
+	tables, err := salvageTables(fresh)
+	if err != nil {
+		return nil, fmt.Errorf("error listing tables to recover: %w", err)
+	}
+	for _, table := range tables {
+		copied, err := salvageTable(corrupt, fresh, table)
+		rec.Salvaged[table] = copied
+		if err != nil {
+			rec.Failed[table] = err.Error()
+		}
+	}
+
+	corrupt.Close()
+	fresh.Close()
+
+	rec.Quarantined = dbPath + ".corrupt-" + time.Now().UTC().Format("20060102T150405Z")
+	if err := moveDatabaseFiles(dbPath, rec.Quarantined); err != nil {
+		return nil, fmt.Errorf("error quarantining corrupt database: %w", err)
+	}
+	if err := moveDatabaseFiles(newPath, dbPath); err != nil {
+		return nil, fmt.Errorf("error replacing corrupt database: %w", err)
+	}
+
+	return rec, nil
+}
+
+// Lists the tables to salvage, parents before the tables referencing them. Read from the new database, built to the
+// corrupt one's schema version, since the corrupt file's own schema may be unreadable
+func salvageTables(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name != ? ORDER BY name", goose.TableName())
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	parents := make(map[string][]string, len(names))
+	for _, name := range names {
+		refs, err := db.Query(`SELECT DISTINCT "table" FROM pragma_foreign_key_list(?)`, name)
+		if err != nil {
+			return nil, err
+		}
+		for refs.Next() {
+			var parent string
+			if err := refs.Scan(&parent); err != nil {
+				refs.Close()
+				return nil, err
+			}
+			parents[name] = append(parents[name], parent)
+		}
+		refs.Close()
+		if err := refs.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var ordered []string
+	visited := make(map[string]bool, len(names))
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, parent := range parents[name] {
+			if slices.Contains(names, parent) {
+				visit(parent)
+			}
+		}
+		ordered = append(ordered, name)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return ordered, nil
+}
+
+// Copies the readable rows of table from src to dst in rowid order. Unreadable stretches are skipped with a growing
+// stride, so a damaged page costs a few failed reads rather than one per row. Columns missing from either side are
+// left out, and rows clashing with ones already copied are dropped
+func salvageTable(src, dst *sql.DB, table string) (int64, error) {
+	columns, err := commonColumns(src, dst, table)
+	if err != nil {
+		return 0, err
+	}
+	if len(columns) == 0 {
+		return 0, nil
+	}
+
+	list := strings.Join(columns, ", ")
+	query := fmt.Sprintf("SELECT rowid, %s FROM %s WHERE rowid > ? ORDER BY rowid LIMIT %d", list, table, salvageBatch)
+	insert := fmt.Sprintf("INSERT OR IGNORE INTO %s (%s) VALUES (%s)", table, list, strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "))
+
+	var copied, after int64
+	var skip int64 = 1
+	var lastErr error
+	for failures := 0; failures < maxSalvageFailures; {
+		read, n, last, err := copyBatch(src, dst, query, insert, len(columns), after)
+		copied += n
+		if err != nil {
+			lastErr = err
+			failures++
+			after = max(after, last) + skip
+			skip *= 2
+			continue
+		}
+		if read == 0 {
+			if lastErr != nil {
+				return copied, fmt.Errorf("skipped unreadable rows: %w", lastErr)
+			}
+			return copied, nil
+		}
+		after, skip = last, 1
+	}
+
+	return copied, fmt.Errorf("gave up after %d read errors: %w", maxSalvageFailures, lastErr)
+}
+
+// Copies up to one batch of rows with rowid greater than after, returning the rows read and copied and the last rowid
+// read
+func copyBatch(src, dst *sql.DB, query, insert string, width int, after int64) (read, copied, last int64, err error) {
+	rows, err := src.Query(query, after)
+	if err != nil {
+		return 0, 0, after, err
+	}
+	defer rows.Close()
+
+	tx, err := dst.Begin()
+	if err != nil {
+		return 0, 0, after, err
+	}
+	defer tx.Rollback()
+
+	last = after
+	var readErr error
+	for rows.Next() {
+		var rowid int64
+		values := make([]any, width)
+		dest := []any{&rowid}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if readErr = rows.Scan(dest...); readErr != nil {
+			break
+		}
+		read++
+		last = rowid
+
+		if _, err := tx.Exec(insert, values...); err == nil {
+			copied++
+		}
+	}
+	if readErr == nil {
+		readErr = rows.Err()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return read, 0, last, err
+	}
+	return read, copied, last, readErr
+}
+
+// Returns the columns of table present in both databases, in dst's order
+func commonColumns(src, dst *sql.DB, table string) ([]string, error) {
+	have, err := tableColumns(src, table)
+	if err != nil {
+		return nil, err
+	}
+	want, err := tableColumns(dst, table)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool, len(have))
+	for _, c := range have {
+		present[c] = true
+	}
+
+	var columns []string
+	for _, c := range want {
+		if present[c] {
+			columns = append(columns, c)
+		}
+	}
+	return columns, nil
+}
+
+// Lists table's column names
+func tableColumns(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// Moves a database file and its WAL and shared memory files from src to dst. An empty dst deletes them
+func moveDatabaseFiles(src, dst string) error {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		var err error
+		if dst == "" {
+			err = os.Remove(src + suffix)
+		} else {
+			err = os.Rename(src+suffix, dst+suffix)
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error moving %s: %w", filepath.Base(src+suffix), err)
+		}
+	}
+	return nil
+}
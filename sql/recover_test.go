@@ -0,0 +1,82 @@
+//go:build linux
+
+package sql
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestRecoverCorruptDatabase(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dbPath, err := getDatabasePath()
+	if err != nil {
+		t.Fatalf("Failed to get database path: %v", err)
+	}
+
+	db, err := OpenLocalDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	const sessions = 3000
+	_, err = db.Exec("INSERT INTO tracked_programs (name, lifetime_seconds, session_count) VALUES ('code', ?, ?)", sessions*60, sessions)
+	assert.Nil(t, err)
+	start := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	for i := range sessions {
+		at := start.Add(time.Duration(i) * time.Hour)
+		_, err := db.Exec("INSERT INTO session_history (program_name, start_time, end_time, duration_seconds, metadata) VALUES ('code', ?, ?, 60, ?)",
+			at, at.Add(time.Minute), fmt.Sprintf(`{"note": "session %d of a history long enough to fill many pages"}`, i))
+		assert.Nil(t, err)
+	}
+	_, err = db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	assert.Nil(t, err)
+	db.Close()
+
+	// Overwrite a stretch of pages towards the end of the file, where the later sessions are
+	file, err := os.OpenFile(dbPath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("Failed to open database file: %v", err)
+	}
+	info, err := file.Stat()
+	assert.Nil(t, err)
+	garbage := make([]byte, 3*4096)
+	for i := range garbage {
+		garbage[i] = 0xa5
+	}
+	_, err = file.WriteAt(garbage, (info.Size()*3/4)/4096*4096)
+	assert.Nil(t, err)
+	file.Close()
+
+	db, rec, _, err := OpenLocalDatabaseRecovering("")
+	if err != nil {
+		t.Fatalf("Failed to recover database: %v", err)
+	}
+	defer db.Close()
+	if !assert.NotNil(t, rec, "The damage should be detected") {
+		return
+	}
+	assert.NotEmpty(t, rec.Problem)
+	_, err = os.Stat(rec.Quarantined)
+	assert.Nil(t, err, "The corrupt file should be kept aside")
+
+	salvaged := rec.Salvaged["session_history"]
+	assert.Greater(t, salvaged, int64(0))
+	assert.Less(t, salvaged, int64(sessions), "Some sessions should have been lost")
+	assert.Equal(t, int64(1), rec.Salvaged["tracked_programs"])
+
+	var count, total, lifetime, sessionCount int64
+	assert.Nil(t, db.QueryRow("SELECT COUNT(*), SUM(duration_seconds) FROM session_history").Scan(&count, &total))
+	assert.Equal(t, salvaged, count)
+	assert.Nil(t, db.QueryRow("SELECT lifetime_seconds, session_count FROM tracked_programs WHERE name = 'code'").Scan(&lifetime, &sessionCount))
+	assert.Equal(t, total, lifetime, "The lifetime should be recounted from the sessions that survived")
+	assert.Equal(t, count, sessionCount)
+
+	var check string
+	assert.Nil(t, db.QueryRow("PRAGMA quick_check").Scan(&check))
+	assert.Equal(t, "ok", check)
+}
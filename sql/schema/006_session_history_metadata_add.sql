@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE session_history
+ADD metadata TEXT;
+
+-- +goose Down
+ALTER TABLE session_history
+DROP COLUMN metadata;
\ No newline at end of file
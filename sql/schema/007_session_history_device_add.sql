@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE session_history
+ADD device TEXT;
+
+-- +goose Down
+ALTER TABLE session_history
+DROP COLUMN device;
\ No newline at end of file
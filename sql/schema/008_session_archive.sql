@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE session_archive (
+    id INTEGER PRIMARY KEY,
+    program_name TEXT NOT NULL REFERENCES tracked_programs(name)
+    ON DELETE CASCADE,
+    start_time DATETIME NOT NULL,
+    end_time DATETIME NOT NULL,
+    duration_seconds INTEGER NOT NULL,
+    metadata TEXT,
+    device TEXT,
+    archived_at DATETIME NOT NULL
+);
+
+-- +goose Down
+DROP TABLE session_archive;
\ No newline at end of file
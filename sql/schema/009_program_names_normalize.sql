@@ -0,0 +1,39 @@
+-- +goose Up
+-- Program names are stored without a .exe extension, so Windows and Linux record the same program under one name.
+-- Programs tracked under both forms are merged into the bare name
+UPDATE tracked_programs
+SET lifetime_seconds = lifetime_seconds + (
+        SELECT exe.lifetime_seconds FROM tracked_programs exe WHERE exe.name = tracked_programs.name || '.exe'
+    ),
+    category = coalesce(category, (
+        SELECT exe.category FROM tracked_programs exe WHERE exe.name = tracked_programs.name || '.exe'
+    )),
+    project = coalesce(project, (
+        SELECT exe.project FROM tracked_programs exe WHERE exe.name = tracked_programs.name || '.exe'
+    ))
+WHERE name || '.exe' IN (SELECT name FROM tracked_programs);
+
+INSERT INTO tracked_programs (name, lifetime_seconds, category, project)
+SELECT substr(name, 1, length(name) - 4), lifetime_seconds, category, project
+FROM tracked_programs
+WHERE name LIKE '%.exe'
+AND substr(name, 1, length(name) - 4) NOT IN (SELECT name FROM tracked_programs);
+
+DELETE FROM active_sessions
+WHERE program_name LIKE '%.exe'
+AND substr(program_name, 1, length(program_name) - 4) IN (SELECT program_name FROM active_sessions);
+
+UPDATE active_sessions SET program_name = substr(program_name, 1, length(program_name) - 4)
+WHERE program_name LIKE '%.exe';
+
+UPDATE session_history SET program_name = substr(program_name, 1, length(program_name) - 4)
+WHERE program_name LIKE '%.exe';
+
+UPDATE session_archive SET program_name = substr(program_name, 1, length(program_name) - 4)
+WHERE program_name LIKE '%.exe';
+
+DELETE FROM tracked_programs WHERE name LIKE '%.exe';
+
+-- +goose Down
+-- Stripped extensions can't be restored
+SELECT 1;
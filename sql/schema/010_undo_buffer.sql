@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE undo_operation (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    operation TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE undo_rows (
+    id INTEGER PRIMARY KEY,
+    table_name TEXT NOT NULL,
+    data TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE undo_rows;
+DROP TABLE undo_operation;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE upgrade_log (
+    id INTEGER PRIMARY KEY,
+    component TEXT NOT NULL,
+    from_version INTEGER NOT NULL,
+    to_version INTEGER NOT NULL,
+    upgraded_at DATETIME NOT NULL
+);
+
+-- +goose Down
+DROP TABLE upgrade_log;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE sync_log (
+    seq INTEGER PRIMARY KEY,
+    sync_id TEXT NOT NULL UNIQUE,
+    device TEXT NOT NULL,
+    program_name TEXT NOT NULL,
+    category TEXT,
+    project TEXT,
+    start_time DATETIME NOT NULL,
+    end_time DATETIME NOT NULL,
+    duration_seconds INTEGER NOT NULL,
+    metadata TEXT,
+    received_at DATETIME NOT NULL
+);
+
+-- +goose Down
+DROP TABLE sync_log;
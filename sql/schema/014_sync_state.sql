@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE sync_log ADD COLUMN device_id TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE sync_state (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    device_id TEXT NOT NULL,
+    pushed_through INTEGER NOT NULL,
+    pull_cursor INTEGER NOT NULL,
+    last_sync DATETIME
+);
+
+-- +goose Down
+DROP TABLE sync_state;
+ALTER TABLE sync_log DROP COLUMN device_id;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE team_state (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    last_day TEXT NOT NULL,
+    reported_at DATETIME NOT NULL
+);
+
+-- +goose Down
+DROP TABLE team_state;
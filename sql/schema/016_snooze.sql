@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE snooze (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    resume_at DATETIME NOT NULL
+);
+
+-- +goose Down
+DROP TABLE snooze;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE digest_state (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    last_week TEXT NOT NULL,
+    sent_at DATETIME NOT NULL
+);
+
+-- +goose Down
+DROP TABLE digest_state;
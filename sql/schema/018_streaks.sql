@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE streaks (
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    current INTEGER NOT NULL DEFAULT 0,
+    best INTEGER NOT NULL DEFAULT 0,
+    through TEXT NOT NULL,
+    PRIMARY KEY (kind, name)
+);
+
+-- +goose Down
+DROP TABLE streaks;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE export_state (
+    target TEXT PRIMARY KEY,
+    pushed_through INTEGER NOT NULL,
+    pushed_at DATETIME NOT NULL
+);
+
+-- +goose Down
+DROP TABLE export_state;
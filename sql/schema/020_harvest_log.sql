@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE harvest_log (
+    key TEXT PRIMARY KEY,
+    entry_id INTEGER NOT NULL,
+    spent_date TEXT NOT NULL,
+    pushed_at DATETIME NOT NULL
+);
+
+-- +goose Down
+DROP TABLE harvest_log;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE program_groups (
+    group_name TEXT NOT NULL,
+    program_name TEXT NOT NULL,
+    PRIMARY KEY (group_name, program_name)
+);
+
+-- +goose Down
+DROP TABLE program_groups;
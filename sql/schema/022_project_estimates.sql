@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE project_estimates (
+    project TEXT NOT NULL PRIMARY KEY,
+    estimate_seconds INTEGER NOT NULL,
+    set_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE project_estimates;
@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE incognito (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    until DATETIME
+);
+
+-- +goose Down
+DROP TABLE incognito;
@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE tracked_programs
+ADD remote BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Sessions pulled from other devices were added to lifetimes, and their programs to the tracked programs
+UPDATE tracked_programs
+SET lifetime_seconds = MAX(0, lifetime_seconds - (
+    SELECT COALESCE(SUM(duration_seconds), 0) FROM sync_log
+    WHERE sync_log.program_name = tracked_programs.name
+));
+
+UPDATE tracked_programs
+SET remote = TRUE
+WHERE lifetime_seconds = 0 AND name IN (SELECT program_name FROM sync_log);
+
+-- +goose Down
+ALTER TABLE tracked_programs
+DROP COLUMN remote;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE tracked_programs
+ADD session_count INTEGER NOT NULL DEFAULT 0;
+
+-- Sessions recorded on this machine, as counted towards lifetimes: those pulled from other devices are left out
+UPDATE tracked_programs
+SET session_count = MAX(0, (
+    SELECT COUNT(*) FROM session_history
+    WHERE session_history.program_name = tracked_programs.name
+) + (
+    SELECT COUNT(*) FROM session_archive
+    WHERE session_archive.program_name = tracked_programs.name
+) - (
+    SELECT COUNT(*) FROM sync_log
+    WHERE sync_log.program_name = tracked_programs.name
+));
+
+-- +goose Down
+ALTER TABLE tracked_programs
+DROP COLUMN session_count;
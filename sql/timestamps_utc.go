@@ -0,0 +1,90 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+// Migration 025, in Go as SQLite can't parse the times Go writes. Sessions recorded before timestamps were stored in
+// UTC hold the machine's local time, which compares wrongly against the UTC bounds of queries, so each is rewritten
+// as the same instant in UTC
+func init() {
+	goose.AddNamedMigrationContext("025_timestamps_utc.go", timestampsUTCUp, timestampsUTCDown)
+}
+
+// Columns holding session times written before migration 025
+var sessionTimeColumns = []struct{ table, column string }{
+	{"active_sessions", "start_time"},
+	{"session_history", "start_time"},
+	{"session_history", "end_time"},
+	{"session_archive", "start_time"},
+	{"session_archive", "end_time"},
+}
+
+// Layout of time.Time.String, in which the driver writes times
+const storedTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+func timestampsUTCUp(ctx context.Context, tx *sql.Tx) error {
+	for _, c := range sessionTimeColumns {
+		if err := columnToUTC(ctx, tx, c.table, c.column); err != nil {
+			return fmt.Errorf("error converting %s.%s to UTC: %w", c.table, c.column, err)
+		}
+	}
+	return nil
+}
+
+// Local offsets aren't kept, rows stay in UTC, which every version reads as the same instant
+func timestampsUTCDown(ctx context.Context, tx *sql.Tx) error {
+	return nil
+}
+
+// Rewrites the times in column of table that aren't in UTC. Times written with a monotonic clock reading, as
+// time.Now() was, carry an "m=+1.23" suffix, which is dropped
+func columnToUTC(ctx context.Context, tx *sql.Tx, table, column string) error {
+	// #nosec G201 -- table and column come from sessionTimeColumns
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT rowid, CAST(%s AS TEXT) FROM %s", column, table))
+	if err != nil {
+		return err
+	}
+
+	local := map[int64]time.Time{}
+	for rows.Next() {
+		var id int64
+		var stored string
+		if err := rows.Scan(&id, &stored); err != nil {
+			rows.Close()
+			return err
+		}
+		if i := strings.Index(stored, " m="); i >= 0 {
+			stored = stored[:i]
+		}
+		t, err := time.Parse(storedTimeLayout, stored)
+		if err != nil { // Some other layout, written by hand or by a tool, left as it is
+			continue
+		}
+		if t.Location() != time.UTC {
+			local[id] = t
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	// #nosec G201 -- table and column come from sessionTimeColumns
+	update := fmt.Sprintf("UPDATE %s SET %s = ? WHERE rowid = ?", table, column)
+	for id, t := range local {
+		if _, err := tx.ExecContext(ctx, update, t.UTC(), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+// Components recorded in the upgrade log
+const (
+	ComponentSchema = "schema"
+	ComponentConfig = "config"
+)
+
+// A version change applied to the database schema or config file
+type Upgrade struct {
+	From int64
+	To   int64
+}
+
+// Applies pending migrations to db. When an existing database moves to a newer schema version the change is recorded
+// in the upgrade log and returned, a new database being created at the latest version isn't an upgrade
+func migrate(db *sql.DB) (*Upgrade, error) {
+	from, err := goose.GetDBVersion(db)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema version: %w", err)
+	}
+
+	if err := goose.Up(db, "schema"); err != nil {
+		return nil, fmt.Errorf("error migrating database from schema version %d: %w", from, err)
+	}
+
+	to, err := goose.GetDBVersion(db)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema version: %w", err)
+	}
+	if from == 0 || to <= from {
+		return nil, nil
+	}
+
+	upgrade := &Upgrade{From: from, To: to}
+	if err := RecordUpgrade(db, ComponentSchema, *upgrade); err != nil {
+		return upgrade, err
+	}
+	return upgrade, nil
+}
+
+// Adds an entry to the upgrade log
+func RecordUpgrade(db *sql.DB, component string, upgrade Upgrade) error {
+	_, err := db.Exec("INSERT INTO upgrade_log (component, from_version, to_version, upgraded_at) VALUES (?, ?, ?, ?)",
+		component, upgrade.From, upgrade.To, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("error recording %s upgrade: %w", component, err)
+	}
+	return nil
+}